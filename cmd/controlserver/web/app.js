@@ -10,11 +10,75 @@ function tailswanApp() {
         sas: [],
         manualConnectionName: '',
         loadingConnections: {},
+        peerFormat: 'sheet',
 
         nodeInfo: null,
+        haState: null,
+        tunnelHealth: [],
         peers: [],
         serveConfig: null,
 
+        diagForm: {
+            kind: 'ping',
+            connection: '',
+            target: '',
+            port: 443,
+            peer: '',
+            type: 'disco',
+            destination: ''
+        },
+        diagRuns: [],
+
+        schedules: [],
+
+        credentials: [],
+        expiringCerts: [],
+        certKind: 'cert',
+
+        pkiCA: null,
+        pkiCerts: [],
+        pkiForm: {
+            name: '',
+            profile: 'client',
+            sans: '',
+            install: false,
+        },
+
+        rwUsers: [],
+        rwPools: [],
+        rwLeases: [],
+        rwError: '',
+        rwUserForm: {
+            name: '',
+            auth: 'eap-mschapv2',
+        },
+        rwPoolForm: {
+            name: '',
+            addrs: '',
+            dns: '',
+        },
+
+        templates: [],
+        templateName: '',
+        templateValues: {},
+        templateFields: {},
+        templateResult: null,
+
+        configVersions: [],
+        configDiff: null,
+        configPending: null,
+        configWatch: null,
+        reloadPreview: null,
+        confirmTimeout: 0,
+        clock: Date.now(),
+
+        identities: [],
+        identitiesEnforced: false,
+        identitiesError: '',
+
+        fleetEnabled: false,
+        fleetSites: [],
+
         notification: {
             show: false,
             message: '',
@@ -32,12 +96,29 @@ function tailswanApp() {
             this.loadTailscaleStatus();
             this.loadTailscalePeers();
             this.loadTailscaleServe();
+            this.loadDiagResults();
+            this.loadSchedules();
+            this.loadCertificates();
+            this.loadPKI();
+            this.loadRoadWarriors();
+            this.loadIdentities();
+            this.loadTemplates();
+            this.loadConfigHistory();
+            this.loadConfigPending();
+            this.loadConfigWatch();
+            setInterval(() => { this.clock = Date.now(); }, 1000);
+            if (this.currentTab === 'fleet') {
+                this.loadFleet();
+            }
             this.connectSSE();
         },
 
         switchTab(tab) {
             this.currentTab = tab;
             localStorage.setItem('tailswan_current_tab', tab);
+            if (tab === 'fleet') {
+                this.loadFleet();
+            }
         },
 
         async checkServerStatus() {
@@ -45,6 +126,8 @@ function tailswanApp() {
                 const response = await fetch(`${API_BASE}/health`);
                 const data = await response.json();
                 this.serverOnline = data.success;
+                this.haState = data.ha || null;
+                this.tunnelHealth = data.tunnels || [];
             } catch (error) {
                 this.serverOnline = false;
             }
@@ -281,6 +364,68 @@ function tailswanApp() {
             }
         },
 
+        async loadDiagResults() {
+            try {
+                const response = await fetch(`${API_BASE}/diag/results`);
+                const data = await response.json();
+                if (data.success && data.results) {
+                    this.diagRuns = data.results;
+                }
+            } catch (error) {
+                console.error('Error loading diagnostics:', error);
+            }
+        },
+
+        async runDiagnostic() {
+            const form = this.diagForm;
+            const body = { count: 4 };
+            if (['ping', 'tcp', 'mtu'].includes(form.kind)) {
+                body.connection = form.connection.trim();
+                body.target = form.target.trim();
+            }
+            if (form.kind === 'tcp') {
+                body.port = form.port;
+            }
+            if (['tailscale-ping', 'xfrm'].includes(form.kind)) {
+                body.peer = form.peer.trim();
+            }
+            if (form.kind === 'tailscale-ping') {
+                body.type = form.type;
+                body.count = 10;
+            }
+            if (form.kind === 'xfrm') {
+                body.destination = form.destination.trim();
+            }
+
+            try {
+                const response = await fetch(`${API_BASE}/diag/${form.kind}`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify(body),
+                });
+
+                const data = await response.json();
+
+                if (data.success) {
+                    this.upsertDiagRun({ id: data.id, kind: form.kind, lines: [], done: false });
+                } else {
+                    this.showNotification(data.error || data.message, 'error');
+                }
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        upsertDiagRun(run) {
+            const index = this.diagRuns.findIndex(r => r.id === run.id);
+            if (index === -1) {
+                this.diagRuns.unshift(run);
+                this.diagRuns = this.diagRuns.slice(0, 20);
+            } else {
+                this.diagRuns[index] = { ...this.diagRuns[index], ...run };
+            }
+        },
+
         refreshAll() {
             this.checkServerStatus();
             this.loadConnections();
@@ -288,6 +433,629 @@ function tailswanApp() {
             this.loadTailscaleStatus();
             this.loadTailscalePeers();
             this.loadTailscaleServe();
+            this.loadSchedules();
+            if (this.currentTab === 'fleet') {
+                this.loadFleet();
+            }
+        },
+
+        async loadSchedules() {
+            try {
+                const response = await fetch(`${API_BASE}/schedules`);
+                const data = await response.json();
+                this.schedules = data.connections || [];
+            } catch (error) {
+                console.error('Error loading schedules:', error);
+            }
+        },
+
+        scheduleDetails(st) {
+            let details = `${st.state} (${st.reason})`;
+            if (st.lease) {
+                details += ` · lease by ${st.lease.owner} until ${new Date(st.lease.until).toLocaleString()}`;
+            }
+            if (st.next) {
+                details += ` · ${st.next.state} at ${new Date(st.next.at).toLocaleString()}`;
+            }
+            return details;
+        },
+
+        tunnelHealthDetails(t) {
+            let details = `${t.status} · ${t.kind} ${t.target}`;
+            if (t.status === 'healthy') {
+                details += ` · ${t.latency_ms} ms`;
+            } else if (t.error) {
+                details += ` · ${t.error}`;
+            }
+            if (t.restarts > 0) {
+                details += ` · restarted ${t.restarts}×`;
+            }
+            return details;
+        },
+
+        async leaseConnection(name, minutes) {
+            try {
+                const response = await fetch(`${API_BASE}/schedules/leases`, {
+                    method: minutes ? 'POST' : 'DELETE',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ name: name, minutes: minutes }),
+                });
+                const data = await response.json();
+                this.showNotification(data.success ? data.message : (data.error || data.message), data.success ? 'success' : 'error');
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async loadCertificates() {
+            try {
+                const response = await fetch(`${API_BASE}/certs`);
+                this.updateCertificates(await response.json());
+            } catch (error) {
+                console.error('Error loading certificates:', error);
+            }
+        },
+
+        updateCertificates(data) {
+            this.credentials = data.credentials || [];
+            const expiring = (data.expiring || []).map(c => `${c.kind}/${c.name}`);
+            const added = expiring.filter(name => !this.expiringCerts.includes(name));
+            this.expiringCerts = expiring;
+            if (added.length > 0) {
+                this.showNotification(`Certificates expire soon: ${added.join(', ')}`, 'warning');
+            }
+        },
+
+        credentialDetails(c) {
+            if (c.error) {
+                return c.error;
+            }
+            if (c.kind === 'key') {
+                return c.key_type + (c.certificates ? ` · for ${c.certificates.join(', ')}` : '');
+            }
+            if (c.kind === 'crl') {
+                let details = `${c.issuer} · ${c.revoked || 0} revoked`;
+                if (c.next_update) {
+                    details += ` · next update ${new Date(c.next_update).toLocaleDateString()}`;
+                }
+                return details;
+            }
+            let details = c.subject;
+            if (c.sans) {
+                details += ` · ${c.sans.join(', ')}`;
+            }
+            return `${details} · expires ${new Date(c.not_after).toLocaleDateString()}`;
+        },
+
+        async uploadCredential(event) {
+            const file = event.target.files[0];
+            if (!file) {
+                return;
+            }
+            try {
+                const response = await fetch(`${API_BASE}/certs/${this.certKind}/${encodeURIComponent(file.name)}`, {
+                    method: 'PUT',
+                    body: file,
+                });
+                const data = await response.json();
+                this.showNotification(data.success ? data.message : (data.error || data.message), data.success ? 'success' : 'error');
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+            event.target.value = '';
+        },
+
+        async loadPKI() {
+            try {
+                const response = await fetch(`${API_BASE}/pki`);
+                this.updatePKI(await response.json());
+            } catch (error) {
+                console.error('Error loading the CA:', error);
+            }
+        },
+
+        updatePKI(data) {
+            this.pkiCA = data.ca || null;
+            this.pkiCerts = data.certificates || [];
+        },
+
+        issuedDetails(c) {
+            let details = c.serial;
+            if (c.sans) {
+                details += ` · ${c.sans.join(', ')}`;
+            }
+            if (c.revoked) {
+                return `${details} · revoked ${new Date(c.revoked).toLocaleDateString()}`;
+            }
+            return `${details} · expires ${new Date(c.not_after).toLocaleDateString()}`;
+        },
+
+        async issueCertificate() {
+            const form = this.pkiForm;
+            const body = {
+                name: form.name.trim(),
+                profile: form.profile,
+                sans: form.sans.split(',').map(s => s.trim()).filter(s => s),
+                install: form.install,
+            };
+            try {
+                const response = await fetch(`${API_BASE}/pki/certs`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify(body),
+                });
+                const data = await response.json();
+                if (data.success) {
+                    this.showNotification(data.message, 'success');
+                    form.name = '';
+                    form.sans = '';
+                } else {
+                    this.showNotification(data.error || data.message, 'error');
+                }
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async revokeCertificate(c) {
+            if (!confirm(`Revoke ${c.name}? Peers using it can no longer connect.`)) {
+                return;
+            }
+            try {
+                const response = await fetch(`${API_BASE}/pki/certs/${encodeURIComponent(c.name)}/revoke`, { method: 'POST' });
+                const data = await response.json();
+                this.showNotification(data.success ? data.message : (data.error || data.message), data.success ? 'success' : 'error');
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async exportCertificate(c, format) {
+            const body = { format };
+            if (format === 'p12') {
+                body.password = prompt(`Password for ${c.name}.p12:`);
+                if (!body.password) {
+                    return;
+                }
+            }
+            try {
+                const response = await fetch(`${API_BASE}/pki/certs/${encodeURIComponent(c.name)}/export`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify(body),
+                });
+                if (!response.ok) {
+                    const data = await response.json();
+                    this.showNotification(data.error || data.message, 'error');
+                    return;
+                }
+                const url = URL.createObjectURL(await response.blob());
+                const link = document.createElement('a');
+                link.href = url;
+                link.download = `${c.name}.${format}`;
+                link.click();
+                URL.revokeObjectURL(url);
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async loadRoadWarriors() {
+            try {
+                const response = await fetch(`${API_BASE}/roadwarrior`);
+                this.updateRoadWarriors(await response.json());
+            } catch (error) {
+                console.error('Error loading road-warrior users:', error);
+            }
+        },
+
+        updateRoadWarriors(data) {
+            this.rwUsers = data.users || [];
+            this.rwPools = data.pools || [];
+            this.rwLeases = data.leases || [];
+            this.rwError = data.error || '';
+        },
+
+        userLease(u) {
+            for (const pool of this.rwLeases) {
+                const lease = (pool.leases || []).find(l => l.identity === u.name && l.status === 'online');
+                if (lease) {
+                    return lease;
+                }
+            }
+            return null;
+        },
+
+        poolDetails(p) {
+            const loaded = this.rwLeases.find(l => l.name === p.name);
+            let details = p.addrs;
+            if (p.dns) {
+                details += ` · DNS ${p.dns.join(', ')}`;
+            }
+            if (!loaded) {
+                return `${details} · not loaded`;
+            }
+            return `${details} · ${loaded.online}/${loaded.size} online, ${loaded.offline} offline`;
+        },
+
+        async loadTemplates() {
+            try {
+                const response = await fetch(`${API_BASE}/templates`);
+                const data = await response.json();
+                this.templates = data.templates || [];
+            } catch (error) {
+                console.error('Error loading templates:', error);
+            }
+        },
+
+        currentTemplate() {
+            return this.templates.find(t => t.name === this.templateName) || null;
+        },
+
+        selectTemplate() {
+            const t = this.currentTemplate();
+            this.templateValues = {};
+            (t ? t.params : []).forEach(p => { this.templateValues[p.name] = p.default || ''; });
+            this.templateFields = {};
+            this.templateResult = null;
+        },
+
+        async renderTemplate(load) {
+            if (load && !confirm(`Load the rendered connections into charon?`)) {
+                return;
+            }
+            try {
+                const response = await fetch(`${API_BASE}/templates/${encodeURIComponent(this.templateName)}`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ values: this.templateValues, load: load, confirm_timeout: load ? this.confirmTimeout : 0 }),
+                });
+                const data = await response.json();
+                this.templateFields = data.fields || {};
+                if (!data.success) {
+                    this.showNotification(data.error || data.message, 'error');
+                    return;
+                }
+                this.templateResult = data;
+                if (data.loaded) {
+                    this.showNotification(`${data.message}, saved to ${data.file}`, 'success');
+                    this.loadConnections();
+                    this.loadConfigHistory();
+                }
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async loadConfigHistory() {
+            try {
+                const response = await fetch(`${API_BASE}/config/history`);
+                const data = await response.json();
+                this.configVersions = data.versions || [];
+            } catch (error) {
+                console.error('Error loading configuration history:', error);
+            }
+        },
+
+        configVersionDetails(v) {
+            const author = v.author.name || v.author.login || 'unknown';
+            let details = `${new Date(v.time).toLocaleString()} · ${v.source} by ${author}`;
+            if (v.changed && v.changed.length > 0) {
+                details += ` · ${v.changed.join(', ')}`;
+            }
+            return details;
+        },
+
+        async showConfigDiff(v) {
+            if (this.configDiff && this.configDiff.to === v.version) {
+                this.configDiff = null;
+                return;
+            }
+            try {
+                const response = await fetch(`${API_BASE}/config/history/${v.version}/diff`);
+                const data = await response.json();
+                if (!data.success) {
+                    this.showNotification(data.error || data.message, 'error');
+                    return;
+                }
+                this.configDiff = data;
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async rollbackConfig(v) {
+            if (!confirm(`Roll back to version ${v.version}? Its connections are loaded and its routes advertised.`)) {
+                return;
+            }
+            try {
+                const response = await fetch(`${API_BASE}/config/history/${v.version}/rollback`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ confirm_timeout: this.confirmTimeout }),
+                });
+                const data = await response.json();
+                this.showNotification(data.success ? data.message : (data.error || data.message), data.success ? 'success' : 'error');
+                if (data.success) {
+                    this.configDiff = null;
+                    this.loadConnections();
+                    this.loadConfigHistory();
+                }
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async loadConfigPending() {
+            try {
+                const response = await fetch(`${API_BASE}/config/pending`);
+                const data = await response.json();
+                this.configPending = data.pending || null;
+            } catch (error) {
+                console.error('Error loading the pending change:', error);
+            }
+        },
+
+        async previewReload() {
+            if (this.reloadPreview) {
+                this.reloadPreview = null;
+                return;
+            }
+            try {
+                const response = await fetch(`${API_BASE}/config/dry-run`);
+                const data = await response.json();
+                if (!data.success) {
+                    this.showNotification(data.error || data.message, 'error');
+                    return;
+                }
+                this.reloadPreview = data.text;
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async loadConfigWatch() {
+            try {
+                const response = await fetch(`${API_BASE}/config/watch`);
+                this.configWatch = await response.json();
+            } catch (error) {
+                console.error('Error loading the configuration watcher:', error);
+            }
+        },
+
+        configWatchDetails() {
+            const last = this.configWatch.last;
+            if (!last) {
+                return 'Watching swanctl.conf and conf.d for edits';
+            }
+            const when = new Date(last.time).toLocaleString();
+            if (last.status === 'invalid') {
+                return `Edit of ${last.files.join(', ')} not applied at ${when}: ${last.error}`;
+            }
+            return `Edit of ${last.files.join(', ')} ${last.status} at ${when}` + (last.error ? `: ${last.error}` : '');
+        },
+
+        pendingCountdown() {
+            const seconds = Math.max(0, Math.round((new Date(this.configPending.deadline) - this.clock) / 1000));
+            const author = this.configPending.author.name || this.configPending.author.login || 'unknown';
+            return `By ${author} · reverted in ${Math.floor(seconds / 60)}:${String(seconds % 60).padStart(2, '0')} unless confirmed`;
+        },
+
+        async confirmConfig() {
+            try {
+                const response = await fetch(`${API_BASE}/config/confirm`, { method: 'POST' });
+                const data = await response.json();
+                this.showNotification(data.success ? data.message : (data.error || data.message), data.success ? 'success' : 'error');
+                this.loadConfigPending();
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async revertConfig() {
+            if (!confirm('Revert the change now? The configuration and routes from before it are restored, undoing any change made since.')) {
+                return;
+            }
+            try {
+                const response = await fetch(`${API_BASE}/config/revert`, { method: 'POST' });
+                const data = await response.json();
+                this.showNotification(data.success ? data.message : (data.error || data.message), data.success ? 'success' : 'error');
+                this.loadConfigPending();
+                this.loadConnections();
+                this.loadConfigHistory();
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async loadIdentities() {
+            try {
+                const response = await fetch(`${API_BASE}/identities`);
+                this.updateIdentities(await response.json());
+            } catch (error) {
+                console.error('Error loading identities:', error);
+            }
+        },
+
+        updateIdentities(data) {
+            this.identities = data.identities || [];
+            this.identitiesEnforced = data.enforced || false;
+            this.identitiesError = data.error || '';
+        },
+
+        identityDetails(m) {
+            let details = `${m.addresses.join(', ')} · ${m.connection} from ${m.remote_host}`;
+            if (this.identitiesEnforced) {
+                details += m.allowed && m.allowed.length > 0 ? ` · may reach ${m.allowed.join(', ')}` : ' · no tailnet access';
+            }
+            return details;
+        },
+
+        async postRoadWarrior(path, body) {
+            const response = await fetch(`${API_BASE}/roadwarrior/${path}`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(body),
+            });
+            return response.json();
+        },
+
+        async addRoadWarrior() {
+            const form = this.rwUserForm;
+            try {
+                const data = await this.postRoadWarrior('users', { name: form.name.trim(), auth: form.auth });
+                if (!data.success) {
+                    this.showNotification(data.error || data.message, 'error');
+                    return;
+                }
+                form.name = '';
+                if (data.user && data.user.password) {
+                    // The password is only returned once.
+                    prompt(`${data.message}. Password, shown once:`, data.user.password);
+                } else {
+                    this.showNotification(data.message, 'success');
+                }
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async deleteRoadWarrior(u) {
+            if (!confirm(`Delete ${u.name}? They can no longer connect.`)) {
+                return;
+            }
+            try {
+                const response = await fetch(`${API_BASE}/roadwarrior/users/${encodeURIComponent(u.name)}`, { method: 'DELETE' });
+                const data = await response.json();
+                this.showNotification(data.success ? data.message : (data.error || data.message), data.success ? 'success' : 'error');
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async downloadProfile(u, format) {
+            const body = { format };
+            if (u.auth === 'eap-tls') {
+                body.password = prompt(`Password protecting ${u.name}'s certificate:`);
+                if (!body.password) {
+                    return;
+                }
+            }
+            try {
+                const response = await fetch(`${API_BASE}/roadwarrior/users/${encodeURIComponent(u.name)}/profile`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify(body),
+                });
+                if (!response.ok) {
+                    const data = await response.json();
+                    this.showNotification(data.error || data.message, 'error');
+                    return;
+                }
+                const url = URL.createObjectURL(await response.blob());
+                const link = document.createElement('a');
+                link.href = url;
+                link.download = `${u.name}.${format}`;
+                link.click();
+                URL.revokeObjectURL(url);
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async downloadPeerConfig(name) {
+            try {
+                const response = await fetch(`${API_BASE}/peer-config/${encodeURIComponent(name)}?format=${encodeURIComponent(this.peerFormat)}`);
+                if (!response.ok) {
+                    const data = await response.json();
+                    this.showNotification(data.error || data.message, 'error');
+                    return;
+                }
+                const disposition = response.headers.get('Content-Disposition') || '';
+                const match = disposition.match(/filename="([^"]+)"/);
+                const url = URL.createObjectURL(await response.blob());
+                const link = document.createElement('a');
+                link.href = url;
+                link.download = match ? match[1] : `${name}-${this.peerFormat}.txt`;
+                link.click();
+                URL.revokeObjectURL(url);
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async addPool() {
+            const form = this.rwPoolForm;
+            const body = {
+                name: form.name.trim(),
+                addrs: form.addrs.trim(),
+                dns: form.dns.split(',').map(s => s.trim()).filter(s => s),
+            };
+            try {
+                const data = await this.postRoadWarrior('pools', body);
+                if (data.success) {
+                    this.showNotification(data.message, 'success');
+                    form.name = '';
+                    form.addrs = '';
+                    form.dns = '';
+                } else {
+                    this.showNotification(data.error || data.message, 'error');
+                }
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async deletePool(p) {
+            if (!confirm(`Delete pool ${p.name}?`)) {
+                return;
+            }
+            try {
+                const response = await fetch(`${API_BASE}/roadwarrior/pools/${encodeURIComponent(p.name)}`, { method: 'DELETE' });
+                const data = await response.json();
+                this.showNotification(data.success ? data.message : (data.error || data.message), data.success ? 'success' : 'error');
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async deleteCredential(c) {
+            if (!confirm(`Delete ${c.kind} ${c.name}?`)) {
+                return;
+            }
+            try {
+                const response = await fetch(`${API_BASE}/certs/${c.kind}/${encodeURIComponent(c.name)}`, { method: 'DELETE' });
+                const data = await response.json();
+                this.showNotification(data.success ? data.message : (data.error || data.message), data.success ? 'success' : 'error');
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
+        },
+
+        async loadFleet() {
+            try {
+                const response = await fetch(`${API_BASE}/fleet`);
+                const data = await response.json();
+                this.fleetEnabled = !!data.enabled;
+                this.fleetSites = data.sites || [];
+            } catch (error) {
+                console.error('Error loading fleet:', error);
+            }
+        },
+
+        async fleetConnection(gateway, name, direction) {
+            try {
+                const response = await fetch(`${API_BASE}/fleet/proxy/${encodeURIComponent(gateway)}/vici/connections/${direction}`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ name: name }),
+                });
+                const data = await response.json();
+                this.showNotification(data.success ? `${gateway}: ${data.message}` : (data.error || data.message), data.success ? 'success' : 'error');
+                setTimeout(() => this.loadFleet(), 1000);
+            } catch (error) {
+                this.showNotification(`Error: ${error.message}`, 'error');
+            }
         },
 
         showNotification(message, type = 'info') {
@@ -329,6 +1097,72 @@ function tailswanApp() {
                 this.updateNodeInfo(data);
             });
 
+            this.eventSource.addEventListener('ha-update', (e) => {
+                this.haState = JSON.parse(e.data);
+            });
+
+            this.eventSource.addEventListener('config-pending', (e) => {
+                const hadPending = this.configPending !== null;
+                this.configPending = JSON.parse(e.data).pending || null;
+                if (hadPending && !this.configPending) {
+                    this.loadConnections();
+                    this.loadConfigHistory();
+                }
+            });
+
+            this.eventSource.addEventListener('config-watch', (e) => {
+                const event = JSON.parse(e.data);
+                this.configWatch = { enabled: true, last: event };
+                if (event.status === 'applied') {
+                    this.showNotification(`Applied the edit of ${event.files.join(', ')}`, 'success');
+                } else {
+                    this.showNotification(this.configWatchDetails(), event.status === 'invalid' ? 'warning' : 'error');
+                }
+                if (event.status !== 'invalid') {
+                    this.loadConnections();
+                    this.loadConfigHistory();
+                }
+            });
+
+            this.eventSource.addEventListener('tunnel-health', (e) => {
+                this.tunnelHealth = JSON.parse(e.data).tunnels || [];
+            });
+
+            this.eventSource.addEventListener('roadwarrior-update', (e) => {
+                this.updateRoadWarriors(JSON.parse(e.data));
+            });
+
+            this.eventSource.addEventListener('identity-update', (e) => {
+                this.updateIdentities(JSON.parse(e.data));
+            });
+
+            this.eventSource.addEventListener('pki-update', (e) => {
+                this.updatePKI(JSON.parse(e.data));
+            });
+
+            this.eventSource.addEventListener('certs-update', (e) => {
+                this.updateCertificates(JSON.parse(e.data));
+            });
+
+            this.eventSource.addEventListener('schedule-update', (e) => {
+                this.schedules = JSON.parse(e.data).connections || [];
+            });
+
+            this.eventSource.addEventListener('diag-progress', (e) => {
+                const data = JSON.parse(e.data);
+                const run = this.diagRuns.find(r => r.id === data.id);
+                if (run) {
+                    run.lines.push(data.line);
+                } else {
+                    this.upsertDiagRun({ id: data.id, kind: data.kind, lines: [data.line], done: false });
+                }
+            });
+
+            this.eventSource.addEventListener('diag-result', (e) => {
+                const data = JSON.parse(e.data);
+                this.upsertDiagRun(data);
+            });
+
             this.eventSource.onopen = () => {
                 this.reconnectDelay = 1000;
             };
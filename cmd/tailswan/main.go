@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/klowdo/tailswan/internal/cli"
 	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/logfile"
 	"github.com/klowdo/tailswan/internal/supervisor"
 	"github.com/klowdo/tailswan/internal/version"
 )
@@ -38,15 +40,15 @@ var rootCmd = &cobra.Command{
 	Long:  `TailSwan is a unified supervisor for managing strongSwan IPsec and Tailscale VPN connections.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		cfg = config.Load()
-		initLogger(cfg)
+		initLogger(cfg, os.Stdout)
 	},
 }
 
-func initLogger(cfg *config.Config) {
+func initLogger(cfg *config.Config, w io.Writer) {
 	opts := &slog.HandlerOptions{
 		Level: cfg.GetLogLevel(),
 	}
-	handler := slog.NewTextHandler(os.Stdout, opts)
+	handler := slog.NewTextHandler(w, opts)
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
 }
@@ -62,7 +64,18 @@ var serveCmd = &cobra.Command{
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
 
+		logOutput := io.Writer(os.Stdout)
+		if logFile, err := logfile.Open(cfg.LogPath(), logfile.DefaultMaxSize); err != nil {
+			slog.Warn("Logging to stdout only", "path", cfg.LogPath(), "error", err)
+		} else {
+			defer logFile.Close() //nolint:errcheck
+			logOutput = io.MultiWriter(os.Stdout, logFile)
+			initLogger(cfg, logOutput)
+		}
+
 		supervisorCfg := supervisor.Config{
+			LogOutput:         logOutput,
+			StateDir:          cfg.StateDir,
 			ControlPort:       cfg.Port,
 			TailscaleStateDir: cfg.Tailscale.StateDir,
 			TailscaleSocket:   cfg.Tailscale.Socket,
@@ -72,6 +85,7 @@ var serveCmd = &cobra.Command{
 				Socket:      cfg.Tailscale.Socket,
 				Hostname:    cfg.Tailscale.Hostname,
 				AuthKey:     cfg.Tailscale.AuthKey,
+				TunMode:     cfg.Tailscale.TunMode,
 				Routes:      cfg.Tailscale.Routes,
 				SSH:         cfg.Tailscale.SSH,
 				ExtraArgs:   cfg.Tailscale.ExtraArgs,
@@ -80,6 +94,37 @@ var serveCmd = &cobra.Command{
 			SwanConfigPath:  cfg.Swan.ConfigPath,
 			SwanAutoStart:   cfg.Swan.AutoStart,
 			SwanConnections: cfg.Swan.Connections,
+			SwanSchedules:   cfg.Swan.Schedules,
+			XFRMInterfaces:  cfg.Swan.XFRMInterfaces,
+			BGP: supervisor.BGPConfig{
+				Enabled:         cfg.BGP.Enabled,
+				ASN:             cfg.BGP.ASN,
+				RouterID:        cfg.BGP.RouterID,
+				Neighbors:       cfg.BGP.Neighbors,
+				ImportFilter:    cfg.BGP.ImportFilter,
+				AnnounceTailnet: cfg.BGP.AnnounceTailnet,
+			},
+			HA: supervisor.HAConfig{
+				Mode:      cfg.HA.Mode,
+				NodeID:    cfg.HA.NodeID,
+				Peer:      cfg.HA.Peer,
+				LeaseFile: cfg.HA.LeaseFile,
+				Priority:  cfg.HA.Priority,
+				LeaseTTL:  cfg.HA.LeaseTTL,
+			},
+			Watchdog: supervisor.WatchdogConfig{
+				Probes:    cfg.Watchdog.Probes,
+				Interval:  cfg.Watchdog.Interval,
+				Timeout:   cfg.Watchdog.Timeout,
+				Threshold: cfg.Watchdog.Threshold,
+			},
+			Firewall: supervisor.FirewallConfig{
+				Backend:       cfg.Firewall.Backend,
+				Access:        cfg.RW.Access,
+				Masquerade:    cfg.Firewall.Masquerade,
+				MSSClamp:      cfg.Firewall.MSSClamp,
+				DropUnmatched: cfg.Firewall.DropUnmatched,
+			},
 		}
 
 		if err := supervisor.SetupSystem(); err != nil {
@@ -116,5 +161,19 @@ func init() {
 		cli.NewStartCmd(),
 		cli.NewStopCmd(),
 		cli.NewReloadCmd(),
+		cli.NewFirewallCmd(),
+		cli.NewDiagCmd(),
+		cli.NewDoctorCmd(),
+		cli.NewSupportBundleCmd(),
+		cli.NewScheduleCmd(),
+		cli.NewCertsCmd(),
+		cli.NewPKICmd(),
+		cli.NewRoadWarriorCmd(),
+		cli.NewIdentitiesCmd(),
+		cli.NewExportPeerConfigCmd(),
+		cli.NewImportCmd(),
+		cli.NewTemplatesCmd(),
+		cli.NewHistoryCmd(),
+		cli.NewOperatorCmd(),
 	)
 }
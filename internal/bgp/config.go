@@ -0,0 +1,116 @@
+package bgp
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// APIHost and APIPort are where gobgpd serves its gRPC API. It is bound to
+// loopback so the speaker cannot be reconfigured from the tailnet.
+const (
+	APIHost = "127.0.0.1"
+	APIPort = "50051"
+)
+
+type Neighbor struct {
+	Address netip.Addr
+	ASN     uint32
+}
+
+func (n Neighbor) String() string {
+	return fmt.Sprintf("%d@%s", n.ASN, n.Address)
+}
+
+// ParseNeighbor parses "asn@address", e.g. "65001@169.254.10.2".
+func ParseNeighbor(s string) (Neighbor, error) {
+	asn, addr, ok := strings.Cut(strings.TrimSpace(s), "@")
+	if !ok {
+		return Neighbor{}, fmt.Errorf("neighbor %q: expected asn@address", s)
+	}
+	n, err := parseASN(asn)
+	if err != nil {
+		return Neighbor{}, fmt.Errorf("neighbor %q: %w", s, err)
+	}
+	a, err := netip.ParseAddr(addr)
+	if err != nil {
+		return Neighbor{}, fmt.Errorf("neighbor %q: %w", s, err)
+	}
+	return Neighbor{Address: a, ASN: n}, nil
+}
+
+func parseASN(s string) (uint32, error) {
+	n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(s), "AS"), 10, 32)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid AS number %q", s)
+	}
+	return uint32(n), nil
+}
+
+// Config is the speaker configuration handed to gobgpd.
+type Config struct {
+	RouterID  netip.Addr
+	Neighbors []Neighbor
+	Filter    Filter
+	ASN       uint32
+}
+
+// NewConfig validates the raw settings from the environment.
+func NewConfig(asn, routerID string, neighbors, filter []string) (*Config, error) {
+	cfg := &Config{}
+
+	var err error
+	if cfg.ASN, err = parseASN(asn); err != nil {
+		return nil, err
+	}
+	if cfg.RouterID, err = netip.ParseAddr(routerID); err != nil || !cfg.RouterID.Is4() {
+		return nil, fmt.Errorf("router ID %q must be an IPv4 address", routerID)
+	}
+	if len(neighbors) == 0 {
+		return nil, fmt.Errorf("no BGP neighbors configured")
+	}
+	for _, s := range neighbors {
+		n, err := ParseNeighbor(s)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Neighbors = append(cfg.Neighbors, n)
+	}
+	if cfg.Filter, err = ParseFilter(filter); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Render produces the gobgpd TOML configuration.
+func (c *Config) Render() string {
+	var b strings.Builder
+	b.WriteString("[global.config]\n")
+	fmt.Fprintf(&b, "  as = %d\n", c.ASN)
+	fmt.Fprintf(&b, "  router-id = %q\n", c.RouterID)
+
+	for _, n := range c.Neighbors {
+		b.WriteString("\n[[neighbors]]\n")
+		b.WriteString("  [neighbors.config]\n")
+		fmt.Fprintf(&b, "    neighbor-address = %q\n", n.Address)
+		fmt.Fprintf(&b, "    peer-as = %d\n", n.ASN)
+		family := "ipv4-unicast"
+		if n.Address.Is6() {
+			family = "ipv6-unicast"
+		}
+		b.WriteString("  [[neighbors.afi-safis]]\n")
+		b.WriteString("    [neighbors.afi-safis.config]\n")
+		fmt.Fprintf(&b, "      afi-safi-name = %q\n", family)
+	}
+	return b.String()
+}
+
+func (c *Config) isNeighbor(addr netip.Addr) bool {
+	for _, n := range c.Neighbors {
+		if n.Address == addr {
+			return true
+		}
+	}
+	return false
+}
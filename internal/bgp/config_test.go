@@ -0,0 +1,57 @@
+package bgp
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestParseNeighbor(t *testing.T) {
+	n, err := ParseNeighbor("65001@169.254.10.2")
+	if err != nil {
+		t.Fatalf("ParseNeighbor() error = %v", err)
+	}
+	if n.ASN != 65001 || n.Address != netip.MustParseAddr("169.254.10.2") {
+		t.Errorf("unexpected neighbor %+v", n)
+	}
+
+	if n, err := ParseNeighbor("AS4200000001@fd00::2"); err != nil || n.ASN != 4200000001 {
+		t.Errorf("expected 4-byte ASN with AS prefix, got %+v, %v", n, err)
+	}
+
+	for _, bad := range []string{"169.254.10.2", "0@169.254.10.2", "abc@169.254.10.2", "65001@not-an-ip"} {
+		if _, err := ParseNeighbor(bad); err == nil {
+			t.Errorf("ParseNeighbor(%q) expected error", bad)
+		}
+	}
+}
+
+func TestNewConfig(t *testing.T) {
+	if _, err := NewConfig("65000", "10.1.0.1", nil, nil); err == nil {
+		t.Error("expected error without neighbors")
+	}
+	if _, err := NewConfig("65000", "fd00::1", []string{"65001@169.254.10.2"}, nil); err == nil {
+		t.Error("expected error for IPv6 router ID")
+	}
+
+	cfg, err := NewConfig("65000", "10.1.0.1", []string{"65001@169.254.10.2", "65002@fd00::2"}, []string{"10.20.0.0/16"})
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+
+	conf := cfg.Render()
+	expected := []string{
+		"as = 65000",
+		`router-id = "10.1.0.1"`,
+		`neighbor-address = "169.254.10.2"`,
+		"peer-as = 65001",
+		`afi-safi-name = "ipv4-unicast"`,
+		`neighbor-address = "fd00::2"`,
+		`afi-safi-name = "ipv6-unicast"`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(conf, e) {
+			t.Errorf("expected config to contain %q, got:\n%s", e, conf)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package bgp
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+var (
+	tailnetIPv4 = netip.MustParsePrefix("100.64.0.0/10")
+	tailnetIPv6 = netip.MustParsePrefix("fd7a:115c:a1e0::/48")
+)
+
+// FilterRule accepts Prefix and any more-specific prefix whose length lies
+// within MinLen..MaxLen.
+type FilterRule struct {
+	Prefix netip.Prefix
+	MinLen int
+	MaxLen int
+}
+
+// Filter is the import policy for learned prefixes. A prefix is accepted
+// when any rule matches; an empty filter accepts nothing.
+type Filter []FilterRule
+
+// ParseFilter parses entries of the form "10.20.0.0/16" (the prefix and
+// everything inside it) or "10.20.0.0/16:16..24" (only lengths 16 to 24).
+func ParseFilter(entries []string) (Filter, error) {
+	filter := make(Filter, 0, len(entries))
+	for _, entry := range entries {
+		prefix, lengths, hasRange := cutRange(strings.TrimSpace(entry))
+		p, err := netip.ParsePrefix(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("filter %q: %w", entry, err)
+		}
+		rule := FilterRule{Prefix: p.Masked(), MinLen: p.Bits(), MaxLen: p.Addr().BitLen()}
+		if hasRange {
+			minStr, maxStr, ok := strings.Cut(lengths, "..")
+			if !ok {
+				return nil, fmt.Errorf("filter %q: expected prefix:min..max", entry)
+			}
+			if rule.MinLen, err = strconv.Atoi(minStr); err != nil {
+				return nil, fmt.Errorf("filter %q: %w", entry, err)
+			}
+			if rule.MaxLen, err = strconv.Atoi(maxStr); err != nil {
+				return nil, fmt.Errorf("filter %q: %w", entry, err)
+			}
+			if rule.MinLen < p.Bits() || rule.MaxLen < rule.MinLen || rule.MaxLen > p.Addr().BitLen() {
+				return nil, fmt.Errorf("filter %q: length range must lie between /%d and /%d", entry, p.Bits(), p.Addr().BitLen())
+			}
+		}
+		filter = append(filter, rule)
+	}
+	return filter, nil
+}
+
+// cutRange splits off the ":min..max" suffix, which follows the prefix
+// length so IPv6 colons are left alone.
+func cutRange(entry string) (prefix, lengths string, ok bool) {
+	slash := strings.LastIndex(entry, "/")
+	if slash < 0 {
+		return entry, "", false
+	}
+	bits, lengths, ok := strings.Cut(entry[slash:], ":")
+	return entry[:slash] + bits, lengths, ok
+}
+
+// Allow reports whether a learned prefix may be advertised to the tailnet.
+// Default routes and tailnet ranges are always rejected, whatever the rules
+// say, so a peer cannot turn the gateway into an exit node or shadow tailnet
+// addresses.
+func (f Filter) Allow(p netip.Prefix) bool {
+	if p.Bits() == 0 || tailnetIPv4.Overlaps(p) || tailnetIPv6.Overlaps(p) {
+		return false
+	}
+	for _, rule := range f {
+		if rule.Prefix.Addr().Is4() != p.Addr().Is4() {
+			continue
+		}
+		if rule.Prefix.Contains(p.Addr()) && p.Bits() >= rule.MinLen && p.Bits() <= rule.MaxLen {
+			return true
+		}
+	}
+	return false
+}
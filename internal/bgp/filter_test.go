@@ -0,0 +1,61 @@
+package bgp
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestFilterAllow(t *testing.T) {
+	filter, err := ParseFilter([]string{"10.20.0.0/16", "172.16.0.0/12:16..24", "fd00:20::/48"})
+	if err != nil {
+		t.Fatalf("ParseFilter() error = %v", err)
+	}
+
+	tests := []struct {
+		prefix string
+		want   bool
+	}{
+		{"10.20.0.0/16", true},
+		{"10.20.5.0/24", true},
+		{"10.21.0.0/24", false},
+		{"10.0.0.0/8", false},
+		{"172.16.0.0/12", false},
+		{"172.16.4.0/22", true},
+		{"172.16.4.128/25", false},
+		{"fd00:20:0:1::/64", true},
+	}
+	for _, tt := range tests {
+		if got := filter.Allow(netip.MustParsePrefix(tt.prefix)); got != tt.want {
+			t.Errorf("Allow(%s) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestFilterAlwaysRejectsDefaultAndTailnet(t *testing.T) {
+	filter, err := ParseFilter([]string{"0.0.0.0/0", "::/0"})
+	if err != nil {
+		t.Fatalf("ParseFilter() error = %v", err)
+	}
+	for _, p := range []string{"0.0.0.0/0", "::/0", "100.64.0.0/10", "100.100.0.0/16", "fd7a:115c:a1e0::/64"} {
+		if filter.Allow(netip.MustParsePrefix(p)) {
+			t.Errorf("Allow(%s) = true, want false", p)
+		}
+	}
+	if !filter.Allow(netip.MustParsePrefix("10.20.0.0/16")) {
+		t.Error("expected catch-all filter to accept 10.20.0.0/16")
+	}
+}
+
+func TestEmptyFilterRejectsEverything(t *testing.T) {
+	if (Filter{}).Allow(netip.MustParsePrefix("10.20.0.0/16")) {
+		t.Error("expected empty filter to reject")
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, bad := range []string{"10.20.0.0", "10.20.0.0/16:8..24", "10.20.0.0/16:24..20", "10.20.0.0/16:16-24", "10.20.0.0/16:16..33"} {
+		if _, err := ParseFilter([]string{bad}); err == nil {
+			t.Errorf("ParseFilter(%q) expected error", bad)
+		}
+	}
+}
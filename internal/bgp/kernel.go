@@ -0,0 +1,61 @@
+package bgp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// SyncKernelRoutes installs a main-table route towards the next hop of every
+// imported path, so forwarded tailnet traffic follows BGP into the tunnel
+// the neighbor sits behind. Routes TailSwan installed earlier for paths that
+// are gone are removed; they are recognised by the "bgp" route protocol.
+func SyncKernelRoutes(paths []Path) error {
+	filter := &netlink.Route{Protocol: unix.RTPROT_BGP, Table: unix.RT_TABLE_MAIN}
+	existing, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, filter, netlink.RT_FILTER_PROTOCOL|netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return fmt.Errorf("list BGP routes: %w", err)
+	}
+
+	var errs []error
+	wanted := make(map[netip.Prefix]bool, len(paths))
+	for _, p := range paths {
+		if !p.NextHop.IsValid() {
+			continue
+		}
+		wanted[p.Prefix] = true
+		route := &netlink.Route{
+			Dst:      prefixNet(p.Prefix),
+			Gw:       p.NextHop.AsSlice(),
+			Protocol: unix.RTPROT_BGP,
+			Table:    unix.RT_TABLE_MAIN,
+		}
+		if err := netlink.RouteReplace(route); err != nil {
+			errs = append(errs, fmt.Errorf("route %s via %s: %w", p.Prefix, p.NextHop, err))
+		}
+	}
+
+	for i := range existing {
+		route := &existing[i]
+		if route.Dst == nil {
+			continue
+		}
+		ones, _ := route.Dst.Mask.Size()
+		addr, ok := netip.AddrFromSlice(route.Dst.IP)
+		if !ok || wanted[netip.PrefixFrom(addr.Unmap(), ones)] {
+			continue
+		}
+		if err := netlink.RouteDel(route); err != nil {
+			errs = append(errs, fmt.Errorf("delete route %s: %w", route.Dst, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func prefixNet(p netip.Prefix) *net.IPNet {
+	return &net.IPNet{IP: p.Addr().AsSlice(), Mask: net.CIDRMask(p.Bits(), p.Addr().BitLen())}
+}
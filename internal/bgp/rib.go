@@ -0,0 +1,204 @@
+package bgp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"sort"
+)
+
+// Path is a best path from gobgpd's global RIB. Neighbor is invalid for
+// paths originated locally.
+type Path struct {
+	NextHop  netip.Addr
+	Neighbor netip.Addr
+	Prefix   netip.Prefix
+}
+
+type runner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func execRunner(ctx context.Context, name string, args ...string) ([]byte, error) {
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return output, fmt.Errorf("%s: %w: %s", name, err, bytes.TrimSpace(exitErr.Stderr))
+		}
+		return output, fmt.Errorf("%s: %w", name, err)
+	}
+	return output, nil
+}
+
+// Client drives gobgpd through the gobgp CLI.
+type Client struct {
+	run runner
+}
+
+func NewClient() *Client {
+	return &Client{run: execRunner}
+}
+
+func (c *Client) gobgp(ctx context.Context, args ...string) ([]byte, error) {
+	return c.run(ctx, "gobgp", append([]string{"-u", APIHost, "-p", APIPort}, args...)...)
+}
+
+// RIB returns the best path for every prefix in the IPv4 and IPv6 global
+// RIBs.
+func (c *Client) RIB(ctx context.Context) ([]Path, error) {
+	var paths []Path
+	for _, family := range []string{"ipv4", "ipv6"} {
+		output, err := c.gobgp(ctx, "global", "rib", "-a", family, "-j")
+		if err != nil {
+			return nil, fmt.Errorf("list %s RIB: %w", family, err)
+		}
+		parsed, err := parseRIB(output)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s RIB: %w", family, err)
+		}
+		paths = append(paths, parsed...)
+	}
+	return paths, nil
+}
+
+func (c *Client) Announce(ctx context.Context, p netip.Prefix) error {
+	_, err := c.gobgp(ctx, "global", "rib", "add", p.String(), "-a", family(p))
+	return err
+}
+
+func (c *Client) Withdraw(ctx context.Context, p netip.Prefix) error {
+	_, err := c.gobgp(ctx, "global", "rib", "del", p.String(), "-a", family(p))
+	return err
+}
+
+// Neighbors returns the session state of every configured neighbor keyed
+// by address, e.g. "established" or "active".
+func (c *Client) Neighbors(ctx context.Context) (map[netip.Addr]string, error) {
+	output, err := c.gobgp(ctx, "neighbor", "-j")
+	if err != nil {
+		return nil, fmt.Errorf("list neighbors: %w", err)
+	}
+	return parseNeighbors(output)
+}
+
+func family(p netip.Prefix) string {
+	if p.Addr().Is4() {
+		return "ipv4"
+	}
+	return "ipv6"
+}
+
+type ribPath struct {
+	NLRI struct {
+		Prefix string `json:"prefix"`
+	} `json:"nlri"`
+	NeighborIP string `json:"neighbor-ip"`
+	Attrs      []struct {
+		NextHop string `json:"nexthop"`
+		Type    int    `json:"type"`
+	} `json:"attrs"`
+	Best bool `json:"best"`
+}
+
+const (
+	attrNextHop   = 3
+	attrMPReachNL = 14
+)
+
+func parseRIB(output []byte) ([]Path, error) {
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 || string(output) == "null" {
+		return nil, nil
+	}
+
+	var rib map[string][]ribPath
+	if err := json.Unmarshal(output, &rib); err != nil {
+		return nil, err
+	}
+
+	paths := make([]Path, 0, len(rib))
+	for key, candidates := range rib {
+		for i := range candidates {
+			rp := &candidates[i]
+			if !rp.Best {
+				continue
+			}
+			prefix := rp.NLRI.Prefix
+			if prefix == "" {
+				prefix = key
+			}
+			p, err := netip.ParsePrefix(prefix)
+			if err != nil {
+				return nil, err
+			}
+			// Locally originated paths report "<nil>" or no neighbor.
+			path := Path{Prefix: p.Masked(), Neighbor: parseAddr(rp.NeighborIP)}
+			for _, attr := range rp.Attrs {
+				if attr.Type == attrNextHop || attr.Type == attrMPReachNL {
+					path.NextHop = parseAddr(attr.NextHop)
+				}
+			}
+			paths = append(paths, path)
+			break
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i].Prefix.String() < paths[j].Prefix.String() })
+	return paths, nil
+}
+
+// parseAddr returns the zero Addr for anything that is not an address.
+func parseAddr(s string) netip.Addr {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr
+}
+
+type neighborJSON struct {
+	Conf struct {
+		NeighborAddress string `json:"neighbor_address"`
+	} `json:"conf"`
+	State struct {
+		NeighborAddress string `json:"neighbor_address"`
+		SessionState    int    `json:"session_state"`
+	} `json:"state"`
+}
+
+// sessionStates follows the BGP FSM numbering of the gobgp API.
+var sessionStates = map[int]string{
+	1: "idle",
+	2: "connect",
+	3: "active",
+	4: "opensent",
+	5: "openconfirm",
+	6: "established",
+}
+
+func parseNeighbors(output []byte) (map[netip.Addr]string, error) {
+	var neighbors []neighborJSON
+	if err := json.Unmarshal(bytes.TrimSpace(output), &neighbors); err != nil {
+		return nil, err
+	}
+	states := make(map[netip.Addr]string, len(neighbors))
+	for i := range neighbors {
+		n := &neighbors[i]
+		addr := n.Conf.NeighborAddress
+		if addr == "" {
+			addr = n.State.NeighborAddress
+		}
+		a, err := netip.ParseAddr(addr)
+		if err != nil {
+			continue
+		}
+		state, ok := sessionStates[n.State.SessionState]
+		if !ok {
+			state = "unknown"
+		}
+		states[a] = state
+	}
+	return states, nil
+}
@@ -0,0 +1,83 @@
+package bgp
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+const sampleRIB = `{
+  "10.20.1.0/24": [
+    {"nlri":{"prefix":"10.20.1.0/24"},"age":1700000000,"best":false,
+     "attrs":[{"type":1,"value":0},{"type":3,"nexthop":"169.254.10.6"}],"neighbor-ip":"169.254.10.6"},
+    {"nlri":{"prefix":"10.20.1.0/24"},"age":1700000000,"best":true,
+     "attrs":[{"type":1,"value":0},{"type":2,"as_paths":[{"segment_type":2,"num":1,"asns":[65001]}]},{"type":3,"nexthop":"169.254.10.2"}],
+     "neighbor-ip":"169.254.10.2"}
+  ],
+  "100.64.0.0/10": [
+    {"nlri":{"prefix":"100.64.0.0/10"},"age":1700000000,"best":true,
+     "attrs":[{"type":1,"value":0},{"type":3,"nexthop":"0.0.0.0"}],"neighbor-ip":"<nil>"}
+  ]
+}`
+
+func TestParseRIB(t *testing.T) {
+	paths, err := parseRIB([]byte(sampleRIB))
+	if err != nil {
+		t.Fatalf("parseRIB() error = %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 best paths, got %+v", paths)
+	}
+
+	learned := paths[0]
+	if learned.Prefix != netip.MustParsePrefix("10.20.1.0/24") {
+		t.Errorf("unexpected prefix %s", learned.Prefix)
+	}
+	if learned.NextHop != netip.MustParseAddr("169.254.10.2") || learned.Neighbor != netip.MustParseAddr("169.254.10.2") {
+		t.Errorf("expected best path via 169.254.10.2, got %+v", learned)
+	}
+	if paths[1].Neighbor.IsValid() {
+		t.Errorf("expected local path without neighbor, got %+v", paths[1])
+	}
+
+	if paths, err := parseRIB([]byte("null\n")); err != nil || paths != nil {
+		t.Errorf("expected empty RIB, got %v, %v", paths, err)
+	}
+}
+
+func TestParseNeighbors(t *testing.T) {
+	output := `[{"conf":{"neighbor_address":"169.254.10.2","peer_asn":65001},"state":{"session_state":6}},
+	            {"conf":{"neighbor_address":"169.254.10.6"},"state":{"session_state":3}}]`
+	states, err := parseNeighbors([]byte(output))
+	if err != nil {
+		t.Fatalf("parseNeighbors() error = %v", err)
+	}
+	if states[netip.MustParseAddr("169.254.10.2")] != "established" || states[netip.MustParseAddr("169.254.10.6")] != "active" {
+		t.Errorf("unexpected states %v", states)
+	}
+}
+
+func TestClientCommands(t *testing.T) {
+	var calls []string
+	c := &Client{run: func(_ context.Context, name string, args ...string) ([]byte, error) {
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		return nil, nil
+	}}
+
+	ctx := context.Background()
+	if err := c.Announce(ctx, netip.MustParsePrefix("100.64.0.0/10")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Withdraw(ctx, netip.MustParsePrefix("fd7a:115c:a1e0::/48")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"gobgp -u 127.0.0.1 -p 50051 global rib add 100.64.0.0/10 -a ipv4",
+		"gobgp -u 127.0.0.1 -p 50051 global rib del fd7a:115c:a1e0::/48 -a ipv6",
+	}
+	if strings.Join(calls, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("commands = %v, want %v", calls, expected)
+	}
+}
@@ -0,0 +1,106 @@
+package bgp
+
+import (
+	"net/netip"
+	"sort"
+
+	"tailscale.com/ipn/ipnstate"
+)
+
+// Imported returns the best paths learned from configured neighbors that
+// pass the import filter, and the prefixes that were rejected.
+func Imported(cfg *Config, paths []Path) (accepted []Path, rejected []netip.Prefix) {
+	for _, p := range paths {
+		if !p.Neighbor.IsValid() || !cfg.isNeighbor(p.Neighbor) {
+			continue
+		}
+		if !cfg.Filter.Allow(p.Prefix) {
+			rejected = append(rejected, p.Prefix)
+			continue
+		}
+		accepted = append(accepted, p)
+	}
+	return accepted, rejected
+}
+
+// Local returns the prefixes originated by this speaker.
+func Local(paths []Path) []netip.Prefix {
+	var local []netip.Prefix
+	for _, p := range paths {
+		if !p.Neighbor.IsValid() {
+			local = append(local, p.Prefix)
+		}
+	}
+	return local
+}
+
+// AdvertiseRoutes merges the statically configured routes with the imported
+// prefixes into the set the node should advertise to the tailnet.
+func AdvertiseRoutes(static []netip.Prefix, imported []Path) []netip.Prefix {
+	routes := append([]netip.Prefix{}, static...)
+	for _, p := range imported {
+		routes = append(routes, p.Prefix)
+	}
+	return Sorted(routes)
+}
+
+// TailnetSubnets returns what to announce back to the neighbors: the tailnet
+// address ranges and the primary subnet routes of other tailnet nodes.
+// Prefixes learned over BGP are excluded so they are not reflected back.
+func TailnetSubnets(status *ipnstate.Status, learned []Path) []netip.Prefix {
+	exclude := make(map[netip.Prefix]bool, len(learned))
+	for _, p := range learned {
+		exclude[p.Prefix] = true
+	}
+
+	subnets := []netip.Prefix{tailnetIPv4, tailnetIPv6}
+	if status != nil {
+		for _, peer := range status.Peer {
+			if peer.PrimaryRoutes == nil {
+				continue
+			}
+			for _, p := range peer.PrimaryRoutes.All() {
+				if p.Bits() > 0 && !exclude[p.Masked()] {
+					subnets = append(subnets, p.Masked())
+				}
+			}
+		}
+	}
+	return Sorted(subnets)
+}
+
+// Diff returns the prefixes to add and remove to turn current into desired.
+func Diff(current, desired []netip.Prefix) (add, remove []netip.Prefix) {
+	have := make(map[netip.Prefix]bool, len(current))
+	for _, p := range current {
+		have[p] = true
+	}
+	want := make(map[netip.Prefix]bool, len(desired))
+	for _, p := range desired {
+		want[p] = true
+		if !have[p] {
+			add = append(add, p)
+		}
+	}
+	for _, p := range current {
+		if !want[p] {
+			remove = append(remove, p)
+		}
+	}
+	return add, remove
+}
+
+// Sorted returns the prefixes masked, deduplicated and in a stable order.
+func Sorted(prefixes []netip.Prefix) []netip.Prefix {
+	seen := make(map[netip.Prefix]bool, len(prefixes))
+	result := make([]netip.Prefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		p = p.Masked()
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].String() < result[j].String() })
+	return result
+}
@@ -0,0 +1,95 @@
+package bgp
+
+import (
+	"net/netip"
+	"testing"
+
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/types/key"
+	"tailscale.com/types/views"
+)
+
+func testConfig(t *testing.T) *Config {
+	t.Helper()
+	cfg, err := NewConfig("65000", "10.1.0.1", []string{"65001@169.254.10.2"}, []string{"10.20.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestImported(t *testing.T) {
+	neighbor := netip.MustParseAddr("169.254.10.2")
+	paths := []Path{
+		{Prefix: netip.MustParsePrefix("10.20.1.0/24"), NextHop: neighbor, Neighbor: neighbor},
+		{Prefix: netip.MustParsePrefix("192.168.0.0/16"), NextHop: neighbor, Neighbor: neighbor},
+		{Prefix: netip.MustParsePrefix("10.20.2.0/24"), Neighbor: netip.MustParseAddr("169.254.99.1")},
+		{Prefix: netip.MustParsePrefix("100.64.0.0/10")},
+	}
+
+	accepted, rejected := Imported(testConfig(t), paths)
+	if len(accepted) != 1 || accepted[0].Prefix != netip.MustParsePrefix("10.20.1.0/24") {
+		t.Errorf("unexpected accepted paths %+v", accepted)
+	}
+	if len(rejected) != 1 || rejected[0] != netip.MustParsePrefix("192.168.0.0/16") {
+		t.Errorf("unexpected rejected prefixes %v", rejected)
+	}
+	if local := Local(paths); len(local) != 1 || local[0] != netip.MustParsePrefix("100.64.0.0/10") {
+		t.Errorf("unexpected local prefixes %v", local)
+	}
+}
+
+func TestAdvertiseRoutes(t *testing.T) {
+	static := []netip.Prefix{netip.MustParsePrefix("10.2.0.0/24")}
+	imported := []Path{
+		{Prefix: netip.MustParsePrefix("10.20.1.0/24")},
+		{Prefix: netip.MustParsePrefix("10.2.0.0/24")},
+	}
+
+	got := AdvertiseRoutes(static, imported)
+	if len(got) != 2 || got[0] != netip.MustParsePrefix("10.2.0.0/24") || got[1] != netip.MustParsePrefix("10.20.1.0/24") {
+		t.Errorf("AdvertiseRoutes() = %v", got)
+	}
+}
+
+func TestTailnetSubnets(t *testing.T) {
+	peerRoutes := views.SliceOf([]netip.Prefix{
+		netip.MustParsePrefix("192.168.50.0/24"),
+		netip.MustParsePrefix("10.20.1.0/24"),
+		netip.MustParsePrefix("0.0.0.0/0"),
+	})
+	status := &ipnstate.Status{Peer: map[key.NodePublic]*ipnstate.PeerStatus{
+		key.NewNode().Public(): {PrimaryRoutes: &peerRoutes},
+		key.NewNode().Public(): {},
+	}}
+	learned := []Path{{Prefix: netip.MustParsePrefix("10.20.1.0/24")}}
+
+	got := TailnetSubnets(status, learned)
+	want := []netip.Prefix{
+		netip.MustParsePrefix("100.64.0.0/10"),
+		netip.MustParsePrefix("192.168.50.0/24"),
+		netip.MustParsePrefix("fd7a:115c:a1e0::/48"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("TailnetSubnets() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("index %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := netip.MustParsePrefix("10.1.0.0/24")
+	b := netip.MustParsePrefix("10.2.0.0/24")
+	c := netip.MustParsePrefix("10.3.0.0/24")
+
+	add, remove := Diff([]netip.Prefix{a, b}, []netip.Prefix{b, c})
+	if len(add) != 1 || add[0] != c {
+		t.Errorf("add = %v, want [%s]", add, c)
+	}
+	if len(remove) != 1 || remove[0] != a {
+		t.Errorf("remove = %v, want [%s]", remove, a)
+	}
+}
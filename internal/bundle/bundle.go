@@ -0,0 +1,308 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+	"tailscale.com/client/local"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/diag"
+	"github.com/klowdo/tailswan/internal/doctor"
+	"github.com/klowdo/tailswan/internal/firewall"
+	"github.com/klowdo/tailswan/internal/logfile"
+	"github.com/klowdo/tailswan/internal/supervisor"
+	"github.com/klowdo/tailswan/internal/version"
+)
+
+const maxLogBytes = 2 << 20
+
+type entry struct {
+	collect func(ctx context.Context) ([]byte, error)
+	name    string
+}
+
+// Bundle collects troubleshooting data into a tar.gz. Every collector is
+// best effort: a failure is recorded next to the missing file instead of
+// aborting the bundle.
+type Bundle struct {
+	cfg       *config.Config
+	tailscale *local.Client
+}
+
+func New(cfg *config.Config, tailscale *local.Client) *Bundle {
+	if tailscale == nil {
+		tailscale = &local.Client{}
+	}
+	return &Bundle{cfg: cfg, tailscale: tailscale}
+}
+
+func FileName(now time.Time) string {
+	return fmt.Sprintf("tailswan-support-%s.tar.gz", now.UTC().Format("20060102-150405"))
+}
+
+func (b *Bundle) Write(ctx context.Context, w io.Writer) error {
+	now := time.Now().UTC()
+	root := strings.TrimSuffix(FileName(now), ".tar.gz")
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	var errs []string
+	for _, e := range b.entries() {
+		data, err := e.collect(ctx)
+		name := e.name
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", e.name, err))
+			name += ".error"
+			data = []byte(RedactText(err.Error()) + "\n")
+		}
+		if err := writeFile(tw, root+"/"+name, data, now); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFile(tw, root+"/README.txt", []byte(readme(now, errs)), now); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("close tar: %w", err)
+	}
+	return gz.Close()
+}
+
+func (b *Bundle) entries() []entry {
+	return []entry{
+		{name: "version.txt", collect: func(context.Context) ([]byte, error) { return []byte(version.Get() + "\n"), nil }},
+		{name: "config.json", collect: b.config},
+		{name: "swanctl/swanctl.conf", collect: b.swanctl},
+		{name: "vici/list-conns.json", collect: viciStream("list-conns", "list-conn", nil)},
+		{name: "vici/list-sas.json", collect: viciStream("list-sas", "list-sa", RedactSAHosts)},
+		{name: "vici/stats.json", collect: viciCall("stats")},
+		{name: "tailscale/status.json", collect: b.tailscaleStatus},
+		{name: "tailscale/prefs.json", collect: b.tailscalePrefs},
+		{name: "tailscale/netcheck.json", collect: netcheck},
+		{name: "processes.json", collect: b.processes},
+		{name: "logs/tailswan.log", collect: b.logs},
+		{name: "sysctl.txt", collect: sysctls},
+		{name: "firewall.txt", collect: b.firewall},
+		{name: "ip-rules.txt", collect: ipRules},
+		{name: "xfrm.json", collect: xfrm},
+		{name: "doctor.json", collect: b.doctor},
+	}
+}
+
+func (b *Bundle) config(context.Context) ([]byte, error) {
+	return json.MarshalIndent(RedactConfig(b.cfg), "", "  ")
+}
+
+// swanctl returns the main swanctl.conf followed by any conf.d snippets it
+// includes, with secrets removed.
+func (b *Bundle) swanctl(context.Context) ([]byte, error) {
+	paths := []string{b.cfg.Swan.ConfigPath}
+	snippets, err := filepath.Glob(filepath.Join(filepath.Dir(b.cfg.Swan.ConfigPath), "conf.d", "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	paths = append(paths, snippets...)
+
+	var out strings.Builder
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&out, "# ---- %s ----\n%s\n", path, RedactSwanctl(string(data)))
+	}
+	return []byte(out.String()), nil
+}
+
+func viciStream(command, event string, redact func([]map[string]interface{})) func(context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		session, err := vici.NewSession()
+		if err != nil {
+			return nil, err
+		}
+		defer session.Close() //nolint:errcheck
+
+		results := make([]map[string]interface{}, 0)
+		for m, err := range session.CallStreaming(ctx, command, event, vici.NewMessage()) {
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, messageMap(m))
+		}
+		if redact != nil {
+			redact(results)
+		}
+		return json.MarshalIndent(results, "", "  ")
+	}
+}
+
+func viciCall(command string) func(context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		session, err := vici.NewSession()
+		if err != nil {
+			return nil, err
+		}
+		defer session.Close() //nolint:errcheck
+
+		m, err := session.Call(ctx, command, vici.NewMessage())
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(messageMap(m), "", "  ")
+	}
+}
+
+func messageMap(m *vici.Message) map[string]interface{} {
+	result := make(map[string]interface{}, len(m.Keys()))
+	for _, key := range m.Keys() {
+		if sub, ok := m.Get(key).(*vici.Message); ok {
+			result[key] = messageMap(sub)
+			continue
+		}
+		result[key] = m.Get(key)
+	}
+	return result
+}
+
+func (b *Bundle) tailscaleStatus(ctx context.Context) ([]byte, error) {
+	status, err := b.tailscale.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(RedactStatus(status), "", "  ")
+}
+
+func (b *Bundle) tailscalePrefs(ctx context.Context) ([]byte, error) {
+	prefs, err := b.tailscale.GetPrefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(RedactPrefs(prefs), "", "  ")
+}
+
+func netcheck(ctx context.Context) ([]byte, error) {
+	output, err := exec.CommandContext(ctx, "tailscale", "netcheck", "--format=json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("tailscale netcheck: %w", err)
+	}
+	return []byte(RedactNetcheck(string(output))), nil
+}
+
+func (b *Bundle) processes(context.Context) ([]byte, error) {
+	events, err := supervisor.ReadHistory(b.cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []supervisor.ProcessEvent{}
+	}
+	return json.MarshalIndent(events, "", "  ")
+}
+
+func (b *Bundle) logs(context.Context) ([]byte, error) {
+	data, err := logfile.Tail(b.cfg.LogPath(), maxLogBytes)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(RedactText(string(data))), nil
+}
+
+func sysctls(context.Context) ([]byte, error) {
+	values, err := doctor.ReadSysctls()
+	if err != nil {
+		return nil, err
+	}
+
+	var out strings.Builder
+	for _, key := range doctor.SysctlKeys() {
+		if value, ok := values[key]; ok {
+			fmt.Fprintf(&out, "%s = %s\n", key, value)
+		}
+	}
+	return []byte(out.String()), nil
+}
+
+func (b *Bundle) firewall(context.Context) ([]byte, error) {
+	backend, err := firewall.NewBackend(b.cfg.Firewall.Backend)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := backend.Show()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("# backend: %s\n%s", backend.Name(), rules)), nil
+}
+
+func ipRules(ctx context.Context) ([]byte, error) {
+	var out strings.Builder
+	for _, family := range []string{"-4", "-6"} {
+		output, err := exec.CommandContext(ctx, "ip", family, "rule", "show").Output()
+		if err != nil {
+			return nil, fmt.Errorf("ip %s rule show: %w", family, err)
+		}
+		fmt.Fprintf(&out, "# ip %s rule show\n%s\n", family, output)
+	}
+	return []byte(out.String()), nil
+}
+
+func xfrm(ctx context.Context) ([]byte, error) {
+	policies, states, err := diag.ListXFRM(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"policies": policies,
+		"states":   states,
+	}, "", "  ")
+}
+
+func (b *Bundle) doctor(ctx context.Context) ([]byte, error) {
+	return json.MarshalIndent(doctor.Evaluate(doctor.Gather(ctx, b.cfg)), "", "  ")
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}); err != nil {
+		return fmt.Errorf("write %s header: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+func readme(now time.Time, errs []string) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "TailSwan support bundle\nCreated: %s\nVersion: %s\n\n", now.Format(time.RFC3339), version.Get())
+	out.WriteString("Auth keys, PSKs, EAP passwords, private key passphrases, Tailscale\n")
+	out.WriteString("node keys and user profiles have been redacted, as have the public IPs\n")
+	out.WriteString("of this gateway from netcheck, of IKE_SA endpoints and of Tailscale\n")
+	out.WriteString("endpoints. Peer addresses in swanctl.conf, the VICI connection list,\n")
+	out.WriteString("XFRM states, firewall rules and logs are not redacted.\n")
+	out.WriteString("Please review the contents before sharing.\n")
+	if len(errs) > 0 {
+		out.WriteString("\nCollectors that failed:\n")
+		for _, e := range errs {
+			fmt.Fprintf(&out, "  %s\n", RedactText(e))
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,165 @@
+package bundle
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/types/key"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/swanconf"
+)
+
+const Redacted = "<redacted>"
+
+var (
+	tailscaleKeyRe = regexp.MustCompile(`tskey-[A-Za-z0-9_-]+`)
+	authKeyArgRe   = regexp.MustCompile(`(?i)(--?(?:auth-?key|client-secret)[= ])\S+`)
+	// A secret written as "key = value" or "key: value" may contain spaces
+	// and runs to the end of the line, a comment or a closing brace; one
+	// written as key=value ends at the first space.
+	spacedSecretRe = regexp.MustCompile(`(?im)\b(secret|password|passphrase|psk)([ \t]*[=:][ \t]+|[ \t]+[=:][ \t]*)("[^"\n]*"|[^#}\n]*[^\s#}])`)
+	textSecretRe   = regexp.MustCompile(`(?i)\b(secret|password|passphrase|psk)([=:])("[^"]*"|\S+)`)
+)
+
+// RedactSwanctl strips secret values from swanctl.conf content while keeping
+// the structure intact, so section and id layout can still be reviewed.
+func RedactSwanctl(conf string) string {
+	return swanconf.RedactSecrets(conf, func(string) string { return Redacted })
+}
+
+// RedactText masks Tailscale auth keys and obvious key=value secrets in
+// free-form output such as logs.
+func RedactText(text string) string {
+	text = tailscaleKeyRe.ReplaceAllString(text, "tskey-"+Redacted)
+	text = authKeyArgRe.ReplaceAllString(text, "${1}"+Redacted)
+	text = spacedSecretRe.ReplaceAllString(text, "${1}${2}"+Redacted)
+	return textSecretRe.ReplaceAllString(text, "${1}${2}"+Redacted)
+}
+
+func RedactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		name, _, hasValue := strings.Cut(arg, "=")
+		lower := strings.ToLower(strings.TrimLeft(name, "-"))
+		switch {
+		case hasValue && isSecretFlag(lower):
+			redacted[i] = name + "=" + Redacted
+		case i > 0 && isSecretFlag(strings.ToLower(strings.TrimLeft(args[i-1], "-"))) && !strings.Contains(args[i-1], "="):
+			redacted[i] = Redacted
+		default:
+			redacted[i] = RedactText(arg)
+		}
+	}
+	return redacted
+}
+
+func isSecretFlag(name string) bool {
+	switch name {
+	case "authkey", "auth-key", "client-secret":
+		return true
+	}
+	return false
+}
+
+// RedactConfig returns a copy of cfg that is safe to share.
+func RedactConfig(cfg *config.Config) *config.Config {
+	c := *cfg
+	c.Tailscale.AuthKey = ""
+	c.Tailscale.ExtraArgs = RedactArgs(cfg.Tailscale.ExtraArgs)
+	return &c
+}
+
+// RedactPrefs drops the persisted node identity and private keys from prefs.
+func RedactPrefs(prefs *ipn.Prefs) *ipn.Prefs {
+	if prefs == nil {
+		return nil
+	}
+	p := prefs.Clone()
+	p.Persist = nil
+	return p
+}
+
+// RedactStatus drops user profiles (login names, avatars) from status, and
+// the public endpoints of this node and its peers.
+func RedactStatus(status *ipnstate.Status) *ipnstate.Status {
+	if status == nil {
+		return nil
+	}
+	s := *status
+	s.User = nil
+	s.AuthURL = ""
+	s.Self = redactPeer(status.Self)
+	if status.Peer != nil {
+		s.Peer = make(map[key.NodePublic]*ipnstate.PeerStatus, len(status.Peer))
+		for k, peer := range status.Peer {
+			s.Peer[k] = redactPeer(peer)
+		}
+	}
+	return &s
+}
+
+func redactPeer(peer *ipnstate.PeerStatus) *ipnstate.PeerStatus {
+	if peer == nil {
+		return nil
+	}
+	p := *peer
+	p.Addrs = make([]string, len(peer.Addrs))
+	for i, addr := range peer.Addrs {
+		p.Addrs[i] = RedactAddr(addr)
+	}
+	if peer.Addrs == nil {
+		p.Addrs = nil
+	}
+	p.CurAddr = RedactAddr(peer.CurAddr)
+	return &p
+}
+
+// cgnat is the range Tailscale assigns tailnet addresses from.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// RedactAddr replaces s when it is a public address, with or without a
+// port.
+func RedactAddr(s string) string {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		ap, err := netip.ParseAddrPort(s)
+		if err != nil {
+			return s
+		}
+		addr = ap.Addr()
+	}
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || cgnat.Contains(addr) {
+		return s
+	}
+	return Redacted
+}
+
+// RedactSAHosts replaces the public local and remote addresses of the
+// IKE_SAs in list-sas results.
+func RedactSAHosts(results []map[string]interface{}) {
+	for _, result := range results {
+		for _, sa := range result {
+			ike, ok := sa.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, field := range []string{"local-host", "remote-host"} {
+				if host, ok := ike[field].(string); ok {
+					ike[field] = RedactAddr(host)
+				}
+			}
+		}
+	}
+}
+
+var netcheckPublicAddrRe = regexp.MustCompile(`("GlobalV[46]"\s*:\s*)"[^"]*"`)
+
+// RedactNetcheck hides the gateway's public addresses from a JSON netcheck
+// report.
+func RedactNetcheck(report string) string {
+	return netcheckPublicAddrRe.ReplaceAllString(report, `${1}"`+Redacted+`"`)
+}
@@ -0,0 +1,48 @@
+package certs
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+// Load loads a stored credential into charon, so it is used without a
+// restart.
+func (s *Store) Load(ctx context.Context, session *vici.Session, kind, name string) error {
+	p, err := s.read(kind, name)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case KindCert:
+		return viciconn.LoadCert(ctx, session, p.viciType, "NONE", p.der)
+	case KindCA:
+		return viciconn.LoadCert(ctx, session, p.viciType, "CA", p.der)
+	case KindCRL:
+		if err := viciconn.LoadCert(ctx, session, p.viciType, "NONE", p.der); err != nil {
+			return err
+		}
+		// Drop fetched CRLs so the uploaded one is used right away.
+		return viciconn.FlushCerts(ctx, session, p.viciType)
+	case KindKey:
+		return viciconn.LoadKey(ctx, session, p.viciType, p.der)
+	}
+	return fmt.Errorf("unknown credential kind %q", kind)
+}
+
+// Unload makes charon forget deleted credentials. VICI cannot unload a
+// single credential, so swanctl reloads all of them from disk, which keeps
+// the secrets of swanctl.conf, and the certificate cache is flushed.
+func Unload(ctx context.Context, session *vici.Session) error {
+	out, err := exec.CommandContext(ctx, "swanctl", "--load-creds", "--clear", "--noprompt").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("swanctl --load-creds: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return viciconn.FlushCerts(ctx, session, "")
+}
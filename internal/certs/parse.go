@@ -0,0 +1,206 @@
+// Package certs manages the credentials charon authenticates with in the
+// swanctl directory: certificates, CA certificates, CRLs and private keys.
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	KindCert = "cert"
+	KindCA   = "ca"
+	KindCRL  = "crl"
+	KindKey  = "key"
+)
+
+// Kinds lists the credential kinds in the order they are listed.
+var Kinds = []string{KindCert, KindCA, KindCRL, KindKey}
+
+// Credential describes a stored credential. Private keys are described by
+// their type only; key material never leaves the store.
+type Credential struct {
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+	// ThisUpdate and NextUpdate are set for CRLs.
+	ThisUpdate  *time.Time `json:"this_update,omitempty"`
+	NextUpdate  *time.Time `json:"next_update,omitempty"`
+	Kind        string     `json:"kind"`
+	Name        string     `json:"name"`
+	Subject     string     `json:"subject,omitempty"`
+	Issuer      string     `json:"issuer,omitempty"`
+	Serial      string     `json:"serial,omitempty"`
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	KeyType     string     `json:"key_type,omitempty"`
+	// Error is why a file in the swanctl directory could not be parsed.
+	Error string   `json:"error,omitempty"`
+	SANs  []string `json:"sans,omitempty"`
+	// Certificates names the stored certificates a private key belongs
+	// to.
+	Certificates []string `json:"certificates,omitempty"`
+	Revoked      int      `json:"revoked,omitempty"`
+	IsCA         bool     `json:"is_ca,omitempty"`
+}
+
+// ExpiresWithin reports whether a certificate expires before now+d. It is
+// false for CRLs and keys.
+func (c *Credential) ExpiresWithin(now time.Time, d time.Duration) bool {
+	return c.NotAfter != nil && c.NotAfter.Before(now.Add(d))
+}
+
+// parsed is a credential with what charon needs to load it.
+type parsed struct {
+	publicKey crypto.PublicKey
+	// viciType is the load-cert type, or the load-key type for keys.
+	viciType string
+	der      []byte
+	Credential
+}
+
+// Parse validates PEM or DER data as a credential of kind and describes it.
+func Parse(kind, name string, data []byte) (*Credential, error) {
+	p, err := parse(kind, name, data)
+	if err != nil {
+		return nil, err
+	}
+	return &p.Credential, nil
+}
+
+func parse(kind, name string, data []byte) (*parsed, error) {
+	der, pemType, encrypted := decode(data)
+	p := &parsed{Credential: Credential{Kind: kind, Name: name}, der: der}
+
+	switch kind {
+	case KindCert, KindCA:
+		if pemType != "" && pemType != "CERTIFICATE" {
+			return nil, fmt.Errorf("expected a CERTIFICATE, got a PEM %s", pemType)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		if kind == KindCA && !cert.IsCA {
+			return nil, errors.New("certificate is not a CA certificate")
+		}
+		describeCert(&p.Credential, cert)
+		p.publicKey = cert.PublicKey
+		p.viciType = "X509"
+	case KindCRL:
+		if pemType != "" && pemType != "X509 CRL" {
+			return nil, fmt.Errorf("expected an X509 CRL, got a PEM %s", pemType)
+		}
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return nil, fmt.Errorf("parse CRL: %w", err)
+		}
+		p.Issuer = crl.Issuer.String()
+		p.ThisUpdate = timePtr(crl.ThisUpdate)
+		if !crl.NextUpdate.IsZero() {
+			p.NextUpdate = timePtr(crl.NextUpdate)
+		}
+		p.Revoked = len(crl.RevokedCertificateEntries)
+		p.Fingerprint = fingerprint(der)
+		p.viciType = "X509_CRL"
+	case KindKey:
+		if encrypted {
+			return nil, errors.New("encrypted private keys are not supported, upload the key unencrypted")
+		}
+		key, err := parsePrivateKey(der)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		p.publicKey = signer.Public()
+		p.KeyType, p.viciType = describeKey(p.publicKey)
+		if p.viciType == "" {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+	default:
+		return nil, fmt.Errorf("unknown credential kind %q, expected one of %s", kind, strings.Join(Kinds, ", "))
+	}
+	return p, nil
+}
+
+// decode returns the DER of the first PEM block, or data itself when it is
+// not PEM, and whether the block is an encrypted private key.
+func decode(data []byte) ([]byte, string, bool) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return data, "", false
+	}
+	encrypted := block.Type == "ENCRYPTED PRIVATE KEY" || strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED")
+	return block.Bytes, block.Type, encrypted
+}
+
+func parsePrivateKey(der []byte) (any, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("parse private key: not a PKCS#8, PKCS#1 or SEC 1 key")
+}
+
+func describeCert(c *Credential, cert *x509.Certificate) {
+	c.Subject = cert.Subject.String()
+	c.Issuer = cert.Issuer.String()
+	c.Serial = cert.SerialNumber.Text(16)
+	c.NotBefore = timePtr(cert.NotBefore)
+	c.NotAfter = timePtr(cert.NotAfter)
+	c.Fingerprint = fingerprint(cert.Raw)
+	c.IsCA = cert.IsCA
+	c.KeyType, _ = describeKey(cert.PublicKey)
+
+	for _, name := range cert.DNSNames {
+		c.SANs = append(c.SANs, "DNS:"+name)
+	}
+	for _, ip := range cert.IPAddresses {
+		c.SANs = append(c.SANs, "IP:"+ip.String())
+	}
+	for _, email := range cert.EmailAddresses {
+		c.SANs = append(c.SANs, "email:"+email)
+	}
+	for _, uri := range cert.URIs {
+		c.SANs = append(c.SANs, "URI:"+uri.String())
+	}
+}
+
+// describeKey returns a readable key type and charon's load-key type.
+func describeKey(pub crypto.PublicKey) (string, string) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen()), "rsa"
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name, "ecdsa"
+	case ed25519.PublicKey:
+		return "Ed25519", "ed25519"
+	}
+	return "", ""
+}
+
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+func timePtr(t time.Time) *time.Time {
+	t = t.UTC()
+	return &t
+}
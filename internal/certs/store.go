@@ -0,0 +1,178 @@
+package certs
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/statefile"
+)
+
+// dirs are the swanctl directories swanctl --load-creds reads each kind
+// from.
+var dirs = map[string]string{
+	KindCert: "x509",
+	KindCA:   "x509ca",
+	KindCRL:  "x509crl",
+	KindKey:  "private",
+}
+
+var (
+	ErrNotFound = errors.New("credential not found")
+	// ErrInvalid wraps errors about a bad kind, name or file content.
+	ErrInvalid = errors.New("invalid credential")
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Store keeps credentials in the swanctl directory, so charon finds them
+// again when it restarts.
+type Store struct {
+	dir string
+}
+
+func NewStore(swanctlDir string) *Store {
+	return &Store{dir: swanctlDir}
+}
+
+func (s *Store) path(kind, name string) (string, error) {
+	dir, ok := dirs[kind]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown kind %q, expected one of %s", ErrInvalid, kind, strings.Join(Kinds, ", "))
+	}
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("%w: bad name %q", ErrInvalid, name)
+	}
+	return filepath.Join(s.dir, dir, name), nil
+}
+
+// List describes every credential, certificates first. Files that cannot
+// be parsed are listed with an Error.
+func (s *Store) List() ([]Credential, error) {
+	var all []*parsed
+	for _, kind := range Kinds {
+		entries, err := os.ReadDir(filepath.Join(s.dir, dirs[kind]))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			p, err := s.read(kind, e.Name())
+			if err != nil {
+				p = &parsed{Credential: Credential{Kind: kind, Name: e.Name(), Error: err.Error()}}
+			}
+			all = append(all, p)
+		}
+	}
+
+	matchKeys(all)
+	creds := make([]Credential, 0, len(all))
+	for _, p := range all {
+		creds = append(creds, p.Credential)
+	}
+	return creds, nil
+}
+
+// matchKeys records on each private key the certificates for its public
+// key.
+func matchKeys(all []*parsed) {
+	type equaler interface{ Equal(crypto.PublicKey) bool }
+	for _, key := range all {
+		pub, ok := key.publicKey.(equaler)
+		if key.Kind != KindKey || !ok {
+			continue
+		}
+		for _, cert := range all {
+			if cert.Kind == KindCert && cert.publicKey != nil && pub.Equal(cert.publicKey) {
+				key.Certificates = append(key.Certificates, cert.Name)
+			}
+		}
+	}
+}
+
+func (s *Store) Get(kind, name string) (*Credential, error) {
+	if _, err := s.path(kind, name); err != nil {
+		return nil, err
+	}
+	creds, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range creds {
+		if creds[i].Kind == kind && creds[i].Name == name {
+			return &creds[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *Store) read(kind, name string) (*parsed, error) {
+	path, err := s.path(kind, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parse(kind, name, data)
+}
+
+// Put validates data and stores it as name, replacing a credential of the
+// same kind and name. Private keys are readable by root only.
+func (s *Store) Put(kind, name string, data []byte) (*Credential, error) {
+	path, err := s.path(kind, name)
+	if err != nil {
+		return nil, err
+	}
+	p, err := parse(kind, name, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if err := statefile.WriteAtomic(path, data); err != nil {
+		return nil, err
+	}
+	if kind != KindKey {
+		if err := os.Chmod(path, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return &p.Credential, nil
+}
+
+func (s *Store) Delete(kind, name string) error {
+	path, err := s.path(kind, name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Expiring returns the certificates and CA certificates that expire within
+// d of now, including those already expired.
+func Expiring(creds []Credential, now time.Time, d time.Duration) []Credential {
+	var result []Credential
+	for _, c := range creds {
+		if (c.Kind == KindCert || c.Kind == KindCA) && c.ExpiresWithin(now, d) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
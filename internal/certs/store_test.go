@@ -0,0 +1,221 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key := newTestKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2036, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{key: key, cert: cert, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (ca *testCA) issue(t *testing.T, pub crypto.PublicKey, notAfter time.Time) []byte {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "gw.example.com"},
+		DNSNames:     []string{"gw.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("192.0.2.1")},
+		NotBefore:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, pub, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func keyPEM(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	ca := newTestCA(t)
+	key := newTestKey(t)
+	notAfter := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := store.Put(KindCA, "ca.pem", ca.pem); err != nil {
+		t.Fatalf("Put(ca) error = %v", err)
+	}
+	cert, err := store.Put(KindCert, "gw.pem", ca.issue(t, key.Public(), notAfter))
+	if err != nil {
+		t.Fatalf("Put(cert) error = %v", err)
+	}
+	if cert.Subject != "CN=gw.example.com" || cert.Issuer != "CN=Test CA" || !cert.NotAfter.Equal(notAfter) {
+		t.Errorf("unexpected certificate %+v", cert)
+	}
+	if len(cert.SANs) != 2 || cert.SANs[0] != "DNS:gw.example.com" || cert.SANs[1] != "IP:192.0.2.1" {
+		t.Errorf("unexpected SANs %v", cert.SANs)
+	}
+	if _, err := store.Put(KindKey, "gw.key", keyPEM(t, key)); err != nil {
+		t.Fatalf("Put(key) error = %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "private", "gw.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("private key mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	creds, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(creds) != 3 || creds[0].Kind != KindCert || creds[1].Kind != KindCA || creds[2].Kind != KindKey {
+		t.Fatalf("unexpected list %+v", creds)
+	}
+	if k := creds[2]; k.KeyType != "ECDSA P-256" || len(k.Certificates) != 1 || k.Certificates[0] != "gw.pem" {
+		t.Errorf("key should be matched to its certificate, got %+v", k)
+	}
+
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	expiring := Expiring(creds, now, 30*24*time.Hour)
+	if len(expiring) != 1 || expiring[0].Name != "gw.pem" {
+		t.Errorf("Expiring() = %+v, want gw.pem", expiring)
+	}
+
+	if err := store.Delete(KindCert, "gw.pem"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Get(KindCert, "gw.pem"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(KindCert, "gw.pem"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestListReportsUnparseableFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "x509"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "x509", "broken.pem"), []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := NewStore(dir).List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(creds) != 1 || creds[0].Name != "broken.pem" || creds[0].Error == "" {
+		t.Errorf("unexpected list %+v", creds)
+	}
+}
+
+func TestPutRejects(t *testing.T) {
+	ca := newTestCA(t)
+	key := newTestKey(t)
+	leaf := ca.issue(t, key.Public(), time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name     string
+		kind     string
+		credName string
+		data     []byte
+	}{
+		{name: "path traversal", kind: KindCert, credName: "../swanctl.conf", data: leaf},
+		{name: "unknown kind", kind: "pubkey", credName: "gw.pem", data: leaf},
+		{name: "key as certificate", kind: KindCert, credName: "gw.pem", data: keyPEM(t, key)},
+		{name: "leaf as CA", kind: KindCA, credName: "ca.pem", data: leaf},
+		{name: "certificate as CRL", kind: KindCRL, credName: "ca.crl", data: leaf},
+		{name: "garbage key", kind: KindKey, credName: "gw.key", data: []byte("secret")},
+		{
+			name:     "encrypted key",
+			kind:     KindKey,
+			credName: "gw.key",
+			data:     pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: []byte{1, 2, 3}}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if _, err := NewStore(dir).Put(tt.kind, tt.credName, tt.data); err == nil {
+				t.Error("Put() succeeded, want an error")
+			}
+			if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+				t.Errorf("rejected credential was written: %v", entries)
+			}
+		})
+	}
+}
+
+func TestParseCRL(t *testing.T) {
+	ca := newTestCA(t)
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		NextUpdate: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(7), RevocationTime: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// DER is accepted as well as PEM.
+	crl, err := Parse(KindCRL, "ca.crl", der)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if crl.Issuer != "CN=Test CA" || crl.Revoked != 1 || crl.NextUpdate == nil || crl.NotAfter != nil {
+		t.Errorf("unexpected CRL %+v", crl)
+	}
+}
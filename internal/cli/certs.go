@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/certs"
+	"github.com/klowdo/tailswan/internal/config"
+)
+
+func NewCertsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "certs",
+		Short: "Manage certificates, CA certificates, CRLs and private keys",
+		Long: `Manage the credentials charon authenticates with. They are kept in the
+x509, x509ca, x509crl and private directories next to SWAN_CONFIG and loaded
+into charon without a restart. Kinds are cert, ca, crl and key.`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List credentials with their subject and expiry",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			creds, err := certs.NewStore(cfg.Swan.CredentialsDir()).List()
+			if err != nil {
+				return fmt.Errorf("failed to list credentials: %w", err)
+			}
+
+			var out strings.Builder
+			writeCredentials(&out, creds, time.Now(), cfg.Swan.CertExpiryWarning())
+			if _, err := fmt.Fprint(cmd.OutOrStdout(), out.String()); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show <kind> <name>",
+		Short: "Show the subject, SANs, issuer and validity of a credential",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			cred, err := certs.NewStore(cfg.Swan.CredentialsDir()).Get(args[0], args[1])
+			if err != nil {
+				return fmt.Errorf("failed to read %s %s: %w", args[0], args[1], err)
+			}
+
+			var out strings.Builder
+			writeCredential(&out, cred)
+			if _, err := fmt.Fprint(cmd.OutOrStdout(), out.String()); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return nil
+		},
+	})
+
+	var name string
+	addCmd := &cobra.Command{
+		Use:   "add <kind> <file>",
+		Short: "Store a PEM or DER file and load it into charon",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, file := args[0], args[1]
+			if name == "" {
+				name = filepath.Base(file)
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", file, err)
+			}
+
+			cfg := config.Load()
+			store := certs.NewStore(cfg.Swan.CredentialsDir())
+			if _, err := store.Put(kind, name, data); err != nil {
+				return fmt.Errorf("failed to store %s %s: %w", kind, name, err)
+			}
+
+			session, err := vici.NewSession()
+			if err != nil {
+				return fmt.Errorf("stored %s %s, but charon is not reachable: %w", kind, name, err)
+			}
+			defer session.Close() //nolint:errcheck
+
+			if err := store.Load(cmd.Context(), session, kind, name); err != nil {
+				return fmt.Errorf("stored %s %s, but charon did not load it: %w", kind, name, err)
+			}
+			if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Loaded %s '%s'\n", kind, name); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return nil
+		},
+	}
+	addCmd.Flags().StringVar(&name, "name", "", "file name to store it as (default: the base name of <file>)")
+	cmd.AddCommand(addCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <kind> <name>",
+		Short: "Delete a credential and reload charon's credentials",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, name := args[0], args[1]
+			cfg := config.Load()
+			if err := certs.NewStore(cfg.Swan.CredentialsDir()).Delete(kind, name); err != nil {
+				return fmt.Errorf("failed to delete %s %s: %w", kind, name, err)
+			}
+
+			session, err := vici.NewSession()
+			if err != nil {
+				return fmt.Errorf("deleted %s %s, but charon is not reachable: %w", kind, name, err)
+			}
+			defer session.Close() //nolint:errcheck
+
+			if err := certs.Unload(cmd.Context(), session); err != nil {
+				return fmt.Errorf("deleted %s %s, but charon did not reload its credentials: %w", kind, name, err)
+			}
+			if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Deleted %s '%s'\n", kind, name); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+func writeCredentials(out *strings.Builder, creds []certs.Credential, now time.Time, warn time.Duration) {
+	if len(creds) == 0 {
+		out.WriteString("No credentials\n")
+		return
+	}
+	for _, c := range creds {
+		mark := " "
+		if c.ExpiresWithin(now, warn) || c.Error != "" {
+			mark = "!"
+		}
+		fmt.Fprintf(out, "%s %-5s %-24s ", mark, c.Kind, c.Name)
+		switch {
+		case c.Error != "":
+			out.WriteString(c.Error)
+		case c.Kind == certs.KindKey:
+			out.WriteString(c.KeyType)
+			if len(c.Certificates) > 0 {
+				fmt.Fprintf(out, " for %s", strings.Join(c.Certificates, ", "))
+			}
+		case c.Kind == certs.KindCRL:
+			fmt.Fprintf(out, "%s, %d revoked", c.Issuer, c.Revoked)
+			if c.NextUpdate != nil {
+				fmt.Fprintf(out, ", next update %s", c.NextUpdate.Local().Format(time.DateOnly))
+			}
+		default:
+			fmt.Fprintf(out, "%s, expires %s", c.Subject, c.NotAfter.Local().Format(time.DateOnly))
+		}
+		out.WriteString("\n")
+	}
+}
+
+func writeCredential(out *strings.Builder, c *certs.Credential) {
+	field := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(out, "%-13s %s\n", label+":", value)
+		}
+	}
+	field("Kind", c.Kind)
+	field("Name", c.Name)
+	field("Subject", c.Subject)
+	field("SANs", strings.Join(c.SANs, ", "))
+	field("Issuer", c.Issuer)
+	field("Serial", c.Serial)
+	field("Key", c.KeyType)
+	if c.IsCA {
+		field("CA", "yes")
+	}
+	if c.NotBefore != nil {
+		field("Not before", c.NotBefore.Local().Format(time.DateTime))
+	}
+	if c.NotAfter != nil {
+		field("Not after", c.NotAfter.Local().Format(time.DateTime))
+	}
+	if c.ThisUpdate != nil {
+		field("This update", c.ThisUpdate.Local().Format(time.DateTime))
+	}
+	if c.NextUpdate != nil {
+		field("Next update", c.NextUpdate.Local().Format(time.DateTime))
+	}
+	if c.Kind == certs.KindCRL {
+		field("Revoked", fmt.Sprint(c.Revoked))
+	}
+	field("Certificates", strings.Join(c.Certificates, ", "))
+	field("SHA-256", c.Fingerprint)
+}
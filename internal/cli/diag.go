@@ -0,0 +1,370 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strongswan/govici/vici"
+	"tailscale.com/client/local"
+	"tailscale.com/tailcfg"
+
+	"github.com/klowdo/tailswan/internal/diag"
+	"github.com/klowdo/tailswan/internal/mtu"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+func NewDiagCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diag",
+		Short: "Network diagnostics for IPsec and Tailscale paths",
+	}
+
+	cmd.AddCommand(
+		newDiagPingCmd(),
+		newDiagTCPCmd(),
+		newDiagTailscalePingCmd(),
+		newDiagXFRMCmd(),
+		newDiagMTUCmd(),
+	)
+
+	return cmd
+}
+
+func newDiagMTUCmd() *cobra.Command {
+	var target string
+	var noProbe bool
+
+	cmd := &cobra.Command{
+		Use:   "mtu <connection>",
+		Short: "Calculate and probe the MTU of a CHILD_SA",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sa, err := lookupChildSA(args[0])
+			if err != nil {
+				return err
+			}
+
+			result := mtu.ForChildSA(sa)
+
+			var out strings.Builder
+			writeMTUReport(&out, sa, &result)
+
+			if !noProbe {
+				probeMTU(cmd.Context(), &out, sa, &result, target)
+			}
+
+			if _, err := fmt.Fprint(cmd.OutOrStdout(), out.String()); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&target, "target", "", "host behind the tunnel to probe (default: first host of the remote traffic selector)")
+	cmd.Flags().BoolVar(&noProbe, "no-probe", false, "only report the calculated MTU")
+
+	return cmd
+}
+
+func newDiagPingCmd() *cobra.Command {
+	var target string
+	var count int
+
+	cmd := &cobra.Command{
+		Use:   "ping <connection>",
+		Short: "Ping a host behind a CHILD_SA from the matching local address",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveChildPath(args[0], target)
+			if err != nil {
+				return err
+			}
+
+			progress := printProgress(cmd)
+			progress(describePath(path))
+
+			result, err := diag.Ping(cmd.Context(), path.Target, path.Source, count, progress)
+			if err != nil {
+				return err
+			}
+			progress(fmt.Sprintf("%d/%d replies, rtt min/avg/max %.2f/%.2f/%.2f ms",
+				result.Received, result.Transmitted, result.MinRTTMs, result.AvgRTTMs, result.MaxRTTMs))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&target, "target", "", "host behind the tunnel (default: first host of the remote traffic selector)")
+	cmd.Flags().IntVarP(&count, "count", "c", 4, "number of echo requests")
+
+	return cmd
+}
+
+func newDiagTCPCmd() *cobra.Command {
+	var target string
+	var port uint16
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "tcp <connection>",
+		Short: "Open a TCP connection to a host behind a CHILD_SA",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if port == 0 {
+				return fmt.Errorf("--port is required")
+			}
+
+			path, err := resolveChildPath(args[0], target)
+			if err != nil {
+				return err
+			}
+
+			progress := printProgress(cmd)
+			progress(describePath(path))
+
+			result, err := diag.TCPConnect(cmd.Context(), netip.AddrPortFrom(path.Target, port), path.Source, timeout)
+			if err != nil {
+				return err
+			}
+			progress(fmt.Sprintf("connected to %s in %.2f ms", result.Target, result.LatencyMs))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&target, "target", "", "host behind the tunnel (default: first host of the remote traffic selector)")
+	cmd.Flags().Uint16VarP(&port, "port", "p", 0, "TCP port to connect to")
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Second, "connect timeout")
+
+	return cmd
+}
+
+func newDiagTailscalePingCmd() *cobra.Command {
+	var pingType string
+	var count int
+
+	cmd := &cobra.Command{
+		Use:   "tailscale-ping <peer>",
+		Short: "Ping a tailnet peer through tailscaled",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pt, err := diag.ParsePingType(pingType)
+			if err != nil {
+				return err
+			}
+
+			client := &local.Client{}
+			status, err := client.Status(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to get Tailscale status: %w", err)
+			}
+			ip, _, err := diag.ResolvePeer(status, args[0])
+			if err != nil {
+				return err
+			}
+
+			result, err := diag.TailscalePing(cmd.Context(), client, ip, pt, count, printProgress(cmd))
+			if err != nil {
+				return err
+			}
+			if !result.Direct && pt == tailcfg.PingDisco {
+				printProgress(cmd)("no direct path established, traffic is relayed")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&pingType, "type", "disco", "ping type: disco, tsmp, icmp or peerapi")
+	cmd.Flags().IntVarP(&count, "count", "c", 10, "maximum number of pings")
+
+	return cmd
+}
+
+func newDiagXFRMCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "xfrm <peer> <destination>",
+		Short: "Check that a tailnet peer's traffic to a destination hits an IPsec policy",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dst, err := netip.ParseAddr(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid destination %q: %w", args[1], err)
+			}
+
+			status, err := (&local.Client{}).Status(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to get Tailscale status: %w", err)
+			}
+			peer, hostname, err := diag.ResolvePeer(status, args[0])
+			if err != nil {
+				return err
+			}
+
+			check, err := diag.CheckPeer(cmd.Context(), peer, hostname, dst)
+			if err != nil {
+				return err
+			}
+
+			var out strings.Builder
+			writeXFRMReport(&out, check)
+			if _, err := fmt.Fprint(cmd.OutOrStdout(), out.String()); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			if !check.Matched {
+				return fmt.Errorf("traffic from %s to %s does not reach an IPsec SA", check.Peer, check.Destination)
+			}
+			return nil
+		},
+	}
+}
+
+func writeXFRMReport(out *strings.Builder, check *diag.XFRMCheck) {
+	fmt.Fprintf(out, "Peer:          %s\n", check.Peer)
+	fmt.Fprintf(out, "Flow:          %s -> %s\n", check.Source, check.Destination)
+	if check.Policy != nil {
+		p := check.Policy
+		fmt.Fprintf(out, "Policy:        %s -> %s dir %s priority %d reqid %d\n", p.Src, p.Dst, p.Dir, p.Priority, p.ReqID)
+	} else {
+		out.WriteString("Policy:        none\n")
+	}
+	for _, s := range check.States {
+		fmt.Fprintf(out, "SA:            %s -> %s %s spi %s mode %s\n", s.Src, s.Dst, s.Proto, s.SPI, s.Mode)
+	}
+	for _, hint := range check.Hints {
+		fmt.Fprintf(out, "Hint:          %s\n", hint)
+	}
+	if check.Matched {
+		out.WriteString("Result:        OK\n")
+	} else {
+		out.WriteString("Result:        FAIL\n")
+	}
+}
+
+func printProgress(cmd *cobra.Command) diag.Progress {
+	out := cmd.OutOrStdout()
+	return func(line string) {
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return
+		}
+	}
+}
+
+func describePath(path *diag.Path) string {
+	if path.SourceFound {
+		return fmt.Sprintf("Probing %s via %s/%s from %s", path.Target, path.SA.IKE, path.SA.Name, path.Source)
+	}
+	return fmt.Sprintf("Probing %s via %s/%s (no local address inside %s, probe may bypass the tunnel)",
+		path.Target, path.SA.IKE, path.SA.Name, strings.Join(path.SA.LocalTS, ", "))
+}
+
+func resolveChildPath(name, target string) (*diag.Path, error) {
+	sas, err := listChildSAs()
+	if err != nil {
+		return nil, err
+	}
+	return diag.ResolveChild(sas, name, target)
+}
+
+func listChildSAs() ([]viciconn.ChildSA, error) {
+	session, err := vici.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to charon: %w", err)
+	}
+	defer session.Close() //nolint:errcheck
+
+	sas, err := viciconn.ChildSAs(session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SAs: %w", err)
+	}
+	return sas, nil
+}
+
+func lookupChildSA(name string) (*viciconn.ChildSA, error) {
+	sas, err := listChildSAs()
+	if err != nil {
+		return nil, err
+	}
+
+	sa, ok := diag.FindChildSA(sas, name)
+	if !ok {
+		return nil, fmt.Errorf("no CHILD_SA found for %s; is the connection up?", name)
+	}
+	return sa, nil
+}
+
+func writeMTUReport(out *strings.Builder, sa *viciconn.ChildSA, result *mtu.Result) {
+	encr := sa.EncrAlg
+	if sa.EncrKeySize > 0 {
+		encr = fmt.Sprintf("%s-%d", encr, sa.EncrKeySize)
+	}
+	integ := sa.IntegAlg
+	if integ == "" {
+		integ = "(AEAD)"
+	}
+	mode := sa.Mode
+	if sa.UDPEncap {
+		mode += " (UDP-encapsulated)"
+	}
+
+	fmt.Fprintf(out, "CHILD_SA:      %s/%s (%s)\n", sa.IKE, sa.Name, sa.State)
+	fmt.Fprintf(out, "Encryption:    %s\n", encr)
+	fmt.Fprintf(out, "Integrity:     %s\n", integ)
+	fmt.Fprintf(out, "Mode:          %s\n", mode)
+	fmt.Fprintf(out, "Link MTU:      %d (towards %s)\n", result.LinkMTU, sa.RemoteHost)
+	fmt.Fprintf(out, "ESP overhead:  %d bytes (worst case)\n", result.Overhead)
+	fmt.Fprintf(out, "ESP MTU:       %d\n", result.ESPMTU)
+	fmt.Fprintf(out, "Tunnel MTU:    %d (tailnet MTU %d)\n", result.TunnelMTU, mtu.TailnetMTU)
+	fmt.Fprintf(out, "TCP MSS:       %d (IPv4), %d (IPv6)\n", result.MSS4, result.MSS6)
+	if !result.Known {
+		out.WriteString("Note:          unknown algorithm, overhead estimated conservatively\n")
+	}
+}
+
+func probeMTU(ctx context.Context, out *strings.Builder, sa *viciconn.ChildSA, result *mtu.Result, target string) {
+	var addr netip.Addr
+	if target != "" {
+		parsed, err := netip.ParseAddr(target)
+		if err != nil {
+			fmt.Fprintf(out, "\nProbe skipped: invalid target %q\n", target)
+			return
+		}
+		addr = parsed
+	} else {
+		guessed, ok := diag.DefaultTarget(sa.RemoteTS)
+		if !ok {
+			out.WriteString("\nProbe skipped: no remote subnet to probe, use --target\n")
+			return
+		}
+		addr = guessed
+	}
+
+	source, ok := diag.SourceFor(sa.LocalTS)
+	if ok {
+		fmt.Fprintf(out, "\nProbing path MTU to %s from %s...\n", addr, source)
+	} else {
+		fmt.Fprintf(out, "\nProbing path MTU to %s (no local address inside %s, probe may bypass the tunnel)...\n",
+			addr, strings.Join(sa.LocalTS, ", "))
+	}
+
+	low := diag.MinIPv4MTU
+	if addr.Is6() {
+		low = diag.MinIPv6MTU
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	pathMTU, err := diag.ProbePathMTU(probeCtx, addr, source, low, result.ESPMTU, diag.SystemPing)
+	if err != nil {
+		fmt.Fprintf(out, "Probe failed:  %v\n", err)
+		return
+	}
+
+	fmt.Fprintf(out, "Path MTU:      %d\n", pathMTU)
+	if pathMTU < result.TunnelMTU {
+		fmt.Fprintf(out, "Warning:       path MTU is below the calculated tunnel MTU %d; expect PMTU black holes for TCP without clamping to %d\n",
+			result.TunnelMTU, pathMTU-40)
+	}
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/doctor"
+)
+
+func NewDoctorCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Walk the data path and explain why traffic isn't flowing",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+			defer cancel()
+
+			checks := doctor.Evaluate(doctor.Gather(ctx, config.Load()))
+
+			var out strings.Builder
+			if jsonOutput {
+				data, err := json.MarshalIndent(checks, "", "  ")
+				if err != nil {
+					return fmt.Errorf("failed to encode checks: %w", err)
+				}
+				out.Write(data)
+				out.WriteString("\n")
+			} else {
+				writeDoctorReport(&out, checks)
+			}
+			if _, err := fmt.Fprint(cmd.OutOrStdout(), out.String()); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+
+			if failed := doctor.Failed(checks); failed > 0 {
+				return fmt.Errorf("%d check(s) failed", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "print checks as JSON")
+
+	return cmd
+}
+
+func writeDoctorReport(out *strings.Builder, checks []doctor.Check) {
+	for _, c := range checks {
+		mark := "✓"
+		switch c.Status {
+		case doctor.StatusWarn:
+			mark = "!"
+		case doctor.StatusFail:
+			mark = "✗"
+		}
+		fmt.Fprintf(out, "%s %-40s %s\n", mark, c.Name, c.Message)
+		if c.Hint != "" {
+			fmt.Fprintf(out, "    → %s\n", c.Hint)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/firewall"
+)
+
+func NewFirewallCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "firewall",
+		Short: "Inspect TailSwan-managed firewall rules",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Show the firewall rules owned by TailSwan",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			backend, err := firewall.NewBackend(cfg.Firewall.Backend)
+			if err != nil {
+				return fmt.Errorf("failed to select firewall backend: %w", err)
+			}
+
+			rules, err := backend.Show()
+			if err != nil {
+				return fmt.Errorf("failed to list %s rules: %w", backend.Name(), err)
+			}
+			if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Backend: %s\n\n%s", backend.Name(), rules); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return nil
+		},
+	})
+
+	return cmd
+}
@@ -2,24 +2,90 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"tailscale.com/client/local"
 
-	"github.com/klowdo/tailswan/internal/supervisor"
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/health"
 )
 
 func NewHealthCheckCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		required []string
+		ready    bool
+		live     bool
+	)
+
+	cmd := &cobra.Command{
 		Use:   "healthcheck",
 		Short: "Check if all services are healthy",
+		Long: `Run the liveness checks of /api/health/live: charon answers over VICI and the
+supervised processes run. This is what the container HEALTHCHECK runs, so a
+gateway waiting for route approval or a peer is not restarted.
+
+With --ready, run the readiness checks of /api/health/ready instead: also
+tailscaled, approval of the advertised routes, the required connections and
+certificate expiry, which only warns.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := supervisor.HealthCheck(); err != nil {
-				return fmt.Errorf("health check failed: %w", err)
+			cfg := config.Load()
+			checker := &health.Checker{
+				StateDir:       cfg.StateDir,
+				CredentialsDir: cfg.Swan.CredentialsDir(),
+				CertExpiry:     cfg.Swan.CertExpiryWarning(),
 			}
-			if _, err := fmt.Fprintln(cmd.OutOrStdout(), "All services healthy"); err != nil {
+			// tsnet's tailscaled lives inside the control server, out of
+			// reach of the local API socket.
+			if !cfg.Tailscale.UseTsnet {
+				checker.Tailscale = &local.Client{}
+			}
+
+			var report *health.Report
+			if ready || len(required) > 0 {
+				report = checker.Ready(cmd.Context(), required)
+			} else {
+				report = checker.Live(cmd.Context())
+			}
+
+			var out strings.Builder
+			writeHealthReport(&out, report)
+			if _, err := fmt.Fprint(cmd.OutOrStdout(), out.String()); err != nil {
 				return fmt.Errorf("failed to write output: %w", err)
 			}
+
+			if failed := report.Failed(); len(failed) > 0 {
+				names := make([]string, 0, len(failed))
+				for _, c := range failed {
+					names = append(names, c.Name)
+				}
+				return fmt.Errorf("health check failed: %s", strings.Join(names, ", "))
+			}
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&ready, "ready", false, "run the readiness checks instead of the liveness checks")
+	cmd.Flags().StringSliceVar(&required, "require-connections", nil, "connections or children that must have an installed CHILD_SA; implies --ready")
+	// --live selected the liveness checks before they became the default.
+	cmd.Flags().BoolVar(&live, "live", false, "only check that charon answers and the supervised processes run")
+	cmd.Flags().Lookup("live").Deprecated = "the liveness checks are the default"
+	cmd.MarkFlagsMutuallyExclusive("live", "ready")
+
+	return cmd
+}
+
+func writeHealthReport(out *strings.Builder, report *health.Report) {
+	for _, c := range report.Components {
+		mark := "✓"
+		switch c.Status {
+		case health.StatusSkip:
+			mark = "-"
+		case health.StatusWarn:
+			mark = "!"
+		case health.StatusFail:
+			mark = "✗"
+		}
+		fmt.Fprintf(out, "%s %-12s %7.1fms  %s\n", mark, c.Name, c.LatencyMs, c.Reason)
+	}
 }
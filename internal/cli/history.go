@@ -0,0 +1,320 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/netip"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/confighistory"
+	"github.com/klowdo/tailswan/internal/supervisor"
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/swanload"
+)
+
+func NewHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show, diff and roll back configuration versions",
+		Long: `Every change to swanctl.conf, its conf.d snippets and the advertised
+routes is recorded as a version in the state directory, with who made it.`,
+	}
+	cmd.AddCommand(newHistoryListCmd(), newHistoryShowCmd(), newHistoryDiffCmd(), newHistoryRollbackCmd(),
+		newHistoryPendingCmd(), newHistoryConfirmCmd(), newHistoryRevertCmd())
+	return cmd
+}
+
+func historyStore() *confighistory.Store {
+	return confighistory.NewStore(config.Load().StateDir)
+}
+
+func parseVersion(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("invalid version %q", s)
+	}
+	return v, nil
+}
+
+// localAuthor is the local user running the CLI.
+func localAuthor() confighistory.Author {
+	u, err := user.Current()
+	if err != nil {
+		return confighistory.Author{}
+	}
+	return confighistory.Author{Login: u.Username, Name: u.Name}
+}
+
+// advertisedRoutes returns the advertised routes, or nil when tailscaled
+// cannot be asked.
+func advertisedRoutes(ctx context.Context) []string {
+	prefixes, err := supervisor.NewTailscaleService().AdvertisedRoutes(ctx)
+	if err != nil {
+		return nil
+	}
+	return confighistory.Routes(prefixes)
+}
+
+// recordConfig records a change made with the CLI. The routes are
+// carried over when tailscaled cannot be asked.
+func recordConfig(ctx context.Context, cfg *config.Config, source, message string) (*confighistory.Snapshot, error) {
+	return confighistory.NewStore(cfg.StateDir).RecordConfig(cfg.Swan.ConfigPath, advertisedRoutes(ctx), localAuthor(), source, message)
+}
+
+// beginTrial puts the change about to be made on trial for seconds.
+func beginTrial(ctx context.Context, cfg *config.Config, seconds int, message string) (*confighistory.Pending, error) {
+	return confighistory.NewStore(cfg.StateDir).Begin(cfg.Swan.ConfigPath, advertisedRoutes(ctx), time.Duration(seconds)*time.Second, localAuthor(), message)
+}
+
+func trialNotice(p *confighistory.Pending) string {
+	return fmt.Sprintf("Run 'tailswan history confirm' before %s to keep the change; otherwise the control server reverts it\n",
+		p.Deadline.Local().Format(time.TimeOnly))
+}
+
+// charonTarget loads the configuration into charon over session and sets
+// the advertised routes with tailscaled.
+func charonTarget(cfg *config.Config, session *vici.Session) confighistory.Target {
+	ts := supervisor.NewTailscaleService()
+	return confighistory.Target{
+		ConfigPath: cfg.Swan.ConfigPath,
+		Apply: func(ctx context.Context) error {
+			root, err := swanconf.ParseFile(cfg.Swan.ConfigPath)
+			if err != nil {
+				return err
+			}
+			return swanload.Apply(ctx, session, root, filepath.Dir(cfg.Swan.ConfigPath))
+		},
+		SetRoutes: func(ctx context.Context, routes []netip.Prefix) error {
+			_, err := ts.SetAdvertiseRoutes(ctx, routes)
+			return err
+		},
+	}
+}
+
+func newHistoryListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the configuration versions, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			versions, err := historyStore().List()
+			if err != nil {
+				return err
+			}
+			if len(versions) == 0 {
+				return writeOutput(cmd, "No configuration versions recorded\n")
+			}
+			var out strings.Builder
+			for _, v := range versions {
+				fmt.Fprintf(&out, "%4d  %s  %-8s %-32s %s\n", v.Version, v.Time.Local().Format(time.DateTime), v.Source, v.Author, strings.Join(v.Changed, ", "))
+				if v.Message != "" {
+					fmt.Fprintf(&out, "      %s\n", v.Message)
+				}
+			}
+			return writeOutput(cmd, out.String())
+		},
+	}
+}
+
+func newHistoryShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <version>",
+		Short: "Show a version's files, with secrets redacted",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := parseVersion(args[0])
+			if err != nil {
+				return err
+			}
+			snap, err := historyStore().Get(version)
+			if err != nil {
+				return err
+			}
+			snap = snap.Redacted()
+
+			var out strings.Builder
+			fmt.Fprintf(&out, "Version %d, %s by %s (%s)\n", snap.Version, snap.Time.Local().Format(time.DateTime), snap.Author, snap.Source)
+			if snap.Message != "" {
+				fmt.Fprintf(&out, "%s\n", snap.Message)
+			}
+			fmt.Fprintf(&out, "Routes: %s\n", strings.Join(snap.Routes, ", "))
+			for _, name := range slices.Sorted(maps.Keys(snap.Files)) {
+				fmt.Fprintf(&out, "\n==> %s <==\n%s", name, snap.Files[name])
+			}
+			return writeOutput(cmd, out.String())
+		},
+	}
+}
+
+func newHistoryDiffCmd() *cobra.Command {
+	var from int
+
+	cmd := &cobra.Command{
+		Use:   "diff <version>",
+		Short: "Diff a version against the one before it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := parseVersion(args[0])
+			if err != nil {
+				return err
+			}
+			store := historyStore()
+			to, err := store.Get(version)
+			if err != nil {
+				return err
+			}
+			var before *confighistory.Snapshot
+			if from > 0 {
+				before, err = store.Get(from)
+			} else {
+				before, err = store.Previous(version)
+			}
+			if err != nil {
+				return err
+			}
+			return writeOutput(cmd, confighistory.Diff(before, to))
+		},
+	}
+
+	cmd.Flags().IntVar(&from, "from", 0, "version to diff against (default: the one before)")
+	return cmd
+}
+
+func newHistoryRollbackCmd() *cobra.Command {
+	var confirm int
+
+	cmd := &cobra.Command{
+		Use:   "rollback <version>",
+		Short: "Restore a version, load it and advertise its routes",
+		Long: `Restore the files of a version, load them into charon over VICI and
+advertise the routes it had. The rollback is recorded as a new version.
+With BGP or HA enabled, the advertised routes are managed by TailSwan and
+may be changed again.
+
+With --confirm, the rollback is on trial: unless it is confirmed with
+'tailswan history confirm' in time, the control server reverts it.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := parseVersion(args[0])
+			if err != nil {
+				return err
+			}
+			cfg := config.Load()
+			if cfg.Swan.ConfigPath == "" {
+				return errors.New("SWAN_CONFIG is not set")
+			}
+			store := confighistory.NewStore(cfg.StateDir)
+			if _, err := store.Get(version); err != nil {
+				return err
+			}
+
+			var pending *confighistory.Pending
+			if confirm > 0 {
+				if pending, err = beginTrial(cmd.Context(), cfg, confirm, fmt.Sprintf("Rolled back to version %d", version)); err != nil {
+					return err
+				}
+			}
+			var snap *confighistory.Snapshot
+			err = withCharon(func(session *vici.Session) error {
+				snap, err = store.Rollback(cmd.Context(), charonTarget(cfg, session), version, localAuthor())
+				return err
+			})
+			if err != nil {
+				if pending != nil {
+					err = errors.Join(err, store.Cancel())
+				}
+				return err
+			}
+
+			out := fmt.Sprintf("Rolled back to version %d, recorded as version %d\n", version, snap.Version)
+			if pending != nil {
+				out += trialNotice(pending)
+			}
+			return writeOutput(cmd, out)
+		},
+	}
+
+	cmd.Flags().IntVar(&confirm, "confirm", 0, "revert unless confirmed within this many seconds")
+	return cmd
+}
+
+func newHistoryPendingCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "pending",
+		Short: "Show the change waiting to be confirmed",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			p, err := historyStore().Pending()
+			if err != nil {
+				return err
+			}
+			if p == nil {
+				return writeOutput(cmd, "No change is waiting to be confirmed\n")
+			}
+			var out strings.Builder
+			fmt.Fprintf(&out, "%s by %s\n", p.Message, p.Author)
+			fmt.Fprintf(&out, "Started:  %s\n", p.Started.Local().Format(time.DateTime))
+			fmt.Fprintf(&out, "Deadline: %s (in %s)\n", p.Deadline.Local().Format(time.DateTime), time.Until(p.Deadline).Round(time.Second))
+			return writeOutput(cmd, out.String())
+		},
+	}
+}
+
+func newHistoryConfirmCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "confirm",
+		Short: "Keep the change waiting to be confirmed",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			p, err := historyStore().Confirm()
+			if err != nil {
+				return err
+			}
+			return writeOutput(cmd, fmt.Sprintf("Confirmed: %s\n", p.Message))
+		},
+	}
+}
+
+func newHistoryRevertCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "revert",
+		Short: "Revert the change waiting to be confirmed now",
+		Long: `Restore the configuration and the advertised routes from before the
+change waiting to be confirmed. Changes made after it are reverted too.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg := config.Load()
+			if cfg.Swan.ConfigPath == "" {
+				return errors.New("SWAN_CONFIG is not set")
+			}
+			var (
+				p    *confighistory.Pending
+				snap *confighistory.Snapshot
+			)
+			err := withCharon(func(session *vici.Session) error {
+				var err error
+				p, snap, err = confighistory.NewStore(cfg.StateDir).Revert(cmd.Context(), charonTarget(cfg, session), localAuthor())
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			out := fmt.Sprintf("Reverted: %s\n", p.Message)
+			if snap != nil {
+				out += fmt.Sprintf("Recorded as version %d\n", snap.Version)
+			}
+			return writeOutput(cmd, out)
+		},
+	}
+}
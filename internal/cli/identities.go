@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/identity"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+func NewIdentitiesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "identities [address]",
+		Short: "Show which IPsec client identity holds each virtual IP",
+		Long: `List the IPsec clients holding virtual IPs with the identity they
+authenticated as: the EAP or XAuth identity when they used one, their IKE
+identity otherwise. With RW_ACCESS set, also show the tailnet destinations
+each may reach. Given an address, show only the client holding it.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			policy, err := identity.ParsePolicy(config.Load().RW.Access)
+			if err != nil {
+				return fmt.Errorf("RW_ACCESS: %w", err)
+			}
+
+			var ids []viciconn.IKEIdentity
+			err = withCharon(func(session *vici.Session) error {
+				var err error
+				ids, err = viciconn.IKEIdentities(cmd.Context(), session)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list IKE_SAs: %w", err)
+			}
+
+			table := identity.NewTable(policy)
+			table.Reset(ids)
+			mappings := table.List()
+			if len(args) == 1 {
+				addr, err := netip.ParseAddr(args[0])
+				if err != nil {
+					return fmt.Errorf("invalid address: %w", err)
+				}
+				m, ok := table.Lookup(addr)
+				if !ok {
+					return fmt.Errorf("no IPsec client holds %s", addr)
+				}
+				mappings = []identity.Mapping{m}
+			}
+
+			var out strings.Builder
+			writeIdentities(&out, mappings, len(policy) > 0)
+			return writeOutput(cmd, out.String())
+		},
+	}
+}
+
+func writeIdentities(out *strings.Builder, mappings []identity.Mapping, enforced bool) {
+	if len(mappings) == 0 {
+		out.WriteString("No IPsec clients hold virtual IPs\n")
+		return
+	}
+	for i := range mappings {
+		m := &mappings[i]
+		fmt.Fprintf(out, "%s %s (%s from %s, since %s)\n", m.Identity, strings.Join(m.Addresses, ", "),
+			m.Connection, m.RemoteHost, m.Since.Local().Format(time.DateTime))
+		if !enforced {
+			continue
+		}
+		if len(m.Allowed) == 0 {
+			out.WriteString("  allowed: nothing\n")
+			continue
+		}
+		fmt.Fprintf(out, "  allowed: %s\n", strings.Join(m.Allowed, ", "))
+	}
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/klowdo/tailswan/internal/ipsecconf"
+)
+
+func NewImportCmd() *cobra.Command {
+	var (
+		secretsPath string
+		output      string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import <ipsec.conf>",
+		Short: "Convert a legacy ipsec.conf and ipsec.secrets to swanctl.conf",
+		Long: `Convert the conn and ca sections of a stroke-style ipsec.conf, resolving
+also= and %default, together with ipsec.secrets, to an equivalent
+swanctl.conf. The left side is taken as the local one, and conns with
+auto=ignore are skipped. Options that could not be translated, and files
+to copy into the swanctl directories, are reported on stderr and at the
+top of the output.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := ipsecconf.ParseFile(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", args[0], err)
+			}
+
+			path := secretsPath
+			if path == "" {
+				path = filepath.Join(filepath.Dir(args[0]), "ipsec.secrets")
+			}
+			secrets, err := ipsecconf.ParseSecretsFile(path)
+			switch {
+			case errors.Is(err, fs.ErrNotExist) && secretsPath == "":
+			case err != nil:
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+
+			res, err := ipsecconf.Convert(conf, secrets)
+			if err != nil {
+				return err
+			}
+
+			var report strings.Builder
+			for _, w := range res.Warnings {
+				report.WriteString("Warning: " + w.String() + "\n")
+			}
+			if _, err := fmt.Fprint(cmd.ErrOrStderr(), report.String()); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+
+			if output == "" {
+				return writeOutput(cmd, res.String())
+			}
+			if err := os.WriteFile(output, []byte(res.String()), 0o600); err != nil {
+				return fmt.Errorf("failed to write %s: %w", output, err)
+			}
+			return writeOutput(cmd, fmt.Sprintf("Wrote %s\n", output))
+		},
+	}
+
+	cmd.Flags().StringVar(&secretsPath, "secrets", "", "ipsec.secrets to convert (default: ipsec.secrets next to <ipsec.conf>, if any)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write (default: stdout)")
+	return cmd
+}
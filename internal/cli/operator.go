@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/operator"
+	"github.com/klowdo/tailswan/internal/supervisor"
+)
+
+func NewOperatorCmd() *cobra.Command {
+	var (
+		gateway   string
+		namespace string
+		resync    time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "operator",
+		Short: "Reconcile TailSwanConnection and TailSwanGateway resources from Kubernetes",
+		Long: `Run as a Kubernetes operator next to charon and tailscaled, in the
+TailSwan pod, with its service account.
+
+The TailSwanConnections naming the gateway are loaded into charon, and
+unloaded when they are deleted. The routes of the TailSwanGateway are
+advertised to the tailnet with those of TS_ROUTES. The state of the IKE
+and CHILD_SAs is written back to the status of both.
+
+The gateway defaults to TS_HOSTNAME, and the namespace to the pod's.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			client, err := operator.InCluster()
+			if err != nil {
+				return err
+			}
+			if namespace != "" {
+				client = client.WithNamespace(namespace)
+			}
+			if gateway == "" {
+				gateway = cfg.Tailscale.Hostname
+			}
+			var base []netip.Prefix
+			for _, route := range cfg.Tailscale.Routes {
+				p, err := netip.ParsePrefix(route)
+				if err != nil {
+					return fmt.Errorf("invalid route %q in TS_ROUTES", route)
+				}
+				base = append(base, p)
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return withCharon(func(session *vici.Session) error {
+				return operator.New(client, session, operator.Config{
+					SetRoutes:  supervisor.NewTailscaleService().SetAdvertiseRoutes,
+					Gateway:    gateway,
+					Dir:        cfg.Swan.CredentialsDir(),
+					StatePath:  filepath.Join(cfg.StateDir, "operator.json"),
+					BaseRoutes: base,
+					Resync:     resync,
+				}).Run(ctx)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&gateway, "gateway", "", "name of the TailSwanGateway this instance is (default TS_HOSTNAME)")
+	cmd.Flags().StringVar(&namespace, "namespace", "", "namespace of the resources (default the pod's)")
+	cmd.Flags().DurationVar(&resync, "resync", 30*time.Second, "how often to reconcile and update the SA state without changes")
+	return cmd
+}
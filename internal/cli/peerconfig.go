@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/peerconfig"
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+func NewExportPeerConfigCmd() *cobra.Command {
+	var (
+		format  string
+		address string
+		output  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export-peer-config <conn>",
+		Short: "Generate the partner side of a site-to-site connection",
+		Long: `Generate the configuration the remote end of a loaded connection needs,
+with the sides swapped: addresses, identities, proposals, traffic selectors
+and lifetimes. Pre-shared keys are never exported; the output holds a
+placeholder instead and notes anything that had to be guessed.
+
+Formats: ` + strings.Join(peerconfig.Formats(), ", ") + `.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(peerconfig.Formats(), format) {
+				return fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(peerconfig.Formats(), ", "))
+			}
+
+			var conns []viciconn.Conn
+			err := withCharon(func(session *vici.Session) error {
+				var err error
+				conns, err = viciconn.Conns(cmd.Context(), session)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list connections: %w", err)
+			}
+			i := slices.IndexFunc(conns, func(c viciconn.Conn) bool { return c.Name == args[0] })
+			if i < 0 {
+				return fmt.Errorf("connection %s is not loaded", args[0])
+			}
+
+			path := config.Load().Swan.ConfigPath
+			file, err := swanconf.ParseFile(path)
+			if err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					if _, err := fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err); err != nil {
+						return fmt.Errorf("failed to write output: %w", err)
+					}
+				}
+				file = nil
+			}
+
+			peer, err := peerconfig.Build(&conns[i], file, address)
+			if err != nil {
+				return err
+			}
+			out, err := peerconfig.Render(peer, format)
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				return writeOutput(cmd, out)
+			}
+			if err := os.WriteFile(output, []byte(out), 0o600); err != nil {
+				return fmt.Errorf("failed to write %s: %w", output, err)
+			}
+			return writeOutput(cmd, fmt.Sprintf("Wrote %s\n", output))
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", peerconfig.FormatSheet, "output format: "+strings.Join(peerconfig.Formats(), ", "))
+	cmd.Flags().StringVar(&address, "address", "", "TailSwan's public address (default: the connection's local address)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write (default: stdout)")
+	return cmd
+}
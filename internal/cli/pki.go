@@ -0,0 +1,268 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/certs"
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/pki"
+)
+
+func NewPKICmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pki",
+		Short: "Issue, revoke and export certificates with the built-in CA",
+		Long: `A minimal CA kept in TAILSWAN_STATE_DIR/pki. It is created on first use, and
+its certificate and CRL are installed in the swanctl directory and loaded
+into charon. Server certificates carry the serverAuth and IKE intermediate
+EKUs gateways and road-warrior clients expect, client certificates carry
+clientAuth.`,
+	}
+
+	var commonName string
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create the CA and install it into charon",
+		Long: `Create the CA and install its certificate and CRL into charon. When the CA
+exists it is installed again, e.g. after charon was not reachable.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			dir := pki.Dir(cfg.StateDir)
+			verb := "Created"
+			if pki.Exists(dir) {
+				verb = "Installed existing"
+			}
+			ca, err := pki.Open(dir, commonName)
+			if err != nil {
+				return fmt.Errorf("failed to create the CA: %w", err)
+			}
+			if err := installCA(cmd.Context(), cfg, ca); err != nil {
+				return err
+			}
+			return writeOutput(cmd, fmt.Sprintf("%s CA '%s'\n", verb, ca.Certificate().Subject.CommonName))
+		},
+	}
+	initCmd.Flags().StringVar(&commonName, "cn", pki.DefaultCommonName, "common name of the CA")
+	cmd.AddCommand(initCmd)
+
+	var (
+		profile string
+		keyType string
+		sans    []string
+		days    int
+		install bool
+	)
+	issueCmd := &cobra.Command{
+		Use:   "issue <name>",
+		Short: "Issue a server or client certificate",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if days <= 0 {
+				return errors.New("--days must be positive")
+			}
+			cfg := config.Load()
+			ca, err := openCA(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			issued, err := ca.Issue(pki.Request{
+				Name:     args[0],
+				Profile:  profile,
+				KeyType:  keyType,
+				SANs:     sans,
+				Validity: time.Duration(days) * 24 * time.Hour,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to issue %s: %w", args[0], err)
+			}
+			if install {
+				store := certs.NewStore(cfg.Swan.CredentialsDir())
+				creds, err := ca.InstallIssued(store, issued.Name)
+				if err != nil {
+					return fmt.Errorf("issued %s, but failed to install it: %w", issued.Name, err)
+				}
+				if err := loadCredentials(cmd.Context(), store, creds); err != nil {
+					return fmt.Errorf("installed %s, but charon did not load it: %w", issued.Name, err)
+				}
+			}
+			return writeOutput(cmd, fmt.Sprintf("Issued %s certificate '%s', serial %s, expires %s\n",
+				issued.Profile, issued.Name, issued.Serial, issued.NotAfter.Local().Format(time.DateOnly)))
+		},
+	}
+	issueCmd.Flags().StringVar(&profile, "profile", pki.ProfileClient, "server (gateways) or client (road-warriors)")
+	issueCmd.Flags().StringVar(&keyType, "key-type", pki.KeyECDSA, "ecdsa or rsa")
+	issueCmd.Flags().StringSliceVar(&sans, "san", nil, "DNS name, IP address or email address (repeatable)")
+	issueCmd.Flags().IntVar(&days, "days", int(pki.DefaultValidity/(24*time.Hour)), "validity in days")
+	issueCmd.Flags().BoolVar(&install, "install", false, "also install the certificate and key as this gateway's own")
+	cmd.AddCommand(issueCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the issued certificates",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			dir := pki.Dir(cfg.StateDir)
+			if !pki.Exists(dir) {
+				return writeOutput(cmd, "No CA yet, run 'tailswan pki init'\n")
+			}
+			ca, err := pki.Open(dir, "")
+			if err != nil {
+				return fmt.Errorf("failed to open the CA: %w", err)
+			}
+			list, err := ca.List()
+			if err != nil {
+				return fmt.Errorf("failed to list certificates: %w", err)
+			}
+			var out strings.Builder
+			writeIssued(&out, ca, list)
+			return writeOutput(cmd, out.String())
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "revoke <name>",
+		Short: "Revoke a certificate and load the new CRL into charon",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			dir := pki.Dir(cfg.StateDir)
+			if !pki.Exists(dir) {
+				return errors.New("no CA yet")
+			}
+			ca, err := pki.Open(dir, "")
+			if err != nil {
+				return fmt.Errorf("failed to open the CA: %w", err)
+			}
+			revoked, err := ca.Revoke(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to revoke %s: %w", args[0], err)
+			}
+			if err := installCA(cmd.Context(), cfg, ca); err != nil {
+				return fmt.Errorf("revoked %s, but %w", args[0], err)
+			}
+			return writeOutput(cmd, fmt.Sprintf("Revoked '%s', serial %s\n", revoked.Name, revoked.Serial))
+		},
+	})
+
+	var (
+		format   string
+		password string
+		legacy   bool
+		output   string
+	)
+	exportCmd := &cobra.Command{
+		Use:   "export <name>",
+		Short: "Export a certificate with its key and the CA as PKCS#12 or PEM",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			dir := pki.Dir(cfg.StateDir)
+			if !pki.Exists(dir) {
+				return errors.New("no CA yet")
+			}
+			ca, err := pki.Open(dir, "")
+			if err != nil {
+				return fmt.Errorf("failed to open the CA: %w", err)
+			}
+			if password == "" {
+				password = os.Getenv("TAILSWAN_EXPORT_PASSWORD")
+			}
+			data, err := ca.Export(args[0], format, password, legacy)
+			if err != nil {
+				return fmt.Errorf("failed to export %s: %w", args[0], err)
+			}
+			if output == "" {
+				output = args[0] + "." + format
+			}
+			if err := os.WriteFile(output, data, 0o600); err != nil {
+				return fmt.Errorf("failed to write %s: %w", output, err)
+			}
+			return writeOutput(cmd, fmt.Sprintf("Wrote %s\n", output))
+		},
+	}
+	exportCmd.Flags().StringVar(&format, "format", pki.FormatPKCS12, "p12 for road-warrior clients, pem for partner gateways")
+	exportCmd.Flags().StringVar(&password, "password", "", "PKCS#12 password (default: $TAILSWAN_EXPORT_PASSWORD)")
+	exportCmd.Flags().BoolVar(&legacy, "legacy", false, "use the 3DES PKCS#12 encryption older Android and macOS versions require")
+	exportCmd.Flags().StringVarP(&output, "output", "o", "", "file to write (default: <name>.<format>)")
+	cmd.AddCommand(exportCmd)
+
+	return cmd
+}
+
+// openCA opens the CA, installing it into charon when it is created.
+func openCA(ctx context.Context, cfg *config.Config) (*pki.CA, error) {
+	dir := pki.Dir(cfg.StateDir)
+	created := !pki.Exists(dir)
+	ca, err := pki.Open(dir, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the CA: %w", err)
+	}
+	if created {
+		if err := installCA(ctx, cfg, ca); err != nil {
+			return nil, fmt.Errorf("created the CA, but %w; run 'tailswan pki init' to install it", err)
+		}
+	}
+	return ca, nil
+}
+
+func installCA(ctx context.Context, cfg *config.Config, ca *pki.CA) error {
+	store := certs.NewStore(cfg.Swan.CredentialsDir())
+	creds, err := ca.Install(store)
+	if err != nil {
+		return fmt.Errorf("failed to install the CA: %w", err)
+	}
+	if err := loadCredentials(ctx, store, creds); err != nil {
+		return fmt.Errorf("charon did not load the CA: %w", err)
+	}
+	return nil
+}
+
+func loadCredentials(ctx context.Context, store *certs.Store, creds []certs.Credential) error {
+	session, err := vici.NewSession()
+	if err != nil {
+		return fmt.Errorf("charon is not reachable: %w", err)
+	}
+	defer session.Close() //nolint:errcheck
+
+	for _, c := range creds {
+		if err := store.Load(ctx, session, c.Kind, c.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeIssued(out *strings.Builder, ca *pki.CA, list []pki.Cert) {
+	cert := ca.Certificate()
+	fmt.Fprintf(out, "CA %s, expires %s\n", cert.Subject.CommonName, cert.NotAfter.Local().Format(time.DateOnly))
+	if len(list) == 0 {
+		out.WriteString("No certificates issued\n")
+		return
+	}
+	for _, c := range list {
+		state := "expires " + c.NotAfter.Local().Format(time.DateOnly)
+		if c.Revoked != nil {
+			state = "revoked " + c.Revoked.Local().Format(time.DateOnly)
+		}
+		fmt.Fprintf(out, "  %-6s %-24s %-18s %s", c.Profile, c.Name, state, c.Serial)
+		if len(c.SANs) > 0 {
+			fmt.Fprintf(out, "  %s", strings.Join(c.SANs, ", "))
+		}
+		out.WriteString("\n")
+	}
+}
+
+func writeOutput(cmd *cobra.Command, s string) error {
+	if _, err := fmt.Fprint(cmd.OutOrStdout(), s); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
@@ -1,26 +1,118 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/strongswan/govici/vici"
 
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/confighistory"
 	"github.com/klowdo/tailswan/internal/supervisor"
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/swandiff"
 )
 
 func NewReloadCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		confirm int
+		dryRun  bool
+	)
+
+	cmd := &cobra.Command{
 		Use:   "reload",
 		Short: "Reload strongSwan configuration",
+		Long: `Reload strongSwan configuration.
+
+With --confirm, the reload is on trial: unless it is confirmed with
+'tailswan history confirm' in time, the control server restores and loads
+the configuration from before it.
+
+With --dry-run, nothing is loaded: the configuration is compared with what
+charon has loaded, listing the connections, shared secrets, pools and
+authorities a reload would add, change or remove, and the SAs of the
+connections it changes or removes.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			if dryRun {
+				return reloadDryRun(cmd, cfg)
+			}
+			var pending *confighistory.Pending
+			if confirm > 0 {
+				if cfg.Swan.ConfigPath == "" {
+					return errors.New("--confirm needs SWAN_CONFIG to be set")
+				}
+				var err error
+				if pending, err = beginTrial(cmd.Context(), cfg, confirm, "Reloaded with tailswan reload"); err != nil {
+					return err
+				}
+			}
+
 			sw := &supervisor.SwanService{}
 			if err := sw.Reload(); err != nil {
-				return fmt.Errorf("failed to reload configuration: %w", err)
+				err = fmt.Errorf("failed to reload configuration: %w", err)
+				if pending != nil {
+					err = errors.Join(err, confighistory.NewStore(cfg.StateDir).Cancel())
+				}
+				return err
 			}
 			if _, err := fmt.Fprintln(cmd.OutOrStdout(), "Configuration reloaded"); err != nil {
 				return fmt.Errorf("failed to write output: %w", err)
 			}
-			return nil
+
+			if cfg.Swan.ConfigPath == "" {
+				return nil
+			}
+			var out string
+			snap, err := recordConfig(cmd.Context(), cfg, confighistory.SourceReload, "Reloaded with tailswan reload")
+			switch {
+			case err != nil:
+				if _, err := fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to record the configuration: %v\n", err); err != nil {
+					return fmt.Errorf("failed to write output: %w", err)
+				}
+			case snap != nil:
+				out = fmt.Sprintf("Recorded as version %d\n", snap.Version)
+			}
+			if pending != nil {
+				out += trialNotice(pending)
+			}
+			if out == "" {
+				return nil
+			}
+			return writeOutput(cmd, out)
 		},
 	}
+
+	cmd.Flags().IntVar(&confirm, "confirm", 0, "revert unless confirmed within this many seconds")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what the reload would change without loading anything")
+	cmd.MarkFlagsMutuallyExclusive("confirm", "dry-run")
+	return cmd
+}
+
+func reloadDryRun(cmd *cobra.Command, cfg *config.Config) error {
+	if cfg.Swan.ConfigPath == "" {
+		return errors.New("--dry-run needs SWAN_CONFIG to be set")
+	}
+	root, err := swanconf.ParseFile(cfg.Swan.ConfigPath)
+	if err != nil {
+		return err
+	}
+	var report *swandiff.Report
+	err = withCharon(func(session *vici.Session) error {
+		loaded, err := swandiff.Fetch(cmd.Context(), session)
+		if err != nil {
+			return err
+		}
+		report = swandiff.Compare(root, loaded)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	out := report.String()
+	if !report.Empty() {
+		out += "\nProposals, timeouts and the secrets themselves are not compared: charon does not report them.\n"
+	}
+	return writeOutput(cmd, out)
 }
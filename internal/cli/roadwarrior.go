@@ -0,0 +1,349 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/pki"
+	"github.com/klowdo/tailswan/internal/roadwarrior"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+func NewRoadWarriorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "rw",
+		Aliases: []string{"roadwarrior"},
+		Short:   "Manage IKEv2 remote-access users, virtual IP pools and client profiles",
+		Long: `Manage road-warrior users and the virtual IP pools they get addresses from.
+They are kept in TAILSWAN_STATE_DIR and loaded into charon over VICI; the
+control server loads them again when charon restarts. EAP-MSCHAPv2 users
+sign in with a password, EAP-TLS users with a client certificate of the
+built-in CA.`,
+	}
+
+	userCmd := &cobra.Command{
+		Use:   "user",
+		Short: "Add, delete and list users",
+	}
+
+	var auth, password string
+	addUserCmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Add a user and load its secret into charon",
+		Long: `Add a user. An EAP-MSCHAPv2 user without --password gets a generated one,
+which is printed once. An EAP-TLS user is issued a client certificate with
+the name as SAN.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			store := roadwarrior.NewStore(cfg.StateDir)
+			u, err := store.AddUser(time.Now(), roadwarrior.User{Name: args[0], Auth: auth, Password: password})
+			if err != nil {
+				return fmt.Errorf("failed to add %s: %w", args[0], err)
+			}
+
+			var out strings.Builder
+			if u.Auth == roadwarrior.AuthTLS {
+				issued, err := issueUserCert(cmd.Context(), cfg, u.Name)
+				if err != nil {
+					if _, derr := store.DeleteUser(u.Name); derr != nil {
+						return errors.Join(err, derr)
+					}
+					return err
+				}
+				fmt.Fprintf(&out, "Added EAP-TLS user '%s', certificate serial %s\n", u.Name, issued.Serial)
+			} else {
+				fmt.Fprintf(&out, "Added EAP-MSCHAPv2 user '%s'\n", u.Name)
+				if password == "" {
+					fmt.Fprintf(&out, "Password: %s\n", u.Password)
+				}
+			}
+
+			err = withCharon(func(session *vici.Session) error {
+				return roadwarrior.LoadUser(cmd.Context(), session, u)
+			})
+			if err != nil {
+				return fmt.Errorf("added %s, but charon did not load its secret: %w", u.Name, err)
+			}
+			return writeOutput(cmd, out.String())
+		},
+	}
+	addUserCmd.Flags().StringVar(&auth, "auth", roadwarrior.AuthMSCHAPv2, "eap-mschapv2 or eap-tls")
+	addUserCmd.Flags().StringVar(&password, "password", "", "EAP-MSCHAPv2 password (default: generated)")
+	userCmd.AddCommand(addUserCmd)
+
+	userCmd.AddCommand(&cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a user, unloading its secret or revoking its certificate",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			u, err := roadwarrior.NewStore(cfg.StateDir).DeleteUser(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to delete %s: %w", args[0], err)
+			}
+			err = withCharon(func(session *vici.Session) error {
+				return roadwarrior.UnloadUser(cmd.Context(), session, u)
+			})
+			if err != nil {
+				return fmt.Errorf("deleted %s, but charon did not unload its secret: %w", u.Name, err)
+			}
+			if u.Auth == roadwarrior.AuthTLS {
+				if err := revokeUserCert(cmd.Context(), cfg, u.Name); err != nil {
+					return fmt.Errorf("deleted %s, but %w", u.Name, err)
+				}
+			}
+			return writeOutput(cmd, fmt.Sprintf("Deleted '%s'\n", u.Name))
+		},
+	})
+
+	userCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the users",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			users, err := roadwarrior.NewStore(config.Load().StateDir).Users()
+			if err != nil {
+				return fmt.Errorf("failed to list users: %w", err)
+			}
+			var out strings.Builder
+			if len(users) == 0 {
+				out.WriteString("No users\n")
+			}
+			for _, u := range users {
+				fmt.Fprintf(&out, "  %-32s %-13s added %s\n", u.Name, u.Auth, u.Created.Local().Format(time.DateOnly))
+			}
+			return writeOutput(cmd, out.String())
+		},
+	})
+	cmd.AddCommand(userCmd)
+
+	poolCmd := &cobra.Command{
+		Use:   "pool",
+		Short: "Add, delete and list virtual IP pools",
+		Long: `Manage the virtual IP pools road-warriors get addresses from. A connection
+uses one with pools = <name>.`,
+	}
+
+	var dns []string
+	addPoolCmd := &cobra.Command{
+		Use:   "add <name> <addrs>",
+		Short: "Add or replace a pool of a subnet or a from-to range and load it",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			p, err := roadwarrior.NewStore(cfg.StateDir).PutPool(roadwarrior.Pool{Name: args[0], Addrs: args[1], DNS: dns})
+			if err != nil {
+				return fmt.Errorf("failed to add pool %s: %w", args[0], err)
+			}
+			err = withCharon(func(session *vici.Session) error {
+				return roadwarrior.LoadPool(cmd.Context(), session, p)
+			})
+			if err != nil {
+				return fmt.Errorf("stored pool %s, but charon did not load it: %w", p.Name, err)
+			}
+			return writeOutput(cmd, fmt.Sprintf("Loaded pool '%s' (%s)\n", p.Name, p.Addrs))
+		},
+	}
+	addPoolCmd.Flags().StringSliceVar(&dns, "dns", nil, "DNS server handed to the clients (repeatable)")
+	poolCmd.AddCommand(addPoolCmd)
+
+	poolCmd.AddCommand(&cobra.Command{
+		Use:   "delete <name>",
+		Short: "Unload and delete a pool; charon refuses while it has online leases",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := withCharon(func(session *vici.Session) error {
+				return viciconn.UnloadPool(cmd.Context(), session, args[0])
+			})
+			if err != nil {
+				return fmt.Errorf("charon did not unload pool %s: %w", args[0], err)
+			}
+			if err := roadwarrior.NewStore(config.Load().StateDir).DeletePool(args[0]); err != nil {
+				return fmt.Errorf("failed to delete pool %s: %w", args[0], err)
+			}
+			return writeOutput(cmd, fmt.Sprintf("Deleted pool '%s'\n", args[0]))
+		},
+	})
+
+	poolCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the configured pools",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pools, err := roadwarrior.NewStore(config.Load().StateDir).Pools()
+			if err != nil {
+				return fmt.Errorf("failed to list pools: %w", err)
+			}
+			var out strings.Builder
+			if len(pools) == 0 {
+				out.WriteString("No pools\n")
+			}
+			for _, p := range pools {
+				fmt.Fprintf(&out, "  %-16s %-32s", p.Name, p.Addrs)
+				if len(p.DNS) > 0 {
+					fmt.Fprintf(&out, " dns %s", strings.Join(p.DNS, ", "))
+				}
+				out.WriteString("\n")
+			}
+			return writeOutput(cmd, out.String())
+		},
+	})
+	cmd.AddCommand(poolCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "leases",
+		Short: "Show the pools charon has loaded with their leases",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var pools []viciconn.Pool
+			err := withCharon(func(session *vici.Session) error {
+				var err error
+				pools, err = viciconn.Pools(cmd.Context(), session)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to read the pools: %w", err)
+			}
+			var out strings.Builder
+			writeLeases(&out, pools)
+			return writeOutput(cmd, out.String())
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "sync",
+		Short: "Load every user and pool into charon again",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store := roadwarrior.NewStore(config.Load().StateDir)
+			err := withCharon(func(session *vici.Session) error {
+				return store.Sync(cmd.Context(), session)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to load the users and pools: %w", err)
+			}
+			return writeOutput(cmd, "Loaded the users and pools into charon\n")
+		},
+	})
+
+	var format, output string
+	profileCmd := &cobra.Command{
+		Use:   "profile <name>",
+		Short: "Generate a user's client profile",
+		Long: `Generate a client profile for a user: mobileconfig for macOS and iOS, sswan
+for the strongSwan Android app, or ps1, a PowerShell script for Windows.
+The profile trusts the built-in CA when there is one. An EAP-TLS profile
+carries the user's certificate bundle, encrypted with --password.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			u, err := roadwarrior.NewStore(cfg.StateDir).User(args[0])
+			if err != nil {
+				return err
+			}
+			if password == "" {
+				password = os.Getenv("TAILSWAN_EXPORT_PASSWORD")
+			}
+			p := &roadwarrior.Profile{
+				User:           u,
+				Name:           cfg.RW.ProfileName,
+				Server:         cfg.RW.Server,
+				ServerID:       cfg.RW.ServerID,
+				PKCS12Password: password,
+			}
+
+			dir := pki.Dir(cfg.StateDir)
+			if pki.Exists(dir) {
+				ca, err := pki.Open(dir, "")
+				if err != nil {
+					return fmt.Errorf("failed to open the CA: %w", err)
+				}
+				p.CACert = ca.Certificate()
+				if u.Auth == roadwarrior.AuthTLS {
+					// Apple and Android clients only import the legacy
+					// PKCS#12 encryption.
+					legacy := format != roadwarrior.FormatPowerShell
+					if p.PKCS12, err = ca.Export(u.Name, pki.FormatPKCS12, password, legacy); err != nil {
+						return fmt.Errorf("failed to export the certificate of %s: %w", u.Name, err)
+					}
+				}
+			}
+
+			data, err := roadwarrior.Generate(format, p)
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				output = u.Name + "." + format
+			}
+			if err := os.WriteFile(output, data, 0o600); err != nil {
+				return fmt.Errorf("failed to write %s: %w", output, err)
+			}
+			return writeOutput(cmd, fmt.Sprintf("Wrote %s\n", output))
+		},
+	}
+	profileCmd.Flags().StringVar(&format, "format", roadwarrior.FormatMobileconfig, "mobileconfig, sswan or ps1")
+	profileCmd.Flags().StringVar(&password, "password", "", "EAP-TLS certificate bundle password (default: $TAILSWAN_EXPORT_PASSWORD)")
+	profileCmd.Flags().StringVarP(&output, "output", "o", "", "file to write (default: <name>.<format>)")
+	cmd.AddCommand(profileCmd)
+
+	return cmd
+}
+
+func withCharon(fn func(session *vici.Session) error) error {
+	session, err := vici.NewSession()
+	if err != nil {
+		return fmt.Errorf("charon is not reachable: %w", err)
+	}
+	defer session.Close() //nolint:errcheck
+
+	return fn(session)
+}
+
+func issueUserCert(ctx context.Context, cfg *config.Config, name string) (*pki.Cert, error) {
+	ca, err := openCA(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	issued, err := ca.Issue(pki.Request{Name: name, Profile: pki.ProfileClient, SANs: []string{name}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue a certificate for %s: %w", name, err)
+	}
+	return issued, nil
+}
+
+func revokeUserCert(ctx context.Context, cfg *config.Config, name string) error {
+	dir := pki.Dir(cfg.StateDir)
+	if !pki.Exists(dir) {
+		return nil
+	}
+	ca, err := pki.Open(dir, "")
+	if err != nil {
+		return fmt.Errorf("failed to open the CA: %w", err)
+	}
+	if _, err := ca.Revoke(name); err != nil {
+		if errors.Is(err, pki.ErrRevoked) || errors.Is(err, pki.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to revoke its certificate: %w", err)
+	}
+	return installCA(ctx, cfg, ca)
+}
+
+func writeLeases(out *strings.Builder, pools []viciconn.Pool) {
+	if len(pools) == 0 {
+		out.WriteString("No pools loaded\n")
+		return
+	}
+	for _, p := range pools {
+		fmt.Fprintf(out, "%s %s: %d/%d online, %d offline\n", p.Name, p.Base, p.Online, p.Size, p.Offline)
+		for _, l := range p.Leases {
+			fmt.Fprintf(out, "  %-39s %-8s %s\n", l.Address, l.Status, l.Identity)
+		}
+	}
+}
@@ -25,6 +25,20 @@ func NewRootCmd(stdin io.Reader, stdout, stderr io.Writer) *cobra.Command {
 		NewStartCmd(),
 		NewStopCmd(),
 		NewReloadCmd(),
+		NewFirewallCmd(),
+		NewDiagCmd(),
+		NewDoctorCmd(),
+		NewSupportBundleCmd(),
+		NewScheduleCmd(),
+		NewCertsCmd(),
+		NewPKICmd(),
+		NewRoadWarriorCmd(),
+		NewIdentitiesCmd(),
+		NewExportPeerConfigCmd(),
+		NewImportCmd(),
+		NewTemplatesCmd(),
+		NewHistoryCmd(),
+		NewOperatorCmd(),
 	)
 
 	return rootCmd
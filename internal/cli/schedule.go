@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/schedule"
+)
+
+func NewScheduleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Show connection schedules and manage on-demand leases",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Show scheduled and leased connections and their next transition",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			windows, err := schedule.ParseWindows(cfg.Swan.Schedules)
+			if err != nil {
+				return fmt.Errorf("invalid SWAN_SCHEDULES: %w", err)
+			}
+			now := time.Now()
+			leases, err := schedule.NewLeaseStore(cfg.StateDir).Active(now)
+			if err != nil {
+				return fmt.Errorf("failed to read leases: %w", err)
+			}
+
+			var out strings.Builder
+			writeSchedule(&out, schedule.Plan(now, windows, leases))
+			if _, err := fmt.Fprint(cmd.OutOrStdout(), out.String()); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return nil
+		},
+	})
+
+	var duration time.Duration
+	leaseCmd := &cobra.Command{
+		Use:   "lease <connection>",
+		Short: "Bring a connection up for a while, regardless of its schedule",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			lease, err := schedule.NewLeaseStore(cfg.StateDir).Grant(time.Now(), args[0], "cli", duration)
+			if err != nil {
+				return fmt.Errorf("failed to lease connection %s: %w", args[0], err)
+			}
+			if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Connection '%s' leased until %s\n", lease.Connection, lease.Until.Local().Format(time.DateTime)); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return nil
+		},
+	}
+	leaseCmd.Flags().DurationVar(&duration, "for", time.Hour, "how long to keep the connection up")
+	cmd.AddCommand(leaseCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "cancel <connection>",
+		Short: "End a connection's lease early",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Load()
+			found, err := schedule.NewLeaseStore(cfg.StateDir).Cancel(time.Now(), args[0])
+			if err != nil {
+				return fmt.Errorf("failed to cancel lease of %s: %w", args[0], err)
+			}
+			if !found {
+				return fmt.Errorf("connection %s has no active lease", args[0])
+			}
+			if _, err := fmt.Fprintf(cmd.OutOrStdout(), "Lease of '%s' cancelled\n", args[0]); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+func writeSchedule(out *strings.Builder, plan []schedule.Status) {
+	if len(plan) == 0 {
+		out.WriteString("No scheduled or leased connections\n")
+		return
+	}
+	for _, st := range plan {
+		fmt.Fprintf(out, "%-20s %-5s (%s)", st.Connection, st.State, st.Reason)
+		if st.Lease != nil {
+			fmt.Fprintf(out, "  lease by %s until %s", st.Lease.Owner, st.Lease.Until.Local().Format(time.DateTime))
+		}
+		if st.Next != nil {
+			fmt.Fprintf(out, "  %s at %s", st.Next.State, st.Next.At.Local().Format(time.DateTime))
+		}
+		out.WriteString("\n")
+	}
+}
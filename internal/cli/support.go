@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/klowdo/tailswan/internal/bundle"
+	"github.com/klowdo/tailswan/internal/config"
+)
+
+func NewSupportBundleCmd() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "support-bundle",
+		Short: "Write a redacted tar.gz of configuration, state and logs for bug reports",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), 2*time.Minute)
+			defer cancel()
+
+			if output == "" {
+				output = bundle.FileName(time.Now())
+			}
+
+			var w io.Writer = cmd.OutOrStdout()
+			if output != "-" {
+				file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+				if err != nil {
+					return fmt.Errorf("failed to create %s: %w", output, err)
+				}
+				defer file.Close() //nolint:errcheck
+				w = file
+			}
+
+			if err := bundle.New(config.Load(), nil).Write(ctx, w); err != nil {
+				return fmt.Errorf("failed to write support bundle: %w", err)
+			}
+
+			if output != "-" {
+				if _, err := fmt.Fprintf(cmd.ErrOrStderr(), "Support bundle written to %s\nReview it before attaching it to an issue.\n", output); err != nil {
+					return fmt.Errorf("failed to write output: %w", err)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "output file, - for stdout (default: tailswan-support-<time>.tar.gz)")
+
+	return cmd
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/klowdo/tailswan/internal/templates"
+)
+
+func NewTemplatesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "templates",
+		Short: "Render connections from built-in templates",
+	}
+	cmd.AddCommand(newTemplatesListCmd(), newTemplatesShowCmd(), newTemplatesRenderCmd())
+	return cmd
+}
+
+func lookupTemplate(name string) (*templates.Template, error) {
+	tmpl := templates.Lookup(name)
+	if tmpl == nil {
+		return nil, fmt.Errorf("unknown template %q, see tailswan templates list", name)
+	}
+	return tmpl, nil
+}
+
+func newTemplatesListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the templates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var out strings.Builder
+			for _, t := range templates.All() {
+				fmt.Fprintf(&out, "%-20s %s\n", t.Name, t.Description)
+			}
+			return writeOutput(cmd, out.String())
+		},
+	}
+}
+
+func newTemplatesShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <template>",
+		Short: "Show a template's parameters",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tmpl, err := lookupTemplate(args[0])
+			if err != nil {
+				return err
+			}
+			var out strings.Builder
+			fmt.Fprintf(&out, "%s: %s\n\n", tmpl.Title, tmpl.Description)
+			for _, p := range tmpl.Params {
+				var attrs []string
+				if p.Required {
+					attrs = append(attrs, "required")
+				}
+				if p.Default != "" {
+					attrs = append(attrs, "default "+p.Default)
+				}
+				if len(p.Options) > 0 {
+					attrs = append(attrs, "one of "+strings.Join(p.Options, ", "))
+				}
+				fmt.Fprintf(&out, "  %-16s %-9s %s", p.Name, p.Type, p.Label)
+				if len(attrs) > 0 {
+					fmt.Fprintf(&out, " (%s)", strings.Join(attrs, "; "))
+				}
+				out.WriteString("\n")
+				if p.Help != "" {
+					fmt.Fprintf(&out, "  %-16s %-9s %s\n", "", "", p.Help)
+				}
+			}
+			return writeOutput(cmd, out.String())
+		},
+	}
+}
+
+func newTemplatesRenderCmd() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "render <template> [param=value...]",
+		Short: "Render a template to swanctl.conf",
+		Long: `Render a template with the given parameter values to a swanctl.conf
+snippet. Write it into conf.d and run tailswan reload to load it, or use
+the web UI's template wizard, which loads it directly.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tmpl, err := lookupTemplate(args[0])
+			if err != nil {
+				return err
+			}
+			values := templates.Values{}
+			for _, arg := range args[1:] {
+				k, v, ok := strings.Cut(arg, "=")
+				if !ok {
+					return fmt.Errorf("expected param=value, got %q", arg)
+				}
+				values[k] = v
+			}
+
+			rendered, err := tmpl.Render(values)
+			var verr *templates.ValidationError
+			if errors.As(err, &verr) {
+				var out strings.Builder
+				out.WriteString("Invalid parameters:\n")
+				for _, name := range slices.Sorted(maps.Keys(verr.Fields)) {
+					fmt.Fprintf(&out, "  %s: %s\n", name, verr.Fields[name])
+				}
+				if _, err := fmt.Fprint(cmd.ErrOrStderr(), out.String()); err != nil {
+					return fmt.Errorf("failed to write output: %w", err)
+				}
+				return fmt.Errorf("template %s not rendered", tmpl.Name)
+			}
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				return writeOutput(cmd, rendered.String())
+			}
+			if err := os.WriteFile(output, []byte(rendered.String()), 0o600); err != nil {
+				return fmt.Errorf("failed to write %s: %w", output, err)
+			}
+			return writeOutput(cmd, fmt.Sprintf("Wrote %s\n", output))
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write (default: stdout)")
+	return cmd
+}
@@ -3,14 +3,23 @@ package config
 import (
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 type Config struct {
 	Port      string
 	LogLevel  string
+	StateDir  string
 	Swan      SwanConfig
+	Firewall  FirewallConfig
 	Tailscale TailscaleConfig
+	HA        HAConfig
+	Fleet     FleetConfig
+	Watchdog  WatchdogConfig
+	RW        RoadWarriorConfig
+	BGP       BGPConfig
 }
 
 type TailscaleConfig struct {
@@ -18,6 +27,7 @@ type TailscaleConfig struct {
 	Socket      string
 	Hostname    string
 	AuthKey     string `json:"-"`
+	TunMode     string
 	Routes      []string
 	ExtraArgs   []string
 	SSH         bool
@@ -26,14 +36,107 @@ type TailscaleConfig struct {
 }
 
 type SwanConfig struct {
-	ConfigPath  string
-	Connections []string
-	AutoStart   bool
+	ConfigPath     string
+	Schedules      string
+	CertExpiry     string
+	Connections    []string
+	AutoStart      bool
+	XFRMInterfaces bool
+	// Watch applies edits of swanctl.conf and its conf.d snippets as they
+	// are saved.
+	Watch bool
+}
+
+// defaultCertExpiry is how long before expiry certificates are reported
+// when SWAN_CERT_EXPIRY_WARNING is not a valid duration.
+const defaultCertExpiry = 30 * 24 * time.Hour
+
+// CredentialsDir is the swanctl directory holding the x509, x509ca,
+// x509crl and private directories, or empty without a SWAN_CONFIG.
+func (s *SwanConfig) CredentialsDir() string {
+	if s.ConfigPath == "" {
+		return ""
+	}
+	return filepath.Dir(s.ConfigPath)
+}
+
+// CertExpiryWarning is how long before expiry a certificate is reported.
+func (s *SwanConfig) CertExpiryWarning() time.Duration {
+	d, err := time.ParseDuration(s.CertExpiry)
+	if err != nil || d <= 0 {
+		if s.CertExpiry != "" {
+			slog.Warn("Invalid SWAN_CERT_EXPIRY_WARNING, using the default", "value", s.CertExpiry, "default", defaultCertExpiry)
+		}
+		return defaultCertExpiry
+	}
+	return d
+}
+
+type FirewallConfig struct {
+	Backend       string
+	Masquerade    bool
+	MSSClamp      bool
+	DropUnmatched bool
+}
+
+type BGPConfig struct {
+	ASN             string
+	RouterID        string
+	Neighbors       []string
+	ImportFilter    []string
+	Enabled         bool
+	AnnounceTailnet bool
+}
+
+type HAConfig struct {
+	Mode      string
+	NodeID    string
+	Peer      string
+	LeaseFile string
+	Priority  string
+	LeaseTTL  string
+}
+
+type WatchdogConfig struct {
+	Probes    string
+	Interval  string
+	Timeout   string
+	Threshold string
+}
+
+// Enabled reports whether any connection has a probe.
+func (w *WatchdogConfig) Enabled() bool {
+	return strings.TrimSpace(w.Probes) != ""
+}
+
+type FleetConfig struct {
+	HostnamePrefix string
+	Port           string
+	Tags           []string
+}
+
+// RoadWarriorConfig describes the gateway to the client profiles of
+// road-warrior users.
+type RoadWarriorConfig struct {
+	// Server is the public address clients connect to.
+	Server string
+	// ServerID is the gateway's IKE identity, the SAN of its certificate.
+	ServerID    string
+	ProfileName string
+	// Access is RW_ACCESS, the tailnet destinations each IPsec client
+	// identity may reach; empty enforces nothing.
+	Access string
+}
+
+// Enabled reports whether HA_MODE selects an election backend.
+func (h *HAConfig) Enabled() bool {
+	return h.Mode != "" && h.Mode != "off"
 }
 
 func Load() *Config {
 	port := getEnv("CONTROL_PORT", "8080")
 	logLevel := getEnv("LOG_LEVEL", "info")
+	stateDir := getEnv("TAILSWAN_STATE_DIR", "/var/lib/tailswan")
 
 	tsStateDir := getEnv("TS_STATE_DIR", "/var/lib/tailscale")
 	tsSocket := getEnv("TS_SOCKET", "/var/run/tailscale/tailscaled.sock")
@@ -42,21 +145,61 @@ func Load() *Config {
 	tsRoutes := getEnv("TS_ROUTES", "")
 	tsSSH := getEnvBool("TS_SSH", false)
 	tsExtraArgs := getEnv("TS_EXTRA_ARGS", "")
+	tsTunMode := getEnv("TS_TUN_MODE", "userspace")
 	useTsnet := getEnvBool("USE_TSNET", false)
 	tsEnableServe := getEnvBool("SWAN_TS_SERVE", false)
 
 	swanConfig := getEnv("SWAN_CONFIG", "/etc/swanctl/swanctl.conf")
 	swanAutoStart := getEnvBool("SWAN_AUTO_START", false)
 	swanConnections := getEnv("SWAN_CONNECTIONS", "")
+	swanXFRMInterfaces := getEnvBool("SWAN_XFRM_INTERFACES", false)
+	swanWatch := getEnvBool("SWAN_WATCH", false)
+	swanSchedules := getEnv("SWAN_SCHEDULES", "")
+	swanCertExpiry := getEnv("SWAN_CERT_EXPIRY_WARNING", "720h")
+
+	fwBackend := getEnv("FIREWALL_BACKEND", "auto")
+	fwMasquerade := getEnvBool("FIREWALL_MASQUERADE", true)
+	fwMSSClamp := getEnvBool("FIREWALL_MSS_CLAMP", true)
+	fwDropUnmatched := getEnvBool("FIREWALL_DROP_UNMATCHED", false)
+
+	bgpEnabled := getEnvBool("BGP_ENABLED", false)
+	bgpASN := getEnv("BGP_ASN", "")
+	bgpRouterID := getEnv("BGP_ROUTER_ID", "")
+	bgpNeighbors := getEnv("BGP_NEIGHBORS", "")
+	bgpImportFilter := getEnv("BGP_IMPORT_FILTER", "")
+	bgpAnnounceTailnet := getEnvBool("BGP_ANNOUNCE_TAILNET", false)
+
+	haMode := getEnv("HA_MODE", "off")
+	haNodeID := getEnv("HA_NODE_ID", tsHostname)
+	haPeer := getEnv("HA_PEER", "")
+	haLeaseFile := getEnv("HA_LEASE_FILE", "")
+	haPriority := getEnv("HA_PRIORITY", "100")
+	haLeaseTTL := getEnv("HA_LEASE_TTL", "30s")
+
+	watchdogProbes := getEnv("WATCHDOG_PROBES", "")
+	watchdogInterval := getEnv("WATCHDOG_INTERVAL", "30s")
+	watchdogTimeout := getEnv("WATCHDOG_TIMEOUT", "5s")
+	watchdogThreshold := getEnv("WATCHDOG_THRESHOLD", "3")
+
+	fleetTags := getEnv("FLEET_TAGS", "")
+	fleetHostnamePrefix := getEnv("FLEET_HOSTNAME_PREFIX", "")
+	fleetPort := getEnv("FLEET_PORT", port)
+
+	rwServer := getEnv("RW_SERVER", "")
+	rwServerID := getEnv("RW_SERVER_ID", rwServer)
+	rwProfileName := getEnv("RW_PROFILE_NAME", "TailSwan VPN")
+	rwAccess := getEnv("RW_ACCESS", "")
 
 	cfg := &Config{
 		Port:     port,
 		LogLevel: logLevel,
+		StateDir: stateDir,
 		Tailscale: TailscaleConfig{
 			StateDir:    tsStateDir,
 			Socket:      tsSocket,
 			Hostname:    tsHostname,
 			AuthKey:     tsAuthKey,
+			TunMode:     strings.ToLower(tsTunMode),
 			Routes:      parseCommaSeparated(tsRoutes),
 			SSH:         tsSSH,
 			ExtraArgs:   strings.Fields(tsExtraArgs),
@@ -64,9 +207,52 @@ func Load() *Config {
 			EnableServe: tsEnableServe,
 		},
 		Swan: SwanConfig{
-			ConfigPath:  swanConfig,
-			AutoStart:   swanAutoStart,
-			Connections: parseCommaSeparated(swanConnections),
+			ConfigPath:     swanConfig,
+			AutoStart:      swanAutoStart,
+			Connections:    parseCommaSeparated(swanConnections),
+			XFRMInterfaces: swanXFRMInterfaces,
+			Watch:          swanWatch,
+			Schedules:      swanSchedules,
+			CertExpiry:     swanCertExpiry,
+		},
+		Firewall: FirewallConfig{
+			Backend:       fwBackend,
+			Masquerade:    fwMasquerade,
+			MSSClamp:      fwMSSClamp,
+			DropUnmatched: fwDropUnmatched,
+		},
+		BGP: BGPConfig{
+			Enabled:         bgpEnabled,
+			ASN:             bgpASN,
+			RouterID:        bgpRouterID,
+			Neighbors:       parseCommaSeparated(bgpNeighbors),
+			ImportFilter:    parseCommaSeparated(bgpImportFilter),
+			AnnounceTailnet: bgpAnnounceTailnet,
+		},
+		HA: HAConfig{
+			Mode:      strings.ToLower(haMode),
+			NodeID:    haNodeID,
+			Peer:      haPeer,
+			LeaseFile: haLeaseFile,
+			Priority:  haPriority,
+			LeaseTTL:  haLeaseTTL,
+		},
+		Fleet: FleetConfig{
+			Tags:           parseCommaSeparated(fleetTags),
+			HostnamePrefix: fleetHostnamePrefix,
+			Port:           fleetPort,
+		},
+		Watchdog: WatchdogConfig{
+			Probes:    watchdogProbes,
+			Interval:  watchdogInterval,
+			Timeout:   watchdogTimeout,
+			Threshold: watchdogThreshold,
+		},
+		RW: RoadWarriorConfig{
+			Server:      rwServer,
+			ServerID:    rwServerID,
+			ProfileName: rwProfileName,
+			Access:      rwAccess,
 		},
 	}
 
@@ -92,6 +278,12 @@ func (c *Config) Address() string {
 	return ":" + c.Port
 }
 
+// LogPath is the file the supervisor mirrors its own and its children's
+// output to, so recent logs survive for support bundles.
+func (c *Config) LogPath() string {
+	return filepath.Join(c.StateDir, "tailswan.log")
+}
+
 func (c *Config) GetLogLevel() slog.Level {
 	switch strings.ToLower(strings.TrimSpace(c.LogLevel)) {
 	case "debug":
@@ -3,6 +3,7 @@ package config
 import (
 	"log/slog"
 	"testing"
+	"time"
 )
 
 func TestParseCommaSeparated(t *testing.T) {
@@ -336,10 +337,16 @@ func TestConfigGetLogLevel(t *testing.T) {
 func TestLoad(t *testing.T) {
 	t.Run("default values", func(t *testing.T) {
 		envVars := []string{
-			"CONTROL_PORT", "LOG_LEVEL",
+			"CONTROL_PORT", "LOG_LEVEL", "TAILSWAN_STATE_DIR",
 			"TS_STATE_DIR", "TS_SOCKET", "TS_HOSTNAME", "TS_AUTHKEY",
-			"TS_ROUTES", "TS_SSH", "TS_EXTRA_ARGS", "USE_TSNET", "SWAN_TS_SERVE",
-			"SWAN_CONFIG", "SWAN_AUTO_START", "SWAN_CONNECTIONS",
+			"TS_ROUTES", "TS_SSH", "TS_EXTRA_ARGS", "TS_TUN_MODE", "USE_TSNET", "SWAN_TS_SERVE",
+			"SWAN_CONFIG", "SWAN_AUTO_START", "SWAN_CONNECTIONS", "SWAN_XFRM_INTERFACES", "SWAN_SCHEDULES",
+			"SWAN_CERT_EXPIRY_WARNING", "SWAN_WATCH",
+			"BGP_ENABLED", "BGP_ASN", "BGP_ROUTER_ID", "BGP_NEIGHBORS", "BGP_IMPORT_FILTER", "BGP_ANNOUNCE_TAILNET",
+			"HA_MODE", "HA_NODE_ID", "HA_PEER", "HA_LEASE_FILE", "HA_PRIORITY", "HA_LEASE_TTL",
+			"FLEET_TAGS", "FLEET_HOSTNAME_PREFIX", "FLEET_PORT",
+			"WATCHDOG_PROBES", "WATCHDOG_INTERVAL", "WATCHDOG_TIMEOUT", "WATCHDOG_THRESHOLD",
+			"RW_SERVER", "RW_SERVER_ID", "RW_PROFILE_NAME", "RW_ACCESS",
 		}
 		for _, v := range envVars {
 			t.Setenv(v, "")
@@ -353,6 +360,12 @@ func TestLoad(t *testing.T) {
 		if cfg.LogLevel != "info" {
 			t.Errorf("expected LogLevel %q, got %q", "info", cfg.LogLevel)
 		}
+		if cfg.StateDir != "/var/lib/tailswan" {
+			t.Errorf("expected StateDir %q, got %q", "/var/lib/tailswan", cfg.StateDir)
+		}
+		if cfg.LogPath() != "/var/lib/tailswan/tailswan.log" {
+			t.Errorf("expected LogPath %q, got %q", "/var/lib/tailswan/tailswan.log", cfg.LogPath())
+		}
 		if cfg.Tailscale.StateDir != "/var/lib/tailscale" {
 			t.Errorf("expected StateDir %q, got %q", "/var/lib/tailscale", cfg.Tailscale.StateDir)
 		}
@@ -374,6 +387,9 @@ func TestLoad(t *testing.T) {
 		if len(cfg.Tailscale.ExtraArgs) != 0 {
 			t.Errorf("expected empty ExtraArgs, got %v", cfg.Tailscale.ExtraArgs)
 		}
+		if cfg.Tailscale.TunMode != "userspace" {
+			t.Errorf("expected TunMode %q, got %q", "userspace", cfg.Tailscale.TunMode)
+		}
 		if cfg.Tailscale.UseTsnet != false {
 			t.Errorf("expected UseTsnet %v, got %v", false, cfg.Tailscale.UseTsnet)
 		}
@@ -389,6 +405,36 @@ func TestLoad(t *testing.T) {
 		if len(cfg.Swan.Connections) != 0 {
 			t.Errorf("expected empty Connections, got %v", cfg.Swan.Connections)
 		}
+		if cfg.Swan.XFRMInterfaces != false {
+			t.Errorf("expected XFRMInterfaces %v, got %v", false, cfg.Swan.XFRMInterfaces)
+		}
+		if cfg.Swan.Watch != false {
+			t.Errorf("expected Watch %v, got %v", false, cfg.Swan.Watch)
+		}
+		if cfg.Swan.Schedules != "" {
+			t.Errorf("expected no schedules, got %q", cfg.Swan.Schedules)
+		}
+		if cfg.Swan.CertExpiryWarning() != 30*24*time.Hour || cfg.Swan.CredentialsDir() != "/etc/swanctl" {
+			t.Errorf("unexpected certificate defaults %q %q", cfg.Swan.CertExpiry, cfg.Swan.CredentialsDir())
+		}
+		if cfg.BGP.Enabled || cfg.BGP.AnnounceTailnet || len(cfg.BGP.Neighbors) != 0 || len(cfg.BGP.ImportFilter) != 0 {
+			t.Errorf("expected BGP disabled by default, got %+v", cfg.BGP)
+		}
+		if cfg.HA.Enabled() || cfg.HA.Mode != "off" {
+			t.Errorf("expected HA off by default, got %+v", cfg.HA)
+		}
+		if cfg.HA.NodeID != "tailswan" || cfg.HA.Priority != "100" || cfg.HA.LeaseTTL != "30s" {
+			t.Errorf("unexpected HA defaults %+v", cfg.HA)
+		}
+		if len(cfg.Fleet.Tags) != 0 || cfg.Fleet.HostnamePrefix != "" || cfg.Fleet.Port != "8080" {
+			t.Errorf("unexpected fleet defaults %+v", cfg.Fleet)
+		}
+		if cfg.Watchdog.Enabled() || cfg.Watchdog.Interval != "30s" || cfg.Watchdog.Timeout != "5s" || cfg.Watchdog.Threshold != "3" {
+			t.Errorf("unexpected watchdog defaults %+v", cfg.Watchdog)
+		}
+		if cfg.RW.Server != "" || cfg.RW.ServerID != "" || cfg.RW.ProfileName != "TailSwan VPN" || cfg.RW.Access != "" {
+			t.Errorf("unexpected road-warrior defaults %+v", cfg.RW)
+		}
 	})
 
 	t.Run("custom values from environment", func(t *testing.T) {
@@ -401,11 +447,35 @@ func TestLoad(t *testing.T) {
 		t.Setenv("TS_ROUTES", "192.168.1.0/24,10.0.0.0/8")
 		t.Setenv("TS_SSH", "true")
 		t.Setenv("TS_EXTRA_ARGS", "--advertise-exit-node --accept-routes")
+		t.Setenv("TS_TUN_MODE", "Kernel")
 		t.Setenv("USE_TSNET", "1")
 		t.Setenv("SWAN_TS_SERVE", "yes")
 		t.Setenv("SWAN_CONFIG", "/custom/swanctl.conf")
 		t.Setenv("SWAN_AUTO_START", "true")
 		t.Setenv("SWAN_CONNECTIONS", "vpn1,vpn2,vpn3")
+		t.Setenv("SWAN_XFRM_INTERFACES", "true")
+		t.Setenv("SWAN_WATCH", "true")
+		t.Setenv("SWAN_SCHEDULES", "partner-a=0 8 * * mon-fri|0 18 * * mon-fri")
+		t.Setenv("SWAN_CERT_EXPIRY_WARNING", "336h")
+		t.Setenv("BGP_ENABLED", "true")
+		t.Setenv("BGP_ASN", "65000")
+		t.Setenv("BGP_ROUTER_ID", "10.1.0.1")
+		t.Setenv("BGP_NEIGHBORS", "65001@169.254.10.2, 65002@169.254.10.6")
+		t.Setenv("BGP_IMPORT_FILTER", "10.20.0.0/16:16..24")
+		t.Setenv("BGP_ANNOUNCE_TAILNET", "yes")
+		t.Setenv("HA_MODE", "Tailnet")
+		t.Setenv("HA_PEER", "tailswan-b:8080")
+		t.Setenv("HA_PRIORITY", "200")
+		t.Setenv("HA_LEASE_TTL", "15s")
+		t.Setenv("FLEET_TAGS", "tag:tailswan, tag:gateway")
+		t.Setenv("FLEET_HOSTNAME_PREFIX", "tailswan-")
+		t.Setenv("WATCHDOG_PROBES", "partner-a=icmp:10.2.0.10")
+		t.Setenv("WATCHDOG_INTERVAL", "10s")
+		t.Setenv("WATCHDOG_THRESHOLD", "5")
+		t.Setenv("RW_SERVER", "vpn.example.com")
+		t.Setenv("RW_SERVER_ID", "")
+		t.Setenv("RW_PROFILE_NAME", "")
+		t.Setenv("RW_ACCESS", "*@example.com=100.64.1.10")
 
 		cfg := Load()
 
@@ -448,6 +518,9 @@ func TestLoad(t *testing.T) {
 				t.Errorf("expected ExtraArgs[%d] %q, got %q", i, expectedExtraArgs[i], a)
 			}
 		}
+		if cfg.Tailscale.TunMode != "kernel" {
+			t.Errorf("expected TunMode %q, got %q", "kernel", cfg.Tailscale.TunMode)
+		}
 		if cfg.Tailscale.UseTsnet != true {
 			t.Errorf("expected UseTsnet %v, got %v", true, cfg.Tailscale.UseTsnet)
 		}
@@ -461,6 +534,48 @@ func TestLoad(t *testing.T) {
 			t.Errorf("expected AutoStart %v, got %v", true, cfg.Swan.AutoStart)
 		}
 		expectedConnections := []string{"vpn1", "vpn2", "vpn3"}
+		if cfg.Swan.XFRMInterfaces != true {
+			t.Errorf("expected XFRMInterfaces %v, got %v", true, cfg.Swan.XFRMInterfaces)
+		}
+		if cfg.Swan.Watch != true {
+			t.Errorf("expected Watch %v, got %v", true, cfg.Swan.Watch)
+		}
+		if cfg.Swan.Schedules != "partner-a=0 8 * * mon-fri|0 18 * * mon-fri" {
+			t.Errorf("unexpected schedules %q", cfg.Swan.Schedules)
+		}
+		if cfg.Swan.CertExpiryWarning() != 14*24*time.Hour || cfg.Swan.CredentialsDir() != "/custom" {
+			t.Errorf("unexpected certificate settings %q %q", cfg.Swan.CertExpiry, cfg.Swan.CredentialsDir())
+		}
+		if !cfg.BGP.Enabled || !cfg.BGP.AnnounceTailnet || cfg.BGP.ASN != "65000" || cfg.BGP.RouterID != "10.1.0.1" {
+			t.Errorf("unexpected BGP config %+v", cfg.BGP)
+		}
+		if len(cfg.BGP.Neighbors) != 2 || cfg.BGP.Neighbors[1] != "65002@169.254.10.6" {
+			t.Errorf("unexpected BGP neighbors %v", cfg.BGP.Neighbors)
+		}
+		if len(cfg.BGP.ImportFilter) != 1 || cfg.BGP.ImportFilter[0] != "10.20.0.0/16:16..24" {
+			t.Errorf("unexpected BGP import filter %v", cfg.BGP.ImportFilter)
+		}
+		if !cfg.HA.Enabled() || cfg.HA.Mode != "tailnet" || cfg.HA.Peer != "tailswan-b:8080" {
+			t.Errorf("unexpected HA config %+v", cfg.HA)
+		}
+		if cfg.HA.NodeID != "custom-host" || cfg.HA.Priority != "200" || cfg.HA.LeaseTTL != "15s" {
+			t.Errorf("unexpected HA config %+v", cfg.HA)
+		}
+		if len(cfg.Fleet.Tags) != 2 || cfg.Fleet.Tags[1] != "tag:gateway" || cfg.Fleet.HostnamePrefix != "tailswan-" {
+			t.Errorf("unexpected fleet config %+v", cfg.Fleet)
+		}
+		if cfg.Fleet.Port != "9090" {
+			t.Errorf("expected fleet port to default to CONTROL_PORT, got %q", cfg.Fleet.Port)
+		}
+		if !cfg.Watchdog.Enabled() || cfg.Watchdog.Probes != "partner-a=icmp:10.2.0.10" || cfg.Watchdog.Interval != "10s" || cfg.Watchdog.Threshold != "5" {
+			t.Errorf("unexpected watchdog config %+v", cfg.Watchdog)
+		}
+		if cfg.RW.Server != "vpn.example.com" || cfg.RW.ServerID != "vpn.example.com" {
+			t.Errorf("expected RW_SERVER_ID to default to RW_SERVER, got %+v", cfg.RW)
+		}
+		if cfg.RW.Access != "*@example.com=100.64.1.10" {
+			t.Errorf("unexpected RW_ACCESS %q", cfg.RW.Access)
+		}
 		if len(cfg.Swan.Connections) != len(expectedConnections) {
 			t.Errorf("expected Connections %v, got %v", expectedConnections, cfg.Swan.Connections)
 		}
@@ -538,3 +653,48 @@ func TestSwanConfig(t *testing.T) {
 		}
 	})
 }
+
+func TestLoadFirewall(t *testing.T) {
+	t.Run("default values", func(t *testing.T) {
+		for _, v := range []string{"FIREWALL_BACKEND", "FIREWALL_MASQUERADE", "FIREWALL_MSS_CLAMP", "FIREWALL_DROP_UNMATCHED"} {
+			t.Setenv(v, "")
+		}
+
+		cfg := Load()
+
+		if cfg.Firewall.Backend != "auto" {
+			t.Errorf("expected Backend %q, got %q", "auto", cfg.Firewall.Backend)
+		}
+		if !cfg.Firewall.Masquerade {
+			t.Error("expected Masquerade to default to true")
+		}
+		if !cfg.Firewall.MSSClamp {
+			t.Error("expected MSSClamp to default to true")
+		}
+		if cfg.Firewall.DropUnmatched {
+			t.Error("expected DropUnmatched to default to false")
+		}
+	})
+
+	t.Run("custom values", func(t *testing.T) {
+		t.Setenv("FIREWALL_BACKEND", "iptables")
+		t.Setenv("FIREWALL_MASQUERADE", "false")
+		t.Setenv("FIREWALL_MSS_CLAMP", "no")
+		t.Setenv("FIREWALL_DROP_UNMATCHED", "true")
+
+		cfg := Load()
+
+		if cfg.Firewall.Backend != "iptables" {
+			t.Errorf("expected Backend %q, got %q", "iptables", cfg.Firewall.Backend)
+		}
+		if cfg.Firewall.Masquerade {
+			t.Error("expected Masquerade to be false")
+		}
+		if cfg.Firewall.MSSClamp {
+			t.Error("expected MSSClamp to be false")
+		}
+		if !cfg.Firewall.DropUnmatched {
+			t.Error("expected DropUnmatched to be true")
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package confighistory
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+)
+
+const diffContext = 3
+
+// redact replaces secret values. With a key they are replaced with a
+// fingerprint, so a diff shows that a secret changed without showing it;
+// the key is random for each diff so fingerprints cannot be brute-forced.
+func redact(content string, key []byte) string {
+	return swanconf.RedactSecrets(content, func(value string) string {
+		if key == nil {
+			return "<redacted>"
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value))
+		return "<redacted " + hex.EncodeToString(mac.Sum(nil)[:4]) + ">"
+	})
+}
+
+// Redacted returns a copy of s with its secret values removed.
+func (s *Snapshot) Redacted() *Snapshot {
+	c := *s
+	c.Files = make(map[string]string, len(s.Files))
+	for name, content := range s.Files {
+		c.Files[name] = redact(content, nil)
+	}
+	return &c
+}
+
+// Diff returns a unified diff of the files and routes from one version to
+// another, with secrets redacted. A nil from diffs against nothing.
+func Diff(from, to *Snapshot) string {
+	if from == nil {
+		from = &Snapshot{}
+	}
+	names := slices.Collect(maps.Keys(to.Files))
+	for name := range from.Files {
+		if _, ok := to.Files[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	key := []byte(rand.Text())
+
+	var out strings.Builder
+	for _, name := range names {
+		before, hadBefore := from.Files[name]
+		after, hasAfter := to.Files[name]
+		fromName, toName := "a/"+name, "b/"+name
+		if !hadBefore {
+			fromName = "/dev/null"
+		}
+		if !hasAfter {
+			toName = "/dev/null"
+		}
+		writeDiff(&out, fromName, toName, lines(redact(before, key)), lines(redact(after, key)))
+	}
+	writeDiff(&out, "a/"+routesName, "b/"+routesName, from.Routes, to.Routes)
+	return out.String()
+}
+
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+type edit struct {
+	line string
+	op   byte
+}
+
+// edits returns the line edits from a to b along a longest common
+// subsequence.
+func edits(a, b []string) []edit {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var es []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			es = append(es, edit{a[i], ' '})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			es = append(es, edit{a[i], '-'})
+			i++
+		default:
+			es = append(es, edit{b[j], '+'})
+			j++
+		}
+	}
+	return es
+}
+
+// writeDiff writes the hunks turning a into b, if there are any.
+func writeDiff(out *strings.Builder, fromName, toName string, a, b []string) {
+	es := edits(a, b)
+	if !slices.ContainsFunc(es, func(e edit) bool { return e.op != ' ' }) {
+		return
+	}
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Line numbers in a and b before each edit.
+	ai, bi := make([]int, len(es)+1), make([]int, len(es)+1)
+	for k, e := range es {
+		ai[k+1], bi[k+1] = ai[k], bi[k]
+		if e.op != '+' {
+			ai[k+1]++
+		}
+		if e.op != '-' {
+			bi[k+1]++
+		}
+	}
+
+	for k := 0; k < len(es); {
+		if es[k].op == ' ' {
+			k++
+			continue
+		}
+		start := max(k-diffContext, 0)
+		end := k
+		// Extend the hunk while the next change is close enough for the
+		// contexts to touch.
+		for end < len(es) {
+			if es[end].op != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(es) && es[next].op == ' ' {
+				next++
+			}
+			if next == len(es) || next-end > 2*diffContext {
+				end = min(end+diffContext, len(es))
+				break
+			}
+			end = next
+		}
+
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(ai[start], ai[end]-ai[start]), hunkRange(bi[start], bi[end]-bi[start]))
+		for _, e := range es[start:end] {
+			out.WriteByte(e.op)
+			out.WriteString(e.line)
+			out.WriteByte('\n')
+		}
+		k = end
+	}
+}
+
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
@@ -0,0 +1,329 @@
+// Package confighistory keeps versioned snapshots of the configuration:
+// swanctl.conf, the conf.d snippets next to it and the advertised routes,
+// with who changed them and when, so a change can be diffed and rolled
+// back.
+package confighistory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/statefile"
+)
+
+const (
+	dirName     = "config-history"
+	maxVersions = 100
+	// routesName stands for the advertised routes in Changed.
+	routesName = "routes"
+)
+
+// Sources of a change.
+const (
+	SourceStartup  = "startup"
+	SourceReload   = "reload"
+	SourceAPI      = "api"
+	SourceRoutes   = "routes"
+	SourceRollback = "rollback"
+	SourceWatch    = "watch"
+)
+
+var ErrNotFound = errors.New("version not found")
+
+// Author is who made a change: a tailnet user from WhoIs, a local user
+// of the CLI, or a component of TailSwan itself, which has only a name.
+type Author struct {
+	Login string `json:"login,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+func (a Author) String() string {
+	switch {
+	case a.Login == "" && a.Name == "":
+		return "unknown"
+	case a.Login == "":
+		return a.Name
+	case a.Name != "":
+		return a.Name + " <" + a.Login + ">"
+	}
+	return a.Login
+}
+
+// Snapshot is one version of the configuration. Files maps paths relative
+// to the swanctl directory to their content. Changed lists the files, and
+// "routes", that differ from the version before.
+type Snapshot struct {
+	Time    time.Time         `json:"time"`
+	Files   map[string]string `json:"files,omitempty"`
+	Author  Author            `json:"author"`
+	Source  string            `json:"source"`
+	Message string            `json:"message,omitempty"`
+	Routes  []string          `json:"routes"`
+	Changed []string          `json:"changed,omitempty"`
+	Version int               `json:"version"`
+}
+
+// Capture reads the configuration files: the swanctl.conf at configPath
+// and the conf.d/*.conf snippets next to it.
+func Capture(configPath string) (map[string]string, error) {
+	files := map[string]string{}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	files[filepath.Base(configPath)] = string(data)
+
+	snippets, err := filepath.Glob(filepath.Join(filepath.Dir(configPath), "conf.d", "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range snippets {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Join("conf.d", filepath.Base(path))] = string(data)
+	}
+	return files, nil
+}
+
+// Restore writes the files of snap back next to configPath and removes
+// conf.d snippets it does not have.
+func Restore(configPath string, snap *Snapshot) error {
+	dir := filepath.Dir(configPath)
+	for name, content := range snap.Files {
+		if name != filepath.Base(configPath) && !isSnippet(name) {
+			return fmt.Errorf("version %d: unexpected file %s", snap.Version, name)
+		}
+		path := filepath.Join(dir, name)
+		mode := os.FileMode(0o600)
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := statefile.WriteAtomic(path, []byte(content)); err != nil {
+			return err
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+
+	snippets, err := filepath.Glob(filepath.Join(dir, "conf.d", "*.conf"))
+	if err != nil {
+		return err
+	}
+	for _, path := range snippets {
+		if _, ok := snap.Files[filepath.Join("conf.d", filepath.Base(path))]; !ok {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isSnippet(name string) bool {
+	return filepath.Dir(name) == "conf.d" && strings.HasSuffix(name, ".conf") && filepath.Base(name) == filepath.Clean(filepath.Base(name))
+}
+
+// Store keeps the snapshots as one file per version in the state
+// directory, so the supervisor, the control server and the CLI can all
+// record changes.
+type Store struct {
+	dir string
+}
+
+func NewStore(stateDir string) *Store {
+	return &Store{dir: filepath.Join(stateDir, dirName)}
+}
+
+// RecordConfig records the files at configPath and the advertised routes,
+// nil when unknown, as changed by author.
+func (s *Store) RecordConfig(configPath string, routes []string, author Author, source, message string) (*Snapshot, error) {
+	files, err := Capture(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return s.Record(Snapshot{
+		Files:   files,
+		Routes:  routes,
+		Author:  author,
+		Source:  source,
+		Message: message,
+	})
+}
+
+func (s *Store) path(version int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%06d.json", version))
+}
+
+// Record stores snap as a new version unless its files and routes equal
+// the latest version's, in which case it returns nil. Routes left nil are
+// taken from the latest version, for callers that cannot ask Tailscale.
+func (s *Store) Record(snap Snapshot) (*Snapshot, error) {
+	var recorded *Snapshot
+	err := statefile.Locked(filepath.Join(s.dir, "versions"), func() error {
+		latest, err := s.Latest()
+		if err != nil {
+			return err
+		}
+		if snap.Routes == nil {
+			snap.Routes = []string{}
+			if latest != nil {
+				snap.Routes = latest.Routes
+			}
+		}
+		snap.Routes = slices.Sorted(slices.Values(snap.Routes))
+		snap.Changed = changed(latest, &snap)
+		if latest != nil && len(snap.Changed) == 0 {
+			return nil
+		}
+
+		snap.Version = 1
+		if latest != nil {
+			snap.Version = latest.Version + 1
+		}
+		if snap.Time.IsZero() {
+			snap.Time = time.Now().UTC()
+		}
+		data, err := json.MarshalIndent(&snap, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := statefile.WriteAtomic(s.path(snap.Version), data); err != nil {
+			return err
+		}
+		recorded = &snap
+		return s.prune()
+	})
+	return recorded, err
+}
+
+// changed lists what differs between two versions; everything does when
+// there is no version before.
+func changed(before, after *Snapshot) []string {
+	if before == nil {
+		before = &Snapshot{}
+	}
+	var names []string
+	for _, name := range slices.Sorted(maps.Keys(after.Files)) {
+		if content, ok := before.Files[name]; !ok || content != after.Files[name] {
+			names = append(names, name)
+		}
+	}
+	for name := range before.Files {
+		if _, ok := after.Files[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if !slices.Equal(before.Routes, after.Routes) && (len(before.Routes) > 0 || len(after.Routes) > 0) {
+		names = append(names, routesName)
+	}
+	return names
+}
+
+func (s *Store) prune() error {
+	versions, err := s.versions()
+	if err != nil {
+		return err
+	}
+	for len(versions) > maxVersions {
+		if err := os.Remove(s.path(versions[0])); err != nil {
+			return err
+		}
+		versions = versions[1:]
+	}
+	return nil
+}
+
+// versions returns the stored versions, oldest first.
+func (s *Store) versions() ([]int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []int
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.Atoi(name); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+func (s *Store) Get(version int) (*Snapshot, error) {
+	data, err := os.ReadFile(s.path(version))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %d", ErrNotFound, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("version %d: %w", version, err)
+	}
+	if snap.Files == nil {
+		snap.Files = map[string]string{}
+	}
+	return &snap, nil
+}
+
+// Latest returns the newest version, or nil when there is none.
+func (s *Store) Latest() (*Snapshot, error) {
+	versions, err := s.versions()
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return s.Get(versions[len(versions)-1])
+}
+
+// Previous returns the newest version before version, or nil when there
+// is none.
+func (s *Store) Previous(version int) (*Snapshot, error) {
+	versions, err := s.versions()
+	if err != nil {
+		return nil, err
+	}
+	i, _ := slices.BinarySearch(versions, version)
+	if i == 0 {
+		return nil, nil
+	}
+	return s.Get(versions[i-1])
+}
+
+// List returns the versions newest first, without their files.
+func (s *Store) List() ([]Snapshot, error) {
+	versions, err := s.versions()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Snapshot, 0, len(versions))
+	for _, v := range slices.Backward(versions) {
+		snap, err := s.Get(v)
+		if err != nil {
+			return nil, err
+		}
+		snap.Files = nil
+		list = append(list, *snap)
+	}
+	return list, nil
+}
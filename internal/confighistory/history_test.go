@@ -0,0 +1,194 @@
+package confighistory
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStore_Record(t *testing.T) {
+	s := NewStore(t.TempDir())
+
+	if latest, err := s.Latest(); err != nil || latest != nil {
+		t.Fatalf("expected an empty history, got %v, %v", latest, err)
+	}
+
+	first, err := s.Record(Snapshot{
+		Files:  map[string]string{"swanctl.conf": "connections {}\n"},
+		Source: SourceStartup,
+		Routes: []string{"10.2.0.0/16", "10.1.0.0/16"},
+	})
+	if err != nil || first == nil {
+		t.Fatalf("Record() = %v, %v", first, err)
+	}
+	if first.Version != 1 || !reflect.DeepEqual(first.Routes, []string{"10.1.0.0/16", "10.2.0.0/16"}) {
+		t.Errorf("unexpected first version %+v", first)
+	}
+
+	// Nothing changed, and unknown routes are carried over.
+	snap, err := s.Record(Snapshot{Files: map[string]string{"swanctl.conf": "connections {}\n"}, Source: SourceReload})
+	if err != nil || snap != nil {
+		t.Fatalf("expected no new version, got %v, %v", snap, err)
+	}
+
+	second, err := s.Record(Snapshot{
+		Files: map[string]string{
+			"swanctl.conf":        "connections {}\n",
+			"conf.d/partner.conf": "connections { partner {} }\n",
+		},
+		Author: Author{Login: "alice@example.com"},
+		Source: SourceAPI,
+	})
+	if err != nil || second == nil {
+		t.Fatalf("Record() = %v, %v", second, err)
+	}
+	if second.Version != 2 || !reflect.DeepEqual(second.Changed, []string{"conf.d/partner.conf"}) {
+		t.Errorf("unexpected second version %+v", second)
+	}
+	if !reflect.DeepEqual(second.Routes, first.Routes) {
+		t.Errorf("expected the routes to be carried over, got %v", second.Routes)
+	}
+
+	third, err := s.Record(Snapshot{Files: map[string]string{"swanctl.conf": "connections {}\n"}, Routes: []string{}, Source: SourceRoutes})
+	if err != nil || third == nil {
+		t.Fatalf("Record() = %v, %v", third, err)
+	}
+	if !reflect.DeepEqual(third.Changed, []string{"conf.d/partner.conf", "routes"}) {
+		t.Errorf("changed = %v", third.Changed)
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[0].Version != 3 || list[0].Files != nil {
+		t.Errorf("expected the versions newest first without files, got %+v", list)
+	}
+	prev, err := s.Previous(3)
+	if err != nil || prev == nil || prev.Version != 2 || prev.Author.String() != "alice@example.com" {
+		t.Errorf("Previous(3) = %+v, %v", prev, err)
+	}
+	if _, err := s.Get(7); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	s := NewStore(t.TempDir())
+	for i := range maxVersions + 5 {
+		if _, err := s.Record(Snapshot{Files: map[string]string{"swanctl.conf": strings.Repeat("#\n", i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions, err := s.versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != maxVersions || versions[0] != 6 {
+		t.Errorf("expected the oldest versions to be pruned, got %d versions from %d", len(versions), versions[0])
+	}
+}
+
+func TestCaptureRestore(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "swanctl.conf")
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("swanctl.conf", "include conf.d/*.conf\n")
+	write("conf.d/a.conf", "a\n")
+
+	files, err := Capture(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"swanctl.conf": "include conf.d/*.conf\n", "conf.d/a.conf": "a\n"}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("Capture() = %v", files)
+	}
+
+	write("swanctl.conf", "changed\n")
+	write("conf.d/b.conf", "b\n")
+	if err := Restore(configPath, &Snapshot{Files: files}); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := Capture(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, want) {
+		t.Errorf("expected the snapshot back, got %v", restored)
+	}
+	if info, err := os.Stat(configPath); err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("expected the file mode to be kept, got %v, %v", info.Mode(), err)
+	}
+
+	err = Restore(configPath, &Snapshot{Files: map[string]string{"../escape.conf": "x"}})
+	if err == nil {
+		t.Error("expected a file outside the config directory to be refused")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	from := &Snapshot{
+		Files: map[string]string{
+			"swanctl.conf":    "connections {\n  a {\n    version = 2\n  }\n}\nsecrets {\n  ike-a {\n    secret = old-secret\n  }\n}\n",
+			"conf.d/old.conf": "old\n",
+		},
+		Routes: []string{"10.1.0.0/16"},
+	}
+	to := &Snapshot{
+		Files: map[string]string{
+			"swanctl.conf":    "connections {\n  a {\n    version = 1\n  }\n}\nsecrets {\n  ike-a {\n    secret = new-secret\n  }\n}\n",
+			"conf.d/new.conf": "new\n",
+		},
+		Routes: []string{"10.1.0.0/16", "10.2.0.0/16"},
+	}
+	diff := Diff(from, to)
+
+	for _, want := range []string{
+		"--- a/conf.d/old.conf\n+++ /dev/null\n@@ -1 +0,0 @@\n-old\n",
+		"--- /dev/null\n+++ b/conf.d/new.conf\n@@ -0,0 +1 @@\n+new\n",
+		"-    version = 2\n+    version = 1\n",
+		"--- a/routes\n+++ b/routes\n@@ -1 +1,2 @@\n 10.1.0.0/16\n+10.2.0.0/16\n",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("expected %q in diff:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "old-secret") || strings.Contains(diff, "new-secret") {
+		t.Errorf("expected secrets to be redacted:\n%s", diff)
+	}
+	if !strings.Contains(diff, "-    secret = <redacted") || !strings.Contains(diff, "+    secret = <redacted") {
+		t.Errorf("expected the changed secret to show:\n%s", diff)
+	}
+
+	if diff := Diff(to, to); diff != "" {
+		t.Errorf("expected no diff between equal versions, got:\n%s", diff)
+	}
+}
+
+func TestSnapshot_Redacted(t *testing.T) {
+	s := &Snapshot{Files: map[string]string{
+		"swanctl.conf": "secrets {\n  ike-a {\n    secret = correct horse battery # site a\n  }\n  eap-b { id = b secret = \"two words\" }\n}\n",
+	}}
+	got := s.Redacted().Files["swanctl.conf"]
+
+	want := "secrets {\n  ike-a {\n    secret = <redacted> # site a\n  }\n  eap-b { id = b secret = <redacted> }\n}\n"
+	if got != want {
+		t.Errorf("Redacted() = %q, want %q", got, want)
+	}
+	if !strings.Contains(s.Files["swanctl.conf"], "correct horse battery") {
+		t.Error("Redacted modified its input")
+	}
+}
@@ -0,0 +1,182 @@
+package confighistory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/statefile"
+)
+
+const pendingName = "pending.json"
+
+// SourceRevert is the source of a version that undid a change that was not
+// confirmed in time.
+const SourceRevert = "revert"
+
+// Bounds of the time to confirm a change in.
+const (
+	MinConfirmTimeout = 10 * time.Second
+	MaxConfirmTimeout = time.Hour
+)
+
+var (
+	ErrPending        = errors.New("another change is waiting to be confirmed")
+	ErrNoPending      = errors.New("no change is waiting to be confirmed")
+	ErrInvalidTimeout = errors.New("invalid confirm timeout")
+)
+
+// Pending is a change applied on trial: unless it is confirmed before
+// Deadline, the configuration before it, Baseline, is restored.
+type Pending struct {
+	Deadline time.Time `json:"deadline"`
+	Started  time.Time `json:"started"`
+	Author   Author    `json:"author"`
+	Message  string    `json:"message"`
+	Baseline Snapshot  `json:"baseline"`
+}
+
+// Summary returns p without the files from before the change.
+func (p *Pending) Summary() *Pending {
+	c := *p
+	c.Baseline.Files = nil
+	return &c
+}
+
+func (s *Store) pendingPath() string {
+	return filepath.Join(s.dir, pendingName)
+}
+
+// Begin puts a change on trial for timeout. It is called before the change
+// is made, with the files at configPath and the advertised routes, nil
+// when unknown, as they are before it; a failed change is taken back with
+// Cancel.
+func (s *Store) Begin(configPath string, routes []string, timeout time.Duration, author Author, message string) (*Pending, error) {
+	if timeout < MinConfirmTimeout || timeout > MaxConfirmTimeout {
+		return nil, fmt.Errorf("%w: %s is not between %s and %s", ErrInvalidTimeout, timeout, MinConfirmTimeout, MaxConfirmTimeout)
+	}
+	var p *Pending
+	err := statefile.Locked(s.pendingPath(), func() error {
+		existing, err := s.readPending()
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrPending
+		}
+		files, err := Capture(configPath)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		p = &Pending{
+			Started:  now,
+			Deadline: now.Add(timeout),
+			Baseline: Snapshot{Files: files, Routes: routes},
+			Author:   author,
+			Message:  message,
+		}
+		data, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return err
+		}
+		return statefile.WriteAtomic(s.pendingPath(), data)
+	})
+	return p, err
+}
+
+// Pending returns the change on trial, or nil when there is none.
+func (s *Store) Pending() (*Pending, error) {
+	return s.readPending()
+}
+
+func (s *Store) readPending() (*Pending, error) {
+	data, err := os.ReadFile(s.pendingPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p Pending
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", pendingName, err)
+	}
+	return &p, nil
+}
+
+// Confirm keeps the change on trial.
+func (s *Store) Confirm() (*Pending, error) {
+	var p *Pending
+	err := statefile.Locked(s.pendingPath(), func() error {
+		var err error
+		if p, err = s.readPending(); err != nil {
+			return err
+		}
+		if p == nil {
+			return ErrNoPending
+		}
+		return os.Remove(s.pendingPath())
+	})
+	return p, err
+}
+
+// Cancel forgets the change on trial without restoring anything, for a
+// change that failed and was undone by its caller.
+func (s *Store) Cancel() error {
+	return statefile.Locked(s.pendingPath(), func() error {
+		err := os.Remove(s.pendingPath())
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	})
+}
+
+// Revert undoes the change on trial: it restores and loads the files from
+// before it, advertises the routes from before it and records the result.
+func (s *Store) Revert(ctx context.Context, t Target, author Author) (*Pending, *Snapshot, error) {
+	return s.revert(ctx, t, author, func(p *Pending) (bool, error) {
+		if p == nil {
+			return false, ErrNoPending
+		}
+		return true, nil
+	})
+}
+
+// RevertExpired reverts the change on trial if its deadline passed before
+// now. It returns nil when there is nothing to revert.
+func (s *Store) RevertExpired(ctx context.Context, t Target, author Author, now time.Time) (*Pending, *Snapshot, error) {
+	return s.revert(ctx, t, author, func(p *Pending) (bool, error) {
+		return p != nil && !now.Before(p.Deadline), nil
+	})
+}
+
+func (s *Store) revert(ctx context.Context, t Target, author Author, due func(p *Pending) (bool, error)) (*Pending, *Snapshot, error) {
+	var (
+		p    *Pending
+		snap *Snapshot
+	)
+	err := statefile.Locked(s.pendingPath(), func() error {
+		pending, err := s.readPending()
+		if err != nil {
+			return err
+		}
+		if ok, err := due(pending); !ok || err != nil {
+			return err
+		}
+		p = pending
+		// The change is taken back even when the configuration from
+		// before it does not load; the error says what went wrong.
+		if err := os.Remove(s.pendingPath()); err != nil {
+			return err
+		}
+		snap, err = s.restore(ctx, t, &p.Baseline, author, SourceRevert, "Reverted: "+p.Message)
+		return err
+	})
+	return p, snap, err
+}
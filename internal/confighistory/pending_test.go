@@ -0,0 +1,78 @@
+package confighistory
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPending(t *testing.T) {
+	s := NewStore(t.TempDir())
+	configPath := filepath.Join(t.TempDir(), "swanctl.conf")
+	if err := os.WriteFile(configPath, []byte("before\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var routes []netip.Prefix
+	applied := 0
+	target := Target{
+		ConfigPath: configPath,
+		Apply: func(context.Context) error {
+			applied++
+			return nil
+		},
+		SetRoutes: func(_ context.Context, r []netip.Prefix) error {
+			routes = r
+			return nil
+		},
+	}
+
+	p, err := s.Begin(configPath, []string{"10.1.0.0/16"}, time.Minute, Author{Login: "alice@example.com"}, "Loaded partner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Begin(configPath, nil, time.Minute, Author{}, "second"); !errors.Is(err, ErrPending) {
+		t.Errorf("expected ErrPending for a second change, got %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte("after\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, _, err := s.RevertExpired(context.Background(), target, Author{Name: "tailswan"}, p.Deadline.Add(-time.Second))
+	if err != nil || reverted != nil {
+		t.Fatalf("expected nothing to be reverted before the deadline, got %v, %v", reverted, err)
+	}
+	reverted, snap, err := s.RevertExpired(context.Background(), target, Author{Name: "tailswan"}, p.Deadline)
+	if err != nil || reverted == nil || snap == nil {
+		t.Fatalf("RevertExpired() = %v, %v, %v", reverted, snap, err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "before\n" || applied != 1 || len(routes) != 1 || routes[0].String() != "10.1.0.0/16" {
+		t.Errorf("expected the configuration from before to be restored, got %q, %d loads, routes %v", data, applied, routes)
+	}
+	if snap.Source != SourceRevert || snap.Message != "Reverted: Loaded partner" {
+		t.Errorf("unexpected version %+v", snap)
+	}
+	if p, err := s.Pending(); err != nil || p != nil {
+		t.Errorf("expected no pending change, got %v, %v", p, err)
+	}
+
+	if _, err := s.Confirm(); !errors.Is(err, ErrNoPending) {
+		t.Errorf("expected ErrNoPending, got %v", err)
+	}
+	if _, err := s.Begin(configPath, nil, time.Minute, Author{}, "third"); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := s.Confirm(); err != nil || p.Message != "third" {
+		t.Errorf("Confirm() = %v, %v", p, err)
+	}
+	if _, _, err := s.Revert(context.Background(), target, Author{}); !errors.Is(err, ErrNoPending) {
+		t.Errorf("expected nothing to revert after confirming, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package confighistory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// Target is the running configuration a version is rolled back onto.
+type Target struct {
+	// Apply loads the files at ConfigPath into charon.
+	Apply func(ctx context.Context) error
+	// SetRoutes replaces the routes advertised to the tailnet.
+	SetRoutes  func(ctx context.Context, routes []netip.Prefix) error
+	ConfigPath string
+}
+
+// Rollback restores the files of version, loads them, advertises its
+// routes and records the result as a new version. When charon refuses the
+// restored files the current ones are put back.
+func (s *Store) Rollback(ctx context.Context, t Target, version int, author Author) (*Snapshot, error) {
+	snap, err := s.Get(version)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := s.restore(ctx, t, snap, author, SourceRollback, fmt.Sprintf("Rolled back to version %d", version))
+	if err != nil {
+		return nil, fmt.Errorf("version %d: %w", version, err)
+	}
+	return recorded, nil
+}
+
+// restore makes snap the running configuration and records it. Routes
+// are left alone when snap does not know them.
+func (s *Store) restore(ctx context.Context, t Target, snap *Snapshot, author Author, source, message string) (*Snapshot, error) {
+	routes, err := parseRoutes(snap.Routes)
+	if err != nil {
+		return nil, err
+	}
+	current, err := Capture(t.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Restore(t.ConfigPath, snap); err != nil {
+		return nil, err
+	}
+	if err := t.Apply(ctx); err != nil {
+		if restoreErr := Restore(t.ConfigPath, &Snapshot{Files: current}); restoreErr != nil {
+			return nil, errors.Join(err, restoreErr)
+		}
+		err = fmt.Errorf("failed to load the configuration: %w", err)
+		if applyErr := t.Apply(ctx); applyErr != nil {
+			return nil, errors.Join(err, fmt.Errorf("failed to reload the current configuration: %w", applyErr))
+		}
+		return nil, err
+	}
+	if snap.Routes != nil {
+		if err := t.SetRoutes(ctx, routes); err != nil {
+			return nil, fmt.Errorf("failed to advertise the routes: %w", err)
+		}
+	}
+
+	recorded, err := s.RecordConfig(t.ConfigPath, snap.Routes, author, source, message)
+	if err != nil || recorded != nil {
+		return recorded, err
+	}
+	// Nothing changed: snap is the latest version already.
+	return s.Latest()
+}
+
+func parseRoutes(routes []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(routes))
+	for _, r := range routes {
+		p, err := netip.ParsePrefix(r)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes, nil
+}
+
+// Routes converts advertised routes to the form snapshots keep them in.
+func Routes(prefixes []netip.Prefix) []string {
+	routes := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		routes = append(routes, p.String())
+	}
+	return routes
+}
@@ -0,0 +1,90 @@
+package diag
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"tailscale.com/ipn/ipnstate"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+// Path is a probe target behind a CHILD_SA together with the local address
+// that makes the probe match the child's policy.
+type Path struct {
+	SA          *viciconn.ChildSA
+	Target      netip.Addr
+	Source      netip.Addr
+	SourceFound bool
+}
+
+func ResolveChild(sas []viciconn.ChildSA, name, target string) (*Path, error) {
+	sa, ok := FindChildSA(sas, name)
+	if !ok {
+		return nil, fmt.Errorf("no CHILD_SA found for %s; is the connection up?", name)
+	}
+
+	path := &Path{SA: sa}
+	if target == "" {
+		addr, ok := DefaultTarget(sa.RemoteTS)
+		if !ok {
+			return nil, fmt.Errorf("%s has no remote subnet to probe, specify a target", name)
+		}
+		path.Target = addr
+	} else {
+		addr, err := netip.ParseAddr(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target %q: %w", target, err)
+		}
+		if !coveredBy(addr, sa.RemoteTS) {
+			return nil, fmt.Errorf("target %s is not covered by the remote traffic selectors of %s (%s)",
+				addr, sa.Name, strings.Join(sa.RemoteTS, ", "))
+		}
+		path.Target = addr
+	}
+
+	path.Source, path.SourceFound = SourceFor(sa.LocalTS)
+	return path, nil
+}
+
+func coveredBy(addr netip.Addr, selectors []string) bool {
+	prefixes := viciconn.Prefixes(selectors)
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolvePeer finds a tailnet peer by Tailscale IP, hostname or MagicDNS
+// name and returns its first Tailscale IP.
+func ResolvePeer(status *ipnstate.Status, name string) (netip.Addr, string, error) {
+	if addr, err := netip.ParseAddr(name); err == nil {
+		for _, peer := range status.Peer {
+			for _, ip := range peer.TailscaleIPs {
+				if ip == addr {
+					return addr, peer.HostName, nil
+				}
+			}
+		}
+		return addr, "", nil
+	}
+
+	want := strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, peer := range status.Peer {
+		dnsName := strings.TrimSuffix(strings.ToLower(peer.DNSName), ".")
+		shortName, _, _ := strings.Cut(dnsName, ".")
+		if strings.EqualFold(peer.HostName, want) || dnsName == want || shortName == want {
+			if len(peer.TailscaleIPs) == 0 {
+				return netip.Addr{}, "", fmt.Errorf("peer %s has no Tailscale IPs", name)
+			}
+			return peer.TailscaleIPs[0], peer.HostName, nil
+		}
+	}
+	return netip.Addr{}, "", fmt.Errorf("peer %s not found in tailnet", name)
+}
@@ -0,0 +1,125 @@
+package diag
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress receives human-readable output as a diagnostic runs.
+type Progress func(line string)
+
+type PingResult struct {
+	Target      string  `json:"target"`
+	Source      string  `json:"source,omitempty"`
+	Transmitted int     `json:"transmitted"`
+	Received    int     `json:"received"`
+	MinRTTMs    float64 `json:"min_rtt_ms"`
+	AvgRTTMs    float64 `json:"avg_rtt_ms"`
+	MaxRTTMs    float64 `json:"max_rtt_ms"`
+}
+
+type TCPResult struct {
+	Target    string  `json:"target"`
+	Source    string  `json:"source,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+var (
+	pingSummaryRe = regexp.MustCompile(`(\d+) packets transmitted, (\d+) (?:packets )?received`)
+	pingRTTRe     = regexp.MustCompile(`= ([\d.]+)/([\d.]+)/([\d.]+)`)
+)
+
+func Ping(ctx context.Context, target, source netip.Addr, count int, progress Progress) (*PingResult, error) {
+	if count <= 0 {
+		count = 4
+	}
+
+	args := []string{"-c", strconv.Itoa(count), "-W", "2"}
+	if source.IsValid() {
+		args = append(args, "-I", source.String())
+	}
+	args = append(args, target.String())
+
+	cmd := exec.CommandContext(ctx, "ping", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("ping: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ping: %w", err)
+	}
+
+	var output strings.Builder
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		output.WriteString(line + "\n")
+		if progress != nil && line != "" {
+			progress(line)
+		}
+	}
+	waitErr := cmd.Wait()
+
+	result := parsePingOutput(output.String())
+	result.Target = target.String()
+	if source.IsValid() {
+		result.Source = source.String()
+	}
+	if result.Received == 0 {
+		if waitErr != nil {
+			return result, fmt.Errorf("no replies from %s: %w", target, waitErr)
+		}
+		return result, fmt.Errorf("no replies from %s", target)
+	}
+	return result, nil
+}
+
+func parsePingOutput(output string) *PingResult {
+	result := &PingResult{}
+	if m := pingSummaryRe.FindStringSubmatch(output); m != nil {
+		result.Transmitted = int(parseFloat(m[1]))
+		result.Received = int(parseFloat(m[2]))
+	}
+	if m := pingRTTRe.FindStringSubmatch(output); m != nil {
+		result.MinRTTMs = parseFloat(m[1])
+		result.AvgRTTMs = parseFloat(m[2])
+		result.MaxRTTMs = parseFloat(m[3])
+	}
+	return result
+}
+
+func parseFloat(s string) float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func TCPConnect(ctx context.Context, target netip.AddrPort, source netip.Addr, timeout time.Duration) (*TCPResult, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	result := &TCPResult{Target: target.String()}
+	if source.IsValid() {
+		dialer.LocalAddr = &net.TCPAddr{IP: source.AsSlice()}
+		result.Source = source.String()
+	}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", target.String())
+	if err != nil {
+		return result, fmt.Errorf("connect %s: %w", target, err)
+	}
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err := conn.Close(); err != nil {
+		return result, fmt.Errorf("close %s: %w", target, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,130 @@
+package diag
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const pingOutput = `PING 10.2.0.1 (10.2.0.1) from 10.1.0.1 : 56(84) bytes of data.
+64 bytes from 10.2.0.1: icmp_seq=1 ttl=63 time=12.1 ms
+64 bytes from 10.2.0.1: icmp_seq=3 ttl=63 time=11.8 ms
+
+--- 10.2.0.1 ping statistics ---
+3 packets transmitted, 2 received, 33.3333% packet loss, time 2003ms
+rtt min/avg/max/mdev = 11.812/11.956/12.100/0.144 ms
+`
+
+func TestParsePingOutput(t *testing.T) {
+	got := parsePingOutput(pingOutput)
+	if got.Transmitted != 3 || got.Received != 2 {
+		t.Errorf("expected 2/3 replies, got %d/%d", got.Received, got.Transmitted)
+	}
+	if got.MinRTTMs != 11.812 || got.AvgRTTMs != 11.956 || got.MaxRTTMs != 12.1 {
+		t.Errorf("unexpected rtt %+v", got)
+	}
+
+	busybox := parsePingOutput("4 packets transmitted, 0 packets received, 100% packet loss\n")
+	if busybox.Transmitted != 4 || busybox.Received != 0 {
+		t.Errorf("expected busybox summary 0/4, got %d/%d", busybox.Received, busybox.Transmitted)
+	}
+}
+
+func TestResolveChild(t *testing.T) {
+	sas := []viciconn.ChildSA{
+		{IKE: "mysite", Name: "net-net", State: "INSTALLED", RemoteTS: []string{"10.2.0.0/24"}},
+	}
+
+	path, err := ResolveChild(sas, "net-net", "")
+	if err != nil {
+		t.Fatalf("ResolveChild() error: %v", err)
+	}
+	if path.Target.String() != "10.2.0.1" {
+		t.Errorf("expected default target 10.2.0.1, got %s", path.Target)
+	}
+
+	if _, err := ResolveChild(sas, "net-net", "10.2.0.50"); err != nil {
+		t.Errorf("expected target inside remote TS to resolve: %v", err)
+	}
+	if _, err := ResolveChild(sas, "net-net", "192.168.1.1"); err == nil {
+		t.Error("expected error for target outside remote TS")
+	}
+	if _, err := ResolveChild(sas, "missing", ""); err == nil {
+		t.Error("expected error for unknown connection")
+	}
+}
+
+func TestResolvePeer(t *testing.T) {
+	status := &ipnstate.Status{
+		Peer: map[key.NodePublic]*ipnstate.PeerStatus{
+			key.NewNode().Public(): {
+				HostName:     "laptop",
+				DNSName:      "laptop.tail1234.ts.net.",
+				TailscaleIPs: []netip.Addr{netip.MustParseAddr("100.64.0.5")},
+			},
+		},
+	}
+
+	for _, name := range []string{"laptop", "laptop.tail1234.ts.net", "LAPTOP", "100.64.0.5"} {
+		ip, hostname, err := ResolvePeer(status, name)
+		if err != nil {
+			t.Fatalf("ResolvePeer(%q) error: %v", name, err)
+		}
+		if ip.String() != "100.64.0.5" || hostname != "laptop" {
+			t.Errorf("ResolvePeer(%q) = %s %q", name, ip, hostname)
+		}
+	}
+
+	if _, _, err := ResolvePeer(status, "desktop"); err == nil {
+		t.Error("expected error for unknown peer")
+	}
+}
+
+type fakeTailscalePinger struct {
+	results []*ipnstate.PingResult
+	calls   int
+}
+
+func (f *fakeTailscalePinger) Ping(_ context.Context, _ netip.Addr, _ tailcfg.PingType) (*ipnstate.PingResult, error) {
+	r := f.results[f.calls%len(f.results)]
+	f.calls++
+	return r, nil
+}
+
+func TestTailscalePingStopsOnDirectPath(t *testing.T) {
+	pinger := &fakeTailscalePinger{results: []*ipnstate.PingResult{
+		{NodeName: "laptop", DERPRegionCode: "fra", LatencySeconds: 0.030},
+		{NodeName: "laptop", Endpoint: "203.0.113.7:41641", LatencySeconds: 0.008},
+	}}
+
+	var lines []string
+	result, err := TailscalePing(context.Background(), pinger, netip.MustParseAddr("100.64.0.5"), tailcfg.PingDisco, 10,
+		func(line string) { lines = append(lines, line) })
+	if err != nil {
+		t.Fatalf("TailscalePing() error: %v", err)
+	}
+	if !result.Direct || result.Received != 2 || pinger.calls != 2 {
+		t.Errorf("expected to stop after direct pong, got %+v after %d calls", result, pinger.calls)
+	}
+	if len(lines) != 2 || lines[0] != "pong from laptop (100.64.0.5) via DERP(fra) in 30.0ms" {
+		t.Errorf("unexpected progress lines %q", lines)
+	}
+}
+
+func TestTailscalePingNoReplies(t *testing.T) {
+	pinger := &fakeTailscalePinger{results: []*ipnstate.PingResult{{Err: "timeout"}}}
+
+	result, err := TailscalePing(context.Background(), pinger, netip.MustParseAddr("100.64.0.5"), tailcfg.PingTSMP, 3, nil)
+	if err == nil {
+		t.Error("expected error without replies")
+	}
+	if len(result.Replies) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(result.Replies))
+	}
+}
@@ -0,0 +1,129 @@
+package diag
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os/exec"
+	"strconv"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const (
+	MinIPv4MTU = 576
+	MinIPv6MTU = 1280
+
+	icmpv4Headers = 28
+	icmpv6Headers = 48
+)
+
+// Pinger sends a single non-fragmentable echo request of size bytes,
+// including IP and ICMP headers.
+type Pinger func(ctx context.Context, target, source netip.Addr, size int) error
+
+func SystemPing(ctx context.Context, target, source netip.Addr, size int) error {
+	headers := icmpv4Headers
+	if target.Is6() {
+		headers = icmpv6Headers
+	}
+
+	args := []string{"-c", "1", "-W", "1", "-M", "do", "-s", strconv.Itoa(size - headers)}
+	if source.IsValid() {
+		args = append(args, "-I", source.String())
+	}
+	args = append(args, target.String())
+
+	output, err := exec.CommandContext(ctx, "ping", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ping %s (%d bytes): %w: %s", target, size, err, output)
+	}
+	return nil
+}
+
+// ProbePathMTU binary-searches the largest packet between low and high that
+// reaches target without fragmentation.
+func ProbePathMTU(ctx context.Context, target, source netip.Addr, low, high int, ping Pinger) (int, error) {
+	if err := ping(ctx, target, source, low); err != nil {
+		return 0, fmt.Errorf("target unreachable at minimum size %d: %w", low, err)
+	}
+
+	best := low
+	for low <= high {
+		if err := ctx.Err(); err != nil {
+			return best, err
+		}
+		mid := (low + high) / 2
+		if ping(ctx, target, source, mid) == nil {
+			best = mid
+			low = mid + 1
+		} else {
+			high = mid - 1
+		}
+	}
+	return best, nil
+}
+
+// FindChildSA returns the CHILD_SA matching name, which may be either the
+// child or the IKE connection name. Installed SAs are preferred.
+func FindChildSA(sas []viciconn.ChildSA, name string) (*viciconn.ChildSA, bool) {
+	var found *viciconn.ChildSA
+	for i := range sas {
+		sa := &sas[i]
+		if sa.Name != name && sa.IKE != name {
+			continue
+		}
+		if sa.State == "INSTALLED" {
+			return sa, true
+		}
+		if found == nil {
+			found = sa
+		}
+	}
+	return found, found != nil
+}
+
+// DefaultTarget picks the first host of the first remote traffic selector.
+func DefaultTarget(remoteTS []string) (netip.Addr, bool) {
+	prefixes := viciconn.Prefixes(remoteTS)
+	if len(prefixes) == 0 {
+		return netip.Addr{}, false
+	}
+	p := prefixes[0]
+	if p.IsSingleIP() {
+		return p.Addr(), true
+	}
+	return p.Addr().Next(), true
+}
+
+// SourceFor returns a local interface address inside the local traffic
+// selectors, so probes match the CHILD_SA policy instead of leaving through
+// the default route.
+func SourceFor(localTS []string) (netip.Addr, bool) {
+	ifAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	addrs := make([]netip.Addr, 0, len(ifAddrs))
+	for _, a := range ifAddrs {
+		if ipNet, ok := a.(*net.IPNet); ok {
+			if addr, ok := netip.AddrFromSlice(ipNet.IP); ok {
+				addrs = append(addrs, addr.Unmap())
+			}
+		}
+	}
+	return pickSource(addrs, viciconn.Prefixes(localTS))
+}
+
+func pickSource(addrs []netip.Addr, prefixes []netip.Prefix) (netip.Addr, bool) {
+	for _, p := range prefixes {
+		for _, addr := range addrs {
+			if p.Contains(addr) {
+				return addr, true
+			}
+		}
+	}
+	return netip.Addr{}, false
+}
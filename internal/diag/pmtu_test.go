@@ -0,0 +1,105 @@
+package diag
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+func pingerWithMTU(pathMTU int) Pinger {
+	return func(_ context.Context, _, _ netip.Addr, size int) error {
+		if size > pathMTU {
+			return errors.New("message too long")
+		}
+		return nil
+	}
+}
+
+func TestProbePathMTU(t *testing.T) {
+	target := netip.MustParseAddr("10.2.0.1")
+
+	for _, pathMTU := range []int{576, 1280, 1399, 1438} {
+		got, err := ProbePathMTU(context.Background(), target, netip.Addr{}, MinIPv4MTU, 1438, pingerWithMTU(pathMTU))
+		if err != nil {
+			t.Fatalf("ProbePathMTU() error: %v", err)
+		}
+		if got != pathMTU {
+			t.Errorf("expected path MTU %d, got %d", pathMTU, got)
+		}
+	}
+}
+
+func TestProbePathMTUUnreachable(t *testing.T) {
+	target := netip.MustParseAddr("10.2.0.1")
+
+	if _, err := ProbePathMTU(context.Background(), target, netip.Addr{}, MinIPv4MTU, 1438, pingerWithMTU(0)); err == nil {
+		t.Error("expected error for unreachable target")
+	}
+}
+
+func TestFindChildSA(t *testing.T) {
+	sas := []viciconn.ChildSA{
+		{IKE: "mysite", Name: "net-net", State: "REKEYED"},
+		{IKE: "mysite", Name: "net-net", State: "INSTALLED", EncrAlg: "AES_GCM_16"},
+		{IKE: "other", Name: "lan", State: "INSTALLED"},
+	}
+
+	sa, ok := FindChildSA(sas, "net-net")
+	if !ok || sa.State != "INSTALLED" {
+		t.Errorf("expected installed net-net SA, got %+v", sa)
+	}
+
+	sa, ok = FindChildSA(sas, "other")
+	if !ok || sa.Name != "lan" {
+		t.Errorf("expected lookup by IKE name, got %+v", sa)
+	}
+
+	if _, ok := FindChildSA(sas, "missing"); ok {
+		t.Error("expected no SA for unknown name")
+	}
+}
+
+func TestDefaultTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		ts     []string
+		wantOK bool
+	}{
+		{name: "subnet", ts: []string{"10.2.0.0/24"}, want: "10.2.0.1", wantOK: true},
+		{name: "host", ts: []string{"10.2.0.5/32"}, want: "10.2.0.5", wantOK: true},
+		{name: "dynamic only", ts: []string{"dynamic"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DefaultTarget(tt.ts)
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok %v, got %v", tt.wantOK, ok)
+			}
+			if ok && got.String() != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPickSource(t *testing.T) {
+	addrs := []netip.Addr{
+		netip.MustParseAddr("127.0.0.1"),
+		netip.MustParseAddr("172.17.0.2"),
+		netip.MustParseAddr("10.1.0.1"),
+	}
+
+	got, ok := pickSource(addrs, []netip.Prefix{netip.MustParsePrefix("10.1.0.0/24")})
+	if !ok || got.String() != "10.1.0.1" {
+		t.Errorf("expected 10.1.0.1, got %s", got)
+	}
+
+	if _, ok := pickSource(addrs, []netip.Prefix{netip.MustParsePrefix("192.168.0.0/16")}); ok {
+		t.Error("expected no source outside local traffic selectors")
+	}
+}
@@ -0,0 +1,127 @@
+package diag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+)
+
+// TailscalePinger is the subset of the LocalAPI client used by TailscalePing.
+type TailscalePinger interface {
+	Ping(ctx context.Context, ip netip.Addr, pingType tailcfg.PingType) (*ipnstate.PingResult, error)
+}
+
+type TailscalePingReply struct {
+	Via       string  `json:"via"`
+	NodeName  string  `json:"node_name,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+type TailscalePingResult struct {
+	Target   string               `json:"target"`
+	Type     string               `json:"type"`
+	Replies  []TailscalePingReply `json:"replies"`
+	Received int                  `json:"received"`
+	Direct   bool                 `json:"direct"`
+}
+
+func ParsePingType(s string) (tailcfg.PingType, error) {
+	switch strings.ToLower(s) {
+	case "", "disco":
+		return tailcfg.PingDisco, nil
+	case "tsmp":
+		return tailcfg.PingTSMP, nil
+	case "icmp":
+		return tailcfg.PingICMP, nil
+	case "peerapi":
+		return tailcfg.PingPeerAPI, nil
+	default:
+		return "", fmt.Errorf("unknown ping type %q (want disco, tsmp, icmp or peerapi)", s)
+	}
+}
+
+// TailscalePing pings a tailnet peer through tailscaled count times, stopping
+// early once a direct path has been confirmed.
+func TailscalePing(ctx context.Context, client TailscalePinger, ip netip.Addr, pingType tailcfg.PingType, count int, progress Progress) (*TailscalePingResult, error) {
+	if count <= 0 {
+		count = 10
+	}
+
+	result := &TailscalePingResult{
+		Target:  ip.String(),
+		Type:    string(pingType),
+		Replies: make([]TailscalePingReply, 0, count),
+	}
+
+	for i := 0; i < count; i++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		pr, err := client.Ping(pingCtx, ip, pingType)
+		cancel()
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		reply := pingReply(pr, err)
+		result.Replies = append(result.Replies, reply)
+		if progress != nil {
+			progress(formatPingReply(ip, &reply))
+		}
+		if reply.Error != "" {
+			continue
+		}
+
+		result.Received++
+		if reply.Via != "" && !strings.HasPrefix(reply.Via, "DERP") {
+			result.Direct = true
+			break
+		}
+	}
+
+	if result.Received == 0 {
+		return result, errors.New("no replies from " + ip.String())
+	}
+	return result, nil
+}
+
+func pingReply(pr *ipnstate.PingResult, err error) TailscalePingReply {
+	if err != nil {
+		return TailscalePingReply{Error: err.Error()}
+	}
+	if pr.Err != "" {
+		return TailscalePingReply{Error: pr.Err}
+	}
+
+	reply := TailscalePingReply{
+		NodeName:  pr.NodeName,
+		LatencyMs: pr.LatencySeconds * 1000,
+	}
+	switch {
+	case pr.Endpoint != "":
+		reply.Via = pr.Endpoint
+	case pr.PeerRelay != "":
+		reply.Via = "peer-relay " + pr.PeerRelay
+	case pr.DERPRegionCode != "":
+		reply.Via = "DERP(" + pr.DERPRegionCode + ")"
+	case pr.PeerAPIURL != "":
+		reply.Via = pr.PeerAPIURL
+	}
+	return reply
+}
+
+func formatPingReply(ip netip.Addr, reply *TailscalePingReply) string {
+	if reply.Error != "" {
+		return fmt.Sprintf("ping %s: %s", ip, reply.Error)
+	}
+	via := reply.Via
+	if via == "" {
+		via = "tailscale"
+	}
+	return fmt.Sprintf("pong from %s (%s) via %s in %.1fms", reply.NodeName, ip, via, reply.LatencyMs)
+}
@@ -0,0 +1,275 @@
+package diag
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+type XFRMPolicy struct {
+	Src      netip.Prefix `json:"src"`
+	Dst      netip.Prefix `json:"dst"`
+	Dir      string       `json:"dir"`
+	IfID     string       `json:"if_id,omitempty"`
+	Priority int          `json:"priority"`
+	ReqID    int          `json:"reqid"`
+}
+
+// XFRMState is an SA from the kernel SAD. Key material is never parsed.
+type XFRMState struct {
+	Src   netip.Addr `json:"src"`
+	Dst   netip.Addr `json:"dst"`
+	Proto string     `json:"proto"`
+	SPI   string     `json:"spi"`
+	Mode  string     `json:"mode"`
+	Encap string     `json:"encap,omitempty"`
+	IfID  string     `json:"if_id,omitempty"`
+	ReqID int        `json:"reqid"`
+}
+
+type XFRMCheck struct {
+	Peer        string      `json:"peer"`
+	Source      string      `json:"source"`
+	Destination string      `json:"destination"`
+	Policy      *XFRMPolicy `json:"policy,omitempty"`
+	States      []XFRMState `json:"states"`
+	Hints       []string    `json:"hints,omitempty"`
+	Matched     bool        `json:"matched"`
+}
+
+func ListXFRM(ctx context.Context) ([]XFRMPolicy, []XFRMState, error) {
+	policies, err := exec.CommandContext(ctx, "ip", "xfrm", "policy", "list").Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("ip xfrm policy list: %w", err)
+	}
+	states, err := exec.CommandContext(ctx, "ip", "xfrm", "state", "list").Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("ip xfrm state list: %w", err)
+	}
+	return parseXFRMPolicies(string(policies)), parseXFRMStates(string(states)), nil
+}
+
+// RouteSource returns the source address the kernel selects towards dst,
+// which is what tailscaled in userspace-networking mode dials from.
+func RouteSource(ctx context.Context, dst netip.Addr) (netip.Addr, bool) {
+	output, err := exec.CommandContext(ctx, "ip", "route", "get", dst.String()).Output()
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return parseRouteSource(string(output))
+}
+
+func parseRouteSource(output string) (netip.Addr, bool) {
+	fields := strings.Fields(output)
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == "src" {
+			addr, err := netip.ParseAddr(fields[i+1])
+			return addr, err == nil
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// xfrmBlocks splits ip xfrm output into entries; each entry starts on an
+// unindented line and continues over the indented lines below it.
+func xfrmBlocks(output string) [][]string {
+	var blocks [][]string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			blocks = append(blocks, nil)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		blocks[len(blocks)-1] = append(blocks[len(blocks)-1], strings.Fields(line)...)
+	}
+	return blocks
+}
+
+func parseXFRMPolicies(output string) []XFRMPolicy {
+	var policies []XFRMPolicy
+	for _, fields := range xfrmBlocks(output) {
+		var p XFRMPolicy
+		inTmpl := false
+		for i := 0; i < len(fields)-1; i++ {
+			value := fields[i+1]
+			switch fields[i] {
+			case "tmpl":
+				inTmpl = true
+			case "src":
+				if !inTmpl {
+					p.Src = parsePrefix(value)
+				}
+			case "dst":
+				if !inTmpl {
+					p.Dst = parsePrefix(value)
+				}
+			case "dir":
+				p.Dir = value
+			case "priority":
+				p.Priority = parseInt(value)
+			case "reqid":
+				p.ReqID = parseInt(value)
+			case "if_id":
+				p.IfID = value
+			}
+		}
+		if p.Dir == "" || !p.Src.IsValid() || !p.Dst.IsValid() {
+			continue
+		}
+		policies = append(policies, p)
+	}
+	return policies
+}
+
+func parseXFRMStates(output string) []XFRMState {
+	var states []XFRMState
+	for _, fields := range xfrmBlocks(output) {
+		var s XFRMState
+		for i := 0; i < len(fields)-1; i++ {
+			value := fields[i+1]
+			switch fields[i] {
+			case "src":
+				if s.Src.IsValid() {
+					continue
+				}
+				if addr, err := netip.ParseAddr(value); err == nil {
+					s.Src = addr
+				}
+			case "dst":
+				if s.Dst.IsValid() {
+					continue
+				}
+				if addr, err := netip.ParseAddr(value); err == nil {
+					s.Dst = addr
+				}
+			case "proto":
+				s.Proto = value
+			case "spi":
+				s.SPI = value
+			case "reqid":
+				s.ReqID = parseInt(value)
+			case "mode":
+				s.Mode = value
+			case "type":
+				if fields[i-1] == "encap" {
+					s.Encap = value
+				}
+			case "if_id":
+				s.IfID = value
+			}
+		}
+		if s.SPI == "" {
+			continue
+		}
+		states = append(states, s)
+	}
+	return states
+}
+
+func parsePrefix(s string) netip.Prefix {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked()
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen())
+	}
+	return netip.Prefix{}
+}
+
+func parseInt(s string) int {
+	n, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// MatchPolicy returns the outbound policy the kernel would apply to a packet
+// from src to dst. Lower priority values win.
+func MatchPolicy(policies []XFRMPolicy, src, dst netip.Addr) (*XFRMPolicy, bool) {
+	var best *XFRMPolicy
+	for i := range policies {
+		p := &policies[i]
+		if p.Dir != "out" || !p.Src.Contains(src) || !p.Dst.Contains(dst) {
+			continue
+		}
+		if best == nil || p.Priority < best.Priority {
+			best = p
+		}
+	}
+	return best, best != nil
+}
+
+// CheckXFRM reports whether traffic from source to dst hits an outbound
+// IPsec policy with an installed SA behind it.
+func CheckXFRM(policies []XFRMPolicy, states []XFRMState, source, dst netip.Addr) *XFRMCheck {
+	check := &XFRMCheck{
+		Source:      source.String(),
+		Destination: dst.String(),
+		States:      []XFRMState{},
+	}
+
+	policy, ok := MatchPolicy(policies, source, dst)
+	if !ok {
+		check.Hints = append(check.Hints, fmt.Sprintf(
+			"no outbound XFRM policy covers %s -> %s; the local traffic selector must include the source address", source, dst))
+		return check
+	}
+	check.Policy = policy
+
+	for _, s := range states {
+		if s.ReqID == policy.ReqID && s.Proto == "esp" {
+			check.States = append(check.States, s)
+		}
+	}
+	if len(check.States) == 0 {
+		check.Hints = append(check.Hints, fmt.Sprintf(
+			"policy %s -> %s (reqid %d) has no ESP state; the CHILD_SA is not established", policy.Src, policy.Dst, policy.ReqID))
+		return check
+	}
+
+	check.Matched = true
+	return check
+}
+
+// TrafficSource returns the source address a tailnet peer's traffic carries
+// when it leaves towards dst. Subnet-routed flows are re-originated from the
+// local stack in userspace networking and SNATed by tailscaled in kernel TUN
+// mode, so neither keeps the peer's Tailscale IP.
+func TrafficSource(ctx context.Context, peer, dst netip.Addr) netip.Addr {
+	if src, ok := RouteSource(ctx, dst); ok {
+		return src
+	}
+	return peer
+}
+
+// CheckPeer checks that traffic from a tailnet peer to dst is picked up by an
+// outbound XFRM policy.
+func CheckPeer(ctx context.Context, peer netip.Addr, peerName string, dst netip.Addr) (*XFRMCheck, error) {
+	policies, states, err := ListXFRM(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	source := TrafficSource(ctx, peer, dst)
+	check := CheckXFRM(policies, states, source, dst)
+	check.Peer = peer.String()
+	if peerName != "" {
+		check.Peer = fmt.Sprintf("%s (%s)", peerName, peer)
+	}
+	if source != peer {
+		check.Hints = append([]string{fmt.Sprintf(
+			"tailscaled re-originates or SNATs subnet traffic, so traffic from %s enters the tunnel with source %s", peer, source)}, check.Hints...)
+	}
+	return check, nil
+}
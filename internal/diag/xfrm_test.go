@@ -0,0 +1,98 @@
+package diag
+
+import (
+	"net/netip"
+	"testing"
+)
+
+const xfrmPolicyOutput = `src 10.1.0.0/24 dst 10.2.0.0/24 
+	dir out priority 375423 ptype main 
+	tmpl src 192.0.2.1 dst 198.51.100.1
+		proto esp spi 0xc1a2b3c4 reqid 1 mode tunnel
+src 10.2.0.0/24 dst 10.1.0.0/24 
+	dir in priority 375423 ptype main 
+	tmpl src 198.51.100.1 dst 192.0.2.1
+		proto esp reqid 1 mode tunnel
+src 0.0.0.0/0 dst 0.0.0.0/0 
+	socket out priority 0 ptype main 
+src 0.0.0.0/0 dst 10.2.5.0/24 
+	dir out priority 399999 ptype main 
+	if_id 0x2
+	tmpl src 192.0.2.1 dst 198.51.100.9
+		proto esp reqid 7 mode tunnel
+`
+
+const xfrmStateOutput = `src 192.0.2.1 dst 198.51.100.1
+	proto esp spi 0xc1a2b3c4 reqid 1 mode tunnel
+	replay-window 0 flag af-unspec
+	aead rfc4106(gcm(aes)) 0x00112233445566778899aabbccddeeff00112233 128
+	encap type espinudp sport 4500 dport 4500 addr 0.0.0.0
+	anti-replay context: seq 0x0, oseq 0x5, bitmap 0x00000000
+src 198.51.100.1 dst 192.0.2.1
+	proto esp spi 0xd4e5f6a7 reqid 1 mode tunnel
+	replay-window 32 flag af-unspec
+	aead rfc4106(gcm(aes)) 0xffeeddccbbaa99887766554433221100ffeeddcc 128
+`
+
+func TestParseXFRMPolicies(t *testing.T) {
+	policies := parseXFRMPolicies(xfrmPolicyOutput)
+	if len(policies) != 3 {
+		t.Fatalf("expected 3 policies (socket policy skipped), got %d: %+v", len(policies), policies)
+	}
+
+	out := policies[0]
+	if out.Dir != "out" || out.Src.String() != "10.1.0.0/24" || out.Dst.String() != "10.2.0.0/24" ||
+		out.ReqID != 1 || out.Priority != 375423 {
+		t.Errorf("unexpected outbound policy %+v", out)
+	}
+	if policies[2].IfID != "0x2" || policies[2].ReqID != 7 {
+		t.Errorf("expected if_id and reqid on third policy, got %+v", policies[2])
+	}
+}
+
+func TestParseXFRMStates(t *testing.T) {
+	states := parseXFRMStates(xfrmStateOutput)
+	if len(states) != 2 {
+		t.Fatalf("expected 2 states, got %d", len(states))
+	}
+
+	s := states[0]
+	if s.Src.String() != "192.0.2.1" || s.Dst.String() != "198.51.100.1" || s.SPI != "0xc1a2b3c4" ||
+		s.ReqID != 1 || s.Mode != "tunnel" || s.Encap != "espinudp" {
+		t.Errorf("unexpected state %+v", s)
+	}
+	if states[1].Encap != "" {
+		t.Errorf("expected no encapsulation on second state, got %q", states[1].Encap)
+	}
+}
+
+func TestCheckXFRM(t *testing.T) {
+	policies := parseXFRMPolicies(xfrmPolicyOutput)
+	states := parseXFRMStates(xfrmStateOutput)
+	dst := netip.MustParseAddr("10.2.0.10")
+
+	check := CheckXFRM(policies, states, netip.MustParseAddr("10.1.0.1"), dst)
+	if !check.Matched || check.Policy.ReqID != 1 || len(check.States) != 2 {
+		t.Errorf("expected match with both SAs, got %+v", check)
+	}
+
+	check = CheckXFRM(policies, states, netip.MustParseAddr("100.64.0.5"), dst)
+	if check.Matched || check.Policy != nil || len(check.Hints) == 0 {
+		t.Errorf("expected no policy for tailnet source, got %+v", check)
+	}
+
+	check = CheckXFRM(policies, states, netip.MustParseAddr("100.64.0.5"), netip.MustParseAddr("10.2.5.1"))
+	if check.Matched || check.Policy == nil || check.Policy.ReqID != 7 {
+		t.Errorf("expected policy without state, got %+v", check)
+	}
+}
+
+func TestParseRouteSource(t *testing.T) {
+	got, ok := parseRouteSource("10.2.0.10 via 172.17.0.1 dev eth0 table 220 src 10.1.0.1 uid 0 \n    cache \n")
+	if !ok || got.String() != "10.1.0.1" {
+		t.Errorf("expected 10.1.0.1, got %s", got)
+	}
+	if _, ok := parseRouteSource("local 127.0.0.1 dev lo table local"); ok {
+		t.Error("expected no source")
+	}
+}
@@ -0,0 +1,487 @@
+package doctor
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+
+	"github.com/klowdo/tailswan/internal/diag"
+	"github.com/klowdo/tailswan/internal/routing"
+	"github.com/klowdo/tailswan/internal/supervisor"
+	"github.com/klowdo/tailswan/internal/viciconn"
+	"github.com/klowdo/tailswan/internal/xfrmif"
+)
+
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+const TunUserspace = supervisor.TunUserspaceNetworking
+
+type Check struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// Facts is everything the checks look at. Gather collects it from the running
+// system; errors are kept so a missing component becomes a failed check
+// rather than aborting the whole run.
+type Facts struct {
+	Sysctls map[string]string
+	// RouteSources maps a remote host to the source address the kernel
+	// selects towards it.
+	RouteSources map[netip.Addr]netip.Addr
+	// InterfacesUp maps XFRM interface names to whether they are up.
+	InterfacesUp map[string]bool
+	Status       *ipnstate.Status
+	Prefs        *ipn.Prefs
+	SysctlErr    error
+	VICIErr      error
+	XFRMErr      error
+	TailscaleErr error
+	FirewallErr  error
+	RoutingErr   error
+	TunMode      string
+	// ConfiguredTunMode is TS_TUN_MODE, which differs from TunMode when the
+	// supervisor had to fall back to userspace networking.
+	ConfiguredTunMode string
+	FirewallBackend   string
+	FirewallRules     string
+	Children          []viciconn.Child
+	SAs               []viciconn.ChildSA
+	Policies          []diag.XFRMPolicy
+	States            []diag.XFRMState
+	ConfiguredRoutes  []string
+	LocalNets         []netip.Prefix
+	MissingRules      []routing.Rule
+	XFRMInterfaces    bool
+}
+
+func Evaluate(f *Facts) []Check {
+	var checks []Check
+	checks = append(checks, checkSysctls(f)...)
+	checks = append(checks, checkTailscale(f)...)
+	checks = append(checks, checkTunMode(f))
+	checks = append(checks, checkPolicyRouting(f)...)
+	checks = append(checks, checkRoutes(f)...)
+	checks = append(checks, checkChildren(f)...)
+	checks = append(checks, checkInterfaces(f)...)
+	checks = append(checks, checkFirewall(f))
+	checks = append(checks, checkOverlaps(f)...)
+	return checks
+}
+
+func Failed(checks []Check) int {
+	n := 0
+	for _, c := range checks {
+		if c.Status == StatusFail {
+			n++
+		}
+	}
+	return n
+}
+
+func checkSysctls(f *Facts) []Check {
+	if f.SysctlErr != nil {
+		return []Check{{
+			Name:    "IP forwarding",
+			Status:  StatusFail,
+			Message: fmt.Sprintf("cannot read sysctls: %v", f.SysctlErr),
+		}}
+	}
+
+	var checks []Check
+	for _, s := range supervisor.Sysctls {
+		got, ok := f.Sysctls[s.Key]
+		check := Check{Name: "sysctl " + s.Key, Status: StatusOK, Message: fmt.Sprintf("%s = %s", s.Key, got)}
+		if !ok || got != s.Value {
+			check.Status = StatusFail
+			check.Message = fmt.Sprintf("%s is %q, expected %s", s.Key, got, s.Value)
+			check.Hint = "run the container with --sysctl " + s.Key + "=" + s.Value + " or grant NET_ADMIN so TailSwan can set it"
+		}
+		checks = append(checks, check)
+	}
+
+	if v := f.Sysctls["net.ipv4.conf.all.rp_filter"]; v == "1" {
+		checks = append(checks, Check{
+			Name:    "sysctl net.ipv4.conf.all.rp_filter",
+			Status:  StatusWarn,
+			Message: "strict reverse path filtering is enabled",
+			Hint:    "decrypted packets arriving on a different interface than the route back can be dropped; set net.ipv4.conf.all.rp_filter=2",
+		})
+	}
+	return checks
+}
+
+func checkTailscale(f *Facts) []Check {
+	if f.TailscaleErr != nil {
+		return []Check{{
+			Name:    "Tailscale",
+			Status:  StatusFail,
+			Message: fmt.Sprintf("tailscaled not reachable: %v", f.TailscaleErr),
+		}}
+	}
+
+	check := Check{Name: "Tailscale", Status: StatusOK, Message: "backend state " + f.Status.BackendState}
+	if f.Status.BackendState != ipn.Running.String() {
+		check.Status = StatusFail
+		check.Hint = "the node is not connected to the tailnet; check TS_AUTHKEY or run tailscale up"
+	}
+	checks := []Check{check}
+
+	for _, warning := range f.Status.Health {
+		checks = append(checks, Check{Name: "Tailscale health", Status: StatusWarn, Message: warning})
+	}
+	return checks
+}
+
+func checkTunMode(f *Facts) Check {
+	check := Check{Name: "Tailscale TUN mode", Status: StatusOK, Message: "tailscaled uses kernel TUN " + f.TunMode}
+	switch f.TunMode {
+	case "":
+		check.Status = StatusWarn
+		check.Message = "could not determine tailscaled --tun mode"
+	case TunUserspace:
+		check.Status = StatusWarn
+		check.Message = "tailscaled runs with --tun=userspace-networking"
+		check.Hint = "subnet traffic is proxied by tailscaled and re-originated from this host instead of being forwarded into the kernel: " +
+			"flows leave with the gateway's own address as source, ICMP other than ping is lost, and hosts behind the IPsec tunnel cannot reach tailnet peers; " +
+			"set TS_TUN_MODE=kernel to use a tailscale0 device"
+		if f.ConfiguredTunMode == supervisor.TunModeKernel {
+			check.Message = "TS_TUN_MODE=kernel but tailscaled fell back to userspace networking"
+			check.Hint = "no TUN interface could be created; run the container with --device /dev/net/tun and --cap-add NET_ADMIN"
+		}
+	}
+	return check
+}
+
+func kernelTun(f *Facts) bool {
+	return f.TunMode != "" && f.TunMode != TunUserspace
+}
+
+func checkPolicyRouting(f *Facts) []Check {
+	if !kernelTun(f) {
+		return nil
+	}
+
+	check := Check{Name: "policy routing", Status: StatusOK, Message: "tailnet and IPsec rule priorities installed"}
+	switch {
+	case f.RoutingErr != nil:
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("cannot list ip rules: %v", f.RoutingErr)
+	case len(f.MissingRules) > 0:
+		missing := make([]string, len(f.MissingRules))
+		for i := range f.MissingRules {
+			missing[i] = f.MissingRules[i].String()
+		}
+		check.Status = StatusFail
+		check.Message = "missing ip rules: " + strings.Join(missing, "; ")
+		check.Hint = "without them tailnet replies can be routed into the tunnel or IPsec subnets can be captured by accepted tailnet routes; " +
+			"restart TailSwan so the supervisor reinstalls them"
+	}
+	return []Check{check}
+}
+
+func checkRoutes(f *Facts) []Check {
+	if f.Prefs == nil || f.Status == nil {
+		return nil
+	}
+
+	advertised := make(map[netip.Prefix]bool)
+	for _, p := range f.Prefs.AdvertiseRoutes {
+		advertised[p.Masked()] = true
+	}
+
+	var checks []Check
+	for _, r := range f.ConfiguredRoutes {
+		p, err := netip.ParsePrefix(r)
+		if err != nil {
+			checks = append(checks, Check{
+				Name:    "route " + r,
+				Status:  StatusFail,
+				Message: fmt.Sprintf("TS_ROUTES entry %q is not a valid prefix", r),
+			})
+			continue
+		}
+		if !advertised[p.Masked()] {
+			checks = append(checks, Check{
+				Name:    "route " + r,
+				Status:  StatusFail,
+				Message: "configured in TS_ROUTES but not advertised in the Tailscale prefs",
+				Hint:    "tailscale up was run with different --advertise-routes; restart TailSwan to re-apply",
+			})
+		}
+	}
+
+	approved := make(map[netip.Prefix]bool)
+	if f.Status.Self != nil && f.Status.Self.AllowedIPs != nil {
+		for _, p := range f.Status.Self.AllowedIPs.All() {
+			approved[p.Masked()] = true
+		}
+	}
+
+	for _, p := range sortedPrefixes(advertised) {
+		check := Check{Name: "route " + p.String(), Status: StatusOK, Message: "advertised and approved"}
+		if !approved[p] {
+			check.Status = StatusFail
+			check.Message = "advertised but not approved in the tailnet"
+			check.Hint = "approve the subnet route in the Tailscale admin console or add an autoApprovers rule to the policy file"
+		}
+		checks = append(checks, check)
+	}
+
+	for _, child := range f.Children {
+		for _, remote := range viciconn.Prefixes(child.RemoteTS) {
+			if !coveredByAny(remote, sortedPrefixes(advertised)) {
+				checks = append(checks, Check{
+					Name:    fmt.Sprintf("route %s (%s/%s)", remote, child.Connection, child.Name),
+					Status:  StatusFail,
+					Message: "remote subnet of the CHILD_SA is not advertised to the tailnet",
+					Hint:    "add " + remote.String() + " to TS_ROUTES so tailnet peers send this traffic to the gateway",
+				})
+			}
+		}
+	}
+	return checks
+}
+
+func checkChildren(f *Facts) []Check {
+	if f.VICIErr != nil {
+		return []Check{{
+			Name:    "strongSwan",
+			Status:  StatusFail,
+			Message: fmt.Sprintf("charon not reachable over VICI: %v", f.VICIErr),
+		}}
+	}
+	if len(f.Children) == 0 {
+		return []Check{{
+			Name:    "strongSwan",
+			Status:  StatusWarn,
+			Message: "no connections loaded",
+			Hint:    "check SWAN_CONFIG and run tailswan reload",
+		}}
+	}
+
+	var checks []Check
+	for _, child := range f.Children {
+		checks = append(checks, checkChild(f, &child))
+	}
+	return checks
+}
+
+func checkChild(f *Facts, child *viciconn.Child) Check {
+	check := Check{Name: fmt.Sprintf("CHILD_SA %s/%s", child.Connection, child.Name)}
+
+	sa, ok := installedSA(f.SAs, child)
+	if !ok {
+		check.Status = StatusFail
+		check.Message = "not established"
+		check.Hint = "bring it up with tailswan start " + child.Name + ", or set start_action = start / SWAN_AUTO_START=true"
+		return check
+	}
+	if f.XFRMErr != nil {
+		check.Status = StatusWarn
+		check.Message = fmt.Sprintf("established, but XFRM state cannot be read: %v", f.XFRMErr)
+		return check
+	}
+
+	localTS := viciconn.Prefixes(sa.LocalTS)
+	remoteTS := viciconn.Prefixes(sa.RemoteTS)
+	for _, local := range localTS {
+		for _, remote := range remoteTS {
+			policy, ok := policyFor(f.Policies, local, remote)
+			if !ok {
+				check.Status = StatusFail
+				check.Message = fmt.Sprintf("no outbound XFRM policy for %s -> %s", local, remote)
+				check.Hint = "charon installed the SA without a policy; check for policies = no or a conflicting VTI/if_id setup"
+				return check
+			}
+			if !hasState(f.States, policy.ReqID) {
+				check.Status = StatusFail
+				check.Message = fmt.Sprintf("XFRM policy %s -> %s (reqid %d) has no ESP state", local, remote, policy.ReqID)
+				return check
+			}
+		}
+	}
+
+	for _, remote := range remoteTS {
+		if !reoriginated(f) {
+			break
+		}
+		target, ok := diag.DefaultTarget([]string{remote.String()})
+		if !ok {
+			continue
+		}
+		src, ok := f.RouteSources[target]
+		if !ok || containsAddr(localTS, src) {
+			continue
+		}
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("traffic to %s leaves with source %s, which is outside the local traffic selector %s",
+			target, src, strings.Join(sa.LocalTS, ", "))
+		check.Hint = "tailnet flows are re-originated (userspace networking) or SNATed (kernel TUN) to this source and bypass the tunnel; " +
+			"add the gateway's address to local_ts or give the container an address inside it"
+		return check
+	}
+
+	check.Status = StatusOK
+	check.Message = fmt.Sprintf("installed, %s <-> %s", strings.Join(sa.LocalTS, ", "), strings.Join(sa.RemoteTS, ", "))
+	return check
+}
+
+func checkInterfaces(f *Facts) []Check {
+	if !f.XFRMInterfaces {
+		return nil
+	}
+
+	planned, problems := xfrmif.Plan(f.Children)
+	var checks []Check
+	for _, p := range problems {
+		checks = append(checks, Check{
+			Name:    "if_id " + p.Connection + "/" + p.Child,
+			Status:  StatusWarn,
+			Message: p.Message,
+			Hint:    "the child has no TailSwan-managed XFRM interface, so its traffic is neither routed nor firewalled per interface",
+		})
+	}
+
+	for i := range planned {
+		iface := &planned[i]
+		check := Check{
+			Name:    "XFRM interface " + iface.Name,
+			Status:  StatusOK,
+			Message: fmt.Sprintf("up, if_id %d for %s", iface.IfID, iface.Connection),
+		}
+		up, exists := f.InterfacesUp[iface.Name]
+		switch {
+		case !exists:
+			check.Status = StatusFail
+			check.Message = fmt.Sprintf("missing, expected for %s (if_id %d)", iface.Connection, iface.IfID)
+			check.Hint = "the supervisor creates it within 30 seconds of the connection being loaded; check its logs for netlink errors"
+		case !up:
+			check.Status = StatusFail
+			check.Message = "interface is down"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func checkFirewall(f *Facts) Check {
+	check := Check{Name: "firewall", Status: StatusOK, Message: "TailSwan rules present (" + f.FirewallBackend + ")"}
+	switch {
+	case f.FirewallBackend == "none":
+		check.Status = StatusWarn
+		check.Message = "firewall management disabled (FIREWALL_BACKEND=none)"
+		check.Hint = "forwarded traffic then relies on rules you manage yourself, including masquerade and MSS clamping"
+	case f.FirewallErr != nil:
+		check.Status = StatusFail
+		check.Message = fmt.Sprintf("TailSwan rules missing: %v", f.FirewallErr)
+		check.Hint = "the supervisor reconciles rules every 30 seconds; check its logs for nft/iptables errors"
+	case strings.TrimSpace(f.FirewallRules) == "":
+		check.Status = StatusFail
+		check.Message = "TailSwan rules are empty"
+	}
+	return check
+}
+
+func checkOverlaps(f *Facts) []Check {
+	var checks []Check
+	for _, child := range f.Children {
+		for _, remote := range viciconn.Prefixes(child.RemoteTS) {
+			for _, local := range f.LocalNets {
+				if remote.Overlaps(local) {
+					checks = append(checks, Check{
+						Name:    fmt.Sprintf("overlap %s/%s", child.Connection, child.Name),
+						Status:  StatusWarn,
+						Message: fmt.Sprintf("remote subnet %s overlaps local network %s", remote, local),
+						Hint:    "replies to hosts in the overlap are delivered locally instead of through the tunnel; use a different subnet or NAT",
+					})
+				}
+			}
+		}
+	}
+	return checks
+}
+
+func installedSA(sas []viciconn.ChildSA, child *viciconn.Child) (*viciconn.ChildSA, bool) {
+	for i := range sas {
+		sa := &sas[i]
+		if sa.IKE == child.Connection && sa.Name == child.Name && sa.State == "INSTALLED" {
+			return sa, true
+		}
+	}
+	return nil, false
+}
+
+func policyFor(policies []diag.XFRMPolicy, local, remote netip.Prefix) (*diag.XFRMPolicy, bool) {
+	for i := range policies {
+		p := &policies[i]
+		if p.Dir == "out" && covers(p.Src, local) && covers(p.Dst, remote) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+func hasState(states []diag.XFRMState, reqID int) bool {
+	for _, s := range states {
+		if s.ReqID == reqID && s.Proto == "esp" {
+			return true
+		}
+	}
+	return false
+}
+
+func covers(outer, inner netip.Prefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
+
+func coveredByAny(p netip.Prefix, prefixes []netip.Prefix) bool {
+	for _, outer := range prefixes {
+		if covers(outer, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedPrefixes(set map[netip.Prefix]bool) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(set))
+	for p := range set {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i].String() < prefixes[j].String()
+	})
+	return prefixes
+}
+
+// reoriginated reports whether tailnet flows reach the tunnel with this
+// host's route source rather than the peer's tailnet address: always in
+// userspace networking, and in kernel mode unless --snat-subnet-routes=false.
+func reoriginated(f *Facts) bool {
+	if f.TunMode == TunUserspace {
+		return true
+	}
+	return kernelTun(f) && f.Prefs != nil && !f.Prefs.NoSNAT
+}
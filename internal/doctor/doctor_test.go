@@ -0,0 +1,272 @@
+package doctor
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/types/views"
+
+	"github.com/klowdo/tailswan/internal/diag"
+	"github.com/klowdo/tailswan/internal/routing"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+func healthyFacts() *Facts {
+	allowed := views.SliceOf([]netip.Prefix{
+		netip.MustParsePrefix("100.64.0.1/32"),
+		netip.MustParsePrefix("10.2.0.0/24"),
+	})
+
+	return &Facts{
+		Sysctls: map[string]string{
+			"net.ipv4.ip_forward":                  "1",
+			"net.ipv6.conf.all.forwarding":         "1",
+			"net.ipv4.conf.all.send_redirects":     "0",
+			"net.ipv4.conf.default.send_redirects": "0",
+			"net.ipv4.conf.all.rp_filter":          "2",
+		},
+		Children: []viciconn.Child{
+			{Connection: "mysite", Name: "net-net", LocalTS: []string{"10.1.0.0/24"}, RemoteTS: []string{"10.2.0.0/24"}},
+		},
+		SAs: []viciconn.ChildSA{
+			{IKE: "mysite", Name: "net-net", State: "INSTALLED", LocalTS: []string{"10.1.0.0/24"}, RemoteTS: []string{"10.2.0.0/24"}},
+		},
+		Policies: []diag.XFRMPolicy{
+			{Src: netip.MustParsePrefix("10.1.0.0/24"), Dst: netip.MustParsePrefix("10.2.0.0/24"), Dir: "out", ReqID: 1},
+		},
+		States: []diag.XFRMState{{Proto: "esp", SPI: "0xc1a2b3c4", ReqID: 1}},
+		Status: &ipnstate.Status{
+			BackendState: ipn.Running.String(),
+			Self:         &ipnstate.PeerStatus{AllowedIPs: &allowed},
+		},
+		Prefs:            &ipn.Prefs{AdvertiseRoutes: []netip.Prefix{netip.MustParsePrefix("10.2.0.0/24")}},
+		ConfiguredRoutes: []string{"10.2.0.0/24"},
+		TunMode:          TunUserspace,
+		FirewallBackend:  "nftables",
+		FirewallRules:    "table inet tailswan {}",
+		LocalNets:        []netip.Prefix{netip.MustParsePrefix("10.1.0.0/24")},
+		RouteSources: map[netip.Addr]netip.Addr{
+			netip.MustParseAddr("10.2.0.1"): netip.MustParseAddr("10.1.0.1"),
+		},
+	}
+}
+
+func findCheck(checks []Check, prefix string, status Status) *Check {
+	for i := range checks {
+		if strings.HasPrefix(checks[i].Name, prefix) && checks[i].Status == status {
+			return &checks[i]
+		}
+	}
+	return nil
+}
+
+func TestEvaluateHealthy(t *testing.T) {
+	checks := Evaluate(healthyFacts())
+	if n := Failed(checks); n != 0 {
+		t.Errorf("expected no failures, got %d: %+v", n, checks)
+	}
+	if findCheck(checks, "Tailscale TUN mode", StatusWarn) == nil {
+		t.Error("expected userspace-networking warning")
+	}
+}
+
+func TestEvaluateFailures(t *testing.T) {
+	tests := []struct {
+		mutate func(*Facts)
+		name   string
+		check  string
+		status Status
+	}{
+		{
+			name:   "forwarding disabled",
+			mutate: func(f *Facts) { f.Sysctls["net.ipv4.ip_forward"] = "0" },
+			check:  "sysctl net.ipv4.ip_forward",
+			status: StatusFail,
+		},
+		{
+			name:   "strict rp_filter",
+			mutate: func(f *Facts) { f.Sysctls["net.ipv4.conf.all.rp_filter"] = "1" },
+			check:  "sysctl net.ipv4.conf.all.rp_filter",
+			status: StatusWarn,
+		},
+		{
+			name:   "tailscale logged out",
+			mutate: func(f *Facts) { f.Status.BackendState = ipn.NeedsLogin.String() },
+			check:  "Tailscale",
+			status: StatusFail,
+		},
+		{
+			name: "route not approved",
+			mutate: func(f *Facts) {
+				allowed := views.SliceOf([]netip.Prefix{netip.MustParsePrefix("100.64.0.1/32")})
+				f.Status.Self.AllowedIPs = &allowed
+			},
+			check:  "route 10.2.0.0/24",
+			status: StatusFail,
+		},
+		{
+			name:   "configured route not advertised",
+			mutate: func(f *Facts) { f.ConfiguredRoutes = append(f.ConfiguredRoutes, "10.3.0.0/24") },
+			check:  "route 10.3.0.0/24",
+			status: StatusFail,
+		},
+		{
+			name:   "remote subnet not advertised",
+			mutate: func(f *Facts) { f.Prefs.AdvertiseRoutes = nil },
+			check:  "route 10.2.0.0/24 (mysite/net-net)",
+			status: StatusFail,
+		},
+		{
+			name:   "child not installed",
+			mutate: func(f *Facts) { f.SAs = nil },
+			check:  "CHILD_SA mysite/net-net",
+			status: StatusFail,
+		},
+		{
+			name:   "missing policy",
+			mutate: func(f *Facts) { f.Policies = nil },
+			check:  "CHILD_SA mysite/net-net",
+			status: StatusFail,
+		},
+		{
+			name:   "missing state",
+			mutate: func(f *Facts) { f.States = nil },
+			check:  "CHILD_SA mysite/net-net",
+			status: StatusFail,
+		},
+		{
+			name: "source outside local traffic selector",
+			mutate: func(f *Facts) {
+				f.RouteSources[netip.MustParseAddr("10.2.0.1")] = netip.MustParseAddr("172.17.0.2")
+			},
+			check:  "CHILD_SA mysite/net-net",
+			status: StatusFail,
+		},
+		{
+			name:   "vici down",
+			mutate: func(f *Facts) { f.VICIErr = errors.New("connection refused") },
+			check:  "strongSwan",
+			status: StatusFail,
+		},
+		{
+			name:   "firewall rules missing",
+			mutate: func(f *Facts) { f.FirewallErr = errors.New("no such table") },
+			check:  "firewall",
+			status: StatusFail,
+		},
+		{
+			name:   "remote overlaps local network",
+			mutate: func(f *Facts) { f.LocalNets = append(f.LocalNets, netip.MustParsePrefix("10.2.0.0/16")) },
+			check:  "overlap mysite/net-net",
+			status: StatusWarn,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := healthyFacts()
+			tt.mutate(f)
+			checks := Evaluate(f)
+
+			c := findCheck(checks, tt.check, tt.status)
+			if c == nil {
+				t.Fatalf("expected %s check %q, got %+v", tt.status, tt.check, checks)
+			}
+			if c.Message == "" {
+				t.Error("expected an explanation")
+			}
+		})
+	}
+}
+
+func TestSourceCheckInKernelMode(t *testing.T) {
+	f := healthyFacts()
+	f.TunMode = "tailscale0"
+	f.RouteSources[netip.MustParseAddr("10.2.0.1")] = netip.MustParseAddr("172.17.0.2")
+
+	if findCheck(Evaluate(f), "CHILD_SA mysite/net-net", StatusFail) == nil {
+		t.Error("expected SNATed kernel TUN traffic to be checked against the local traffic selector")
+	}
+
+	f.Prefs.NoSNAT = true
+	if n := Failed(Evaluate(f)); n != 0 {
+		t.Errorf("expected --snat-subnet-routes=false to skip the source check, got %d failures", n)
+	}
+}
+
+func TestPolicyRoutingCheck(t *testing.T) {
+	f := healthyFacts()
+	if c := findCheck(Evaluate(f), "policy routing", StatusOK); c != nil {
+		t.Error("expected no policy routing check in userspace mode")
+	}
+
+	f.TunMode = "tailscale0"
+	checks := Evaluate(f)
+	if findCheck(checks, "policy routing", StatusOK) == nil {
+		t.Errorf("expected policy routing ok, got %+v", checks)
+	}
+	if findCheck(checks, "Tailscale TUN mode", StatusOK) == nil {
+		t.Error("expected kernel TUN mode to be ok")
+	}
+
+	f.MissingRules = routing.KernelRules()[:1]
+	c := findCheck(Evaluate(f), "policy routing", StatusFail)
+	if c == nil || !strings.Contains(c.Message, "pref 200") {
+		t.Errorf("expected missing rule to fail, got %+v", c)
+	}
+}
+
+func TestTunModeFallback(t *testing.T) {
+	f := healthyFacts()
+	f.ConfiguredTunMode = "kernel"
+
+	c := findCheck(Evaluate(f), "Tailscale TUN mode", StatusWarn)
+	if c == nil || !strings.Contains(c.Message, "fell back") {
+		t.Errorf("expected fallback warning, got %+v", c)
+	}
+}
+
+func TestInterfaceChecks(t *testing.T) {
+	f := healthyFacts()
+	f.Children[0].IfIDIn, f.Children[0].IfIDOut = 1, 1
+	f.Children = append(f.Children, viciconn.Child{Connection: "other", Name: "lan", IfIDIn: 2, IfIDOut: 3})
+	if checks := checkInterfaces(f); len(checks) != 0 {
+		t.Errorf("expected no interface checks when disabled, got %+v", checks)
+	}
+
+	f.XFRMInterfaces = true
+	checks := Evaluate(f)
+	if findCheck(checks, "XFRM interface xfrm1", StatusFail) == nil {
+		t.Errorf("expected missing xfrm1 to fail, got %+v", checks)
+	}
+	if findCheck(checks, "if_id other/lan", StatusWarn) == nil {
+		t.Errorf("expected asymmetric if_id warning, got %+v", checks)
+	}
+
+	f.InterfacesUp = map[string]bool{"xfrm1": true}
+	if findCheck(Evaluate(f), "XFRM interface xfrm1", StatusOK) == nil {
+		t.Error("expected xfrm1 to be ok once it is up")
+	}
+}
+
+func TestTunArg(t *testing.T) {
+	tests := []struct {
+		want string
+		args []string
+	}{
+		{args: []string{"--state", "x", "--tun", "userspace-networking"}, want: TunUserspace},
+		{args: []string{"--tun=ts0"}, want: "ts0"},
+		{args: []string{"-tun", "userspace-networking"}, want: TunUserspace},
+		{args: []string{"--state", "x"}, want: "tailscale0"},
+	}
+
+	for _, tt := range tests {
+		if got := tunArg(tt.args); got != tt.want {
+			t.Errorf("tunArg(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/strongswan/govici/vici"
+	"tailscale.com/client/local"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/diag"
+	"github.com/klowdo/tailswan/internal/firewall"
+	"github.com/klowdo/tailswan/internal/routing"
+	"github.com/klowdo/tailswan/internal/supervisor"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+var extraSysctls = []string{
+	"net.ipv4.conf.all.rp_filter",
+	"net.ipv4.conf.default.rp_filter",
+	"net.ipv4.ip_no_pmtu_disc",
+	"net.ipv4.tcp_mtu_probing",
+}
+
+func Gather(ctx context.Context, cfg *config.Config) *Facts {
+	f := &Facts{
+		ConfiguredRoutes: cfg.Tailscale.Routes,
+		FirewallBackend:  cfg.Firewall.Backend,
+		RouteSources:     make(map[netip.Addr]netip.Addr),
+	}
+
+	f.Sysctls, f.SysctlErr = ReadSysctls()
+	gatherVICI(f)
+	f.Policies, f.States, f.XFRMErr = diag.ListXFRM(ctx)
+	gatherTailscale(ctx, f)
+	f.TunMode = TunMode()
+	f.ConfiguredTunMode = cfg.Tailscale.TunMode
+	if kernelTun(f) {
+		f.MissingRules, f.RoutingErr = routing.NewManager().Missing(routing.KernelRules())
+	}
+	gatherFirewall(f)
+	f.LocalNets = localNets()
+	if cfg.Swan.XFRMInterfaces {
+		f.XFRMInterfaces = true
+		f.InterfacesUp = xfrmInterfaces()
+	}
+
+	for _, child := range f.Children {
+		for _, remote := range viciconn.Prefixes(child.RemoteTS) {
+			target, ok := diag.DefaultTarget([]string{remote.String()})
+			if !ok {
+				continue
+			}
+			if src, ok := diag.RouteSource(ctx, target); ok {
+				f.RouteSources[target] = src
+			}
+		}
+	}
+	return f
+}
+
+// SysctlKeys lists the sysctls SetupSystem manages followed by the ones that
+// commonly break forwarding through a tunnel.
+func SysctlKeys() []string {
+	keys := make([]string, 0, len(supervisor.Sysctls)+len(extraSysctls))
+	for _, s := range supervisor.Sysctls {
+		keys = append(keys, s.Key)
+	}
+	return append(keys, extraSysctls...)
+}
+
+func ReadSysctls() (map[string]string, error) {
+	keys := SysctlKeys()
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		data, err := os.ReadFile(filepath.Join("/proc/sys", strings.ReplaceAll(key, ".", "/")))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		values[key] = strings.TrimSpace(string(data))
+	}
+	return values, nil
+}
+
+func gatherVICI(f *Facts) {
+	session, err := vici.NewSession()
+	if err != nil {
+		f.VICIErr = err
+		return
+	}
+	defer session.Close() //nolint:errcheck
+
+	if f.Children, err = viciconn.Children(session); err != nil {
+		f.VICIErr = err
+		return
+	}
+	f.SAs, f.VICIErr = viciconn.ChildSAs(session)
+}
+
+func gatherTailscale(ctx context.Context, f *Facts) {
+	client := &local.Client{}
+	status, err := client.Status(ctx)
+	if err != nil {
+		f.TailscaleErr = err
+		return
+	}
+	prefs, err := client.GetPrefs(ctx)
+	if err != nil {
+		f.TailscaleErr = fmt.Errorf("get prefs: %w", err)
+		return
+	}
+	f.Status = status
+	f.Prefs = prefs
+}
+
+func gatherFirewall(f *Facts) {
+	if f.FirewallBackend == firewall.BackendNone {
+		return
+	}
+	backend, err := firewall.NewBackend(f.FirewallBackend)
+	if err != nil {
+		f.FirewallErr = err
+		return
+	}
+	f.FirewallBackend = backend.Name()
+	f.FirewallRules, f.FirewallErr = backend.Show()
+}
+
+// TunMode returns the --tun argument of the running tailscaled, or
+// "tailscale0" when it runs with the default kernel TUN device.
+func TunMode() string {
+	procs, err := filepath.Glob("/proc/[0-9]*/cmdline")
+	if err != nil {
+		return ""
+	}
+	for _, path := range procs {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		args := strings.Split(string(bytes.TrimRight(data, "\x00")), "\x00")
+		if filepath.Base(args[0]) != "tailscaled" {
+			continue
+		}
+		return tunArg(args[1:])
+	}
+	return ""
+}
+
+func tunArg(args []string) string {
+	for i, arg := range args {
+		switch {
+		case arg == "--tun" || arg == "-tun":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+		case strings.HasPrefix(arg, "--tun="), strings.HasPrefix(arg, "-tun="):
+			_, value, _ := strings.Cut(arg, "=")
+			return value
+		}
+	}
+	return "tailscale0"
+}
+
+func xfrmInterfaces() map[string]bool {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	up := make(map[string]bool)
+	for _, iface := range ifaces {
+		if strings.HasPrefix(iface.Name, "xfrm") {
+			up[iface.Name] = iface.Flags&net.FlagUp != 0
+		}
+	}
+	return up
+}
+
+func localNets() []netip.Prefix {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+
+	var nets []netip.Prefix
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 || strings.HasPrefix(iface.Name, "tailscale") {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			addr, ok := netip.AddrFromSlice(ipNet.IP)
+			if !ok || addr.Unmap().IsLinkLocalUnicast() {
+				continue
+			}
+			ones, _ := ipNet.Mask.Size()
+			nets = append(nets, netip.PrefixFrom(addr.Unmap(), ones).Masked())
+		}
+	}
+	return nets
+}
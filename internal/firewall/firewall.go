@@ -0,0 +1,207 @@
+package firewall
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os/exec"
+	"reflect"
+	"sync"
+)
+
+const (
+	BackendAuto     = "auto"
+	BackendNftables = "nftables"
+	BackendIptables = "iptables"
+	BackendNone     = "none"
+
+	TableName             = "tailswan"
+	DefaultTailscaleIface = "tailscale0"
+)
+
+// ForwardRule allows traffic between the tailnet and the remote subnets of a
+// single CHILD_SA. When IPsecIface is empty the IPsec side is matched by
+// policy, which is what policy-based (non-XFRM-interface) tunnels need.
+// A non-zero MTU clamps TCP MSS for the subnets to fit through the tunnel.
+type ForwardRule struct {
+	Connection    string
+	Child         string
+	IPsecIface    string
+	RemoteSubnets []netip.Prefix
+	MTU           int
+}
+
+// IdentityRule limits the tailnet destinations an IPsec client's virtual
+// IPs may reach to Allowed; everything else it sends to the tailnet is
+// dropped.
+type IdentityRule struct {
+	Identity  string
+	Addresses []netip.Addr
+	Allowed   []netip.Prefix
+}
+
+type RuleSet struct {
+	TailscaleIface  string
+	MasqueradeIface string
+	Forward         []ForwardRule
+	Identities      []IdentityRule
+	// VirtualIPPools are dropped towards the tailnet after the identity
+	// rules, for clients that have no rule yet.
+	VirtualIPPools []netip.Prefix
+	MSSClamp       bool
+	DropUnmatched  bool
+}
+
+type Backend interface {
+	Name() string
+	Apply(rs *RuleSet) error
+	Flush() error
+	Show() (string, error)
+}
+
+type runner func(stdin string, name string, args ...string) ([]byte, error)
+
+func execRunner(stdin, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != "" {
+		cmd.Stdin = bytes.NewBufferString(stdin)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return output, fmt.Errorf("%s: %w: %s", name, err, bytes.TrimSpace(output))
+	}
+	return output, nil
+}
+
+func NewBackend(name string) (Backend, error) {
+	switch name {
+	case BackendNftables:
+		return newNftables(execRunner), nil
+	case BackendIptables:
+		return newIptables(execRunner), nil
+	case BackendNone:
+		return noopBackend{}, nil
+	case BackendAuto, "":
+		if _, err := exec.LookPath("nft"); err == nil {
+			return newNftables(execRunner), nil
+		}
+		if _, err := exec.LookPath("iptables-legacy"); err == nil {
+			slog.Warn("nft not found, falling back to iptables-legacy")
+			return newIptables(execRunner), nil
+		}
+		return nil, fmt.Errorf("no firewall backend available: neither nft nor iptables-legacy found")
+	default:
+		return nil, fmt.Errorf("unknown firewall backend %q", name)
+	}
+}
+
+type Manager struct {
+	backend Backend
+	current *RuleSet
+	mu      sync.Mutex
+}
+
+func NewManager(backend Backend) *Manager {
+	return &Manager{backend: backend}
+}
+
+func (m *Manager) Backend() Backend {
+	return m.backend
+}
+
+// Reconcile replaces everything TailSwan owns with rs. Backends apply the
+// whole rule set at once, so calling it repeatedly never duplicates rules;
+// an unchanged rule set is not re-applied at all.
+func (m *Manager) Reconcile(rs *RuleSet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if reflect.DeepEqual(m.current, rs) {
+		return nil
+	}
+
+	if err := m.backend.Apply(rs); err != nil {
+		return fmt.Errorf("apply %s rules: %w", m.backend.Name(), err)
+	}
+	m.current = rs
+	slog.Info("Firewall rules reconciled",
+		"backend", m.backend.Name(),
+		"forward_rules", len(rs.Forward),
+		"identity_rules", len(rs.Identities),
+		"masquerade", rs.MasqueradeIface != "",
+		"mss_clamp", rs.MSSClamp)
+	return nil
+}
+
+func (m *Manager) Current() *RuleSet {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+func (m *Manager) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.backend.Flush(); err != nil {
+		return fmt.Errorf("flush %s rules: %w", m.backend.Name(), err)
+	}
+	m.current = nil
+	return nil
+}
+
+func (rs *RuleSet) tailscaleIface() string {
+	if rs.TailscaleIface == "" {
+		return DefaultTailscaleIface
+	}
+	return rs.TailscaleIface
+}
+
+func splitFamilies(prefixes []netip.Prefix) (v4, v6 []netip.Prefix) {
+	for _, p := range prefixes {
+		if p.Addr().Is4() {
+			v4 = append(v4, p)
+		} else {
+			v6 = append(v6, p)
+		}
+	}
+	return v4, v6
+}
+
+const (
+	ipv4TCPHeaders = 40
+	ipv6TCPHeaders = 60
+)
+
+func (r *ForwardRule) mss(ipv6 bool) int {
+	if ipv6 {
+		return r.MTU - ipv6TCPHeaders
+	}
+	return r.MTU - ipv4TCPHeaders
+}
+
+// allowed returns the destinations of addr's family.
+func (r *IdentityRule) allowed(addr netip.Addr) []netip.Prefix {
+	var allowed []netip.Prefix
+	for _, p := range r.Allowed {
+		if p.Addr().Is4() == addr.Is4() {
+			allowed = append(allowed, p)
+		}
+	}
+	return allowed
+}
+
+func (r *ForwardRule) label() string {
+	if r.Child == "" || r.Child == r.Connection {
+		return r.Connection
+	}
+	return r.Connection + "/" + r.Child
+}
+
+type noopBackend struct{}
+
+func (noopBackend) Name() string          { return BackendNone }
+func (noopBackend) Apply(*RuleSet) error  { return nil }
+func (noopBackend) Flush() error          { return nil }
+func (noopBackend) Show() (string, error) { return "firewall management disabled\n", nil }
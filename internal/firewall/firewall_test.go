@@ -0,0 +1,377 @@
+package firewall
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+type recordedCall struct {
+	stdin string
+	args  []string
+}
+
+type fakeRunner struct {
+	failures map[string]bool
+	calls    []recordedCall
+}
+
+func (f *fakeRunner) run(stdin, name string, args ...string) ([]byte, error) {
+	full := append([]string{name}, args...)
+	f.calls = append(f.calls, recordedCall{stdin: stdin, args: full})
+	if f.failures[strings.Join(full, " ")] {
+		return nil, errors.New("failed")
+	}
+	return nil, nil
+}
+
+func (f *fakeRunner) commands() []string {
+	cmds := make([]string, len(f.calls))
+	for i, c := range f.calls {
+		cmds[i] = strings.Join(c.args, " ")
+	}
+	return cmds
+}
+
+func testRuleSet() *RuleSet {
+	return &RuleSet{
+		MasqueradeIface: "tailscale0",
+		MSSClamp:        true,
+		Forward: []ForwardRule{
+			{
+				Connection: "mysite",
+				Child:      "net-net",
+				RemoteSubnets: []netip.Prefix{
+					netip.MustParsePrefix("10.2.0.0/24"),
+					netip.MustParsePrefix("10.3.0.0/24"),
+					netip.MustParsePrefix("fd00:2::/64"),
+				},
+			},
+		},
+	}
+}
+
+func TestRenderNftables(t *testing.T) {
+	script := renderNftables(testRuleSet())
+
+	expected := []string{
+		"table inet tailswan\ndelete table inet tailswan\ntable inet tailswan {",
+		`iifname "tailscale0" ip daddr { 10.2.0.0/24, 10.3.0.0/24 } rt ipsec exists accept comment "mysite/net-net tailnet to ipsec"`,
+		`meta ipsec exists oifname "tailscale0" ip saddr { 10.2.0.0/24, 10.3.0.0/24 } accept comment "mysite/net-net ipsec to tailnet"`,
+		`iifname "tailscale0" ip6 daddr fd00:2::/64 rt ipsec exists accept`,
+		"tcp option maxseg size set rt mtu",
+		`oifname "tailscale0" masquerade`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, script)
+		}
+	}
+
+	if strings.Contains(script, "drop") {
+		t.Errorf("expected no drop rule without DropUnmatched, got:\n%s", script)
+	}
+}
+
+func TestRenderNftablesOptionalChains(t *testing.T) {
+	script := renderNftables(&RuleSet{DropUnmatched: true})
+
+	if strings.Contains(script, "masquerade") {
+		t.Error("expected no masquerade chain")
+	}
+	if strings.Contains(script, "maxseg") {
+		t.Error("expected no MSS clamping chain")
+	}
+	if !strings.Contains(script, `iifname "tailscale0" drop`) {
+		t.Errorf("expected default drop rule, got:\n%s", script)
+	}
+}
+
+func TestRenderNftablesXFRMInterface(t *testing.T) {
+	rs := &RuleSet{
+		TailscaleIface: "ts0",
+		Forward: []ForwardRule{{
+			Connection:    "site",
+			IPsecIface:    "xfrm1",
+			RemoteSubnets: []netip.Prefix{netip.MustParsePrefix("10.9.0.0/16")},
+		}},
+	}
+	script := renderNftables(rs)
+
+	want := `iifname "ts0" oifname "xfrm1" ip daddr 10.9.0.0/16 accept comment "site tailnet to ipsec"`
+	if !strings.Contains(script, want) {
+		t.Errorf("expected script to contain %q, got:\n%s", want, script)
+	}
+	if strings.Contains(script, "ipsec exists") {
+		t.Error("expected no policy match when an XFRM interface is set")
+	}
+}
+
+func TestNftablesApplyIsSingleTransaction(t *testing.T) {
+	f := &fakeRunner{}
+	b := newNftables(f.run)
+
+	if err := b.Apply(testRuleSet()); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+	if err := b.Apply(testRuleSet()); err != nil {
+		t.Fatalf("second Apply() error: %v", err)
+	}
+
+	if len(f.calls) != 2 {
+		t.Fatalf("expected one nft call per apply, got %v", f.commands())
+	}
+	if f.calls[0].stdin != f.calls[1].stdin {
+		t.Error("expected identical scripts for identical rule sets")
+	}
+}
+
+func TestIptablesChains(t *testing.T) {
+	chains := iptablesChains(testRuleSet(), false)
+	if len(chains) != 3 {
+		t.Fatalf("expected 3 chains, got %d", len(chains))
+	}
+
+	forward := chains[0]
+	if forward.name != chainForward || len(forward.rules) != 2 {
+		t.Fatalf("unexpected forward chain %+v", forward)
+	}
+	rule := strings.Join(forward.rules[0], " ")
+	if !strings.Contains(rule, "-d 10.2.0.0/24,10.3.0.0/24 -m policy --dir out --pol ipsec") {
+		t.Errorf("unexpected forward rule %q", rule)
+	}
+
+	v6 := iptablesChains(testRuleSet(), true)
+	if got := strings.Join(v6[0].rules[0], " "); !strings.Contains(got, "fd00:2::/64") {
+		t.Errorf("expected IPv6 prefix in ip6tables rule, got %q", got)
+	}
+
+	empty := iptablesChains(&RuleSet{}, false)
+	for _, c := range empty {
+		if len(c.rules) != 0 {
+			t.Errorf("expected chain %s to be empty, got %v", c.name, c.rules)
+		}
+	}
+}
+
+func TestIptablesApplyInsertsJumpOnce(t *testing.T) {
+	f := &fakeRunner{failures: map[string]bool{
+		"iptables-legacy -t filter -C FORWARD -j TAILSWAN-FORWARD": true,
+	}}
+	b := newIptables(f.run)
+
+	if err := b.Apply(&RuleSet{}); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+
+	var inserts int
+	for _, cmd := range f.commands() {
+		if strings.Contains(cmd, "-I") {
+			inserts++
+			if cmd != "iptables-legacy -t filter -I FORWARD 1 -j TAILSWAN-FORWARD" {
+				t.Errorf("unexpected insert %q", cmd)
+			}
+		}
+	}
+	if inserts != 1 {
+		t.Errorf("expected exactly one jump insert, got %d", inserts)
+	}
+}
+
+func TestIptablesApplyCreatesMissingChain(t *testing.T) {
+	f := &fakeRunner{failures: map[string]bool{
+		"iptables-legacy -t nat -n -L TAILSWAN-POSTROUTING": true,
+	}}
+	b := newIptables(f.run)
+
+	if err := b.Apply(testRuleSet()); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+
+	var created bool
+	for _, cmd := range f.commands() {
+		if cmd == "iptables-legacy -t nat -N TAILSWAN-POSTROUTING" {
+			created = true
+		}
+		if strings.Contains(cmd, "-N TAILSWAN-FORWARD") {
+			t.Error("expected existing chain not to be recreated")
+		}
+	}
+	if !created {
+		t.Error("expected missing chain to be created")
+	}
+}
+
+func TestManagerReconcile(t *testing.T) {
+	f := &fakeRunner{}
+	m := NewManager(newNftables(f.run))
+
+	rs := testRuleSet()
+	if err := m.Reconcile(rs); err != nil {
+		t.Fatalf("Reconcile() error: %v", err)
+	}
+	if m.Current() != rs {
+		t.Error("expected current rule set to be recorded")
+	}
+
+	if err := m.Flush(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	if m.Current() != nil {
+		t.Error("expected current rule set to be cleared after flush")
+	}
+}
+
+func TestManagerReconcileError(t *testing.T) {
+	f := &fakeRunner{failures: map[string]bool{"nft -f -": true}}
+	m := NewManager(newNftables(f.run))
+
+	if err := m.Reconcile(testRuleSet()); err == nil {
+		t.Fatal("expected error from failing backend")
+	}
+	if m.Current() != nil {
+		t.Error("expected no current rule set after failed reconcile")
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	for _, name := range []string{BackendNftables, BackendIptables, BackendNone} {
+		b, err := NewBackend(name)
+		if err != nil {
+			t.Fatalf("NewBackend(%q) error: %v", name, err)
+		}
+		if b.Name() != name {
+			t.Errorf("expected backend %q, got %q", name, b.Name())
+		}
+	}
+
+	if _, err := NewBackend("pf"); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+}
+
+func TestRenderNftablesPerChildMSS(t *testing.T) {
+	rs := testRuleSet()
+	rs.Forward[0].MTU = 1400
+	script := renderNftables(rs)
+
+	for _, want := range []string{
+		"ip daddr { 10.2.0.0/24, 10.3.0.0/24 } tcp flags & (syn | rst) == syn tcp option maxseg size > 1360 tcp option maxseg size set 1360",
+		"ip saddr { 10.2.0.0/24, 10.3.0.0/24 } tcp flags & (syn | rst) == syn tcp option maxseg size > 1360",
+		"ip6 daddr fd00:2::/64 tcp flags & (syn | rst) == syn tcp option maxseg size > 1340 tcp option maxseg size set 1340",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, script)
+		}
+	}
+
+	rs.MSSClamp = false
+	if script := renderNftables(rs); strings.Contains(script, "maxseg") {
+		t.Errorf("expected no MSS rules when clamping is disabled, got:\n%s", script)
+	}
+}
+
+func TestIptablesPerChildMSS(t *testing.T) {
+	rs := testRuleSet()
+	rs.Forward[0].MTU = 1400
+
+	mss := iptablesChains(rs, false)[1]
+	if mss.name != chainMSS || len(mss.rules) != 3 {
+		t.Fatalf("unexpected MSS chain %+v", mss)
+	}
+	got := strings.Join(mss.rules[0], " ")
+	want := "-d 10.2.0.0/24,10.3.0.0/24 -p tcp --tcp-flags SYN,RST SYN -m tcpmss --mss 1361:65535 -m comment --comment mysite/net-net mss -j TCPMSS --set-mss 1360"
+	if got != want {
+		t.Errorf("expected rule %q, got %q", want, got)
+	}
+
+	v6 := iptablesChains(rs, true)[1]
+	if got := strings.Join(v6.rules[0], " "); !strings.Contains(got, "--set-mss 1340") {
+		t.Errorf("expected IPv6 MSS 1340, got %q", got)
+	}
+}
+
+func TestManagerReconcileSkipsUnchanged(t *testing.T) {
+	f := &fakeRunner{}
+	m := NewManager(newNftables(f.run))
+
+	if err := m.Reconcile(testRuleSet()); err != nil {
+		t.Fatalf("Reconcile() error: %v", err)
+	}
+	if err := m.Reconcile(testRuleSet()); err != nil {
+		t.Fatalf("second Reconcile() error: %v", err)
+	}
+	if len(f.calls) != 1 {
+		t.Errorf("expected unchanged rule set to be applied once, got %d calls", len(f.calls))
+	}
+
+	changed := testRuleSet()
+	changed.Forward[0].MTU = 1380
+	if err := m.Reconcile(changed); err != nil {
+		t.Fatalf("Reconcile() error: %v", err)
+	}
+	if len(f.calls) != 2 {
+		t.Errorf("expected changed rule set to be applied, got %d calls", len(f.calls))
+	}
+}
+
+func identityRuleSet() *RuleSet {
+	rs := testRuleSet()
+	rs.Identities = []IdentityRule{
+		{
+			Identity:  "alice@example.com",
+			Addresses: []netip.Addr{netip.MustParseAddr("10.10.0.1"), netip.MustParseAddr("fd00:10::1")},
+			Allowed:   []netip.Prefix{netip.MustParsePrefix("100.64.1.10/32"), netip.MustParsePrefix("10.1.0.0/16")},
+		},
+		{Identity: "bob", Addresses: []netip.Addr{netip.MustParseAddr("10.10.0.2")}},
+	}
+	rs.VirtualIPPools = []netip.Prefix{netip.MustParsePrefix("10.10.0.0/24")}
+	return rs
+}
+
+func TestRenderNftablesIdentities(t *testing.T) {
+	script := renderNftables(identityRuleSet())
+
+	expected := []string{
+		`meta ipsec exists oifname "tailscale0" ip saddr 10.10.0.1 ip daddr { 100.64.1.10/32, 10.1.0.0/16 } accept comment "alice@example.com"`,
+		`meta ipsec exists oifname "tailscale0" ip saddr 10.10.0.1 drop comment "alice@example.com"`,
+		`meta ipsec exists oifname "tailscale0" ip6 saddr fd00:10::1 drop comment "alice@example.com"`,
+		`meta ipsec exists oifname "tailscale0" ip saddr 10.10.0.2 drop comment "bob"`,
+		`meta ipsec exists oifname "tailscale0" ip saddr 10.10.0.0/24 drop comment "virtual IP pools"`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "ip6 saddr fd00:10::1 ip6 daddr") {
+		t.Error("expected no IPv6 accept without IPv6 destinations")
+	}
+	if strings.Index(script, "virtual IP pools") > strings.Index(script, "mysite/net-net") {
+		t.Error("expected the identity rules before the forward rules")
+	}
+}
+
+func TestIptablesIdentities(t *testing.T) {
+	forward := iptablesChains(identityRuleSet(), false)[0]
+	if len(forward.rules) != 6 {
+		t.Fatalf("expected 3 identity, 1 pool and 2 forward rules, got %v", forward.rules)
+	}
+	want := []string{
+		"-o tailscale0 -s 10.10.0.1 -m policy --dir in --pol ipsec -d 100.64.1.10/32,10.1.0.0/16 -m comment --comment alice@example.com -j ACCEPT",
+		"-o tailscale0 -s 10.10.0.1 -m policy --dir in --pol ipsec -m comment --comment alice@example.com -j DROP",
+		"-o tailscale0 -s 10.10.0.2 -m policy --dir in --pol ipsec -m comment --comment bob -j DROP",
+		"-o tailscale0 -s 10.10.0.0/24 -m policy --dir in --pol ipsec -m comment --comment virtual IP pools -j DROP",
+	}
+	for i, w := range want {
+		if got := strings.Join(forward.rules[i], " "); got != w {
+			t.Errorf("rule %d: expected %q, got %q", i, w, got)
+		}
+	}
+
+	v6 := iptablesChains(identityRuleSet(), true)[0]
+	if got := strings.Join(v6.rules[0], " "); got != "-o tailscale0 -s fd00:10::1 -m policy --dir in --pol ipsec -m comment --comment alice@example.com -j DROP" {
+		t.Errorf("unexpected ip6tables identity rule %q", got)
+	}
+}
@@ -0,0 +1,241 @@
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	chainForward     = "TAILSWAN-FORWARD"
+	chainMSS         = "TAILSWAN-MSS"
+	chainPostrouting = "TAILSWAN-POSTROUTING"
+)
+
+// iptablesChain is a TailSwan-owned chain and the built-in chain that jumps
+// into it. Every owned chain is flushed and refilled on each apply, even when
+// empty, and the jump is only inserted when missing, which keeps repeated
+// applies idempotent.
+type iptablesChain struct {
+	table  string
+	parent string
+	name   string
+	rules  [][]string
+}
+
+type iptablesBackend struct {
+	run      runner
+	binaries map[bool]string
+}
+
+func newIptables(run runner) *iptablesBackend {
+	return &iptablesBackend{
+		run: run,
+		binaries: map[bool]string{
+			false: "iptables-legacy",
+			true:  "ip6tables-legacy",
+		},
+	}
+}
+
+func (b *iptablesBackend) Name() string {
+	return BackendIptables
+}
+
+func (b *iptablesBackend) Apply(rs *RuleSet) error {
+	for _, ipv6 := range []bool{false, true} {
+		bin := b.binaries[ipv6]
+		for _, chain := range iptablesChains(rs, ipv6) {
+			if err := b.applyChain(bin, &chain); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *iptablesBackend) applyChain(bin string, c *iptablesChain) error {
+	if _, err := b.run("", bin, "-t", c.table, "-n", "-L", c.name); err != nil {
+		if _, err := b.run("", bin, "-t", c.table, "-N", c.name); err != nil {
+			return fmt.Errorf("create chain %s: %w", c.name, err)
+		}
+	}
+	if _, err := b.run("", bin, "-t", c.table, "-F", c.name); err != nil {
+		return fmt.Errorf("flush chain %s: %w", c.name, err)
+	}
+	for _, rule := range c.rules {
+		args := append([]string{"-t", c.table, "-A", c.name}, rule...)
+		if _, err := b.run("", bin, args...); err != nil {
+			return fmt.Errorf("append to %s: %w", c.name, err)
+		}
+	}
+	if _, err := b.run("", bin, "-t", c.table, "-C", c.parent, "-j", c.name); err != nil {
+		if _, err := b.run("", bin, "-t", c.table, "-I", c.parent, "1", "-j", c.name); err != nil {
+			return fmt.Errorf("jump %s to %s: %w", c.parent, c.name, err)
+		}
+	}
+	return nil
+}
+
+func (b *iptablesBackend) Flush() error {
+	var errs []error
+	for _, ipv6 := range []bool{false, true} {
+		bin := b.binaries[ipv6]
+		for _, c := range iptablesChains(&RuleSet{}, ipv6) {
+			if _, err := b.run("", bin, "-t", c.table, "-n", "-L", c.name); err != nil {
+				continue
+			}
+			for {
+				if _, err := b.run("", bin, "-t", c.table, "-D", c.parent, "-j", c.name); err != nil {
+					break
+				}
+			}
+			if _, err := b.run("", bin, "-t", c.table, "-F", c.name); err != nil {
+				errs = append(errs, err)
+			}
+			if _, err := b.run("", bin, "-t", c.table, "-X", c.name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *iptablesBackend) Show() (string, error) {
+	var out strings.Builder
+	for _, ipv6 := range []bool{false, true} {
+		bin := b.binaries[ipv6]
+		for _, c := range iptablesChains(&RuleSet{}, ipv6) {
+			output, err := b.run("", bin, "-t", c.table, "-S", c.name)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(&out, "# %s -t %s\n%s", bin, c.table, output)
+		}
+	}
+	return out.String(), nil
+}
+
+func iptablesChains(rs *RuleSet, ipv6 bool) []iptablesChain {
+	tsIface := rs.tailscaleIface()
+
+	forward := iptablesChain{table: "filter", parent: "FORWARD", name: chainForward}
+	for i := range rs.Identities {
+		forward.rules = append(forward.rules, iptablesIdentityRules(&rs.Identities[i], tsIface, ipv6)...)
+	}
+	v4, v6 := splitFamilies(rs.VirtualIPPools)
+	pools := v4
+	if ipv6 {
+		pools = v6
+	}
+	if len(pools) > 0 {
+		forward.rules = append(forward.rules, []string{
+			"-o", tsIface, "-s", joinPrefixes(pools), "-m", "policy", "--dir", "in", "--pol", "ipsec",
+			"-m", "comment", "--comment", "virtual IP pools", "-j", "DROP",
+		})
+	}
+	if rs.DropUnmatched {
+		forward.rules = append(forward.rules,
+			[]string{"-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT"})
+	}
+	for i := range rs.Forward {
+		forward.rules = append(forward.rules, iptablesForwardRules(&rs.Forward[i], tsIface, ipv6)...)
+	}
+	if rs.DropUnmatched {
+		forward.rules = append(forward.rules, []string{"-i", tsIface, "-j", "DROP"})
+	}
+
+	mss := iptablesChain{table: "mangle", parent: "FORWARD", name: chainMSS}
+	if rs.MSSClamp {
+		for i := range rs.Forward {
+			mss.rules = append(mss.rules, iptablesMSSRules(&rs.Forward[i], ipv6)...)
+		}
+		mss.rules = append(mss.rules,
+			[]string{"-p", "tcp", "--tcp-flags", "SYN,RST", "SYN", "-j", "TCPMSS", "--clamp-mss-to-pmtu"})
+	}
+
+	postrouting := iptablesChain{table: "nat", parent: "POSTROUTING", name: chainPostrouting}
+	if rs.MasqueradeIface != "" {
+		postrouting.rules = append(postrouting.rules, []string{"-o", rs.MasqueradeIface, "-j", "MASQUERADE"})
+	}
+
+	return []iptablesChain{forward, mss, postrouting}
+}
+
+func iptablesForwardRules(r *ForwardRule, tsIface string, ipv6 bool) [][]string {
+	v4, v6 := splitFamilies(r.RemoteSubnets)
+	prefixes := v4
+	if ipv6 {
+		prefixes = v6
+	}
+	if len(prefixes) == 0 {
+		return nil
+	}
+
+	set := joinPrefixes(prefixes)
+	if r.IPsecIface != "" {
+		return [][]string{
+			{"-i", tsIface, "-o", r.IPsecIface, "-d", set, "-m", "comment", "--comment", r.label() + " tailnet to ipsec", "-j", "ACCEPT"},
+			{"-i", r.IPsecIface, "-o", tsIface, "-s", set, "-m", "comment", "--comment", r.label() + " ipsec to tailnet", "-j", "ACCEPT"},
+		}
+	}
+	return [][]string{
+		{"-i", tsIface, "-d", set, "-m", "policy", "--dir", "out", "--pol", "ipsec", "-m", "comment", "--comment", r.label() + " tailnet to ipsec", "-j", "ACCEPT"},
+		{"-o", tsIface, "-s", set, "-m", "policy", "--dir", "in", "--pol", "ipsec", "-m", "comment", "--comment", r.label() + " ipsec to tailnet", "-j", "ACCEPT"},
+	}
+}
+
+func iptablesIdentityRules(r *IdentityRule, tsIface string, ipv6 bool) [][]string {
+	var rules [][]string
+	for _, addr := range r.Addresses {
+		if addr.Is4() == ipv6 {
+			continue
+		}
+		match := []string{"-o", tsIface, "-s", addr.String(), "-m", "policy", "--dir", "in", "--pol", "ipsec"}
+		if allowed := r.allowed(addr); len(allowed) > 0 {
+			rules = append(rules, append(slices.Clone(match),
+				"-d", joinPrefixes(allowed), "-m", "comment", "--comment", r.Identity, "-j", "ACCEPT"))
+		}
+		rules = append(rules, append(slices.Clone(match), "-m", "comment", "--comment", r.Identity, "-j", "DROP"))
+	}
+	return rules
+}
+
+func iptablesMSSRules(r *ForwardRule, ipv6 bool) [][]string {
+	if r.MTU <= 0 {
+		return nil
+	}
+	v4, v6 := splitFamilies(r.RemoteSubnets)
+	prefixes := v4
+	if ipv6 {
+		prefixes = v6
+	}
+	if len(prefixes) == 0 {
+		return nil
+	}
+
+	set := joinPrefixes(prefixes)
+	mss := strconv.Itoa(r.mss(ipv6))
+	above := strconv.Itoa(r.mss(ipv6)+1) + ":65535"
+	rules := make([][]string, 0, 2)
+	for _, dir := range []string{"-d", "-s"} {
+		rules = append(rules, []string{
+			dir, set, "-p", "tcp", "--tcp-flags", "SYN,RST", "SYN",
+			"-m", "tcpmss", "--mss", above,
+			"-m", "comment", "--comment", r.label() + " mss",
+			"-j", "TCPMSS", "--set-mss", mss,
+		})
+	}
+	return rules
+}
+
+func joinPrefixes(prefixes []netip.Prefix) string {
+	parts := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		parts[i] = p.String()
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,170 @@
+package firewall
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+type nftablesBackend struct {
+	run runner
+}
+
+func newNftables(run runner) *nftablesBackend {
+	return &nftablesBackend{run: run}
+}
+
+func (b *nftablesBackend) Name() string {
+	return BackendNftables
+}
+
+func (b *nftablesBackend) Apply(rs *RuleSet) error {
+	_, err := b.run(renderNftables(rs), "nft", "-f", "-")
+	return err
+}
+
+func (b *nftablesBackend) Flush() error {
+	// Declaring the table before deleting it keeps the delete from failing
+	// when the table does not exist yet.
+	script := fmt.Sprintf("table inet %s\ndelete table inet %s\n", TableName, TableName)
+	_, err := b.run(script, "nft", "-f", "-")
+	return err
+}
+
+func (b *nftablesBackend) Show() (string, error) {
+	output, err := b.run("", "nft", "list", "table", "inet", TableName)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// renderNftables produces a single nft transaction that drops and recreates
+// the TailSwan table, so the kernel only ever sees the declared rule set.
+func renderNftables(rs *RuleSet) string {
+	var b strings.Builder
+	tsIface := rs.tailscaleIface()
+
+	fmt.Fprintf(&b, "table inet %s\n", TableName)
+	fmt.Fprintf(&b, "delete table inet %s\n", TableName)
+	fmt.Fprintf(&b, "table inet %s {\n", TableName)
+
+	b.WriteString("\tchain forward {\n")
+	b.WriteString("\t\ttype filter hook forward priority filter; policy accept;\n")
+	// Identity rules come first, so neither established connections nor
+	// the forward rules let a client past its policy.
+	for i := range rs.Identities {
+		writeNftIdentity(&b, &rs.Identities[i], tsIface)
+	}
+	v4, v6 := splitFamilies(rs.VirtualIPPools)
+	for _, fam := range []struct {
+		keyword  string
+		prefixes []netip.Prefix
+	}{{"ip", v4}, {"ip6", v6}} {
+		if len(fam.prefixes) > 0 {
+			fmt.Fprintf(&b, "\t\tmeta ipsec exists oifname %q %s saddr %s drop comment \"virtual IP pools\"\n",
+				tsIface, fam.keyword, nftSet(fam.prefixes))
+		}
+	}
+	if rs.DropUnmatched {
+		b.WriteString("\t\tct state established,related accept\n")
+	}
+	for i := range rs.Forward {
+		writeNftForward(&b, &rs.Forward[i], tsIface)
+	}
+	if rs.DropUnmatched {
+		fmt.Fprintf(&b, "\t\tiifname %q drop comment \"tailnet default drop\"\n", tsIface)
+	}
+	b.WriteString("\t}\n")
+
+	if rs.MSSClamp {
+		b.WriteString("\tchain mangle_forward {\n")
+		b.WriteString("\t\ttype filter hook forward priority mangle; policy accept;\n")
+		for i := range rs.Forward {
+			writeNftMSS(&b, &rs.Forward[i])
+		}
+		b.WriteString("\t\ttcp flags & (syn | rst) == syn tcp option maxseg size set rt mtu\n")
+		b.WriteString("\t}\n")
+	}
+
+	if rs.MasqueradeIface != "" {
+		b.WriteString("\tchain postrouting {\n")
+		b.WriteString("\t\ttype nat hook postrouting priority srcnat; policy accept;\n")
+		fmt.Fprintf(&b, "\t\toifname %q masquerade\n", rs.MasqueradeIface)
+		b.WriteString("\t}\n")
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func writeNftForward(b *strings.Builder, r *ForwardRule, tsIface string) {
+	v4, v6 := splitFamilies(r.RemoteSubnets)
+	for _, fam := range []struct {
+		keyword  string
+		prefixes []netip.Prefix
+	}{{"ip", v4}, {"ip6", v6}} {
+		if len(fam.prefixes) == 0 {
+			continue
+		}
+		set := nftSet(fam.prefixes)
+		if r.IPsecIface != "" {
+			fmt.Fprintf(b, "\t\tiifname %q oifname %q %s daddr %s accept comment %q\n",
+				tsIface, r.IPsecIface, fam.keyword, set, r.label()+" tailnet to ipsec")
+			fmt.Fprintf(b, "\t\tiifname %q oifname %q %s saddr %s accept comment %q\n",
+				r.IPsecIface, tsIface, fam.keyword, set, r.label()+" ipsec to tailnet")
+			continue
+		}
+		fmt.Fprintf(b, "\t\tiifname %q %s daddr %s rt ipsec exists accept comment %q\n",
+			tsIface, fam.keyword, set, r.label()+" tailnet to ipsec")
+		fmt.Fprintf(b, "\t\tmeta ipsec exists oifname %q %s saddr %s accept comment %q\n",
+			tsIface, fam.keyword, set, r.label()+" ipsec to tailnet")
+	}
+}
+
+func writeNftIdentity(b *strings.Builder, r *IdentityRule, tsIface string) {
+	for _, addr := range r.Addresses {
+		keyword := "ip"
+		if !addr.Is4() {
+			keyword = "ip6"
+		}
+		if allowed := r.allowed(addr); len(allowed) > 0 {
+			fmt.Fprintf(b, "\t\tmeta ipsec exists oifname %q %s saddr %s %s daddr %s accept comment %q\n",
+				tsIface, keyword, addr, keyword, nftSet(allowed), r.Identity)
+		}
+		fmt.Fprintf(b, "\t\tmeta ipsec exists oifname %q %s saddr %s drop comment %q\n",
+			tsIface, keyword, addr, r.Identity)
+	}
+}
+
+func writeNftMSS(b *strings.Builder, r *ForwardRule) {
+	if r.MTU <= 0 {
+		return
+	}
+	v4, v6 := splitFamilies(r.RemoteSubnets)
+	for _, fam := range []struct {
+		keyword  string
+		prefixes []netip.Prefix
+		mss      int
+	}{{"ip", v4, r.mss(false)}, {"ip6", v6, r.mss(true)}} {
+		if len(fam.prefixes) == 0 {
+			continue
+		}
+		set := nftSet(fam.prefixes)
+		for _, dir := range []string{"daddr", "saddr"} {
+			fmt.Fprintf(b, "\t\t%s %s %s tcp flags & (syn | rst) == syn tcp option maxseg size > %d tcp option maxseg size set %d comment %q\n",
+				fam.keyword, dir, set, fam.mss, fam.mss, r.label()+" mss")
+		}
+	}
+}
+
+func nftSet(prefixes []netip.Prefix) string {
+	if len(prefixes) == 1 {
+		return prefixes[0].String()
+	}
+	parts := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		parts[i] = p.String()
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
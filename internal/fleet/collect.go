@@ -0,0 +1,183 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/ha"
+)
+
+const collectTimeout = 5 * time.Second
+
+// Tunnel is a configured connection on a gateway and the state of its
+// IKE_SA, "DOWN" when there is none.
+type Tunnel struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// Site is what the fleet dashboard shows for one gateway.
+type Site struct {
+	HA      *ha.State `json:"ha,omitempty"`
+	Node    Node      `json:"node"`
+	Error   string    `json:"error,omitempty"`
+	Tunnels []Tunnel  `json:"tunnels"`
+	Healthy bool      `json:"healthy"`
+}
+
+// Collector fetches the /api data of every gateway.
+type Collector struct {
+	client   *http.Client
+	baseURL  func(Node) string
+	port     string
+	selfPort string
+}
+
+// NewCollector fetches from peers on port through client, and from this
+// node on selfPort over loopback.
+func NewCollector(client *http.Client, port, selfPort string) *Collector {
+	c := &Collector{client: client, port: port, selfPort: selfPort}
+	c.baseURL = c.defaultBaseURL
+	return c
+}
+
+func (c *Collector) defaultBaseURL(n Node) string {
+	if n.Self {
+		return "http://" + net.JoinHostPort("127.0.0.1", c.selfPort)
+	}
+	// Gateways running with tsnet serve only HTTPS on 443, with a
+	// certificate for their DNS name.
+	if c.port == "443" && n.DNSName != "" {
+		return "https://" + n.DNSName
+	}
+	return "http://" + net.JoinHostPort(n.Addr.String(), c.port)
+}
+
+// Transport is the round tripper used to reach the gateways.
+func (c *Collector) Transport() http.RoundTripper {
+	return c.client.Transport
+}
+
+// BaseURL is where the node's control server is reached.
+func (c *Collector) BaseURL(n Node) string {
+	return c.baseURL(n)
+}
+
+// Collect fetches all nodes concurrently. A node that cannot be reached is
+// reported with an error rather than failing the whole view.
+func (c *Collector) Collect(ctx context.Context, nodes []Node) []Site {
+	sites := make([]Site, len(nodes))
+	var wg sync.WaitGroup
+	for i := range nodes {
+		sites[i] = Site{Node: nodes[i], Tunnels: []Tunnel{}}
+		if !nodes[i].Online {
+			sites[i].Error = "offline"
+			continue
+		}
+		wg.Add(1)
+		go func(site *Site) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+			defer cancel()
+			if err := c.collect(ctx, site); err != nil {
+				site.Error = err.Error()
+			}
+		}(&sites[i])
+	}
+	wg.Wait()
+	return sites
+}
+
+type healthResponse struct {
+	HA      *ha.State `json:"ha"`
+	Success bool      `json:"success"`
+}
+
+type connectionsResponse struct {
+	Connections []map[string]json.RawMessage `json:"connections"`
+}
+
+type sasResponse struct {
+	SAs []map[string]struct {
+		State string `json:"state"`
+	} `json:"sas"`
+}
+
+func (c *Collector) collect(ctx context.Context, site *Site) error {
+	base := c.baseURL(site.Node)
+
+	var health healthResponse
+	if err := c.get(ctx, base+"/api/health", &health); err != nil {
+		return err
+	}
+	site.Healthy = health.Success
+	site.HA = health.HA
+
+	var conns connectionsResponse
+	if err := c.get(ctx, base+"/api/vici/connections/list", &conns); err != nil {
+		return err
+	}
+	var sas sasResponse
+	if err := c.get(ctx, base+"/api/vici/sas/list", &sas); err != nil {
+		return err
+	}
+	site.Tunnels = tunnels(&conns, &sas)
+	return nil
+}
+
+// tunnels joins the configured connections with their IKE_SA states.
+// Established SAs of connections that are not listed are kept too.
+func tunnels(conns *connectionsResponse, sas *sasResponse) []Tunnel {
+	states := make(map[string]string)
+	for _, sa := range sas.SAs {
+		for name, ike := range sa {
+			states[name] = ike.State
+		}
+	}
+
+	seen := make(map[string]bool)
+	result := []Tunnel{}
+	for _, conn := range conns.Connections {
+		for name := range conn {
+			state := states[name]
+			if state == "" {
+				state = "DOWN"
+			}
+			result = append(result, Tunnel{Name: name, State: state})
+			seen[name] = true
+		}
+	}
+	for name, state := range states {
+		if !seen[name] {
+			result = append(result, Tunnel{Name: name, State: state})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func (c *Collector) get(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", req.URL.Path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("GET %s: %w", req.URL.Path, err)
+	}
+	return nil
+}
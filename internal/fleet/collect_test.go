@@ -0,0 +1,97 @@
+package fleet
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func gatewayServer(t *testing.T, health, conns, sas string) *httptest.Server {
+	t.Helper()
+	responses := map[string]string{
+		"/api/health":                health,
+		"/api/vici/connections/list": conns,
+		"/api/vici/sas/list":         sas,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Errorf("write: %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCollect(t *testing.T) {
+	oslo := gatewayServer(t,
+		`{"success":true,"message":"ok","ha":{"mode":"tailnet","node_id":"tailswan-oslo","role":"leader"}}`,
+		`{"success":true,"connections":[{"partner-a":{"loaded":true}},{"partner-b":{"loaded":true}}]}`,
+		`{"success":true,"sas":[{"partner-a":{"state":"ESTABLISHED","child-sas":{}}},{"adhoc":{"state":"CONNECTING"}}]}`,
+	)
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(broken.Close)
+
+	urls := map[string]string{"tailswan-oslo": oslo.URL, "tailswan-broken": broken.URL}
+	c := NewCollector(http.DefaultClient, "8080", "8080")
+	c.baseURL = func(n Node) string { return urls[n.Name] }
+
+	sites := c.Collect(context.Background(), []Node{
+		{Name: "tailswan-oslo", Online: true},
+		{Name: "tailswan-broken", Online: true},
+		{Name: "tailswan-offline"},
+	})
+	if len(sites) != 3 {
+		t.Fatalf("expected 3 sites, got %d", len(sites))
+	}
+
+	site := sites[0]
+	if !site.Healthy || site.Error != "" {
+		t.Fatalf("unexpected site %+v", site)
+	}
+	if site.HA == nil || !site.HA.IsLeader() {
+		t.Errorf("expected the HA role to be collected, got %+v", site.HA)
+	}
+	want := []Tunnel{
+		{Name: "adhoc", State: "CONNECTING"},
+		{Name: "partner-a", State: "ESTABLISHED"},
+		{Name: "partner-b", State: "DOWN"},
+	}
+	if len(site.Tunnels) != len(want) {
+		t.Fatalf("Tunnels = %+v, want %+v", site.Tunnels, want)
+	}
+	for i := range want {
+		if site.Tunnels[i] != want[i] {
+			t.Errorf("Tunnels[%d] = %+v, want %+v", i, site.Tunnels[i], want[i])
+		}
+	}
+
+	if sites[1].Healthy || sites[1].Error == "" {
+		t.Errorf("expected an error for the broken gateway, got %+v", sites[1])
+	}
+	if sites[2].Error != "offline" {
+		t.Errorf("expected the offline gateway to be skipped, got %+v", sites[2])
+	}
+}
+
+func TestDefaultBaseURL(t *testing.T) {
+	c := NewCollector(http.DefaultClient, "8080", "9090")
+	nodes := Discover(testStatus(), Filter{HostnamePrefix: "tailswan-"})
+
+	self, _ := Find(nodes, "tailswan-hq")
+	if got := c.BaseURL(self); got != "http://127.0.0.1:9090" {
+		t.Errorf("self BaseURL = %q", got)
+	}
+	oslo, _ := Find(nodes, "tailswan-oslo")
+	if got := c.BaseURL(oslo); got != "http://100.64.0.2:8080" {
+		t.Errorf("peer BaseURL = %q", got)
+	}
+}
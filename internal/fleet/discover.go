@@ -0,0 +1,97 @@
+package fleet
+
+import (
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+
+	"tailscale.com/ipn/ipnstate"
+)
+
+// Filter selects which tailnet nodes are TailSwan gateways. A node matches
+// when it carries any of the tags or its hostname starts with the prefix.
+type Filter struct {
+	HostnamePrefix string
+	Tags           []string
+}
+
+func (f Filter) Enabled() bool {
+	return f.HostnamePrefix != "" || len(f.Tags) > 0
+}
+
+func (f Filter) Match(hostname string, tags []string) bool {
+	if f.HostnamePrefix != "" && strings.HasPrefix(strings.ToLower(hostname), strings.ToLower(f.HostnamePrefix)) {
+		return true
+	}
+	for _, tag := range f.Tags {
+		if slices.Contains(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Node is a gateway found on the tailnet.
+type Node struct {
+	Addr    netip.Addr `json:"addr"`
+	Name    string     `json:"name"`
+	DNSName string     `json:"dns_name"`
+	Online  bool       `json:"online"`
+	Self    bool       `json:"self"`
+}
+
+// Discover returns the gateways in the peer list that match the filter,
+// including this node, sorted by name.
+func Discover(status *ipnstate.Status, f Filter) []Node {
+	if status == nil || !f.Enabled() {
+		return nil
+	}
+
+	var nodes []Node
+	add := func(peer *ipnstate.PeerStatus, self bool) {
+		if peer == nil {
+			return
+		}
+		var tags []string
+		if peer.Tags != nil {
+			tags = peer.Tags.AsSlice()
+		}
+		if !f.Match(peer.HostName, tags) {
+			return
+		}
+		node := Node{
+			Name:    peer.HostName,
+			DNSName: strings.TrimSuffix(peer.DNSName, "."),
+			Online:  peer.Online || self,
+			Self:    self,
+		}
+		for _, ip := range peer.TailscaleIPs {
+			if ip.Is4() {
+				node.Addr = ip
+				break
+			}
+		}
+		if !node.Addr.IsValid() && len(peer.TailscaleIPs) > 0 {
+			node.Addr = peer.TailscaleIPs[0]
+		}
+		nodes = append(nodes, node)
+	}
+
+	add(status.Self, true)
+	for _, peer := range status.Peer {
+		add(peer, false)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes
+}
+
+// Find returns the node with the given name or DNS name.
+func Find(nodes []Node, name string) (Node, bool) {
+	for _, n := range nodes {
+		if strings.EqualFold(n.Name, name) || strings.EqualFold(n.DNSName, strings.TrimSuffix(name, ".")) {
+			return n, true
+		}
+	}
+	return Node{}, false
+}
@@ -0,0 +1,98 @@
+package fleet
+
+import (
+	"net/netip"
+	"testing"
+
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/types/key"
+	"tailscale.com/types/views"
+)
+
+func peer(hostname, ip string, online bool, tags ...string) *ipnstate.PeerStatus {
+	p := &ipnstate.PeerStatus{
+		HostName:     hostname,
+		DNSName:      hostname + ".example.ts.net.",
+		TailscaleIPs: []netip.Addr{netip.MustParseAddr("fd7a:115c:a1e0::1"), netip.MustParseAddr(ip)},
+		Online:       online,
+	}
+	if len(tags) > 0 {
+		v := views.SliceOf(tags)
+		p.Tags = &v
+	}
+	return p
+}
+
+func testStatus() *ipnstate.Status {
+	return &ipnstate.Status{
+		Self: peer("tailswan-hq", "100.64.0.1", false, "tag:tailswan"),
+		Peer: map[key.NodePublic]*ipnstate.PeerStatus{
+			key.NewNode().Public(): peer("tailswan-oslo", "100.64.0.2", true),
+			key.NewNode().Public(): peer("gw-berlin", "100.64.0.3", true, "tag:tailswan"),
+			key.NewNode().Public(): peer("laptop", "100.64.0.4", true, "tag:dev"),
+		},
+	}
+}
+
+func names(nodes []Node) []string {
+	var result []string
+	for _, n := range nodes {
+		result = append(result, n.Name)
+	}
+	return result
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "disabled", filter: Filter{}, want: nil},
+		{name: "by tag", filter: Filter{Tags: []string{"tag:tailswan"}}, want: []string{"gw-berlin", "tailswan-hq"}},
+		{name: "by hostname prefix", filter: Filter{HostnamePrefix: "TailSwan-"}, want: []string{"tailswan-hq", "tailswan-oslo"}},
+		{
+			name:   "tag or prefix",
+			filter: Filter{Tags: []string{"tag:tailswan"}, HostnamePrefix: "tailswan-"},
+			want:   []string{"gw-berlin", "tailswan-hq", "tailswan-oslo"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := names(Discover(testStatus(), tt.filter))
+			if len(got) != len(tt.want) {
+				t.Fatalf("Discover() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Discover() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiscoverNodeFields(t *testing.T) {
+	nodes := Discover(testStatus(), Filter{Tags: []string{"tag:tailswan"}})
+
+	self, ok := Find(nodes, "tailswan-hq.example.ts.net.")
+	if !ok {
+		t.Fatal("self not found by DNS name")
+	}
+	if !self.Self || !self.Online {
+		t.Errorf("self should be marked self and online, got %+v", self)
+	}
+	if self.Addr != netip.MustParseAddr("100.64.0.1") {
+		t.Errorf("expected the IPv4 address, got %s", self.Addr)
+	}
+
+	berlin, ok := Find(nodes, "GW-Berlin")
+	if !ok || berlin.Self || berlin.DNSName != "gw-berlin.example.ts.net" {
+		t.Errorf("unexpected node %+v", berlin)
+	}
+
+	if _, ok := Find(nodes, "laptop"); ok {
+		t.Error("laptop does not match the filter")
+	}
+}
@@ -0,0 +1,108 @@
+package ha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/statefile"
+)
+
+// Elector decides which instance of a pair is the leader. Campaign is
+// called periodically and reports whether this instance leads until the
+// next call; Resign gives up leadership on shutdown so the other instance
+// can take over without waiting for a timeout.
+type Elector interface {
+	Campaign(ctx context.Context) (bool, error)
+	Resign(ctx context.Context) error
+}
+
+type lease struct {
+	Expires time.Time `json:"expires"`
+	Holder  string    `json:"holder"`
+}
+
+// LeaseElector elects through a lease file both instances can reach, such
+// as a shared volume. The holder renews the lease on every campaign; the
+// other instance takes it once it has expired.
+type LeaseElector struct {
+	now    func() time.Time
+	path   string
+	nodeID string
+	ttl    time.Duration
+}
+
+func NewLeaseElector(path, nodeID string, ttl time.Duration) *LeaseElector {
+	return &LeaseElector{path: path, nodeID: nodeID, ttl: ttl, now: time.Now}
+}
+
+func (e *LeaseElector) Campaign(ctx context.Context) (bool, error) {
+	leader := false
+	err := e.locked(func() error {
+		current, err := e.read()
+		if err != nil {
+			return err
+		}
+		now := e.now()
+		if current.Holder != "" && current.Holder != e.nodeID && now.Before(current.Expires) {
+			return nil
+		}
+		leader = true
+		return e.write(lease{Holder: e.nodeID, Expires: now.Add(e.ttl)})
+	})
+	if err != nil {
+		return false, err
+	}
+	return leader, nil
+}
+
+func (e *LeaseElector) Resign(ctx context.Context) error {
+	return e.locked(func() error {
+		current, err := e.read()
+		if err != nil || current.Holder != e.nodeID {
+			return err
+		}
+		return e.write(lease{})
+	})
+}
+
+// Holder returns the node holding an unexpired lease, or "" if there is
+// none.
+func (e *LeaseElector) Holder() (string, error) {
+	current, err := e.read()
+	if err != nil || !e.now().Before(current.Expires) {
+		return "", err
+	}
+	return current.Holder, nil
+}
+
+// locked serializes the read-check-write of the two instances.
+func (e *LeaseElector) locked(fn func() error) error {
+	return statefile.Locked(e.path, fn)
+}
+
+func (e *LeaseElector) read() (lease, error) {
+	var l lease
+	data, err := os.ReadFile(e.path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(data) == 0) {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+	if err := json.Unmarshal(data, &l); err != nil {
+		return l, fmt.Errorf("parse lease %s: %w", e.path, err)
+	}
+	return l, nil
+}
+
+func (e *LeaseElector) write(l lease) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return statefile.WriteAtomic(e.path, data)
+}
@@ -0,0 +1,113 @@
+package ha
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newLeasePair(t *testing.T) (a, b *LeaseElector, clock *fakeClock) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "lease.json")
+	clock = &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	a = NewLeaseElector(path, "a", 30*time.Second)
+	b = NewLeaseElector(path, "b", 30*time.Second)
+	a.now, b.now = clock.now, clock.now
+	return a, b, clock
+}
+
+func campaign(t *testing.T, e Elector) bool {
+	t.Helper()
+	leader, err := e.Campaign(context.Background())
+	if err != nil {
+		t.Fatalf("Campaign() error = %v", err)
+	}
+	return leader
+}
+
+func TestLeaseElectorFirstCampaignWins(t *testing.T) {
+	a, b, _ := newLeasePair(t)
+
+	if !campaign(t, a) {
+		t.Fatal("a should acquire the free lease")
+	}
+	if campaign(t, b) {
+		t.Fatal("b should not acquire a lease held by a")
+	}
+	if !campaign(t, a) {
+		t.Fatal("a should renew its own lease")
+	}
+
+	holder, err := a.Holder()
+	if err != nil {
+		t.Fatalf("Holder() error = %v", err)
+	}
+	if holder != "a" {
+		t.Errorf("Holder() = %q, want a", holder)
+	}
+}
+
+func TestLeaseElectorFailover(t *testing.T) {
+	a, b, clock := newLeasePair(t)
+
+	campaign(t, a)
+	clock.t = clock.t.Add(20 * time.Second)
+	if campaign(t, b) {
+		t.Fatal("b took over before the lease expired")
+	}
+
+	clock.t = clock.t.Add(11 * time.Second)
+	if !campaign(t, b) {
+		t.Fatal("b should take over the expired lease")
+	}
+	if campaign(t, a) {
+		t.Fatal("a should not win back the lease b renewed")
+	}
+}
+
+func TestLeaseElectorResign(t *testing.T) {
+	a, b, _ := newLeasePair(t)
+
+	campaign(t, a)
+	if err := b.Resign(context.Background()); err != nil {
+		t.Fatalf("Resign() error = %v", err)
+	}
+	if campaign(t, b) {
+		t.Fatal("resign by a non-holder must not release the lease")
+	}
+
+	if err := a.Resign(context.Background()); err != nil {
+		t.Fatalf("Resign() error = %v", err)
+	}
+	if !campaign(t, b) {
+		t.Fatal("b should acquire the lease a resigned")
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	st, err := ReadState(dir)
+	if err != nil || st != nil {
+		t.Fatalf("ReadState() on empty dir = %v, %v; want nil, nil", st, err)
+	}
+
+	want := &State{Mode: ModeLease, NodeID: "a", Role: RoleLeader, Transitions: 1}
+	if err := WriteState(dir, want); err != nil {
+		t.Fatalf("WriteState() error = %v", err)
+	}
+	got, err := ReadState(dir)
+	if err != nil {
+		t.Fatalf("ReadState() error = %v", err)
+	}
+	if *got != *want {
+		t.Errorf("ReadState() = %+v, want %+v", got, want)
+	}
+}
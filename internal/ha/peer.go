@@ -0,0 +1,120 @@
+package ha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PeerElector elects over the tailnet by polling the other instance's
+// /api/ha endpoint. An established leader keeps its role while the peer can
+// see it; when neither leads, the higher priority wins and ties go to the
+// lower node ID. The peer is considered gone once it has not answered with
+// a fresh state for ttl, and the standby then takes over.
+type PeerElector struct {
+	now      func() time.Time
+	client   *http.Client
+	lastSeen time.Time
+	url      string
+	nodeID   string
+	ttl      time.Duration
+	priority int
+	leader   bool
+}
+
+// NewPeerElector returns an elector polling peer, given as host:port or a
+// URL. The client decides how the peer is dialled, e.g. through tailscaled.
+func NewPeerElector(peer, nodeID string, priority int, ttl time.Duration, client *http.Client) *PeerElector {
+	if !strings.Contains(peer, "://") {
+		peer = "http://" + peer
+	}
+	return &PeerElector{
+		url:      strings.TrimSuffix(peer, "/") + "/api/ha",
+		nodeID:   nodeID,
+		priority: priority,
+		ttl:      ttl,
+		client:   client,
+		now:      time.Now,
+	}
+}
+
+func (e *PeerElector) Campaign(ctx context.Context) (bool, error) {
+	now := e.now()
+	if e.lastSeen.IsZero() {
+		// Give the peer one ttl to answer before assuming it is gone, so
+		// a restarted leader does not pre-empt a healthy one.
+		e.lastSeen = now
+	}
+
+	peer, err := e.fetch(ctx)
+	if err == nil && peer != nil && peer.NodeID != "" && peer.Fresh(now, e.ttl) {
+		e.lastSeen = now
+		e.leader = e.decide(peer)
+		return e.leader, nil
+	}
+
+	if now.Sub(e.lastSeen) > e.ttl {
+		e.leader = true
+	}
+	if err != nil {
+		return e.leader, fmt.Errorf("peer %s: %w", e.url, err)
+	}
+	return e.leader, nil
+}
+
+// Resign drops leadership locally. The supervisor publishes the standby
+// role before shutting down, which the peer picks up on its next poll.
+func (e *PeerElector) Resign(ctx context.Context) error {
+	e.leader = false
+	return nil
+}
+
+func (e *PeerElector) decide(peer *State) bool {
+	switch {
+	case peer.NodeID == e.nodeID:
+		// Misconfigured pair pointing at itself; lead rather than stall.
+		return true
+	case e.leader && peer.IsLeader():
+		return e.outranks(peer)
+	case e.leader:
+		return true
+	case peer.IsLeader():
+		return false
+	default:
+		return e.outranks(peer)
+	}
+}
+
+func (e *PeerElector) outranks(peer *State) bool {
+	if e.priority != peer.Priority {
+		return e.priority > peer.Priority
+	}
+	return e.nodeID < peer.NodeID
+}
+
+func (e *PeerElector) fetch(ctx context.Context) (*State, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.ttl/2)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var st State
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
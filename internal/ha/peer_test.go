@@ -0,0 +1,134 @@
+package ha
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakePeer serves a settable State on /api/ha, or a 503 when down.
+type fakePeer struct {
+	state State
+	down  bool
+}
+
+func (p *fakePeer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.down || r.URL.Path != "/api/ha" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(&p.state); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func newPeerElector(t *testing.T, peer *fakePeer, nodeID string, priority int) (*PeerElector, *fakeClock) {
+	t.Helper()
+	srv := httptest.NewServer(peer)
+	t.Cleanup(srv.Close)
+
+	clock := &fakeClock{t: time.Now()}
+	e := NewPeerElector(srv.Listener.Addr().String(), nodeID, priority, 30*time.Second, srv.Client())
+	e.now = clock.now
+	return e, clock
+}
+
+func TestPeerElectorPriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodeID   string
+		peer     State
+		priority int
+		want     bool
+	}{
+		{
+			name:     "higher priority wins when neither leads",
+			nodeID:   "b",
+			priority: 200,
+			peer:     State{NodeID: "a", Role: RoleStandby, Priority: 100},
+			want:     true,
+		},
+		{
+			name:     "lower priority stays standby",
+			nodeID:   "a",
+			priority: 100,
+			peer:     State{NodeID: "b", Role: RoleStandby, Priority: 200},
+			want:     false,
+		},
+		{
+			name:     "equal priority goes to the lower node ID",
+			nodeID:   "a",
+			priority: 100,
+			peer:     State{NodeID: "b", Role: RoleStandby, Priority: 100},
+			want:     true,
+		},
+		{
+			name:     "established leader is not pre-empted",
+			nodeID:   "b",
+			priority: 200,
+			peer:     State{NodeID: "a", Role: RoleLeader, Priority: 100},
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := &fakePeer{state: tt.peer}
+			e, clock := newPeerElector(t, peer, tt.nodeID, tt.priority)
+			peer.state.Updated = clock.t
+
+			if got := campaign(t, e); got != tt.want {
+				t.Errorf("Campaign() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeerElectorSplitBrainResolves(t *testing.T) {
+	peer := &fakePeer{}
+	e, clock := newPeerElector(t, peer, "b", 100)
+	e.leader = true
+
+	peer.state = State{NodeID: "a", Role: RoleLeader, Priority: 100, Updated: clock.t}
+	if campaign(t, e) {
+		t.Fatal("with both leading, b should step down for the lower node ID a")
+	}
+}
+
+func TestPeerElectorTakesOverWhenPeerGone(t *testing.T) {
+	peer := &fakePeer{}
+	e, clock := newPeerElector(t, peer, "b", 100)
+
+	peer.state = State{NodeID: "a", Role: RoleLeader, Priority: 100, Updated: clock.t}
+	if campaign(t, e) {
+		t.Fatal("b should follow the live leader a")
+	}
+
+	peer.down = true
+	clock.t = clock.t.Add(20 * time.Second)
+	if leader, err := e.Campaign(t.Context()); err == nil || leader {
+		t.Fatalf("Campaign() = %v, %v; want standby with an error inside the ttl", leader, err)
+	}
+
+	clock.t = clock.t.Add(11 * time.Second)
+	if leader, err := e.Campaign(t.Context()); err == nil || !leader {
+		t.Fatalf("Campaign() = %v, %v; want leader once the peer is gone for the ttl", leader, err)
+	}
+}
+
+func TestPeerElectorIgnoresStaleState(t *testing.T) {
+	peer := &fakePeer{}
+	e, clock := newPeerElector(t, peer, "b", 100)
+
+	// The peer's control server is up but its supervisor stopped updating.
+	peer.state = State{NodeID: "a", Role: RoleLeader, Priority: 100, Updated: clock.t.Add(-time.Minute)}
+	if campaign(t, e) {
+		t.Fatal("b should wait a ttl before taking over")
+	}
+	clock.t = clock.t.Add(31 * time.Second)
+	if !campaign(t, e) {
+		t.Fatal("b should take over from a peer with stale state")
+	}
+}
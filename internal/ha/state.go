@@ -0,0 +1,74 @@
+package ha
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/statefile"
+)
+
+const (
+	ModeOff     = "off"
+	ModeLease   = "lease"
+	ModeTailnet = "tailnet"
+
+	RoleLeader  = "leader"
+	RoleStandby = "standby"
+
+	stateFile = "ha.json"
+)
+
+// State is what the supervisor publishes about its role. It is written to
+// the state directory for the control server and served to the peer.
+type State struct {
+	Since       time.Time `json:"since"`
+	Updated     time.Time `json:"updated"`
+	Mode        string    `json:"mode"`
+	NodeID      string    `json:"node_id"`
+	Role        string    `json:"role"`
+	Transitions int       `json:"transitions"`
+	Priority    int       `json:"priority"`
+}
+
+func (s *State) IsLeader() bool {
+	return s.Role == RoleLeader
+}
+
+// Fresh reports whether the state was written within ttl, i.e. whether the
+// supervisor that wrote it is still running its election loop.
+func (s *State) Fresh(now time.Time, ttl time.Duration) bool {
+	return now.Sub(s.Updated) <= ttl
+}
+
+func StatePath(stateDir string) string {
+	return filepath.Join(stateDir, stateFile)
+}
+
+func WriteState(stateDir string, st *State) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return statefile.WriteAtomic(StatePath(stateDir), data)
+}
+
+// ReadState returns nil without an error when no state has been written,
+// which is the case when HA is off.
+func ReadState(stateDir string) (*State, error) {
+	data, err := os.ReadFile(StatePath(stateDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", StatePath(stateDir), err)
+	}
+	return &st, nil
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/certs"
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+)
+
+const (
+	certsPrefix       = "/api/certs/"
+	certsPollInterval = time.Hour
+	// maxCredentialSize bounds an uploaded certificate, CRL or key.
+	maxCredentialSize = 1 << 20
+)
+
+// CertHandler manages the credentials in the swanctl directory and loads
+// them into charon.
+type CertHandler struct {
+	store   *certs.Store
+	now     func() time.Time
+	load    func(ctx context.Context, kind, name string) error
+	unload  func(ctx context.Context) error
+	caller  CallerFunc
+	changed chan struct{}
+	expiry  time.Duration
+}
+
+func NewCertHandler(cfg *config.Config, session *vici.Session, caller CallerFunc) *CertHandler {
+	store := certs.NewStore(cfg.Swan.CredentialsDir())
+	return &CertHandler{
+		store:   store,
+		caller:  caller,
+		now:     time.Now,
+		changed: make(chan struct{}, 1),
+		expiry:  cfg.Swan.CertExpiryWarning(),
+		load: func(ctx context.Context, kind, name string) error {
+			return store.Load(ctx, session, kind, name)
+		},
+		unload: func(ctx context.Context) error {
+			return certs.Unload(ctx, session)
+		},
+	}
+}
+
+func (h *CertHandler) list() (*models.CertificatesResponse, error) {
+	creds, err := h.store.List()
+	if err != nil {
+		return nil, err
+	}
+	return &models.CertificatesResponse{
+		Credentials: creds,
+		Expiring:    certs.Expiring(creds, h.now(), h.expiry),
+		Success:     true,
+	}, nil
+}
+
+// List describes the stored certificates, CA certificates, CRLs and keys.
+func (h *CertHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := h.list()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "Failed to list credentials",
+			Error:   err.Error(),
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// Credential inspects (GET), uploads (PUT or POST, with the PEM or DER file
+// as the body) or deletes (DELETE) /api/certs/{kind}/{name}.
+func (h *CertHandler) Credential(w http.ResponseWriter, r *http.Request) {
+	kind, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, certsPrefix), "/")
+	if !ok || kind == "" || name == "" {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid credential path",
+			Error:   "expected " + certsPrefix + "{cert|ca|crl|key}/{name}",
+		})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, kind, name)
+	case http.MethodPut, http.MethodPost:
+		h.put(w, r, kind, name)
+	case http.MethodDelete:
+		h.delete(w, r, kind, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *CertHandler) get(w http.ResponseWriter, kind, name string) {
+	cred, err := h.store.Get(kind, name)
+	if err != nil {
+		respondJSON(w, credentialErrorStatus(err), models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to read %s '%s'", kind, name),
+			Error:   err.Error(),
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, models.CredentialResponse{
+		Credential: cred,
+		Response:   models.Response{Success: true, Message: fmt.Sprintf("%s '%s'", kind, name)},
+	})
+}
+
+func (h *CertHandler) put(w http.ResponseWriter, r *http.Request, kind, name string) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCredentialSize))
+	if err != nil {
+		respondJSON(w, credentialErrorStatus(err), models.Response{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	cred, err := h.store.Put(kind, name, data)
+	if err != nil {
+		respondJSON(w, credentialErrorStatus(err), models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to store %s '%s'", kind, name),
+			Error:   err.Error(),
+		})
+		return
+	}
+	slog.Info("Stored credential", append([]any{"kind", kind, "name", name}, h.caller.attrs(r)...)...)
+	h.notify()
+
+	if err := h.load(r.Context(), kind, name); err != nil {
+		respondJSON(w, http.StatusBadGateway, models.CredentialResponse{
+			Credential: cred,
+			Response: models.Response{
+				Success: false,
+				Message: fmt.Sprintf("Stored %s '%s', but charon did not load it", kind, name),
+				Error:   err.Error(),
+			},
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, models.CredentialResponse{
+		Credential: cred,
+		Response:   models.Response{Success: true, Message: fmt.Sprintf("Loaded %s '%s'", kind, name)},
+	})
+}
+
+func (h *CertHandler) delete(w http.ResponseWriter, r *http.Request, kind, name string) {
+	if err := h.store.Delete(kind, name); err != nil {
+		respondJSON(w, credentialErrorStatus(err), models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to delete %s '%s'", kind, name),
+			Error:   err.Error(),
+		})
+		return
+	}
+	slog.Info("Deleted credential", append([]any{"kind", kind, "name", name}, h.caller.attrs(r)...)...)
+	h.notify()
+
+	if err := h.unload(r.Context()); err != nil {
+		respondJSON(w, http.StatusBadGateway, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Deleted %s '%s', but charon did not reload its credentials", kind, name),
+			Error:   err.Error(),
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, models.Response{
+		Success: true,
+		Message: fmt.Sprintf("Deleted %s '%s'", kind, name),
+	})
+}
+
+func credentialErrorStatus(err error) int {
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.Is(err, certs.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, certs.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.As(err, &maxBytes):
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *CertHandler) notify() {
+	select {
+	case h.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Watch publishes a certs-update event when the credentials or the set of
+// expiring certificates change. The web UI shows the expiring ones as
+// warnings.
+func (h *CertHandler) Watch(ctx context.Context, publisher EventPublisher) {
+	ticker := time.NewTicker(certsPollInterval)
+	defer ticker.Stop()
+
+	var last *models.CertificatesResponse
+	for {
+		resp, err := h.list()
+		if err != nil {
+			slog.Info("Error listing credentials", "error", err)
+		} else if last == nil || !reflect.DeepEqual(resp, last) {
+			last = resp
+			if len(resp.Expiring) > 0 {
+				slog.Warn("Certificates expire soon", "count", len(resp.Expiring), "within", h.expiry)
+			}
+			publisher.Publish("certs-update", resp)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.changed:
+		}
+	}
+}
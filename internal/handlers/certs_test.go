@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+)
+
+var certsNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+type credentialCalls struct {
+	loaded   []string
+	unloaded int
+}
+
+func newTestCertHandler(t *testing.T) (*CertHandler, *credentialCalls) {
+	t.Helper()
+	h := NewCertHandler(&config.Config{
+		Swan: config.SwanConfig{ConfigPath: filepath.Join(t.TempDir(), "swanctl.conf"), CertExpiry: "720h"},
+	}, nil, nil)
+	calls := &credentialCalls{}
+	h.now = func() time.Time { return certsNow }
+	h.load = func(_ context.Context, kind, name string) error {
+		calls.loaded = append(calls.loaded, kind+"/"+name)
+		return nil
+	}
+	h.unload = func(context.Context) error {
+		calls.unloaded++
+		return nil
+	}
+	return h, calls
+}
+
+func selfSignedPEM(t *testing.T, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gw.example.com"},
+		DNSNames:     []string{"gw.example.com"},
+		NotBefore:    certsNow.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func serveCredential(h *CertHandler, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.Credential(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestCertHandler_UploadInspectDelete(t *testing.T) {
+	h, calls := newTestCertHandler(t)
+
+	rec := serveCredential(h, http.MethodPut, "/api/certs/cert/gw.pem", selfSignedPEM(t, certsNow.AddDate(0, 0, 10)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(calls.loaded) != 1 || calls.loaded[0] != "cert/gw.pem" {
+		t.Errorf("expected the certificate to be loaded into charon, got %v", calls.loaded)
+	}
+
+	rec = serveCredential(h, http.MethodGet, "/api/certs/cert/gw.pem", "")
+	var resp models.CredentialResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || resp.Credential == nil || resp.Credential.Subject != "CN=gw.example.com" {
+		t.Fatalf("unexpected inspect response %d %+v", rec.Code, resp)
+	}
+
+	listRec := httptest.NewRecorder()
+	h.List(listRec, httptest.NewRequest(http.MethodGet, "/api/certs", http.NoBody))
+	var list models.CertificatesResponse
+	if err := json.NewDecoder(listRec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Credentials) != 1 || len(list.Expiring) != 1 {
+		t.Errorf("expected one credential expiring within 30 days, got %+v", list)
+	}
+
+	if rec := serveCredential(h, http.MethodDelete, "/api/certs/cert/gw.pem", ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete: expected status 200, got %d", rec.Code)
+	}
+	if calls.unloaded != 1 {
+		t.Errorf("expected charon to reload its credentials, got %d reloads", calls.unloaded)
+	}
+	if rec := serveCredential(h, http.MethodDelete, "/api/certs/cert/gw.pem", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete: expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestCertHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "missing name", method: http.MethodGet, path: "/api/certs/cert/", status: http.StatusBadRequest},
+		{name: "unknown kind", method: http.MethodGet, path: "/api/certs/pubkey/gw.pem", status: http.StatusBadRequest},
+		{name: "not found", method: http.MethodGet, path: "/api/certs/ca/ca.pem", status: http.StatusNotFound},
+		{name: "invalid certificate", method: http.MethodPut, path: "/api/certs/cert/gw.pem", body: "garbage", status: http.StatusBadRequest},
+		{name: "too large", method: http.MethodPut, path: "/api/certs/cert/gw.pem", body: strings.Repeat("A", maxCredentialSize+1), status: http.StatusRequestEntityTooLarge},
+		{name: "method", method: http.MethodPatch, path: "/api/certs/cert/gw.pem", status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, calls := newTestCertHandler(t)
+			if rec := serveCredential(h, tt.method, tt.path, tt.body); rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if len(calls.loaded) != 0 {
+				t.Errorf("nothing should be loaded, got %v", calls.loaded)
+			}
+		})
+	}
+}
+
+func TestCertHandler_LoadFailureKeepsFile(t *testing.T) {
+	h, _ := newTestCertHandler(t)
+	h.load = func(context.Context, string, string) error { return errors.New("vici: connection refused") }
+
+	rec := serveCredential(h, http.MethodPut, "/api/certs/cert/gw.pem", selfSignedPEM(t, certsNow.AddDate(1, 0, 0)))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected status 502, got %d", rec.Code)
+	}
+	if _, err := h.store.Get("cert", "gw.pem"); err != nil {
+		t.Errorf("the stored file should be kept for the next reload: %v", err)
+	}
+}
+
+func TestCertHandler_WatchPublishesChanges(t *testing.T) {
+	h, _ := newTestCertHandler(t)
+	publisher := &recordingPublisher{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Watch(ctx, publisher)
+
+	waitForEvents(t, publisher, 1)
+	serveCredential(h, http.MethodPut, "/api/certs/cert/gw.pem", selfSignedPEM(t, certsNow.AddDate(1, 0, 0)))
+	waitForEvents(t, publisher, 2)
+}
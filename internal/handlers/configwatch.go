@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/confighistory"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/swanload"
+)
+
+const configWatchInterval = 2 * time.Second
+
+// ConfigWatchHandler applies edits of swanctl.conf and its conf.d
+// snippets as they are saved, with SWAN_WATCH. Only the connections,
+// shared secrets and pools that changed are loaded or unloaded, and an
+// edit that does not parse or convert is not applied at all.
+type ConfigWatchHandler struct {
+	history    *HistoryHandler
+	apply      func(ctx context.Context, root *swanconf.Section, changes *swanload.Changes) error
+	last       *models.ConfigWatchEvent
+	configPath string
+	mu         sync.Mutex
+	enabled    bool
+}
+
+func NewConfigWatchHandler(cfg *config.Config, session *vici.Session, history *HistoryHandler) *ConfigWatchHandler {
+	configPath := cfg.Swan.ConfigPath
+	return &ConfigWatchHandler{
+		history:    history,
+		configPath: configPath,
+		enabled:    cfg.Swan.Watch && configPath != "",
+		apply: func(ctx context.Context, root *swanconf.Section, changes *swanload.Changes) error {
+			return swanload.ApplyChanges(ctx, session, root, filepath.Dir(configPath), changes)
+		},
+	}
+}
+
+// Status serves GET /api/config/watch, whether the watcher runs and the
+// outcome of the last edit it picked up.
+func (h *ConfigWatchHandler) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.mu.Lock()
+	last := h.last
+	h.mu.Unlock()
+	respondJSON(w, http.StatusOK, models.ConfigWatchResponse{
+		Response: models.Response{Success: true},
+		Enabled:  h.enabled,
+		Last:     last,
+	})
+}
+
+// configState is what the watcher knows of the files: those last applied,
+// parsed, and those seen at the last poll, which must stay the same for a
+// poll before an edit is applied so half-saved files are not picked up.
+type configState struct {
+	applied  map[string]string
+	root     *swanconf.Section
+	seen     map[string]string
+	rejected map[string]string
+}
+
+// newConfigState takes the files as they are as applied: the supervisor
+// loaded them at startup.
+func (h *ConfigWatchHandler) newConfigState() *configState {
+	s := &configState{}
+	if files, err := confighistory.Capture(h.configPath); err == nil {
+		s.applied, s.seen = files, files
+	}
+	if root, err := swanconf.ParseFile(h.configPath); err == nil {
+		s.root = root
+	}
+	return s
+}
+
+// Watch polls the files and applies an edit once it is saved, publishing
+// the outcome as a config-watch event.
+func (h *ConfigWatchHandler) Watch(ctx context.Context, publisher EventPublisher) {
+	if !h.enabled {
+		return
+	}
+	slog.Info("Watching the swanctl configuration for changes", "path", h.configPath)
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	state := h.newConfigState()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if event := h.poll(ctx, state); event != nil {
+			h.mu.Lock()
+			h.last = event
+			h.mu.Unlock()
+			publisher.Publish("config-watch", event)
+		}
+	}
+}
+
+// poll applies the files if they changed since the last poll and not
+// since, returning the outcome, or nil when there is nothing to apply.
+func (h *ConfigWatchHandler) poll(ctx context.Context, s *configState) *models.ConfigWatchEvent {
+	files, err := confighistory.Capture(h.configPath)
+	if err != nil {
+		slog.Info("Error reading the swanctl configuration", "error", err)
+		return nil
+	}
+	settled := maps.Equal(files, s.seen)
+	s.seen = files
+	if maps.Equal(files, s.applied) || !settled || maps.Equal(files, s.rejected) {
+		return nil
+	}
+
+	event := &models.ConfigWatchEvent{
+		Time:  time.Now().UTC(),
+		Files: changedFiles(s.applied, files),
+	}
+	root, err := swanconf.ParseFile(h.configPath)
+	if err == nil {
+		err = swanload.Check(root, filepath.Dir(h.configPath))
+	}
+	if err != nil {
+		return h.reject(s, event, files, err)
+	}
+
+	// Changes made through the API are loaded and recorded already.
+	if latest, err := h.history.store.Latest(); err == nil && latest != nil && maps.Equal(latest.Files, files) {
+		s.applied, s.root = files, root
+		return nil
+	}
+
+	event.Changes = swanload.Compare(s.root, root)
+	if hasConns(s.root) && !hasConns(root) {
+		return h.reject(s, event, files, errors.New("the edit removes every connection; run tailswan reload to apply it anyway"))
+	}
+	s.applied, s.root, s.rejected = files, root, nil
+
+	event.Status = models.ConfigWatchApplied
+	if err := h.apply(ctx, root, event.Changes); err != nil {
+		event.Status = models.ConfigWatchFailed
+		event.Error = err.Error()
+		slog.Error("Failed to apply the edited swanctl configuration", "files", event.Files, "changes", event.Changes.String(), "error", err)
+	} else {
+		slog.Info("Applied the edited swanctl configuration", "files", event.Files, "changes", event.Changes.String())
+	}
+	if snap := h.history.record(ctx, confighistory.Author{}, confighistory.SourceWatch, "Applied edits: "+event.Changes.String()); snap != nil {
+		event.Version = snap.Version
+	}
+	return event
+}
+
+// reject reports an edit that is not applied, once until the files change
+// again.
+func (h *ConfigWatchHandler) reject(s *configState, event *models.ConfigWatchEvent, files map[string]string, err error) *models.ConfigWatchEvent {
+	s.rejected = files
+	event.Status = models.ConfigWatchInvalid
+	event.Error = err.Error()
+	slog.Warn("Not applying the edited swanctl configuration", "files", event.Files, "error", err)
+	return event
+}
+
+func changedFiles(before, after map[string]string) []string {
+	var names []string
+	for name, content := range after {
+		if old, ok := before[name]; !ok || old != content {
+			names = append(names, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func hasConns(root *swanconf.Section) bool {
+	if root == nil {
+		return false
+	}
+	conns := root.Section("connections")
+	return conns != nil && len(conns.Sections) > 0
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/confighistory"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/swanload"
+)
+
+func TestConfigWatchHandler_Poll(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "swanctl.conf")
+	if err := os.Mkdir(filepath.Join(dir, "conf.d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestConfig(t, configPath, "connections {\n  a {\n    remote_addrs = 198.51.100.1\n  }\n}\ninclude conf.d/*.conf\n")
+	history, _ := newTestHistoryHandler(t, configPath)
+	h := NewConfigWatchHandler(&config.Config{
+		StateDir: t.TempDir(),
+		Swan:     config.SwanConfig{ConfigPath: configPath, Watch: true},
+	}, nil, history)
+	var applied []*swanload.Changes
+	h.apply = func(_ context.Context, _ *swanconf.Section, changes *swanload.Changes) error {
+		applied = append(applied, changes)
+		return nil
+	}
+	state := h.newConfigState()
+	ctx := context.Background()
+
+	if event := h.poll(ctx, state); event != nil {
+		t.Fatalf("expected nothing to apply without an edit, got %+v", event)
+	}
+
+	writeTestConfig(t, configPath, "connections {\n  a {\n    remote_addrs = 198.51.100.10\n  }\n}\ninclude conf.d/*.conf\n")
+	writeTestConfig(t, filepath.Join(dir, "conf.d", "b.conf"), "connections {\n  b {\n    remote_addrs = 198.51.100.2\n  }\n}\n")
+	if event := h.poll(ctx, state); event != nil {
+		t.Fatalf("expected the edit to settle first, got %+v", event)
+	}
+	event := h.poll(ctx, state)
+	if event == nil || event.Status != models.ConfigWatchApplied {
+		t.Fatalf("expected the edit to be applied, got %+v", event)
+	}
+	want := &swanload.Changes{Conns: swanload.Delta{Added: []string{"b"}, Changed: []string{"a"}}}
+	if len(applied) != 1 || !reflect.DeepEqual(applied[0], want) {
+		t.Errorf("expected only a and b to be loaded, got %+v", applied)
+	}
+	if !reflect.DeepEqual(event.Files, []string{"conf.d/b.conf", "swanctl.conf"}) || event.Version != 1 {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if latest, err := history.store.Latest(); err != nil || latest.Source != confighistory.SourceWatch {
+		t.Errorf("expected the edit to be recorded, got %+v, %v", latest, err)
+	}
+
+	for _, content := range []string{
+		"connections {\n  a {\n    remote_addrs = 198.51.100.10\n",
+		"connections {\n}\n",
+	} {
+		writeTestConfig(t, configPath, content)
+		h.poll(ctx, state)
+		event := h.poll(ctx, state)
+		if event == nil || event.Status != models.ConfigWatchInvalid || event.Error == "" {
+			t.Errorf("expected %q to be rejected, got %+v", content, event)
+		}
+		if event := h.poll(ctx, state); event != nil {
+			t.Errorf("expected a rejected edit to be reported once, got %+v", event)
+		}
+	}
+	if len(applied) != 1 {
+		t.Errorf("expected rejected edits not to be applied, got %+v", applied)
+	}
+
+	// A change made through the API is loaded and recorded already.
+	writeTestConfig(t, configPath, "connections {\n  c {\n  }\n}\ninclude conf.d/*.conf\n")
+	recordTestChange(history, "loaded c")
+	h.poll(ctx, state)
+	if event := h.poll(ctx, state); event != nil || len(applied) != 1 {
+		t.Errorf("expected a recorded change not to be applied again, got %+v", event)
+	}
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/diag"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/mtu"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const (
+	maxDiagRuns = 20
+	diagTimeout = 2 * time.Minute
+)
+
+// EventPublisher pushes named events to SSE clients.
+type EventPublisher interface {
+	Publish(event string, v interface{})
+}
+
+type DiagRun struct {
+	Started  time.Time   `json:"started"`
+	Finished *time.Time  `json:"finished,omitempty"`
+	Result   interface{} `json:"result,omitempty"`
+	ID       string      `json:"id"`
+	Kind     string      `json:"kind"`
+	Error    string      `json:"error,omitempty"`
+	Lines    []string    `json:"lines"`
+	Done     bool        `json:"done"`
+}
+
+type diagProgress struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	Line string `json:"line"`
+}
+
+// diagFunc is a prepared diagnostic; validation happens before it is returned
+// so bad requests are rejected synchronously.
+type diagFunc func(ctx context.Context, progress diag.Progress) (interface{}, error)
+
+type DiagHandler struct {
+	session   *vici.Session
+	tsHandler *TailscaleHandler
+	publisher EventPublisher
+	runs      map[string]*DiagRun
+	order     []string
+	mu        sync.Mutex
+}
+
+func NewDiagHandler(session *vici.Session, tsHandler *TailscaleHandler, publisher EventPublisher) *DiagHandler {
+	return &DiagHandler{
+		session:   session,
+		tsHandler: tsHandler,
+		publisher: publisher,
+		runs:      make(map[string]*DiagRun),
+	}
+}
+
+func (h *DiagHandler) Ping(w http.ResponseWriter, r *http.Request) {
+	h.start(w, r, "ping", func(req *models.DiagRequest) (diagFunc, error) {
+		path, err := h.resolveChild(req)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, progress diag.Progress) (interface{}, error) {
+			progress(fmt.Sprintf("Pinging %s via %s/%s", path.Target, path.SA.IKE, path.SA.Name))
+			return diag.Ping(ctx, path.Target, path.Source, req.Count, progress)
+		}, nil
+	})
+}
+
+func (h *DiagHandler) TCP(w http.ResponseWriter, r *http.Request) {
+	h.start(w, r, "tcp", func(req *models.DiagRequest) (diagFunc, error) {
+		if req.Port == 0 {
+			return nil, errors.New("port is required")
+		}
+		path, err := h.resolveChild(req)
+		if err != nil {
+			return nil, err
+		}
+		target := netip.AddrPortFrom(path.Target, req.Port)
+		return func(ctx context.Context, progress diag.Progress) (interface{}, error) {
+			progress(fmt.Sprintf("Connecting to %s via %s/%s", target, path.SA.IKE, path.SA.Name))
+			return diag.TCPConnect(ctx, target, path.Source, 5*time.Second)
+		}, nil
+	})
+}
+
+func (h *DiagHandler) TailscalePing(w http.ResponseWriter, r *http.Request) {
+	h.start(w, r, "tailscale-ping", func(req *models.DiagRequest) (diagFunc, error) {
+		pingType, err := diag.ParsePingType(req.Type)
+		if err != nil {
+			return nil, err
+		}
+		client := h.tsHandler.LocalClient()
+		ip, err := h.resolvePeer(req)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, progress diag.Progress) (interface{}, error) {
+			return diag.TailscalePing(ctx, client, ip, pingType, req.Count, progress)
+		}, nil
+	})
+}
+
+func (h *DiagHandler) XFRM(w http.ResponseWriter, r *http.Request) {
+	h.start(w, r, "xfrm", func(req *models.DiagRequest) (diagFunc, error) {
+		dst, err := netip.ParseAddr(req.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("invalid destination %q: %w", req.Destination, err)
+		}
+		peer, err := h.resolvePeer(req)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, progress diag.Progress) (interface{}, error) {
+			check, err := diag.CheckPeer(ctx, peer, req.Peer, dst)
+			if err != nil {
+				return nil, err
+			}
+			for _, hint := range check.Hints {
+				progress(hint)
+			}
+			if !check.Matched {
+				return check, fmt.Errorf("traffic from %s to %s does not reach an IPsec SA", check.Peer, check.Destination)
+			}
+			progress(fmt.Sprintf("%s -> %s matches policy reqid %d", check.Source, check.Destination, check.Policy.ReqID))
+			return check, nil
+		}, nil
+	})
+}
+
+func (h *DiagHandler) MTU(w http.ResponseWriter, r *http.Request) {
+	h.start(w, r, "mtu", func(req *models.DiagRequest) (diagFunc, error) {
+		path, err := h.resolveChild(req)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, progress diag.Progress) (interface{}, error) {
+			result := mtu.ForChildSA(path.SA)
+			progress(fmt.Sprintf("Calculated tunnel MTU %d (ESP MTU %d, MSS %d)", result.TunnelMTU, result.ESPMTU, result.MSS4))
+
+			low := diag.MinIPv4MTU
+			if path.Target.Is6() {
+				low = diag.MinIPv6MTU
+			}
+			progress(fmt.Sprintf("Probing path MTU to %s", path.Target))
+			pathMTU, err := diag.ProbePathMTU(ctx, path.Target, path.Source, low, result.ESPMTU, diag.SystemPing)
+			report := map[string]interface{}{
+				"calculated": result,
+				"path_mtu":   pathMTU,
+			}
+			if err != nil {
+				return report, err
+			}
+			progress(fmt.Sprintf("Path MTU %d", pathMTU))
+			return report, nil
+		}, nil
+	})
+}
+
+func (h *DiagHandler) Results(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.Lock()
+	runs := make([]DiagRun, 0, len(h.order))
+	for i := len(h.order) - 1; i >= 0; i-- {
+		run := *h.runs[h.order[i]]
+		run.Lines = append([]string(nil), run.Lines...)
+		runs = append(runs, run)
+	}
+	h.mu.Unlock()
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"results": runs,
+	})
+}
+
+func (h *DiagHandler) start(w http.ResponseWriter, r *http.Request, kind string, prepare func(*models.DiagRequest) (diagFunc, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.DiagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	fn, err := prepare(&req)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Cannot run " + kind + " diagnostic",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	run := h.newRun(kind)
+	go h.execute(run.ID, kind, fn)
+
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{
+		"success": true,
+		"message": "Diagnostic started",
+		"id":      run.ID,
+	})
+}
+
+func (h *DiagHandler) execute(id, kind string, fn diagFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), diagTimeout)
+	defer cancel()
+
+	result, err := fn(ctx, func(line string) {
+		h.mu.Lock()
+		if run, ok := h.runs[id]; ok {
+			run.Lines = append(run.Lines, line)
+		}
+		h.mu.Unlock()
+		h.publish("diag-progress", diagProgress{ID: id, Kind: kind, Line: line})
+	})
+
+	h.mu.Lock()
+	run, ok := h.runs[id]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	finished := time.Now()
+	run.Finished = &finished
+	run.Result = result
+	run.Done = true
+	if err != nil {
+		run.Error = err.Error()
+	}
+	snapshot := *run
+	snapshot.Lines = append([]string(nil), run.Lines...)
+	h.mu.Unlock()
+
+	h.publish("diag-result", snapshot)
+}
+
+func (h *DiagHandler) newRun(kind string) *DiagRun {
+	run := &DiagRun{
+		ID:      newRunID(),
+		Kind:    kind,
+		Started: time.Now(),
+		Lines:   []string{},
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.runs[run.ID] = run
+	h.order = append(h.order, run.ID)
+	if len(h.order) > maxDiagRuns {
+		delete(h.runs, h.order[0])
+		h.order = h.order[1:]
+	}
+	return run
+}
+
+func (h *DiagHandler) publish(event string, v interface{}) {
+	if h.publisher != nil {
+		h.publisher.Publish(event, v)
+	}
+}
+
+func (h *DiagHandler) resolveChild(req *models.DiagRequest) (*diag.Path, error) {
+	if req.Connection == "" {
+		return nil, errors.New("connection is required")
+	}
+	if h.session == nil {
+		return nil, errors.New("not connected to charon")
+	}
+	sas, err := viciconn.ChildSAs(h.session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SAs: %w", err)
+	}
+	return diag.ResolveChild(sas, req.Connection, req.Target)
+}
+
+func (h *DiagHandler) resolvePeer(req *models.DiagRequest) (netip.Addr, error) {
+	if req.Peer == "" {
+		return netip.Addr{}, errors.New("peer is required")
+	}
+	status, err := h.tsHandler.LocalClient().Status(context.Background())
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("failed to get Tailscale status: %w", err)
+	}
+	ip, _, err := diag.ResolvePeer(status, req.Peer)
+	return ip, err
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/diag"
+	"github.com/klowdo/tailswan/internal/models"
+)
+
+type recordingPublisher struct {
+	events []string
+	mu     sync.Mutex
+}
+
+func (p *recordingPublisher) Publish(event string, _ interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+}
+
+func (p *recordingPublisher) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.events)
+}
+
+func TestDiagHandler_MethodNotAllowed(t *testing.T) {
+	handler := NewDiagHandler(nil, NewTailscaleHandler(), nil)
+
+	for name, fn := range map[string]http.HandlerFunc{
+		"ping":           handler.Ping,
+		"tcp":            handler.TCP,
+		"tailscale-ping": handler.TailscalePing,
+		"xfrm":           handler.XFRM,
+		"mtu":            handler.MTU,
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			fn(rec, httptest.NewRequest(http.MethodGet, "/api/diag/"+name, http.NoBody))
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+			}
+		})
+	}
+}
+
+func TestDiagHandler_ValidationErrors(t *testing.T) {
+	handler := NewDiagHandler(nil, NewTailscaleHandler(), nil)
+
+	tests := []struct {
+		fn   http.HandlerFunc
+		name string
+		body string
+	}{
+		{name: "invalid json", fn: handler.Ping, body: "{"},
+		{name: "ping without connection", fn: handler.Ping, body: `{}`},
+		{name: "tcp without port", fn: handler.TCP, body: `{"connection":"net-net"}`},
+		{name: "xfrm bad destination", fn: handler.XFRM, body: `{"peer":"laptop","destination":"nope"}`},
+		{name: "tailscale ping bad type", fn: handler.TailscalePing, body: `{"peer":"laptop","type":"udp"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.fn(rec, httptest.NewRequest(http.MethodPost, "/api/diag", bytes.NewBufferString(tt.body)))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+			var resp models.Response
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Success || resp.Error == "" {
+				t.Errorf("expected failure with error, got %+v", resp)
+			}
+		})
+	}
+}
+
+func TestDiagHandler_StreamsResults(t *testing.T) {
+	publisher := &recordingPublisher{}
+	handler := NewDiagHandler(nil, NewTailscaleHandler(), publisher)
+
+	rec := httptest.NewRecorder()
+	handler.start(rec, httptest.NewRequest(http.MethodPost, "/api/diag/test", bytes.NewBufferString(`{}`)), "test",
+		func(*models.DiagRequest) (diagFunc, error) {
+			return func(_ context.Context, progress diag.Progress) (interface{}, error) {
+				progress("step 1")
+				progress("step 2")
+				return nil, errors.New("unreachable")
+			}, nil
+		})
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for publisher.count() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	publisher.mu.Lock()
+	events := append([]string(nil), publisher.events...)
+	publisher.mu.Unlock()
+	if len(events) != 3 || events[0] != "diag-progress" || events[2] != "diag-result" {
+		t.Fatalf("unexpected events %v", events)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.Results(rec, httptest.NewRequest(http.MethodGet, "/api/diag/results", http.NoBody))
+
+	var resp struct {
+		Results []DiagRun `json:"results"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(resp.Results))
+	}
+	run := resp.Results[0]
+	if !run.Done || run.Error != "unreachable" || len(run.Lines) != 2 {
+		t.Errorf("unexpected run %+v", run)
+	}
+}
+
+func TestDiagHandler_BoundsResults(t *testing.T) {
+	handler := NewDiagHandler(nil, NewTailscaleHandler(), nil)
+	for range maxDiagRuns + 5 {
+		handler.newRun("ping")
+	}
+	if len(handler.runs) != maxDiagRuns || len(handler.order) != maxDiagRuns {
+		t.Errorf("expected %d retained runs, got %d", maxDiagRuns, len(handler.runs))
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/swandiff"
+)
+
+// DryRunHandler shows what tailswan reload would change: the swanctl
+// configuration on disk compared with what charon has loaded.
+type DryRunHandler struct {
+	fetch      func(ctx context.Context) (*swandiff.Loaded, error)
+	configPath string
+}
+
+func NewDryRunHandler(cfg *config.Config, session *vici.Session) *DryRunHandler {
+	return &DryRunHandler{
+		configPath: cfg.Swan.ConfigPath,
+		fetch: func(ctx context.Context) (*swandiff.Loaded, error) {
+			return swandiff.Fetch(ctx, session)
+		},
+	}
+}
+
+// DryRun serves GET /api/config/dry-run.
+func (h *DryRunHandler) DryRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.configPath == "" {
+		respondJSON(w, http.StatusServiceUnavailable, models.Response{
+			Success: false,
+			Message: "Dry run is not available",
+			Error:   "SWAN_CONFIG is not set",
+		})
+		return
+	}
+	root, err := swanconf.ParseFile(h.configPath)
+	if err != nil {
+		respondJSON(w, http.StatusUnprocessableEntity, models.Response{
+			Success: false,
+			Message: "Failed to read the swanctl configuration",
+			Error:   err.Error(),
+		})
+		return
+	}
+	loaded, err := h.fetch(r.Context())
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "Failed to read what charon has loaded",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	report := swandiff.Compare(root, loaded)
+	message := "Nothing to change"
+	if !report.Empty() {
+		message = fmt.Sprintf("A reload changes %d connections, %d secrets, %d pools and %d authorities, affecting %d SAs",
+			report.Conns.Len(), report.Secrets.Len(), report.Pools.Len(), report.Authorities.Len(), len(report.Affected))
+	}
+	respondJSON(w, http.StatusOK, models.ConfigDryRunResponse{
+		Response: models.Response{Success: true, Message: message},
+		Report:   report,
+		Text:     report.String(),
+	})
+}
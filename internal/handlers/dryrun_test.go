@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/swandiff"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+func TestDryRunHandler(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "swanctl.conf")
+	writeTestConfig(t, configPath, "connections {\n  a {\n    remote_addrs = 198.51.100.1\n  }\n}\n")
+	auth := []viciconn.Auth{{Class: "any"}}
+	h := &DryRunHandler{
+		configPath: configPath,
+		fetch: func(context.Context) (*swandiff.Loaded, error) {
+			return &swandiff.Loaded{
+				Conns: []viciconn.Conn{{Name: "b", Version: "IKEv1/2", Local: auth, Remote: auth}},
+				SAs:   []viciconn.ChildSA{{IKE: "b", Name: "b", State: "INSTALLED"}},
+			}, nil
+		},
+	}
+
+	rec := servePKI(h.DryRun, http.MethodGet, "/api/config/dry-run", "")
+	var resp models.ConfigDryRunResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || resp.Report == nil {
+		t.Fatalf("expected a report, got %d %+v", rec.Code, resp)
+	}
+	if !reflect.DeepEqual(resp.Report.Conns.Added, []string{"a"}) || !reflect.DeepEqual(resp.Report.Conns.Removed, []string{"b"}) {
+		t.Errorf("unexpected connections %+v", resp.Report.Conns)
+	}
+	if len(resp.Report.Affected) != 1 || resp.Report.Affected[0].Reason != swandiff.ReasonRemoved || resp.Text == "" {
+		t.Errorf("expected the SA of b to be affected, got %+v", resp)
+	}
+
+	h.fetch = func(context.Context) (*swandiff.Loaded, error) {
+		return nil, errors.New("charon is not reachable")
+	}
+	if rec := servePKI(h.DryRun, http.MethodGet, "/api/config/dry-run", ""); rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 without charon, got %d", rec.Code)
+	}
+	writeTestConfig(t, configPath, "connections {\n  a {\n")
+	if rec := servePKI(h.DryRun, http.MethodGet, "/api/config/dry-run", ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a configuration that does not parse, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/fleet"
+	"github.com/klowdo/tailswan/internal/models"
+)
+
+const fleetProxyPrefix = "/api/fleet/proxy/"
+
+// Identity headers. The login and name are the ones Tailscale Serve sets,
+// so a gateway handles a proxied action as if the caller had connected to
+// it directly; Via names the gateway that forwarded it.
+const (
+	headerUserLogin = "Tailscale-User-Login"
+	headerUserName  = "Tailscale-User-Name"
+	headerVia       = "X-TailSwan-Via"
+)
+
+type FleetHandler struct {
+	tsHandler *TailscaleHandler
+	collector *fleet.Collector
+	// discover and whois are replaced in tests.
+	discover func(ctx context.Context) ([]fleet.Node, error)
+	whois    whoisFunc
+	filter   fleet.Filter
+}
+
+func NewFleetHandler(cfg *config.Config, tsHandler *TailscaleHandler) *FleetHandler {
+	h := &FleetHandler{
+		tsHandler: tsHandler,
+		filter: fleet.Filter{
+			Tags:           cfg.Fleet.Tags,
+			HostnamePrefix: cfg.Fleet.HostnamePrefix,
+		},
+	}
+	h.collector = fleet.NewCollector(&http.Client{Transport: h.transport()}, cfg.Fleet.Port, cfg.Port)
+	h.discover = h.discoverPeers
+	h.whois = tailscaleWhois(tsHandler)
+	return h
+}
+
+// transport reaches other gateways through tailscaled or tsnet, so it works
+// with userspace networking, and this gateway over loopback.
+func (h *FleetHandler) transport() *http.Transport {
+	var dialer net.Dialer
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, portStr, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+				return dialer.DialContext(ctx, network, addr)
+			}
+			port, err := strconv.ParseUint(portStr, 10, 16)
+			if err != nil {
+				return nil, err
+			}
+			return h.tsHandler.LocalClient().DialTCP(ctx, host, uint16(port))
+		},
+		ResponseHeaderTimeout: 30 * time.Second,
+	}
+}
+
+func (h *FleetHandler) discoverPeers(ctx context.Context) ([]fleet.Node, error) {
+	status, err := h.tsHandler.LocalClient().Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return fleet.Discover(status, h.filter), nil
+}
+
+// peerIdentity is the tailnet node behind a remote address and the user
+// owning it.
+type peerIdentity struct {
+	login, name string
+	// node is the node's DNS name.
+	node string
+}
+
+// whoisFunc looks up the tailnet node and user behind a remote address.
+type whoisFunc func(ctx context.Context, remoteAddr string) (peerIdentity, error)
+
+func tailscaleWhois(tsHandler *TailscaleHandler) whoisFunc {
+	return func(ctx context.Context, remoteAddr string) (peerIdentity, error) {
+		who, err := tsHandler.LocalClient().WhoIs(ctx, remoteAddr)
+		if err != nil {
+			return peerIdentity{}, err
+		}
+		var peer peerIdentity
+		if who.Node != nil {
+			peer.node = who.Node.Name
+		}
+		if who.UserProfile != nil {
+			peer.login, peer.name = who.UserProfile.LoginName, who.UserProfile.DisplayName
+		}
+		return peer, nil
+	}
+}
+
+// Fleet aggregates the health and tunnels of every discovered gateway.
+func (h *FleetHandler) Fleet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.filter.Enabled() {
+		respondJSON(w, http.StatusOK, models.FleetResponse{
+			Success: true,
+			Sites:   []fleet.Site{},
+		})
+		return
+	}
+
+	nodes, err := h.discover(r.Context())
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "Failed to discover fleet gateways",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.FleetResponse{
+		Success: true,
+		Enabled: true,
+		Sites:   h.collector.Collect(r.Context(), nodes),
+	})
+}
+
+// Proxy forwards /api/fleet/proxy/{gateway}/{path} to /api/{path} on the
+// gateway, carrying the caller's tailnet identity along.
+func (h *FleetHandler) Proxy(w http.ResponseWriter, r *http.Request) {
+	gateway, path, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, fleetProxyPrefix), "/")
+	if !ok || gateway == "" || path == "" {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid fleet proxy path",
+			Error:   "expected " + fleetProxyPrefix + "{gateway}/{api path}",
+		})
+		return
+	}
+	if path == "fleet" || strings.HasPrefix(path, "fleet/") {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Fleet requests cannot be proxied",
+			Error:   "proxying /api/fleet would loop between gateways",
+		})
+		return
+	}
+
+	nodes, err := h.discover(r.Context())
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "Failed to discover fleet gateways",
+			Error:   err.Error(),
+		})
+		return
+	}
+	node, found := fleet.Find(nodes, gateway)
+	if !found {
+		respondJSON(w, http.StatusNotFound, models.Response{
+			Success: false,
+			Message: "Unknown gateway",
+			Error:   "no fleet gateway named " + gateway,
+		})
+		return
+	}
+
+	target, err := url.Parse(h.collector.BaseURL(node))
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "Invalid gateway address",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	caller := h.Caller(r)
+	login, name := caller.Login, caller.Name
+	via := ""
+	if self, ok := selfNode(nodes); ok {
+		via = self.Name
+	}
+	slog.Info("Proxying fleet request", "gateway", node.Name, "method", r.Method, "path", "/api/"+path, "caller", login)
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = target.Scheme
+			pr.Out.URL.Host = target.Host
+			pr.Out.URL.Path = "/api/" + path
+			pr.Out.URL.RawPath = ""
+			pr.Out.Host = target.Host
+			pr.Out.Header.Del(headerUserLogin)
+			pr.Out.Header.Del(headerUserName)
+			pr.Out.Header.Del(headerVia)
+			if login != "" {
+				pr.Out.Header.Set(headerUserLogin, login)
+				pr.Out.Header.Set(headerUserName, name)
+			}
+			if via != "" {
+				pr.Out.Header.Set(headerVia, via)
+			}
+		},
+		Transport: h.collector.Transport(),
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			respondJSON(w, http.StatusBadGateway, models.Response{
+				Success: false,
+				Message: "Gateway " + node.Name + " did not respond",
+				Error:   err.Error(),
+			})
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// Caller is who made a request, as recorded in audit logs, lease owners
+// and configuration history.
+type Caller struct {
+	Login string
+	Name  string
+	// Via is the fleet gateway that forwarded the request.
+	Via string
+}
+
+// CallerFunc identifies who made a request.
+type CallerFunc func(r *http.Request) Caller
+
+// of returns the caller of r, or nobody when f is not set.
+func (f CallerFunc) of(r *http.Request) Caller {
+	if f == nil {
+		return Caller{}
+	}
+	return f(r)
+}
+
+// attrs describes the caller of r for the log.
+func (f CallerFunc) attrs(r *http.Request) []any {
+	c := f.of(r)
+	var attrs []any
+	if c.Login != "" {
+		attrs = append(attrs, "caller", c.Login)
+	}
+	if c.Via != "" {
+		attrs = append(attrs, "via", c.Via)
+	}
+	return attrs
+}
+
+// Caller identifies who made the request. The direct peer is looked up with
+// WhoIs. When it is another fleet gateway, the request was forwarded from
+// its fleet view and the identity headers it set are trusted. Requests
+// arriving over loopback come through Tailscale Serve, which has already set
+// the login and name. Identity headers from anyone else are not trusted.
+func (h *FleetHandler) Caller(r *http.Request) Caller {
+	peer, err := h.whois(r.Context(), r.RemoteAddr)
+	if err == nil {
+		if gateway, ok := h.forwardingGateway(r, peer); ok {
+			via := r.Header.Get(headerVia)
+			if via == "" {
+				via = gateway.Name
+			}
+			return Caller{Login: r.Header.Get(headerUserLogin), Name: r.Header.Get(headerUserName), Via: via}
+		}
+		if peer.login != "" {
+			return Caller{Login: peer.login, Name: peer.name}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return Caller{}
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return Caller{Login: r.Header.Get(headerUserLogin), Name: r.Header.Get(headerUserName)}
+	}
+	return Caller{}
+}
+
+// forwardingGateway returns the fleet gateway peer is, when r carries
+// identity headers. Gateways are only discovered for such requests.
+func (h *FleetHandler) forwardingGateway(r *http.Request, peer peerIdentity) (fleet.Node, bool) {
+	if peer.node == "" || (r.Header.Get(headerUserLogin) == "" && r.Header.Get(headerVia) == "") {
+		return fleet.Node{}, false
+	}
+	nodes, err := h.discover(r.Context())
+	if err != nil {
+		return fleet.Node{}, false
+	}
+	node, found := fleet.Find(nodes, peer.node)
+	if !found || node.Self {
+		return fleet.Node{}, false
+	}
+	return node, true
+}
+
+func selfNode(nodes []fleet.Node) (fleet.Node, bool) {
+	for _, n := range nodes {
+		if n.Self {
+			return n, true
+		}
+	}
+	return fleet.Node{}, false
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/fleet"
+	"github.com/klowdo/tailswan/internal/models"
+)
+
+type proxiedRequest struct {
+	method, path, login, name, via string
+}
+
+// newTestFleetHandler proxies to a backend that records the request. The
+// gateway is reported as this node, so it is reached over loopback.
+func newTestFleetHandler(t *testing.T, whois whoisFunc) (*FleetHandler, *proxiedRequest) {
+	t.Helper()
+	got := &proxiedRequest{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*got = proxiedRequest{
+			method: r.Method,
+			path:   r.URL.Path,
+			login:  r.Header.Get(headerUserLogin),
+			name:   r.Header.Get(headerUserName),
+			via:    r.Header.Get(headerVia),
+		}
+		respondJSON(w, http.StatusOK, models.Response{Success: true, Message: "ok"})
+	}))
+	t.Cleanup(backend.Close)
+
+	_, port, ok := strings.Cut(strings.TrimPrefix(backend.URL, "http://"), ":")
+	if !ok {
+		t.Fatalf("unexpected backend URL %s", backend.URL)
+	}
+	h := &FleetHandler{
+		filter:    fleet.Filter{HostnamePrefix: "tailswan-"},
+		collector: fleet.NewCollector(http.DefaultClient, "8080", port),
+		discover: func(ctx context.Context) ([]fleet.Node, error) {
+			return []fleet.Node{{Name: "tailswan-hq", Online: true, Self: true}}, nil
+		},
+		whois: whois,
+	}
+	return h, got
+}
+
+func noWhois(ctx context.Context, remoteAddr string) (peerIdentity, error) {
+	return peerIdentity{}, errors.New("not a tailnet address")
+}
+
+func TestFleetHandler_ProxyPreservesIdentity(t *testing.T) {
+	h, got := newTestFleetHandler(t, func(ctx context.Context, remoteAddr string) (peerIdentity, error) {
+		return peerIdentity{login: "alice@example.com", name: "Alice", node: "alice-laptop.tail1234.ts.net."}, nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/fleet/proxy/tailswan-hq/vici/connections/up", strings.NewReader(`{"name":"partner-a"}`))
+	req.Header.Set(headerUserLogin, "mallory@example.com")
+	rec := httptest.NewRecorder()
+	h.Proxy(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	want := proxiedRequest{method: http.MethodPost, path: "/api/vici/connections/up", login: "alice@example.com", name: "Alice", via: "tailswan-hq"}
+	if *got != want {
+		t.Errorf("proxied %+v, want %+v", *got, want)
+	}
+}
+
+func TestFleetHandler_ProxyTrustsHeadersOnlyFromServe(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		wantLogin  string
+	}{
+		{name: "via Tailscale Serve on loopback", remoteAddr: "127.0.0.1:40000", wantLogin: "bob@example.com"},
+		{name: "spoofed by a direct client", remoteAddr: "192.0.2.10:40000", wantLogin: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, got := newTestFleetHandler(t, noWhois)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/fleet/proxy/tailswan-hq/health", http.NoBody)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(headerUserLogin, "bob@example.com")
+			rec := httptest.NewRecorder()
+			h.Proxy(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if got.login != tt.wantLogin {
+				t.Errorf("forwarded login %q, want %q", got.login, tt.wantLogin)
+			}
+		})
+	}
+}
+
+func TestFleetHandler_ProxyRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{name: "missing api path", path: "/api/fleet/proxy/tailswan-hq", status: http.StatusBadRequest},
+		{name: "fleet loop", path: "/api/fleet/proxy/tailswan-hq/fleet", status: http.StatusBadRequest},
+		{name: "unknown gateway", path: "/api/fleet/proxy/laptop/health", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, got := newTestFleetHandler(t, noWhois)
+			rec := httptest.NewRecorder()
+			h.Proxy(rec, httptest.NewRequest(http.MethodGet, tt.path, http.NoBody))
+
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if got.path != "" {
+				t.Errorf("request should not have been proxied, got %+v", *got)
+			}
+		})
+	}
+}
+
+func TestFleetHandler_Caller(t *testing.T) {
+	peers := map[string]peerIdentity{
+		// A gateway is owned by whoever tagged it, not by the user whose
+		// action it forwards.
+		"100.64.0.2:40000": {login: "tagged-devices", node: "tailswan-branch.tail1234.ts.net."},
+		"100.64.0.3:40000": {login: "mallory@example.com", name: "Mallory", node: "mallory-laptop.tail1234.ts.net."},
+	}
+	h := &FleetHandler{
+		discover: func(ctx context.Context) ([]fleet.Node, error) {
+			return []fleet.Node{
+				{Name: "tailswan-branch", DNSName: "tailswan-branch.tail1234.ts.net", Online: true},
+				{Name: "tailswan-hq", DNSName: "tailswan-hq.tail1234.ts.net", Online: true, Self: true},
+			}, nil
+		},
+		whois: func(ctx context.Context, remoteAddr string) (peerIdentity, error) {
+			peer, ok := peers[remoteAddr]
+			if !ok {
+				return peerIdentity{}, errors.New("not a tailnet address")
+			}
+			return peer, nil
+		},
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       Caller
+		forwarded  bool
+	}{
+		{
+			name:       "forwarded by a fleet gateway",
+			remoteAddr: "100.64.0.2:40000",
+			forwarded:  true,
+			want:       Caller{Login: "alice@example.com", Name: "Alice", Via: "tailswan-branch"},
+		},
+		{
+			name:       "forwarded headers from another tailnet node",
+			remoteAddr: "100.64.0.3:40000",
+			forwarded:  true,
+			want:       Caller{Login: "mallory@example.com", Name: "Mallory"},
+		},
+		{
+			name:       "gateway acting on its own",
+			remoteAddr: "100.64.0.2:40000",
+			want:       Caller{Login: "tagged-devices"},
+		},
+		{
+			name:       "Tailscale Serve on loopback",
+			remoteAddr: "127.0.0.1:40000",
+			forwarded:  true,
+			want:       Caller{Login: "alice@example.com", Name: "Alice"},
+		},
+		{
+			name:       "spoofed by a direct client",
+			remoteAddr: "192.0.2.10:40000",
+			forwarded:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/vici/connections/up", http.NoBody)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded {
+				req.Header.Set(headerUserLogin, "alice@example.com")
+				req.Header.Set(headerUserName, "Alice")
+				req.Header.Set(headerVia, "tailswan-branch")
+			}
+			if got := h.Caller(req); got != tt.want {
+				t.Errorf("Caller() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFleetHandler_FleetDisabled(t *testing.T) {
+	h := &FleetHandler{}
+	rec := httptest.NewRecorder()
+	h.Fleet(rec, httptest.NewRequest(http.MethodGet, "/api/fleet", http.NoBody))
+
+	var resp models.FleetResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Success || resp.Enabled || resp.Sites == nil || len(resp.Sites) != 0 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/ha"
+	"github.com/klowdo/tailswan/internal/models"
+)
+
+const haPollInterval = 5 * time.Second
+
+// HAHandler serves the leadership state the supervisor writes to the state
+// directory. The peer instance polls it over the tailnet to run the
+// election.
+type HAHandler struct {
+	stateDir string
+	mode     string
+	enabled  bool
+}
+
+func NewHAHandler(cfg *config.Config) *HAHandler {
+	return &HAHandler{
+		stateDir: cfg.StateDir,
+		mode:     cfg.HA.Mode,
+		enabled:  cfg.HA.Enabled(),
+	}
+}
+
+// State returns nil when HA is off.
+func (h *HAHandler) State() (*ha.State, error) {
+	if h == nil || !h.enabled {
+		return nil, nil
+	}
+	st, err := ha.ReadState(h.stateDir)
+	if err != nil || st != nil {
+		return st, err
+	}
+	// The supervisor has not elected yet.
+	return &ha.State{Mode: h.mode, Role: ha.RoleStandby}, nil
+}
+
+func (h *HAHandler) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	st, err := h.State()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "Failed to read HA state",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if st == nil {
+		st = &ha.State{Mode: ha.ModeOff}
+	}
+	respondJSON(w, http.StatusOK, st)
+}
+
+// Watch publishes an ha-update event whenever the role changes.
+func (h *HAHandler) Watch(ctx context.Context, publisher EventPublisher) {
+	if !h.enabled {
+		return
+	}
+
+	ticker := time.NewTicker(haPollInterval)
+	defer ticker.Stop()
+
+	var last ha.State
+	for {
+		st, err := h.State()
+		if err != nil {
+			slog.Info("Error reading HA state", "error", err)
+		} else if st.Role != last.Role || st.Transitions != last.Transitions {
+			last = *st
+			publisher.Publish("ha-update", st)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
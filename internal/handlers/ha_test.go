@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/ha"
+	"github.com/klowdo/tailswan/internal/models"
+)
+
+func newTestHAHandler(t *testing.T, mode string, st *ha.State) *HAHandler {
+	t.Helper()
+	cfg := &config.Config{StateDir: t.TempDir(), HA: config.HAConfig{Mode: mode}}
+	if st != nil {
+		if err := ha.WriteState(cfg.StateDir, st); err != nil {
+			t.Fatalf("WriteState() error = %v", err)
+		}
+	}
+	return NewHAHandler(cfg)
+}
+
+func TestHAHandler_Status(t *testing.T) {
+	tests := []struct {
+		state    *ha.State
+		name     string
+		mode     string
+		wantMode string
+		wantRole string
+	}{
+		{name: "off", mode: "off", wantMode: ha.ModeOff},
+		{name: "not elected yet", mode: ha.ModeTailnet, wantMode: ha.ModeTailnet, wantRole: ha.RoleStandby},
+		{
+			name:     "leader",
+			mode:     ha.ModeLease,
+			state:    &ha.State{Mode: ha.ModeLease, NodeID: "a", Role: ha.RoleLeader, Transitions: 1},
+			wantMode: ha.ModeLease,
+			wantRole: ha.RoleLeader,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestHAHandler(t, tt.mode, tt.state)
+			rec := httptest.NewRecorder()
+			handler.Status(rec, httptest.NewRequest(http.MethodGet, "/api/ha", http.NoBody))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			var st ha.State
+			if err := json.NewDecoder(rec.Body).Decode(&st); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if st.Mode != tt.wantMode || st.Role != tt.wantRole {
+				t.Errorf("got mode %q role %q, want %q %q", st.Mode, st.Role, tt.wantMode, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestHealthHandler_IncludesHA(t *testing.T) {
+	haHandler := newTestHAHandler(t, ha.ModeLease, &ha.State{Mode: ha.ModeLease, NodeID: "b", Role: ha.RoleStandby})
+	rec := httptest.NewRecorder()
+	NewHealthHandler(&config.Config{}, nil, haHandler, nil).Check(rec, httptest.NewRequest(http.MethodGet, "/api/health", http.NoBody))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("a standby must report healthy, got status %d", rec.Code)
+	}
+	var resp models.HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Success || resp.HA == nil || resp.HA.Role != ha.RoleStandby || resp.HA.NodeID != "b" {
+		t.Errorf("unexpected health response %+v", resp)
+	}
+}
+
+func TestHAHandler_WatchPublishesChanges(t *testing.T) {
+	handler := newTestHAHandler(t, ha.ModeLease, &ha.State{Mode: ha.ModeLease, NodeID: "a", Role: ha.RoleLeader, Transitions: 1})
+	publisher := &recordingPublisher{}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go handler.Watch(ctx, publisher)
+
+	deadline := time.Now().Add(time.Second)
+	for publisher.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if publisher.count() != 1 {
+		t.Fatalf("expected one ha-update event, got %d", publisher.count())
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		state *ha.State
+		name  string
+		mode  string
+		want  []string
+	}{
+		{
+			name: "ha off",
+			mode: "off",
+			want: []string{"tailswan_ha_enabled 0\n"},
+		},
+		{
+			name:  "leader",
+			mode:  ha.ModeTailnet,
+			state: &ha.State{Mode: ha.ModeTailnet, NodeID: "a", Role: ha.RoleLeader, Transitions: 3, Since: since},
+			want: []string{
+				"tailswan_ha_enabled 1\n",
+				`tailswan_ha_leader{mode="tailnet",node_id="a"} 1` + "\n",
+				`tailswan_ha_transitions_total{mode="tailnet",node_id="a"} 3` + "\n",
+				`tailswan_ha_role_since_timestamp_seconds{mode="tailnet",node_id="a"} 1767225600` + "\n",
+				"# TYPE tailswan_ha_transitions_total counter\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewMetricsHandler(newTestHAHandler(t, tt.mode, tt.state), nil)
+			rec := httptest.NewRecorder()
+			handler.Metrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			body := rec.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("metrics missing %q:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
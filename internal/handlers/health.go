@@ -1,20 +1,102 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/health"
 	"github.com/klowdo/tailswan/internal/models"
 )
 
-type HealthHandler struct{}
+type HealthHandler struct {
+	ts         *TailscaleHandler
+	ha         *HAHandler
+	tunnels    *TunnelHealthHandler
+	stateDir   string
+	credsDir   string
+	certExpiry time.Duration
+}
 
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+// NewHealthHandler reports the HA role alongside the health when ha is
+// non-nil and HA is enabled. A standby is healthy. The watchdog's tunnel
+// probes are reported too, but do not make the control server unhealthy.
+// The live and ready checks reach tailscaled through ts, so they follow
+// the switch to tsnet's client.
+func NewHealthHandler(cfg *config.Config, ts *TailscaleHandler, ha *HAHandler, tunnels *TunnelHealthHandler) *HealthHandler {
+	return &HealthHandler{
+		ts:         ts,
+		ha:         ha,
+		tunnels:    tunnels,
+		stateDir:   cfg.StateDir,
+		credsDir:   cfg.Swan.CredentialsDir(),
+		certExpiry: cfg.Swan.CertExpiryWarning(),
+	}
 }
 
 func (h *HealthHandler) Check(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, models.Response{
-		Success: true,
-		Message: "TailSwan control server is healthy",
-	})
+	resp := models.HealthResponse{
+		Response: models.Response{
+			Success: true,
+			Message: "TailSwan control server is healthy",
+		},
+	}
+	// A missing HA state is not a health problem.
+	if st, err := h.ha.State(); err == nil {
+		resp.HA = st
+	}
+	if st, err := h.tunnels.State(); err == nil && st != nil {
+		resp.Tunnels = st.Tunnels
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func (h *HealthHandler) checker() *health.Checker {
+	c := &health.Checker{StateDir: h.stateDir, CredentialsDir: h.credsDir, CertExpiry: h.certExpiry}
+	if h.ts != nil {
+		c.Tailscale = h.ts.LocalClient()
+	}
+	return c
+}
+
+// Live answers 503 when charon does not answer or a supervised process
+// died, which is worth restarting the gateway for.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	respondReport(w, "live", h.checker().Live(r.Context()))
+}
+
+// Ready answers 503 until the gateway can carry traffic. The connections
+// that must be established are given as ?require_connections=a,b.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	var required []string
+	for _, name := range strings.Split(r.URL.Query().Get("require_connections"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			required = append(required, name)
+		}
+	}
+	respondReport(w, "ready", h.checker().Ready(r.Context(), required))
+}
+
+func respondReport(w http.ResponseWriter, what string, report *health.Report) {
+	resp := models.ComponentHealthResponse{
+		Components: report.Components,
+		Response: models.Response{
+			Success: true,
+			Message: "TailSwan is " + what,
+		},
+	}
+	if !report.Healthy {
+		var names []string
+		for _, c := range report.Failed() {
+			names = append(names, c.Name)
+		}
+		resp.Success = false
+		resp.Message = "TailSwan is not " + what
+		resp.Error = fmt.Sprintf("failing: %s", strings.Join(names, ", "))
+		respondJSON(w, http.StatusServiceUnavailable, resp)
+		return
+	}
+	respondJSON(w, http.StatusOK, resp)
 }
@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/health"
 	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/supervisor"
 )
 
 func TestHealthHandler_Check(t *testing.T) {
@@ -38,7 +42,7 @@ func TestHealthHandler_Check(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			handler := NewHealthHandler()
+			handler := NewHealthHandler(&config.Config{}, nil, nil, nil)
 			req := httptest.NewRequest(tt.method, "/health", http.NoBody)
 			rec := httptest.NewRecorder()
 
@@ -70,8 +74,65 @@ func TestHealthHandler_Check(t *testing.T) {
 }
 
 func TestNewHealthHandler(t *testing.T) {
-	handler := NewHealthHandler()
+	handler := NewHealthHandler(&config.Config{}, nil, nil, nil)
 	if handler == nil {
 		t.Error("expected non-nil handler")
 	}
 }
+
+func TestHealthHandler_Ready(t *testing.T) {
+	stateDir := t.TempDir()
+	supervisor.NewHistory(stateDir).Record("ipsec", "started", os.Getpid(), nil)
+	handler := NewHealthHandler(&config.Config{StateDir: stateDir}, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	handler.Ready(rec, httptest.NewRequest(http.MethodGet, "/api/health/ready?require_connections=hq,+lab", http.NoBody))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while the required connections are down, got %d", rec.Code)
+	}
+	var resp models.ComponentHealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Success {
+		t.Error("expected Success false")
+	}
+
+	statuses := make(map[string]health.Component)
+	for _, c := range resp.Components {
+		statuses[c.Name] = c
+	}
+	want := map[string]string{
+		health.ComponentTailscale: health.StatusSkip,
+		health.ComponentRoutes:    health.StatusSkip,
+		health.ComponentChildren:  health.StatusFail,
+		health.ComponentProcesses: health.StatusOK,
+	}
+	for name, status := range want {
+		if got := statuses[name]; got.Status != status || got.Reason == "" {
+			t.Errorf("component %s = %+v, want status %s with a reason", name, got, status)
+		}
+	}
+	if _, ok := statuses[health.ComponentVICI]; !ok {
+		t.Error("expected a vici component")
+	}
+}
+
+func TestHealthHandler_LiveWithoutSupervisor(t *testing.T) {
+	handler := NewHealthHandler(&config.Config{StateDir: t.TempDir()}, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	handler.Live(rec, httptest.NewRequest(http.MethodGet, "/api/health/live", http.NoBody))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without supervised processes, got %d", rec.Code)
+	}
+	var resp models.ComponentHealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Components) != 2 {
+		t.Errorf("expected the vici and processes components, got %+v", resp.Components)
+	}
+}
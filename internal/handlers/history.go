@@ -0,0 +1,445 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+	"tailscale.com/ipn"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/confighistory"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/swanload"
+)
+
+const (
+	historyPrefix       = "/api/config/history/"
+	pendingPollInterval = time.Second
+	maxHistoryRequest   = 4 << 10
+)
+
+// tailswanAuthor is the author of the reverts of changes not confirmed in
+// time.
+var tailswanAuthor = confighistory.Author{Name: "tailswan"}
+
+// HistoryHandler records configuration changes made through the API and
+// serves the history, diffs between versions and rollbacks.
+type HistoryHandler struct {
+	store     *confighistory.Store
+	caller    CallerFunc
+	routes    func(ctx context.Context) ([]netip.Prefix, error)
+	setRoutes func(ctx context.Context, routes []netip.Prefix) error
+	apply     func(ctx context.Context) error
+	now       func() time.Time
+	changed   chan struct{}
+	// configPath is SWAN_CONFIG; without it nothing is recorded.
+	configPath string
+}
+
+func NewHistoryHandler(cfg *config.Config, session *vici.Session, tsHandler *TailscaleHandler, caller CallerFunc) *HistoryHandler {
+	configPath := cfg.Swan.ConfigPath
+	return &HistoryHandler{
+		store:      confighistory.NewStore(cfg.StateDir),
+		caller:     caller,
+		now:        time.Now,
+		changed:    make(chan struct{}, 1),
+		configPath: configPath,
+		routes: func(ctx context.Context) ([]netip.Prefix, error) {
+			prefs, err := tsHandler.LocalClient().GetPrefs(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return prefs.AdvertiseRoutes, nil
+		},
+		setRoutes: func(ctx context.Context, routes []netip.Prefix) error {
+			_, err := tsHandler.LocalClient().EditPrefs(ctx, &ipn.MaskedPrefs{
+				Prefs:              ipn.Prefs{AdvertiseRoutes: routes},
+				AdvertiseRoutesSet: true,
+			})
+			return err
+		},
+		apply: func(ctx context.Context) error {
+			root, err := swanconf.ParseFile(configPath)
+			if err != nil {
+				return err
+			}
+			return swanload.Apply(ctx, session, root, filepath.Dir(configPath))
+		},
+	}
+}
+
+func (h *HistoryHandler) target() confighistory.Target {
+	return confighistory.Target{ConfigPath: h.configPath, Apply: h.apply, SetRoutes: h.setRoutes}
+}
+
+// advertised returns the advertised routes, or nil when they are unknown.
+func (h *HistoryHandler) advertised(ctx context.Context) []string {
+	prefixes, err := h.routes(ctx)
+	if err != nil {
+		return nil
+	}
+	return confighistory.Routes(prefixes)
+}
+
+func (h *HistoryHandler) author(r *http.Request) confighistory.Author {
+	c := h.caller.of(r)
+	return confighistory.Author{Login: c.Login, Name: c.Name}
+}
+
+func (h *HistoryHandler) notify() {
+	select {
+	case h.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Begin puts the change r is about to make on trial for seconds, so it is
+// reverted unless confirmed in time. On failure it returns the status to
+// respond with.
+func (h *HistoryHandler) Begin(r *http.Request, seconds int, message string) (*confighistory.Pending, int, error) {
+	if h.configPath == "" {
+		return nil, http.StatusServiceUnavailable, errors.New("SWAN_CONFIG is not set")
+	}
+	p, err := h.store.Begin(h.configPath, h.advertised(r.Context()), time.Duration(seconds)*time.Second, h.author(r), message)
+	switch {
+	case errors.Is(err, confighistory.ErrPending):
+		return nil, http.StatusConflict, err
+	case errors.Is(err, confighistory.ErrInvalidTimeout):
+		return nil, http.StatusBadRequest, err
+	case err != nil:
+		return nil, http.StatusInternalServerError, err
+	}
+	h.notify()
+	return p.Summary(), http.StatusOK, nil
+}
+
+// Cancel takes back a change on trial that failed and was undone.
+func (h *HistoryHandler) Cancel() {
+	if err := h.store.Cancel(); err != nil {
+		slog.Warn("Failed to cancel the pending change", "error", err)
+	}
+	h.notify()
+}
+
+// Record snapshots the configuration after a change made by r. A failure
+// is logged rather than failing the change, which has been made already.
+func (h *HistoryHandler) Record(r *http.Request, source, message string) {
+	h.record(r.Context(), h.author(r), source, message)
+}
+
+func (h *HistoryHandler) record(ctx context.Context, author confighistory.Author, source, message string) *confighistory.Snapshot {
+	if h.configPath == "" {
+		return nil
+	}
+	// Unknown routes are carried over from the previous version.
+	snap, err := h.store.RecordConfig(h.configPath, h.advertised(ctx), author, source, message)
+	if err != nil {
+		slog.Warn("Failed to record the configuration change", "error", err)
+		return nil
+	}
+	if snap != nil {
+		slog.Info("Recorded configuration version", "version", snap.Version, "source", source, "author", snap.Author.String())
+	}
+	return snap
+}
+
+// History serves GET /api/config/history.
+func (h *HistoryHandler) History(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	versions, err := h.store.List()
+	if err != nil {
+		respondHistoryError(w, "Failed to read the configuration history", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, models.ConfigHistoryResponse{Success: true, Versions: versions})
+}
+
+// Version serves GET /api/config/history/{version}, GET
+// /api/config/history/{version}/diff?from={version} and POST
+// /api/config/history/{version}/rollback.
+func (h *HistoryHandler) Version(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, historyPrefix)
+	v, action, _ := strings.Cut(rest, "/")
+	version, err := strconv.Atoi(v)
+	if err != nil || version < 1 {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid version",
+			Error:   fmt.Sprintf("invalid version %q", v),
+		})
+		return
+	}
+
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		snap, err := h.store.Get(version)
+		if err != nil {
+			respondHistoryError(w, fmt.Sprintf("Failed to read version %d", version), err)
+			return
+		}
+		respondJSON(w, http.StatusOK, snap.Redacted())
+	case "diff":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.diff(w, r, version)
+	case "rollback":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.rollback(w, r, version)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// diff diffs version against the version given by from, by default the
+// one before it.
+func (h *HistoryHandler) diff(w http.ResponseWriter, r *http.Request, version int) {
+	to, err := h.store.Get(version)
+	if err != nil {
+		respondHistoryError(w, fmt.Sprintf("Failed to read version %d", version), err)
+		return
+	}
+	var from *confighistory.Snapshot
+	if f := r.URL.Query().Get("from"); f != "" {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, models.Response{
+				Success: false,
+				Message: "Invalid version",
+				Error:   fmt.Sprintf("invalid version %q", f),
+			})
+			return
+		}
+		from, err = h.store.Get(n)
+	} else {
+		from, err = h.store.Previous(version)
+	}
+	if err != nil {
+		respondHistoryError(w, "Failed to read the version to diff against", err)
+		return
+	}
+
+	resp := models.ConfigDiffResponse{
+		Response: models.Response{Success: true},
+		Diff:     confighistory.Diff(from, to),
+		To:       version,
+	}
+	if from != nil {
+		resp.From = from.Version
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func (h *HistoryHandler) rollback(w http.ResponseWriter, r *http.Request, version int) {
+	if h.configPath == "" {
+		respondJSON(w, http.StatusServiceUnavailable, models.Response{
+			Success: false,
+			Message: "Rollback is not available",
+			Error:   "SWAN_CONFIG is not set",
+		})
+		return
+	}
+	var req models.ConfigRollbackRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHistoryRequest)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if _, err := h.store.Get(version); err != nil {
+		respondHistoryError(w, fmt.Sprintf("Failed to read version %d", version), err)
+		return
+	}
+
+	var pending *confighistory.Pending
+	if req.ConfirmTimeout > 0 {
+		p, status, err := h.Begin(r, req.ConfirmTimeout, fmt.Sprintf("Rolled back to version %d", version))
+		if err != nil {
+			respondJSON(w, status, models.Response{
+				Success: false,
+				Message: fmt.Sprintf("Failed to roll back to version %d", version),
+				Error:   err.Error(),
+			})
+			return
+		}
+		pending = p
+	}
+
+	author := h.author(r)
+	snap, err := h.store.Rollback(r.Context(), h.target(), version, author)
+	if err != nil {
+		if pending != nil {
+			h.Cancel()
+		}
+		slog.Error("Failed to roll back the configuration", "version", version, "author", author.String(), "error", err)
+		respondHistoryError(w, fmt.Sprintf("Failed to roll back to version %d", version), err)
+		return
+	}
+	slog.Info("Rolled back the configuration", "version", version, "recorded", snap.Version, "author", author.String(), "confirm_timeout", req.ConfirmTimeout)
+	snap.Files = nil
+	resp := models.ConfigRollbackResponse{
+		Response: models.Response{Success: true, Message: fmt.Sprintf("Rolled back to version %d", version)},
+		Version:  snap,
+		Pending:  pending,
+	}
+	if pending != nil {
+		resp.Message += fmt.Sprintf(", confirm within %d seconds to keep it", req.ConfirmTimeout)
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// Pending serves GET /api/config/pending, the change waiting to be
+// confirmed.
+func (h *HistoryHandler) Pending(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp, err := h.pending()
+	if err != nil {
+		respondHistoryError(w, "Failed to read the pending change", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func (h *HistoryHandler) pending() (*models.ConfigPendingResponse, error) {
+	p, err := h.store.Pending()
+	if err != nil {
+		return nil, err
+	}
+	resp := &models.ConfigPendingResponse{Response: models.Response{Success: true}}
+	if p != nil {
+		resp.Pending = p.Summary()
+	}
+	return resp, nil
+}
+
+// Confirm serves POST /api/config/confirm, which keeps the change on
+// trial.
+func (h *HistoryHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p, err := h.store.Confirm()
+	if err != nil {
+		respondHistoryError(w, "Failed to confirm the change", err)
+		return
+	}
+	h.notify()
+	slog.Info("Confirmed the configuration change", "change", p.Message, "author", h.author(r).String())
+	respondJSON(w, http.StatusOK, models.ConfigPendingResponse{
+		Response: models.Response{Success: true, Message: "Confirmed: " + p.Message},
+		Pending:  p.Summary(),
+	})
+}
+
+// Revert serves POST /api/config/revert, which reverts the change on trial
+// right away.
+func (h *HistoryHandler) Revert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	author := h.author(r)
+	p, snap, err := h.store.Revert(r.Context(), h.target(), author)
+	h.notify()
+	if err != nil {
+		if p != nil {
+			slog.Error("Failed to revert the configuration change", "change", p.Message, "author", author.String(), "error", err)
+		}
+		respondHistoryError(w, "Failed to revert the change", err)
+		return
+	}
+	slog.Info("Reverted the configuration change", "change", p.Message, "recorded", snap.Version, "author", author.String())
+	snap.Files = nil
+	respondJSON(w, http.StatusOK, models.ConfigRollbackResponse{
+		Response: models.Response{Success: true, Message: "Reverted: " + p.Message},
+		Version:  snap,
+	})
+}
+
+// Watch reverts a change on trial once its deadline passes, and publishes
+// a config-pending event whenever the change waiting to be confirmed
+// changes.
+func (h *HistoryHandler) Watch(ctx context.Context, publisher EventPublisher) {
+	ticker := time.NewTicker(pendingPollInterval)
+	defer ticker.Stop()
+
+	var last *models.ConfigPendingResponse
+	for {
+		if h.configPath != "" {
+			h.revertExpired(ctx)
+		}
+
+		resp, err := h.pending()
+		if err != nil {
+			slog.Info("Error reading the pending change", "error", err)
+		} else if last == nil || !reflect.DeepEqual(resp, last) {
+			last = resp
+			publisher.Publish("config-pending", resp)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.changed:
+		}
+	}
+}
+
+func (h *HistoryHandler) revertExpired(ctx context.Context) {
+	p, snap, err := h.store.RevertExpired(ctx, h.target(), tailswanAuthor, h.now())
+	switch {
+	case err != nil && p != nil:
+		slog.Error("Failed to revert the unconfirmed configuration change", "change", p.Message, "error", err)
+	case err != nil:
+		slog.Info("Error reading the pending change", "error", err)
+	case p != nil:
+		slog.Warn("Reverted the configuration change that was not confirmed in time",
+			"change", p.Message, "author", p.Author.String(), "recorded", snap.Version)
+	}
+}
+
+func respondHistoryError(w http.ResponseWriter, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, confighistory.ErrNotFound), errors.Is(err, confighistory.ErrNoPending):
+		status = http.StatusNotFound
+	case errors.Is(err, confighistory.ErrPending):
+		status = http.StatusConflict
+	}
+	respondJSON(w, status, models.Response{
+		Success: false,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
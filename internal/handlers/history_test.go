@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/confighistory"
+	"github.com/klowdo/tailswan/internal/models"
+)
+
+type testTailnet struct {
+	routes  []netip.Prefix
+	applied int
+}
+
+func newTestHistoryHandler(t *testing.T, configPath string) (*HistoryHandler, *testTailnet) {
+	t.Helper()
+	tn := &testTailnet{routes: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}}
+	h := NewHistoryHandler(&config.Config{
+		StateDir: t.TempDir(),
+		Swan:     config.SwanConfig{ConfigPath: configPath},
+	}, nil, nil, func(*http.Request) Caller {
+		return Caller{Login: "alice@example.com", Name: "Alice"}
+	})
+	h.routes = func(context.Context) ([]netip.Prefix, error) {
+		return tn.routes, nil
+	}
+	h.setRoutes = func(_ context.Context, routes []netip.Prefix) error {
+		tn.routes = routes
+		return nil
+	}
+	h.apply = func(context.Context) error {
+		tn.applied++
+		return nil
+	}
+	return h, tn
+}
+
+func writeTestConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func recordTestChange(h *HistoryHandler, message string) {
+	h.Record(httptest.NewRequest(http.MethodPost, "/api/templates/psk-site-to-site", http.NoBody), confighistory.SourceAPI, message)
+}
+
+func TestHistoryHandler_RecordDiff(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "swanctl.conf")
+	writeTestConfig(t, configPath, "connections {\n}\nsecrets {\n  ike-a {\n    secret = first-secret\n  }\n}\n")
+	h, tn := newTestHistoryHandler(t, configPath)
+
+	recordTestChange(h, "first")
+	recordTestChange(h, "nothing changed")
+	writeTestConfig(t, configPath, "connections {\n  a {\n  }\n}\nsecrets {\n  ike-a {\n    secret = second-secret\n  }\n}\n")
+	tn.routes = append(tn.routes, netip.MustParsePrefix("10.2.0.0/16"))
+	recordTestChange(h, "second")
+
+	rec := servePKI(h.History, http.MethodGet, "/api/config/history", "")
+	var list models.ConfigHistoryResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Versions) != 2 || list.Versions[0].Message != "second" {
+		t.Fatalf("expected two versions, newest first, got %+v", list.Versions)
+	}
+	if got := list.Versions[0]; got.Author.Login != "alice@example.com" || got.Source != confighistory.SourceAPI ||
+		!reflect.DeepEqual(got.Changed, []string{"swanctl.conf", "routes"}) {
+		t.Errorf("unexpected version %+v", got)
+	}
+
+	rec = servePKI(h.Version, http.MethodGet, "/api/config/history/2/diff", "")
+	var diff models.ConfigDiffResponse
+	if err := json.NewDecoder(rec.Body).Decode(&diff); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if diff.From != 1 || diff.To != 2 || !strings.Contains(diff.Diff, "+  a {") || !strings.Contains(diff.Diff, "+10.2.0.0/16") {
+		t.Errorf("unexpected diff %+v", diff)
+	}
+	if strings.Contains(diff.Diff, "second-secret") {
+		t.Errorf("expected the secret to be redacted:\n%s", diff.Diff)
+	}
+
+	rec = servePKI(h.Version, http.MethodGet, "/api/config/history/2", "")
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "second-secret") {
+		t.Errorf("expected the version with its secret redacted, got %d %s", rec.Code, rec.Body)
+	}
+	rec = servePKI(h.Version, http.MethodGet, "/api/config/history/9/diff", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown version, got %d", rec.Code)
+	}
+	rec = servePKI(h.Version, http.MethodGet, "/api/config/history/latest", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid version, got %d", rec.Code)
+	}
+}
+
+func TestHistoryHandler_Rollback(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "swanctl.conf")
+	writeTestConfig(t, configPath, "connections {\n  a {\n  }\n}\n")
+	h, tn := newTestHistoryHandler(t, configPath)
+	recordTestChange(h, "first")
+
+	writeTestConfig(t, configPath, "connections {\n  b {\n  }\n}\n")
+	tn.routes = nil
+	recordTestChange(h, "second")
+
+	rec := servePKI(h.Version, http.MethodPost, "/api/config/history/1/rollback", "")
+	var resp models.ConfigRollbackResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || resp.Version == nil {
+		t.Fatalf("expected the rollback to succeed, got %d %+v", rec.Code, resp)
+	}
+	if resp.Version.Version != 3 || resp.Version.Source != confighistory.SourceRollback || resp.Version.Author.Name != "Alice" {
+		t.Errorf("expected the rollback to be recorded, got %+v", resp.Version)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "a {") || tn.applied != 1 {
+		t.Errorf("expected version 1 to be restored and loaded, got %d loads of:\n%s", tn.applied, data)
+	}
+	if len(tn.routes) != 1 || tn.routes[0].String() != "10.1.0.0/16" {
+		t.Errorf("expected the routes of version 1 to be advertised, got %v", tn.routes)
+	}
+}
+
+func TestHistoryHandler_RollbackRejected(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "swanctl.conf")
+	writeTestConfig(t, configPath, "connections {\n  a {\n  }\n}\n")
+	h, tn := newTestHistoryHandler(t, configPath)
+	recordTestChange(h, "first")
+	writeTestConfig(t, configPath, "connections {\n  b {\n  }\n}\n")
+	recordTestChange(h, "second")
+
+	h.apply = func(context.Context) error {
+		tn.applied++
+		if tn.applied == 1 {
+			return errors.New("unknown option")
+		}
+		return nil
+	}
+	rec := servePKI(h.Version, http.MethodPost, "/api/config/history/1/rollback", "")
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected the rollback to fail, got %d: %s", rec.Code, rec.Body)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "b {") || tn.applied != 2 {
+		t.Errorf("expected the current configuration to be restored and reloaded, got %d loads of:\n%s", tn.applied, data)
+	}
+}
+
+func TestHistoryHandler_RollbackOnTrial(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "swanctl.conf")
+	writeTestConfig(t, configPath, "connections {\n  a {\n  }\n}\n")
+	h, tn := newTestHistoryHandler(t, configPath)
+	recordTestChange(h, "first")
+	writeTestConfig(t, configPath, "connections {\n  b {\n  }\n}\n")
+	tn.routes = nil
+	recordTestChange(h, "second")
+
+	rec := servePKI(h.Version, http.MethodPost, "/api/config/history/1/rollback", `{"confirm_timeout": 5}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a timeout below the minimum, got %d", rec.Code)
+	}
+
+	rec = servePKI(h.Version, http.MethodPost, "/api/config/history/1/rollback", `{"confirm_timeout": 60}`)
+	var resp models.ConfigRollbackResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || resp.Pending == nil || resp.Pending.Baseline.Files != nil {
+		t.Fatalf("expected the rollback to be on trial, got %d %+v", rec.Code, resp)
+	}
+	rec = servePKI(h.Version, http.MethodPost, "/api/config/history/2/rollback", `{"confirm_timeout": 60}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 while a change is on trial, got %d", rec.Code)
+	}
+
+	// Not due yet.
+	h.revertExpired(context.Background())
+	if data, _ := os.ReadFile(configPath); !strings.Contains(string(data), "a {") {
+		t.Fatalf("expected the rollback to stay before its deadline, got:\n%s", data)
+	}
+
+	h.now = func() time.Time { return resp.Pending.Deadline }
+	h.revertExpired(context.Background())
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "b {") || len(tn.routes) != 0 {
+		t.Errorf("expected the configuration before the rollback to be restored, got routes %v and:\n%s", tn.routes, data)
+	}
+	latest, err := h.store.Latest()
+	if err != nil || latest.Source != confighistory.SourceRevert || latest.Author.Name != "tailswan" {
+		t.Errorf("expected the revert to be recorded, got %+v, %v", latest, err)
+	}
+
+	rec = servePKI(h.Pending, http.MethodGet, "/api/config/pending", "")
+	var pending models.ConfigPendingResponse
+	if err := json.NewDecoder(rec.Body).Decode(&pending); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if pending.Pending != nil {
+		t.Errorf("expected no pending change, got %+v", pending.Pending)
+	}
+}
+
+func TestHistoryHandler_ConfirmRevert(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "swanctl.conf")
+	writeTestConfig(t, configPath, "connections {\n  a {\n  }\n}\n")
+	h, _ := newTestHistoryHandler(t, configPath)
+	recordTestChange(h, "first")
+	writeTestConfig(t, configPath, "connections {\n  b {\n  }\n}\n")
+	recordTestChange(h, "second")
+
+	if rec := servePKI(h.Confirm, http.MethodPost, "/api/config/confirm", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without a pending change, got %d", rec.Code)
+	}
+
+	servePKI(h.Version, http.MethodPost, "/api/config/history/1/rollback", `{"confirm_timeout": 60}`)
+	if rec := servePKI(h.Confirm, http.MethodPost, "/api/config/confirm", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected the rollback to be confirmed, got %d: %s", rec.Code, rec.Body)
+	}
+	h.now = func() time.Time { return time.Now().Add(time.Hour) }
+	h.revertExpired(context.Background())
+	if data, _ := os.ReadFile(configPath); !strings.Contains(string(data), "a {") {
+		t.Errorf("expected the confirmed rollback to stay, got:\n%s", data)
+	}
+
+	servePKI(h.Version, http.MethodPost, "/api/config/history/2/rollback", `{"confirm_timeout": 60}`)
+	if rec := servePKI(h.Revert, http.MethodPost, "/api/config/revert", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected the rollback to be reverted, got %d: %s", rec.Code, rec.Body)
+	}
+	if data, _ := os.ReadFile(configPath); !strings.Contains(string(data), "a {") {
+		t.Errorf("expected the configuration before the rollback, got:\n%s", data)
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"reflect"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/identity"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const (
+	identityResetInterval    = time.Minute
+	identityResubscribeDelay = 5 * time.Second
+)
+
+// IdentityHandler maps IPsec clients to the virtual IPs they were
+// assigned. The table is reset from list-sas periodically and follows
+// ike-updown events in between. The supervisor enforces RW_ACCESS; the
+// handler only reports what each identity is allowed.
+type IdentityHandler struct {
+	table   *identity.Table
+	list    func(ctx context.Context) ([]viciconn.IKEIdentity, error)
+	events  func(ctx context.Context, fn func(ids []viciconn.IKEIdentity, up bool)) error
+	changed chan struct{}
+	// configErr is why RW_ACCESS could not be parsed.
+	configErr string
+	enforced  bool
+}
+
+func NewIdentityHandler(cfg *config.Config, session *vici.Session) *IdentityHandler {
+	policy, err := identity.ParsePolicy(cfg.RW.Access)
+	h := &IdentityHandler{
+		table:    identity.NewTable(policy),
+		changed:  make(chan struct{}, 1),
+		enforced: len(policy) > 0,
+		list: func(ctx context.Context) ([]viciconn.IKEIdentity, error) {
+			return viciconn.IKEIdentities(ctx, session)
+		},
+		events: watchIKEUpdown,
+	}
+	if err != nil {
+		slog.Warn("Invalid RW_ACCESS", "error", err)
+		h.configErr = err.Error()
+	}
+	return h
+}
+
+// watchIKEUpdown follows ike-updown events on a session of its own, so
+// they are not held up by the commands of the handlers' shared session.
+func watchIKEUpdown(ctx context.Context, fn func(ids []viciconn.IKEIdentity, up bool)) error {
+	session, err := vici.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close() //nolint:errcheck
+
+	return viciconn.WatchIKEUpdown(ctx, session, fn)
+}
+
+func (h *IdentityHandler) response() *models.IdentitiesResponse {
+	return &models.IdentitiesResponse{
+		Error:      h.configErr,
+		Identities: h.table.List(),
+		Enforced:   h.enforced,
+		Success:    true,
+	}
+}
+
+// Identities lists the identity of every IPsec client holding a virtual
+// IP. With ?address= it returns the client holding that address.
+func (h *IdentityHandler) Identities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := h.response()
+	if s := r.URL.Query().Get("address"); s != "" {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, models.Response{
+				Success: false,
+				Message: "Invalid address",
+				Error:   err.Error(),
+			})
+			return
+		}
+		m, ok := h.table.Lookup(addr)
+		if !ok {
+			respondJSON(w, http.StatusNotFound, models.Response{
+				Success: false,
+				Message: "No IPsec client holds " + addr.String(),
+			})
+			return
+		}
+		resp.Identities = []identity.Mapping{m}
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func (h *IdentityHandler) reset(ctx context.Context) {
+	ids, err := h.list(ctx)
+	if err != nil {
+		slog.Info("Error listing IKE_SAs", "error", err)
+		return
+	}
+	h.table.Reset(ids)
+}
+
+func (h *IdentityHandler) notify() {
+	select {
+	case h.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (h *IdentityHandler) follow(ctx context.Context) {
+	for {
+		err := h.events(ctx, func(ids []viciconn.IKEIdentity, up bool) {
+			h.table.Update(ids, up)
+			h.notify()
+		})
+		if err != nil {
+			slog.Info("Lost ike-updown events", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(identityResubscribeDelay):
+		}
+		// Events may have been missed while resubscribing.
+		h.reset(ctx)
+		h.notify()
+	}
+}
+
+// Watch keeps the table up to date and publishes "identity-update"
+// whenever the mappings change.
+func (h *IdentityHandler) Watch(ctx context.Context, publisher EventPublisher) {
+	ticker := time.NewTicker(identityResetInterval)
+	defer ticker.Stop()
+
+	h.reset(ctx)
+	go h.follow(ctx)
+
+	var last *models.IdentitiesResponse
+	for {
+		resp := h.response()
+		if last == nil || !reflect.DeepEqual(resp, last) {
+			last = resp
+			publisher.Publish("identity-update", resp)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.reset(ctx)
+		case <-h.changed:
+		}
+	}
+}
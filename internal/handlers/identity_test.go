@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+var testAlice = viciconn.IKEIdentity{
+	Connection: "rw",
+	UniqueID:   "7",
+	RemoteID:   "198.51.100.7",
+	EAPID:      "alice@example.com",
+	VirtualIPs: []string{"10.10.0.1"},
+}
+
+func newTestIdentityHandler(access string) (*IdentityHandler, chan func(ids []viciconn.IKEIdentity, up bool)) {
+	h := NewIdentityHandler(&config.Config{RW: config.RoadWarriorConfig{Access: access}}, nil)
+	h.list = func(context.Context) ([]viciconn.IKEIdentity, error) {
+		return []viciconn.IKEIdentity{testAlice}, nil
+	}
+	subscribed := make(chan func(ids []viciconn.IKEIdentity, up bool), 1)
+	h.events = func(ctx context.Context, fn func(ids []viciconn.IKEIdentity, up bool)) error {
+		subscribed <- fn
+		<-ctx.Done()
+		return nil
+	}
+	return h, subscribed
+}
+
+func getIdentities(t *testing.T, h *IdentityHandler, query string) (*models.IdentitiesResponse, int) {
+	t.Helper()
+	rec := servePKI(h.Identities, http.MethodGet, "/api/identities"+query, "")
+	var resp models.IdentitiesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return &resp, rec.Code
+}
+
+func TestIdentityHandler_Identities(t *testing.T) {
+	h, _ := newTestIdentityHandler("*@example.com=100.64.1.10")
+	h.reset(context.Background())
+
+	resp, code := getIdentities(t, h, "")
+	if code != http.StatusOK || !resp.Enforced || len(resp.Identities) != 1 {
+		t.Fatalf("unexpected response %d %+v", code, resp)
+	}
+	if m := resp.Identities[0]; m.Identity != "alice@example.com" || len(m.Allowed) != 1 || m.Allowed[0] != "100.64.1.10/32" {
+		t.Errorf("unexpected mapping %+v", m)
+	}
+
+	resp, code = getIdentities(t, h, "?address=10.10.0.1")
+	if code != http.StatusOK || len(resp.Identities) != 1 || resp.Identities[0].UniqueID != "7" {
+		t.Errorf("expected the lookup to find alice, got %d %+v", code, resp)
+	}
+	if _, code := getIdentities(t, h, "?address=10.10.0.2"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unassigned address, got %d", code)
+	}
+	if _, code := getIdentities(t, h, "?address=nope"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad address, got %d", code)
+	}
+
+	if rec := servePKI(h.Identities, http.MethodPost, "/api/identities", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+
+	bad, _ := newTestIdentityHandler("alice")
+	if resp, _ := getIdentities(t, bad, ""); resp.Enforced || resp.Error == "" {
+		t.Errorf("expected an invalid RW_ACCESS to be reported and not enforced, got %+v", resp)
+	}
+}
+
+func TestIdentityHandler_Watch(t *testing.T) {
+	h, subscribed := newTestIdentityHandler("")
+	publisher := &recordingPublisher{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Watch(ctx, publisher)
+
+	update := <-subscribed
+	waitForEvents(t, publisher, 1)
+
+	bob := viciconn.IKEIdentity{Connection: "rw", UniqueID: "9", RemoteID: "bob", VirtualIPs: []string{"10.10.0.2"}}
+	update([]viciconn.IKEIdentity{bob}, true)
+	waitForEvents(t, publisher, 2)
+	if _, code := getIdentities(t, h, "?address=10.10.0.2"); code != http.StatusOK {
+		t.Errorf("expected bob to be mapped after ike-updown, got %d", code)
+	}
+
+	update([]viciconn.IKEIdentity{bob}, false)
+	waitForEvents(t, publisher, 3)
+	if resp, _ := getIdentities(t, h, ""); len(resp.Identities) != 1 || resp.Enforced || resp.Identities[0].Allowed != nil {
+		t.Errorf("expected only alice without enforcement, got %+v", resp)
+	}
+}
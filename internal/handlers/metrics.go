@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MetricsHandler serves Prometheus text-format metrics.
+type MetricsHandler struct {
+	ha      *HAHandler
+	tunnels *TunnelHealthHandler
+}
+
+func NewMetricsHandler(ha *HAHandler, tunnels *TunnelHealthHandler) *MetricsHandler {
+	return &MetricsHandler{ha: ha, tunnels: tunnels}
+}
+
+func (h *MetricsHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var b strings.Builder
+	h.writeHA(&b)
+	h.writeTunnels(&b)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		http.Error(w, "Failed to write response", http.StatusInternalServerError)
+	}
+}
+
+func (h *MetricsHandler) writeHA(b *strings.Builder) {
+	st, err := h.ha.State()
+	if err != nil || st == nil {
+		writeMetric(b, "tailswan_ha_enabled", "gauge", "Whether high availability is enabled.", "", 0)
+		return
+	}
+
+	labels := fmt.Sprintf(`{mode=%q,node_id=%q}`, st.Mode, st.NodeID)
+	writeMetric(b, "tailswan_ha_enabled", "gauge", "Whether high availability is enabled.", "", 1)
+	writeMetric(b, "tailswan_ha_leader", "gauge", "Whether this instance is the HA leader.", labels, boolValue(st.IsLeader()))
+	writeMetric(b, "tailswan_ha_transitions_total", "counter", "Leadership changes since the supervisor started.", labels, float64(st.Transitions))
+	if !st.Since.IsZero() {
+		writeMetric(b, "tailswan_ha_role_since_timestamp_seconds", "gauge", "When this instance took its current HA role.", labels, float64(st.Since.Unix()))
+	}
+}
+
+// writeTunnels exports the watchdog's probe results, one sample per probed
+// connection.
+func (h *MetricsHandler) writeTunnels(b *strings.Builder) {
+	st, err := h.tunnels.State()
+	if err != nil || st == nil || len(st.Tunnels) == 0 {
+		return
+	}
+
+	labels := make([]string, len(st.Tunnels))
+	for i, t := range st.Tunnels {
+		labels[i] = fmt.Sprintf(`{connection=%q,probe=%q,target=%q}`, t.Connection, t.Kind, t.Target)
+	}
+
+	writeMetricHeader(b, "tailswan_tunnel_healthy", "gauge", "Whether the last probe through the tunnel succeeded.")
+	for i := range st.Tunnels {
+		writeSample(b, "tailswan_tunnel_healthy", labels[i], boolValue(st.Tunnels[i].Healthy()))
+	}
+	writeMetricHeader(b, "tailswan_tunnel_probe_failures", "gauge", "Probes that failed in a row since the last success or restart.")
+	for i := range st.Tunnels {
+		writeSample(b, "tailswan_tunnel_probe_failures", labels[i], float64(st.Tunnels[i].Failures))
+	}
+	writeMetricHeader(b, "tailswan_tunnel_probe_latency_seconds", "gauge", "Round trip time of the last successful probe.")
+	for i := range st.Tunnels {
+		writeSample(b, "tailswan_tunnel_probe_latency_seconds", labels[i], st.Tunnels[i].LatencyMs/1000)
+	}
+	writeMetricHeader(b, "tailswan_tunnel_restarts_total", "counter", "Children the watchdog restarted since the supervisor started.")
+	for i := range st.Tunnels {
+		writeSample(b, "tailswan_tunnel_restarts_total", labels[i], float64(st.Tunnels[i].Restarts))
+	}
+}
+
+func writeMetric(b *strings.Builder, name, kind, help, labels string, value float64) {
+	writeMetricHeader(b, name, kind, help)
+	writeSample(b, name, labels, value)
+}
+
+func writeMetricHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, kind)
+}
+
+func writeSample(b *strings.Builder, name, labels string, value float64) {
+	fmt.Fprintf(b, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+func boolValue(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
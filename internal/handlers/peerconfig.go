@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/peerconfig"
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const peerConfigPrefix = "/api/peer-config/"
+
+// PeerConfigHandler exports the partner's side of a site-to-site
+// connection. Connections come from charon; proposals, which charon does
+// not report, from the swanctl configuration.
+type PeerConfigHandler struct {
+	conns      func(ctx context.Context) ([]viciconn.Conn, error)
+	readConfig func() (*swanconf.Section, error)
+}
+
+func NewPeerConfigHandler(cfg *config.Config, session *vici.Session) *PeerConfigHandler {
+	return &PeerConfigHandler{
+		conns: func(ctx context.Context) ([]viciconn.Conn, error) {
+			return viciconn.Conns(ctx, session)
+		},
+		readConfig: func() (*swanconf.Section, error) {
+			return swanconf.ParseFile(cfg.Swan.ConfigPath)
+		},
+	}
+}
+
+// Export serves GET /api/peer-config/{conn}?format=&address=.
+func (h *PeerConfigHandler) Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, peerConfigPrefix)
+	if name == "" || strings.Contains(name, "/") {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Connection name is required",
+			Error:   "expected " + peerConfigPrefix + "{conn}",
+		})
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = peerconfig.FormatSheet
+	}
+	if !slices.Contains(peerconfig.Formats(), format) {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Unknown format",
+			Error:   fmt.Sprintf("expected one of %s", strings.Join(peerconfig.Formats(), ", ")),
+		})
+		return
+	}
+
+	conns, err := h.conns(r.Context())
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "Failed to list connections",
+			Error:   err.Error(),
+		})
+		return
+	}
+	i := slices.IndexFunc(conns, func(c viciconn.Conn) bool { return c.Name == name })
+	if i < 0 {
+		respondJSON(w, http.StatusNotFound, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Connection '%s' is not loaded", name),
+			Error:   "unknown connection",
+		})
+		return
+	}
+
+	// Without the file the export falls back to charon's default
+	// proposals and says so in its notes.
+	file, err := h.readConfig()
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Failed to read the swanctl configuration", "error", err)
+		}
+		file = nil
+	}
+	peer, err := peerconfig.Build(&conns[i], file, r.URL.Query().Get("address"))
+	var out string
+	if err == nil {
+		out, err = peerconfig.Render(peer, format)
+	}
+	if err != nil {
+		respondJSON(w, http.StatusUnprocessableEntity, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to export '%s'", name),
+			Error:   err.Error(),
+		})
+		return
+	}
+	respondFile(w, peerconfig.ContentType(format), peerconfig.FileName(name, format), []byte(out))
+}
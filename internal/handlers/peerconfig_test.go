@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+func newTestPeerConfigHandler() *PeerConfigHandler {
+	h := NewPeerConfigHandler(&config.Config{}, nil)
+	h.conns = func(context.Context) ([]viciconn.Conn, error) {
+		return []viciconn.Conn{{
+			Name:        "partner-a",
+			Version:     "IKEv2",
+			LocalAddrs:  []string{"%any"},
+			RemoteAddrs: []string{"203.0.113.10"},
+			Local:       []viciconn.Auth{{Class: "pre-shared key", ID: "@tailswan.example.com"}},
+			Remote:      []viciconn.Auth{{Class: "pre-shared key"}},
+			Children: []viciconn.Child{{
+				Name:     "net",
+				LocalTS:  []string{"10.1.0.0/24"},
+				RemoteTS: []string{"10.2.0.0/24"},
+			}},
+		}}, nil
+	}
+	h.readConfig = func() (*swanconf.Section, error) {
+		return swanconf.Parse(strings.NewReader(`
+connections {
+    partner-a {
+        proposals = aes128-sha256-modp2048
+        children {
+            net {
+                esp_proposals = aes128gcm16
+            }
+        }
+    }
+}
+`))
+	}
+	return h
+}
+
+func TestPeerConfigHandler_Export(t *testing.T) {
+	h := newTestPeerConfigHandler()
+
+	rec := servePKI(h.Export, http.MethodGet, "/api/peer-config/partner-a?format=fortinet&address=198.51.100.1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, `filename="partner-a-fortinet.txt"`) {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"set remote-gw 198.51.100.1", "set proposal aes128-sha256", "set dhgrp 14"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the export to contain %q, got:\n%s", want, body)
+		}
+	}
+
+	rec = servePKI(h.Export, http.MethodGet, "/api/peer-config/partner-a?format=pfsense", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/xml" {
+		t.Errorf("unexpected pfSense export %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestPeerConfigHandler_MissingConfig(t *testing.T) {
+	h := newTestPeerConfigHandler()
+	h.readConfig = func() (*swanconf.Section, error) {
+		return nil, os.ErrNotExist
+	}
+
+	rec := servePKI(h.Export, http.MethodGet, "/api/peer-config/partner-a", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the export to fall back to defaults, got %d: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "default proposals are assumed") {
+		t.Errorf("expected a note about the defaults, got:\n%s", rec.Body)
+	}
+}
+
+func TestPeerConfigHandler_Errors(t *testing.T) {
+	h := newTestPeerConfigHandler()
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/api/peer-config/", http.StatusBadRequest},
+		{http.MethodGet, "/api/peer-config/partner-a?format=juniper", http.StatusBadRequest},
+		{http.MethodGet, "/api/peer-config/partner-b", http.StatusNotFound},
+		{http.MethodPost, "/api/peer-config/partner-a", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if rec := servePKI(h.Export, tt.method, tt.path, ""); rec.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.want, rec.Code)
+		}
+	}
+}
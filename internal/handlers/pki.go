@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/certs"
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/pki"
+)
+
+const (
+	pkiCertsPrefix    = "/api/pki/certs/"
+	pkiPollInterval   = time.Hour
+	maxPKIRequestSize = 64 << 10
+)
+
+// PKIHandler runs the built-in CA. The CA is created on the first
+// certificate issued, and its certificate and CRL are installed in the
+// swanctl directory and loaded into charon.
+type PKIHandler struct {
+	ca      *pki.CA
+	store   *certs.Store
+	load    func(ctx context.Context, kind, name string) error
+	caller  CallerFunc
+	changed chan struct{}
+	dir     string
+	mu      sync.Mutex
+}
+
+func NewPKIHandler(cfg *config.Config, session *vici.Session, caller CallerFunc) *PKIHandler {
+	store := certs.NewStore(cfg.Swan.CredentialsDir())
+	return &PKIHandler{
+		store:   store,
+		caller:  caller,
+		dir:     pki.Dir(cfg.StateDir),
+		changed: make(chan struct{}, 1),
+		load: func(ctx context.Context, kind, name string) error {
+			return store.Load(ctx, session, kind, name)
+		},
+	}
+}
+
+// openCA returns the CA, creating it when create is set. It is nil when
+// there is none yet.
+func (h *PKIHandler) openCA(ctx context.Context, create bool) (*pki.CA, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.ca != nil {
+		return h.ca, nil
+	}
+	if !create && !pki.Exists(h.dir) {
+		return nil, nil
+	}
+	ca, err := pki.Open(h.dir, "")
+	if err != nil {
+		return nil, err
+	}
+	h.ca = ca
+	// The CA is usable without charon; it trusts the CA once the files
+	// are loaded.
+	if err := h.install(ctx, ca); err != nil {
+		slog.Warn("Failed to install the CA into charon", "error", err)
+	}
+	return ca, nil
+}
+
+func (h *PKIHandler) install(ctx context.Context, ca *pki.CA) error {
+	creds, err := ca.Install(h.store)
+	if err != nil {
+		return err
+	}
+	return h.loadAll(ctx, creds)
+}
+
+func (h *PKIHandler) loadAll(ctx context.Context, creds []certs.Credential) error {
+	var errs []error
+	for _, c := range creds {
+		errs = append(errs, h.load(ctx, c.Kind, c.Name))
+	}
+	return errors.Join(errs...)
+}
+
+func (h *PKIHandler) overview(ctx context.Context) (*models.PKIResponse, error) {
+	resp := &models.PKIResponse{Certificates: []pki.Cert{}, Success: true}
+	ca, err := h.openCA(ctx, false)
+	if err != nil || ca == nil {
+		return resp, err
+	}
+	if resp.CA, err = certs.Parse(certs.KindCA, pki.CAName, ca.CertificatePEM()); err != nil {
+		return nil, err
+	}
+	list, err := ca.List()
+	if err != nil {
+		return nil, err
+	}
+	if list != nil {
+		resp.Certificates = list
+	}
+	return resp, nil
+}
+
+// Overview describes the CA and the certificates it issued.
+func (h *PKIHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := h.overview(r.Context())
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "Failed to read the CA",
+			Error:   err.Error(),
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// CACert serves the CA certificate for peers to trust.
+func (h *PKIHandler) CACert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ca, err := h.existingCA(r.Context())
+	if err != nil {
+		respondPKIError(w, "Failed to open the CA", err)
+		return
+	}
+	respondFile(w, "application/x-pem-file", pki.CAName, ca.CertificatePEM())
+}
+
+// CRL serves the current CRL.
+func (h *PKIHandler) CRL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ca, err := h.existingCA(r.Context())
+	var crl []byte
+	if err == nil {
+		crl, err = ca.CRL()
+	}
+	if err != nil {
+		respondPKIError(w, "Failed to read the CRL", err)
+		return
+	}
+	respondFile(w, "application/pkix-crl", pki.CRLName, crl)
+}
+
+// Issue issues a certificate with POST {"name", "profile", "sans", "days",
+// "key_type", "install"}.
+func (h *PKIHandler) Issue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.IssueRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPKIRequestSize)).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if req.Days < 0 {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid request",
+			Error:   "days must be positive",
+		})
+		return
+	}
+
+	ca, err := h.openCA(r.Context(), true)
+	if err != nil {
+		respondPKIError(w, "Failed to open the CA", err)
+		return
+	}
+	issued, err := ca.Issue(pki.Request{
+		Name:     req.Name,
+		Profile:  req.Profile,
+		KeyType:  req.KeyType,
+		SANs:     req.SANs,
+		Validity: time.Duration(req.Days) * 24 * time.Hour,
+	})
+	if err != nil {
+		respondPKIError(w, fmt.Sprintf("Failed to issue '%s'", req.Name), err)
+		return
+	}
+	slog.Info("Issued certificate", append([]any{"name", issued.Name, "profile", issued.Profile, "serial", issued.Serial}, h.caller.attrs(r)...)...)
+	h.notify()
+
+	if req.Install {
+		creds, err := ca.InstallIssued(h.store, issued.Name)
+		if err == nil {
+			err = h.loadAll(r.Context(), creds)
+		}
+		if err != nil {
+			respondJSON(w, http.StatusBadGateway, models.IssuedCertResponse{
+				Certificate: issued,
+				Response: models.Response{
+					Success: false,
+					Message: fmt.Sprintf("Issued '%s', but failed to install it", issued.Name),
+					Error:   err.Error(),
+				},
+			})
+			return
+		}
+	}
+	respondJSON(w, http.StatusOK, models.IssuedCertResponse{
+		Certificate: issued,
+		Response:    models.Response{Success: true, Message: fmt.Sprintf("Issued %s certificate '%s'", issued.Profile, issued.Name)},
+	})
+}
+
+// Cert serves GET /api/pki/certs/{name}, POST /api/pki/certs/{name}/revoke
+// and POST /api/pki/certs/{name}/export with {"format", "password",
+// "legacy"}.
+func (h *PKIHandler) Cert(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, pkiCertsPrefix), "/")
+	if name == "" {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Certificate name is required",
+			Error:   "expected " + pkiCertsPrefix + "{name}",
+		})
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		h.get(w, r, name)
+	case action == "revoke" && r.Method == http.MethodPost:
+		h.revoke(w, r, name)
+	case action == "export" && r.Method == http.MethodPost:
+		h.export(w, r, name)
+	case action == "" || action == "revoke" || action == "export":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// existingCA returns the CA, or ErrNotFound when nothing was issued yet.
+func (h *PKIHandler) existingCA(ctx context.Context) (*pki.CA, error) {
+	ca, err := h.openCA(ctx, false)
+	if err == nil && ca == nil {
+		err = fmt.Errorf("%w: no CA created yet", pki.ErrNotFound)
+	}
+	return ca, err
+}
+
+func (h *PKIHandler) get(w http.ResponseWriter, r *http.Request, name string) {
+	ca, err := h.existingCA(r.Context())
+	var c *pki.Cert
+	if err == nil {
+		c, err = ca.Get(name)
+	}
+	if err != nil {
+		respondPKIError(w, fmt.Sprintf("Failed to read '%s'", name), err)
+		return
+	}
+	respondJSON(w, http.StatusOK, models.IssuedCertResponse{
+		Certificate: c,
+		Response:    models.Response{Success: true, Message: fmt.Sprintf("%s certificate '%s'", c.Profile, name)},
+	})
+}
+
+func (h *PKIHandler) revoke(w http.ResponseWriter, r *http.Request, name string) {
+	ca, err := h.existingCA(r.Context())
+	var c *pki.Cert
+	if err == nil {
+		c, err = ca.Revoke(name)
+	}
+	if err != nil {
+		respondPKIError(w, fmt.Sprintf("Failed to revoke '%s'", name), err)
+		return
+	}
+	slog.Info("Revoked certificate", append([]any{"name", name, "serial", c.Serial}, h.caller.attrs(r)...)...)
+	h.notify()
+
+	if err := h.install(r.Context(), ca); err != nil {
+		respondJSON(w, http.StatusBadGateway, models.IssuedCertResponse{
+			Certificate: c,
+			Response: models.Response{
+				Success: false,
+				Message: fmt.Sprintf("Revoked '%s', but charon did not load the CRL", name),
+				Error:   err.Error(),
+			},
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, models.IssuedCertResponse{
+		Certificate: c,
+		Response:    models.Response{Success: true, Message: fmt.Sprintf("Revoked '%s'", name)},
+	})
+}
+
+func (h *PKIHandler) export(w http.ResponseWriter, r *http.Request, name string) {
+	var req models.ExportRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPKIRequestSize)).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ca, err := h.existingCA(r.Context())
+	var data []byte
+	if err == nil {
+		data, err = ca.Export(name, req.Format, req.Password, req.Legacy)
+	}
+	if err != nil {
+		respondPKIError(w, fmt.Sprintf("Failed to export '%s'", name), err)
+		return
+	}
+	slog.Info("Exported certificate", append([]any{"name", name, "format", req.Format}, h.caller.attrs(r)...)...)
+
+	contentType := "application/x-pem-file"
+	if req.Format == pki.FormatPKCS12 {
+		contentType = "application/x-pkcs12"
+	}
+	respondFile(w, contentType, name+"."+req.Format, data)
+}
+
+func respondFile(w http.ResponseWriter, contentType, name string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if _, err := w.Write(data); err != nil {
+		return
+	}
+}
+
+func respondPKIError(w http.ResponseWriter, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, pki.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, pki.ErrInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, pki.ErrExists), errors.Is(err, pki.ErrRevoked):
+		status = http.StatusConflict
+	}
+	respondJSON(w, status, models.Response{
+		Success: false,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
+
+func (h *PKIHandler) notify() {
+	select {
+	case h.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Watch reissues the CRL before it passes its next update and loads it into
+// charon, and publishes a pki-update event when the issued certificates
+// change.
+func (h *PKIHandler) Watch(ctx context.Context, publisher EventPublisher) {
+	ticker := time.NewTicker(pkiPollInterval)
+	defer ticker.Stop()
+
+	var last *models.PKIResponse
+	for {
+		if ca, err := h.openCA(ctx, false); err != nil {
+			slog.Info("Error opening the CA", "error", err)
+		} else if ca != nil {
+			if refreshed, err := ca.RefreshCRL(); err != nil {
+				slog.Warn("Failed to reissue the CRL", "error", err)
+			} else if refreshed {
+				if err := h.install(ctx, ca); err != nil {
+					slog.Warn("Failed to load the reissued CRL", "error", err)
+				}
+			}
+		}
+
+		resp, err := h.overview(ctx)
+		if err != nil {
+			slog.Info("Error reading the CA", "error", err)
+		} else if last == nil || !reflect.DeepEqual(resp, last) {
+			last = resp
+			publisher.Publish("pki-update", resp)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.changed:
+		}
+	}
+}
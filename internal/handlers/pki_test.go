@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/pki"
+)
+
+func newTestPKIHandler(t *testing.T) (*PKIHandler, *[]string) {
+	t.Helper()
+	dir := t.TempDir()
+	h := NewPKIHandler(&config.Config{
+		StateDir: filepath.Join(dir, "state"),
+		Swan:     config.SwanConfig{ConfigPath: filepath.Join(dir, "swanctl", "swanctl.conf")},
+	}, nil, nil)
+	var loaded []string
+	h.load = func(_ context.Context, kind, name string) error {
+		loaded = append(loaded, kind+"/"+name)
+		return nil
+	}
+	return h, &loaded
+}
+
+func servePKI(handler http.HandlerFunc, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestPKIHandler_IssueRevokeExport(t *testing.T) {
+	h, loaded := newTestPKIHandler(t)
+
+	rec := servePKI(h.Overview, http.MethodGet, "/api/pki", "")
+	var overview models.PKIResponse
+	if err := json.NewDecoder(rec.Body).Decode(&overview); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if overview.CA != nil || len(overview.Certificates) != 0 {
+		t.Fatalf("the CA should not exist before first use, got %+v", overview)
+	}
+
+	rec = servePKI(h.Issue, http.MethodPost, "/api/pki/certs", `{"name":"gw","profile":"server","sans":["gw.example.com"],"install":true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("issue: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	want := []string{"ca/" + pki.CAName, "crl/" + pki.CRLName, "cert/gw.pem", "key/gw.pem"}
+	if !slices.Equal(*loaded, want) {
+		t.Errorf("expected %v loaded into charon, got %v", want, *loaded)
+	}
+
+	rec = servePKI(h.Issue, http.MethodPost, "/api/pki/certs", `{"name":"gw","profile":"server"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("reissue: expected status 409, got %d", rec.Code)
+	}
+
+	rec = servePKI(h.Cert, http.MethodPost, "/api/pki/certs/gw/export", `{"format":"pem"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "BEGIN PRIVATE KEY") {
+		t.Fatalf("export: unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, `filename="gw.pem"`) {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+
+	*loaded = nil
+	if rec := servePKI(h.Cert, http.MethodPost, "/api/pki/certs/gw/revoke", ""); rec.Code != http.StatusOK {
+		t.Fatalf("revoke: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !slices.Contains(*loaded, "crl/"+pki.CRLName) {
+		t.Errorf("expected the CRL to be loaded after revoking, got %v", *loaded)
+	}
+	if rec := servePKI(h.Cert, http.MethodPost, "/api/pki/certs/gw/export", `{"format":"pem"}`); rec.Code != http.StatusConflict {
+		t.Errorf("export revoked: expected status 409, got %d", rec.Code)
+	}
+}
+
+func TestPKIHandler_Errors(t *testing.T) {
+	tests := []struct {
+		handler func(h *PKIHandler) http.HandlerFunc
+		name    string
+		method  string
+		path    string
+		body    string
+		status  int
+	}{
+		{name: "bad profile", handler: func(h *PKIHandler) http.HandlerFunc { return h.Issue }, method: http.MethodPost, path: "/api/pki/certs", body: `{"name":"gw","profile":"peer"}`, status: http.StatusBadRequest},
+		{name: "bad json", handler: func(h *PKIHandler) http.HandlerFunc { return h.Issue }, method: http.MethodPost, path: "/api/pki/certs", body: `{`, status: http.StatusBadRequest},
+		{name: "issue method", handler: func(h *PKIHandler) http.HandlerFunc { return h.Issue }, method: http.MethodGet, path: "/api/pki/certs", status: http.StatusMethodNotAllowed},
+		{name: "no CA yet", handler: func(h *PKIHandler) http.HandlerFunc { return h.Cert }, method: http.MethodGet, path: "/api/pki/certs/gw", status: http.StatusNotFound},
+		{name: "no CRL yet", handler: func(h *PKIHandler) http.HandlerFunc { return h.CRL }, method: http.MethodGet, path: "/api/pki/crl.pem", status: http.StatusNotFound},
+		{name: "unknown action", handler: func(h *PKIHandler) http.HandlerFunc { return h.Cert }, method: http.MethodPost, path: "/api/pki/certs/gw/renew", status: http.StatusNotFound},
+		{name: "missing name", handler: func(h *PKIHandler) http.HandlerFunc { return h.Cert }, method: http.MethodGet, path: "/api/pki/certs/", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestPKIHandler(t)
+			if rec := servePKI(tt.handler(h), tt.method, tt.path, tt.body); rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestPKIHandler_CACert(t *testing.T) {
+	h, _ := newTestPKIHandler(t)
+	if rec := servePKI(h.CACert, http.MethodGet, "/api/pki/ca.pem", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 before the CA exists, got %d", rec.Code)
+	}
+	servePKI(h.Issue, http.MethodPost, "/api/pki/certs", `{"name":"alice","profile":"client"}`)
+	rec := servePKI(h.CACert, http.MethodGet, "/api/pki/ca.pem", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "BEGIN CERTIFICATE") {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+}
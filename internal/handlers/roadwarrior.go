@@ -0,0 +1,527 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/pki"
+	"github.com/klowdo/tailswan/internal/roadwarrior"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const (
+	rwUsersPrefix    = "/api/roadwarrior/users/"
+	rwPoolsPrefix    = "/api/roadwarrior/pools/"
+	rwPollInterval   = 30 * time.Second
+	maxRWRequestSize = 64 << 10
+)
+
+// RoadWarriorHandler manages the remote-access users and virtual IP pools
+// and loads them into charon. EAP-TLS users get client certificates of the
+// built-in CA.
+type RoadWarriorHandler struct {
+	store      *roadwarrior.Store
+	pki        *PKIHandler
+	now        func() time.Time
+	loadUser   func(ctx context.Context, u *roadwarrior.User) error
+	unloadUser func(ctx context.Context, u *roadwarrior.User) error
+	loadPool   func(ctx context.Context, p *roadwarrior.Pool) error
+	unloadPool func(ctx context.Context, name string) error
+	leases     func(ctx context.Context) ([]viciconn.Pool, error)
+	sharedIDs  func(ctx context.Context) ([]string, error)
+	started    func(ctx context.Context) (string, error)
+	caller     CallerFunc
+	changed    chan struct{}
+	cfg        config.RoadWarriorConfig
+}
+
+func NewRoadWarriorHandler(cfg *config.Config, session *vici.Session, pkiHandler *PKIHandler, caller CallerFunc) *RoadWarriorHandler {
+	return &RoadWarriorHandler{
+		store:   roadwarrior.NewStore(cfg.StateDir),
+		pki:     pkiHandler,
+		caller:  caller,
+		now:     time.Now,
+		changed: make(chan struct{}, 1),
+		cfg:     cfg.RW,
+		loadUser: func(ctx context.Context, u *roadwarrior.User) error {
+			return roadwarrior.LoadUser(ctx, session, u)
+		},
+		unloadUser: func(ctx context.Context, u *roadwarrior.User) error {
+			return roadwarrior.UnloadUser(ctx, session, u)
+		},
+		loadPool: func(ctx context.Context, p *roadwarrior.Pool) error {
+			return roadwarrior.LoadPool(ctx, session, p)
+		},
+		unloadPool: func(ctx context.Context, name string) error {
+			return viciconn.UnloadPool(ctx, session, name)
+		},
+		leases: func(ctx context.Context) ([]viciconn.Pool, error) {
+			return viciconn.Pools(ctx, session)
+		},
+		sharedIDs: func(ctx context.Context) ([]string, error) {
+			return viciconn.SharedIDs(ctx, session)
+		},
+		started: func(ctx context.Context) (string, error) {
+			return viciconn.Started(ctx, session)
+		},
+	}
+}
+
+func (h *RoadWarriorHandler) overview(ctx context.Context) (*models.RoadWarriorResponse, error) {
+	users, err := h.store.Users()
+	if err != nil {
+		return nil, err
+	}
+	pools, err := h.store.Pools()
+	if err != nil {
+		return nil, err
+	}
+	resp := &models.RoadWarriorResponse{
+		Users:   make([]roadwarrior.User, 0, len(users)),
+		Pools:   pools,
+		Leases:  []viciconn.Pool{},
+		Success: true,
+	}
+	for _, u := range users {
+		resp.Users = append(resp.Users, u.Redacted())
+	}
+	if leases, err := h.leases(ctx); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Leases = leases
+	}
+	return resp, nil
+}
+
+// Overview lists the users, the pools and their leases.
+func (h *RoadWarriorHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := h.overview(r.Context())
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "Failed to read the road-warrior users",
+			Error:   err.Error(),
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// AddUser adds a user with POST {"name", "auth", "password"} and loads its
+// secret into charon. An EAP-TLS user is issued a client certificate.
+func (h *RoadWarriorHandler) AddUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req roadwarrior.User
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRWRequestSize)).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	u, err := h.store.AddUser(h.now(), req)
+	if err != nil {
+		respondRWError(w, "Failed to add the user", err)
+		return
+	}
+
+	var issued *pki.Cert
+	if u.Auth == roadwarrior.AuthTLS {
+		if issued, err = h.issue(r.Context(), u.Name); err != nil {
+			if _, derr := h.store.DeleteUser(u.Name); derr != nil {
+				slog.Warn("Failed to remove the user without a certificate", "user", u.Name, "error", derr)
+			}
+			respondRWError(w, fmt.Sprintf("Failed to issue a certificate for '%s'", u.Name), err)
+			return
+		}
+	}
+	slog.Info("Added road-warrior user", append([]any{"user", u.Name, "auth", u.Auth}, h.caller.attrs(r)...)...)
+	h.notify()
+
+	if err := h.loadUser(r.Context(), u); err != nil {
+		respondJSON(w, http.StatusBadGateway, models.RoadWarriorUserResponse{
+			User:        u,
+			Certificate: issued,
+			Response: models.Response{
+				Success: false,
+				Message: fmt.Sprintf("Added '%s', but charon did not load its secret", u.Name),
+				Error:   err.Error(),
+			},
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, models.RoadWarriorUserResponse{
+		User:        u,
+		Certificate: issued,
+		Response:    models.Response{Success: true, Message: fmt.Sprintf("Added %s user '%s'", u.Auth, u.Name)},
+	})
+}
+
+// issue issues an EAP-TLS user's client certificate, with the user's name
+// as the identity charon matches against its EAP identity.
+func (h *RoadWarriorHandler) issue(ctx context.Context, name string) (*pki.Cert, error) {
+	ca, err := h.pki.openCA(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	issued, err := ca.Issue(pki.Request{Name: name, Profile: pki.ProfileClient, SANs: []string{name}})
+	if err != nil {
+		return nil, err
+	}
+	h.pki.notify()
+	return issued, nil
+}
+
+// User serves DELETE /api/roadwarrior/users/{name} and POST
+// /api/roadwarrior/users/{name}/profile with {"format", "password"}.
+func (h *RoadWarriorHandler) User(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, rwUsersPrefix), "/")
+	if name == "" {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "User name is required",
+			Error:   "expected " + rwUsersPrefix + "{name}",
+		})
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodDelete:
+		h.deleteUser(w, r, name)
+	case action == "profile" && r.Method == http.MethodPost:
+		h.profile(w, r, name)
+	case action == "" || action == "profile":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *RoadWarriorHandler) deleteUser(w http.ResponseWriter, r *http.Request, name string) {
+	u, err := h.store.DeleteUser(name)
+	if err != nil {
+		respondRWError(w, fmt.Sprintf("Failed to delete '%s'", name), err)
+		return
+	}
+	slog.Info("Deleted road-warrior user", append([]any{"user", name}, h.caller.attrs(r)...)...)
+	h.notify()
+
+	var errs []error
+	errs = append(errs, h.unloadUser(r.Context(), u))
+	if u.Auth == roadwarrior.AuthTLS {
+		errs = append(errs, h.revoke(r.Context(), name))
+	}
+	if err := errors.Join(errs...); err != nil {
+		respondJSON(w, http.StatusBadGateway, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Deleted '%s', but charon may still accept it", name),
+			Error:   err.Error(),
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, models.Response{Success: true, Message: fmt.Sprintf("Deleted '%s'", name)})
+}
+
+// revoke revokes an EAP-TLS user's certificate and loads the new CRL.
+func (h *RoadWarriorHandler) revoke(ctx context.Context, name string) error {
+	ca, err := h.pki.existingCA(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := ca.Revoke(name); err != nil {
+		if errors.Is(err, pki.ErrRevoked) || errors.Is(err, pki.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	h.pki.notify()
+	return h.pki.install(ctx, ca)
+}
+
+func (h *RoadWarriorHandler) profile(w http.ResponseWriter, r *http.Request, name string) {
+	var req models.ProfileRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRWRequestSize)).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	data, err := h.generate(r.Context(), name, &req)
+	if err != nil {
+		respondRWError(w, fmt.Sprintf("Failed to generate a profile for '%s'", name), err)
+		return
+	}
+	slog.Info("Generated road-warrior profile", append([]any{"user", name, "format", req.Format}, h.caller.attrs(r)...)...)
+	respondFile(w, roadwarrior.ContentType(req.Format), name+"."+req.Format, data)
+}
+
+func (h *RoadWarriorHandler) generate(ctx context.Context, name string, req *models.ProfileRequest) ([]byte, error) {
+	u, err := h.store.User(name)
+	if err != nil {
+		return nil, err
+	}
+	p := &roadwarrior.Profile{
+		User:           u,
+		Name:           h.cfg.ProfileName,
+		Server:         h.cfg.Server,
+		ServerID:       h.cfg.ServerID,
+		PKCS12Password: req.Password,
+	}
+
+	ca, err := h.pki.openCA(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if ca != nil {
+		p.CACert = ca.Certificate()
+	}
+	if u.Auth == roadwarrior.AuthTLS {
+		if ca == nil {
+			return nil, fmt.Errorf("%w: no CA created yet", pki.ErrNotFound)
+		}
+		// Apple and Android clients only import the legacy PKCS#12
+		// encryption.
+		legacy := req.Format != roadwarrior.FormatPowerShell
+		if p.PKCS12, err = ca.Export(name, pki.FormatPKCS12, req.Password, legacy); err != nil {
+			return nil, err
+		}
+	}
+	return roadwarrior.Generate(req.Format, p)
+}
+
+// AddPool adds or replaces a pool with POST {"name", "addrs", "dns"} and
+// loads it into charon.
+func (h *RoadWarriorHandler) AddPool(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req roadwarrior.Pool
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRWRequestSize)).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	p, err := h.store.PutPool(req)
+	if err != nil {
+		respondRWError(w, "Failed to add the pool", err)
+		return
+	}
+	slog.Info("Stored virtual IP pool", append([]any{"pool", p.Name, "addrs", p.Addrs}, h.caller.attrs(r)...)...)
+	h.notify()
+
+	if err := h.loadPool(r.Context(), p); err != nil {
+		respondJSON(w, http.StatusBadGateway, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Stored pool '%s', but charon did not load it", p.Name),
+			Error:   err.Error(),
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, models.Response{Success: true, Message: fmt.Sprintf("Loaded pool '%s' (%s)", p.Name, p.Addrs)})
+}
+
+// Pool serves DELETE /api/roadwarrior/pools/{name}.
+func (h *RoadWarriorHandler) Pool(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, rwPoolsPrefix)
+	if name == "" || strings.Contains(name, "/") {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Pool name is required",
+			Error:   "expected " + rwPoolsPrefix + "{name}",
+		})
+		return
+	}
+
+	// charon refuses to unload a pool with online leases, so it is only
+	// removed from the store once charon let go of it.
+	if err := h.unloadPool(r.Context(), name); err != nil {
+		respondJSON(w, http.StatusBadGateway, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("charon did not unload pool '%s'", name),
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := h.store.DeletePool(name); err != nil {
+		respondRWError(w, fmt.Sprintf("Failed to delete pool '%s'", name), err)
+		return
+	}
+	slog.Info("Deleted virtual IP pool", append([]any{"pool", name}, h.caller.attrs(r)...)...)
+	h.notify()
+	respondJSON(w, http.StatusOK, models.Response{Success: true, Message: fmt.Sprintf("Deleted pool '%s'", name)})
+}
+
+func respondRWError(w http.ResponseWriter, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, roadwarrior.ErrNotFound), errors.Is(err, pki.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, roadwarrior.ErrInvalid), errors.Is(err, pki.ErrInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, roadwarrior.ErrExists), errors.Is(err, pki.ErrExists), errors.Is(err, pki.ErrRevoked):
+		status = http.StatusConflict
+	}
+	respondJSON(w, status, models.Response{
+		Success: false,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
+
+func (h *RoadWarriorHandler) notify() {
+	select {
+	case h.changed <- struct{}{}:
+	default:
+	}
+}
+
+// sync loads the users and pools into charon.
+func (h *RoadWarriorHandler) sync(ctx context.Context) error {
+	users, err := h.store.Users()
+	if err != nil {
+		return err
+	}
+	pools, err := h.store.Pools()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range pools {
+		if err := h.loadPool(ctx, &pools[i]); err != nil {
+			errs = append(errs, fmt.Errorf("pool %s: %w", pools[i].Name, err))
+		}
+	}
+	for i := range users {
+		if err := h.loadUser(ctx, &users[i]); err != nil {
+			errs = append(errs, fmt.Errorf("user %s: %w", users[i].Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// missing reports whether charon lacks the secret of a user or a pool, as
+// after the credentials are cleared when one is deleted through /api/certs,
+// or after `tailswan reload`.
+func (h *RoadWarriorHandler) missing(ctx context.Context) (bool, error) {
+	users, err := h.store.Users()
+	if err != nil {
+		return false, err
+	}
+	pools, err := h.store.Pools()
+	if err != nil {
+		return false, err
+	}
+	shared, err := h.sharedIDs(ctx)
+	if err != nil {
+		return false, err
+	}
+	loaded, err := h.leases(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range users {
+		if users[i].Auth == roadwarrior.AuthMSCHAPv2 && !slices.Contains(shared, roadwarrior.SecretID(users[i].Name)) {
+			return true, nil
+		}
+	}
+	for i := range pools {
+		if !slices.ContainsFunc(loaded, func(p viciconn.Pool) bool { return p.Name == pools[i].Name }) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// resync loads the users and pools into charon when it started since the
+// last sync, or lacks any of them. It returns charon's start time as of the
+// last successful sync.
+func (h *RoadWarriorHandler) resync(ctx context.Context, synced string) string {
+	started, err := h.started(ctx)
+	if err != nil {
+		slog.Info("Error reading charon's start time", "error", err)
+		return synced
+	}
+	if started == synced {
+		missing, err := h.missing(ctx)
+		if err != nil {
+			slog.Info("Error checking the road-warrior users and pools in charon", "error", err)
+			return synced
+		}
+		if !missing {
+			return synced
+		}
+		slog.Info("Charon lacks road-warrior users or pools, loading them again")
+	}
+	if err := h.sync(ctx); err != nil {
+		slog.Warn("Failed to load the road-warrior users and pools", "error", err)
+		return synced
+	}
+	return started
+}
+
+// Watch loads the users and pools into charon whenever charon (re)starts,
+// since it forgets what was loaded over VICI, or lacks any of them, and
+// publishes a roadwarrior-update event when the users, pools or leases
+// change.
+func (h *RoadWarriorHandler) Watch(ctx context.Context, publisher EventPublisher) {
+	ticker := time.NewTicker(rwPollInterval)
+	defer ticker.Stop()
+
+	var synced string
+	var last *models.RoadWarriorResponse
+	for {
+		synced = h.resync(ctx, synced)
+
+		resp, err := h.overview(ctx)
+		if err != nil {
+			slog.Info("Error reading the road-warrior users", "error", err)
+		} else if last == nil || !reflect.DeepEqual(resp, last) {
+			last = resp
+			publisher.Publish("roadwarrior-update", resp)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.changed:
+		}
+	}
+}
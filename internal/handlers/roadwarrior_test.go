@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/pki"
+	"github.com/klowdo/tailswan/internal/roadwarrior"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+type fakeCharon struct {
+	secrets map[string]string
+	pools   map[string]string
+	started string
+	busy    bool
+}
+
+func newTestRoadWarriorHandler(t *testing.T) (*RoadWarriorHandler, *fakeCharon, *[]string) {
+	t.Helper()
+	pkiHandler, loaded := newTestPKIHandler(t)
+	h := NewRoadWarriorHandler(&config.Config{
+		StateDir: t.TempDir(),
+		RW:       config.RoadWarriorConfig{Server: "vpn.example.com", ProfileName: "TailSwan VPN"},
+	}, nil, pkiHandler, nil)
+	h.now = func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) }
+
+	charon := &fakeCharon{secrets: map[string]string{}, pools: map[string]string{}, started: "boot-1"}
+	h.loadUser = func(_ context.Context, u *roadwarrior.User) error {
+		if u.Auth == roadwarrior.AuthMSCHAPv2 {
+			charon.secrets[u.Name] = u.Password
+		}
+		return nil
+	}
+	h.unloadUser = func(_ context.Context, u *roadwarrior.User) error {
+		delete(charon.secrets, u.Name)
+		return nil
+	}
+	h.loadPool = func(_ context.Context, p *roadwarrior.Pool) error {
+		charon.pools[p.Name] = p.Addrs
+		return nil
+	}
+	h.unloadPool = func(_ context.Context, name string) error {
+		if charon.busy {
+			return errors.New("pool has online leases")
+		}
+		delete(charon.pools, name)
+		return nil
+	}
+	h.leases = func(context.Context) ([]viciconn.Pool, error) {
+		var pools []viciconn.Pool
+		for name, addrs := range charon.pools {
+			pools = append(pools, viciconn.Pool{Name: name, Base: addrs, Leases: []viciconn.Lease{}})
+		}
+		return pools, nil
+	}
+	h.sharedIDs = func(context.Context) ([]string, error) {
+		var ids []string
+		for name := range charon.secrets {
+			ids = append(ids, roadwarrior.SecretID(name))
+		}
+		return ids, nil
+	}
+	h.started = func(context.Context) (string, error) { return charon.started, nil }
+	return h, charon, loaded
+}
+
+func TestRoadWarriorHandler_MSCHAPv2User(t *testing.T) {
+	h, charon, _ := newTestRoadWarriorHandler(t)
+
+	rec := servePKI(h.AddUser, http.MethodPost, "/api/roadwarrior/users", `{"name":"alice@example.com"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("add: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var added models.RoadWarriorUserResponse
+	if err := json.NewDecoder(rec.Body).Decode(&added); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if added.User.Password == "" || charon.secrets["alice@example.com"] != added.User.Password {
+		t.Fatalf("expected the generated password returned and loaded, got %+v and %v", added.User, charon.secrets)
+	}
+
+	rec = servePKI(h.Overview, http.MethodGet, "/api/roadwarrior", "")
+	if strings.Contains(rec.Body.String(), added.User.Password) {
+		t.Error("the overview should not reveal passwords")
+	}
+
+	rec = servePKI(h.User, http.MethodPost, "/api/roadwarrior/users/alice@example.com/profile", `{"format":"mobileconfig"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), added.User.Password) {
+		t.Fatalf("profile: unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-apple-aspen-config" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+
+	if rec := servePKI(h.User, http.MethodDelete, "/api/roadwarrior/users/alice@example.com", ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(charon.secrets) != 0 {
+		t.Errorf("expected the secret unloaded, got %v", charon.secrets)
+	}
+}
+
+func TestRoadWarriorHandler_TLSUser(t *testing.T) {
+	h, _, loaded := newTestRoadWarriorHandler(t)
+
+	rec := servePKI(h.AddUser, http.MethodPost, "/api/roadwarrior/users", `{"name":"bob","auth":"eap-tls"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("add: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var added models.RoadWarriorUserResponse
+	if err := json.NewDecoder(rec.Body).Decode(&added); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if added.Certificate == nil || added.Certificate.Profile != pki.ProfileClient || !slices.Equal(added.Certificate.SANs, []string{"bob"}) {
+		t.Fatalf("expected a client certificate for bob, got %+v", added.Certificate)
+	}
+
+	if rec := servePKI(h.User, http.MethodPost, "/api/roadwarrior/users/bob/profile", `{"format":"sswan"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("profile without a password: expected status 400, got %d", rec.Code)
+	}
+	rec = servePKI(h.User, http.MethodPost, "/api/roadwarrior/users/bob/profile", `{"format":"sswan","password":"secret"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"p12"`) {
+		t.Fatalf("profile: unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+
+	*loaded = nil
+	if rec := servePKI(h.User, http.MethodDelete, "/api/roadwarrior/users/bob", ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !slices.Contains(*loaded, "crl/"+pki.CRLName) {
+		t.Errorf("expected the CRL loaded after deleting an EAP-TLS user, got %v", *loaded)
+	}
+
+	if rec := servePKI(h.AddUser, http.MethodPost, "/api/roadwarrior/users", `{"name":"bob","auth":"eap-tls"}`); rec.Code != http.StatusOK {
+		t.Errorf("re-add: a revoked certificate should not block a new one, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRoadWarriorHandler_Pools(t *testing.T) {
+	h, charon, _ := newTestRoadWarriorHandler(t)
+
+	rec := servePKI(h.AddPool, http.MethodPost, "/api/roadwarrior/pools", `{"name":"rw","addrs":"10.10.0.0/24","dns":["10.1.0.53"]}`)
+	if rec.Code != http.StatusOK || charon.pools["rw"] != "10.10.0.0/24" {
+		t.Fatalf("add: unexpected response %d %s, pools %v", rec.Code, rec.Body.String(), charon.pools)
+	}
+	if rec := servePKI(h.AddPool, http.MethodPost, "/api/roadwarrior/pools", `{"name":"bad","addrs":"10.10.0.0"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad addrs: expected status 400, got %d", rec.Code)
+	}
+
+	rec = servePKI(h.Overview, http.MethodGet, "/api/roadwarrior", "")
+	var overview models.RoadWarriorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&overview); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(overview.Pools) != 1 || len(overview.Leases) != 1 || overview.Leases[0].Base != "10.10.0.0/24" {
+		t.Errorf("unexpected overview %+v", overview)
+	}
+
+	charon.busy = true
+	if rec := servePKI(h.Pool, http.MethodDelete, "/api/roadwarrior/pools/rw", ""); rec.Code != http.StatusBadGateway {
+		t.Errorf("busy pool: expected status 502, got %d", rec.Code)
+	}
+	if pools, _ := h.store.Pools(); len(pools) != 1 {
+		t.Error("a pool charon keeps should stay in the store")
+	}
+	charon.busy = false
+	if rec := servePKI(h.Pool, http.MethodDelete, "/api/roadwarrior/pools/rw", ""); rec.Code != http.StatusOK {
+		t.Errorf("delete: expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRoadWarriorHandler_Errors(t *testing.T) {
+	tests := []struct {
+		handler func(h *RoadWarriorHandler) http.HandlerFunc
+		name    string
+		method  string
+		path    string
+		body    string
+		status  int
+	}{
+		{name: "bad auth", handler: func(h *RoadWarriorHandler) http.HandlerFunc { return h.AddUser }, method: http.MethodPost, path: "/api/roadwarrior/users", body: `{"name":"alice","auth":"psk"}`, status: http.StatusBadRequest},
+		{name: "bad json", handler: func(h *RoadWarriorHandler) http.HandlerFunc { return h.AddUser }, method: http.MethodPost, path: "/api/roadwarrior/users", body: `{`, status: http.StatusBadRequest},
+		{name: "add method", handler: func(h *RoadWarriorHandler) http.HandlerFunc { return h.AddUser }, method: http.MethodGet, path: "/api/roadwarrior/users", status: http.StatusMethodNotAllowed},
+		{name: "unknown user", handler: func(h *RoadWarriorHandler) http.HandlerFunc { return h.User }, method: http.MethodDelete, path: "/api/roadwarrior/users/carol", status: http.StatusNotFound},
+		{name: "unknown user profile", handler: func(h *RoadWarriorHandler) http.HandlerFunc { return h.User }, method: http.MethodPost, path: "/api/roadwarrior/users/carol/profile", body: `{"format":"sswan"}`, status: http.StatusNotFound},
+		{name: "unknown action", handler: func(h *RoadWarriorHandler) http.HandlerFunc { return h.User }, method: http.MethodPost, path: "/api/roadwarrior/users/carol/reset", status: http.StatusNotFound},
+		{name: "missing name", handler: func(h *RoadWarriorHandler) http.HandlerFunc { return h.User }, method: http.MethodDelete, path: "/api/roadwarrior/users/", status: http.StatusBadRequest},
+		{name: "pool method", handler: func(h *RoadWarriorHandler) http.HandlerFunc { return h.Pool }, method: http.MethodGet, path: "/api/roadwarrior/pools/rw", status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _, _ := newTestRoadWarriorHandler(t)
+			if rec := servePKI(tt.handler(h), tt.method, tt.path, tt.body); rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRoadWarriorHandler_WatchReloadsAfterRestart(t *testing.T) {
+	h, charon, _ := newTestRoadWarriorHandler(t)
+	servePKI(h.AddUser, http.MethodPost, "/api/roadwarrior/users", `{"name":"alice","password":"secret"}`)
+	servePKI(h.AddPool, http.MethodPost, "/api/roadwarrior/pools", `{"name":"rw","addrs":"10.10.0.0/24"}`)
+
+	// charon restarted and forgot everything loaded over VICI.
+	charon.secrets = map[string]string{}
+	charon.pools = map[string]string{}
+	charon.started = "boot-2"
+
+	publisher := &recordingPublisher{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Watch(ctx, publisher)
+	waitForEvents(t, publisher, 1)
+	cancel()
+
+	if charon.secrets["alice"] != "secret" || charon.pools["rw"] != "10.10.0.0/24" {
+		t.Errorf("expected the users and pools reloaded, got %v and %v", charon.secrets, charon.pools)
+	}
+}
+
+func TestRoadWarriorHandler_ResyncReloadsMissing(t *testing.T) {
+	h, charon, _ := newTestRoadWarriorHandler(t)
+	servePKI(h.AddUser, http.MethodPost, "/api/roadwarrior/users", `{"name":"alice","password":"secret"}`)
+	servePKI(h.AddPool, http.MethodPost, "/api/roadwarrior/pools", `{"name":"rw","addrs":"10.10.0.0/24"}`)
+	ctx := context.Background()
+
+	if synced := h.resync(ctx, "boot-1"); synced != "boot-1" || charon.secrets["alice"] != "secret" {
+		t.Fatalf("expected nothing to reload, got %q and %v", synced, charon.secrets)
+	}
+
+	// Deleting a credential through /api/certs clears all credentials
+	// loaded over VICI, and `tailswan reload` unloads the pools, while
+	// charon keeps running.
+	charon.secrets = map[string]string{}
+	charon.pools = map[string]string{}
+	if synced := h.resync(ctx, "boot-1"); synced != "boot-1" {
+		t.Errorf("expected the start time kept, got %q", synced)
+	}
+	if charon.secrets["alice"] != "secret" || charon.pools["rw"] != "10.10.0.0/24" {
+		t.Errorf("expected the users and pools reloaded, got %v and %v", charon.secrets, charon.pools)
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/schedule"
+)
+
+const schedulePollInterval = 10 * time.Second
+
+// ScheduleHandler shows the connection schedules and manages leases. The
+// supervisor's scheduler reads the same configuration and lease file and
+// does the initiating and terminating.
+type ScheduleHandler struct {
+	leases  *schedule.LeaseStore
+	now     func() time.Time
+	caller  CallerFunc
+	changed chan struct{}
+	// configErr is why SWAN_SCHEDULES could not be parsed; the
+	// supervisor refuses to start with it, so this only shows up when
+	// the control server runs on its own.
+	configErr string
+	windows   []schedule.Window
+}
+
+func NewScheduleHandler(cfg *config.Config, caller CallerFunc) *ScheduleHandler {
+	h := &ScheduleHandler{
+		leases:  schedule.NewLeaseStore(cfg.StateDir),
+		now:     time.Now,
+		caller:  caller,
+		changed: make(chan struct{}, 1),
+	}
+	windows, err := schedule.ParseWindows(cfg.Swan.Schedules)
+	if err != nil {
+		slog.Warn("Invalid SWAN_SCHEDULES", "error", err)
+		h.configErr = err.Error()
+	}
+	h.windows = windows
+	return h
+}
+
+func (h *ScheduleHandler) plan() (*models.ScheduleResponse, error) {
+	now := h.now()
+	leases, err := h.leases.Active(now)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ScheduleResponse{
+		Connections: schedule.Plan(now, h.windows, leases),
+		Error:       h.configErr,
+		Success:     true,
+	}, nil
+}
+
+// Schedules lists the scheduled and leased connections with their desired
+// state and next transition.
+func (h *ScheduleHandler) Schedules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := h.plan()
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "Failed to read connection leases",
+			Error:   err.Error(),
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// Leases grants a lease with POST {"name", "minutes"} and cancels one with
+// DELETE {"name"}.
+func (h *ScheduleHandler) Leases(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.LeaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if req.Name == "" {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Connection name is required",
+			Error:   "name field cannot be empty",
+		})
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		h.cancel(w, r, req.Name)
+		return
+	}
+
+	owner := h.caller.of(r).Login
+	if owner == "" {
+		owner = "api"
+	}
+	lease, err := h.leases.Grant(h.now(), req.Name, owner, time.Duration(req.Minutes)*time.Minute)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to lease connection '%s'", req.Name),
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	slog.Info("Granted connection lease", append([]any{"connection", req.Name, "until", lease.Until}, h.caller.attrs(r)...)...)
+	h.notify()
+	respondJSON(w, http.StatusOK, models.LeaseResponse{
+		Lease:   &lease,
+		Message: fmt.Sprintf("Connection '%s' is up until %s", req.Name, lease.Until.Format(time.RFC3339)),
+		Success: true,
+	})
+}
+
+func (h *ScheduleHandler) cancel(w http.ResponseWriter, r *http.Request, name string) {
+	found, err := h.leases.Cancel(h.now(), name)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to cancel lease of '%s'", name),
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !found {
+		respondJSON(w, http.StatusNotFound, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Connection '%s' has no active lease", name),
+			Error:   "no active lease",
+		})
+		return
+	}
+
+	slog.Info("Cancelled connection lease", append([]any{"connection", name}, h.caller.attrs(r)...)...)
+	h.notify()
+	respondJSON(w, http.StatusOK, models.LeaseResponse{
+		Message: fmt.Sprintf("Lease of '%s' cancelled", name),
+		Success: true,
+	})
+}
+
+func (h *ScheduleHandler) notify() {
+	select {
+	case h.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Watch publishes a schedule-update event when a connection's desired
+// state, lease or next transition changes.
+func (h *ScheduleHandler) Watch(ctx context.Context, publisher EventPublisher) {
+	ticker := time.NewTicker(schedulePollInterval)
+	defer ticker.Stop()
+
+	var last []schedule.Status
+	for {
+		resp, err := h.plan()
+		if err != nil {
+			slog.Info("Error reading connection leases", "error", err)
+		} else if last == nil || !reflect.DeepEqual(resp.Connections, last) {
+			last = resp.Connections
+			publisher.Publish("schedule-update", resp)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.changed:
+		}
+	}
+}
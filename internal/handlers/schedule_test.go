@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/schedule"
+)
+
+// Monday 2026-10-19 07:00, an hour before partner-a's window opens.
+var scheduleNow = time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+
+func newTestScheduleHandler(t *testing.T) *ScheduleHandler {
+	t.Helper()
+	h := NewScheduleHandler(&config.Config{
+		StateDir: t.TempDir(),
+		Swan:     config.SwanConfig{Schedules: "partner-a=0 8 * * mon-fri|0 18 * * mon-fri"},
+	}, func(*http.Request) Caller {
+		return Caller{Login: "alice@example.com"}
+	})
+	h.now = func() time.Time { return scheduleNow }
+	return h
+}
+
+func getSchedules(t *testing.T, h *ScheduleHandler) models.ScheduleResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.Schedules(rec, httptest.NewRequest(http.MethodGet, "/api/schedules", http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var resp models.ScheduleResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp
+}
+
+func waitForEvents(t *testing.T, publisher *recordingPublisher, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for publisher.count() < n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if publisher.count() != n {
+		t.Fatalf("expected %d schedule-update events, got %d", n, publisher.count())
+	}
+}
+
+func TestScheduleHandler_Schedules(t *testing.T) {
+	resp := getSchedules(t, newTestScheduleHandler(t))
+
+	if !resp.Success || len(resp.Connections) != 1 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	st := resp.Connections[0]
+	if st.Connection != "partner-a" || st.State != schedule.StateDown || st.Next == nil || st.Next.State != schedule.StateUp {
+		t.Errorf("unexpected status %+v", st)
+	}
+	if !st.Next.At.Equal(scheduleNow.Add(time.Hour)) {
+		t.Errorf("expected the window to open at 08:00, got %s", st.Next.At)
+	}
+}
+
+func TestScheduleHandler_GrantAndCancelLease(t *testing.T) {
+	h := newTestScheduleHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/schedules/leases", strings.NewReader(`{"name":"partner-b","minutes":30}`))
+	rec := httptest.NewRecorder()
+	h.Leases(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	resp := getSchedules(t, h)
+	if len(resp.Connections) != 2 {
+		t.Fatalf("expected the leased connection to be listed, got %+v", resp.Connections)
+	}
+	leased := resp.Connections[1]
+	if leased.Connection != "partner-b" || leased.State != schedule.StateUp || leased.Reason != schedule.ReasonLease {
+		t.Errorf("unexpected status %+v", leased)
+	}
+	if leased.Lease == nil || leased.Lease.Owner != "alice@example.com" {
+		t.Errorf("expected the lease owner to be the caller, got %+v", leased.Lease)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Leases(rec, httptest.NewRequest(http.MethodDelete, "/api/schedules/leases", strings.NewReader(`{"name":"partner-b"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if resp := getSchedules(t, h); len(resp.Connections) != 1 {
+		t.Errorf("expected the lease to be gone, got %+v", resp.Connections)
+	}
+}
+
+func TestScheduleHandler_LeaseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{name: "wrong method", method: http.MethodGet, body: "", status: http.StatusMethodNotAllowed},
+		{name: "invalid json", method: http.MethodPost, body: "{", status: http.StatusBadRequest},
+		{name: "missing name", method: http.MethodPost, body: `{"minutes":5}`, status: http.StatusBadRequest},
+		{name: "zero minutes", method: http.MethodPost, body: `{"name":"partner-b"}`, status: http.StatusBadRequest},
+		{name: "too long", method: http.MethodPost, body: `{"name":"partner-b","minutes":20000}`, status: http.StatusBadRequest},
+		{name: "cancel without lease", method: http.MethodDelete, body: `{"name":"partner-b"}`, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestScheduleHandler(t)
+			rec := httptest.NewRecorder()
+			h.Leases(rec, httptest.NewRequest(tt.method, "/api/schedules/leases", strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestScheduleHandler_WatchPublishesLeaseChanges(t *testing.T) {
+	h := newTestScheduleHandler(t)
+	pub := &recordingPublisher{}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go h.Watch(ctx, pub)
+
+	waitForEvents(t, pub, 1)
+
+	rec := httptest.NewRecorder()
+	h.Leases(rec, httptest.NewRequest(http.MethodPost, "/api/schedules/leases", strings.NewReader(`{"name":"partner-b","minutes":5}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	waitForEvents(t, pub, 2)
+}
+
+func TestScheduleHandler_InvalidConfig(t *testing.T) {
+	h := NewScheduleHandler(&config.Config{StateDir: t.TempDir(), Swan: config.SwanConfig{Schedules: "partner-a=bogus"}}, nil)
+
+	resp := getSchedules(t, h)
+	if resp.Error == "" || len(resp.Connections) != 0 {
+		t.Errorf("expected the config error to be reported, got %+v", resp)
+	}
+}
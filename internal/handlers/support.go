@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/bundle"
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+)
+
+const supportBundleTimeout = 2 * time.Minute
+
+type SupportHandler struct {
+	config    *config.Config
+	tsHandler *TailscaleHandler
+}
+
+func NewSupportHandler(cfg *config.Config, tsHandler *TailscaleHandler) *SupportHandler {
+	return &SupportHandler{
+		config:    cfg,
+		tsHandler: tsHandler,
+	}
+}
+
+func (h *SupportHandler) Bundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), supportBundleTimeout)
+	defer cancel()
+
+	// Collecting can outlast the server's default write timeout.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(supportBundleTimeout)); err != nil {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := bundle.New(h.config, h.tsHandler.LocalClient()).Write(ctx, &buf); err != nil {
+		respondJSON(w, http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "Failed to create support bundle",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+bundle.FileName(time.Now())+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/confighistory"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/statefile"
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/swanload"
+	"github.com/klowdo/tailswan/internal/templates"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const (
+	templatesPrefix        = "/api/templates/"
+	maxTemplateRequestSize = 64 << 10
+)
+
+// TemplatesHandler renders connection templates, checks the result and
+// loads it. Loaded connections are saved to conf.d next to SWAN_CONFIG so
+// they survive restarts.
+type TemplatesHandler struct {
+	history *HistoryHandler
+	conns   func(ctx context.Context) ([]viciconn.Conn, error)
+	load    func(ctx context.Context, root *swanconf.Section) error
+	dir     string
+}
+
+func NewTemplatesHandler(cfg *config.Config, session *vici.Session, history *HistoryHandler) *TemplatesHandler {
+	dir := cfg.Swan.CredentialsDir()
+	return &TemplatesHandler{
+		history: history,
+		dir:     dir,
+		conns: func(ctx context.Context) ([]viciconn.Conn, error) {
+			return viciconn.Conns(ctx, session)
+		},
+		load: func(ctx context.Context, root *swanconf.Section) error {
+			return swanload.Load(ctx, session, root, dir)
+		},
+	}
+}
+
+// List serves GET /api/templates.
+func (h *TemplatesHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	respondJSON(w, http.StatusOK, models.TemplatesResponse{Success: true, Templates: templates.All()})
+}
+
+// Template serves GET /api/templates/{name}, which describes a template,
+// and POST, which renders it from a models.TemplateRequest.
+func (h *TemplatesHandler) Template(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, templatesPrefix)
+	tmpl := templates.Lookup(name)
+	if tmpl == nil {
+		respondJSON(w, http.StatusNotFound, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Template '%s' not found", name),
+			Error:   "unknown template",
+		})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		respondJSON(w, http.StatusOK, tmpl)
+	case http.MethodPost:
+		h.render(w, r, tmpl)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *TemplatesHandler) render(w http.ResponseWriter, r *http.Request, tmpl *templates.Template) {
+	var req models.TemplateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTemplateRequestSize)).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	rendered, err := tmpl.Render(req.Values)
+	var verr *templates.ValidationError
+	if errors.As(err, &verr) {
+		respondJSON(w, http.StatusUnprocessableEntity, models.TemplateResponse{
+			Response: models.Response{Success: false, Message: "Invalid parameters", Error: err.Error()},
+			Fields:   verr.Fields,
+		})
+		return
+	}
+	if err == nil {
+		err = swanload.Check(rendered.Config, h.dir)
+	}
+	if err != nil {
+		respondJSON(w, http.StatusUnprocessableEntity, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to render '%s'", tmpl.Name),
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	resp := models.TemplateResponse{
+		Response:    models.Response{Success: true, Message: "Rendered " + strings.Join(rendered.Connections(), ", ")},
+		Config:      rendered.String(),
+		Connections: rendered.Connections(),
+		Notes:       rendered.Notes,
+	}
+	if !req.Load {
+		respondJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	message := fmt.Sprintf("Loaded %s from template %s", strings.Join(rendered.Connections(), ", "), tmpl.Name)
+	if req.ConfirmTimeout > 0 {
+		p, status, err := h.history.Begin(r, req.ConfirmTimeout, message)
+		if err != nil {
+			respondJSON(w, status, models.Response{
+				Success: false,
+				Message: fmt.Sprintf("Failed to load '%s'", rendered.Name),
+				Error:   err.Error(),
+			})
+			return
+		}
+		resp.Pending = p
+	}
+
+	status, err := h.save(r.Context(), rendered)
+	if err != nil {
+		if resp.Pending != nil {
+			h.history.Cancel()
+		}
+		respondJSON(w, status, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to load '%s'", rendered.Name),
+			Error:   err.Error(),
+		})
+		return
+	}
+	resp.File = h.file(rendered.Name)
+	resp.Loaded = true
+	resp.Message = "Loaded " + strings.Join(rendered.Connections(), ", ")
+	if resp.Pending != nil {
+		resp.Message += fmt.Sprintf(", confirm within %d seconds to keep them", req.ConfirmTimeout)
+	}
+	slog.Info("Loaded connections from template", "template", tmpl.Name, "connections", rendered.Connections(), "file", resp.File, "confirm_timeout", req.ConfirmTimeout)
+	h.history.Record(r, confighistory.SourceAPI, message)
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func (h *TemplatesHandler) file(name string) string {
+	return filepath.Join(h.dir, "conf.d", name+".conf")
+}
+
+// save writes the rendered connections to conf.d and loads them. It never
+// replaces a loaded connection or an existing file, and removes the file
+// again when charon refuses the connections.
+func (h *TemplatesHandler) save(ctx context.Context, rendered *templates.Rendered) (int, error) {
+	if h.dir == "" {
+		return http.StatusServiceUnavailable, fmt.Errorf("SWAN_CONFIG is not set")
+	}
+	conns, err := h.conns(ctx)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	for _, name := range rendered.Connections() {
+		if slices.ContainsFunc(conns, func(c viciconn.Conn) bool { return c.Name == name }) {
+			return http.StatusConflict, fmt.Errorf("connection %s is already loaded", name)
+		}
+	}
+	path := h.file(rendered.Name)
+	if _, err := os.Stat(path); err == nil {
+		return http.StatusConflict, fmt.Errorf("%s already exists", path)
+	}
+
+	if err := statefile.WriteAtomic(path, []byte(rendered.String())); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err := h.load(ctx, rendered.Config); err != nil {
+		if rmErr := os.Remove(path); rmErr != nil {
+			slog.Warn("Failed to remove the rejected configuration", "file", path, "error", rmErr)
+		}
+		return http.StatusBadGateway, err
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const testTemplateRequest = `{"values": {
+	"name": "partner-b",
+	"remote_addrs": "198.51.100.1",
+	"psk": "correct-horse-battery-staple",
+	"local_ts": "10.1.0.0/16",
+	"remote_ts": "10.2.0.0/16"
+}%s}`
+
+func newTestTemplatesHandler(t *testing.T) (*TemplatesHandler, *[]*swanconf.Section) {
+	t.Helper()
+	configPath := filepath.Join(t.TempDir(), "swanctl.conf")
+	writeTestConfig(t, configPath, "include conf.d/*.conf\n")
+	history, _ := newTestHistoryHandler(t, configPath)
+	h := NewTemplatesHandler(&config.Config{Swan: config.SwanConfig{ConfigPath: configPath}}, nil, history)
+	h.conns = func(context.Context) ([]viciconn.Conn, error) {
+		return []viciconn.Conn{{Name: "partner-a"}}, nil
+	}
+	var loaded []*swanconf.Section
+	h.load = func(_ context.Context, root *swanconf.Section) error {
+		loaded = append(loaded, root)
+		return nil
+	}
+	return h, &loaded
+}
+
+func TestTemplatesHandler_List(t *testing.T) {
+	h, _ := newTestTemplatesHandler(t)
+
+	rec := servePKI(h.List, http.MethodGet, "/api/templates", "")
+	var resp models.TemplatesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Templates) != 5 || len(resp.Templates[0].Params) == 0 {
+		t.Errorf("expected the built-in templates with their parameters, got %+v", resp.Templates)
+	}
+
+	rec = servePKI(h.Template, http.MethodGet, "/api/templates/unknown", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown template, got %d", rec.Code)
+	}
+}
+
+func TestTemplatesHandler_Render(t *testing.T) {
+	h, loaded := newTestTemplatesHandler(t)
+
+	body := strings.Replace(testTemplateRequest, `"psk": "correct-horse-battery-staple"`, `"psk": "short"`, 1)
+	rec := servePKI(h.Template, http.MethodPost, "/api/templates/psk-site-to-site", strings.Replace(body, "%s", "", 1))
+	var resp models.TemplateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusUnprocessableEntity || resp.Fields["psk"] == "" {
+		t.Errorf("expected the short key to be reported, got %d %+v", rec.Code, resp)
+	}
+
+	rec = servePKI(h.Template, http.MethodPost, "/api/templates/psk-site-to-site", strings.Replace(testTemplateRequest, "%s", "", 1))
+	resp = models.TemplateResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || resp.Loaded || !strings.Contains(resp.Config, "partner-b {") {
+		t.Errorf("expected a rendered config, got %d %+v", rec.Code, resp)
+	}
+	if len(*loaded) != 0 {
+		t.Error("rendering alone must not load anything")
+	}
+}
+
+func TestTemplatesHandler_Load(t *testing.T) {
+	h, loaded := newTestTemplatesHandler(t)
+
+	rec := servePKI(h.Template, http.MethodPost, "/api/templates/psk-site-to-site", strings.Replace(testTemplateRequest, "%s", `, "load": true`, 1))
+	var resp models.TemplateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || !resp.Loaded {
+		t.Fatalf("expected the connection to be loaded, got %d %+v", rec.Code, resp)
+	}
+	if len(*loaded) != 1 || (*loaded)[0].Section("connections", "partner-b") == nil {
+		t.Errorf("expected partner-b to be loaded, got %v", *loaded)
+	}
+	data, err := os.ReadFile(resp.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(resp.File) != "partner-b.conf" || !strings.Contains(string(data), "ike-partner-b") {
+		t.Errorf("unexpected file %s:\n%s", resp.File, data)
+	}
+	latest, err := h.history.store.Latest()
+	if err != nil || latest == nil || latest.Files["conf.d/partner-b.conf"] == "" {
+		t.Errorf("expected the loaded file to be recorded, got %+v, %v", latest, err)
+	}
+
+	// A second load would overwrite the file.
+	rec = servePKI(h.Template, http.MethodPost, "/api/templates/psk-site-to-site", strings.Replace(testTemplateRequest, "%s", `, "load": true`, 1))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 for an existing file, got %d", rec.Code)
+	}
+
+	body := strings.Replace(testTemplateRequest, `"name": "partner-b"`, `"name": "partner-a"`, 1)
+	rec = servePKI(h.Template, http.MethodPost, "/api/templates/psk-site-to-site", strings.Replace(body, "%s", `, "load": true`, 1))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a loaded connection, got %d", rec.Code)
+	}
+}
+
+func TestTemplatesHandler_LoadRejected(t *testing.T) {
+	h, _ := newTestTemplatesHandler(t)
+	h.load = func(context.Context, *swanconf.Section) error {
+		return errors.New("proposal mismatch")
+	}
+
+	rec := servePKI(h.Template, http.MethodPost, "/api/templates/psk-site-to-site", strings.Replace(testTemplateRequest, "%s", `, "load": true`, 1))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Stat(filepath.Join(h.dir, "conf.d", "partner-b.conf")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the rejected file to be removed, got %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/strongswan/govici/vici"
@@ -14,10 +15,11 @@ import (
 
 type VICIHandler struct {
 	session         *vici.Session
+	caller          CallerFunc
 	configuredConns []string
 }
 
-func NewVICIHandler(configured []string) (*VICIHandler, error) {
+func NewVICIHandler(configured []string, caller CallerFunc) (*VICIHandler, error) {
 	session, err := vici.NewSession()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create VICI session: %w", err)
@@ -25,6 +27,7 @@ func NewVICIHandler(configured []string) (*VICIHandler, error) {
 
 	return &VICIHandler{
 		session:         session,
+		caller:          caller,
 		configuredConns: configured,
 	}, nil
 }
@@ -75,6 +78,7 @@ func (h *VICIHandler) ConnectionUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	slog.Info("Initiating connection", append([]any{"connection", req.Name}, h.caller.attrs(r)...)...)
 	_, err := h.session.Call(context.Background(), "initiate", msg)
 	if err != nil {
 		respondJSON(w, http.StatusInternalServerError, models.Response{
@@ -126,6 +130,7 @@ func (h *VICIHandler) ConnectionDown(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	slog.Info("Terminating connection", append([]any{"connection", req.Name}, h.caller.attrs(r)...)...)
 	_, err := h.session.Call(context.Background(), "terminate", msg)
 	if err != nil {
 		respondJSON(w, http.StatusInternalServerError, models.Response{
@@ -171,11 +176,7 @@ func (h *VICIHandler) ListSAs(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
-		saMap := make(map[string]interface{})
-		for _, key := range m.Keys() {
-			saMap[key] = m.Get(key)
-		}
-		sas = append(sas, saMap)
+		sas = append(sas, viciconn.Map(m))
 	}
 
 	respondJSON(w, http.StatusOK, models.SAsResponse{
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/watchdog"
+)
+
+const watchdogPollInterval = 5 * time.Second
+
+// TunnelHealthHandler reads the probe results the supervisor's watchdog
+// writes to the state directory.
+type TunnelHealthHandler struct {
+	stateDir string
+	enabled  bool
+}
+
+func NewTunnelHealthHandler(cfg *config.Config) *TunnelHealthHandler {
+	return &TunnelHealthHandler{
+		stateDir: cfg.StateDir,
+		enabled:  cfg.Watchdog.Enabled(),
+	}
+}
+
+// State returns nil when no connection has a probe or the watchdog has not
+// started yet.
+func (h *TunnelHealthHandler) State() (*watchdog.State, error) {
+	if h == nil || !h.enabled {
+		return nil, nil
+	}
+	return watchdog.ReadState(h.stateDir)
+}
+
+// Watch publishes a tunnel-health event after every probe round.
+func (h *TunnelHealthHandler) Watch(ctx context.Context, publisher EventPublisher) {
+	if !h.enabled {
+		return
+	}
+
+	ticker := time.NewTicker(watchdogPollInterval)
+	defer ticker.Stop()
+
+	var last time.Time
+	for {
+		st, err := h.State()
+		if err != nil {
+			slog.Info("Error reading watchdog state", "error", err)
+		} else if st != nil && !st.Updated.Equal(last) {
+			last = st.Updated
+			publisher.Publish("tunnel-health", st)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
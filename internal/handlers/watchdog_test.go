@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/config"
+	"github.com/klowdo/tailswan/internal/models"
+	"github.com/klowdo/tailswan/internal/watchdog"
+)
+
+func newTestTunnelHealthHandler(t *testing.T) *TunnelHealthHandler {
+	t.Helper()
+	cfg := &config.Config{
+		StateDir: t.TempDir(),
+		Watchdog: config.WatchdogConfig{Probes: "partner-a=icmp:10.2.0.10;partner-b=tcp:10.3.0.5:443"},
+	}
+	probes, err := watchdog.ParseProbes(cfg.Watchdog.Probes)
+	if err != nil {
+		t.Fatalf("ParseProbes() error = %v", err)
+	}
+
+	dog := watchdog.New(probes, 3)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	dog.Record("partner-a", 12*time.Millisecond, nil, now)
+	dog.Record("partner-b", 0, errors.New("connection refused"), now)
+	dog.Restarted("partner-b", now)
+	dog.Record("partner-b", 0, errors.New("connection refused"), now)
+	if err := watchdog.WriteState(cfg.StateDir, dog.State(now)); err != nil {
+		t.Fatalf("WriteState() error = %v", err)
+	}
+	return NewTunnelHealthHandler(cfg)
+}
+
+func TestHealthHandler_ReportsTunnelHealth(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewHealthHandler(&config.Config{}, nil, nil, newTestTunnelHealthHandler(t)).Check(rec, httptest.NewRequest(http.MethodGet, "/api/health", http.NoBody))
+
+	var resp models.HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Success {
+		t.Error("failing probes should not make the control server unhealthy")
+	}
+	if len(resp.Tunnels) != 2 || !resp.Tunnels[0].Healthy() || resp.Tunnels[1].Status != watchdog.StatusFailing {
+		t.Errorf("unexpected tunnel health %+v", resp.Tunnels)
+	}
+}
+
+func TestMetricsHandler_TunnelHealth(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewMetricsHandler(nil, newTestTunnelHealthHandler(t)).Metrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"tailswan_tunnel_healthy{connection=\"partner-a\",probe=\"icmp\",target=\"10.2.0.10\"} 1\n",
+		"tailswan_tunnel_healthy{connection=\"partner-b\",probe=\"tcp\",target=\"10.3.0.5:443\"} 0\n",
+		"tailswan_tunnel_probe_failures{connection=\"partner-b\",probe=\"tcp\",target=\"10.3.0.5:443\"} 1\n",
+		"tailswan_tunnel_probe_latency_seconds{connection=\"partner-a\",probe=\"icmp\",target=\"10.2.0.10\"} 0.012\n",
+		"tailswan_tunnel_restarts_total{connection=\"partner-b\",probe=\"tcp\",target=\"10.3.0.5:443\"} 1\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q in:\n%s", want, body)
+		}
+	}
+	if n := strings.Count(body, "# TYPE tailswan_tunnel_healthy gauge"); n != 1 {
+		t.Errorf("expected one TYPE line per metric, got %d", n)
+	}
+}
+
+func TestTunnelHealthHandler_Watch(t *testing.T) {
+	handler := newTestTunnelHealthHandler(t)
+	publisher := &recordingPublisher{}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go handler.Watch(ctx, publisher)
+
+	deadline := time.Now().Add(time.Second)
+	for publisher.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if publisher.count() != 1 {
+		t.Fatalf("expected one tunnel-health event, got %d", publisher.count())
+	}
+}
+
+func TestTunnelHealthHandler_Disabled(t *testing.T) {
+	handler := NewTunnelHealthHandler(&config.Config{StateDir: t.TempDir()})
+	st, err := handler.State()
+	if err != nil || st != nil {
+		t.Errorf("State() = %v, %v; want nil, nil", st, err)
+	}
+}
@@ -0,0 +1,272 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+
+	"github.com/klowdo/tailswan/internal/certs"
+	"github.com/klowdo/tailswan/internal/supervisor"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+// charonProcess is started first by the supervisor, so its latest start
+// marks the current run in the process history.
+const charonProcess = "ipsec"
+
+func (c *Checker) vici(ctx context.Context) (string, string) {
+	session, err := vici.NewSession()
+	if err != nil {
+		return StatusFail, fmt.Sprintf("charon not reachable over VICI: %v", err)
+	}
+	defer session.Close() //nolint:errcheck
+
+	stats, err := session.Call(ctx, "stats", nil)
+	if err != nil {
+		return StatusFail, fmt.Sprintf("VICI stats: %v", err)
+	}
+	return evalStats(stats)
+}
+
+func evalStats(stats *vici.Message) (string, string) {
+	reason := "charon answers"
+	if uptime, ok := stats.Get("uptime").(*vici.Message); ok {
+		if running := viciconn.StringValue(uptime.Get("running")); running != "" {
+			reason = "charon up " + running
+		}
+	}
+	if ikesas, ok := stats.Get("ikesas").(*vici.Message); ok {
+		if total := viciconn.StringValue(ikesas.Get("total")); total != "" {
+			reason += fmt.Sprintf(", %s IKE_SAs", total)
+		}
+	}
+	return StatusOK, reason
+}
+
+func (c *Checker) tailscale(ctx context.Context) (string, string) {
+	if c.Tailscale == nil {
+		return StatusSkip, "tailscaled is not reachable from here"
+	}
+	status, err := c.Tailscale.StatusWithoutPeers(ctx)
+	if err != nil {
+		return StatusFail, fmt.Sprintf("tailscaled not responding: %v", err)
+	}
+	return evalTailscale(status)
+}
+
+func evalTailscale(status *ipnstate.Status) (string, string) {
+	if status.BackendState != ipn.Running.String() {
+		return StatusFail, fmt.Sprintf("backend state is %s", status.BackendState)
+	}
+	if len(status.Health) > 0 {
+		return StatusFail, "health warnings: " + strings.Join(status.Health, "; ")
+	}
+	if len(status.TailscaleIPs) > 0 {
+		return StatusOK, fmt.Sprintf("running as %s", status.TailscaleIPs[0])
+	}
+	return StatusOK, "running"
+}
+
+func (c *Checker) routes(ctx context.Context) (string, string) {
+	if c.Tailscale == nil {
+		return StatusSkip, "tailscaled is not reachable from here"
+	}
+	prefs, err := c.Tailscale.GetPrefs(ctx)
+	if err != nil {
+		return StatusFail, fmt.Sprintf("read tailscale prefs: %v", err)
+	}
+	status, err := c.Tailscale.StatusWithoutPeers(ctx)
+	if err != nil {
+		return StatusFail, fmt.Sprintf("tailscaled not responding: %v", err)
+	}
+	return evalRoutes(prefs, status)
+}
+
+// evalRoutes compares the routes advertised in the prefs with the ones the
+// tailnet approved, which show up in the node's own AllowedIPs.
+func evalRoutes(prefs *ipn.Prefs, status *ipnstate.Status) (string, string) {
+	if len(prefs.AdvertiseRoutes) == 0 {
+		return StatusOK, "no routes advertised"
+	}
+
+	approved := make(map[netip.Prefix]bool)
+	if status.Self != nil && status.Self.AllowedIPs != nil {
+		for _, p := range status.Self.AllowedIPs.All() {
+			approved[p.Masked()] = true
+		}
+	}
+
+	var pending []string
+	for _, p := range prefs.AdvertiseRoutes {
+		if !approved[p.Masked()] {
+			pending = append(pending, p.Masked().String())
+		}
+	}
+	if len(pending) > 0 {
+		sort.Strings(pending)
+		return StatusFail, fmt.Sprintf("%d of %d advertised routes not approved: %s",
+			len(pending), len(prefs.AdvertiseRoutes), strings.Join(pending, ", "))
+	}
+	return StatusOK, fmt.Sprintf("%d advertised routes approved", len(prefs.AdvertiseRoutes))
+}
+
+func (c *Checker) children(ctx context.Context, required []string) (string, string) {
+	session, err := vici.NewSession()
+	if err != nil {
+		return StatusFail, fmt.Sprintf("charon not reachable over VICI: %v", err)
+	}
+	defer session.Close() //nolint:errcheck
+
+	sas, err := viciconn.ChildSAs(session)
+	if err != nil {
+		return StatusFail, fmt.Sprintf("list SAs: %v", err)
+	}
+	return evalChildren(sas, required)
+}
+
+// evalChildren requires an installed CHILD_SA for every required name,
+// which may be a connection or one of its children.
+func evalChildren(sas []viciconn.ChildSA, required []string) (string, string) {
+	installed := make(map[string]bool)
+	count := 0
+	for _, sa := range sas {
+		if sa.State != "INSTALLED" {
+			continue
+		}
+		count++
+		installed[sa.IKE] = true
+		installed[sa.Name] = true
+	}
+
+	if len(required) == 0 {
+		return StatusOK, fmt.Sprintf("%d CHILD_SAs installed, none required", count)
+	}
+
+	var missing []string
+	for _, name := range required {
+		if !installed[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return StatusFail, "not established: " + strings.Join(missing, ", ")
+	}
+	return StatusOK, fmt.Sprintf("%d required connections established", len(required))
+}
+
+func (c *Checker) processes(context.Context) (string, string) {
+	events, err := supervisor.ReadHistory(c.StateDir)
+	if err != nil {
+		return StatusFail, fmt.Sprintf("read process history: %v", err)
+	}
+	return evalProcesses(events, processAlive)
+}
+
+// evalProcesses looks at the latest event of every process since charon was
+// last started, so processes of an earlier run or configuration do not
+// count.
+func evalProcesses(events []supervisor.ProcessEvent, alive func(pid int) bool) (string, string) {
+	start := -1
+	for i, ev := range events {
+		if ev.Name == charonProcess && ev.Event == "started" {
+			start = i
+		}
+	}
+	if start < 0 {
+		return StatusFail, "no supervised processes recorded; is the supervisor running?"
+	}
+
+	latest := make(map[string]supervisor.ProcessEvent)
+	for _, ev := range events[start:] {
+		latest[ev.Name] = ev
+	}
+	names := make([]string, 0, len(latest))
+	for name := range latest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		ev := latest[name]
+		switch {
+		case ev.Event == "started" && !alive(ev.PID):
+			problems = append(problems, fmt.Sprintf("%s (pid %d) is gone", name, ev.PID))
+		case ev.Event == "exited":
+			problems = append(problems, describeExit(name, "exited", ev.Error))
+		case ev.Event == "failed":
+			problems = append(problems, describeExit(name, "failed to start", ev.Error))
+		}
+	}
+	if len(problems) > 0 {
+		return StatusFail, strings.Join(problems, "; ")
+	}
+	return StatusOK, strings.Join(names, ", ") + " running"
+}
+
+func describeExit(name, what, err string) string {
+	if err == "" {
+		return name + " " + what
+	}
+	return fmt.Sprintf("%s %s: %s", name, what, err)
+}
+
+func (c *Checker) certificates(context.Context) (string, string) {
+	if c.CredentialsDir == "" {
+		return StatusSkip, "no swanctl directory configured"
+	}
+	creds, err := certs.NewStore(c.CredentialsDir).List()
+	if err != nil {
+		return StatusWarn, fmt.Sprintf("list certificates: %v", err)
+	}
+	return evalCertificates(creds, time.Now(), c.CertExpiry)
+}
+
+// evalCertificates warns about certificates that expire within d; an
+// expired certificate only breaks the connections that use it.
+func evalCertificates(creds []certs.Credential, now time.Time, d time.Duration) (string, string) {
+	count := 0
+	for _, cred := range creds {
+		if cred.NotAfter != nil {
+			count++
+		}
+	}
+
+	expiring := certs.Expiring(creds, now, d)
+	if len(expiring) == 0 {
+		return StatusOK, fmt.Sprintf("%d certificates valid for more than %s", count, formatDays(d))
+	}
+	var parts []string
+	for _, cred := range expiring {
+		if cred.NotAfter.Before(now) {
+			parts = append(parts, fmt.Sprintf("%s expired %s", cred.Name, cred.NotAfter.Format(time.DateOnly)))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s expires %s", cred.Name, cred.NotAfter.Format(time.DateOnly)))
+		}
+	}
+	return StatusWarn, strings.Join(parts, "; ")
+}
+
+func formatDays(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,240 @@
+package health
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/types/views"
+
+	"github.com/klowdo/tailswan/internal/certs"
+	"github.com/klowdo/tailswan/internal/supervisor"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+func TestEvalStats(t *testing.T) {
+	uptime := vici.NewMessage()
+	if err := uptime.Set("running", "2 hours"); err != nil {
+		t.Fatal(err)
+	}
+	ikesas := vici.NewMessage()
+	if err := ikesas.Set("total", "3"); err != nil {
+		t.Fatal(err)
+	}
+	stats := vici.NewMessage()
+	if err := stats.Set("uptime", uptime); err != nil {
+		t.Fatal(err)
+	}
+	if err := stats.Set("ikesas", ikesas); err != nil {
+		t.Fatal(err)
+	}
+
+	status, reason := evalStats(stats)
+	if status != StatusOK || reason != "charon up 2 hours, 3 IKE_SAs" {
+		t.Errorf("got %s %q", status, reason)
+	}
+}
+
+func TestEvalTailscale(t *testing.T) {
+	tests := []struct {
+		status *ipnstate.Status
+		name   string
+		want   string
+		reason string
+	}{
+		{
+			name:   "running",
+			status: &ipnstate.Status{BackendState: ipn.Running.String(), TailscaleIPs: []netip.Addr{netip.MustParseAddr("100.64.0.1")}},
+			want:   StatusOK,
+			reason: "running as 100.64.0.1",
+		},
+		{
+			name:   "needs login",
+			status: &ipnstate.Status{BackendState: ipn.NeedsLogin.String()},
+			want:   StatusFail,
+			reason: "backend state is NeedsLogin",
+		},
+		{
+			name:   "health warnings",
+			status: &ipnstate.Status{BackendState: ipn.Running.String(), Health: []string{"no DERP home"}},
+			want:   StatusFail,
+			reason: "health warnings: no DERP home",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reason := evalTailscale(tt.status)
+			if status != tt.want || reason != tt.reason {
+				t.Errorf("got %s %q, want %s %q", status, reason, tt.want, tt.reason)
+			}
+		})
+	}
+}
+
+func TestEvalRoutes(t *testing.T) {
+	allowed := views.SliceOf([]netip.Prefix{
+		netip.MustParsePrefix("100.64.0.1/32"),
+		netip.MustParsePrefix("10.2.0.0/24"),
+	})
+	status := &ipnstate.Status{Self: &ipnstate.PeerStatus{AllowedIPs: &allowed}}
+
+	tests := []struct {
+		name   string
+		want   string
+		reason string
+		routes []netip.Prefix
+	}{
+		{name: "nothing advertised", want: StatusOK, reason: "no routes advertised"},
+		{
+			name:   "approved",
+			routes: []netip.Prefix{netip.MustParsePrefix("10.2.0.0/24")},
+			want:   StatusOK,
+			reason: "1 advertised routes approved",
+		},
+		{
+			name:   "pending approval",
+			routes: []netip.Prefix{netip.MustParsePrefix("10.2.0.0/24"), netip.MustParsePrefix("10.3.0.0/24")},
+			want:   StatusFail,
+			reason: "1 of 2 advertised routes not approved: 10.3.0.0/24",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reason := evalRoutes(&ipn.Prefs{AdvertiseRoutes: tt.routes}, status)
+			if status != tt.want || reason != tt.reason {
+				t.Errorf("got %s %q, want %s %q", status, reason, tt.want, tt.reason)
+			}
+		})
+	}
+}
+
+func TestEvalChildren(t *testing.T) {
+	sas := []viciconn.ChildSA{
+		{IKE: "hq", Name: "hq-lan", State: "INSTALLED"},
+		{IKE: "branch", Name: "branch-lan", State: "REKEYING"},
+	}
+
+	tests := []struct {
+		name     string
+		want     string
+		reason   string
+		required []string
+	}{
+		{name: "none required", want: StatusOK, reason: "1 CHILD_SAs installed, none required"},
+		{name: "by connection", required: []string{"hq"}, want: StatusOK, reason: "1 required connections established"},
+		{name: "by child", required: []string{"hq-lan"}, want: StatusOK, reason: "1 required connections established"},
+		{name: "not installed", required: []string{"hq", "branch", "lab"}, want: StatusFail, reason: "not established: branch, lab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reason := evalChildren(sas, tt.required)
+			if status != tt.want || reason != tt.reason {
+				t.Errorf("got %s %q, want %s %q", status, reason, tt.want, tt.reason)
+			}
+		})
+	}
+}
+
+func TestEvalProcesses(t *testing.T) {
+	alive := func(pid int) bool { return pid != 13 }
+	earlier := []supervisor.ProcessEvent{
+		{Name: "ipsec", Event: "started", PID: 1},
+		{Name: "gobgpd", Event: "exited", PID: 2, Error: "exit status 1"},
+	}
+
+	tests := []struct {
+		name   string
+		want   string
+		reason string
+		events []supervisor.ProcessEvent
+	}{
+		{name: "no history", want: StatusFail, reason: "no supervised processes recorded; is the supervisor running?"},
+		{
+			name: "running",
+			events: append(earlier,
+				supervisor.ProcessEvent{Name: "ipsec", Event: "started", PID: 10},
+				supervisor.ProcessEvent{Name: "controlserver", Event: "started", PID: 11},
+				supervisor.ProcessEvent{Name: "tailscaled", Event: "started", PID: 12},
+			),
+			want:   StatusOK,
+			reason: "controlserver, ipsec, tailscaled running",
+		},
+		{
+			name: "exited and gone",
+			events: append(earlier,
+				supervisor.ProcessEvent{Name: "ipsec", Event: "started", PID: 10},
+				supervisor.ProcessEvent{Name: "controlserver", Event: "started", PID: 11},
+				supervisor.ProcessEvent{Name: "controlserver", Event: "exited", PID: 11, Error: "signal: killed"},
+				supervisor.ProcessEvent{Name: "tailscaled", Event: "started", PID: 13},
+			),
+			want:   StatusFail,
+			reason: "controlserver exited: signal: killed; tailscaled (pid 13) is gone",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reason := evalProcesses(tt.events, alive)
+			if status != tt.want || reason != tt.reason {
+				t.Errorf("got %s %q, want %s %q", status, reason, tt.want, tt.reason)
+			}
+		})
+	}
+}
+
+func TestEvalCertificates(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	at := func(y int, m time.Month, d int) *time.Time {
+		t := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	creds := []certs.Credential{
+		{Kind: certs.KindCert, Name: "gw.pem", NotAfter: at(2027, 10, 1)},
+		{Kind: certs.KindCA, Name: "ca.pem", NotAfter: at(2036, 1, 1)},
+		{Kind: certs.KindKey, Name: "gw.key"},
+	}
+
+	status, reason := evalCertificates(creds, now, 30*24*time.Hour)
+	if status != StatusOK || reason != "2 certificates valid for more than 30 days" {
+		t.Errorf("got %s %q", status, reason)
+	}
+
+	creds = append(creds,
+		certs.Credential{Kind: certs.KindCert, Name: "partner.pem", NotAfter: at(2026, 11, 1)},
+		certs.Credential{Kind: certs.KindCA, Name: "old-ca.pem", NotAfter: at(2026, 10, 1)},
+	)
+	status, reason = evalCertificates(creds, now, 30*24*time.Hour)
+	if status != StatusWarn || reason != "partner.pem expires 2026-11-01; old-ca.pem expired 2026-10-01" {
+		t.Errorf("got %s %q", status, reason)
+	}
+}
+
+func TestRunTimesOutSlowChecks(t *testing.T) {
+	c := &Checker{Timeout: 20 * time.Millisecond}
+	report := c.run(context.Background(), []check{
+		{name: "fast", fn: func(context.Context) (string, string) { return StatusOK, "fine" }},
+		{name: "skipped", fn: func(context.Context) (string, string) { return StatusSkip, "not here" }},
+		{name: "warning", fn: func(context.Context) (string, string) { return StatusWarn, "soon" }},
+		{name: "slow", fn: func(ctx context.Context) (string, string) {
+			time.Sleep(time.Second)
+			return StatusOK, "too late"
+		}},
+	})
+
+	if report.Healthy {
+		t.Error("a timed out check must make the report unhealthy")
+	}
+	if got := report.Components[0]; got.Name != "fast" || got.Status != StatusOK || got.Reason != "fine" {
+		t.Errorf("unexpected first component %+v", got)
+	}
+	if got := report.Components[3]; got.Status != StatusFail || !strings.Contains(got.Reason, "no answer within") {
+		t.Errorf("unexpected slow component %+v", got)
+	}
+	if failed := report.Failed(); len(failed) != 1 || failed[0].Name != "slow" {
+		t.Errorf("skipped checks and warnings are not failures, got %+v", failed)
+	}
+}
@@ -0,0 +1,150 @@
+// Package health checks the components a gateway needs to carry traffic:
+// charon over VICI, tailscaled, the approval of the advertised routes, the
+// required CHILD_SAs, the supervised processes and the expiry of the
+// certificates. The control server's
+// /api/health/live and /api/health/ready and `tailswan healthcheck` share
+// these checks.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"tailscale.com/client/local"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+	// StatusSkip is a component that cannot be checked from here, such as
+	// tsnet's embedded tailscaled from the CLI.
+	StatusSkip = "skip"
+
+	ComponentVICI      = "vici"
+	ComponentTailscale = "tailscale"
+	ComponentRoutes    = "routes"
+	ComponentChildren  = "children"
+	ComponentProcesses = "processes"
+	ComponentCerts     = "certificates"
+
+	DefaultTimeout = 3 * time.Second
+)
+
+// Component is the result of one check.
+type Component struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Reason    string  `json:"reason"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+type Report struct {
+	Components []Component `json:"components"`
+	Healthy    bool        `json:"healthy"`
+}
+
+// Failed returns the components that failed.
+func (r *Report) Failed() []Component {
+	var failed []Component
+	for _, c := range r.Components {
+		if c.Status == StatusFail {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Checker runs the checks against the local daemons.
+type Checker struct {
+	// Tailscale is nil when tailscaled cannot be reached from here; its
+	// checks are then skipped.
+	Tailscale *local.Client
+	// StateDir holds the supervisor's process history.
+	StateDir string
+	// CredentialsDir is the swanctl directory; certificates are not
+	// checked when it is empty.
+	CredentialsDir string
+	// CertExpiry is how long before expiry a certificate is a warning.
+	CertExpiry time.Duration
+	// Timeout bounds each check. Zero means DefaultTimeout.
+	Timeout time.Duration
+}
+
+type check struct {
+	fn   func(ctx context.Context) (status, reason string)
+	name string
+}
+
+// Live checks what has to work for the gateway to be worth keeping: charon
+// answers and no supervised process has died.
+func (c *Checker) Live(ctx context.Context) *Report {
+	return c.run(ctx, []check{
+		{name: ComponentVICI, fn: c.vici},
+		{name: ComponentProcesses, fn: c.processes},
+	})
+}
+
+// Ready checks that the gateway can carry traffic: on top of Live,
+// tailscaled is running, the advertised routes are approved and the
+// required connections have an installed CHILD_SA. Expiring certificates
+// are a warning only.
+func (c *Checker) Ready(ctx context.Context, required []string) *Report {
+	return c.run(ctx, []check{
+		{name: ComponentVICI, fn: c.vici},
+		{name: ComponentTailscale, fn: c.tailscale},
+		{name: ComponentRoutes, fn: c.routes},
+		{name: ComponentChildren, fn: func(ctx context.Context) (string, string) { return c.children(ctx, required) }},
+		{name: ComponentProcesses, fn: c.processes},
+		{name: ComponentCerts, fn: c.certificates},
+	})
+}
+
+// run runs the checks concurrently. A check that does not return within
+// the timeout fails; charon's VICI calls cannot all be cancelled, so it is
+// left to finish in the background.
+func (c *Checker) run(ctx context.Context, checks []check) *Report {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	report := &Report{Components: make([]Component, len(checks)), Healthy: true}
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Components[i] = runCheck(ctx, chk, timeout)
+		}()
+	}
+	wg.Wait()
+
+	report.Healthy = len(report.Failed()) == 0
+	return report
+}
+
+func runCheck(ctx context.Context, chk check, timeout time.Duration) Component {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct{ status, reason string }
+	done := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		status, reason := chk.fn(ctx)
+		done <- result{status: status, reason: reason}
+	}()
+
+	comp := Component{Name: chk.name}
+	select {
+	case r := <-done:
+		comp.Status, comp.Reason = r.status, r.reason
+	case <-ctx.Done():
+		comp.Status, comp.Reason = StatusFail, fmt.Sprintf("no answer within %s", timeout)
+	}
+	comp.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	return comp
+}
@@ -0,0 +1,186 @@
+// Package identity maps IPsec clients to the virtual IPs they were
+// assigned, so they can be told apart on the tailnet, and declares which
+// tailnet destinations each identity may reach.
+package identity
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"strings"
+
+	"github.com/klowdo/tailswan/internal/firewall"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+// Rule admits the identities matching Pattern, a glob such as
+// *@example.com, to the Allowed tailnet destinations.
+type Rule struct {
+	Pattern string
+	Allowed []netip.Prefix
+}
+
+// Policy is the access granted to IPsec clients. An empty policy enforces
+// nothing; otherwise identities no rule matches cannot reach the tailnet.
+type Policy []Rule
+
+// ParsePolicy parses RW_ACCESS: rules such as
+// alice@example.com=100.64.1.10,10.1.0.0/16 separated by semicolons.
+func ParsePolicy(s string) (Policy, error) {
+	var policy Policy
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		// Distinguished names contain '=', prefixes never do.
+		i := strings.LastIndex(entry, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("bad rule %q, expected <identity>=<prefix>,...", entry)
+		}
+		rule := Rule{Pattern: strings.TrimSpace(entry[:i])}
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad identity pattern %q: %w", rule.Pattern, err)
+		}
+		for _, dst := range strings.Split(entry[i+1:], ",") {
+			prefix, err := parseDestination(strings.TrimSpace(dst))
+			if err != nil {
+				return nil, fmt.Errorf("rule for %s: %w", rule.Pattern, err)
+			}
+			rule.Allowed = append(rule.Allowed, prefix)
+		}
+		policy = append(policy, rule)
+	}
+	return policy, nil
+}
+
+func parseDestination(s string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("bad destination %q, expected an address or a prefix", s)
+	}
+	return prefix.Masked(), nil
+}
+
+// Allowed returns the destinations of every rule matching identity.
+func (p Policy) Allowed(identity string) []netip.Prefix {
+	var allowed []netip.Prefix
+	for _, rule := range p {
+		if ok, _ := path.Match(rule.Pattern, identity); !ok {
+			continue
+		}
+		for _, prefix := range rule.Allowed {
+			if !containsPrefix(allowed, prefix) {
+				allowed = append(allowed, prefix)
+			}
+		}
+	}
+	return allowed
+}
+
+func containsPrefix(prefixes []netip.Prefix, p netip.Prefix) bool {
+	for _, q := range prefixes {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+// FirewallRules limits each mapped client's virtual IPs to the
+// destinations the policy allows its identity.
+func (p Policy) FirewallRules(mappings []Mapping) []firewall.IdentityRule {
+	rules := make([]firewall.IdentityRule, 0, len(mappings))
+	for i := range mappings {
+		m := &mappings[i]
+		rule := firewall.IdentityRule{Identity: m.Identity, Allowed: p.Allowed(m.Identity)}
+		for _, s := range m.Addresses {
+			if addr, err := netip.ParseAddr(s); err == nil {
+				rule.Addresses = append(rule.Addresses, addr)
+			}
+		}
+		if len(rule.Addresses) > 0 {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// PoolPrefixes covers the addresses of the loaded virtual IP pools, so
+// clients that connected since the rules were last applied are held back
+// until their identity's rule is in place. The cover may include the
+// address past a pool's end.
+func PoolPrefixes(pools []viciconn.Pool) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, pool := range pools {
+		base, err := netip.ParseAddr(pool.Base)
+		if err != nil || pool.Size <= 0 {
+			continue
+		}
+		prefixes = append(prefixes, rangePrefixes(base, add(base, uint64(pool.Size)))...)
+	}
+	return prefixes
+}
+
+// rangePrefixes returns the fewest prefixes covering exactly first to last.
+func rangePrefixes(first, last netip.Addr) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for first.IsValid() && !last.Less(first) {
+		best := netip.PrefixFrom(first, first.BitLen())
+		for bits := first.BitLen() - 1; bits >= 0; bits-- {
+			p := netip.PrefixFrom(first, bits)
+			if p.Masked().Addr() != first || last.Less(lastAddr(p)) {
+				break
+			}
+			best = p
+		}
+		prefixes = append(prefixes, best)
+		end := lastAddr(best)
+		if end == last {
+			break
+		}
+		first = end.Next()
+	}
+	return prefixes
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().As16()
+	offset := 0
+	if p.Addr().Is4() {
+		offset = 96
+	}
+	for bit := offset + p.Bits(); bit < 128; bit++ {
+		b[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr := netip.AddrFrom16(b)
+	if p.Addr().Is4() {
+		return addr.Unmap()
+	}
+	return addr
+}
+
+// add returns addr+n, saturating at the end of its family.
+func add(addr netip.Addr, n uint64) netip.Addr {
+	b := addr.As16()
+	carry := n
+	for i := 15; i >= 0 && carry > 0; i-- {
+		sum := uint64(b[i]) + carry&0xff
+		b[i] = byte(sum)
+		carry = carry>>8 + sum>>8
+	}
+	sum := netip.AddrFrom16(b)
+	if addr.Is4() {
+		if carry > 0 || !sum.Unmap().Is4() {
+			return lastAddr(netip.PrefixFrom(netip.IPv4Unspecified(), 0))
+		}
+		return sum.Unmap()
+	}
+	if carry > 0 {
+		return lastAddr(netip.PrefixFrom(netip.IPv6Unspecified(), 0))
+	}
+	return sum
+}
@@ -0,0 +1,100 @@
+package identity
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+func prefixes(s ...string) []netip.Prefix {
+	var ps []netip.Prefix
+	for _, p := range s {
+		ps = append(ps, netip.MustParsePrefix(p))
+	}
+	return ps
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("alice@example.com=100.64.1.10,10.1.0.5/16; *@example.com=100.64.1.20 ;C=SE, O=Example, CN=carol=fd7a:115c:a1e0::1;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy) != 3 {
+		t.Fatalf("expected 3 rules, got %+v", policy)
+	}
+	if policy[2].Pattern != "C=SE, O=Example, CN=carol" {
+		t.Errorf("expected the DN to keep its '=', got %q", policy[2].Pattern)
+	}
+
+	got := policy.Allowed("alice@example.com")
+	if want := prefixes("100.64.1.10/32", "10.1.0.0/16", "100.64.1.20/32"); !slices.Equal(got, want) {
+		t.Errorf("expected the union of matching rules %v, got %v", want, got)
+	}
+	if got := policy.Allowed("bob@example.com"); !slices.Equal(got, prefixes("100.64.1.20/32")) {
+		t.Errorf("unexpected destinations for bob %v", got)
+	}
+	if got := policy.Allowed("C=SE, O=Example, CN=carol"); !slices.Equal(got, prefixes("fd7a:115c:a1e0::1/128")) {
+		t.Errorf("unexpected destinations for carol %v", got)
+	}
+	if got := policy.Allowed("mallory@example.org"); got != nil {
+		t.Errorf("expected no destinations for an unmatched identity, got %v", got)
+	}
+
+	if empty, err := ParsePolicy(" "); err != nil || len(empty) != 0 {
+		t.Errorf("expected an empty policy, got %v, %v", empty, err)
+	}
+	for _, bad := range []string{"alice", "=10.0.0.1", "alice=", "alice=10.0.0.300", "[a=10.0.0.1"} {
+		if _, err := ParsePolicy(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestPolicy_FirewallRules(t *testing.T) {
+	policy, err := ParsePolicy("alice=100.64.1.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := policy.FirewallRules([]Mapping{
+		{Identity: "alice", Addresses: []string{"10.10.0.1", "fd00:10::1"}},
+		{Identity: "bob", Addresses: []string{"10.10.0.2"}},
+		{Identity: "broken", Addresses: []string{"nope"}},
+	})
+	if len(rules) != 2 {
+		t.Fatalf("expected rules for alice and bob, got %+v", rules)
+	}
+	if rules[0].Identity != "alice" || len(rules[0].Addresses) != 2 || !slices.Equal(rules[0].Allowed, prefixes("100.64.1.10/32")) {
+		t.Errorf("unexpected rule %+v", rules[0])
+	}
+	if rules[1].Identity != "bob" || rules[1].Allowed != nil {
+		t.Errorf("expected bob to be allowed nothing, got %+v", rules[1])
+	}
+}
+
+func TestPoolPrefixes(t *testing.T) {
+	got := PoolPrefixes([]viciconn.Pool{
+		{Name: "v4", Base: "10.10.0.0", Size: 255},
+		{Name: "odd", Base: "10.20.0.3", Size: 4},
+		{Name: "v6", Base: "fd00:10::", Size: 1 << 16},
+		{Name: "broken", Base: "", Size: 8},
+	})
+	want := prefixes("10.10.0.0/24", "10.20.0.3/32", "10.20.0.4/30", "fd00:10::/112", "fd00:10::1:0/128")
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRangePrefixes(t *testing.T) {
+	last := netip.MustParseAddr("255.255.255.255")
+	if got := rangePrefixes(netip.MustParseAddr("255.255.255.254"), last); !slices.Equal(got, prefixes("255.255.255.254/31")) {
+		t.Errorf("unexpected prefixes at the end of the address space %v", got)
+	}
+	if got := add(netip.MustParseAddr("255.255.255.250"), 100); got != last {
+		t.Errorf("expected add to saturate, got %v", got)
+	}
+	if got := add(netip.MustParseAddr("10.0.0.255"), 1); got != netip.MustParseAddr("10.0.1.0") {
+		t.Errorf("expected the carry to propagate, got %v", got)
+	}
+}
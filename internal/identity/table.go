@@ -0,0 +1,146 @@
+package identity
+
+import (
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+// Mapping is an IPsec client's identity with the virtual IPs of its
+// IKE_SA. Allowed lists the tailnet destinations it may reach when access
+// is enforced.
+type Mapping struct {
+	Since      time.Time `json:"since"`
+	Identity   string    `json:"identity"`
+	RemoteID   string    `json:"remote_id"`
+	Connection string    `json:"connection"`
+	RemoteHost string    `json:"remote_host"`
+	UniqueID   string    `json:"unique_id"`
+	Addresses  []string  `json:"addresses"`
+	Allowed    []string  `json:"allowed,omitempty"`
+}
+
+// Mappings returns the IKE_SAs that were assigned virtual IPs.
+func Mappings(ids []viciconn.IKEIdentity, now time.Time) []Mapping {
+	var mappings []Mapping
+	for i := range ids {
+		if m, ok := mapping(&ids[i], now); ok {
+			mappings = append(mappings, m)
+		}
+	}
+	sortMappings(mappings)
+	return mappings
+}
+
+func mapping(id *viciconn.IKEIdentity, now time.Time) (Mapping, bool) {
+	if len(id.VirtualIPs) == 0 {
+		return Mapping{}, false
+	}
+	return Mapping{
+		Since:      now.Add(-time.Duration(id.Established) * time.Second).UTC().Truncate(time.Second),
+		Identity:   id.Identity(),
+		RemoteID:   id.RemoteID,
+		Connection: id.Connection,
+		RemoteHost: id.RemoteHost,
+		UniqueID:   id.UniqueID,
+		Addresses:  id.VirtualIPs,
+	}, true
+}
+
+func sortMappings(mappings []Mapping) {
+	sort.Slice(mappings, func(i, j int) bool {
+		if mappings[i].Identity != mappings[j].Identity {
+			return mappings[i].Identity < mappings[j].Identity
+		}
+		return mappings[i].UniqueID < mappings[j].UniqueID
+	})
+}
+
+// Table keeps the current mappings. It is reset from list-sas and kept up
+// to date in between from ike-updown events.
+type Table struct {
+	byID   map[string]Mapping
+	now    func() time.Time
+	policy Policy
+	mu     sync.Mutex
+}
+
+func NewTable(policy Policy) *Table {
+	return &Table{byID: make(map[string]Mapping), now: time.Now, policy: policy}
+}
+
+// Reset replaces the mappings with the IKE_SAs charon lists.
+func (t *Table) Reset(ids []viciconn.IKEIdentity) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	previous := t.byID
+	t.byID = make(map[string]Mapping)
+	t.add(ids)
+	// Since is derived from charon's whole seconds; keep the first reading
+	// so it does not jitter from reset to reset.
+	for id, m := range t.byID {
+		if p, ok := previous[id]; ok && p.Identity == m.Identity {
+			m.Since = p.Since
+			t.byID[id] = m
+		}
+	}
+}
+
+// Update applies an ike-updown event.
+func (t *Table) Update(ids []viciconn.IKEIdentity, up bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if up {
+		t.add(ids)
+		return
+	}
+	for i := range ids {
+		delete(t.byID, ids[i].UniqueID)
+	}
+}
+
+func (t *Table) add(ids []viciconn.IKEIdentity) {
+	now := t.now()
+	for i := range ids {
+		if m, ok := mapping(&ids[i], now); ok {
+			t.byID[m.UniqueID] = m
+		}
+	}
+}
+
+// List returns the mappings sorted by identity, with the destinations the
+// policy allows when it is enforced.
+func (t *Table) List() []Mapping {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	mappings := make([]Mapping, 0, len(t.byID))
+	for _, m := range t.byID {
+		if len(t.policy) > 0 {
+			m.Allowed = []string{}
+			for _, p := range t.policy.Allowed(m.Identity) {
+				m.Allowed = append(m.Allowed, p.String())
+			}
+		}
+		mappings = append(mappings, m)
+	}
+	sortMappings(mappings)
+	return mappings
+}
+
+// Lookup returns the mapping holding addr.
+func (t *Table) Lookup(addr netip.Addr) (Mapping, bool) {
+	for _, m := range t.List() {
+		for _, s := range m.Addresses {
+			if a, err := netip.ParseAddr(s); err == nil && a == addr {
+				return m, true
+			}
+		}
+	}
+	return Mapping{}, false
+}
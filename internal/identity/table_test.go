@@ -0,0 +1,67 @@
+package identity
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+var testNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func TestTable(t *testing.T) {
+	policy, err := ParsePolicy("*@example.com=100.64.1.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := NewTable(policy)
+	table.now = func() time.Time { return testNow }
+
+	alice := viciconn.IKEIdentity{Connection: "rw", UniqueID: "7", RemoteID: "198.51.100.7", EAPID: "alice@example.com", VirtualIPs: []string{"10.10.0.1"}, Established: 60}
+	site := viciconn.IKEIdentity{Connection: "partner", UniqueID: "3", RemoteID: "partner.example.com"}
+	table.Reset([]viciconn.IKEIdentity{alice, site})
+
+	got := table.List()
+	if len(got) != 1 {
+		t.Fatalf("expected only the IKE_SA with virtual IPs, got %+v", got)
+	}
+	if got[0].Identity != "alice@example.com" || !got[0].Since.Equal(testNow.Add(-time.Minute)) {
+		t.Errorf("unexpected mapping %+v", got[0])
+	}
+	if !slices.Equal(got[0].Allowed, []string{"100.64.1.10/32"}) {
+		t.Errorf("expected the policy's destinations, got %v", got[0].Allowed)
+	}
+
+	table.now = func() time.Time { return testNow.Add(1500 * time.Millisecond) }
+	alice.Established = 61
+	table.Reset([]viciconn.IKEIdentity{alice, site})
+	if since := table.List()[0].Since; !since.Equal(testNow.Add(-time.Minute)) {
+		t.Errorf("expected a reset to keep the first reading of Since, got %v", since)
+	}
+
+	bob := viciconn.IKEIdentity{Connection: "rw", UniqueID: "9", RemoteID: "bob", VirtualIPs: []string{"10.10.0.2"}}
+	table.Update([]viciconn.IKEIdentity{bob}, true)
+	got = table.List()
+	if len(got) != 2 || got[1].Identity != "bob" {
+		t.Fatalf("expected bob to be added, got %+v", got)
+	}
+	if got[1].Allowed == nil || len(got[1].Allowed) != 0 {
+		t.Errorf("expected bob to be allowed nothing, got %#v", got[1].Allowed)
+	}
+
+	if m, ok := table.Lookup(netip.MustParseAddr("10.10.0.2")); !ok || m.UniqueID != "9" {
+		t.Errorf("expected 10.10.0.2 to be bob's, got %+v, %v", m, ok)
+	}
+	table.Update([]viciconn.IKEIdentity{bob}, false)
+	if _, ok := table.Lookup(netip.MustParseAddr("10.10.0.2")); ok {
+		t.Error("expected bob's address to be released")
+	}
+
+	unenforced := NewTable(nil)
+	unenforced.Reset([]viciconn.IKEIdentity{alice})
+	if got := unenforced.List(); len(got) != 1 || got[0].Allowed != nil {
+		t.Errorf("expected no destinations without a policy, got %+v", got)
+	}
+}
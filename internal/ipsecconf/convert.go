@@ -0,0 +1,794 @@
+package ipsecconf
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+)
+
+// Warning is an option that was not translated or needs attention.
+type Warning struct {
+	Section string
+	Option  string
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Option == "" {
+		return w.Section + ": " + w.Message
+	}
+	return w.Section + ": " + w.Option + ": " + w.Message
+}
+
+// Result is the converted configuration and what to review in it.
+type Result struct {
+	Config   *swanconf.Section
+	Warnings []Warning
+}
+
+// String renders the configuration with the warnings as a leading comment.
+func (r *Result) String() string {
+	var b strings.Builder
+	b.WriteString("# Converted from ipsec.conf by tailswan import\n")
+	if len(r.Warnings) > 0 {
+		b.WriteString("#\n# Review before loading:\n")
+		for _, w := range r.Warnings {
+			b.WriteString("#   " + w.String() + "\n")
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(r.Config.String())
+	return b.String()
+}
+
+// The stroke defaults for the lifetimes, which differ from swanctl's.
+const (
+	defaultIKELifetime   = 3 * 3600
+	defaultChildLifetime = 3600
+	defaultMargin        = 9 * 60
+)
+
+type converter struct {
+	res   *Result
+	pools *swanconf.Section
+	// poolNames maps a pool's addresses and DNS servers to its name, so
+	// conns sharing a range share the pool as they did under stroke.
+	poolNames map[string]string
+	// copies holds the files already reported as to be copied.
+	copies map[string]bool
+	unique string
+}
+
+// Convert translates conn and ca sections with auto other than ignore,
+// and secrets, to swanctl.conf. The left side is taken as the local one.
+func Convert(conf *Config, secrets []Secret) (*Result, error) {
+	cv := &converter{
+		res:       &Result{Config: &swanconf.Section{}},
+		pools:     &swanconf.Section{Name: "pools"},
+		poolNames: map[string]string{},
+		copies:    map[string]bool{},
+	}
+	cv.setup(conf.Setup)
+
+	templates := map[string]bool{}
+	for _, s := range conf.Conns {
+		for _, p := range s.Params {
+			if p.Key == "also" {
+				for _, name := range strings.Fields(p.Value) {
+					templates[name] = true
+				}
+			}
+		}
+	}
+
+	conns := &swanconf.Section{Name: "connections"}
+	for _, s := range conf.Conns {
+		if s.Name == "%default" {
+			continue
+		}
+		params, err := conf.Resolve(s)
+		if err != nil {
+			return nil, err
+		}
+		if auto := params["auto"]; auto == "" || auto == "ignore" {
+			if !templates[s.Name] {
+				cv.warn("conn "+s.Name, "", "auto=ignore, not converted")
+			}
+			continue
+		}
+		conns.Sections = append(conns.Sections, cv.conn(s.Name, params))
+	}
+
+	authorities := &swanconf.Section{Name: "authorities"}
+	for _, s := range conf.CAs {
+		if s.Name == "%default" {
+			continue
+		}
+		params, err := conf.Resolve(s)
+		if err != nil {
+			return nil, err
+		}
+		if auto := params["auto"]; auto == "" || auto == "ignore" {
+			cv.warn("ca "+s.Name, "", "auto=ignore, not converted")
+			continue
+		}
+		authorities.Sections = append(authorities.Sections, cv.ca(s.Name, params))
+	}
+
+	root := cv.res.Config
+	for _, sec := range []*swanconf.Section{conns, cv.pools, authorities, cv.secrets(secrets)} {
+		if len(sec.Sections) > 0 {
+			root.Sections = append(root.Sections, sec)
+		}
+	}
+	return cv.res, nil
+}
+
+func (cv *converter) warn(section, option, format string, args ...any) {
+	cv.res.Warnings = append(cv.res.Warnings, Warning{Section: section, Option: option, Message: fmt.Sprintf(format, args...)})
+}
+
+// copyFile reports once that a file stroke loaded has to be copied into
+// the swanctl directory dir.
+func (cv *converter) copyFile(section, option, path, dir string) {
+	if cv.copies[path] {
+		return
+	}
+	cv.copies[path] = true
+	cv.warn(section, option, "copy %s to the swanctl %s directory", path, dir)
+}
+
+func (cv *converter) setup(setup map[string]string) {
+	// stroke replaces duplicate IKE_SAs by default, swanctl keeps them.
+	cv.unique = "replace"
+	keys := make([]string, 0, len(setup))
+	for k := range setup {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := setup[k]
+		switch k {
+		case "uniqueids":
+			switch v {
+			case "yes", "replace":
+				cv.unique = "replace"
+			case "no", "never", "keep":
+				cv.unique = v
+			default:
+				cv.warn("config setup", k, "unknown value %q", v)
+			}
+		case "charondebug":
+			cv.warn("config setup", k, "not translated, configure logging in strongswan.conf")
+		case "strictcrlpolicy":
+			cv.warn("config setup", k, "not translated, set revocation in the remote sections")
+		default:
+			cv.warn("config setup", k, "not translated")
+		}
+	}
+}
+
+// conn holds a conn's effective parameters and tracks which were used.
+type conn struct {
+	cv      *converter
+	params  map[string]string
+	used    map[string]bool
+	name    string
+	section string
+}
+
+func (cv *converter) newConn(typ, name string, params map[string]string) *conn {
+	return &conn{
+		cv:      cv,
+		params:  params,
+		used:    map[string]bool{"auto": true, "also": true},
+		name:    name,
+		section: typ + " " + name,
+	}
+}
+
+func (c *conn) take(key string) (string, bool) {
+	v, ok := c.params[key]
+	if ok {
+		c.used[key] = true
+	}
+	return v, ok
+}
+
+// takeAny takes the first of key and its aliases that is set.
+func (c *conn) takeAny(keys ...string) (string, bool) {
+	for _, k := range keys {
+		if v, ok := c.take(k); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (c *conn) warn(option, format string, args ...any) {
+	c.cv.warn(c.section, option, format, args...)
+}
+
+// copyOption copies key to the swanctl setting of the same meaning.
+func (c *conn) copyOption(sec *swanconf.Section, key, swanKey string) {
+	if v, ok := c.take(key); ok {
+		sec.Set(swanKey, v)
+	}
+}
+
+// mapOption copies key through values, which maps stroke values to
+// swanctl ones; an empty mapping drops the option.
+func (c *conn) mapOption(sec *swanconf.Section, key, swanKey string, values map[string]string) {
+	v, ok := c.take(key)
+	if !ok {
+		return
+	}
+	mapped, known := values[v]
+	if !known {
+		c.warn(key, "unknown value %q", v)
+		return
+	}
+	if mapped != "" {
+		sec.Set(swanKey, mapped)
+	}
+}
+
+func (cv *converter) conn(name string, params map[string]string) *swanconf.Section {
+	c := cv.newConn("conn", name, params)
+	sec := &swanconf.Section{Name: name}
+	child := &swanconf.Section{Name: name}
+
+	c.mapOption(sec, "keyexchange", "version", map[string]string{"ike": "", "ikev1": "1", "ikev2": "2"})
+	if v, ok := c.take("left"); ok {
+		if addrs := addresses(v); addrs != "" {
+			sec.Set("local_addrs", addrs)
+		}
+	}
+	if v, ok := c.take("right"); ok {
+		if addrs := addresses(v); addrs != "" {
+			sec.Set("remote_addrs", addrs)
+		}
+	}
+	c.copyOption(sec, "leftikeport", "local_port")
+	c.copyOption(sec, "rightikeport", "remote_port")
+	if v, ok := c.take("ike"); ok {
+		sec.Set("proposals", proposals(v))
+	}
+	c.mapOption(sec, "aggressive", "aggressive", yesNo)
+	c.mapOption(sec, "mobike", "mobike", yesNo)
+	c.mapOption(sec, "fragmentation", "fragmentation", map[string]string{"yes": "yes", "no": "no", "accept": "accept", "force": "force"})
+	c.mapOption(sec, "forceencaps", "encap", yesNo)
+	c.mapOption(sec, "modeconfig", "pull", map[string]string{"pull": "", "push": "no"})
+	if v, ok := c.take("keyingtries"); ok {
+		if v == "%forever" {
+			v = "0"
+		}
+		sec.Set("keyingtries", v)
+	}
+	if cv.unique != "no" {
+		sec.Set("unique", cv.unique)
+	}
+	local, _ := c.take("leftsubnet")
+	localPorts, _ := c.take("leftprotoport")
+	if ts := selectors(local, localPorts); ts != "" {
+		child.Set("local_ts", ts)
+	}
+	remote, _ := c.take("rightsubnet")
+	remotePorts, _ := c.take("rightprotoport")
+	if ts := selectors(remote, remotePorts); ts != "" {
+		child.Set("remote_ts", ts)
+	}
+	if v, ok := c.take("esp"); ok {
+		child.Set("esp_proposals", proposals(v))
+	}
+	if v, ok := c.take("ah"); ok {
+		child.Set("ah_proposals", proposals(v))
+	}
+	c.vips(sec)
+	c.pools(sec)
+	c.lifetimes(sec, child)
+	c.dpd(sec, child)
+	c.auth(sec)
+
+	c.copyOption(child, "leftupdown", "updown")
+	if v, ok := c.take("leftfirewall"); ok && v == "yes" {
+		c.warn("leftfirewall", "not translated, TailSwan sets up forwarding rules itself")
+	}
+	c.mapOption(child, "lefthostaccess", "hostaccess", yesNo)
+	c.mapOption(child, "type", "mode", map[string]string{
+		"tunnel": "", "transport": "transport", "transport_proxy": "transport_proxy",
+		"beet": "beet", "passthrough": "pass", "pass": "pass", "drop": "drop",
+	})
+	c.mapOption(child, "auto", "start_action", map[string]string{"add": "", "route": "trap", "start": "start"})
+	c.mapOption(child, "closeaction", "close_action", map[string]string{
+		"none": "", "clear": "clear", "hold": "trap", "restart": "start",
+	})
+	c.mapOption(child, "compress", "ipcomp", yesNo)
+	c.mapOption(child, "installpolicy", "policies", yesNo)
+	if v, ok := c.take("mark"); ok {
+		child.Set("mark_in", v)
+		child.Set("mark_out", v)
+	}
+	for _, key := range []string{"mark_in", "mark_out", "if_id_in", "if_id_out", "reqid", "replay_window", "inactivity", "sha256_96", "hw_offload"} {
+		c.copyOption(child, key, key)
+	}
+	c.copyOption(child, "tfc", "tfc_padding")
+	if _, ok := c.takeAny("pfs", "pfsgroup"); ok {
+		c.warn("pfs", "ignored, add a DH group to esp to use PFS")
+	}
+
+	children := sec.Ensure("children")
+	children.Sections = append(children.Sections, child)
+	c.unused()
+	return sec
+}
+
+var yesNo = map[string]string{"yes": "yes", "no": "no"}
+
+func (c *conn) unused() {
+	keys := make([]string, 0, len(c.params))
+	for k := range c.params {
+		if !c.used[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		c.warn(k, "not translated")
+	}
+}
+
+// addresses translates left= or right=. Wildcards are swanctl's default,
+// and a leading % only allowed any address under stroke.
+func addresses(v string) string {
+	var list []string
+	for _, a := range strings.Split(v, ",") {
+		a = strings.TrimSpace(a)
+		switch a {
+		case "", "%any", "%any4", "%any6", "%defaultroute":
+			continue
+		}
+		list = append(list, strings.TrimPrefix(a, "%"))
+	}
+	return strings.Join(list, ",")
+}
+
+// proposals translates ike= or esp=. Without a trailing ! stroke also
+// accepted its defaults.
+func proposals(v string) string {
+	if strict, ok := strings.CutSuffix(v, "!"); ok {
+		return strict
+	}
+	return v + ",default"
+}
+
+func selectors(subnet, protoport string) string {
+	if subnet == "" && protoport == "" {
+		return ""
+	}
+	if subnet == "" || subnet == "%dynamic" {
+		subnet = "dynamic"
+	}
+	if protoport == "" {
+		return subnet
+	}
+	list := strings.Split(subnet, ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i]) + "[" + protoport + "]"
+	}
+	return strings.Join(list, ",")
+}
+
+// vips translates leftsourceip=%config, requesting a virtual IP.
+func (c *conn) vips(sec *swanconf.Section) {
+	v, ok := c.take("leftsourceip")
+	if !ok {
+		return
+	}
+	var vips []string
+	for _, ip := range strings.Split(v, ",") {
+		switch strings.TrimSpace(ip) {
+		case "%config", "%config4", "%modeconfig", "%modecfg", "%cfg":
+			vips = append(vips, "0.0.0.0")
+		case "%config6":
+			vips = append(vips, "::")
+		default:
+			c.warn("leftsourceip", "%s not translated, only %%config is", ip)
+		}
+	}
+	if len(vips) > 0 {
+		sec.Set("vips", strings.Join(vips, ","))
+	}
+	if _, ok := c.take("leftdns"); ok {
+		c.warn("leftdns", "not translated, charon installs the DNS servers it is assigned")
+	}
+}
+
+// pools translates rightsourceip into pools, one per range.
+func (c *conn) pools(sec *swanconf.Section) {
+	v, ok := c.take("rightsourceip")
+	if !ok {
+		return
+	}
+	dns, _ := c.take("rightdns")
+	var names []string
+	for _, addrs := range strings.Split(v, ",") {
+		addrs = strings.TrimSpace(addrs)
+		switch {
+		case addrs == "%config" || addrs == "%config4" || addrs == "%config6":
+			c.warn("rightsourceip", "%s not translated", addrs)
+			continue
+		case strings.HasPrefix(addrs, "%"):
+			name := strings.TrimPrefix(addrs, "%")
+			c.warn("rightsourceip", "uses the external pool %s, define it in the pools section", name)
+			names = append(names, name)
+			continue
+		}
+		key := addrs + "|" + dns
+		name, ok := c.cv.poolNames[key]
+		if !ok {
+			name = c.uniquePool()
+			pool := c.cv.pools.Ensure(name)
+			pool.Set("addrs", addrs)
+			if dns != "" {
+				pool.Set("dns", dns)
+			}
+			c.cv.poolNames[key] = name
+		}
+		names = append(names, name)
+	}
+	if len(names) > 0 {
+		sec.Set("pools", strings.Join(names, ","))
+	}
+}
+
+func (c *conn) uniquePool() string {
+	for n := 1; ; n++ {
+		name := c.name
+		if n > 1 {
+			name += "-" + strconv.Itoa(n)
+		}
+		if c.cv.pools.Section(name) == nil {
+			return name
+		}
+	}
+}
+
+// lifetimes translates stroke's lifetime and margin into swanctl's rekey
+// and hard lifetimes. They are only set when the conn sets one of them,
+// otherwise swanctl's defaults apply.
+func (c *conn) lifetimes(sec, child *swanconf.Section) {
+	ikeLife, ikeSet := c.take("ikelifetime")
+	life, lifeSet := c.takeAny("lifetime", "keylife")
+	margin, marginSet := c.takeAny("margintime", "rekeymargin")
+	fuzz, fuzzSet := c.take("rekeyfuzz")
+	rekey, _ := c.take("rekey")
+	reauth, _ := c.take("reauth")
+
+	marginSecs := c.seconds("margintime", margin, marginSet, defaultMargin)
+	fuzzPercent := 100
+	if fuzzSet {
+		n, err := strconv.Atoi(strings.TrimSuffix(fuzz, "%"))
+		if err != nil {
+			c.warn("rekeyfuzz", "invalid value %q", fuzz)
+		} else {
+			fuzzPercent = n
+		}
+	}
+	randTime := formatSeconds(marginSecs * fuzzPercent / 100)
+	common := marginSet || fuzzSet || rekey == "no"
+
+	if ikeSet || common || reauth == "yes" {
+		lifeSecs := c.seconds("ikelifetime", ikeLife, ikeSet, defaultIKELifetime)
+		rekeyTime := formatSeconds(max(lifeSecs-marginSecs, 0))
+		switch {
+		case rekey == "no":
+			sec.Set("rekey_time", "0")
+		case reauth == "yes":
+			sec.Set("reauth_time", rekeyTime)
+		default:
+			sec.Set("rekey_time", rekeyTime)
+		}
+		sec.Set("over_time", formatSeconds(marginSecs))
+		sec.Set("rand_time", randTime)
+	}
+	if lifeSet || common {
+		lifeSecs := c.seconds("lifetime", life, lifeSet, defaultChildLifetime)
+		if rekey == "no" {
+			child.Set("rekey_time", "0")
+		} else {
+			child.Set("rekey_time", formatSeconds(max(lifeSecs-marginSecs, 0)))
+		}
+		child.Set("life_time", formatSeconds(lifeSecs))
+		child.Set("rand_time", randTime)
+	}
+}
+
+func (c *conn) seconds(key, v string, set bool, def int) int {
+	if !set {
+		return def
+	}
+	n, err := parseSeconds(v)
+	if err != nil {
+		c.warn(key, "%v, using %s", err, formatSeconds(def))
+		return def
+	}
+	return n
+}
+
+var timeUnits = []struct {
+	suffix string
+	secs   int
+}{{"d", 86400}, {"h", 3600}, {"m", 60}, {"s", 1}}
+
+func parseSeconds(v string) (int, error) {
+	mult := 1
+	num := v
+	for _, u := range timeUnits {
+		if n, ok := strings.CutSuffix(v, u.suffix); ok {
+			num, mult = n, u.secs
+			break
+		}
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid time %q", v)
+	}
+	return n * mult, nil
+}
+
+// formatSeconds uses the largest unit that divides the time evenly.
+func formatSeconds(secs int) string {
+	for _, u := range timeUnits {
+		if secs >= u.secs && secs%u.secs == 0 {
+			return strconv.Itoa(secs/u.secs) + u.suffix
+		}
+	}
+	return "0s"
+}
+
+// dpd translates dead peer detection. Under stroke dpddelay only applies
+// with a dpdaction.
+func (c *conn) dpd(sec, child *swanconf.Section) {
+	delay, delaySet := c.take("dpddelay")
+	timeout, timeoutSet := c.take("dpdtimeout")
+	action, ok := c.take("dpdaction")
+	if !ok || action == "none" {
+		return
+	}
+	mapped, known := map[string]string{"clear": "clear", "hold": "trap", "restart": "restart"}[action]
+	if !known {
+		c.warn("dpdaction", "unknown value %q", action)
+		return
+	}
+	if !delaySet {
+		delay = "30s"
+	}
+	sec.Set("dpd_delay", delay)
+	if timeoutSet {
+		sec.Set("dpd_timeout", timeout)
+	}
+	child.Set("dpd_action", mapped)
+}
+
+// auth translates authby and the per-side authentication options into
+// local and remote sections, with -2 sections for second rounds.
+func (c *conn) auth(sec *swanconf.Section) {
+	localAuth, _ := c.take("leftauth")
+	remoteAuth, _ := c.take("rightauth")
+	localAuth2, _ := c.take("leftauth2")
+	remoteAuth2, _ := c.take("rightauth2")
+	xauthSide, _ := c.take("xauth")
+
+	if authby, ok := c.take("authby"); ok {
+		base, xauth := "", false
+		switch authby {
+		case "secret", "psk":
+			base = "psk"
+		case "rsasig", "rsa", "ecdsasig", "ecdsa", "pubkey":
+			base = "pubkey"
+		case "xauthpsk":
+			base, xauth = "psk", true
+		case "xauthrsasig":
+			base, xauth = "pubkey", true
+		default:
+			c.warn("authby", "%q not translated", authby)
+		}
+		if localAuth == "" {
+			localAuth = base
+		}
+		if remoteAuth == "" {
+			remoteAuth = base
+		}
+		if xauth && xauthSide == "client" && localAuth2 == "" {
+			localAuth2 = "xauth"
+		} else if xauth && remoteAuth2 == "" {
+			remoteAuth2 = "xauth"
+		}
+	}
+	if localAuth == "" {
+		localAuth = "pubkey"
+	}
+	if remoteAuth == "" {
+		remoteAuth = "pubkey"
+	}
+
+	local := c.round("left", "", localAuth)
+	remote := c.round("right", "", remoteAuth)
+	if v, ok := c.take("leftsendcert"); ok {
+		mapped, known := map[string]string{"always": "always", "yes": "always", "ifasked": "ifasked", "never": "never", "no": "never"}[v]
+		if known {
+			local.Set("send_cert", mapped)
+		} else {
+			c.warn("leftsendcert", "unknown value %q", v)
+		}
+	}
+	if v, ok := c.take("eap_identity"); ok {
+		if strings.HasPrefix(localAuth, "eap") {
+			local.Set("eap_id", v)
+		} else {
+			if v == "%identity" {
+				v = "%any"
+			}
+			remote.Set("eap_id", v)
+		}
+	}
+	c.copyOption(remote, "aaa_identity", "aaa_id")
+
+	local2 := c.round("left", "2", localAuth2)
+	remote2 := c.round("right", "2", remoteAuth2)
+	if v, ok := c.take("xauth_identity"); ok {
+		switch {
+		case localAuth2 != "":
+			local2.Set("xauth_id", v)
+		case remoteAuth2 != "":
+			remote2.Set("xauth_id", v)
+		default:
+			c.warn("xauth_identity", "not translated without an XAuth round")
+		}
+	}
+
+	for _, s := range []*swanconf.Section{local, local2, remote, remote2} {
+		if len(s.Settings) > 0 {
+			sec.Sections = append(sec.Sections, s)
+		}
+	}
+}
+
+func (c *conn) round(side, round, auth string) *swanconf.Section {
+	local := side == "left"
+	name := "remote"
+	if local {
+		name = "local"
+	}
+	if round != "" {
+		name += "-" + round
+	}
+	s := &swanconf.Section{Name: name}
+	if auth != "" {
+		s.Set("auth", auth)
+	}
+	c.copyOption(s, side+"id"+round, "id")
+	if v, ok := c.take(side + "cert" + round); ok {
+		s.Set("certs", filepath.Base(v))
+		c.cv.copyFile(c.section, side+"cert"+round, certPath(v), "x509")
+	}
+	if local {
+		if _, ok := c.take("leftca" + round); ok && c.params["rightca"+round] != "%same" {
+			c.warn("leftca"+round, "not translated, the local certificate is chosen by leftcert")
+		}
+		if _, ok := c.take("leftgroups" + round); ok {
+			c.warn("leftgroups"+round, "not translated")
+		}
+		return s
+	}
+	if v, ok := c.take("rightca" + round); ok {
+		if v == "%same" {
+			v = c.params["leftca"+round]
+		}
+		if v == "" {
+			c.warn("rightca"+round, "%%same without leftca")
+		} else {
+			s.Set("ca_id", v)
+		}
+	}
+	c.copyOption(s, "rightgroups"+round, "groups")
+	return s
+}
+
+// certPath returns where stroke looked for a certificate.
+func certPath(v string) string {
+	if filepath.IsAbs(v) {
+		return v
+	}
+	return filepath.Join("/etc/ipsec.d/certs", v)
+}
+
+func (cv *converter) ca(name string, params map[string]string) *swanconf.Section {
+	c := cv.newConn("ca", name, params)
+	sec := &swanconf.Section{Name: name}
+	if v, ok := c.take("cacert"); ok {
+		sec.Set("cacert", filepath.Base(v))
+		path := v
+		if !filepath.IsAbs(v) {
+			path = filepath.Join("/etc/ipsec.d/cacerts", v)
+		}
+		cv.copyFile(c.section, "cacert", path, "x509ca")
+	}
+	var crls, ocsp []string
+	for _, key := range []string{"crluri", "crluri1", "crluri2"} {
+		if v, ok := c.take(key); ok {
+			crls = append(crls, v)
+		}
+	}
+	for _, key := range []string{"ocspuri", "ocspuri1", "ocspuri2"} {
+		if v, ok := c.take(key); ok {
+			ocsp = append(ocsp, v)
+		}
+	}
+	if len(crls) > 0 {
+		sec.Set("crl_uris", strings.Join(crls, ","))
+	}
+	if len(ocsp) > 0 {
+		sec.Set("ocsp_uris", strings.Join(ocsp, ","))
+	}
+	c.copyOption(sec, "certuribase", "cert_uri_base")
+	c.unused()
+	return sec
+}
+
+var secretTypes = map[string]string{
+	"PSK": "ike", "EAP": "eap", "XAUTH": "xauth", "NTLM": "ntlm",
+	"RSA": "rsa", "ECDSA": "ecdsa", "P12": "pkcs12",
+}
+
+func (cv *converter) secrets(secrets []Secret) *swanconf.Section {
+	root := &swanconf.Section{Name: "secrets"}
+	counts := map[string]int{}
+	for i := range secrets {
+		s := &secrets[i]
+		typ, ok := secretTypes[s.Type]
+		if !ok {
+			cv.warn("ipsec.secrets", s.Type, "not translated")
+			continue
+		}
+
+		sec := &swanconf.Section{}
+		switch typ {
+		case "rsa", "ecdsa", "pkcs12":
+			dir := map[string]string{"rsa": "rsa", "ecdsa": "ecdsa", "pkcs12": "pkcs12"}[typ]
+			path := s.Value
+			if !filepath.IsAbs(path) {
+				path = filepath.Join("/etc/ipsec.d/private", path)
+			}
+			cv.copyFile("ipsec.secrets", s.Type, path, dir)
+			switch s.Passphrase {
+			case "":
+				// Unencrypted keys are loaded from the directory.
+				continue
+			case "%prompt":
+				cv.warn("ipsec.secrets", s.Type, "%%prompt not translated for %s", filepath.Base(path))
+				continue
+			}
+			sec.Set("file", filepath.Base(path))
+			sec.Set("secret", s.Passphrase)
+		default:
+			if len(s.IDs) == 1 {
+				sec.Set("id", s.IDs[0])
+			} else {
+				for j, id := range s.IDs {
+					sec.Set("id-"+strconv.Itoa(j+1), id)
+				}
+			}
+			sec.Set("secret", s.Value)
+		}
+		counts[typ]++
+		sec.Name = typ + "-" + strconv.Itoa(counts[typ])
+		root.Sections = append(root.Sections, sec)
+	}
+	return root
+}
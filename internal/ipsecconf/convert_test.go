@@ -0,0 +1,55 @@
+package ipsecconf
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestConvert_Golden(t *testing.T) {
+	dirs, err := filepath.Glob("testdata/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			conf, err := ParseFile(filepath.Join(dir, "ipsec.conf"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			secrets, err := ParseSecretsFile(filepath.Join(dir, "ipsec.secrets"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := Convert(conf, secrets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := res.String()
+
+			golden := filepath.Join(dir, "swanctl.conf")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s, run with -update to see the change:\n%s", golden, got)
+			}
+
+			// The output must be valid swanctl.conf.
+			if _, err := swanconf.ParseFile(golden); err != nil {
+				t.Errorf("the golden file does not parse: %v", err)
+			}
+		})
+	}
+}
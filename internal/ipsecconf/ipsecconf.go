@@ -0,0 +1,253 @@
+// Package ipsecconf reads the legacy stroke configuration, ipsec.conf and
+// ipsec.secrets, and converts it to swanctl.conf.
+package ipsecconf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const maxIncludeDepth = 10
+
+// Section is a config, conn or ca section. Params keep their order and
+// repetitions, since also= may be given more than once.
+type Section struct {
+	Type   string
+	Name   string
+	Params []Param
+}
+
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Config is a parsed ipsec.conf.
+type Config struct {
+	Setup map[string]string
+	Conns []*Section
+	CAs   []*Section
+}
+
+// ParseFile parses an ipsec.conf, resolving include statements relative
+// to the including file.
+func ParseFile(path string) (*Config, error) {
+	c := &Config{Setup: map[string]string{}}
+	if err := c.parseFile(path, 0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Parse parses an ipsec.conf without includes.
+func Parse(r io.Reader) (*Config, error) {
+	c := &Config{Setup: map[string]string{}}
+	if err := c.parse(r, "<input>", nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) parseFile(path string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: includes nested too deep", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	return c.parse(f, path, func(pattern string) error {
+		return include(path, pattern, func(m string) error {
+			return c.parseFile(m, depth+1)
+		})
+	})
+}
+
+// include calls parse for the files matching pattern, which is relative
+// to the including file, in lexical order.
+func include(from, pattern string, parse func(path string) error) error {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(from), pattern)
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	sort.Strings(matches)
+	for _, m := range matches {
+		if err := parse(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) parse(r io.Reader, name string, includeFn func(pattern string) error) error {
+	var cur *Section
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		raw := scanner.Text()
+		line, err := stripComment(raw)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", name, n, err)
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if raw[0] != ' ' && raw[0] != '\t' {
+			fields := strings.Fields(line)
+			switch {
+			case fields[0] == "version":
+				cur = nil
+			case fields[0] == "include" && len(fields) == 2:
+				if includeFn == nil {
+					return fmt.Errorf("%s:%d: include is not supported here", name, n)
+				}
+				if err := includeFn(fields[1]); err != nil {
+					return err
+				}
+				cur = nil
+			case len(fields) == 2 && (fields[0] == "conn" || fields[0] == "ca" || fields[0] == "config"):
+				cur = c.section(fields[0], fields[1])
+			default:
+				return fmt.Errorf("%s:%d: unexpected %q", name, n, strings.TrimSpace(line))
+			}
+			continue
+		}
+
+		if cur == nil {
+			return fmt.Errorf("%s:%d: parameter outside of a section", name, n)
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected key=value", name, n)
+		}
+		key = strings.TrimSpace(key)
+		value = unquote(strings.TrimSpace(value))
+		if cur.Type == "config" {
+			c.Setup[key] = value
+			continue
+		}
+		cur.Params = append(cur.Params, Param{Key: key, Value: value})
+	}
+	return scanner.Err()
+}
+
+// section returns the section to add parameters to. A section defined
+// again continues the earlier definition.
+func (c *Config) section(typ, name string) *Section {
+	list := &c.Conns
+	switch typ {
+	case "ca":
+		list = &c.CAs
+	case "config":
+		return &Section{Type: typ, Name: name}
+	}
+	for _, s := range *list {
+		if s.Name == name {
+			return s
+		}
+	}
+	s := &Section{Type: typ, Name: name}
+	*list = append(*list, s)
+	return s
+}
+
+// stripComment removes a # comment outside of quotes.
+func stripComment(line string) (string, error) {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i], nil
+		}
+	}
+	if quote != 0 {
+		return "", fmt.Errorf("unterminated quote")
+	}
+	return line, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// Resolve returns the effective parameters of a conn or ca section:
+// %default first, then the sections named by also= in order, then its
+// own. An empty value resets a parameter to its default.
+func (c *Config) Resolve(s *Section) (map[string]string, error) {
+	list := c.Conns
+	if s.Type == "ca" {
+		list = c.CAs
+	}
+	find := func(name string) *Section {
+		for _, other := range list {
+			if other.Name == name {
+				return other
+			}
+		}
+		return nil
+	}
+
+	params := map[string]string{}
+	if def := find("%default"); def != nil && s.Name != "%default" {
+		if err := apply(params, def, find, map[string]bool{s.Name: true}); err != nil {
+			return nil, err
+		}
+	}
+	if err := apply(params, s, find, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	for k, v := range params {
+		if v == "" {
+			delete(params, k)
+		}
+	}
+	return params, nil
+}
+
+func apply(params map[string]string, s *Section, find func(string) *Section, seen map[string]bool) error {
+	if seen[s.Name] {
+		return fmt.Errorf("%s %s: also= loop", s.Type, s.Name)
+	}
+	seen[s.Name] = true
+	defer delete(seen, s.Name)
+
+	for _, p := range s.Params {
+		if p.Key != "also" {
+			continue
+		}
+		for _, name := range strings.Fields(p.Value) {
+			other := find(name)
+			if other == nil {
+				return fmt.Errorf("%s %s: also=%s is not defined", s.Type, s.Name, name)
+			}
+			if err := apply(params, other, find, seen); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range s.Params {
+		if p.Key != "also" {
+			params[p.Key] = p.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package ipsecconf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	conf, err := Parse(strings.NewReader(`
+conn a
+    esp=aes128-sha256
+    leftid=@a   # trailing comment
+
+conn %default
+    keyexchange=ikev2
+    esp=aes256-sha256
+
+conn b
+    also=a
+    rightid="C=SE, CN=b # not a comment"
+    keyexchange=
+
+conn c
+    also=b
+    leftid=@c
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := conf.Resolve(conf.Conns[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"esp":     "aes128-sha256",
+		"leftid":  "@c",
+		"rightid": "C=SE, CN=b # not a comment",
+	}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("expected %v, got %v", want, params)
+	}
+}
+
+func TestResolve_Errors(t *testing.T) {
+	for _, conf := range []string{
+		"conn a\n    also=b\n\nconn b\n    also=a\n",
+		"conn a\n    also=missing\n",
+	} {
+		c, err := Parse(strings.NewReader(conf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Resolve(c.Conns[0]); err == nil {
+			t.Errorf("expected an error for %q", conf)
+		}
+	}
+
+	for _, conf := range []string{
+		"    left=%any\n",
+		"conn a\n    left\n",
+		"setup x\n",
+		"conn a\n    leftid=\"unterminated\n",
+	} {
+		if _, err := Parse(strings.NewReader(conf)); err == nil {
+			t.Errorf("expected a parse error for %q", conf)
+		}
+	}
+}
+
+func TestParseSecrets(t *testing.T) {
+	secrets, err := ParseSecrets(strings.NewReader(`
+# comment
+2001:db8::1 @gw : PSK "s3cret"
+: RSA gw.key "pass phrase"
+"C=SE, CN=alice"
+    : EAP 'pa"ss'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Secret{
+		{Type: "PSK", Value: "s3cret", IDs: []string{"2001:db8::1", "@gw"}},
+		{Type: "RSA", Value: "gw.key", Passphrase: "pass phrase"},
+		{Type: "EAP", Value: `pa"ss`, IDs: []string{"C=SE, CN=alice"}},
+	}
+	if !reflect.DeepEqual(secrets, want) {
+		t.Errorf("expected %+v, got %+v", want, secrets)
+	}
+
+	if _, err := ParseSecrets(strings.NewReader("alice EAP secret\n")); err == nil {
+		t.Error("expected an entry without a colon to be rejected")
+	}
+}
@@ -0,0 +1,165 @@
+package ipsecconf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Secret is an ipsec.secrets entry. For RSA, ECDSA and P12 entries Value
+// is the key file and Passphrase the optional passphrase.
+type Secret struct {
+	Type       string
+	Value      string
+	Passphrase string
+	IDs        []string
+}
+
+// ParseSecretsFile parses an ipsec.secrets, resolving include statements
+// relative to the including file.
+func ParseSecretsFile(path string) ([]Secret, error) {
+	return parseSecretsFile(path, 0)
+}
+
+func parseSecretsFile(path string, depth int) ([]Secret, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("%s: includes nested too deep", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	var secrets []Secret
+	err = parseSecrets(f, path, &secrets, func(pattern string) error {
+		return include(path, pattern, func(m string) error {
+			included, err := parseSecretsFile(m, depth+1)
+			secrets = append(secrets, included...)
+			return err
+		})
+	})
+	return secrets, err
+}
+
+// ParseSecrets parses an ipsec.secrets without includes.
+func ParseSecrets(r io.Reader) ([]Secret, error) {
+	var secrets []Secret
+	err := parseSecrets(r, "<input>", &secrets, nil)
+	return secrets, err
+}
+
+func parseSecrets(r io.Reader, name string, secrets *[]Secret, includeFn func(pattern string) error) error {
+	// Entries may continue on indented lines, so lines are joined before
+	// they are parsed.
+	var entries []string
+	var lines []int
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		raw := scanner.Text()
+		line, err := stripComment(raw)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", name, n, err)
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if (raw[0] == ' ' || raw[0] == '\t') && len(entries) > 0 {
+			entries[len(entries)-1] += " " + strings.TrimSpace(line)
+			continue
+		}
+		entries = append(entries, strings.TrimSpace(line))
+		lines = append(lines, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		if pattern, ok := strings.CutPrefix(entry, "include "); ok {
+			if includeFn == nil {
+				return fmt.Errorf("%s:%d: include is not supported here", name, lines[i])
+			}
+			if err := includeFn(strings.TrimSpace(pattern)); err != nil {
+				return err
+			}
+			continue
+		}
+		s, err := parseSecret(entry)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", name, lines[i], err)
+		}
+		*secrets = append(*secrets, s)
+	}
+	return nil
+}
+
+func parseSecret(entry string) (Secret, error) {
+	var s Secret
+	selectors, rest, ok := cutSelectors(entry)
+	if !ok {
+		return s, fmt.Errorf("expected [ids] : TYPE secret")
+	}
+	ids, err := fields(selectors)
+	if err != nil {
+		return s, err
+	}
+	s.IDs = ids
+	values, err := fields(rest)
+	if err != nil {
+		return s, err
+	}
+	if len(values) < 2 {
+		return s, fmt.Errorf("expected a type and a secret")
+	}
+	s.Type = strings.ToUpper(values[0])
+	s.Value = values[1]
+	if len(values) > 2 {
+		s.Passphrase = values[2]
+	}
+	return s, nil
+}
+
+// cutSelectors splits an entry at the first colon outside of quotes that
+// is followed by whitespace; IPv6 addresses contain colons too.
+func cutSelectors(entry string) (string, string, bool) {
+	var quote byte
+	for i := 0; i < len(entry); i++ {
+		switch c := entry[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ':' && (i+1 == len(entry) || entry[i+1] == ' ' || entry[i+1] == '\t'):
+			return entry[:i], entry[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// fields splits s at whitespace, keeping quoted strings together.
+func fields(s string) ([]string, error) {
+	var list []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if q := s[0]; q == '"' || q == '\'' {
+			end := strings.IndexByte(s[1:], q)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			list = append(list, s[1:end+1])
+			s = s[end+2:]
+			continue
+		}
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		list = append(list, s[:end])
+		s = s[end:]
+	}
+	return list, nil
+}
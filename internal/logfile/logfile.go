@@ -0,0 +1,120 @@
+package logfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const DefaultMaxSize = 5 << 20
+
+// Writer appends to a log file and rotates it to path.1 once it grows past
+// maxSize, so at most two files are kept.
+type Writer struct {
+	file    *os.File
+	path    string
+	size    int64
+	maxSize int64
+	mu      sync.Mutex
+}
+
+func Open(path string, maxSize int64) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close() //nolint:errcheck
+		return nil, fmt.Errorf("stat log file: %w", err)
+	}
+
+	return &Writer{file: file, path: path, size: info.Size(), maxSize: maxSize}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.size+int64(len(p)) > w.maxSize && w.size > 0 {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("close log file: %w", err)
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	w.file = file
+	w.size = 0
+	return nil
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// Tail returns up to n bytes from the end of the log, continuing into the
+// rotated file when the current one is shorter.
+func Tail(path string, n int64) ([]byte, error) {
+	current, err := tailFile(path, n)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(current)) >= n {
+		return current, nil
+	}
+
+	previous, err := tailFile(path+".1", n-int64(len(current)))
+	if err != nil {
+		return nil, err
+	}
+	return append(previous, current...), nil
+}
+
+func tailFile(path string, n int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := info.Size() - n
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(file)
+}
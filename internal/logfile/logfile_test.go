@@ -0,0 +1,62 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriterRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tailswan.log")
+
+	w, err := Open(path, 10)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	if string(current) != "third\n" {
+		t.Errorf("expected current log %q, got %q", "third\n", current)
+	}
+	rotated, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	if string(rotated) != "second\n" {
+		t.Errorf("expected rotated log %q, got %q", "second\n", rotated)
+	}
+}
+
+func TestTail(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tailswan.log")
+	if err := os.WriteFile(path+".1", []byte("older\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("newer\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Tail(path, 9)
+	if err != nil {
+		t.Fatalf("Tail() error: %v", err)
+	}
+	if string(got) != "er\nnewer\n" {
+		t.Errorf("unexpected tail %q", got)
+	}
+
+	got, err = Tail(filepath.Join(dir, "missing.log"), 100)
+	if err != nil || len(got) != 0 {
+		t.Errorf("expected empty tail for missing log, got %q, %v", got, err)
+	}
+}
@@ -1,5 +1,24 @@
 package models
 
+import (
+	"time"
+
+	"github.com/klowdo/tailswan/internal/certs"
+	"github.com/klowdo/tailswan/internal/confighistory"
+	"github.com/klowdo/tailswan/internal/fleet"
+	"github.com/klowdo/tailswan/internal/ha"
+	"github.com/klowdo/tailswan/internal/health"
+	"github.com/klowdo/tailswan/internal/identity"
+	"github.com/klowdo/tailswan/internal/pki"
+	"github.com/klowdo/tailswan/internal/roadwarrior"
+	"github.com/klowdo/tailswan/internal/schedule"
+	"github.com/klowdo/tailswan/internal/swandiff"
+	"github.com/klowdo/tailswan/internal/swanload"
+	"github.com/klowdo/tailswan/internal/templates"
+	"github.com/klowdo/tailswan/internal/viciconn"
+	"github.com/klowdo/tailswan/internal/watchdog"
+)
+
 type ConnectionRequest struct {
 	Name string `json:"name"`
 }
@@ -10,6 +29,124 @@ type Response struct {
 	Success bool   `json:"success"`
 }
 
+// HealthResponse carries the HA role when high availability is enabled,
+// and the watchdog's probe results when connections are probed.
+type HealthResponse struct {
+	HA      *ha.State         `json:"ha,omitempty"`
+	Tunnels []watchdog.Health `json:"tunnels,omitempty"`
+	Response
+}
+
+// ComponentHealthResponse is the result of a liveness or readiness check.
+type ComponentHealthResponse struct {
+	Components []health.Component `json:"components"`
+	Response
+}
+
+// CertificatesResponse lists the stored credentials and the certificates
+// that expire within SWAN_CERT_EXPIRY_WARNING.
+type CertificatesResponse struct {
+	Credentials []certs.Credential `json:"credentials"`
+	Expiring    []certs.Credential `json:"expiring"`
+	Success     bool               `json:"success"`
+}
+
+type CredentialResponse struct {
+	Credential *certs.Credential `json:"credential,omitempty"`
+	Response
+}
+
+// PKIResponse describes the built-in CA, which is nil until it is created,
+// and the certificates it issued.
+type PKIResponse struct {
+	CA           *certs.Credential `json:"ca"`
+	Certificates []pki.Cert        `json:"certificates"`
+	Success      bool              `json:"success"`
+}
+
+// IssueRequest asks the built-in CA for a certificate. Install also stores
+// it with its key in the swanctl directory, for this gateway's own
+// certificate.
+type IssueRequest struct {
+	Name    string   `json:"name"`
+	Profile string   `json:"profile"`
+	KeyType string   `json:"key_type,omitempty"`
+	SANs    []string `json:"sans,omitempty"`
+	Days    int      `json:"days,omitempty"`
+	Install bool     `json:"install,omitempty"`
+}
+
+// ExportRequest selects the bundle format, p12 or pem. Legacy selects the
+// PKCS#12 encryption older clients require.
+type ExportRequest struct {
+	Format   string `json:"format"`
+	Password string `json:"password,omitempty"`
+	Legacy   bool   `json:"legacy,omitempty"`
+}
+
+type IssuedCertResponse struct {
+	Certificate *pki.Cert `json:"certificate,omitempty"`
+	Response
+}
+
+// RoadWarriorResponse lists the road-warrior users without their
+// passwords, the configured pools, and the pools charon reports with their
+// leases. Error is set when charon could not be asked.
+type RoadWarriorResponse struct {
+	Error   string             `json:"error,omitempty"`
+	Users   []roadwarrior.User `json:"users"`
+	Pools   []roadwarrior.Pool `json:"pools"`
+	Leases  []viciconn.Pool    `json:"leases"`
+	Success bool               `json:"success"`
+}
+
+// RoadWarriorUserResponse returns an added EAP-MSCHAPv2 user's password
+// once, and an EAP-TLS user's certificate.
+type RoadWarriorUserResponse struct {
+	User        *roadwarrior.User `json:"user,omitempty"`
+	Certificate *pki.Cert         `json:"certificate,omitempty"`
+	Response
+}
+
+// ProfileRequest selects a client profile format. Password encrypts an
+// EAP-TLS user's certificate bundle.
+type ProfileRequest struct {
+	Format   string `json:"format"`
+	Password string `json:"password,omitempty"`
+}
+
+// IdentitiesResponse maps the IPsec clients' identities to their virtual
+// IPs. Enforced is set when RW_ACCESS limits what they can reach.
+type IdentitiesResponse struct {
+	Error      string             `json:"error,omitempty"`
+	Identities []identity.Mapping `json:"identities"`
+	Enforced   bool               `json:"enforced"`
+	Success    bool               `json:"success"`
+}
+
+type FleetResponse struct {
+	Sites   []fleet.Site `json:"sites"`
+	Enabled bool         `json:"enabled"`
+	Success bool         `json:"success"`
+}
+
+type ScheduleResponse struct {
+	Error       string            `json:"error,omitempty"`
+	Connections []schedule.Status `json:"connections"`
+	Success     bool              `json:"success"`
+}
+
+type LeaseRequest struct {
+	Name    string `json:"name"`
+	Minutes int    `json:"minutes"`
+}
+
+type LeaseResponse struct {
+	Lease   *schedule.Lease `json:"lease,omitempty"`
+	Message string          `json:"message"`
+	Success bool            `json:"success"`
+}
+
 type ConnectionsResponse struct {
 	Connections []map[string]interface{} `json:"connections"`
 	Success     bool                     `json:"success"`
@@ -24,3 +161,112 @@ type SSEMessage struct {
 	Event string
 	Data  []byte
 }
+
+type DiagRequest struct {
+	Connection  string `json:"connection,omitempty"`
+	Target      string `json:"target,omitempty"`
+	Peer        string `json:"peer,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Count       int    `json:"count,omitempty"`
+	Port        uint16 `json:"port,omitempty"`
+}
+
+type TemplatesResponse struct {
+	Templates []*templates.Template `json:"templates"`
+	Success   bool                  `json:"success"`
+}
+
+// TemplateRequest holds a template's parameter values. With Load the
+// rendered connections are saved to conf.d and loaded into charon;
+// otherwise they are only rendered and checked. A ConfirmTimeout, in
+// seconds, loads them on trial: they are removed again unless confirmed in
+// time.
+type TemplateRequest struct {
+	Values         templates.Values `json:"values"`
+	ConfirmTimeout int              `json:"confirm_timeout,omitempty"`
+	Load           bool             `json:"load,omitempty"`
+}
+
+// TemplateResponse is a rendered template. Fields maps the parameters with
+// bad values to what is wrong with them.
+type TemplateResponse struct {
+	Fields      map[string]string      `json:"fields,omitempty"`
+	Config      string                 `json:"config,omitempty"`
+	File        string                 `json:"file,omitempty"`
+	Connections []string               `json:"connections,omitempty"`
+	Notes       []string               `json:"notes,omitempty"`
+	Pending     *confighistory.Pending `json:"pending,omitempty"`
+	Response
+	Loaded bool `json:"loaded"`
+}
+
+// ConfigHistoryResponse lists the configuration versions, newest first.
+type ConfigHistoryResponse struct {
+	Versions []confighistory.Snapshot `json:"versions"`
+	Success  bool                     `json:"success"`
+}
+
+// ConfigDiffResponse is a unified diff between two configuration versions,
+// with secrets redacted. From is 0 when diffing against nothing.
+type ConfigDiffResponse struct {
+	Diff string `json:"diff"`
+	Response
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// ConfigRollbackRequest optionally rolls back on trial: the rollback is
+// reverted unless confirmed within ConfirmTimeout seconds.
+type ConfigRollbackRequest struct {
+	ConfirmTimeout int `json:"confirm_timeout,omitempty"`
+}
+
+// ConfigRollbackResponse describes the version a rollback recorded, and
+// the change waiting to be confirmed for a rollback on trial.
+type ConfigRollbackResponse struct {
+	Version *confighistory.Snapshot `json:"version,omitempty"`
+	Pending *confighistory.Pending  `json:"pending,omitempty"`
+	Response
+}
+
+// ConfigPendingResponse is the change waiting to be confirmed, if any.
+type ConfigPendingResponse struct {
+	Pending *confighistory.Pending `json:"pending"`
+	Response
+}
+
+// ConfigDryRunResponse is what a reload would change, also as Text.
+type ConfigDryRunResponse struct {
+	Report *swandiff.Report `json:"report"`
+	Text   string           `json:"text"`
+	Response
+}
+
+// Outcomes of applying an edit picked up by the configuration watcher.
+const (
+	ConfigWatchApplied = "applied"
+	// ConfigWatchFailed is an edit charon rejected part of; the rest was
+	// applied.
+	ConfigWatchFailed = "failed"
+	// ConfigWatchInvalid is an edit that was not applied at all.
+	ConfigWatchInvalid = "invalid"
+)
+
+// ConfigWatchEvent is the outcome of applying an edit of swanctl.conf or
+// its conf.d snippets, with the files that changed and, once applied, the
+// version it was recorded as.
+type ConfigWatchEvent struct {
+	Time    time.Time         `json:"time"`
+	Changes *swanload.Changes `json:"changes,omitempty"`
+	Status  string            `json:"status"`
+	Error   string            `json:"error,omitempty"`
+	Files   []string          `json:"files"`
+	Version int               `json:"version,omitempty"`
+}
+
+type ConfigWatchResponse struct {
+	Last *ConfigWatchEvent `json:"last"`
+	Response
+	Enabled bool `json:"enabled"`
+}
@@ -0,0 +1,25 @@
+package mtu
+
+import (
+	"net/netip"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+// ForChildSA calculates the tunnel MTU of an established CHILD_SA, looking up
+// the link MTU of the route towards its IKE peer.
+func ForChildSA(sa *viciconn.ChildSA) Result {
+	outerIPv6 := false
+	if addr, err := netip.ParseAddr(sa.RemoteHost); err == nil {
+		outerIPv6 = addr.Unmap().Is6()
+	}
+
+	return Calculate(&Params{
+		EncrAlg:   sa.EncrAlg,
+		IntegAlg:  sa.IntegAlg,
+		Mode:      sa.Mode,
+		LinkMTU:   LinkMTU(sa.RemoteHost),
+		UDPEncap:  sa.UDPEncap,
+		OuterIPv6: outerIPv6,
+	})
+}
@@ -0,0 +1,62 @@
+package mtu
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LinkMTU returns the MTU of the route towards remote, preferring a
+// per-route MTU over the egress device MTU.
+func LinkMTU(remote string) int {
+	if remote == "" {
+		return DefaultLinkMTU
+	}
+
+	output, err := exec.Command("ip", "route", "get", remote).Output()
+	if err != nil {
+		return DefaultLinkMTU
+	}
+
+	dev, routeMTU := parseRouteGet(string(output))
+	if routeMTU > 0 {
+		return routeMTU
+	}
+	if dev == "" {
+		return DefaultLinkMTU
+	}
+	return deviceMTU(dev)
+}
+
+func deviceMTU(dev string) int {
+	data, err := os.ReadFile(filepath.Join("/sys/class/net", filepath.Base(dev), "mtu"))
+	if err != nil {
+		return DefaultLinkMTU
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || value <= 0 {
+		return DefaultLinkMTU
+	}
+	return value
+}
+
+func parseRouteGet(output string) (dev string, routeMTU int) {
+	fields := strings.Fields(output)
+	for i := 0; i < len(fields)-1; i++ {
+		switch fields[i] {
+		case "dev":
+			dev = fields[i+1]
+		case "mtu":
+			value := fields[i+1]
+			if value == "lock" && i+2 < len(fields) {
+				value = fields[i+2]
+			}
+			if n, err := strconv.Atoi(value); err == nil {
+				routeMTU = n
+			}
+		}
+	}
+	return dev, routeMTU
+}
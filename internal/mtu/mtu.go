@@ -0,0 +1,133 @@
+package mtu
+
+import (
+	"strings"
+)
+
+const (
+	DefaultLinkMTU = 1500
+	TailnetMTU     = 1280
+
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	tcpHeaderLen  = 20
+	udpHeaderLen  = 8
+	espHeaderLen  = 8
+	espTrailerLen = 2
+)
+
+// Params describes a negotiated CHILD_SA as reported by VICI list-sas.
+type Params struct {
+	EncrAlg    string
+	IntegAlg   string
+	Mode       string
+	LinkMTU    int
+	TailnetMTU int
+	UDPEncap   bool
+	OuterIPv6  bool
+}
+
+type Result struct {
+	LinkMTU   int  `json:"link_mtu"`
+	Overhead  int  `json:"overhead"`
+	ESPMTU    int  `json:"esp_mtu"`
+	TunnelMTU int  `json:"tunnel_mtu"`
+	MSS4      int  `json:"mss_ipv4"`
+	MSS6      int  `json:"mss_ipv6"`
+	Known     bool `json:"known_cipher"`
+}
+
+type cipher struct {
+	block int
+	iv    int
+	icv   int
+}
+
+// ciphers maps strongSwan's encr-alg names to their ESP framing. AEAD
+// ciphers carry their own ICV; block is the padding alignment.
+var ciphers = map[string]cipher{
+	"AES_CBC":           {block: 16, iv: 16},
+	"CAMELLIA_CBC":      {block: 16, iv: 16},
+	"3DES_CBC":          {block: 8, iv: 8},
+	"AES_CTR":           {block: 4, iv: 8},
+	"AES_GCM_8":         {block: 4, iv: 8, icv: 8},
+	"AES_GCM_12":        {block: 4, iv: 8, icv: 12},
+	"AES_GCM_16":        {block: 4, iv: 8, icv: 16},
+	"AES_CCM_8":         {block: 4, iv: 8, icv: 8},
+	"AES_CCM_12":        {block: 4, iv: 8, icv: 12},
+	"AES_CCM_16":        {block: 4, iv: 8, icv: 16},
+	"CHACHA20_POLY1305": {block: 4, iv: 8, icv: 16},
+	"NULL":              {block: 4},
+}
+
+var integrity = map[string]int{
+	"HMAC_MD5_96":       12,
+	"HMAC_SHA1_96":      12,
+	"AES_XCBC_96":       12,
+	"AES_CMAC_96":       12,
+	"HMAC_SHA2_256_128": 16,
+	"HMAC_SHA2_384_192": 24,
+	"HMAC_SHA2_512_256": 32,
+}
+
+// Unknown algorithms are assumed to be as expensive as the worst case we
+// support, so the resulting MTU errs on the small side.
+var fallbackCipher = cipher{block: 16, iv: 16}
+
+const fallbackICV = 32
+
+// Calculate returns the largest inner packet that fits through the CHILD_SA
+// without fragmenting the outer ESP packet, assuming worst-case padding.
+func Calculate(p *Params) Result {
+	linkMTU := p.LinkMTU
+	if linkMTU <= 0 {
+		linkMTU = DefaultLinkMTU
+	}
+	tailnetMTU := p.TailnetMTU
+	if tailnetMTU <= 0 {
+		tailnetMTU = TailnetMTU
+	}
+
+	c, known := ciphers[strings.ToUpper(p.EncrAlg)]
+	if !known {
+		c = fallbackCipher
+	}
+	icv := c.icv
+	if icv == 0 {
+		integ, ok := integrity[strings.ToUpper(p.IntegAlg)]
+		if !ok {
+			integ = fallbackICV
+			known = false
+		}
+		icv = integ
+	}
+
+	fixed := espHeaderLen + c.iv + icv
+	if !strings.EqualFold(p.Mode, "TRANSPORT") {
+		if p.OuterIPv6 {
+			fixed += ipv6HeaderLen
+		} else {
+			fixed += ipv4HeaderLen
+		}
+	}
+	if p.UDPEncap {
+		fixed += udpHeaderLen
+	}
+
+	available := linkMTU - fixed
+	espMTU := (available/c.block)*c.block - espTrailerLen
+	if espMTU < 0 {
+		espMTU = 0
+	}
+
+	tunnelMTU := min(espMTU, tailnetMTU)
+	return Result{
+		LinkMTU:   linkMTU,
+		Overhead:  linkMTU - espMTU,
+		ESPMTU:    espMTU,
+		TunnelMTU: tunnelMTU,
+		MSS4:      max(tunnelMTU-ipv4HeaderLen-tcpHeaderLen, 0),
+		MSS6:      max(tunnelMTU-ipv6HeaderLen-tcpHeaderLen, 0),
+		Known:     known,
+	}
+}
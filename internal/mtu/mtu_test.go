@@ -0,0 +1,131 @@
+package mtu
+
+import "testing"
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     Params
+		wantESPMTU int
+		wantTunnel int
+		wantKnown  bool
+	}{
+		{
+			name:       "AES-CBC with SHA-256 in tunnel mode",
+			params:     Params{EncrAlg: "AES_CBC", IntegAlg: "HMAC_SHA2_256_128", Mode: "TUNNEL", LinkMTU: 1500},
+			wantESPMTU: 1438,
+			wantTunnel: 1280,
+			wantKnown:  true,
+		},
+		{
+			name:       "AES-CBC with NAT-T",
+			params:     Params{EncrAlg: "AES_CBC", IntegAlg: "HMAC_SHA2_256_128", Mode: "TUNNEL", LinkMTU: 1500, UDPEncap: true},
+			wantESPMTU: 1422,
+			wantTunnel: 1280,
+			wantKnown:  true,
+		},
+		{
+			name:       "AES-GCM over IPv6",
+			params:     Params{EncrAlg: "AES_GCM_16", Mode: "TUNNEL", LinkMTU: 1500, OuterIPv6: true},
+			wantESPMTU: 1426,
+			wantTunnel: 1280,
+			wantKnown:  true,
+		},
+		{
+			name:       "transport mode skips outer header",
+			params:     Params{EncrAlg: "AES_GCM_16", Mode: "TRANSPORT", LinkMTU: 1500},
+			wantESPMTU: 1466,
+			wantTunnel: 1280,
+			wantKnown:  true,
+		},
+		{
+			name:       "small underlay is the bottleneck",
+			params:     Params{EncrAlg: "AES_GCM_16", Mode: "TUNNEL", LinkMTU: 1280, UDPEncap: true},
+			wantESPMTU: 1218,
+			wantTunnel: 1218,
+			wantKnown:  true,
+		},
+		{
+			name:       "unknown cipher is conservative",
+			params:     Params{EncrAlg: "SERPENT_CBC", Mode: "TUNNEL", LinkMTU: 1500},
+			wantESPMTU: 1422,
+			wantTunnel: 1280,
+			wantKnown:  false,
+		},
+		{
+			name:       "defaults apply when link MTU is unknown",
+			params:     Params{EncrAlg: "aes_gcm_16", Mode: "TUNNEL", TailnetMTU: 1500},
+			wantESPMTU: 1446,
+			wantTunnel: 1446,
+			wantKnown:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Calculate(&tt.params)
+
+			if got.ESPMTU != tt.wantESPMTU {
+				t.Errorf("expected ESPMTU %d, got %d", tt.wantESPMTU, got.ESPMTU)
+			}
+			if got.TunnelMTU != tt.wantTunnel {
+				t.Errorf("expected TunnelMTU %d, got %d", tt.wantTunnel, got.TunnelMTU)
+			}
+			if got.Known != tt.wantKnown {
+				t.Errorf("expected Known %v, got %v", tt.wantKnown, got.Known)
+			}
+			if got.MSS4 != got.TunnelMTU-40 {
+				t.Errorf("expected MSS4 %d, got %d", got.TunnelMTU-40, got.MSS4)
+			}
+			if got.MSS6 != got.TunnelMTU-60 {
+				t.Errorf("expected MSS6 %d, got %d", got.TunnelMTU-60, got.MSS6)
+			}
+			if got.Overhead != got.LinkMTU-got.ESPMTU {
+				t.Errorf("expected Overhead %d, got %d", got.LinkMTU-got.ESPMTU, got.Overhead)
+			}
+		})
+	}
+}
+
+func TestParseRouteGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		wantDev string
+		wantMTU int
+	}{
+		{
+			name:    "device only",
+			output:  "203.0.113.10 via 192.0.2.254 dev eth0 src 192.0.2.1 uid 0 \n    cache \n",
+			wantDev: "eth0",
+		},
+		{
+			name:    "route MTU",
+			output:  "203.0.113.10 dev wan0 src 192.0.2.1 uid 0 \n    cache mtu 1492 \n",
+			wantDev: "wan0",
+			wantMTU: 1492,
+		},
+		{
+			name:    "locked route MTU",
+			output:  "203.0.113.10 dev ppp0 src 192.0.2.1 \n    cache mtu lock 1400 \n",
+			wantDev: "ppp0",
+			wantMTU: 1400,
+		},
+		{
+			name:   "garbage",
+			output: "RTNETLINK answers: Network is unreachable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, mtu := parseRouteGet(tt.output)
+			if dev != tt.wantDev {
+				t.Errorf("expected dev %q, got %q", tt.wantDev, dev)
+			}
+			if mtu != tt.wantMTU {
+				t.Errorf("expected mtu %d, got %d", tt.wantMTU, mtu)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Package operator reconciles TailSwanConnection and TailSwanGateway
+// custom resources into charon over VICI and into the routes tailscaled
+// advertises, writing the SA state back to their status.
+package operator
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// watchTimeout is how long, in seconds, the API server keeps a watch open.
+const watchTimeout = "300"
+
+// errExpired is returned by Watch when the resource version it started
+// from is too old, and the resources must be listed again.
+var errExpired = errors.New("resource version expired")
+
+// Client talks to the Kubernetes API server over its REST API: enough to
+// list and watch the TailSwan resources of one namespace, update their
+// status and read secrets.
+type Client struct {
+	http      *http.Client
+	token     func() (string, error)
+	server    string
+	namespace string
+}
+
+// NewClient reaches the API server at server, authenticating with the
+// bearer token when it is not empty.
+func NewClient(client *http.Client, server, namespace, token string) *Client {
+	return &Client{
+		http:      client,
+		token:     func() (string, error) { return token, nil },
+		server:    strings.TrimSuffix(server, "/"),
+		namespace: namespace,
+	}
+}
+
+// InCluster reaches the API server of the cluster the pod runs in, with
+// its service account. The token is read for every request, since the
+// kubelet rotates it.
+func InCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes pod: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("read the service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates in the service account CA")
+	}
+	namespace, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+	if err != nil {
+		return nil, fmt.Errorf("read the service account namespace: %w", err)
+	}
+
+	return &Client{
+		http: &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		}},
+		token:     serviceAccountToken,
+		server:    "https://" + net.JoinHostPort(host, port),
+		namespace: strings.TrimSpace(string(namespace)),
+	}, nil
+}
+
+func serviceAccountToken() (string, error) {
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return "", fmt.Errorf("read the service account token: %w", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// Namespace is the namespace whose resources the client reads.
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// WithNamespace returns a client for the resources of another namespace.
+func (c *Client) WithNamespace(namespace string) *Client {
+	clone := *c
+	clone.namespace = namespace
+	return &clone
+}
+
+func (c *Client) resourcePath(resource string) string {
+	return "/apis/" + Group + "/" + Version + "/namespaces/" + url.PathEscape(c.namespace) + "/" + resource
+}
+
+// List decodes the resources into list, a *List.
+func (c *Client) List(ctx context.Context, resource string, list any) error {
+	return c.do(ctx, http.MethodGet, c.resourcePath(resource), "", nil, list)
+}
+
+// Watch calls fn with the type of every change of the resources after
+// resourceVersion, until the API server ends the watch.
+func (c *Client) Watch(ctx context.Context, resource, resourceVersion string, fn func(eventType string)) error {
+	query := url.Values{
+		"watch":           {"true"},
+		"resourceVersion": {resourceVersion},
+		"timeoutSeconds":  {watchTimeout},
+	}
+	resp, err := c.request(ctx, http.MethodGet, c.resourcePath(resource)+"?"+query.Encode(), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type   string `json:"type"`
+			Object struct {
+				Message string `json:"message"`
+				Code    int    `json:"code"`
+			} `json:"object"`
+		}
+		if err := dec.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("watch %s: %w", resource, err)
+		}
+		if event.Type == "ERROR" {
+			if event.Object.Code == http.StatusGone {
+				return errExpired
+			}
+			return fmt.Errorf("watch %s: %s", resource, event.Object.Message)
+		}
+		fn(event.Type)
+	}
+}
+
+// UpdateStatus replaces the status of the resource name.
+func (c *Client) UpdateStatus(ctx context.Context, resource, name string, status any) error {
+	body, err := json.Marshal([]map[string]any{{"op": "add", "path": "/status", "value": status}})
+	if err != nil {
+		return err
+	}
+	path := c.resourcePath(resource) + "/" + url.PathEscape(name) + "/status"
+	return c.do(ctx, http.MethodPatch, path, "application/json-patch+json", body, nil)
+}
+
+// SecretData returns the decoded data of the Secret name.
+func (c *Client) SecretData(ctx context.Context, name string) (map[string][]byte, error) {
+	var secret struct {
+		Data map[string][]byte `json:"data"`
+	}
+	path := "/api/v1/namespaces/" + url.PathEscape(c.namespace) + "/secrets/" + url.PathEscape(name)
+	if err := c.do(ctx, http.MethodGet, path, "", nil, &secret); err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path, contentType string, body []byte, v any) error {
+	resp, err := c.request(ctx, method, path, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// request sends the request and returns the response when it succeeded,
+// or the API server's reason as an error.
+func (c *Client) request(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	token, err := c.token()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode == http.StatusGone {
+		return nil, errExpired
+	}
+	var status struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil || status.Message == "" {
+		return nil, fmt.Errorf("%s %s: %s", method, req.URL.Path, resp.Status)
+	}
+	return nil, fmt.Errorf("%s %s: %s", method, req.URL.Path, status.Message)
+}
@@ -0,0 +1,298 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testNamespace = "tailswan"
+	testToken     = "test-token"
+)
+
+// fakeAPI is an in-memory Kubernetes API server serving the TailSwan
+// resources and secrets of one namespace, with watches and the status
+// subresource.
+type fakeAPI struct {
+	objects  map[string]map[string]map[string]any
+	secrets  map[string]map[string][]byte
+	watchers map[string][]chan string
+	patches  []string
+	mu       sync.Mutex
+	version  int
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *Client) {
+	t.Helper()
+	api := &fakeAPI{
+		objects:  map[string]map[string]map[string]any{ConnectionResource: {}, GatewayResource: {}},
+		secrets:  map[string]map[string][]byte{},
+		watchers: map[string][]chan string{},
+	}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	return api, NewClient(srv.Client(), srv.URL, testNamespace, testToken)
+}
+
+// put creates or replaces the resource described by the JSON object.
+func (a *fakeAPI) put(t *testing.T, resource, object string) {
+	t.Helper()
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(object), &obj); err != nil {
+		t.Fatalf("invalid object: %v", err)
+	}
+	name := objectName(obj)
+	if name == "" {
+		t.Fatal("object without a name")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.version++
+	event := "ADDED"
+	if old, ok := a.objects[resource][name]; ok {
+		obj["status"] = old["status"]
+		event = "MODIFIED"
+	}
+	a.objects[resource][name] = obj
+	a.notify(resource, event)
+}
+
+func (a *fakeAPI) delete(resource, name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.version++
+	delete(a.objects[resource], name)
+	a.notify(resource, "DELETED")
+}
+
+func (a *fakeAPI) notify(resource, event string) {
+	for _, ch := range a.watchers[resource] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// status returns the status of the resource name, encoded as JSON.
+func (a *fakeAPI) status(t *testing.T, resource, name string, v any) {
+	t.Helper()
+	a.mu.Lock()
+	data, err := json.Marshal(a.objects[resource][name]["status"])
+	a.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (a *fakeAPI) watching(resource string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.watchers[resource]) > 0
+}
+
+func (a *fakeAPI) patchCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.patches)
+}
+
+func (a *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"kind": "Status", "message": "Unauthorized"})
+		return
+	}
+	if name, ok := strings.CutPrefix(r.URL.Path, "/api/v1/namespaces/"+testNamespace+"/secrets/"); ok {
+		a.mu.Lock()
+		data, found := a.secrets[name]
+		a.mu.Unlock()
+		if !found {
+			writeJSON(w, http.StatusNotFound, map[string]any{"kind": "Status", "message": `secrets "` + name + `" not found`})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"data": data})
+		return
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.Path, "/apis/"+Group+"/"+Version+"/namespaces/"+testNamespace+"/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.Split(rest, "/")
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet && r.URL.Query().Get("watch") == "true":
+		a.serveWatch(w, r, parts[0])
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		a.serveList(w, parts[0])
+	case len(parts) == 3 && parts[2] == "status" && r.Method == http.MethodPatch:
+		a.servePatch(w, r, parts[0], parts[1])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *fakeAPI) serveList(w http.ResponseWriter, resource string) {
+	a.mu.Lock()
+	items := []map[string]any{}
+	for _, obj := range a.objects[resource] {
+		items = append(items, obj)
+	}
+	version := strconv.Itoa(a.version)
+	a.mu.Unlock()
+	sort.Slice(items, func(i, j int) bool { return objectName(items[i]) < objectName(items[j]) })
+	writeJSON(w, http.StatusOK, map[string]any{"metadata": map[string]any{"resourceVersion": version}, "items": items})
+}
+
+func (a *fakeAPI) serveWatch(w http.ResponseWriter, r *http.Request, resource string) {
+	events := make(chan string, 16)
+	a.mu.Lock()
+	a.watchers[resource] = append(a.watchers[resource], events)
+	a.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return
+	}
+	flusher.Flush()
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			if err := enc.Encode(map[string]any{"type": event, "object": map[string]any{}}); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (a *fakeAPI) servePatch(w http.ResponseWriter, r *http.Request, resource, name string) {
+	if r.Header.Get("Content-Type") != "application/json-patch+json" {
+		http.Error(w, "unsupported patch", http.StatusUnsupportedMediaType)
+		return
+	}
+	var patch []struct {
+		Value any    `json:"value"`
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || len(patch) != 1 || patch[0].Path != "/status" {
+		http.Error(w, "invalid patch", http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	obj, ok := a.objects[resource][name]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]any{"kind": "Status", "message": "not found"})
+		return
+	}
+	obj["status"] = patch[0].Value
+	a.patches = append(a.patches, resource+"/"+name)
+	writeJSON(w, http.StatusOK, obj)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func objectName(obj map[string]any) string {
+	meta, ok := obj["metadata"].(map[string]any)
+	if !ok {
+		return ""
+	}
+	name, ok := meta["name"].(string)
+	if !ok {
+		return ""
+	}
+	return name
+}
+
+func TestClient(t *testing.T) {
+	api, client := newFakeAPI(t)
+	api.put(t, GatewayResource, `{"metadata":{"name":"gw-a","generation":2},"spec":{"advertiseRoutes":["10.1.0.0/16"]}}`)
+	api.secrets["psk"] = map[string][]byte{"key": []byte("s3cret")}
+	ctx := context.Background()
+
+	var list List[Gateway]
+	if err := client.List(ctx, GatewayResource, &list); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Metadata.Name != "gw-a" || list.Items[0].Metadata.Generation != 2 || list.Metadata.ResourceVersion != "1" {
+		t.Fatalf("unexpected list %+v", list)
+	}
+	if got := list.Items[0].Spec.AdvertiseRoutes; len(got) != 1 || got[0] != "10.1.0.0/16" {
+		t.Errorf("unexpected routes %v", got)
+	}
+
+	if err := client.UpdateStatus(ctx, GatewayResource, "gw-a", &GatewayStatus{Connections: 3}); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	var status GatewayStatus
+	api.status(t, GatewayResource, "gw-a", &status)
+	if status.Connections != 3 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	data, err := client.SecretData(ctx, "psk")
+	if err != nil || string(data["key"]) != "s3cret" {
+		t.Errorf("SecretData = %q, %v", data["key"], err)
+	}
+	if _, err := client.SecretData(ctx, "missing"); err == nil || !strings.Contains(err.Error(), `secrets "missing" not found`) {
+		t.Errorf("expected the API server's reason, got %v", err)
+	}
+
+	unauthorized := NewClient(http.DefaultClient, client.server, testNamespace, "wrong")
+	if err := unauthorized.List(ctx, GatewayResource, &list); err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("expected Unauthorized, got %v", err)
+	}
+}
+
+func TestClient_Watch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("watch") != "true" || q.Get("resourceVersion") != "41" {
+			http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		if _, err := w.Write([]byte(`{"type":"ADDED","object":{}}
+{"type":"MODIFIED","object":{}}
+{"type":"ERROR","object":{"kind":"Status","code":410,"message":"too old resource version"}}
+`)); err != nil {
+			t.Errorf("write: %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	var events []string
+	client := NewClient(srv.Client(), srv.URL, testNamespace, "")
+	err := client.Watch(context.Background(), ConnectionResource, "41", func(event string) {
+		events = append(events, event)
+	})
+	if !errors.Is(err, errExpired) {
+		t.Errorf("expected errExpired, got %v", err)
+	}
+	if strings.Join(events, ",") != "ADDED,MODIFIED" {
+		t.Errorf("unexpected events %v", events)
+	}
+}
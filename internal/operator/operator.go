@@ -0,0 +1,564 @@
+package operator
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/bgp"
+	"github.com/klowdo/tailswan/internal/statefile"
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/swanload"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const (
+	defaultResync = 30 * time.Second
+	watchRetry    = 5 * time.Second
+)
+
+// Config is what the operator reconciles, and how.
+type Config struct {
+	// SetRoutes replaces the routes advertised to the tailnet. Without it
+	// TailSwanGateway routes are not applied.
+	SetRoutes func(ctx context.Context, routes []netip.Prefix) (bool, error)
+	// Gateway is the name of the TailSwanGateway this instance is, whose
+	// connections it loads.
+	Gateway string
+	// Dir is the swanctl directory certificate and key files of
+	// connections are read relative to.
+	Dir string
+	// StatePath remembers what was loaded, so connections deleted while
+	// the operator was not running are unloaded when it starts.
+	StatePath string
+	// BaseRoutes are advertised in addition to the gateway's.
+	BaseRoutes []netip.Prefix
+	// Resync is how often everything is reconciled and the SA state
+	// written back without a change of the resources, reloading the
+	// connections and secrets charon no longer has.
+	Resync time.Duration
+}
+
+// loaded is a connection as loaded into charon, with its shared secrets.
+type loaded struct {
+	conn    *swanconf.Section
+	secrets []*swanload.Shared
+}
+
+// Operator loads the TailSwanConnections of its gateway into charon,
+// advertises the routes of its TailSwanGateway, and writes their state
+// back to the status of both.
+type Operator struct {
+	client       *Client
+	loadConn     func(ctx context.Context, conn *swanconf.Section) error
+	unloadConn   func(ctx context.Context, name string) error
+	loadShared   func(ctx context.Context, s *swanload.Shared) error
+	unloadShared func(ctx context.Context, id string) error
+	connNames    func(ctx context.Context) ([]string, error)
+	sharedIDs    func(ctx context.Context) ([]string, error)
+	ikeSAs       func(ctx context.Context) ([]viciconn.IKEIdentity, error)
+	childSAs     func() ([]viciconn.ChildSA, error)
+	applied      map[string]*loaded
+	state        []byte
+	cfg          Config
+	routesSet    bool
+}
+
+func New(client *Client, session *vici.Session, cfg Config) *Operator {
+	if cfg.Resync <= 0 {
+		cfg.Resync = defaultResync
+	}
+	return &Operator{
+		client: client,
+		cfg:    cfg,
+		loadConn: func(ctx context.Context, conn *swanconf.Section) error {
+			return swanload.LoadConn(ctx, session, conn, cfg.Dir)
+		},
+		unloadConn: func(ctx context.Context, name string) error {
+			return swanload.UnloadConn(ctx, session, name)
+		},
+		loadShared: func(ctx context.Context, s *swanload.Shared) error {
+			return swanload.LoadShared(ctx, session, s)
+		},
+		unloadShared: func(ctx context.Context, id string) error {
+			return viciconn.UnloadShared(ctx, session, id)
+		},
+		connNames: func(ctx context.Context) ([]string, error) {
+			conns, err := viciconn.Conns(ctx, session)
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, 0, len(conns))
+			for i := range conns {
+				names = append(names, conns[i].Name)
+			}
+			return names, nil
+		},
+		sharedIDs: func(ctx context.Context) ([]string, error) {
+			return viciconn.SharedIDs(ctx, session)
+		},
+		ikeSAs: func(ctx context.Context) ([]viciconn.IKEIdentity, error) {
+			return viciconn.IKEIdentities(ctx, session)
+		},
+		childSAs: func() ([]viciconn.ChildSA, error) {
+			return viciconn.ChildSAs(session)
+		},
+		applied: map[string]*loaded{},
+	}
+}
+
+// Run reconciles when a resource changes and every Resync, until ctx is
+// done. Secrets are not watched: a changed shared secret is loaded at the
+// next resync.
+func (o *Operator) Run(ctx context.Context) error {
+	if err := o.readState(); err != nil {
+		slog.Warn("Failed to read the operator state", "path", o.cfg.StatePath, "error", err)
+	}
+	slog.Info("Reconciling TailSwan resources", "namespace", o.client.Namespace(), "gateway", o.cfg.Gateway)
+
+	changed := make(chan struct{}, 1)
+	for _, resource := range []string{ConnectionResource, GatewayResource} {
+		go o.watch(ctx, resource, changed)
+	}
+	ticker := time.NewTicker(o.cfg.Resync)
+	defer ticker.Stop()
+	for {
+		if err := o.Reconcile(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Failed to reconcile TailSwan resources", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-changed:
+		}
+	}
+}
+
+// watch signals changed whenever resources of the kind change, listing
+// them again when the watch ends.
+func (o *Operator) watch(ctx context.Context, resource string, changed chan<- struct{}) {
+	notify := func(string) {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	for ctx.Err() == nil {
+		var list List[json.RawMessage]
+		err := o.client.List(ctx, resource, &list)
+		if err == nil {
+			err = o.client.Watch(ctx, resource, list.Metadata.ResourceVersion, notify)
+		}
+		// Changes between two watches are only seen by listing again.
+		notify("")
+		if err == nil || errors.Is(err, errExpired) || ctx.Err() != nil {
+			continue
+		}
+		slog.Warn("Failed to watch TailSwan resources, retrying", "resource", resource, "error", err, "retry", watchRetry)
+		select {
+		case <-ctx.Done():
+		case <-time.After(watchRetry):
+		}
+	}
+}
+
+// Reconcile loads the gateway's connections that changed, unloads those
+// deleted or moved to another gateway, sets the advertised routes, and
+// updates the status of the resources.
+func (o *Operator) Reconcile(ctx context.Context) error {
+	var conns List[Connection]
+	if err := o.client.List(ctx, ConnectionResource, &conns); err != nil {
+		return fmt.Errorf("list %s: %w", ConnectionResource, err)
+	}
+	var gateways List[Gateway]
+	if err := o.client.List(ctx, GatewayResource, &gateways); err != nil {
+		return fmt.Errorf("list %s: %w", GatewayResource, err)
+	}
+
+	var mine []*Connection
+	for i := range conns.Items {
+		if conns.Items[i].Spec.Gateway == o.cfg.Gateway {
+			mine = append(mine, &conns.Items[i])
+		}
+	}
+	sort.Slice(mine, func(i, j int) bool { return mine[i].Metadata.Name < mine[j].Metadata.Name })
+
+	connErrs := o.apply(ctx, mine)
+	if err := o.writeState(); err != nil {
+		slog.Warn("Failed to write the operator state", "path", o.cfg.StatePath, "error", err)
+	}
+
+	ikes, err := o.ikeSAs(ctx)
+	if err != nil {
+		return fmt.Errorf("list SAs: %w", err)
+	}
+	children, err := o.childSAs()
+	if err != nil {
+		return fmt.Errorf("list SAs: %w", err)
+	}
+
+	var errs []error
+	established := 0
+	for _, c := range mine {
+		_, isLoaded := o.applied[c.Metadata.Name]
+		status := connectionStatus(c, connErrs[c.Metadata.Name], isLoaded, ikes, children)
+		if status.Phase == PhaseEstablished {
+			established++
+		}
+		if err := o.updateStatus(ctx, ConnectionResource, c.Metadata.Name, &c.Status, &status); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var gw *Gateway
+	for i := range gateways.Items {
+		if gateways.Items[i].Metadata.Name == o.cfg.Gateway {
+			gw = &gateways.Items[i]
+		}
+	}
+	if err := o.reconcileGateway(ctx, gw, len(mine), established); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// apply loads what changed of the connections, or is missing from charon,
+// and unloads what is no longer wanted, returning the errors by
+// connection. A connection that cannot be read or loaded stays as it was
+// loaded before.
+func (o *Operator) apply(ctx context.Context, conns []*Connection) map[string]error {
+	inCharon := o.inCharon(ctx)
+	errs := map[string]error{}
+	next := map[string]*loaded{}
+	for _, c := range conns {
+		name := c.Metadata.Name
+		prev := o.applied[name]
+		want, err := o.desired(ctx, c)
+		if err == nil && (prev == nil || !reflect.DeepEqual(prev, want) || !inCharon(name, prev)) {
+			if err = o.load(ctx, prev, want); err == nil {
+				slog.Info("Loaded TailSwanConnection", "connection", name, "generation", c.Metadata.Generation)
+			}
+		}
+		switch {
+		case err == nil:
+			next[name] = want
+		case prev != nil:
+			next[name] = prev
+		}
+		if err != nil {
+			errs[name] = err
+			slog.Error("Failed to load TailSwanConnection", "connection", name, "error", err)
+		}
+	}
+
+	for name, prev := range o.applied {
+		if _, ok := next[name]; ok {
+			continue
+		}
+		if err := o.unload(ctx, name, prev); err != nil {
+			slog.Warn("Failed to unload TailSwanConnection", "connection", name, "error", err)
+		} else {
+			slog.Info("Unloaded TailSwanConnection", "connection", name)
+		}
+	}
+	o.applied = next
+	return errs
+}
+
+// inCharon returns whether charon still has a connection and the secrets
+// it was loaded with. They are gone after charon restarts, and after
+// `tailswan reload` or a rollback, which load swanctl.conf in their place.
+// When charon cannot be asked, everything is taken to be there.
+func (o *Operator) inCharon(ctx context.Context) func(name string, l *loaded) bool {
+	conns, err := o.connNames(ctx)
+	var shared []string
+	if err == nil {
+		shared, err = o.sharedIDs(ctx)
+	}
+	if err != nil {
+		slog.Warn("Failed to list the connections and secrets charon has loaded", "error", err)
+		return func(string, *loaded) bool { return true }
+	}
+	return func(name string, l *loaded) bool {
+		if !slices.Contains(conns, name) {
+			return false
+		}
+		for _, s := range l.secrets {
+			if !slices.Contains(shared, s.ID) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// desired converts the connection and reads its shared secrets.
+func (o *Operator) desired(ctx context.Context, c *Connection) (*loaded, error) {
+	name := c.Metadata.Name
+	conn, err := c.Spec.Section(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := swanload.ConnMessage(conn, o.cfg.Dir); err != nil {
+		return nil, err
+	}
+
+	l := &loaded{conn: conn}
+	for i, spec := range c.Spec.Secrets {
+		data, err := o.client.SecretData(ctx, spec.SecretKeyRef.Name)
+		if err != nil {
+			return nil, fmt.Errorf("secrets[%d]: %w", i, err)
+		}
+		value, ok := data[spec.SecretKeyRef.Key]
+		if !ok {
+			return nil, fmt.Errorf("secrets[%d]: secret %s has no key %s", i, spec.SecretKeyRef.Name, spec.SecretKeyRef.Key)
+		}
+		typ := spec.Type
+		if typ == "" {
+			typ = "ike"
+		}
+		// The secret is passed base64 encoded, so values looking like the
+		// 0x and 0s forms are not decoded again.
+		sec := &swanconf.Section{Name: fmt.Sprintf("%s-%s-%d", typ, name, i+1)}
+		sec.Set("secret", "0s"+base64.StdEncoding.EncodeToString(value))
+		for j, id := range spec.IDs {
+			sec.Set(fmt.Sprintf("id-%d", j), id)
+		}
+		shared, err := swanload.ParseShared(sec)
+		if err != nil {
+			return nil, fmt.Errorf("secrets[%d]: %w", i, err)
+		}
+		l.secrets = append(l.secrets, shared)
+	}
+	return l, nil
+}
+
+// load loads want, secrets first so the connection finds them, and
+// unloads the secrets of prev it no longer has.
+func (o *Operator) load(ctx context.Context, prev, want *loaded) error {
+	for _, s := range want.secrets {
+		if err := o.loadShared(ctx, s); err != nil {
+			return fmt.Errorf("secret %s: %w", s.ID, err)
+		}
+	}
+	if err := o.loadConn(ctx, want.conn); err != nil {
+		return err
+	}
+	if prev == nil {
+		return nil
+	}
+	var errs []error
+	for _, s := range prev.secrets {
+		if !slices.ContainsFunc(want.secrets, func(w *swanload.Shared) bool { return w.ID == s.ID }) {
+			errs = append(errs, o.unloadShared(ctx, s.ID))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (o *Operator) unload(ctx context.Context, name string, prev *loaded) error {
+	errs := []error{o.unloadConn(ctx, name)}
+	for _, s := range prev.secrets {
+		errs = append(errs, o.unloadShared(ctx, s.ID))
+	}
+	return errors.Join(errs...)
+}
+
+// connectionStatus is the state of the connection: Failed when it could
+// not be loaded, else by the state of its IKE_SA.
+func connectionStatus(c *Connection, loadErr error, isLoaded bool, ikes []viciconn.IKEIdentity, children []viciconn.ChildSA) ConnectionStatus {
+	name := c.Metadata.Name
+	status := ConnectionStatus{ObservedGeneration: c.Metadata.Generation}
+	if isLoaded {
+		status.Phase = PhaseLoaded
+		for i := range ikes {
+			ike := &ikes[i]
+			if ike.Connection != name || status.IKEState == "ESTABLISHED" {
+				continue
+			}
+			status.IKEState, status.RemoteHost = ike.State, ike.RemoteHost
+		}
+		switch status.IKEState {
+		case "":
+		case "ESTABLISHED":
+			status.Phase = PhaseEstablished
+		default:
+			status.Phase = PhaseConnecting
+		}
+		for i := range children {
+			child := &children[i]
+			if child.IKE == name {
+				status.ChildSAs = append(status.ChildSAs, ChildSAStatus{
+					Name:     child.Name,
+					State:    child.State,
+					LocalTS:  child.LocalTS,
+					RemoteTS: child.RemoteTS,
+				})
+			}
+		}
+		sort.SliceStable(status.ChildSAs, func(i, j int) bool { return status.ChildSAs[i].Name < status.ChildSAs[j].Name })
+	}
+	if loadErr != nil {
+		status.Phase, status.Message = PhaseFailed, loadErr.Error()
+	}
+	return status
+}
+
+// reconcileGateway advertises the gateway's routes, or only the base
+// routes once it is deleted, and updates its status.
+func (o *Operator) reconcileGateway(ctx context.Context, gw *Gateway, connections, established int) error {
+	if o.cfg.SetRoutes == nil {
+		return nil
+	}
+	if gw == nil {
+		if !o.routesSet {
+			return nil
+		}
+		if _, err := o.cfg.SetRoutes(ctx, o.cfg.BaseRoutes); err != nil {
+			return fmt.Errorf("advertise routes: %w", err)
+		}
+		o.routesSet = false
+		return nil
+	}
+
+	status := GatewayStatus{
+		ObservedGeneration: gw.Metadata.Generation,
+		Connections:        connections,
+		Established:        established,
+	}
+	routes, err := o.routes(gw)
+	if err == nil {
+		var changed bool
+		if changed, err = o.cfg.SetRoutes(ctx, routes); err == nil {
+			o.routesSet = true
+			if changed {
+				slog.Info("Advertising TailSwanGateway routes", "gateway", gw.Metadata.Name, "routes", routes)
+			}
+		}
+	}
+	if err != nil {
+		status.Message = "advertise routes: " + err.Error()
+	} else {
+		for _, p := range routes {
+			status.AdvertisedRoutes = append(status.AdvertisedRoutes, p.String())
+		}
+	}
+	return o.updateStatus(ctx, GatewayResource, gw.Metadata.Name, &gw.Status, &status)
+}
+
+// routes are the base routes, the gateway's, and with
+// advertiseConnectionRoutes the remote traffic selectors of the loaded
+// connections.
+func (o *Operator) routes(gw *Gateway) ([]netip.Prefix, error) {
+	routes := slices.Clone(o.cfg.BaseRoutes)
+	for _, s := range gw.Spec.AdvertiseRoutes {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route %q", s)
+		}
+		routes = append(routes, p)
+	}
+	if gw.Spec.AdvertiseConnectionRoutes {
+		for _, l := range o.applied {
+			if l.conn == nil || l.conn.Section("children") == nil {
+				continue
+			}
+			for _, child := range l.conn.Section("children").Sections {
+				routes = append(routes, viciconn.Prefixes(splitList(child.Get("remote_ts")))...)
+			}
+		}
+	}
+	return bgp.Sorted(routes), nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// updateStatus writes status unless the resource has it already.
+func (o *Operator) updateStatus(ctx context.Context, resource, name string, current, status any) error {
+	if reflect.DeepEqual(current, status) {
+		return nil
+	}
+	if err := o.client.UpdateStatus(ctx, resource, name, status); err != nil {
+		return fmt.Errorf("update status of %s %s: %w", resource, name, err)
+	}
+	return nil
+}
+
+// operatorState is what StatePath holds: the shared secret IDs of the
+// loaded connections, by name.
+type operatorState struct {
+	Connections map[string][]string `json:"connections"`
+}
+
+// readState takes the connections loaded before the operator restarted as
+// applied, without their contents, so they are loaded again when still
+// wanted and unloaded otherwise.
+func (o *Operator) readState() error {
+	if o.cfg.StatePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(o.cfg.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state operatorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	for name, ids := range state.Connections {
+		l := &loaded{}
+		for _, id := range ids {
+			l.secrets = append(l.secrets, &swanload.Shared{ID: id})
+		}
+		o.applied[name] = l
+	}
+	return nil
+}
+
+func (o *Operator) writeState() error {
+	if o.cfg.StatePath == "" {
+		return nil
+	}
+	state := operatorState{Connections: map[string][]string{}}
+	for name, l := range o.applied {
+		ids := []string{}
+		for _, s := range l.secrets {
+			ids = append(ids, s.ID)
+		}
+		state.Connections[name] = ids
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil || bytes.Equal(data, o.state) {
+		return err
+	}
+	if err := statefile.WriteAtomic(o.cfg.StatePath, data); err != nil {
+		return err
+	}
+	o.state = data
+	return nil
+}
@@ -0,0 +1,343 @@
+package operator
+
+import (
+	"context"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/swanload"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+// fakeCharon records what the operator loads and reports the SAs set.
+type fakeCharon struct {
+	conns    map[string]*swanconf.Section
+	shared   map[string]*swanload.Shared
+	ikes     []viciconn.IKEIdentity
+	children []viciconn.ChildSA
+	routes   []netip.Prefix
+	loads    int
+	mu       sync.Mutex
+}
+
+func newTestOperator(t *testing.T, client *Client, statePath string) (*Operator, *fakeCharon) {
+	t.Helper()
+	charon := &fakeCharon{conns: map[string]*swanconf.Section{}, shared: map[string]*swanload.Shared{}}
+	o := New(client, nil, Config{
+		Gateway:    "gw-a",
+		Dir:        t.TempDir(),
+		StatePath:  statePath,
+		BaseRoutes: []netip.Prefix{netip.MustParsePrefix("192.168.0.0/24")},
+		SetRoutes: func(_ context.Context, routes []netip.Prefix) (bool, error) {
+			charon.mu.Lock()
+			defer charon.mu.Unlock()
+			changed := !slices.Equal(charon.routes, routes)
+			charon.routes = routes
+			return changed, nil
+		},
+	})
+	o.loadConn = func(_ context.Context, conn *swanconf.Section) error {
+		charon.mu.Lock()
+		defer charon.mu.Unlock()
+		charon.conns[conn.Name] = conn
+		charon.loads++
+		return nil
+	}
+	o.unloadConn = func(_ context.Context, name string) error {
+		charon.mu.Lock()
+		defer charon.mu.Unlock()
+		delete(charon.conns, name)
+		return nil
+	}
+	o.loadShared = func(_ context.Context, s *swanload.Shared) error {
+		charon.mu.Lock()
+		defer charon.mu.Unlock()
+		charon.shared[s.ID] = s
+		return nil
+	}
+	o.unloadShared = func(_ context.Context, id string) error {
+		charon.mu.Lock()
+		defer charon.mu.Unlock()
+		delete(charon.shared, id)
+		return nil
+	}
+	o.connNames = func(context.Context) ([]string, error) {
+		charon.mu.Lock()
+		defer charon.mu.Unlock()
+		var names []string
+		for name := range charon.conns {
+			names = append(names, name)
+		}
+		return names, nil
+	}
+	o.sharedIDs = func(context.Context) ([]string, error) {
+		charon.mu.Lock()
+		defer charon.mu.Unlock()
+		var ids []string
+		for id := range charon.shared {
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
+	o.ikeSAs = func(context.Context) ([]viciconn.IKEIdentity, error) {
+		charon.mu.Lock()
+		defer charon.mu.Unlock()
+		return charon.ikes, nil
+	}
+	o.childSAs = func() ([]viciconn.ChildSA, error) {
+		charon.mu.Lock()
+		defer charon.mu.Unlock()
+		return charon.children, nil
+	}
+	return o, charon
+}
+
+func (c *fakeCharon) loaded(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.conns[name]
+	return ok
+}
+
+const siteB = `{
+	"metadata": {"name": "site-b", "generation": 3},
+	"spec": {
+		"gateway": "gw-a",
+		"connection": {
+			"version": 2,
+			"remote_addrs": ["198.51.100.7"],
+			"mobike": false,
+			"local": {"auth": "psk", "id": "gw-a"},
+			"remote": {"auth": "psk", "id": "gw-b"},
+			"children": {
+				"net": {
+					"local_ts": "10.1.0.0/16",
+					"remote_ts": ["10.3.0.0/16", "10.4.0.0/16"],
+					"start_action": "start"
+				}
+			}
+		},
+		"secrets": [{"ids": ["gw-a", "gw-b"], "secretKeyRef": {"name": "site-b-psk", "key": "psk"}}]
+	}
+}`
+
+func TestReconcile(t *testing.T) {
+	api, client := newFakeAPI(t)
+	api.secrets["site-b-psk"] = map[string][]byte{"psk": []byte("0xnot-hex")}
+	api.put(t, ConnectionResource, siteB)
+	api.put(t, ConnectionResource, `{"metadata":{"name":"site-c"},"spec":{"gateway":"gw-a","connection":{"remote_addrs":"203.0.113.9"},
+		"secrets":[{"secretKeyRef":{"name":"missing","key":"psk"}}]}}`)
+	api.put(t, ConnectionResource, `{"metadata":{"name":"elsewhere"},"spec":{"gateway":"gw-z","connection":{"remote_addrs":"203.0.113.1"}}}`)
+	api.put(t, GatewayResource, `{"metadata":{"name":"gw-a","generation":5},"spec":{"advertiseRoutes":["10.1.0.0/16"],"advertiseConnectionRoutes":true}}`)
+
+	o, charon := newTestOperator(t, client, "")
+	charon.ikes = []viciconn.IKEIdentity{{Connection: "site-b", State: "ESTABLISHED", RemoteHost: "198.51.100.7"}}
+	charon.children = []viciconn.ChildSA{{IKE: "site-b", Name: "net", State: "INSTALLED", LocalTS: []string{"10.1.0.0/16"}, RemoteTS: []string{"10.3.0.0/16", "10.4.0.0/16"}}}
+
+	ctx := context.Background()
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	if len(charon.conns) != 1 {
+		t.Fatalf("expected only site-b loaded, got %v", charon.conns)
+	}
+	conn := charon.conns["site-b"]
+	if conn == nil || conn.Get("version") != "2" || conn.Get("mobike") != "no" || conn.Get("remote_addrs") != "198.51.100.7" {
+		t.Fatalf("unexpected connection %v", conn)
+	}
+	if got := conn.Section("children", "net").Get("remote_ts"); got != "10.3.0.0/16, 10.4.0.0/16" {
+		t.Errorf("unexpected remote_ts %q", got)
+	}
+	s := charon.shared["ike-site-b-1"]
+	if s == nil || s.Type != "IKE" || s.Data != "0xnot-hex" || strings.Join(s.Owners, ",") != "gw-a,gw-b" {
+		t.Errorf("unexpected shared secret %+v", s)
+	}
+	if got := prefixStrings(charon.routes); got != "10.1.0.0/16,10.3.0.0/16,10.4.0.0/16,192.168.0.0/24" {
+		t.Errorf("unexpected routes %s", got)
+	}
+
+	var status ConnectionStatus
+	api.status(t, ConnectionResource, "site-b", &status)
+	if status.Phase != PhaseEstablished || status.IKEState != "ESTABLISHED" || status.RemoteHost != "198.51.100.7" || status.ObservedGeneration != 3 {
+		t.Errorf("unexpected site-b status %+v", status)
+	}
+	if len(status.ChildSAs) != 1 || status.ChildSAs[0].Name != "net" || status.ChildSAs[0].State != "INSTALLED" {
+		t.Errorf("unexpected child SAs %+v", status.ChildSAs)
+	}
+	status = ConnectionStatus{}
+	api.status(t, ConnectionResource, "site-c", &status)
+	if status.Phase != PhaseFailed || !strings.Contains(status.Message, `secrets "missing" not found`) {
+		t.Errorf("unexpected site-c status %+v", status)
+	}
+	var gw GatewayStatus
+	api.status(t, GatewayResource, "gw-a", &gw)
+	if gw.Connections != 2 || gw.Established != 1 || len(gw.AdvertisedRoutes) != 4 || gw.ObservedGeneration != 5 {
+		t.Errorf("unexpected gateway status %+v", gw)
+	}
+
+	// Nothing changed: nothing is loaded and no status written again.
+	loads, patches := charon.loads, api.patchCount()
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if charon.loads != loads || api.patchCount() != patches {
+		t.Errorf("expected no changes, got %d loads and %d patches", charon.loads-loads, api.patchCount()-patches)
+	}
+
+	api.delete(ConnectionResource, "site-b")
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(charon.conns) != 0 || len(charon.shared) != 0 {
+		t.Errorf("expected site-b and its secret unloaded, got %v %v", charon.conns, charon.shared)
+	}
+	if got := prefixStrings(charon.routes); got != "10.1.0.0/16,192.168.0.0/24" {
+		t.Errorf("unexpected routes %s", got)
+	}
+
+	api.delete(GatewayResource, "gw-a")
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if got := prefixStrings(charon.routes); got != "192.168.0.0/24" {
+		t.Errorf("expected only the base routes once the gateway is deleted, got %s", got)
+	}
+}
+
+func TestReconcile_KeepsLoadedOnError(t *testing.T) {
+	api, client := newFakeAPI(t)
+	api.secrets["site-b-psk"] = map[string][]byte{"psk": []byte("s3cret")}
+	api.put(t, ConnectionResource, siteB)
+
+	o, charon := newTestOperator(t, client, "")
+	ctx := context.Background()
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	delete(api.secrets, "site-b-psk")
+	api.put(t, ConnectionResource, strings.Replace(siteB, `"version": 2`, `"version": 1`, 1))
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if charon.conns["site-b"].Get("version") != "2" || charon.shared["ike-site-b-1"] == nil {
+		t.Errorf("expected site-b to stay loaded as before, got %v", charon.conns["site-b"])
+	}
+	var status ConnectionStatus
+	api.status(t, ConnectionResource, "site-b", &status)
+	if status.Phase != PhaseFailed {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestReconcile_ReloadsMissing(t *testing.T) {
+	api, client := newFakeAPI(t)
+	api.secrets["site-b-psk"] = map[string][]byte{"psk": []byte("s3cret")}
+	api.put(t, ConnectionResource, siteB)
+
+	o, charon := newTestOperator(t, client, "")
+	ctx := context.Background()
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	// charon restarted, or `tailswan reload` loaded swanctl.conf in place
+	// of the connection.
+	delete(charon.conns, "site-b")
+	delete(charon.shared, "ike-site-b-1")
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if !charon.loaded("site-b") || charon.shared["ike-site-b-1"] == nil {
+		t.Errorf("expected site-b and its secret loaded again, got %v %v", charon.conns, charon.shared)
+	}
+
+	// A cleared secret alone also reloads the connection.
+	loads := charon.loads
+	delete(charon.shared, "ike-site-b-1")
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if charon.shared["ike-site-b-1"] == nil || charon.loads != loads+1 {
+		t.Errorf("expected the secret loaded again, got %v after %d loads", charon.shared, charon.loads-loads)
+	}
+}
+
+func TestReconcile_UnloadsAfterRestart(t *testing.T) {
+	api, client := newFakeAPI(t)
+	api.secrets["site-b-psk"] = map[string][]byte{"psk": []byte("s3cret")}
+	api.put(t, ConnectionResource, siteB)
+	statePath := filepath.Join(t.TempDir(), "operator.json")
+
+	o, _ := newTestOperator(t, client, statePath)
+	ctx := context.Background()
+	if err := o.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	api.delete(ConnectionResource, "site-b")
+	restarted, charon := newTestOperator(t, client, statePath)
+	charon.conns["site-b"] = &swanconf.Section{Name: "site-b"}
+	charon.shared["ike-site-b-1"] = &swanload.Shared{ID: "ike-site-b-1"}
+	if err := restarted.readState(); err != nil {
+		t.Fatalf("readState: %v", err)
+	}
+	if err := restarted.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(charon.conns) != 0 || len(charon.shared) != 0 {
+		t.Errorf("expected site-b unloaded after the restart, got %v %v", charon.conns, charon.shared)
+	}
+}
+
+func TestRun(t *testing.T) {
+	api, client := newFakeAPI(t)
+	api.secrets["site-b-psk"] = map[string][]byte{"psk": []byte("s3cret")}
+	o, charon := newTestOperator(t, client, "")
+	o.cfg.Resync = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := o.Run(ctx); err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Only the watch can bring the resource created after the first
+	// reconcile in before the resync.
+	deadline := time.Now().Add(5 * time.Second)
+	for !api.watching(ConnectionResource) {
+		if time.Now().After(deadline) {
+			t.Fatal("the operator did not watch the connections")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	api.put(t, ConnectionResource, siteB)
+	for !charon.loaded("site-b") {
+		if time.Now().After(deadline) {
+			t.Fatal("site-b was not loaded after it was created")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func prefixStrings(prefixes []netip.Prefix) string {
+	var s []string
+	for _, p := range prefixes {
+		s = append(s, p.String())
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,183 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+)
+
+// Group and Version are the API group and version of the TailSwan custom
+// resources, as in deploy/kubernetes/crds.yaml.
+const (
+	Group   = "tailswan.io"
+	Version = "v1alpha1"
+
+	ConnectionResource = "tailswanconnections"
+	GatewayResource    = "tailswangateways"
+)
+
+// Phases of a TailSwanConnection.
+const (
+	PhaseLoaded      = "Loaded"
+	PhaseConnecting  = "Connecting"
+	PhaseEstablished = "Established"
+	PhaseFailed      = "Failed"
+)
+
+type ObjectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Generation      int64  `json:"generation,omitempty"`
+}
+
+type ListMeta struct {
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// List is the list of resources the API server returns.
+type List[T any] struct {
+	Metadata ListMeta `json:"metadata"`
+	Items    []T      `json:"items"`
+}
+
+// Connection is a TailSwanConnection: an IPsec connection loaded into
+// charon by the gateway it names.
+type Connection struct {
+	Spec     ConnectionSpec   `json:"spec"`
+	Metadata ObjectMeta       `json:"metadata"`
+	Status   ConnectionStatus `json:"status"`
+}
+
+type ConnectionSpec struct {
+	// Connection is the connection as written in the connections section
+	// of swanctl.conf, with nested objects for subsections such as local,
+	// remote and children, and lists for comma-separated values.
+	Connection map[string]any `json:"connection"`
+	// Gateway is the name of the TailSwanGateway loading the connection.
+	Gateway string       `json:"gateway"`
+	Secrets []SecretSpec `json:"secrets,omitempty"`
+}
+
+// SecretSpec is a shared secret of the connection, read from a key of a
+// Kubernetes Secret.
+type SecretSpec struct {
+	SecretKeyRef SecretKeySelector `json:"secretKeyRef"`
+	// Type is ike, eap, xauth, ntlm or ppk; ike when empty.
+	Type string   `json:"type,omitempty"`
+	IDs  []string `json:"ids,omitempty"`
+}
+
+type SecretKeySelector struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+type ConnectionStatus struct {
+	Phase      string `json:"phase,omitempty"`
+	Message    string `json:"message,omitempty"`
+	IKEState   string `json:"ikeState,omitempty"`
+	RemoteHost string `json:"remoteHost,omitempty"`
+	// ChildSAs are the CHILD_SAs of the connection's IKE_SAs.
+	ChildSAs           []ChildSAStatus `json:"childSAs,omitempty"`
+	ObservedGeneration int64           `json:"observedGeneration,omitempty"`
+}
+
+type ChildSAStatus struct {
+	Name     string   `json:"name"`
+	State    string   `json:"state"`
+	LocalTS  []string `json:"localTS,omitempty"`
+	RemoteTS []string `json:"remoteTS,omitempty"`
+}
+
+// Gateway is a TailSwanGateway: a TailSwan instance, by the name its
+// operator is started with, and the routes it advertises to the tailnet.
+type Gateway struct {
+	Spec     GatewaySpec   `json:"spec"`
+	Metadata ObjectMeta    `json:"metadata"`
+	Status   GatewayStatus `json:"status"`
+}
+
+type GatewaySpec struct {
+	AdvertiseRoutes []string `json:"advertiseRoutes,omitempty"`
+	// AdvertiseConnectionRoutes also advertises the remote traffic
+	// selectors of the gateway's connections.
+	AdvertiseConnectionRoutes bool `json:"advertiseConnectionRoutes,omitempty"`
+}
+
+type GatewayStatus struct {
+	Message            string   `json:"message,omitempty"`
+	AdvertisedRoutes   []string `json:"advertisedRoutes,omitempty"`
+	Connections        int      `json:"connections"`
+	Established        int      `json:"established"`
+	ObservedGeneration int64    `json:"observedGeneration,omitempty"`
+}
+
+// Section converts the spec's connection to a swanctl.conf section named
+// name. Keys are sorted, since JSON objects have no order.
+func (s *ConnectionSpec) Section(name string) (*swanconf.Section, error) {
+	if len(s.Connection) == 0 {
+		return nil, fmt.Errorf("%s: spec.connection is empty", name)
+	}
+	sec := &swanconf.Section{Name: name}
+	if err := fill(sec, s.Connection); err != nil {
+		return nil, fmt.Errorf("%s.%w", name, err)
+	}
+	return sec, nil
+}
+
+func fill(sec *swanconf.Section, values map[string]any) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch v := values[key].(type) {
+		case nil:
+			// null leaves the key unset.
+		case map[string]any:
+			sub := &swanconf.Section{Name: key}
+			if err := fill(sub, v); err != nil {
+				return fmt.Errorf("%s.%w", key, err)
+			}
+			sec.Sections = append(sec.Sections, sub)
+		case []any:
+			list := make([]string, 0, len(v))
+			for _, item := range v {
+				s, err := scalar(item)
+				if err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+				list = append(list, s)
+			}
+			sec.Set(key, strings.Join(list, ", "))
+		default:
+			s, err := scalar(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			sec.Set(key, s)
+		}
+	}
+	return nil
+}
+
+func scalar(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		if v {
+			return "yes", nil
+		}
+		return "no", nil
+	}
+	return "", fmt.Errorf("unexpected %T value", v)
+}
@@ -0,0 +1,64 @@
+package operator
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestConnectionSpec_Section(t *testing.T) {
+	var spec ConnectionSpec
+	if err := json.Unmarshal([]byte(`{"connection": {
+		"version": 2,
+		"rekey_time": "4h",
+		"mobike": false,
+		"proposals": ["aes256-sha256-modp2048", "aes128-sha256-modp2048"],
+		"local": {"auth": "pubkey", "certs": "gw-a.pem"},
+		"children": {"net": {"remote_ts": "10.3.0.0/16", "dpd_action": null}}
+	}}`), &spec); err != nil {
+		t.Fatal(err)
+	}
+	sec, err := spec.Section("site-b")
+	if err != nil {
+		t.Fatalf("Section: %v", err)
+	}
+	want := `mobike = no
+proposals = aes256-sha256-modp2048, aes128-sha256-modp2048
+rekey_time = 4h
+version = 2
+
+children {
+    net {
+        remote_ts = 10.3.0.0/16
+    }
+}
+
+local {
+    auth = pubkey
+    certs = gw-a.pem
+}
+`
+	if sec.Name != "site-b" {
+		t.Errorf("unexpected name %q", sec.Name)
+	}
+	if got := sec.String(); got != want {
+		t.Errorf("unexpected section:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, tc := range []struct {
+		name, connection, err string
+	}{
+		{"empty", `{}`, "spec.connection is empty"},
+		{"object in list", `{"children": {"net": {"local_ts": [{"cidr": "10.1.0.0/16"}]}}}`, "site-b.children.net.local_ts: unexpected map[string]interface {} value"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var spec ConnectionSpec
+			if err := json.Unmarshal([]byte(`{"connection": `+tc.connection+`}`), &spec); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := spec.Section("site-b"); err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
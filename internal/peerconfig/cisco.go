@@ -0,0 +1,236 @@
+package peerconfig
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// renderCisco renders IOS / IOS XE configuration with a crypto map on the
+// outside interface.
+func renderCisco(p *Peer) string {
+	var b strings.Builder
+	b.WriteString(header(p, "!"))
+	name := "tailswan-" + p.Name
+
+	if p.Version == 1 {
+		ciscoIKEv1(&b, p)
+	} else {
+		ciscoIKEv2(&b, p, name)
+	}
+
+	seq := 10
+	for i := range p.Children {
+		c := &p.Children[i]
+		childName := name + "-" + c.Name
+		esp := &c.Proposals[0]
+		if len(c.Proposals) > 1 {
+			fmt.Fprintf(&b, "! NOTE: only the first ESP proposal of %s is rendered\n", c.Name)
+		}
+		fmt.Fprintf(&b, "crypto ipsec transform-set %s %s\n", childName, ciscoTransforms(esp))
+		b.WriteString(" mode tunnel\n!\n")
+
+		fmt.Fprintf(&b, "ip access-list extended %s\n", childName)
+		for _, local := range c.LocalTS {
+			for _, remote := range c.RemoteTS {
+				if !local.Addr().Is4() || !remote.Addr().Is4() {
+					continue
+				}
+				fmt.Fprintf(&b, " permit ip %s %s\n", ciscoNetwork(local), ciscoNetwork(remote))
+			}
+		}
+		b.WriteString("!\n")
+
+		fmt.Fprintf(&b, "crypto map tailswan %d ipsec-isakmp\n", seq)
+		fmt.Fprintf(&b, " set peer %s\n", p.RemoteAddr)
+		fmt.Fprintf(&b, " set transform-set %s\n", childName)
+		if c.PFS() != "" {
+			fmt.Fprintf(&b, " set pfs group%d\n", DHGroup(c.PFS()))
+		}
+		fmt.Fprintf(&b, " set security-association lifetime seconds %d\n", c.Lifetime)
+		if p.Version != 1 {
+			fmt.Fprintf(&b, " set ikev2-profile %s\n", name)
+		}
+		fmt.Fprintf(&b, " match address %s\n!\n", childName)
+		seq += 10
+	}
+	b.WriteString("! Apply to the outside interface:\n")
+	b.WriteString("! interface GigabitEthernet0/0\n!  crypto map tailswan\n")
+	if hasIPv6(p) {
+		b.WriteString("! NOTE: IPv6 traffic selectors need an IPv6 crypto map and are not rendered\n")
+	}
+	return b.String()
+}
+
+func ciscoIKEv2(b *strings.Builder, p *Peer, name string) {
+	for i := range p.Proposals {
+		prop := &p.Proposals[i]
+		fmt.Fprintf(b, "crypto ikev2 proposal %s-%d\n", name, i+1)
+		fmt.Fprintf(b, " encryption %s\n", mapJoin(prop.Encryption, ciscoIKEEncryption))
+		if len(prop.Integrity) > 0 {
+			fmt.Fprintf(b, " integrity %s\n", strings.Join(prop.Integrity, " "))
+		}
+		prf := prop.PRF
+		if len(prf) == 0 && prop.AEAD() {
+			prf = []string{"sha256"}
+		}
+		if len(prf) > 0 {
+			fmt.Fprintf(b, " prf %s\n", strings.Join(prf, " "))
+		}
+		fmt.Fprintf(b, " group %s\n!\n", strings.Join(dhNumbers(prop.DH), " "))
+	}
+	fmt.Fprintf(b, "crypto ikev2 policy %s\n", name)
+	for i := range p.Proposals {
+		fmt.Fprintf(b, " proposal %s-%d\n", name, i+1)
+	}
+	b.WriteString("!\n")
+
+	if swanAuth(p.RemoteAuth) == "psk" {
+		fmt.Fprintf(b, "crypto ikev2 keyring %s\n", name)
+		b.WriteString(" peer tailswan\n")
+		fmt.Fprintf(b, "  address %s\n", p.RemoteAddr)
+		fmt.Fprintf(b, "  pre-shared-key %s\n!\n", placeholderPSK)
+	}
+
+	fmt.Fprintf(b, "crypto ikev2 profile %s\n", name)
+	if IDType(p.RemoteID) == "dn" {
+		fmt.Fprintf(b, " match %s\n", ciscoRemoteIdentity(p.RemoteID))
+	} else {
+		fmt.Fprintf(b, " match identity remote %s\n", ciscoRemoteIdentity(p.RemoteID))
+	}
+	fmt.Fprintf(b, " identity local %s\n", ciscoLocalIdentity(p.LocalID))
+	fmt.Fprintf(b, " authentication remote %s\n", ciscoAuth(p.RemoteAuth))
+	fmt.Fprintf(b, " authentication local %s\n", ciscoAuth(p.LocalAuth))
+	if swanAuth(p.RemoteAuth) == "psk" {
+		fmt.Fprintf(b, " keyring local %s\n", name)
+	} else {
+		b.WriteString(" pki trustpoint <TRUSTPOINT>\n")
+	}
+	fmt.Fprintf(b, " lifetime %d\n", p.Lifetime)
+	if p.DPDDelay > 0 {
+		fmt.Fprintf(b, " dpd %d 5 on-demand\n", p.DPDDelay)
+	}
+	b.WriteString("!\n")
+}
+
+func ciscoIKEv1(b *strings.Builder, p *Peer) {
+	for i := range p.Proposals {
+		prop := &p.Proposals[i]
+		fmt.Fprintf(b, "crypto isakmp policy %d\n", (i+1)*10)
+		fmt.Fprintf(b, " encryption %s\n", ciscoIKEv1Encryption(prop.Encryption[0]))
+		if len(prop.Integrity) > 0 {
+			hash := prop.Integrity[0]
+			if hash == "sha1" {
+				hash = "sha"
+			}
+			fmt.Fprintf(b, " hash %s\n", hash)
+		}
+		fmt.Fprintf(b, " authentication %s\n", ciscoAuth(p.LocalAuth))
+		if len(prop.DH) > 0 {
+			fmt.Fprintf(b, " group %d\n", DHGroup(prop.DH[0]))
+		}
+		fmt.Fprintf(b, " lifetime %d\n!\n", p.Lifetime)
+	}
+	if swanAuth(p.RemoteAuth) == "psk" {
+		fmt.Fprintf(b, "crypto isakmp key %s address %s\n!\n", placeholderPSK, p.RemoteAddr)
+	}
+}
+
+func mapJoin(algs []string, f func(string) string) string {
+	list := make([]string, 0, len(algs))
+	for _, a := range algs {
+		list = append(list, f(a))
+	}
+	return strings.Join(list, " ")
+}
+
+func ciscoIKEEncryption(alg string) string {
+	switch aesMode(alg) {
+	case "cbc", "gcm":
+		return fmt.Sprintf("aes-%s-%d", aesMode(alg), keyLength(alg))
+	}
+	return alg
+}
+
+func ciscoIKEv1Encryption(alg string) string {
+	if aesMode(alg) == "cbc" {
+		return fmt.Sprintf("aes %d", keyLength(alg))
+	}
+	return alg
+}
+
+func ciscoTransforms(prop *Proposal) string {
+	alg := prop.Encryption[0]
+	var transforms []string
+	switch {
+	case aesMode(alg) == "gcm":
+		transforms = append(transforms, fmt.Sprintf("esp-gcm %d", keyLength(alg)))
+	case aesMode(alg) == "cbc":
+		transforms = append(transforms, fmt.Sprintf("esp-aes %d", keyLength(alg)))
+	case alg == "3des":
+		transforms = append(transforms, "esp-3des")
+	default:
+		transforms = append(transforms, "esp-"+alg)
+	}
+	if len(prop.Integrity) > 0 {
+		switch integ := prop.Integrity[0]; integ {
+		case "sha1":
+			transforms = append(transforms, "esp-sha-hmac")
+		default:
+			transforms = append(transforms, "esp-"+integ+"-hmac")
+		}
+	}
+	return strings.Join(transforms, " ")
+}
+
+func ciscoAuth(method string) string {
+	if method == "pubkey" {
+		return "rsa-sig"
+	}
+	return "pre-share"
+}
+
+func ciscoRemoteIdentity(id string) string {
+	switch IDType(id) {
+	case "address":
+		return "address " + id + " 255.255.255.255"
+	case "email":
+		return "email " + id
+	case "dn":
+		return "certificate <CERTIFICATE_MAP>"
+	}
+	return "fqdn " + IDValue(id)
+}
+
+func ciscoLocalIdentity(id string) string {
+	switch IDType(id) {
+	case "address":
+		return "address " + id
+	case "email":
+		return "email " + id
+	case "dn":
+		return "dn"
+	}
+	return "fqdn " + IDValue(id)
+}
+
+// ciscoNetwork renders a prefix as address and wildcard mask.
+func ciscoNetwork(p netip.Prefix) string {
+	if p.Bits() == 32 {
+		return "host " + p.Addr().String()
+	}
+	mask := ^uint32(0) >> p.Bits()
+	b := [4]byte{byte(mask >> 24), byte(mask >> 16), byte(mask >> 8), byte(mask)}
+	return p.Addr().String() + " " + netip.AddrFrom4(b).String()
+}
+
+func hasIPv6(p *Peer) bool {
+	for i := range p.Children {
+		for _, ts := range append(append([]netip.Prefix(nil), p.Children[i].LocalTS...), p.Children[i].RemoteTS...) {
+			if ts.Addr().Is6() {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package peerconfig
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// renderFortinet renders FortiOS route-based phase1/phase2 interfaces.
+func renderFortinet(p *Peer) string {
+	var b strings.Builder
+	b.WriteString(header(p, "#"))
+	name := "tailswan-" + p.Name
+
+	b.WriteString("config vpn ipsec phase1-interface\n")
+	fmt.Fprintf(&b, "    edit %q\n", name)
+	b.WriteString("        set interface \"wan1\"\n")
+	fmt.Fprintf(&b, "        set ike-version %d\n", p.Version)
+	if swanAuth(p.RemoteAuth) == "pubkey" {
+		b.WriteString("        set authmethod signature\n")
+		b.WriteString("        set certificate \"<CERTIFICATE>\"\n")
+	}
+	if IDType(p.RemoteID) == "address" {
+		b.WriteString("        set peertype any\n")
+	} else {
+		b.WriteString("        set peertype one\n")
+		fmt.Fprintf(&b, "        set peerid %q\n", IDValue(p.RemoteID))
+	}
+	if IDType(p.LocalID) != "address" {
+		fmt.Fprintf(&b, "        set localid %q\n", IDValue(p.LocalID))
+	}
+	b.WriteString("        set net-device disable\n")
+	fmt.Fprintf(&b, "        set proposal %s\n", fortinetProposals(p.Proposals, true))
+	fmt.Fprintf(&b, "        set dhgrp %s\n", strings.Join(dhNumbers(allDH(p.Proposals)), " "))
+	fmt.Fprintf(&b, "        set remote-gw %s\n", p.RemoteAddr)
+	if swanAuth(p.RemoteAuth) == "psk" {
+		fmt.Fprintf(&b, "        set psksecret %s\n", placeholderPSK)
+	}
+	fmt.Fprintf(&b, "        set keylife %d\n", p.Lifetime)
+	if p.DPDDelay > 0 {
+		b.WriteString("        set dpd on-idle\n")
+		fmt.Fprintf(&b, "        set dpd-retryinterval %d\n", p.DPDDelay)
+	}
+	b.WriteString("    next\nend\n\n")
+
+	b.WriteString("config vpn ipsec phase2-interface\n")
+	for i := range p.Children {
+		c := &p.Children[i]
+		n := 0
+		for _, local := range c.LocalTS {
+			for _, remote := range c.RemoteTS {
+				if local.Addr().Is4() != remote.Addr().Is4() {
+					continue
+				}
+				n++
+				fmt.Fprintf(&b, "    edit %q\n", fmt.Sprintf("%s-%s-%d", name, c.Name, n))
+				fmt.Fprintf(&b, "        set phase1name %q\n", name)
+				fmt.Fprintf(&b, "        set proposal %s\n", fortinetProposals(c.Proposals, false))
+				if c.PFS() != "" {
+					b.WriteString("        set pfs enable\n")
+					fmt.Fprintf(&b, "        set dhgrp %d\n", DHGroup(c.PFS()))
+				} else {
+					b.WriteString("        set pfs disable\n")
+				}
+				b.WriteString("        set auto-negotiate enable\n")
+				fmt.Fprintf(&b, "        set keylifeseconds %d\n", c.Lifetime)
+				fortinetSubnet(&b, "src", local)
+				fortinetSubnet(&b, "dst", remote)
+				b.WriteString("    next\n")
+			}
+		}
+	}
+	b.WriteString("end\n\n")
+	fmt.Fprintf(&b, "# Add static routes to the TailSwan networks via the %q interface and\n", name)
+	b.WriteString("# firewall policies allowing traffic to and from it.\n")
+	return b.String()
+}
+
+func fortinetSubnet(b *strings.Builder, dir string, p netip.Prefix) {
+	if p.Addr().Is6() {
+		fmt.Fprintf(b, "        set %s-addr-type subnet6\n", dir)
+		fmt.Fprintf(b, "        set %s-subnet6 %s\n", dir, p)
+		return
+	}
+	fmt.Fprintf(b, "        set %s-subnet %s %s\n", dir, p.Addr(), netmask(p))
+}
+
+func netmask(p netip.Prefix) string {
+	mask := ^uint32(0) << (32 - p.Bits())
+	return netip.AddrFrom4([4]byte{byte(mask >> 24), byte(mask >> 16), byte(mask >> 8), byte(mask)}).String()
+}
+
+// fortinetProposals combines each proposal's ciphers and integrity
+// algorithms into FortiOS's cipher-hash pairs.
+func fortinetProposals(proposals []Proposal, ike bool) string {
+	var pairs []string
+	for i := range proposals {
+		prop := &proposals[i]
+		for _, enc := range prop.Encryption {
+			cipher := fortinetCipher(enc)
+			if isAEAD(enc) {
+				if ike {
+					prf := "sha256"
+					if len(prop.PRF) > 0 {
+						prf = prop.PRF[0]
+					}
+					cipher += "-prf" + prf
+				}
+				pairs = appendUnique(pairs, cipher)
+				continue
+			}
+			for _, integ := range prop.Integrity {
+				pairs = appendUnique(pairs, cipher+"-"+integ)
+			}
+		}
+	}
+	return strings.Join(pairs, " ")
+}
+
+func fortinetCipher(alg string) string {
+	switch aesMode(alg) {
+	case "gcm":
+		return fmt.Sprintf("aes%dgcm", keyLength(alg))
+	case "cbc":
+		return fmt.Sprintf("aes%d", keyLength(alg))
+	}
+	return alg
+}
+
+func allDH(proposals []Proposal) []string {
+	var groups []string
+	for i := range proposals {
+		for _, g := range proposals[i].DH {
+			groups = appendUnique(groups, g)
+		}
+	}
+	return groups
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,127 @@
+package peerconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// renderMikroTik renders RouterOS 7 commands.
+func renderMikroTik(p *Peer) string {
+	var b strings.Builder
+	b.WriteString(header(p, "#"))
+	name := "tailswan-" + p.Name
+	ike := &p.Proposals[0]
+	if len(p.Proposals) > 1 {
+		b.WriteString("# NOTE: only the first IKE proposal is rendered\n")
+	}
+
+	b.WriteString("/ip ipsec profile\n")
+	fmt.Fprintf(&b, "add name=%s enc-algorithm=%s hash-algorithm=%s dh-group=%s lifetime=%s",
+		name, mapJoinComma(ike.Encryption, mikrotikIKECipher), mikrotikHash(ike), strings.Join(ike.DH, ","), rosDuration(p.Lifetime))
+	if p.DPDDelay > 0 {
+		fmt.Fprintf(&b, " dpd-interval=%s", rosDuration(p.DPDDelay))
+	}
+	b.WriteString("\n")
+
+	exchange := "ike2"
+	if p.Version == 1 {
+		exchange = "main"
+	}
+	b.WriteString("/ip ipsec peer\n")
+	fmt.Fprintf(&b, "add name=%s address=%s exchange-mode=%s profile=%s\n", name, p.RemoteAddr, exchange, name)
+
+	b.WriteString("/ip ipsec identity\n")
+	fmt.Fprintf(&b, "add peer=%s", name)
+	if swanAuth(p.RemoteAuth) == "pubkey" {
+		b.WriteString(" auth-method=digital-signature certificate=<CERTIFICATE>")
+	} else {
+		fmt.Fprintf(&b, " auth-method=pre-shared-key secret=%q", placeholderPSK)
+	}
+	fmt.Fprintf(&b, " my-id=%s remote-id=%s\n", mikrotikID(p.LocalID), mikrotikID(p.RemoteID))
+
+	b.WriteString("/ip ipsec proposal\n")
+	for i := range p.Children {
+		c := &p.Children[i]
+		esp := &c.Proposals[0]
+		pfs := "none"
+		if c.PFS() != "" {
+			pfs = c.PFS()
+		}
+		auth := "null"
+		if len(esp.Integrity) > 0 {
+			auth = strings.Join(esp.Integrity, ",")
+		}
+		fmt.Fprintf(&b, "add name=%s-%s auth-algorithms=%s enc-algorithms=%s pfs-group=%s lifetime=%s\n",
+			name, c.Name, auth, mapJoinComma(esp.Encryption, mikrotikESPCipher), pfs, rosDuration(c.Lifetime))
+	}
+
+	b.WriteString("/ip ipsec policy\n")
+	for i := range p.Children {
+		c := &p.Children[i]
+		for _, local := range c.LocalTS {
+			for _, remote := range c.RemoteTS {
+				if local.Addr().Is4() != remote.Addr().Is4() {
+					continue
+				}
+				fmt.Fprintf(&b, "add peer=%s tunnel=yes src-address=%s dst-address=%s proposal=%s-%s\n",
+					name, local, remote, name, c.Name)
+			}
+		}
+	}
+	return b.String()
+}
+
+func mapJoinComma(algs []string, f func(string) string) string {
+	return strings.ReplaceAll(mapJoin(algs, f), " ", ",")
+}
+
+func mikrotikIKECipher(alg string) string {
+	if aesMode(alg) != "" {
+		name := fmt.Sprintf("aes-%d", keyLength(alg))
+		if aesMode(alg) != "cbc" {
+			name += "-" + aesMode(alg)
+		}
+		return name
+	}
+	return alg
+}
+
+func mikrotikESPCipher(alg string) string {
+	if aesMode(alg) != "" {
+		return fmt.Sprintf("aes-%d-%s", keyLength(alg), aesMode(alg))
+	}
+	return alg
+}
+
+func mikrotikHash(prop *Proposal) string {
+	switch {
+	case len(prop.PRF) > 0:
+		return prop.PRF[0]
+	case len(prop.Integrity) > 0:
+		return prop.Integrity[0]
+	}
+	return "sha256"
+}
+
+func mikrotikID(id string) string {
+	switch IDType(id) {
+	case "address":
+		return "address:" + id
+	case "email":
+		return "user-fqdn:" + id
+	case "dn":
+		return "auto"
+	}
+	return "fqdn:" + IDValue(id)
+}
+
+// rosDuration formats seconds the way RouterOS prints durations.
+func rosDuration(seconds int) string {
+	switch {
+	case seconds%3600 == 0:
+		return fmt.Sprintf("%dh", seconds/3600)
+	case seconds%60 == 0:
+		return fmt.Sprintf("%dm", seconds/60)
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
@@ -0,0 +1,244 @@
+// Package peerconfig renders the partner's side of a site-to-site
+// connection for common IPsec devices, so onboarding a partner does not
+// mean translating swanctl.conf by hand.
+package peerconfig
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const (
+	// Placeholders for what the partner has to fill in.
+	placeholderAddress = "<TAILSWAN_PUBLIC_IP>"
+	placeholderPartner = "<PARTNER_PUBLIC_IP>"
+	placeholderPSK     = "<PRE_SHARED_KEY>"
+	placeholderCert    = "<PARTNER_CERT>"
+
+	defaultIKELifetime   = 14400
+	defaultChildLifetime = 3600
+)
+
+// Peer is a connection seen from the partner: its local side is
+// TailSwan's remote side and the other way around.
+type Peer struct {
+	Name string
+	// LocalAddr and LocalID are the partner's gateway, RemoteAddr and
+	// RemoteID TailSwan's.
+	LocalAddr  string
+	LocalID    string
+	RemoteAddr string
+	RemoteID   string
+	// LocalAuth is how the partner authenticates, RemoteAuth how TailSwan
+	// does: "psk" or "pubkey".
+	LocalAuth  string
+	RemoteAuth string
+	Proposals  []Proposal
+	Children   []Child
+	// Notes lists what could not be exported and has to be checked.
+	Notes    []string
+	Version  int
+	Lifetime int
+	DPDDelay int
+}
+
+type Child struct {
+	Name      string
+	LocalTS   []netip.Prefix
+	RemoteTS  []netip.Prefix
+	Proposals []Proposal
+	Lifetime  int
+}
+
+// PFS returns the child's first Diffie-Hellman group, or "" without PFS.
+func (c *Child) PFS() string {
+	if len(c.Proposals) > 0 && len(c.Proposals[0].DH) > 0 {
+		return c.Proposals[0].DH[0]
+	}
+	return ""
+}
+
+// Build derives the partner's side of conn. charon does not report
+// proposals, so they are read from the connection's definition in file,
+// which may be nil. address is TailSwan's public address when the
+// connection does not pin one.
+func Build(conn *viciconn.Conn, file *swanconf.Section, address string) (*Peer, error) {
+	p := &Peer{
+		Name:     conn.Name,
+		Version:  2,
+		Lifetime: conn.RekeyTime,
+		DPDDelay: conn.DPDDelay,
+	}
+	if conn.Version == "IKEv1" {
+		p.Version = 1
+	} else if conn.Version != "IKEv2" {
+		p.note("the connection accepts any IKE version, IKEv2 is assumed")
+	}
+	if p.Lifetime == 0 {
+		p.Lifetime = defaultIKELifetime
+	}
+
+	p.RemoteAddr = address
+	if p.RemoteAddr == "" {
+		p.RemoteAddr = specificAddr(conn.LocalAddrs)
+	}
+	if p.RemoteAddr == "" {
+		p.RemoteAddr = placeholderAddress
+		p.note("TailSwan's public address is unknown, replace " + placeholderAddress)
+	}
+	p.LocalAddr = specificAddr(conn.RemoteAddrs)
+	if p.LocalAddr == "" {
+		p.LocalAddr = placeholderPartner
+		p.note("the connection accepts any partner address, replace " + placeholderPartner)
+	}
+
+	var local, remote viciconn.Auth
+	if len(conn.Local) > 0 {
+		remote = conn.Local[0]
+	}
+	if len(conn.Remote) > 0 {
+		local = conn.Remote[0]
+	}
+	if len(conn.Local) > 1 || len(conn.Remote) > 1 {
+		p.note("only the first authentication round is exported")
+	}
+	p.RemoteID = identity(remote.ID, p.RemoteAddr)
+	p.LocalID = identity(local.ID, p.LocalAddr)
+	p.RemoteAuth = p.authMethod(remote.Class, "TailSwan")
+	p.LocalAuth = p.authMethod(local.Class, "the partner")
+	if p.LocalAuth == "" {
+		p.LocalAuth = p.RemoteAuth
+	}
+
+	var def *swanconf.Section
+	if file != nil {
+		def = file.Section("connections", conn.Name)
+	}
+	if def == nil {
+		p.note("the connection is not defined in the swanctl configuration, charon's default proposals are assumed")
+	}
+	var err error
+	if p.Proposals, err = p.proposals(def, "proposals", false); err != nil {
+		return nil, err
+	}
+
+	for i := range conn.Children {
+		c := &conn.Children[i]
+		child := Child{
+			Name:     c.Name,
+			LocalTS:  p.selectors(c.RemoteTS, p.LocalAddr),
+			RemoteTS: p.selectors(c.LocalTS, p.RemoteAddr),
+			Lifetime: c.RekeyTime,
+		}
+		if child.Lifetime == 0 {
+			child.Lifetime = defaultChildLifetime
+		}
+		var childDef *swanconf.Section
+		if def != nil {
+			childDef = def.Section("children", c.Name)
+		}
+		if child.Proposals, err = p.proposals(childDef, "esp_proposals", true); err != nil {
+			return nil, fmt.Errorf("child %s: %w", c.Name, err)
+		}
+		p.Children = append(p.Children, child)
+	}
+	if len(p.Children) == 0 {
+		return nil, fmt.Errorf("connection %s has no children", conn.Name)
+	}
+	return p, nil
+}
+
+func (p *Peer) note(s string) {
+	p.Notes = append(p.Notes, s)
+}
+
+func (p *Peer) authMethod(class, who string) string {
+	switch class {
+	case "pre-shared key":
+		return "psk"
+	case "public key":
+		return "pubkey"
+	case "", "any":
+		return ""
+	}
+	p.note(fmt.Sprintf("%s authenticates with %s, which only pre-shared key and certificate exports support", who, class))
+	return ""
+}
+
+func (p *Peer) proposals(def *swanconf.Section, key string, esp bool) ([]Proposal, error) {
+	value := "default"
+	if def != nil && def.Get(key) != "" {
+		value = def.Get(key)
+	}
+	return ParseProposals(value, esp)
+}
+
+// selectors parses traffic selectors, replacing "dynamic" with the
+// gateway's own address.
+func (p *Peer) selectors(ts []string, gateway string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, s := range ts {
+		if s == "dynamic" {
+			if addr, err := netip.ParseAddr(gateway); err == nil {
+				prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+				continue
+			}
+			p.note("a dynamic traffic selector of an unknown address cannot be exported")
+			continue
+		}
+		parsed := viciconn.Prefixes([]string{s})
+		if len(parsed) == 0 {
+			p.note(fmt.Sprintf("traffic selector %s cannot be exported", s))
+			continue
+		}
+		if strings.Contains(s, "[") {
+			p.note(fmt.Sprintf("the protocol/port restriction of traffic selector %s is not exported", s))
+		}
+		prefixes = append(prefixes, parsed...)
+	}
+	return prefixes
+}
+
+func specificAddr(addrs []string) string {
+	for _, a := range addrs {
+		switch a {
+		case "", "%any", "%any4", "%any6", "0.0.0.0", "::", "0.0.0.0/0", "::/0":
+			continue
+		}
+		return a
+	}
+	return ""
+}
+
+func identity(id, addr string) string {
+	if id == "" || id == "%any" {
+		return addr
+	}
+	return id
+}
+
+// IDType classifies an IKE identity the way vendors configure it:
+// "address", "fqdn", "email" or "dn".
+func IDType(id string) string {
+	if _, err := netip.ParseAddr(id); err == nil {
+		return "address"
+	}
+	switch {
+	case strings.HasPrefix(id, "@"):
+		return "fqdn"
+	case strings.Contains(id, "="):
+		return "dn"
+	case strings.Contains(id, "@"):
+		return "email"
+	}
+	return "fqdn"
+}
+
+// IDValue strips strongSwan's @ that keeps an FQDN from being resolved.
+func IDValue(id string) string {
+	return strings.TrimPrefix(id, "@")
+}
@@ -0,0 +1,126 @@
+package peerconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const testSwanctl = `
+connections {
+    partner-a {
+        proposals = aes256-sha256-modp2048, aes256gcm16-prfsha384-ecp384
+        children {
+            net {
+                esp_proposals = aes256gcm16-modp2048
+            }
+        }
+    }
+}
+`
+
+func testConn() *viciconn.Conn {
+	return &viciconn.Conn{
+		Name:        "partner-a",
+		Version:     "IKEv2",
+		LocalAddrs:  []string{"%any"},
+		RemoteAddrs: []string{"203.0.113.10"},
+		Local:       []viciconn.Auth{{Class: "pre-shared key", ID: "@tailswan.example.com"}},
+		Remote:      []viciconn.Auth{{Class: "pre-shared key", ID: "203.0.113.10"}},
+		Children: []viciconn.Child{{
+			Connection: "partner-a",
+			Name:       "net",
+			LocalTS:    []string{"10.1.0.0/24", "100.64.0.0/10"},
+			RemoteTS:   []string{"10.2.0.0/24"},
+			RekeyTime:  3600,
+		}},
+		RekeyTime: 14400,
+		DPDDelay:  30,
+	}
+}
+
+func file(t *testing.T) *swanconf.Section {
+	t.Helper()
+	f, err := swanconf.Parse(strings.NewReader(testSwanctl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func testPeer(t *testing.T) *Peer {
+	t.Helper()
+	p, err := Build(testConn(), file(t), "198.51.100.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestBuild(t *testing.T) {
+	p := testPeer(t)
+
+	if p.LocalAddr != "203.0.113.10" || p.RemoteAddr != "198.51.100.1" {
+		t.Errorf("expected the addresses to be swapped, got %s / %s", p.LocalAddr, p.RemoteAddr)
+	}
+	if p.LocalID != "203.0.113.10" || p.RemoteID != "@tailswan.example.com" {
+		t.Errorf("expected the identities to be swapped, got %s / %s", p.LocalID, p.RemoteID)
+	}
+	if p.LocalAuth != "psk" || p.RemoteAuth != "psk" || p.Version != 2 || p.Lifetime != 14400 {
+		t.Errorf("unexpected peer %+v", p)
+	}
+	if len(p.Proposals) != 2 || p.Proposals[1].String() != "aes256gcm16-prfsha384-ecp384" {
+		t.Errorf("unexpected IKE proposals %+v", p.Proposals)
+	}
+	c := p.Children[0]
+	if c.LocalTS[0].String() != "10.2.0.0/24" || len(c.RemoteTS) != 2 || c.PFS() != "modp2048" {
+		t.Errorf("unexpected child %+v", c)
+	}
+	if len(p.Notes) != 0 {
+		t.Errorf("expected no notes, got %v", p.Notes)
+	}
+
+	// Without the definition or a public address the export guesses.
+	conn := testConn()
+	conn.Children[0].LocalTS = []string{"dynamic"}
+	guess, err := Build(conn, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guess.RemoteAddr != placeholderAddress || guess.Children[0].Proposals[0].String() != defaultESP || len(guess.Notes) != 3 {
+		t.Errorf("expected placeholders and defaults with notes, got %+v", guess)
+	}
+
+	// A protocol/port restriction keeps the subnet but is noted.
+	conn = testConn()
+	conn.Children[0].RemoteTS = []string{"10.2.0.0/24[tcp/443]"}
+	restricted, err := Build(conn, file(t), "198.51.100.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restricted.Children[0].LocalTS) != 1 || len(restricted.Notes) != 1 || !strings.Contains(restricted.Notes[0], "protocol/port") {
+		t.Errorf("expected the restricted selector to be kept with a note, got %+v", restricted)
+	}
+
+	conn.Children = nil
+	if _, err := Build(conn, nil, ""); err == nil {
+		t.Error("expected a connection without children to be rejected")
+	}
+}
+
+func TestParseProposals(t *testing.T) {
+	got, err := ParseProposals("aes128-aes256-sha1-sha256-modp2048-ecp256, aes256gcm16-prfsha384-x25519!", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || len(got[0].Encryption) != 2 || len(got[0].DH) != 2 || got[1].DH[0] != "curve25519" || !got[1].AEAD() {
+		t.Errorf("unexpected proposals %+v", got)
+	}
+	for _, bad := range []string{"aes256-modp2048", "sha256-modp2048", "aes256-sha256-foo"} {
+		if _, err := ParseProposals(bad, false); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
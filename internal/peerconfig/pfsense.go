@@ -0,0 +1,225 @@
+package peerconfig
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pfSense stores IPsec tunnels in config.xml under <ipsec>; the XML below
+// can be merged there or restored through Diagnostics > Backup & Restore
+// with the IPsec area selected.
+type pfSenseIPsec struct {
+	XMLName xml.Name        `xml:"ipsec"`
+	Phase1  []pfSensePhase1 `xml:"phase1"`
+	Phase2  []pfSensePhase2 `xml:"phase2"`
+}
+
+type pfSensePhase1 struct {
+	IKEID         string              `xml:"ikeid"`
+	IKEType       string              `xml:"iketype"`
+	Interface     string              `xml:"interface"`
+	RemoteGateway string              `xml:"remote-gateway"`
+	Protocol      string              `xml:"protocol"`
+	MyIDType      string              `xml:"myid_type"`
+	MyIDData      string              `xml:"myid_data"`
+	PeerIDType    string              `xml:"peerid_type"`
+	PeerIDData    string              `xml:"peerid_data"`
+	AuthMethod    string              `xml:"authentication_method"`
+	PreSharedKey  string              `xml:"pre-shared-key,omitempty"`
+	CertRef       string              `xml:"certref,omitempty"`
+	CARef         string              `xml:"caref,omitempty"`
+	Descr         string              `xml:"descr"`
+	NATTraversal  string              `xml:"nat_traversal"`
+	MOBIKE        string              `xml:"mobike"`
+	StartAction   string              `xml:"startaction"`
+	CloseAction   string              `xml:"closeaction"`
+	RekeyTime     string              `xml:"rekey_time"`
+	ReauthTime    string              `xml:"reauth_time"`
+	RandTime      string              `xml:"rand_time"`
+	MarginTime    string              `xml:"margintime"`
+	Encryption    []pfSenseEncryption `xml:"encryption>item"`
+	Lifetime      int                 `xml:"lifetime"`
+	DPDDelay      int                 `xml:"dpd_delay,omitempty"`
+	DPDMaxFail    int                 `xml:"dpd_maxfail,omitempty"`
+}
+
+type pfSenseEncryption struct {
+	Algorithm pfSenseAlgorithm `xml:"encryption-algorithm"`
+	Hash      string           `xml:"hash-algorithm"`
+	PRF       string           `xml:"prf-algorithm"`
+	DHGroup   int              `xml:"dhgroup"`
+}
+
+type pfSenseAlgorithm struct {
+	Name   string `xml:"name"`
+	KeyLen string `xml:"keylen"`
+}
+
+type pfSensePhase2 struct {
+	IKEID      string             `xml:"ikeid"`
+	UniqID     string             `xml:"uniqid"`
+	Mode       string             `xml:"mode"`
+	Protocol   string             `xml:"protocol"`
+	Descr      string             `xml:"descr"`
+	LocalID    pfSenseNetwork     `xml:"localid"`
+	RemoteID   pfSenseNetwork     `xml:"remoteid"`
+	Encryption []pfSenseAlgorithm `xml:"encryption-algorithm-option"`
+	Hash       []string           `xml:"hash-algorithm-option"`
+	ReqID      int                `xml:"reqid"`
+	PFSGroup   int                `xml:"pfsgroup"`
+	Lifetime   int                `xml:"lifetime"`
+}
+
+type pfSenseNetwork struct {
+	Type    string `xml:"type"`
+	Address string `xml:"address"`
+	NetBits int    `xml:"netbits"`
+}
+
+func renderPfSense(p *Peer) (string, error) {
+	const ikeID = "1"
+	phase1 := pfSensePhase1{
+		IKEID:         ikeID,
+		IKEType:       fmt.Sprintf("ikev%d", p.Version),
+		Interface:     "wan",
+		RemoteGateway: p.RemoteAddr,
+		Protocol:      "inet",
+		MyIDType:      pfSenseIDType(p.LocalID),
+		MyIDData:      IDValue(p.LocalID),
+		PeerIDType:    pfSenseIDType(p.RemoteID),
+		PeerIDData:    IDValue(p.RemoteID),
+		AuthMethod:    "pre_shared_key",
+		Descr:         "TailSwan " + p.Name,
+		Lifetime:      p.Lifetime,
+		NATTraversal:  "on",
+		MOBIKE:        "off",
+		StartAction:   "start",
+		CloseAction:   "restart",
+		RekeyTime:     strconv.Itoa(p.Lifetime * 9 / 10),
+		ReauthTime:    "0",
+		RandTime:      strconv.Itoa(p.Lifetime / 10),
+		MarginTime:    strconv.Itoa(p.Lifetime / 10),
+	}
+	if strings.Contains(p.RemoteAddr, ":") {
+		phase1.Protocol = "inet6"
+	}
+	if swanAuth(p.RemoteAuth) == "pubkey" {
+		phase1.AuthMethod = "cert"
+		phase1.CertRef = "<CERTREF>"
+		phase1.CARef = "<CAREF>"
+	} else {
+		phase1.PreSharedKey = placeholderPSK
+	}
+	if p.DPDDelay > 0 {
+		phase1.DPDDelay = p.DPDDelay
+		phase1.DPDMaxFail = 5
+	}
+	for i := range p.Proposals {
+		prop := &p.Proposals[i]
+		for _, enc := range prop.Encryption {
+			hash := "sha256"
+			if len(prop.Integrity) > 0 {
+				hash = prop.Integrity[0]
+			}
+			prf := hash
+			if len(prop.PRF) > 0 {
+				prf = prop.PRF[0]
+			}
+			for _, dh := range prop.DH {
+				phase1.Encryption = append(phase1.Encryption, pfSenseEncryption{
+					Algorithm: pfSenseCipher(enc),
+					Hash:      hash,
+					PRF:       prf,
+					DHGroup:   DHGroup(dh),
+				})
+			}
+		}
+	}
+
+	doc := pfSenseIPsec{Phase1: []pfSensePhase1{phase1}}
+	reqID := 1
+	for i := range p.Children {
+		c := &p.Children[i]
+		n := 0
+		for _, local := range c.LocalTS {
+			for _, remote := range c.RemoteTS {
+				if local.Addr().Is4() != remote.Addr().Is4() {
+					continue
+				}
+				n++
+				phase2 := pfSensePhase2{
+					IKEID:    ikeID,
+					UniqID:   fmt.Sprintf("tailswan%d", reqID),
+					Mode:     "tunnel",
+					ReqID:    reqID,
+					LocalID:  pfSenseNetwork{Type: "network", Address: local.Addr().String(), NetBits: local.Bits()},
+					RemoteID: pfSenseNetwork{Type: "network", Address: remote.Addr().String(), NetBits: remote.Bits()},
+					Protocol: "esp",
+					Lifetime: c.Lifetime,
+					Descr:    fmt.Sprintf("TailSwan %s %s %d", p.Name, c.Name, n),
+				}
+				if local.Addr().Is6() {
+					phase2.Mode = "tunnel6"
+				}
+				if c.PFS() != "" {
+					phase2.PFSGroup = DHGroup(c.PFS())
+				}
+				for j := range c.Proposals {
+					prop := &c.Proposals[j]
+					for _, enc := range prop.Encryption {
+						phase2.Encryption = append(phase2.Encryption, pfSenseCipher(enc))
+					}
+					for _, integ := range prop.Integrity {
+						phase2.Hash = appendUnique(phase2.Hash, "hmac_"+integ)
+					}
+				}
+				doc.Phase2 = append(doc.Phase2, phase2)
+				reqID++
+			}
+		}
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString("<!-- Partner side of TailSwan connection " + xmlComment(p.Name) + " -->\n")
+	for _, n := range p.Notes {
+		b.WriteString("<!-- NOTE: " + xmlComment(n) + " -->\n")
+	}
+	b.WriteString("<!-- Renumber ikeid, reqid and uniqid if they clash with existing tunnels -->\n")
+	b.Write(out)
+	b.WriteString("\n")
+	return b.String(), nil
+}
+
+func xmlComment(s string) string {
+	return strings.ReplaceAll(s, "--", "- -")
+}
+
+func pfSenseCipher(alg string) pfSenseAlgorithm {
+	switch aesMode(alg) {
+	case "gcm":
+		// pfSense names AES-GCM by its ICV length.
+		return pfSenseAlgorithm{Name: fmt.Sprintf("aes%dgcm", icvBits(alg)), KeyLen: strconv.Itoa(keyLength(alg))}
+	case "cbc":
+		return pfSenseAlgorithm{Name: "aes", KeyLen: strconv.Itoa(keyLength(alg))}
+	}
+	return pfSenseAlgorithm{Name: alg}
+}
+
+func pfSenseIDType(id string) string {
+	switch IDType(id) {
+	case "address":
+		return "address"
+	case "email":
+		return "user_fqdn"
+	case "dn":
+		return "asn1dn"
+	}
+	return "fqdn"
+}
@@ -0,0 +1,221 @@
+package peerconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Proposal is one strongSwan proposal such as aes256-sha256-modp2048,
+// split into its transforms. Each list holds alternatives.
+type Proposal struct {
+	Encryption []string
+	Integrity  []string
+	PRF        []string
+	DH         []string
+}
+
+// AEAD reports whether the proposal's ciphers authenticate themselves,
+// so it needs no integrity algorithm.
+func (p *Proposal) AEAD() bool {
+	return len(p.Encryption) > 0 && isAEAD(p.Encryption[0])
+}
+
+func (p *Proposal) String() string {
+	parts := make([]string, 0, len(p.Encryption)+len(p.Integrity)+len(p.PRF)+len(p.DH))
+	parts = append(parts, p.Encryption...)
+	parts = append(parts, p.Integrity...)
+	for _, prf := range p.PRF {
+		parts = append(parts, "prf"+prf)
+	}
+	parts = append(parts, p.DH...)
+	return strings.Join(parts, "-")
+}
+
+// defaultIKE and defaultESP stand in for charon's default proposals, which
+// are long lists; these are members of them every vendor supports.
+const (
+	defaultIKE = "aes256-sha256-ecp256"
+	defaultESP = "aes256-sha256"
+)
+
+// ParseProposals parses a comma separated list of proposals. "default"
+// stands for charon's defaults.
+func ParseProposals(s string, esp bool) ([]Proposal, error) {
+	var proposals []Proposal
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(entry), "!"))
+		if entry == "" {
+			continue
+		}
+		if entry == "default" {
+			entry = defaultIKE
+			if esp {
+				entry = defaultESP
+			}
+		}
+		p, err := parseProposal(entry)
+		if err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, p)
+	}
+	return proposals, nil
+}
+
+func parseProposal(s string) (Proposal, error) {
+	var p Proposal
+	for _, alg := range strings.Split(s, "-") {
+		alg = normalize(alg)
+		switch {
+		case alg == "esn" || alg == "noesn":
+		case isEncryption(alg):
+			p.Encryption = append(p.Encryption, alg)
+		case strings.HasPrefix(alg, "prf") && isIntegrity(strings.TrimPrefix(alg, "prf")):
+			p.PRF = append(p.PRF, strings.TrimPrefix(alg, "prf"))
+		case isIntegrity(alg):
+			p.Integrity = append(p.Integrity, alg)
+		case DHGroup(alg) != 0:
+			p.DH = append(p.DH, alg)
+		default:
+			return Proposal{}, fmt.Errorf("unsupported algorithm %q in proposal %q", alg, s)
+		}
+	}
+	if len(p.Encryption) == 0 {
+		return Proposal{}, fmt.Errorf("proposal %q has no encryption algorithm", s)
+	}
+	if !p.AEAD() && len(p.Integrity) == 0 {
+		return Proposal{}, fmt.Errorf("proposal %q has no integrity algorithm", s)
+	}
+	return p, nil
+}
+
+// normalize maps strongSwan's aliases to one name per algorithm.
+func normalize(alg string) string {
+	switch alg {
+	case "aes":
+		return "aes128"
+	case "aes128gcm", "aes128gcm128":
+		return "aes128gcm16"
+	case "aes192gcm", "aes192gcm128":
+		return "aes192gcm16"
+	case "aes256gcm", "aes256gcm128":
+		return "aes256gcm16"
+	case "sha", "sha1_160":
+		return "sha1"
+	case "sha2_256", "sha256_128":
+		return "sha256"
+	case "sha2_384":
+		return "sha384"
+	case "sha2_512":
+		return "sha512"
+	case "x25519":
+		return "curve25519"
+	case "x448":
+		return "curve448"
+	}
+	return alg
+}
+
+var keyLengths = map[string]int{
+	"aes128": 128, "aes192": 192, "aes256": 256,
+	"aes128gcm16": 128, "aes192gcm16": 192, "aes256gcm16": 256,
+	"aes128gcm12": 128, "aes256gcm12": 256,
+	"aes128gcm8": 128, "aes256gcm8": 256,
+	"aes128ctr": 128, "aes256ctr": 256,
+	"3des": 168, "chacha20poly1305": 256,
+}
+
+func isEncryption(alg string) bool {
+	_, ok := keyLengths[alg]
+	return ok
+}
+
+func isAEAD(alg string) bool {
+	return strings.Contains(alg, "gcm") || alg == "chacha20poly1305"
+}
+
+// keyLength returns the key size in bits of an encryption algorithm.
+func keyLength(alg string) int {
+	return keyLengths[alg]
+}
+
+func isIntegrity(alg string) bool {
+	switch alg {
+	case "md5", "sha1", "sha256", "sha384", "sha512", "aesxcbc":
+		return true
+	}
+	return false
+}
+
+var dhGroups = map[string]int{
+	"modp768": 1, "modp1024": 2, "modp1536": 5, "modp2048": 14,
+	"modp3072": 15, "modp4096": 16, "modp6144": 17, "modp8192": 18,
+	"ecp256": 19, "ecp384": 20, "ecp521": 21,
+	"modp1024s160": 22, "modp2048s224": 23, "modp2048s256": 24,
+	"ecp192": 25, "ecp224": 26, "ecp224bp": 27, "ecp256bp": 28,
+	"ecp384bp": 29, "ecp512bp": 30, "curve25519": 31, "curve448": 32,
+}
+
+// DHGroup returns the IANA number of a Diffie-Hellman group, or 0.
+func DHGroup(alg string) int {
+	return dhGroups[alg]
+}
+
+func dhNumbers(groups []string) []string {
+	numbers := make([]string, 0, len(groups))
+	for _, g := range groups {
+		numbers = append(numbers, strconv.Itoa(DHGroup(g)))
+	}
+	return numbers
+}
+
+// aesMode returns "cbc", "gcm" or "ctr" for AES ciphers and "" otherwise.
+func aesMode(alg string) string {
+	switch {
+	case !strings.HasPrefix(alg, "aes"):
+		return ""
+	case strings.Contains(alg, "gcm"):
+		return "gcm"
+	case strings.HasSuffix(alg, "ctr"):
+		return "ctr"
+	}
+	return "cbc"
+}
+
+// displayName is the vendor neutral name used in the configuration sheet.
+func displayName(alg string) string {
+	switch {
+	case aesMode(alg) != "":
+		name := fmt.Sprintf("AES-%d-%s", keyLength(alg), strings.ToUpper(aesMode(alg)))
+		if aesMode(alg) == "gcm" {
+			name += fmt.Sprintf(" (%d-bit ICV)", icvBits(alg))
+		}
+		return name
+	case alg == "3des":
+		return "3DES"
+	case alg == "chacha20poly1305":
+		return "ChaCha20-Poly1305"
+	case alg == "md5":
+		return "MD5"
+	case alg == "sha1":
+		return "SHA-1"
+	case alg == "aesxcbc":
+		return "AES-XCBC"
+	case strings.HasPrefix(alg, "sha"):
+		return "SHA2-" + strings.TrimPrefix(alg, "sha")
+	case DHGroup(alg) != 0:
+		return fmt.Sprintf("DH group %d (%s)", DHGroup(alg), alg)
+	}
+	return alg
+}
+
+func icvBits(alg string) int {
+	switch {
+	case strings.HasSuffix(alg, "gcm8"):
+		return 64
+	case strings.HasSuffix(alg, "gcm12"):
+		return 96
+	}
+	return 128
+}
@@ -0,0 +1,272 @@
+package peerconfig
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+)
+
+const (
+	FormatSwanctl  = "swanctl"
+	FormatIPsec    = "ipsec.conf"
+	FormatCisco    = "cisco"
+	FormatFortinet = "fortinet"
+	FormatPfSense  = "pfsense"
+	FormatMikroTik = "mikrotik"
+	FormatSheet    = "sheet"
+)
+
+// Formats lists the supported output formats.
+func Formats() []string {
+	return []string{FormatSwanctl, FormatIPsec, FormatCisco, FormatFortinet, FormatPfSense, FormatMikroTik, FormatSheet}
+}
+
+// ContentType returns the MIME type of an output format.
+func ContentType(format string) string {
+	if format == FormatPfSense {
+		return "application/xml"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// FileName is the name an export of conn is saved under.
+func FileName(conn, format string) string {
+	switch format {
+	case FormatSwanctl:
+		return conn + "-swanctl.conf"
+	case FormatIPsec:
+		return conn + "-ipsec.conf"
+	case FormatPfSense:
+		return conn + "-pfsense.xml"
+	}
+	return conn + "-" + format + ".txt"
+}
+
+// Render renders the partner's configuration in format.
+func Render(p *Peer, format string) (string, error) {
+	switch format {
+	case FormatSwanctl:
+		return renderSwanctl(p), nil
+	case FormatIPsec:
+		return renderIPsecConf(p), nil
+	case FormatCisco:
+		return renderCisco(p), nil
+	case FormatFortinet:
+		return renderFortinet(p), nil
+	case FormatPfSense:
+		return renderPfSense(p)
+	case FormatMikroTik:
+		return renderMikroTik(p), nil
+	case FormatSheet:
+		return renderSheet(p), nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(Formats(), ", "))
+}
+
+// header is a comment block naming the connection and the notes.
+func header(p *Peer, comment string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s Partner side of TailSwan connection %s\n", comment, p.Name)
+	for _, n := range p.Notes {
+		fmt.Fprintf(&b, "%s NOTE: %s\n", comment, n)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func proposalList(proposals []Proposal) string {
+	list := make([]string, 0, len(proposals))
+	for i := range proposals {
+		list = append(list, proposals[i].String())
+	}
+	return strings.Join(list, ",")
+}
+
+func prefixList(prefixes []netip.Prefix, sep string) string {
+	list := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		list = append(list, p.String())
+	}
+	return strings.Join(list, sep)
+}
+
+func swanAuth(method string) string {
+	if method == "" {
+		return "psk"
+	}
+	return method
+}
+
+func renderSwanctl(p *Peer) string {
+	root := &swanconf.Section{}
+	conn := root.Ensure("connections").Ensure("tailswan-" + p.Name)
+	conn.Set("version", strconv.Itoa(p.Version))
+	conn.Set("local_addrs", p.LocalAddr)
+	conn.Set("remote_addrs", p.RemoteAddr)
+	conn.Set("proposals", proposalList(p.Proposals))
+	conn.Set("rekey_time", strconv.Itoa(p.Lifetime)+"s")
+	if p.DPDDelay > 0 {
+		conn.Set("dpd_delay", strconv.Itoa(p.DPDDelay)+"s")
+	}
+	local := conn.Ensure("local")
+	local.Set("auth", swanAuth(p.LocalAuth))
+	local.Set("id", p.LocalID)
+	if swanAuth(p.LocalAuth) == "pubkey" {
+		local.Set("certs", placeholderCert+".pem")
+	}
+	remote := conn.Ensure("remote")
+	remote.Set("auth", swanAuth(p.RemoteAuth))
+	remote.Set("id", p.RemoteID)
+	for i := range p.Children {
+		c := &p.Children[i]
+		child := conn.Ensure("children").Ensure(c.Name)
+		child.Set("local_ts", prefixList(c.LocalTS, ","))
+		child.Set("remote_ts", prefixList(c.RemoteTS, ","))
+		child.Set("esp_proposals", proposalList(c.Proposals))
+		child.Set("rekey_time", strconv.Itoa(c.Lifetime)+"s")
+		child.Set("dpd_action", "restart")
+		child.Set("start_action", "trap")
+	}
+	if swanAuth(p.RemoteAuth) == "psk" {
+		secret := root.Ensure("secrets").Ensure("ike-tailswan-" + p.Name)
+		secret.Set("id-1", p.LocalID)
+		secret.Set("id-2", p.RemoteID)
+		secret.Set("secret", placeholderPSK)
+	}
+	return header(p, "#") + root.String()
+}
+
+func ipsecLifetime(seconds int) string {
+	return strconv.Itoa(seconds) + "s"
+}
+
+func renderIPsecConf(p *Peer) string {
+	var b strings.Builder
+	b.WriteString(header(p, "#"))
+	base := "tailswan-" + p.Name
+	keyexchange := "ikev2"
+	if p.Version == 1 {
+		keyexchange = "ikev1"
+	}
+
+	fmt.Fprintf(&b, "conn %s\n", base)
+	fmt.Fprintf(&b, "    keyexchange=%s\n", keyexchange)
+	fmt.Fprintf(&b, "    left=%s\n", p.LocalAddr)
+	fmt.Fprintf(&b, "    leftid=%s\n", quoteIPsec(p.LocalID))
+	fmt.Fprintf(&b, "    leftauth=%s\n", swanAuth(p.LocalAuth))
+	fmt.Fprintf(&b, "    right=%s\n", p.RemoteAddr)
+	fmt.Fprintf(&b, "    rightid=%s\n", quoteIPsec(p.RemoteID))
+	fmt.Fprintf(&b, "    rightauth=%s\n", swanAuth(p.RemoteAuth))
+	fmt.Fprintf(&b, "    ike=%s!\n", proposalList(p.Proposals))
+	fmt.Fprintf(&b, "    ikelifetime=%s\n", ipsecLifetime(p.Lifetime))
+	if p.DPDDelay > 0 {
+		fmt.Fprintf(&b, "    dpddelay=%ds\n", p.DPDDelay)
+		b.WriteString("    dpdaction=restart\n")
+	}
+	b.WriteString("    auto=ignore\n")
+
+	for i := range p.Children {
+		c := &p.Children[i]
+		fmt.Fprintf(&b, "\nconn %s-%s\n", base, c.Name)
+		fmt.Fprintf(&b, "    also=%s\n", base)
+		fmt.Fprintf(&b, "    leftsubnet=%s\n", prefixList(c.LocalTS, ","))
+		fmt.Fprintf(&b, "    rightsubnet=%s\n", prefixList(c.RemoteTS, ","))
+		fmt.Fprintf(&b, "    esp=%s!\n", proposalList(c.Proposals))
+		fmt.Fprintf(&b, "    lifetime=%s\n", ipsecLifetime(c.Lifetime))
+		b.WriteString("    auto=route\n")
+	}
+
+	if swanAuth(p.RemoteAuth) == "psk" {
+		b.WriteString("\n# ipsec.secrets\n")
+		fmt.Fprintf(&b, "# %s %s : PSK \"%s\"\n", quoteIPsec(p.LocalID), quoteIPsec(p.RemoteID), placeholderPSK)
+	}
+	return b.String()
+}
+
+func quoteIPsec(id string) string {
+	if strings.ContainsAny(id, " ,=") {
+		return "\"" + id + "\""
+	}
+	return id
+}
+
+func renderSheet(p *Peer) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "IPsec configuration for TailSwan connection %s\n\n", p.Name)
+
+	field := func(label, value string) {
+		fmt.Fprintf(&b, "  %-24s %s\n", label+":", value)
+	}
+	b.WriteString("Gateways\n")
+	field("IKE version", fmt.Sprintf("IKEv%d", p.Version))
+	field("Your gateway", p.LocalAddr)
+	field("Your identity", fmt.Sprintf("%s (%s)", IDValue(p.LocalID), IDType(p.LocalID)))
+	field("TailSwan gateway", p.RemoteAddr)
+	field("TailSwan identity", fmt.Sprintf("%s (%s)", IDValue(p.RemoteID), IDType(p.RemoteID)))
+	field("Authentication", sheetAuth(p.RemoteAuth))
+
+	b.WriteString("\nPhase 1 (IKE)\n")
+	for i := range p.Proposals {
+		field(fmt.Sprintf("Proposal %d", i+1), sheetProposal(&p.Proposals[i], true))
+	}
+	field("Lifetime", fmt.Sprintf("%d seconds", p.Lifetime))
+	if p.DPDDelay > 0 {
+		field("Dead peer detection", fmt.Sprintf("every %d seconds", p.DPDDelay))
+	}
+
+	for i := range p.Children {
+		c := &p.Children[i]
+		fmt.Fprintf(&b, "\nPhase 2 (ESP) %s\n", c.Name)
+		field("Your networks", prefixList(c.LocalTS, ", "))
+		field("TailSwan networks", prefixList(c.RemoteTS, ", "))
+		for j := range c.Proposals {
+			field(fmt.Sprintf("Proposal %d", j+1), sheetProposal(&c.Proposals[j], false))
+		}
+		pfs := "off"
+		if c.PFS() != "" {
+			pfs = displayName(c.PFS())
+		}
+		field("PFS", pfs)
+		field("Lifetime", fmt.Sprintf("%d seconds", c.Lifetime))
+	}
+
+	if len(p.Notes) > 0 {
+		b.WriteString("\nNotes\n")
+		for _, n := range p.Notes {
+			fmt.Fprintf(&b, "  - %s\n", n)
+		}
+	}
+	return b.String()
+}
+
+func sheetAuth(method string) string {
+	if method == "pubkey" {
+		return "certificates"
+	}
+	return "pre-shared key, exchanged separately"
+}
+
+func sheetProposal(prop *Proposal, ike bool) string {
+	var parts []string
+	names := func(algs []string) string {
+		list := make([]string, 0, len(algs))
+		for _, a := range algs {
+			list = append(list, displayName(a))
+		}
+		return strings.Join(list, " or ")
+	}
+	parts = append(parts, names(prop.Encryption))
+	if len(prop.Integrity) > 0 {
+		parts = append(parts, names(prop.Integrity))
+	}
+	if len(prop.PRF) > 0 {
+		parts = append(parts, "PRF "+names(prop.PRF))
+	}
+	if ike && len(prop.DH) > 0 {
+		parts = append(parts, names(prop.DH))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,124 @@
+package peerconfig
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+)
+
+func TestRender(t *testing.T) {
+	p := testPeer(t)
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{FormatSwanctl, []string{
+			"tailswan-partner-a {",
+			"local_addrs = 203.0.113.10",
+			"remote_addrs = 198.51.100.1",
+			"proposals = aes256-sha256-modp2048,aes256gcm16-prfsha384-ecp384",
+			"id = @tailswan.example.com",
+			"local_ts = 10.2.0.0/24",
+			"remote_ts = 10.1.0.0/24,100.64.0.0/10",
+			"esp_proposals = aes256gcm16-modp2048",
+			"secret = " + placeholderPSK,
+		}},
+		{FormatIPsec, []string{
+			"conn tailswan-partner-a\n",
+			"    rightid=@tailswan.example.com\n",
+			"    ike=aes256-sha256-modp2048,aes256gcm16-prfsha384-ecp384!\n",
+			"conn tailswan-partner-a-net\n    also=tailswan-partner-a\n",
+			"    rightsubnet=10.1.0.0/24,100.64.0.0/10\n",
+			`# 203.0.113.10 @tailswan.example.com : PSK "` + placeholderPSK + `"`,
+		}},
+		{FormatCisco, []string{
+			"crypto ikev2 proposal tailswan-partner-a-1\n encryption aes-cbc-256\n integrity sha256\n group 14\n",
+			"crypto ikev2 proposal tailswan-partner-a-2\n encryption aes-gcm-256\n prf sha384\n group 20\n",
+			" match identity remote fqdn tailswan.example.com\n",
+			" identity local address 203.0.113.10\n",
+			"crypto ipsec transform-set tailswan-partner-a-net esp-gcm 256\n",
+			" permit ip 10.2.0.0 0.0.0.255 100.64.0.0 0.63.255.255\n",
+			" set pfs group14\n",
+			" lifetime 14400\n dpd 30 5 on-demand\n",
+		}},
+		{FormatFortinet, []string{
+			`set peerid "tailswan.example.com"`,
+			"set proposal aes256-sha256 aes256gcm-prfsha384\n",
+			"set dhgrp 14 20\n",
+			"set remote-gw 198.51.100.1\n",
+			`edit "tailswan-partner-a-net-2"`,
+			"set proposal aes256gcm\n",
+			"set src-subnet 10.2.0.0 255.255.255.0\n        set dst-subnet 100.64.0.0 255.192.0.0\n",
+		}},
+		{FormatPfSense, []string{
+			"<remote-gateway>198.51.100.1</remote-gateway>",
+			"<peerid_type>fqdn</peerid_type>",
+			"<peerid_data>tailswan.example.com</peerid_data>",
+			"<name>aes128gcm</name>",
+			"<keylen>256</keylen>",
+			"<netbits>10</netbits>",
+			"<pfsgroup>14</pfsgroup>",
+		}},
+		{FormatMikroTik, []string{
+			"add name=tailswan-partner-a enc-algorithm=aes-256 hash-algorithm=sha256 dh-group=modp2048 lifetime=4h dpd-interval=30s\n",
+			"add name=tailswan-partner-a address=198.51.100.1 exchange-mode=ike2 profile=tailswan-partner-a\n",
+			"my-id=address:203.0.113.10 remote-id=fqdn:tailswan.example.com\n",
+			"auth-algorithms=null enc-algorithms=aes-256-gcm pfs-group=modp2048 lifetime=1h\n",
+			"src-address=10.2.0.0/24 dst-address=100.64.0.0/10 proposal=tailswan-partner-a-net\n",
+		}},
+		{FormatSheet, []string{
+			"  TailSwan identity:       tailswan.example.com (fqdn)\n",
+			"  Proposal 1:              AES-256-CBC, SHA2-256, DH group 14 (modp2048)\n",
+			"  Proposal 2:              AES-256-GCM (128-bit ICV), PRF SHA2-384, DH group 20 (ecp384)\n",
+			"  PFS:                     DH group 14 (modp2048)\n",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			out, err := Render(p, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("expected output to contain %q, got:\n%s", want, out)
+				}
+			}
+		})
+	}
+
+	if _, err := Render(p, "juniper"); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+func TestRender_Parses(t *testing.T) {
+	p := testPeer(t)
+
+	out, err := Render(p, FormatSwanctl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := swanconf.Parse(strings.NewReader(out))
+	if err != nil {
+		t.Fatalf("the swanctl export does not parse: %v", err)
+	}
+	if parsed.Section("connections", "tailswan-partner-a", "children", "net") == nil {
+		t.Error("expected the child in the swanctl export")
+	}
+
+	out, err = Render(p, FormatPfSense)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc pfSenseIPsec
+	if err := xml.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("the pfSense export is not valid XML: %v", err)
+	}
+	if len(doc.Phase1) != 1 || len(doc.Phase2) != 2 || len(doc.Phase1[0].Encryption) != 2 {
+		t.Errorf("unexpected pfSense export %+v", doc)
+	}
+}
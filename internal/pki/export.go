@@ -0,0 +1,99 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/klowdo/tailswan/internal/certs"
+)
+
+const (
+	// FormatPKCS12 bundles the key, the certificate and the CA certificate
+	// for road-warrior clients to import.
+	FormatPKCS12 = "p12"
+	// FormatPEM concatenates them for partner gateways.
+	FormatPEM = "pem"
+
+	// CAName and CRLName are the file names the CA certificate and its CRL
+	// are installed as in the swanctl directory.
+	CAName  = "tailswan-ca.pem"
+	CRLName = "tailswan-ca.crl"
+)
+
+// Export bundles the certificate issued for name with its key and the CA
+// certificate. A PKCS#12 bundle needs a password; legacy selects the
+// 3DES encryption older Android and macOS versions require.
+func (ca *CA) Export(name, format, password string, legacy bool) ([]byte, error) {
+	cert, key, err := ca.issued(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatPKCS12:
+		if password == "" {
+			return nil, fmt.Errorf("%w: a PKCS#12 bundle needs a password", ErrInvalid)
+		}
+		enc := pkcs12.Modern
+		if legacy {
+			enc = pkcs12.Legacy
+		}
+		return enc.Encode(key, cert, []*x509.Certificate{ca.cert}, password)
+	case FormatPEM:
+		keyPEM, err := encodeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		buf.Write(encodeCert(cert.Raw))
+		buf.Write(ca.CertificatePEM())
+		buf.Write(keyPEM)
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("%w: unknown format %q, expected %s or %s", ErrInvalid, format, FormatPKCS12, FormatPEM)
+}
+
+// Install stores the CA certificate and its CRL in the swanctl directory,
+// so charon trusts the issued certificates and rejects the revoked ones.
+// It returns what it stored for the caller to load into charon.
+func (ca *CA) Install(store *certs.Store) ([]certs.Credential, error) {
+	crl, err := ca.CRL()
+	if err != nil {
+		return nil, err
+	}
+	caCred, err := store.Put(certs.KindCA, CAName, ca.CertificatePEM())
+	if err != nil {
+		return nil, err
+	}
+	crlCred, err := store.Put(certs.KindCRL, CRLName, crl)
+	if err != nil {
+		return nil, err
+	}
+	return []certs.Credential{*caCred, *crlCred}, nil
+}
+
+// InstallIssued stores a certificate issued for this gateway and its key
+// in the swanctl directory, as <name>.pem.
+func (ca *CA) InstallIssued(store *certs.Store, name string) ([]certs.Credential, error) {
+	cert, key, err := ca.issued(name)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	keyCred, err := store.Put(certs.KindKey, name+".pem", keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	certCred, err := store.Put(certs.KindCert, name+".pem", encodeCert(cert.Raw))
+	if err != nil {
+		return nil, errors.Join(err, store.Delete(certs.KindKey, name+".pem"))
+	}
+	return []certs.Credential{*certCred, *keyCred}, nil
+}
@@ -0,0 +1,546 @@
+// Package pki is a minimal internal CA. It issues the certificates IKE
+// peers authenticate with, for other gateways and road-warrior clients,
+// and publishes a CRL of the revoked ones.
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/statefile"
+)
+
+const (
+	ProfileServer = "server"
+	ProfileClient = "client"
+
+	KeyECDSA = "ecdsa"
+	KeyRSA   = "rsa"
+
+	// DefaultCommonName names a CA created on first use.
+	DefaultCommonName = "TailSwan CA"
+	DefaultValidity   = 365 * 24 * time.Hour
+	caValidity        = 10 * 365 * 24 * time.Hour
+	// crlValidity is the CRL's next update. It is reissued once less than
+	// crlRefresh of it remains, so charon never holds a stale one.
+	crlValidity = 30 * 24 * time.Hour
+	crlRefresh  = 7 * 24 * time.Hour
+
+	caCertFile = "ca.crt"
+	caKeyFile  = "ca.key"
+	crlFile    = "crl.pem"
+	indexFile  = "index.json"
+	issuedDir  = "issued"
+)
+
+var (
+	ErrNotFound = errors.New("certificate not found")
+	ErrExists   = errors.New("certificate already issued")
+	ErrRevoked  = errors.New("certificate is revoked")
+	// ErrInvalid wraps errors about a bad issuing request.
+	ErrInvalid = errors.New("invalid request")
+)
+
+// oidIKEIntermediate is the IKE intermediate EKU of RFC 4945, which
+// Windows and macOS clients expect on a gateway's certificate.
+var oidIKEIntermediate = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 8, 2, 2}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]*$`)
+
+// Cert records a certificate the CA issued.
+type Cert struct {
+	NotBefore time.Time  `json:"not_before"`
+	NotAfter  time.Time  `json:"not_after"`
+	Revoked   *time.Time `json:"revoked,omitempty"`
+	Name      string     `json:"name"`
+	Profile   string     `json:"profile"`
+	Serial    string     `json:"serial"`
+	KeyType   string     `json:"key_type"`
+	SANs      []string   `json:"sans,omitempty"`
+}
+
+// Request asks for a certificate. Name is its common name and the file
+// name it is kept as. SANs are DNS names, IP addresses or email
+// addresses; a server certificate without any gets Name as DNS name.
+type Request struct {
+	Name     string        `json:"name"`
+	Profile  string        `json:"profile"`
+	KeyType  string        `json:"key_type,omitempty"`
+	SANs     []string      `json:"sans,omitempty"`
+	Validity time.Duration `json:"-"`
+}
+
+// CA keeps its key, the issued certificates and keys, and the CRL in a
+// directory of the state directory. The control server and the CLI share
+// it.
+type CA struct {
+	key  crypto.Signer
+	cert *x509.Certificate
+	now  func() time.Time
+	dir  string
+}
+
+// Dir is where the CA of the state directory is kept.
+func Dir(stateDir string) string {
+	return filepath.Join(stateDir, "pki")
+}
+
+// Open loads the CA in dir, creating it with commonName on first use.
+func Open(dir, commonName string) (*CA, error) {
+	ca := &CA{dir: dir, now: time.Now}
+	err := statefile.Locked(ca.path(indexFile), func() error {
+		if _, err := os.Stat(ca.path(caCertFile)); errors.Is(err, os.ErrNotExist) {
+			return ca.create(commonName)
+		}
+		return ca.load()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// Exists reports whether dir holds a CA.
+func Exists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, caCertFile))
+	return err == nil
+}
+
+func (ca *CA) path(elem ...string) string {
+	return filepath.Join(append([]string{ca.dir}, elem...)...)
+}
+
+func (ca *CA) create(commonName string) error {
+	if commonName == "" {
+		commonName = DefaultCommonName
+	}
+	key, err := generateKey(KeyECDSA)
+	if err != nil {
+		return err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return err
+	}
+	now := ca.now().UTC()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return fmt.Errorf("create CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return err
+	}
+	if err := statefile.WriteAtomic(ca.path(caKeyFile), keyPEM); err != nil {
+		return err
+	}
+	if err := statefile.WriteAtomic(ca.path(caCertFile), encodeCert(der)); err != nil {
+		return err
+	}
+	ca.key, ca.cert = key, cert
+	return ca.writeCRL(nil)
+}
+
+func (ca *CA) load() error {
+	certPEM, err := os.ReadFile(ca.path(caCertFile))
+	if err != nil {
+		return err
+	}
+	keyPEM, err := os.ReadFile(ca.path(caKeyFile))
+	if err != nil {
+		return err
+	}
+	cert, err := parseCert(certPEM)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", ca.path(caCertFile), err)
+	}
+	key, err := parseKey(keyPEM)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", ca.path(caKeyFile), err)
+	}
+	ca.key, ca.cert = key, cert
+	return nil
+}
+
+// Certificate is the CA certificate.
+func (ca *CA) Certificate() *x509.Certificate {
+	return ca.cert
+}
+
+// CertificatePEM is the CA certificate for peers to trust.
+func (ca *CA) CertificatePEM() []byte {
+	return encodeCert(ca.cert.Raw)
+}
+
+// List returns the issued certificates, newest first.
+func (ca *CA) List() ([]Cert, error) {
+	certs, err := ca.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(certs, func(i, j int) bool { return certs[i].NotBefore.After(certs[j].NotBefore) })
+	return certs, nil
+}
+
+// Get returns the latest certificate issued for name.
+func (ca *CA) Get(name string) (*Cert, error) {
+	certs, err := ca.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	return latest(certs, name)
+}
+
+func latest(certs []Cert, name string) (*Cert, error) {
+	for i := len(certs) - 1; i >= 0; i-- {
+		if certs[i].Name == name {
+			return &certs[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Issue creates a key and a certificate with the EKUs of the profile. A
+// name can only be issued again once its certificate is revoked.
+func (ca *CA) Issue(req Request) (*Cert, error) {
+	tmpl, err := ca.template(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if req.KeyType == "" {
+		req.KeyType = KeyECDSA
+	}
+
+	var issued *Cert
+	err = statefile.Locked(ca.path(indexFile), func() error {
+		certs, err := ca.readIndex()
+		if err != nil {
+			return err
+		}
+		if prev, err := latest(certs, req.Name); err == nil && prev.Revoked == nil {
+			return fmt.Errorf("%w: %s, revoke it first", ErrExists, req.Name)
+		}
+
+		key, err := generateKey(req.KeyType)
+		if err != nil {
+			return err
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, key.Public(), ca.key)
+		if err != nil {
+			return fmt.Errorf("create certificate: %w", err)
+		}
+		keyPEM, err := encodeKey(key)
+		if err != nil {
+			return err
+		}
+		if err := statefile.WriteAtomic(ca.path(issuedDir, req.Name+".key"), keyPEM); err != nil {
+			return err
+		}
+		if err := statefile.WriteAtomic(ca.path(issuedDir, req.Name+".crt"), encodeCert(der)); err != nil {
+			return err
+		}
+
+		issued = &Cert{
+			Name:      req.Name,
+			Profile:   req.Profile,
+			Serial:    tmpl.SerialNumber.Text(16),
+			KeyType:   req.KeyType,
+			SANs:      sans(tmpl),
+			NotBefore: tmpl.NotBefore,
+			NotAfter:  tmpl.NotAfter,
+		}
+		return ca.writeIndex(append(certs, *issued))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+func (ca *CA) template(req Request) (*x509.Certificate, error) {
+	if !namePattern.MatchString(req.Name) {
+		return nil, fmt.Errorf("bad name %q", req.Name)
+	}
+	if req.KeyType != "" && req.KeyType != KeyECDSA && req.KeyType != KeyRSA {
+		return nil, fmt.Errorf("unknown key type %q, expected %s or %s", req.KeyType, KeyECDSA, KeyRSA)
+	}
+	validity := req.Validity
+	if validity == 0 {
+		validity = DefaultValidity
+	}
+	if validity < 0 {
+		return nil, fmt.Errorf("validity %s must be positive", validity)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := ca.now().UTC()
+	notAfter := now.Add(validity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: req.Name},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if req.KeyType == KeyRSA {
+		tmpl.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+
+	switch req.Profile {
+	case ProfileServer:
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		tmpl.UnknownExtKeyUsage = []asn1.ObjectIdentifier{oidIKEIntermediate}
+		if len(req.SANs) == 0 {
+			req.SANs = []string{req.Name}
+		}
+	case ProfileClient:
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	default:
+		return nil, fmt.Errorf("unknown profile %q, expected %s or %s", req.Profile, ProfileServer, ProfileClient)
+	}
+
+	for _, san := range req.SANs {
+		san = strings.TrimSpace(san)
+		switch {
+		case san == "":
+		case net.ParseIP(san) != nil:
+			tmpl.IPAddresses = append(tmpl.IPAddresses, net.ParseIP(san))
+		case strings.Contains(san, "@"):
+			tmpl.EmailAddresses = append(tmpl.EmailAddresses, san)
+		default:
+			tmpl.DNSNames = append(tmpl.DNSNames, san)
+		}
+	}
+	return tmpl, nil
+}
+
+func sans(cert *x509.Certificate) []string {
+	result := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		result = append(result, ip.String())
+	}
+	return append(result, cert.EmailAddresses...)
+}
+
+// Revoke revokes the certificate issued for name and reissues the CRL.
+func (ca *CA) Revoke(name string) (*Cert, error) {
+	var revoked *Cert
+	err := statefile.Locked(ca.path(indexFile), func() error {
+		certs, err := ca.readIndex()
+		if err != nil {
+			return err
+		}
+		c, err := latest(certs, name)
+		if err != nil {
+			return err
+		}
+		if c.Revoked != nil {
+			return fmt.Errorf("%w: %s", ErrRevoked, name)
+		}
+		now := ca.now().UTC()
+		c.Revoked = &now
+		revoked = c
+		if err := ca.writeIndex(certs); err != nil {
+			return err
+		}
+		return ca.writeCRL(certs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
+
+// CRL returns the current CRL.
+func (ca *CA) CRL() ([]byte, error) {
+	return os.ReadFile(ca.path(crlFile))
+}
+
+// RefreshCRL reissues the CRL when it is about to pass its next update. It
+// reports whether it did.
+func (ca *CA) RefreshCRL() (bool, error) {
+	data, err := ca.CRL()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if err == nil {
+		if block, _ := pem.Decode(data); block != nil {
+			if crl, err := x509.ParseRevocationList(block.Bytes); err == nil && ca.now().Add(crlRefresh).Before(crl.NextUpdate) {
+				return false, nil
+			}
+		}
+	}
+	err = statefile.Locked(ca.path(indexFile), func() error {
+		certs, err := ca.readIndex()
+		if err != nil {
+			return err
+		}
+		return ca.writeCRL(certs)
+	})
+	return err == nil, err
+}
+
+func (ca *CA) writeCRL(certs []Cert) error {
+	var entries []x509.RevocationListEntry
+	for _, c := range certs {
+		if c.Revoked == nil {
+			continue
+		}
+		serial, ok := new(big.Int).SetString(c.Serial, 16)
+		if !ok {
+			return fmt.Errorf("bad serial %q of %s", c.Serial, c.Name)
+		}
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: serial, RevocationTime: *c.Revoked})
+	}
+	now := ca.now().UTC()
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificateEntries: entries,
+		// The time orders CRLs even across a CA restored from a backup.
+		Number:     big.NewInt(now.UnixNano()),
+		ThisUpdate: now,
+		NextUpdate: now.Add(crlValidity),
+	}, ca.cert, ca.key)
+	if err != nil {
+		return fmt.Errorf("create CRL: %w", err)
+	}
+	if err := statefile.WriteAtomic(ca.path(crlFile), pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})); err != nil {
+		return err
+	}
+	return os.Chmod(ca.path(crlFile), 0o644)
+}
+
+// issued reads the certificate and key issued for name.
+func (ca *CA) issued(name string) (*x509.Certificate, crypto.Signer, error) {
+	c, err := ca.Get(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.Revoked != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrRevoked, name)
+	}
+	certPEM, err := os.ReadFile(ca.path(issuedDir, name+".crt"))
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(ca.path(issuedDir, name+".key"))
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := parseCert(certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := parseKey(keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func (ca *CA) readIndex() ([]Cert, error) {
+	data, err := os.ReadFile(ca.path(indexFile))
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(data) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var certs []Cert
+	if err := json.Unmarshal(data, &certs); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ca.path(indexFile), err)
+	}
+	return certs, nil
+}
+
+func (ca *CA) writeIndex(certs []Cert) error {
+	data, err := json.MarshalIndent(certs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statefile.WriteAtomic(ca.path(indexFile), data)
+}
+
+func generateKey(keyType string) (crypto.Signer, error) {
+	if keyType == KeyRSA {
+		return rsa.GenerateKey(rand.Reader, 3072)
+	}
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+}
+
+func encodeCert(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func encodeKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func parseCert(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func parseKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key %T", key)
+	}
+	return signer, nil
+}
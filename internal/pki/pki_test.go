@@ -0,0 +1,231 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/klowdo/tailswan/internal/certs"
+)
+
+func openTestCA(t *testing.T) *CA {
+	t.Helper()
+	ca, err := Open(t.TempDir(), "Test CA")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return ca
+}
+
+func issuedCert(t *testing.T, ca *CA, name string) *x509.Certificate {
+	t.Helper()
+	cert, _, err := ca.issued(name)
+	if err != nil {
+		t.Fatalf("issued(%s): %v", name, err)
+	}
+	return cert
+}
+
+func TestOpen_CreatesOnce(t *testing.T) {
+	dir := t.TempDir()
+	if Exists(dir) {
+		t.Fatal("empty dir should not hold a CA")
+	}
+	first, err := Open(dir, "Test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Open(dir, "Other CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Certificate().Equal(second.Certificate()) {
+		t.Error("reopening should load the existing CA")
+	}
+	if cn := second.Certificate().Subject.CommonName; cn != "Test CA" {
+		t.Errorf("expected CN Test CA, got %q", cn)
+	}
+	if !second.Certificate().IsCA {
+		t.Error("CA certificate should have the CA basic constraint")
+	}
+}
+
+func TestIssue_Profiles(t *testing.T) {
+	ca := openTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+
+	if _, err := ca.Issue(Request{Name: "gw.example.com", Profile: ProfileServer, SANs: []string{"gw.example.com", "203.0.113.1"}}); err != nil {
+		t.Fatal(err)
+	}
+	server := issuedCert(t, ca, "gw.example.com")
+	if !slices.Equal(server.DNSNames, []string{"gw.example.com"}) || len(server.IPAddresses) != 1 {
+		t.Errorf("unexpected SANs %v %v", server.DNSNames, server.IPAddresses)
+	}
+	if !slices.Contains(server.ExtKeyUsage, x509.ExtKeyUsageServerAuth) || !slices.ContainsFunc(server.UnknownExtKeyUsage, oidIKEIntermediate.Equal) {
+		t.Errorf("server certificate lacks serverAuth or ikeIntermediate: %v %v", server.ExtKeyUsage, server.UnknownExtKeyUsage)
+	}
+	if _, err := server.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}); err != nil {
+		t.Errorf("server certificate does not verify: %v", err)
+	}
+
+	c, err := ca.Issue(Request{Name: "alice", Profile: ProfileClient, KeyType: KeyRSA, SANs: []string{"alice@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := issuedCert(t, ca, "alice")
+	if c.KeyType != KeyRSA || !slices.Equal(client.EmailAddresses, []string{"alice@example.com"}) {
+		t.Errorf("unexpected client certificate %+v", c)
+	}
+	if _, err := client.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Errorf("client certificate does not verify: %v", err)
+	}
+}
+
+func TestIssue_Invalid(t *testing.T) {
+	ca := openTestCA(t)
+	for _, req := range []Request{
+		{Name: "gw", Profile: "peer"},
+		{Name: "../gw", Profile: ProfileServer},
+		{Name: "gw", Profile: ProfileServer, KeyType: "dsa"},
+	} {
+		if _, err := ca.Issue(req); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Issue(%+v): expected ErrInvalid, got %v", req, err)
+		}
+	}
+}
+
+func TestRevoke_PublishesCRLAndAllowsReissue(t *testing.T) {
+	ca := openTestCA(t)
+	issued, err := ca.Issue(Request{Name: "bob", Profile: ProfileClient})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ca.Issue(Request{Name: "bob", Profile: ProfileClient}); !errors.Is(err, ErrExists) {
+		t.Fatalf("expected ErrExists, got %v", err)
+	}
+
+	if _, err := ca.Revoke("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ca.Revoke("bob"); !errors.Is(err, ErrRevoked) {
+		t.Errorf("expected ErrRevoked, got %v", err)
+	}
+	if _, err := ca.Export("bob", FormatPEM, "", false); !errors.Is(err, ErrRevoked) {
+		t.Errorf("a revoked certificate should not be exported, got %v", err)
+	}
+
+	data, err := ca.CRL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crl.CheckSignatureFrom(ca.Certificate()); err != nil {
+		t.Errorf("CRL signature: %v", err)
+	}
+	if len(crl.RevokedCertificateEntries) != 1 || crl.RevokedCertificateEntries[0].SerialNumber.Text(16) != issued.Serial {
+		t.Errorf("expected serial %s revoked, got %+v", issued.Serial, crl.RevokedCertificateEntries)
+	}
+
+	if _, err := ca.Issue(Request{Name: "bob", Profile: ProfileClient}); err != nil {
+		t.Fatalf("reissuing a revoked name: %v", err)
+	}
+	list, err := ca.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Errorf("expected the revoked and the new certificate, got %d", len(list))
+	}
+}
+
+func TestRefreshCRL(t *testing.T) {
+	ca := openTestCA(t)
+	if refreshed, err := ca.RefreshCRL(); err != nil || refreshed {
+		t.Fatalf("a fresh CRL should be kept, got %v %v", refreshed, err)
+	}
+	ca.now = func() time.Time { return time.Now().Add(crlValidity - crlRefresh + time.Hour) }
+	if refreshed, err := ca.RefreshCRL(); err != nil || !refreshed {
+		t.Fatalf("a CRL close to its next update should be reissued, got %v %v", refreshed, err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	ca := openTestCA(t)
+	if _, err := ca.Issue(Request{Name: "carol", Profile: ProfileClient}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ca.Export("carol", FormatPKCS12, "", false); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid without a password, got %v", err)
+	}
+	for _, legacy := range []bool{false, true} {
+		data, err := ca.Export("carol", FormatPKCS12, "secret", legacy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, cert, chain, err := pkcs12.DecodeChain(data, "secret")
+		if err != nil {
+			t.Fatalf("decode PKCS#12 (legacy %v): %v", legacy, err)
+		}
+		if key == nil || cert.Subject.CommonName != "carol" || len(chain) != 1 || !chain[0].Equal(ca.Certificate()) {
+			t.Errorf("unexpected bundle %v %v", cert.Subject, chain)
+		}
+	}
+
+	data, err := ca.Export("carol", FormatPEM, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		types = append(types, block.Type)
+	}
+	if !slices.Equal(types, []string{"CERTIFICATE", "CERTIFICATE", "PRIVATE KEY"}) {
+		t.Errorf("unexpected PEM bundle %v", types)
+	}
+
+	if _, err := ca.Export("dave", FormatPEM, "", false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	ca := openTestCA(t)
+	store := certs.NewStore(filepath.Join(t.TempDir(), "swanctl"))
+	if _, err := ca.Install(store); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ca.Issue(Request{Name: "gw", Profile: ProfileServer}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ca.InstallIssued(store, "gw"); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range creds {
+		names = append(names, c.Kind+"/"+c.Name)
+		if c.Error != "" {
+			t.Errorf("%s/%s: %s", c.Kind, c.Name, c.Error)
+		}
+	}
+	want := []string{"cert/gw.pem", "ca/" + CAName, "crl/" + CRLName, "key/gw.pem"}
+	if !slices.Equal(names, want) {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+}
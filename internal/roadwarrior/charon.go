@@ -0,0 +1,57 @@
+package roadwarrior
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+// SecretID is the id a user's EAP secret is loaded under.
+func SecretID(name string) string {
+	return "rw-" + name
+}
+
+// LoadUser loads an EAP-MSCHAPv2 user's secret into charon. EAP-TLS users
+// need nothing loaded; charon trusts the built-in CA.
+func LoadUser(ctx context.Context, session *vici.Session, u *User) error {
+	if u.Auth != AuthMSCHAPv2 {
+		return nil
+	}
+	return viciconn.LoadShared(ctx, session, SecretID(u.Name), "EAP", u.Password, []string{u.Name})
+}
+
+func UnloadUser(ctx context.Context, session *vici.Session, u *User) error {
+	if u.Auth != AuthMSCHAPv2 {
+		return nil
+	}
+	return viciconn.UnloadShared(ctx, session, SecretID(u.Name))
+}
+
+func LoadPool(ctx context.Context, session *vici.Session, p *Pool) error {
+	return viciconn.LoadPool(ctx, session, p.Name, p.Addrs, p.DNS)
+}
+
+// Sync loads every user and pool of the store into charon, e.g. after
+// charon restarted.
+func (s *Store) Sync(ctx context.Context, session *vici.Session) error {
+	st, err := s.read()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range st.Pools {
+		if err := LoadPool(ctx, session, &st.Pools[i]); err != nil {
+			errs = append(errs, fmt.Errorf("pool %s: %w", st.Pools[i].Name, err))
+		}
+	}
+	for i := range st.Users {
+		if err := LoadUser(ctx, session, &st.Users[i]); err != nil {
+			errs = append(errs, fmt.Errorf("user %s: %w", st.Users[i].Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
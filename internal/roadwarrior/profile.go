@@ -0,0 +1,296 @@
+package roadwarrior
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const (
+	FormatMobileconfig = "mobileconfig"
+	FormatSSwan        = "sswan"
+	FormatPowerShell   = "ps1"
+
+	// The profiles propose AES-256, SHA2-256 and ECP-256 for the IKE and
+	// the CHILD_SA, which the gateway's connection has to accept:
+	//
+	//	proposals = aes256-sha256-ecp256
+	//	esp_proposals = aes256-sha256-ecp256
+	IKEProposal = "aes256-sha256-ecp256"
+	ESPProposal = "aes256-sha256-ecp256"
+)
+
+// Formats lists the profile formats.
+var Formats = []string{FormatMobileconfig, FormatSSwan, FormatPowerShell}
+
+// Profile describes what a user's client needs to connect.
+type Profile struct {
+	// CACert is the built-in CA the gateway's certificate is issued by. It
+	// is nil when the gateway's certificate is trusted by the clients
+	// anyway.
+	CACert *x509.Certificate
+	User   *User
+	// Name is the connection's name on the client.
+	Name     string
+	Server   string
+	ServerID string
+	// PKCS12 bundles an EAP-TLS user's certificate and key, encrypted with
+	// PKCS12Password.
+	PKCS12Password string
+	PKCS12         []byte
+}
+
+// ContentType is the media type of a profile format.
+func ContentType(format string) string {
+	switch format {
+	case FormatMobileconfig:
+		return "application/x-apple-aspen-config"
+	case FormatSSwan:
+		return "application/vnd.strongswan.profile"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// Generate renders the profile in format.
+func Generate(format string, p *Profile) ([]byte, error) {
+	if p.Server == "" {
+		return nil, fmt.Errorf("%w: the gateway's address is not set, set RW_SERVER", ErrInvalid)
+	}
+	if p.ServerID == "" {
+		p.ServerID = p.Server
+	}
+	if p.User.Auth == AuthTLS && len(p.PKCS12) == 0 {
+		return nil, fmt.Errorf("%w: an EAP-TLS profile needs the user's certificate", ErrInvalid)
+	}
+
+	switch format {
+	case FormatMobileconfig:
+		return render(mobileconfigTemplate, p)
+	case FormatSSwan:
+		return sswan(p)
+	case FormatPowerShell:
+		return render(powershellTemplate, p)
+	}
+	return nil, fmt.Errorf("%w: unknown format %q, expected one of %s", ErrInvalid, format, strings.Join(Formats, ", "))
+}
+
+func render(tmpl *template.Template, p *Profile) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, p); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sswan renders the strongSwan Android app's profile format.
+func sswan(p *Profile) ([]byte, error) {
+	type remote struct {
+		Addr string `json:"addr"`
+		ID   string `json:"id"`
+		Cert string `json:"cert,omitempty"`
+	}
+	type local struct {
+		EAPID string `json:"eap_id,omitempty"`
+		P12   string `json:"p12,omitempty"`
+	}
+	profile := struct {
+		UUID    string `json:"uuid"`
+		Name    string `json:"name"`
+		Type    string `json:"type"`
+		IKEProp string `json:"ike-proposal"`
+		ESPProp string `json:"esp-proposal"`
+		Remote  remote `json:"remote"`
+		Local   local  `json:"local"`
+	}{
+		UUID:    uuid(p, "sswan"),
+		Name:    p.Name,
+		Type:    "ikev2-eap",
+		IKEProp: IKEProposal,
+		ESPProp: ESPProposal,
+		Remote:  remote{Addr: p.Server, ID: p.ServerID},
+		Local:   local{EAPID: p.User.Name},
+	}
+	if p.CACert != nil {
+		profile.Remote.Cert = base64.StdEncoding.EncodeToString(p.CACert.Raw)
+	}
+	if p.User.Auth == AuthTLS {
+		profile.Type = "ikev2-eap-tls"
+		profile.Local = local{P12: base64.StdEncoding.EncodeToString(p.PKCS12)}
+	}
+	return json.MarshalIndent(profile, "", "  ")
+}
+
+// uuid derives a stable UUID for a payload of the profile, so importing a
+// new profile replaces the old one.
+func uuid(p *Profile, payload string) string {
+	sum := sha256.Sum256([]byte(p.Server + "\x00" + p.User.Name + "\x00" + payload))
+	sum[6] = sum[6]&0x0f | 0x40
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%X-%X-%X-%X-%X", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+var funcs = template.FuncMap{
+	"uuid": uuid,
+	"xml": func(s string) (string, error) {
+		var buf bytes.Buffer
+		err := xml.EscapeText(&buf, []byte(s))
+		return buf.String(), err
+	},
+	// ps quotes a string for a single-quoted PowerShell literal.
+	"ps": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	},
+	"base64": base64.StdEncoding.EncodeToString,
+	"tls":    func(u *User) bool { return u.Auth == AuthTLS },
+}
+
+var mobileconfigTemplate = template.Must(template.New("mobileconfig").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadDisplayName</key>
+	<string>{{xml .Name}}</string>
+	<key>PayloadIdentifier</key>
+	<string>com.tailswan.vpn.{{uuid . "profile"}}</string>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadUUID</key>
+	<string>{{uuid . "profile"}}</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+	<key>PayloadContent</key>
+	<array>
+{{- if .CACert}}
+		<dict>
+			<key>PayloadDisplayName</key>
+			<string>{{xml .CACert.Subject.CommonName}}</string>
+			<key>PayloadIdentifier</key>
+			<string>com.tailswan.vpn.{{uuid . "ca"}}</string>
+			<key>PayloadType</key>
+			<string>com.apple.security.root</string>
+			<key>PayloadUUID</key>
+			<string>{{uuid . "ca"}}</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+			<key>PayloadContent</key>
+			<data>{{base64 .CACert.Raw}}</data>
+		</dict>
+{{- end}}
+{{- if tls .User}}
+		<dict>
+			<key>PayloadDisplayName</key>
+			<string>{{xml .User.Name}}</string>
+			<key>PayloadIdentifier</key>
+			<string>com.tailswan.vpn.{{uuid . "pkcs12"}}</string>
+			<key>PayloadType</key>
+			<string>com.apple.security.pkcs12</string>
+			<key>PayloadUUID</key>
+			<string>{{uuid . "pkcs12"}}</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+			<key>Password</key>
+			<string>{{xml .PKCS12Password}}</string>
+			<key>PayloadContent</key>
+			<data>{{base64 .PKCS12}}</data>
+		</dict>
+{{- end}}
+		<dict>
+			<key>PayloadDisplayName</key>
+			<string>{{xml .Name}}</string>
+			<key>PayloadIdentifier</key>
+			<string>com.tailswan.vpn.{{uuid . "vpn"}}</string>
+			<key>PayloadType</key>
+			<string>com.apple.vpn.managed</string>
+			<key>PayloadUUID</key>
+			<string>{{uuid . "vpn"}}</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+			<key>UserDefinedName</key>
+			<string>{{xml .Name}}</string>
+			<key>VPNType</key>
+			<string>IKEv2</string>
+			<key>IKEv2</key>
+			<dict>
+				<key>RemoteAddress</key>
+				<string>{{xml .Server}}</string>
+				<key>RemoteIdentifier</key>
+				<string>{{xml .ServerID}}</string>
+				<key>LocalIdentifier</key>
+				<string>{{xml .User.Name}}</string>
+				<key>ExtendedAuthEnabled</key>
+				<integer>1</integer>
+{{- if tls .User}}
+				<key>AuthenticationMethod</key>
+				<string>Certificate</string>
+				<key>PayloadCertificateUUID</key>
+				<string>{{uuid . "pkcs12"}}</string>
+{{- else}}
+				<key>AuthenticationMethod</key>
+				<string>None</string>
+				<key>AuthName</key>
+				<string>{{xml .User.Name}}</string>
+				<key>AuthPassword</key>
+				<string>{{xml .User.Password}}</string>
+{{- end}}
+				<key>IKESecurityAssociationParameters</key>
+				<dict>
+					<key>EncryptionAlgorithm</key>
+					<string>AES-256</string>
+					<key>IntegrityAlgorithm</key>
+					<string>SHA2-256</string>
+					<key>DiffieHellmanGroup</key>
+					<integer>19</integer>
+				</dict>
+				<key>ChildSecurityAssociationParameters</key>
+				<dict>
+					<key>EncryptionAlgorithm</key>
+					<string>AES-256</string>
+					<key>IntegrityAlgorithm</key>
+					<string>SHA2-256</string>
+					<key>DiffieHellmanGroup</key>
+					<integer>19</integer>
+				</dict>
+			</dict>
+		</dict>
+	</array>
+</dict>
+</plist>
+`))
+
+var powershellTemplate = template.Must(template.New("ps1").Funcs(funcs).Parse(`# {{.Name}} for {{.User.Name}}. Run in an elevated PowerShell.
+$ErrorActionPreference = 'Stop'
+$name = {{ps .Name}}
+{{- if .CACert}}
+
+$ca = Join-Path $env:TEMP 'tailswan-ca.cer'
+[IO.File]::WriteAllBytes($ca, [Convert]::FromBase64String('{{base64 .CACert.Raw}}'))
+Import-Certificate -FilePath $ca -CertStoreLocation Cert:\LocalMachine\Root | Out-Null
+Remove-Item $ca
+{{- end}}
+{{- if tls .User}}
+
+$p12 = Join-Path $env:TEMP 'tailswan-user.p12'
+[IO.File]::WriteAllBytes($p12, [Convert]::FromBase64String('{{base64 .PKCS12}}'))
+$password = Read-Host -AsSecureString 'Password of the certificate bundle'
+Import-PfxCertificate -FilePath $p12 -CertStoreLocation Cert:\CurrentUser\My -Password $password | Out-Null
+Remove-Item $p12
+$eap = New-EapConfiguration -Tls -UserCertificate
+{{- else}}
+
+$eap = New-EapConfiguration
+{{- end}}
+
+Remove-VpnConnection -Name $name -Force -ErrorAction SilentlyContinue
+Add-VpnConnection -Name $name -ServerAddress {{ps .Server}} -TunnelType Ikev2 -AuthenticationMethod Eap -EapConfigXmlStream $eap.EapConfigXmlStream -EncryptionLevel Required -RememberCredential
+Set-VpnConnectionIPsecConfiguration -ConnectionName $name -AuthenticationTransformConstants SHA256128 -CipherTransformConstants AES256 -EncryptionMethod AES256 -IntegrityCheckMethod SHA256 -DHGroup ECP256 -PfsGroup ECP256 -Force
+{{- if not (tls .User)}}
+
+Write-Host "Connect to $name and sign in as {{.User.Name}}."
+{{- end}}
+`))
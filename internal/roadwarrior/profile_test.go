@@ -0,0 +1,172 @@
+package roadwarrior
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func testCA(t *testing.T) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             testNow,
+		NotAfter:              testNow.AddDate(1, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func wellFormedXML(t *testing.T, data []byte) {
+	t.Helper()
+	dec := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		_, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			t.Fatalf("profile is not well-formed XML: %v\n%s", err, data)
+		}
+	}
+}
+
+func TestGenerate_MSCHAPv2(t *testing.T) {
+	p := &Profile{
+		CACert: testCA(t),
+		User:   &User{Name: "alice@example.com", Auth: AuthMSCHAPv2, Password: `p<&>"'w`},
+		Name:   "Example & Co VPN",
+		Server: "vpn.example.com",
+	}
+
+	data, err := Generate(FormatMobileconfig, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wellFormedXML(t, data)
+	for _, want := range []string{"<string>p&lt;&amp;&gt;&#34;&#39;w</string>", "com.apple.security.root", "<string>vpn.example.com</string>", "<string>None</string>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("mobileconfig lacks %q", want)
+		}
+	}
+	again, err := Generate(FormatMobileconfig, p)
+	if err != nil || string(again) != string(data) {
+		t.Error("payload UUIDs should be stable so a new profile replaces the old one")
+	}
+
+	data, err = Generate(FormatSSwan, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sswan struct {
+		Type   string `json:"type"`
+		Remote struct {
+			Addr string `json:"addr"`
+			ID   string `json:"id"`
+			Cert string `json:"cert"`
+		} `json:"remote"`
+		Local struct {
+			EAPID string `json:"eap_id"`
+		} `json:"local"`
+	}
+	if err := json.Unmarshal(data, &sswan); err != nil {
+		t.Fatal(err)
+	}
+	if sswan.Type != "ikev2-eap" || sswan.Remote.ID != "vpn.example.com" || sswan.Remote.Cert == "" || sswan.Local.EAPID != "alice@example.com" {
+		t.Errorf("unexpected sswan profile %s", data)
+	}
+	if strings.Contains(string(data), "p<&>") {
+		t.Error("the sswan profile should not carry the password")
+	}
+
+	data, err = Generate(FormatPowerShell, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"$name = 'Example & Co VPN'", "-ServerAddress 'vpn.example.com'", "Cert:\\LocalMachine\\Root", "-DHGroup ECP256"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("PowerShell script lacks %q", want)
+		}
+	}
+}
+
+func TestGenerate_TLS(t *testing.T) {
+	p := &Profile{
+		User:           &User{Name: "bob", Auth: AuthTLS},
+		Name:           "VPN",
+		Server:         "203.0.113.1",
+		ServerID:       "vpn.example.com",
+		PKCS12:         []byte("p12"),
+		PKCS12Password: "secret",
+	}
+
+	data, err := Generate(FormatMobileconfig, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wellFormedXML(t, data)
+	if !strings.Contains(string(data), "com.apple.security.pkcs12") || !strings.Contains(string(data), "<string>Certificate</string>") {
+		t.Errorf("EAP-TLS mobileconfig lacks the certificate payload")
+	}
+	if strings.Contains(string(data), "com.apple.security.root") {
+		t.Error("no CA payload without a CA certificate")
+	}
+
+	data, err = Generate(FormatSSwan, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"type": "ikev2-eap-tls"`) || !strings.Contains(string(data), `"p12": "cDEy"`) {
+		t.Errorf("unexpected sswan profile %s", data)
+	}
+
+	data, err = Generate(FormatPowerShell, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "New-EapConfiguration -Tls -UserCertificate") {
+		t.Errorf("PowerShell script should configure EAP-TLS:\n%s", data)
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	tests := []struct {
+		profile *Profile
+		name    string
+		format  string
+	}{
+		{name: "no server", format: FormatSSwan, profile: &Profile{User: &User{Name: "alice", Auth: AuthMSCHAPv2}}},
+		{name: "no certificate", format: FormatSSwan, profile: &Profile{Server: "vpn", User: &User{Name: "bob", Auth: AuthTLS}}},
+		{name: "unknown format", format: "ovpn", profile: &Profile{Server: "vpn", User: &User{Name: "alice", Auth: AuthMSCHAPv2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Generate(tt.format, tt.profile); !errors.Is(err, ErrInvalid) {
+				t.Errorf("expected ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+// Package roadwarrior manages IKEv2 remote-access users and the virtual IP
+// pools they get addresses from, and generates their client profiles.
+package roadwarrior
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/statefile"
+)
+
+const (
+	AuthMSCHAPv2 = "eap-mschapv2"
+	AuthTLS      = "eap-tls"
+
+	stateFile = "roadwarrior.json"
+	// passwordBytes gives generated passwords 160 bits.
+	passwordBytes = 20
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrExists   = errors.New("already exists")
+	// ErrInvalid wraps errors about a bad user or pool.
+	ErrInvalid = errors.New("invalid request")
+)
+
+// identityPattern admits EAP identities such as alice or
+// alice@example.com, which also name the user's certificate.
+var identityPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]*$`)
+
+// User is a road-warrior. Its name is the EAP identity it authenticates
+// with; EAP-TLS users present a certificate of the built-in CA with the
+// name as SAN instead of a password.
+type User struct {
+	Created time.Time `json:"created"`
+	Name    string    `json:"name"`
+	Auth    string    `json:"auth"`
+	// Password is the EAP-MSCHAPv2 secret. charon needs it in clear, so
+	// it is kept in the state file, readable by root only.
+	Password string `json:"password,omitempty"`
+}
+
+// Redacted returns the user without its password.
+func (u User) Redacted() User {
+	u.Password = ""
+	return u
+}
+
+// Pool hands out virtual IPs from Addrs, a CIDR subnet or a from-to range,
+// to the clients of connections with pools = <Name>.
+type Pool struct {
+	Name  string   `json:"name"`
+	Addrs string   `json:"addrs"`
+	DNS   []string `json:"dns,omitempty"`
+}
+
+type state struct {
+	Users []User `json:"users"`
+	Pools []Pool `json:"pools"`
+}
+
+// Store keeps the users and pools in the state directory. charon forgets
+// them when it restarts, so they are loaded again from here.
+type Store struct {
+	path string
+}
+
+func NewStore(stateDir string) *Store {
+	return &Store{path: filepath.Join(stateDir, stateFile)}
+}
+
+// Users returns the users sorted by name, with their passwords.
+func (s *Store) Users() ([]User, error) {
+	st, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return st.Users, nil
+}
+
+func (s *Store) User(name string) (*User, error) {
+	users, err := s.Users()
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if users[i].Name == name {
+			return &users[i], nil
+		}
+	}
+	return nil, fmt.Errorf("user %s %w", name, ErrNotFound)
+}
+
+// AddUser adds a user. An EAP-MSCHAPv2 user without a password gets a
+// generated one, which is returned.
+func (s *Store) AddUser(now time.Time, u User) (*User, error) {
+	if !identityPattern.MatchString(u.Name) {
+		return nil, fmt.Errorf("%w: bad user name %q", ErrInvalid, u.Name)
+	}
+	switch u.Auth {
+	case "":
+		u.Auth = AuthMSCHAPv2
+	case AuthMSCHAPv2, AuthTLS:
+	default:
+		return nil, fmt.Errorf("%w: unknown auth %q, expected %s or %s", ErrInvalid, u.Auth, AuthMSCHAPv2, AuthTLS)
+	}
+	if u.Auth == AuthTLS {
+		u.Password = ""
+	} else if u.Password == "" {
+		password, err := generatePassword()
+		if err != nil {
+			return nil, err
+		}
+		u.Password = password
+	}
+	u.Created = now.UTC().Truncate(time.Second)
+
+	err := s.update(func(st *state) error {
+		for _, existing := range st.Users {
+			if existing.Name == u.Name {
+				return fmt.Errorf("user %s %w", u.Name, ErrExists)
+			}
+		}
+		st.Users = append(st.Users, u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// DeleteUser removes a user and returns it.
+func (s *Store) DeleteUser(name string) (*User, error) {
+	var deleted *User
+	err := s.update(func(st *state) error {
+		for i, u := range st.Users {
+			if u.Name == name {
+				deleted = &u
+				st.Users = append(st.Users[:i], st.Users[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("user %s %w", name, ErrNotFound)
+	})
+	return deleted, err
+}
+
+// Pools returns the pools sorted by name.
+func (s *Store) Pools() ([]Pool, error) {
+	st, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return st.Pools, nil
+}
+
+// PutPool adds a pool or replaces the one of the same name.
+func (s *Store) PutPool(p Pool) (*Pool, error) {
+	if !identityPattern.MatchString(p.Name) {
+		return nil, fmt.Errorf("%w: bad pool name %q", ErrInvalid, p.Name)
+	}
+	if err := validateAddrs(p.Addrs); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	for _, dns := range p.DNS {
+		if _, err := netip.ParseAddr(dns); err != nil {
+			return nil, fmt.Errorf("%w: bad DNS server %q", ErrInvalid, dns)
+		}
+	}
+
+	err := s.update(func(st *state) error {
+		for i := range st.Pools {
+			if st.Pools[i].Name == p.Name {
+				st.Pools[i] = p
+				return nil
+			}
+		}
+		st.Pools = append(st.Pools, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *Store) DeletePool(name string) error {
+	return s.update(func(st *state) error {
+		for i, p := range st.Pools {
+			if p.Name == name {
+				st.Pools = append(st.Pools[:i], st.Pools[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("pool %s %w", name, ErrNotFound)
+	})
+}
+
+// validateAddrs accepts what charon accepts as pool addrs: a subnet such
+// as 10.10.0.0/24 or a range such as 10.10.0.10-10.10.0.99.
+func validateAddrs(addrs string) error {
+	if from, to, ok := strings.Cut(addrs, "-"); ok {
+		start, err := netip.ParseAddr(strings.TrimSpace(from))
+		if err != nil {
+			return fmt.Errorf("bad range start %q", from)
+		}
+		end, err := netip.ParseAddr(strings.TrimSpace(to))
+		if err != nil {
+			return fmt.Errorf("bad range end %q", to)
+		}
+		if start.Is4() != end.Is4() || end.Less(start) {
+			return fmt.Errorf("bad range %q", addrs)
+		}
+		return nil
+	}
+	if _, err := netip.ParsePrefix(addrs); err != nil {
+		return fmt.Errorf("bad addrs %q, expected a subnet or a from-to range", addrs)
+	}
+	return nil
+}
+
+func generatePassword() (string, error) {
+	b := make([]byte, passwordBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)), nil
+}
+
+func (s *Store) update(fn func(st *state) error) error {
+	return statefile.Locked(s.path, func() error {
+		st, err := s.read()
+		if err != nil {
+			return err
+		}
+		if err := fn(st); err != nil {
+			return err
+		}
+		return s.write(st)
+	})
+}
+
+func (s *Store) read() (*state, error) {
+	st := &state{Users: []User{}, Pools: []Pool{}}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(data) == 0) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	if st.Users == nil {
+		st.Users = []User{}
+	}
+	if st.Pools == nil {
+		st.Pools = []Pool{}
+	}
+	sort.Slice(st.Users, func(i, j int) bool { return st.Users[i].Name < st.Users[j].Name })
+	sort.Slice(st.Pools, func(i, j int) bool { return st.Pools[i].Name < st.Pools[j].Name })
+	return st, nil
+}
+
+func (s *Store) write(st *state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statefile.WriteAtomic(s.path, data)
+}
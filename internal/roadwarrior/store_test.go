@@ -0,0 +1,122 @@
+package roadwarrior
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func TestStore_Users(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+
+	alice, err := s.AddUser(testNow, User{Name: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Auth != AuthMSCHAPv2 || len(alice.Password) < 20 {
+		t.Errorf("expected an EAP-MSCHAPv2 user with a generated password, got %+v", alice)
+	}
+	bob, err := s.AddUser(testNow, User{Name: "bob", Auth: AuthTLS, Password: "ignored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bob.Password != "" {
+		t.Error("EAP-TLS users have no password")
+	}
+	if _, err := s.AddUser(testNow, User{Name: "bob"}); !errors.Is(err, ErrExists) {
+		t.Errorf("expected ErrExists, got %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, stateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("the state file holds passwords and should be 0600, got %v", info.Mode().Perm())
+	}
+
+	users, err := s.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].Name != "alice@example.com" || users[0].Password != alice.Password {
+		t.Errorf("unexpected users %+v", users)
+	}
+	if users[0].Redacted().Password != "" {
+		t.Error("Redacted should drop the password")
+	}
+
+	if _, err := s.DeleteUser("alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteUser("alice@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.User("bob"); err != nil {
+		t.Errorf("bob should be kept: %v", err)
+	}
+}
+
+func TestStore_InvalidUsers(t *testing.T) {
+	s := NewStore(t.TempDir())
+	for _, u := range []User{
+		{Name: ""},
+		{Name: "../alice"},
+		{Name: "alice", Auth: "psk"},
+	} {
+		if _, err := s.AddUser(testNow, u); !errors.Is(err, ErrInvalid) {
+			t.Errorf("AddUser(%+v): expected ErrInvalid, got %v", u, err)
+		}
+	}
+}
+
+func TestStore_Pools(t *testing.T) {
+	s := NewStore(t.TempDir())
+
+	valid := []Pool{
+		{Name: "rw", Addrs: "10.10.0.0/24", DNS: []string{"10.1.0.53"}},
+		{Name: "contractors", Addrs: "10.20.0.10-10.20.0.99"},
+		{Name: "v6", Addrs: "fd00:10::/120"},
+	}
+	for _, p := range valid {
+		if _, err := s.PutPool(p); err != nil {
+			t.Errorf("PutPool(%+v): %v", p, err)
+		}
+	}
+	if _, err := s.PutPool(Pool{Name: "rw", Addrs: "10.11.0.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []Pool{
+		{Name: "bad", Addrs: "10.10.0.0"},
+		{Name: "bad", Addrs: "10.20.0.99-10.20.0.10"},
+		{Name: "bad", Addrs: "10.20.0.1-fd00::1"},
+		{Name: "bad", Addrs: "10.10.0.0/24", DNS: []string{"dns.example.com"}},
+		{Name: "", Addrs: "10.10.0.0/24"},
+	}
+	for _, p := range invalid {
+		if _, err := s.PutPool(p); !errors.Is(err, ErrInvalid) {
+			t.Errorf("PutPool(%+v): expected ErrInvalid, got %v", p, err)
+		}
+	}
+
+	pools, err := s.Pools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pools) != 3 || pools[0].Name != "contractors" || pools[1].Addrs != "10.11.0.0/24" {
+		t.Errorf("expected the pools sorted with rw replaced, got %+v", pools)
+	}
+
+	if err := s.DeletePool("v6"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeletePool("v6"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
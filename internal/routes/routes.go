@@ -6,17 +6,84 @@ import (
 	"github.com/klowdo/tailswan/internal/handlers"
 )
 
-func RegisterRoutes(mux *http.ServeMux, viciHandler *handlers.VICIHandler, tsHandler *handlers.TailscaleHandler, healthHandler *handlers.HealthHandler, sseHandler *handlers.SSEHandler) {
-	mux.HandleFunc("/api/health", healthHandler.Check)
-	mux.HandleFunc("/api/events", sseHandler.Events)
-
-	mux.HandleFunc("/api/vici/connections/up", viciHandler.ConnectionUp)
-	mux.HandleFunc("/api/vici/connections/down", viciHandler.ConnectionDown)
-	mux.HandleFunc("/api/vici/connections/list", viciHandler.ListConnections)
-	mux.HandleFunc("/api/vici/sas/list", viciHandler.ListSAs)
-
-	mux.HandleFunc("/api/tailscale/status", tsHandler.Status)
-	mux.HandleFunc("/api/tailscale/peers", tsHandler.Peers)
-	mux.HandleFunc("/api/tailscale/serve", tsHandler.ServeStatus)
-	mux.HandleFunc("/api/tailscale/whois", tsHandler.WhoIs)
+type Handlers struct {
+	VICI      *handlers.VICIHandler
+	Tailscale *handlers.TailscaleHandler
+	Health    *handlers.HealthHandler
+	SSE       *handlers.SSEHandler
+	Diag      *handlers.DiagHandler
+	Support   *handlers.SupportHandler
+	HA        *handlers.HAHandler
+	Metrics   *handlers.MetricsHandler
+	Fleet     *handlers.FleetHandler
+	Schedule  *handlers.ScheduleHandler
+	Certs     *handlers.CertHandler
+	PKI       *handlers.PKIHandler
+	RW        *handlers.RoadWarriorHandler
+	Identity  *handlers.IdentityHandler
+	Peer      *handlers.PeerConfigHandler
+	Templates *handlers.TemplatesHandler
+	History   *handlers.HistoryHandler
+	Watch     *handlers.ConfigWatchHandler
+	DryRun    *handlers.DryRunHandler
+}
+
+func RegisterRoutes(mux *http.ServeMux, h *Handlers) {
+	mux.HandleFunc("/api/health", h.Health.Check)
+	mux.HandleFunc("/api/health/live", h.Health.Live)
+	mux.HandleFunc("/api/health/ready", h.Health.Ready)
+	mux.HandleFunc("/api/events", h.SSE.Events)
+	mux.HandleFunc("/api/ha", h.HA.Status)
+	mux.HandleFunc("/metrics", h.Metrics.Metrics)
+
+	mux.HandleFunc("/api/vici/connections/up", h.VICI.ConnectionUp)
+	mux.HandleFunc("/api/vici/connections/down", h.VICI.ConnectionDown)
+	mux.HandleFunc("/api/vici/connections/list", h.VICI.ListConnections)
+	mux.HandleFunc("/api/vici/sas/list", h.VICI.ListSAs)
+	mux.HandleFunc("/api/peer-config/", h.Peer.Export)
+	mux.HandleFunc("/api/templates", h.Templates.List)
+	mux.HandleFunc("/api/templates/", h.Templates.Template)
+	mux.HandleFunc("/api/config/history", h.History.History)
+	mux.HandleFunc("/api/config/history/", h.History.Version)
+	mux.HandleFunc("/api/config/pending", h.History.Pending)
+	mux.HandleFunc("/api/config/confirm", h.History.Confirm)
+	mux.HandleFunc("/api/config/revert", h.History.Revert)
+	mux.HandleFunc("/api/config/watch", h.Watch.Status)
+	mux.HandleFunc("/api/config/dry-run", h.DryRun.DryRun)
+
+	mux.HandleFunc("/api/schedules", h.Schedule.Schedules)
+	mux.HandleFunc("/api/schedules/leases", h.Schedule.Leases)
+
+	mux.HandleFunc("/api/certs", h.Certs.List)
+	mux.HandleFunc("/api/certs/", h.Certs.Credential)
+
+	mux.HandleFunc("/api/pki", h.PKI.Overview)
+	mux.HandleFunc("/api/pki/ca.pem", h.PKI.CACert)
+	mux.HandleFunc("/api/pki/crl.pem", h.PKI.CRL)
+	mux.HandleFunc("/api/pki/certs", h.PKI.Issue)
+	mux.HandleFunc("/api/pki/certs/", h.PKI.Cert)
+
+	mux.HandleFunc("/api/roadwarrior", h.RW.Overview)
+	mux.HandleFunc("/api/roadwarrior/users", h.RW.AddUser)
+	mux.HandleFunc("/api/roadwarrior/users/", h.RW.User)
+	mux.HandleFunc("/api/roadwarrior/pools", h.RW.AddPool)
+	mux.HandleFunc("/api/roadwarrior/pools/", h.RW.Pool)
+	mux.HandleFunc("/api/identities", h.Identity.Identities)
+
+	mux.HandleFunc("/api/tailscale/status", h.Tailscale.Status)
+	mux.HandleFunc("/api/tailscale/peers", h.Tailscale.Peers)
+	mux.HandleFunc("/api/tailscale/serve", h.Tailscale.ServeStatus)
+	mux.HandleFunc("/api/tailscale/whois", h.Tailscale.WhoIs)
+
+	mux.HandleFunc("/api/diag/ping", h.Diag.Ping)
+	mux.HandleFunc("/api/diag/tcp", h.Diag.TCP)
+	mux.HandleFunc("/api/diag/tailscale-ping", h.Diag.TailscalePing)
+	mux.HandleFunc("/api/diag/xfrm", h.Diag.XFRM)
+	mux.HandleFunc("/api/diag/mtu", h.Diag.MTU)
+	mux.HandleFunc("/api/diag/results", h.Diag.Results)
+
+	mux.HandleFunc("/api/support-bundle", h.Support.Bundle)
+
+	mux.HandleFunc("/api/fleet", h.Fleet.Fleet)
+	mux.HandleFunc("/api/fleet/proxy/", h.Fleet.Proxy)
 }
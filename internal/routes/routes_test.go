@@ -8,27 +8,82 @@ import (
 	"github.com/klowdo/tailswan/internal/handlers"
 )
 
+func testHandlers() *Handlers {
+	return &Handlers{
+		VICI:      &handlers.VICIHandler{},
+		Tailscale: &handlers.TailscaleHandler{},
+		Health:    &handlers.HealthHandler{},
+		SSE:       &handlers.SSEHandler{},
+		Diag:      &handlers.DiagHandler{},
+		Support:   &handlers.SupportHandler{},
+		HA:        &handlers.HAHandler{},
+		Metrics:   &handlers.MetricsHandler{},
+		Fleet:     &handlers.FleetHandler{},
+		Schedule:  &handlers.ScheduleHandler{},
+		Certs:     &handlers.CertHandler{},
+		PKI:       &handlers.PKIHandler{},
+		RW:        &handlers.RoadWarriorHandler{},
+		Identity:  &handlers.IdentityHandler{},
+		Peer:      &handlers.PeerConfigHandler{},
+		Templates: &handlers.TemplatesHandler{},
+		History:   &handlers.HistoryHandler{},
+		Watch:     &handlers.ConfigWatchHandler{},
+		DryRun:    &handlers.DryRunHandler{},
+	}
+}
+
 func TestRegisterRoutes(t *testing.T) {
 	mux := http.NewServeMux()
 
-	viciHandler := &handlers.VICIHandler{}
-	tsHandler := &handlers.TailscaleHandler{}
-	healthHandler := &handlers.HealthHandler{}
-	sseHandler := &handlers.SSEHandler{}
-
-	RegisterRoutes(mux, viciHandler, tsHandler, healthHandler, sseHandler)
+	RegisterRoutes(mux, testHandlers())
 
 	endpoints := []string{
 		"/api/health",
+		"/api/health/live",
+		"/api/health/ready",
 		"/api/events",
+		"/api/ha",
+		"/metrics",
 		"/api/vici/connections/up",
 		"/api/vici/connections/down",
 		"/api/vici/connections/list",
 		"/api/vici/sas/list",
+		"/api/schedules",
+		"/api/schedules/leases",
+		"/api/certs",
+		"/api/certs/cert/gw.pem",
+		"/api/peer-config/partner-a",
+		"/api/templates",
+		"/api/templates/psk-site-to-site",
+		"/api/config/history",
+		"/api/config/history/3/diff",
+		"/api/config/pending",
+		"/api/config/confirm",
+		"/api/config/revert",
+		"/api/config/watch",
+		"/api/config/dry-run",
+		"/api/pki",
+		"/api/pki/certs",
+		"/api/pki/certs/gw/export",
+		"/api/roadwarrior",
+		"/api/roadwarrior/users",
+		"/api/roadwarrior/users/alice/profile",
+		"/api/roadwarrior/pools",
+		"/api/roadwarrior/pools/rw",
+		"/api/identities",
 		"/api/tailscale/status",
 		"/api/tailscale/peers",
 		"/api/tailscale/serve",
 		"/api/tailscale/whois",
+		"/api/diag/ping",
+		"/api/diag/tcp",
+		"/api/diag/tailscale-ping",
+		"/api/diag/xfrm",
+		"/api/diag/mtu",
+		"/api/diag/results",
+		"/api/support-bundle",
+		"/api/fleet",
+		"/api/fleet/proxy/tailswan-b/health",
 	}
 
 	for _, endpoint := range endpoints {
@@ -50,12 +105,7 @@ func TestRegisterRoutes(t *testing.T) {
 func TestUnregisteredRouteReturns404(t *testing.T) {
 	mux := http.NewServeMux()
 
-	viciHandler := &handlers.VICIHandler{}
-	tsHandler := &handlers.TailscaleHandler{}
-	healthHandler := &handlers.HealthHandler{}
-	sseHandler := &handlers.SSEHandler{}
-
-	RegisterRoutes(mux, viciHandler, tsHandler, healthHandler, sseHandler)
+	RegisterRoutes(mux, testHandlers())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/nonexistent", http.NoBody)
 	rr := httptest.NewRecorder()
@@ -0,0 +1,242 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TailscaleTable is the table tailscaled installs tailnet routes into;
+	// its own lookup rule sits at priority 5270.
+	TailscaleTable = "52"
+	// StrongSwanTable is the table charon installs routes to remote traffic
+	// selectors into, looked up at priority 220.
+	StrongSwanTable = "220"
+	// TailscaleBypassMark is the fwmark tailscaled sets on its own WireGuard
+	// and DERP packets.
+	TailscaleBypassMark = "0x80000/0xff0000"
+)
+
+var (
+	TailnetIPv4 = netip.MustParsePrefix("100.64.0.0/10")
+	TailnetIPv6 = netip.MustParsePrefix("fd7a:115c:a1e0::/48")
+)
+
+// Rule is a single ip rule. An invalid To matches all destinations.
+type Rule struct {
+	To       netip.Prefix
+	Mark     string
+	Table    string
+	Priority int
+	IPv6     bool
+}
+
+// KernelRules are the rules TailSwan needs when tailscaled owns a kernel TUN
+// device. Both daemons install their routes into separate tables, so the
+// order the tables are consulted in decides which side wins:
+//
+//   - 200: tailscaled's own encapsulated packets use the main table, so a
+//     CHILD_SA covering the peer's endpoint cannot swallow WireGuard traffic.
+//   - 210: tailnet addresses always go to tailscale0, even when a CHILD_SA
+//     covers 0.0.0.0/0, so IPsec -> tailnet replies are not sent back into
+//     the tunnel.
+//   - 220: charon's table is consulted before tailscaled's (5270), so remote
+//     subnets that another subnet router also advertises (--accept-routes)
+//     keep going through IPsec.
+func KernelRules() []Rule {
+	return []Rule{
+		{Priority: 200, Mark: TailscaleBypassMark, Table: "main"},
+		{Priority: 200, Mark: TailscaleBypassMark, Table: "main", IPv6: true},
+		{Priority: 210, To: TailnetIPv4, Table: TailscaleTable},
+		{Priority: 210, To: TailnetIPv6, Table: TailscaleTable, IPv6: true},
+		{Priority: 220, Table: StrongSwanTable},
+		{Priority: 220, Table: StrongSwanTable, IPv6: true},
+	}
+}
+
+func (r *Rule) family() string {
+	if r.IPv6 {
+		return "-6"
+	}
+	return "-4"
+}
+
+func (r *Rule) selector() []string {
+	args := []string{"pref", strconv.Itoa(r.Priority)}
+	if r.To.IsValid() {
+		args = append(args, "to", r.To.String())
+	}
+	if r.Mark != "" {
+		args = append(args, "fwmark", r.Mark)
+	}
+	return append(args, "lookup", r.Table)
+}
+
+func (r *Rule) String() string {
+	return strings.Join(append([]string{r.family()}, r.selector()...), " ")
+}
+
+type runner func(name string, args ...string) ([]byte, error)
+
+func execRunner(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return output, fmt.Errorf("%s: %w: %s", name, err, bytes.TrimSpace(exitErr.Stderr))
+		}
+		return output, fmt.Errorf("%s: %w", name, err)
+	}
+	return output, nil
+}
+
+// Manager keeps a set of ip rules installed. Rules that already exist are
+// left alone, so Apply can run repeatedly without reordering anything.
+type Manager struct {
+	run runner
+	// added holds the rules Apply installed, by String.
+	added map[string]bool
+}
+
+func NewManager() *Manager {
+	return &Manager{run: execRunner}
+}
+
+func (m *Manager) Apply(rules []Rule) error {
+	for i := range rules {
+		rule := &rules[i]
+		installed, err := m.installed(rule)
+		if err != nil {
+			return err
+		}
+		if installed {
+			continue
+		}
+		args := append([]string{rule.family(), "rule", "add"}, rule.selector()...)
+		if _, err := m.run("ip", args...); err != nil {
+			return fmt.Errorf("add rule %s: %w", rule, err)
+		}
+		if m.added == nil {
+			m.added = make(map[string]bool)
+		}
+		m.added[rule.String()] = true
+	}
+	return nil
+}
+
+// Remove deletes rules added by Apply. Rules that were already installed,
+// such as charon's own lookup rule for table 220, belong to whoever
+// installed them and are left alone. Missing rules are not an error.
+func (m *Manager) Remove(rules []Rule) error {
+	var errs []error
+	for i := range rules {
+		rule := &rules[i]
+		if !m.added[rule.String()] {
+			continue
+		}
+		delete(m.added, rule.String())
+		installed, err := m.installed(rule)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !installed {
+			continue
+		}
+		args := append([]string{rule.family(), "rule", "del"}, rule.selector()...)
+		if _, err := m.run("ip", args...); err != nil {
+			errs = append(errs, fmt.Errorf("delete rule %s: %w", rule, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Missing returns the rules that are not currently installed.
+func (m *Manager) Missing(rules []Rule) ([]Rule, error) {
+	var missing []Rule
+	for i := range rules {
+		installed, err := m.installed(&rules[i])
+		if err != nil {
+			return nil, err
+		}
+		if !installed {
+			missing = append(missing, rules[i])
+		}
+	}
+	return missing, nil
+}
+
+func (m *Manager) installed(rule *Rule) (bool, error) {
+	output, err := m.run("ip", rule.family(), "-j", "rule", "show", "pref", strconv.Itoa(rule.Priority))
+	if err != nil {
+		return false, fmt.Errorf("list rules: %w", err)
+	}
+	existing, err := parseRules(output)
+	if err != nil {
+		return false, err
+	}
+	for i := range existing {
+		if existing[i].matches(rule) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type ipRule struct {
+	Dst      string `json:"dst"`
+	FWMark   string `json:"fwmark"`
+	FWMask   string `json:"fwmask"`
+	Table    string `json:"table"`
+	Priority int    `json:"priority"`
+	DstLen   int    `json:"dstlen"`
+}
+
+func parseRules(output []byte) ([]ipRule, error) {
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 {
+		return nil, nil
+	}
+	var rules []ipRule
+	if err := json.Unmarshal(output, &rules); err != nil {
+		return nil, fmt.Errorf("parse ip rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (r *ipRule) matches(rule *Rule) bool {
+	if r.Priority != rule.Priority || r.Table != rule.Table {
+		return false
+	}
+
+	to := ""
+	if rule.To.IsValid() {
+		to = rule.To.String()
+	}
+	dst := ""
+	if r.Dst != "" && r.Dst != "all" {
+		dst = r.Dst
+		if r.DstLen > 0 {
+			dst += "/" + strconv.Itoa(r.DstLen)
+		}
+	}
+	if dst != to {
+		return false
+	}
+
+	mark := ""
+	if r.FWMark != "" {
+		mark = r.FWMark
+		if r.FWMask != "" {
+			mark += "/" + r.FWMask
+		}
+	}
+	return mark == rule.Mark
+}
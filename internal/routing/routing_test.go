@@ -0,0 +1,138 @@
+package routing
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type fakeRunner struct {
+	// shown maps "-4 200" style keys to the JSON ip rule show returns.
+	shown    map[string]string
+	failures map[string]bool
+	calls    []string
+}
+
+func (f *fakeRunner) run(name string, args ...string) ([]byte, error) {
+	cmd := strings.Join(append([]string{name}, args...), " ")
+	f.calls = append(f.calls, cmd)
+	if f.failures[cmd] {
+		return nil, errors.New("failed")
+	}
+	if len(args) > 2 && args[2] == "rule" && args[3] == "show" {
+		return []byte(f.shown[args[0]+" "+args[len(args)-1]]), nil
+	}
+	return nil, nil
+}
+
+func (f *fakeRunner) changes() []string {
+	var changes []string
+	for _, c := range f.calls {
+		if !strings.Contains(c, " show ") {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+func TestApplyAddsMissingRules(t *testing.T) {
+	fake := &fakeRunner{shown: map[string]string{
+		"-4 220": `[{"priority":220,"src":"all","table":"220"}]`,
+	}}
+	m := &Manager{run: fake.run}
+
+	if err := m.Apply(KernelRules()); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	expected := []string{
+		"ip -4 rule add pref 200 fwmark 0x80000/0xff0000 lookup main",
+		"ip -6 rule add pref 200 fwmark 0x80000/0xff0000 lookup main",
+		"ip -4 rule add pref 210 to 100.64.0.0/10 lookup 52",
+		"ip -6 rule add pref 210 to fd7a:115c:a1e0::/48 lookup 52",
+		"ip -6 rule add pref 220 lookup 220",
+	}
+	got := fake.changes()
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("commands =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestApplyIsIdempotent(t *testing.T) {
+	fake := &fakeRunner{shown: map[string]string{
+		"-4 200": `[{"priority":200,"src":"all","fwmark":"0x80000","fwmask":"0xff0000","table":"main"}]`,
+		"-6 200": `[{"priority":200,"src":"all","fwmark":"0x80000","fwmask":"0xff0000","table":"main"}]`,
+		"-4 210": `[{"priority":210,"src":"all","dst":"100.64.0.0","dstlen":10,"table":"52"}]`,
+		"-6 210": `[{"priority":210,"src":"all","dst":"fd7a:115c:a1e0::","dstlen":48,"table":"52"}]`,
+		"-4 220": `[{"priority":220,"src":"all","table":"220"}]`,
+		"-6 220": `[{"priority":220,"src":"all","table":"220"}]`,
+	}}
+	m := &Manager{run: fake.run}
+
+	if err := m.Apply(KernelRules()); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if changes := fake.changes(); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+
+	missing, err := m.Missing(KernelRules())
+	if err != nil {
+		t.Fatalf("Missing() error = %v", err)
+	}
+	if len(missing) != 0 {
+		t.Errorf("Missing() = %v, want none", missing)
+	}
+}
+
+func TestApplyIgnoresDifferentRuleAtSamePriority(t *testing.T) {
+	fake := &fakeRunner{shown: map[string]string{
+		"-4 210": `[{"priority":210,"src":"all","dst":"10.0.0.0","dstlen":8,"table":"52"}]`,
+	}}
+	m := &Manager{run: fake.run}
+
+	missing, err := m.Missing([]Rule{{Priority: 210, To: TailnetIPv4, Table: TailscaleTable}})
+	if err != nil {
+		t.Fatalf("Missing() error = %v", err)
+	}
+	if len(missing) != 1 {
+		t.Errorf("Missing() = %v, want the tailnet rule", missing)
+	}
+}
+
+func TestApplyReportsFailure(t *testing.T) {
+	fake := &fakeRunner{failures: map[string]bool{
+		"ip -4 rule add pref 200 fwmark 0x80000/0xff0000 lookup main": true,
+	}}
+	m := &Manager{run: fake.run}
+
+	err := m.Apply(KernelRules())
+	if err == nil || !strings.Contains(err.Error(), "pref 200") {
+		t.Errorf("Apply() error = %v, want failure naming the rule", err)
+	}
+}
+
+func TestRemoveDeletesAddedRules(t *testing.T) {
+	fake := &fakeRunner{shown: map[string]string{
+		"-4 220": `[{"priority":220,"src":"all","table":"220"}]`,
+	}}
+	m := &Manager{run: fake.run}
+	if err := m.Apply(KernelRules()); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	// charon's rule was there before Apply; of the added rules, only the
+	// IPv4 tailnet rule is still installed.
+	fake.calls = nil
+	fake.shown = map[string]string{
+		"-4 210": `[{"priority":210,"src":"all","dst":"100.64.0.0","dstlen":10,"table":"52"}]`,
+		"-4 220": `[{"priority":220,"src":"all","table":"220"}]`,
+	}
+	if err := m.Remove(KernelRules()); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	expected := []string{"ip -4 rule del pref 210 to 100.64.0.0/10 lookup 52"}
+	if got := fake.changes(); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("commands = %v, want %v", got, expected)
+	}
+}
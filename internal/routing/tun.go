@@ -0,0 +1,31 @@
+package routing
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const tunDevice = "/dev/net/tun"
+
+// ProbeTUN checks that a kernel TUN interface can be created, which needs
+// both the /dev/net/tun device node and CAP_NET_ADMIN. The probe interface
+// is not persistent and disappears when the descriptor is closed.
+func ProbeTUN() error {
+	f, err := os.OpenFile(tunDevice, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", tunDevice, err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	ifr, err := unix.NewIfreq("tsprobe%d")
+	if err != nil {
+		return err
+	}
+	ifr.SetUint16(unix.IFF_TUN | unix.IFF_NO_PI)
+	if err := unix.IoctlIfreq(int(f.Fd()), unix.TUNSETIFF, ifr); err != nil {
+		return fmt.Errorf("create TUN interface: %w", err)
+	}
+	return nil
+}
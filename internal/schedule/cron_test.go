@@ -0,0 +1,109 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func at(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"0 8 * *",
+		"60 8 * * *",
+		"0 24 * * *",
+		"0 8 0 * *",
+		"0 8 * 13 *",
+		"0 8 * * 8",
+		"0 8 * * fri-mon",
+		"*/0 8 * * *",
+		"0 8 * * funday",
+	}
+
+	for _, expr := range tests {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) should fail", expr)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	tests := []struct {
+		expr string
+		time string
+		want bool
+	}{
+		{expr: "0 8 * * mon-fri", time: "2026-10-19 08:00", want: true},
+		{expr: "0 8 * * mon-fri", time: "2026-10-18 08:00", want: false},
+		{expr: "0 8 * * MON-FRI", time: "2026-10-23 08:00", want: true},
+		{expr: "*/15 * * * *", time: "2026-10-18 13:45", want: true},
+		{expr: "*/15 * * * *", time: "2026-10-18 13:46", want: false},
+		{expr: "30 22 * * 7", time: "2026-10-18 22:30", want: true},
+		{expr: "0 0 1 jan,jul *", time: "2026-07-01 00:00", want: true},
+		{expr: "0 0 1-7 * 1", time: "2026-10-02 00:00", want: true},
+		{expr: "0 0 1-7 * 1", time: "2026-10-12 00:00", want: true},
+		{expr: "0 0 1-7 * 1", time: "2026-10-13 00:00", want: false},
+		{expr: "0 9-17/4 * * *", time: "2026-10-18 13:00", want: true},
+		{expr: "0 9-17/4 * * *", time: "2026-10-18 15:00", want: false},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) error = %v", tt.expr, err)
+		}
+		if got := c.Matches(at(tt.time)); got != tt.want {
+			t.Errorf("%q.Matches(%s) = %v, want %v", tt.expr, tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestCronNextPrev(t *testing.T) {
+	tests := []struct {
+		expr string
+		from string
+		next string
+		prev string
+	}{
+		{expr: "0 8 * * mon-fri", from: "2026-10-16 09:30", next: "2026-10-19 08:00", prev: "2026-10-16 08:00"},
+		{expr: "0 8 * * mon-fri", from: "2026-10-19 08:00", next: "2026-10-20 08:00", prev: "2026-10-19 08:00"},
+		{expr: "59 23 31 * *", from: "2026-10-31 23:59", next: "2026-12-31 23:59", prev: "2026-10-31 23:59"},
+		{expr: "0 0 29 feb *", from: "2026-03-01 00:00", next: "2028-02-29 00:00", prev: "2024-02-29 00:00"},
+		{expr: "*/20 * * * *", from: "2026-10-18 10:05", next: "2026-10-18 10:20", prev: "2026-10-18 10:00"},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) error = %v", tt.expr, err)
+		}
+		next, ok := c.Next(at(tt.from))
+		if !ok || !next.Equal(at(tt.next)) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.expr, tt.from, next, tt.next)
+		}
+		prev, ok := c.Prev(at(tt.from))
+		if !ok || !prev.Equal(at(tt.prev)) {
+			t.Errorf("%q.Prev(%s) = %s, want %s", tt.expr, tt.from, prev, tt.prev)
+		}
+	}
+}
+
+func TestCronNeverMatches(t *testing.T) {
+	c, err := ParseCron("0 0 30 feb *")
+	if err != nil {
+		t.Fatalf("ParseCron() error = %v", err)
+	}
+	if _, ok := c.Next(at("2026-01-01 00:00")); ok {
+		t.Error("Next() should give up on an impossible date")
+	}
+	if _, ok := c.Prev(at("2026-01-01 00:00")); ok {
+		t.Error("Prev() should give up on an impossible date")
+	}
+}
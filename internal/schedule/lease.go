@@ -0,0 +1,145 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/statefile"
+)
+
+const leasesFile = "leases.json"
+
+// MaxLease bounds how long a connection can be brought up on demand.
+const MaxLease = 7 * 24 * time.Hour
+
+// Lease keeps a connection up until it expires, regardless of its
+// schedule.
+type Lease struct {
+	Start      time.Time `json:"start"`
+	Until      time.Time `json:"until"`
+	Connection string    `json:"connection"`
+	Owner      string    `json:"owner,omitempty"`
+}
+
+// LeaseStore keeps the leases in the state directory, where the control
+// server and the CLI grant them and the supervisor's scheduler acts on
+// them.
+type LeaseStore struct {
+	path string
+}
+
+func NewLeaseStore(stateDir string) *LeaseStore {
+	return &LeaseStore{path: filepath.Join(stateDir, leasesFile)}
+}
+
+// Active returns the leases unexpired at now, sorted by connection.
+func (s *LeaseStore) Active(now time.Time) ([]Lease, error) {
+	leases, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return unexpired(leases, now), nil
+}
+
+// Grant brings the connection up for d from now, replacing any lease it
+// already has.
+func (s *LeaseStore) Grant(now time.Time, connection, owner string, d time.Duration) (Lease, error) {
+	if connection == "" {
+		return Lease{}, errors.New("connection name is required")
+	}
+	if d < time.Minute || d > MaxLease {
+		return Lease{}, fmt.Errorf("lease duration %s must be between 1m and %s", d, MaxLease)
+	}
+
+	now = now.UTC()
+	lease := Lease{
+		Connection: connection,
+		Owner:      owner,
+		Start:      now,
+		Until:      now.Add(d).Truncate(time.Second),
+	}
+	err := statefile.Locked(s.path, func() error {
+		leases, err := s.read()
+		if err != nil {
+			return err
+		}
+		kept := []Lease{lease}
+		for _, l := range unexpired(leases, now) {
+			if l.Connection != connection {
+				kept = append(kept, l)
+			}
+		}
+		return s.write(kept)
+	})
+	if err != nil {
+		return Lease{}, err
+	}
+	return lease, nil
+}
+
+// Cancel ends the connection's lease early. It reports whether there was
+// an unexpired one.
+func (s *LeaseStore) Cancel(now time.Time, connection string) (bool, error) {
+	found := false
+	err := statefile.Locked(s.path, func() error {
+		leases, err := s.read()
+		if err != nil {
+			return err
+		}
+		var kept []Lease
+		for _, l := range unexpired(leases, now) {
+			if l.Connection == connection {
+				found = true
+				continue
+			}
+			kept = append(kept, l)
+		}
+		if !found {
+			return nil
+		}
+		return s.write(kept)
+	})
+	return found, err
+}
+
+func unexpired(leases []Lease, now time.Time) []Lease {
+	result := []Lease{}
+	for _, l := range leases {
+		if now.Before(l.Until) {
+			result = append(result, l)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Connection < result[j].Connection })
+	return result
+}
+
+func (s *LeaseStore) read() ([]Lease, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(data) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var leases []Lease
+	if err := json.Unmarshal(data, &leases); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	return leases, nil
+}
+
+func (s *LeaseStore) write(leases []Lease) error {
+	if leases == nil {
+		leases = []Lease{}
+	}
+	data, err := json.Marshal(leases)
+	if err != nil {
+		return err
+	}
+	return statefile.WriteAtomic(s.path, data)
+}
@@ -0,0 +1,90 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+var leaseNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func TestLeaseStoreGrantAndExpire(t *testing.T) {
+	store := NewLeaseStore(t.TempDir())
+
+	active, err := store.Active(leaseNow)
+	if err != nil || len(active) != 0 {
+		t.Fatalf("Active() = %v, %v; want no leases", active, err)
+	}
+
+	lease, err := store.Grant(leaseNow, "partner-b", "alice@example.com", 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Grant() error = %v", err)
+	}
+	if !lease.Until.Equal(leaseNow.Add(30*time.Minute)) || lease.Owner != "alice@example.com" {
+		t.Errorf("unexpected lease %+v", lease)
+	}
+	if _, err := store.Grant(leaseNow, "partner-a", "cli", time.Hour); err != nil {
+		t.Fatalf("Grant() error = %v", err)
+	}
+
+	active, err = store.Active(leaseNow)
+	if err != nil || len(active) != 2 || active[0].Connection != "partner-a" {
+		t.Fatalf("Active() = %v, %v; want both leases sorted", active, err)
+	}
+
+	active, err = store.Active(leaseNow.Add(45 * time.Minute))
+	if err != nil || len(active) != 1 || active[0].Connection != "partner-a" {
+		t.Fatalf("Active() = %v, %v; want only the hour lease", active, err)
+	}
+}
+
+func TestLeaseStoreGrantReplaces(t *testing.T) {
+	store := NewLeaseStore(t.TempDir())
+
+	if _, err := store.Grant(leaseNow, "partner-a", "cli", time.Hour); err != nil {
+		t.Fatalf("Grant() error = %v", err)
+	}
+	if _, err := store.Grant(leaseNow, "partner-a", "cli", 10*time.Minute); err != nil {
+		t.Fatalf("Grant() error = %v", err)
+	}
+
+	active, err := store.Active(leaseNow)
+	if err != nil || len(active) != 1 || !active[0].Until.Equal(leaseNow.Add(10*time.Minute)) {
+		t.Fatalf("Active() = %v, %v; want the second lease only", active, err)
+	}
+}
+
+func TestLeaseStoreGrantValidates(t *testing.T) {
+	store := NewLeaseStore(t.TempDir())
+
+	if _, err := store.Grant(leaseNow, "", "cli", time.Hour); err == nil {
+		t.Error("Grant() without a connection should fail")
+	}
+	if _, err := store.Grant(leaseNow, "partner-a", "cli", 30*time.Second); err == nil {
+		t.Error("Grant() shorter than a minute should fail")
+	}
+	if _, err := store.Grant(leaseNow, "partner-a", "cli", MaxLease+time.Hour); err == nil {
+		t.Error("Grant() longer than MaxLease should fail")
+	}
+}
+
+func TestLeaseStoreCancel(t *testing.T) {
+	store := NewLeaseStore(t.TempDir())
+
+	if _, err := store.Grant(leaseNow, "partner-a", "cli", time.Hour); err != nil {
+		t.Fatalf("Grant() error = %v", err)
+	}
+
+	found, err := store.Cancel(leaseNow, "partner-a")
+	if err != nil || !found {
+		t.Fatalf("Cancel() = %v, %v; want true", found, err)
+	}
+	found, err = store.Cancel(leaseNow, "partner-a")
+	if err != nil || found {
+		t.Fatalf("second Cancel() = %v, %v; want false", found, err)
+	}
+
+	active, err := store.Active(leaseNow)
+	if err != nil || len(active) != 0 {
+		t.Fatalf("Active() = %v, %v; want no leases", active, err)
+	}
+}
@@ -0,0 +1,81 @@
+package schedule
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	ReasonSchedule = "schedule"
+	ReasonLease    = "lease"
+)
+
+// Transition is a change of the desired state.
+type Transition struct {
+	At    time.Time `json:"at"`
+	State string    `json:"state"`
+}
+
+// Status is the desired state of a scheduled or leased connection, why, and
+// when it next changes.
+type Status struct {
+	Next       *Transition `json:"next,omitempty"`
+	Lease      *Lease      `json:"lease,omitempty"`
+	Connection string      `json:"connection"`
+	State      string      `json:"state"`
+	Reason     string      `json:"reason"`
+	Scheduled  bool        `json:"scheduled"`
+}
+
+// Plan returns the desired state at now of every connection that has a
+// window or an active lease, sorted by connection. A lease keeps the
+// connection up past the end of its window; once it expires the window
+// decides again.
+func Plan(now time.Time, windows []Window, leases []Lease) []Status {
+	byConn := make(map[string]*Status)
+	windowOf := make(map[string]*Window)
+	for i := range windows {
+		w := &windows[i]
+		windowOf[w.Connection] = w
+		st := &Status{Connection: w.Connection, Scheduled: true, Reason: ReasonSchedule, State: StateDown}
+		if w.Active(now) {
+			st.State = StateUp
+		}
+		if next, ok := w.Next(now); ok {
+			st.Next = &next
+		}
+		byConn[w.Connection] = st
+	}
+
+	for i := range leases {
+		l := leases[i]
+		if !now.Before(l.Until) {
+			continue
+		}
+		st, ok := byConn[l.Connection]
+		if !ok {
+			st = &Status{Connection: l.Connection}
+			byConn[l.Connection] = st
+		}
+		st.State = StateUp
+		st.Reason = ReasonLease
+		st.Lease = &l
+		st.Next = &Transition{At: l.Until, State: StateDown}
+		if w := windowOf[l.Connection]; w != nil && w.Active(l.Until) {
+			// The window is open when the lease ends, so the connection
+			// stays up until the window closes.
+			if at, ok := w.Down.Next(l.Until); ok {
+				st.Next = &Transition{At: at, State: StateDown}
+			} else {
+				st.Next = nil
+			}
+		}
+	}
+
+	result := make([]Status, 0, len(byConn))
+	for _, st := range byConn {
+		result = append(result, *st)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Connection < result[j].Connection })
+	return result
+}
@@ -0,0 +1,119 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+const businessHours = "partner-a=0 8 * * mon-fri|0 18 * * mon-fri; maint = 0 22 * * sat | 0 2 * * sun"
+
+func testWindows(t *testing.T) []Window {
+	t.Helper()
+	windows, err := ParseWindows(businessHours)
+	if err != nil {
+		t.Fatalf("ParseWindows() error = %v", err)
+	}
+	return windows
+}
+
+func TestParseWindows(t *testing.T) {
+	windows := testWindows(t)
+	if len(windows) != 2 || windows[0].Connection != "partner-a" || windows[1].Connection != "maint" {
+		t.Fatalf("unexpected windows %+v", windows)
+	}
+	if windows[1].Up.String() != "0 22 * * sat" {
+		t.Errorf("up = %q", windows[1].Up)
+	}
+
+	for _, spec := range []string{
+		"partner-a",
+		"partner-a=0 8 * * *",
+		"=0 8 * * *|0 18 * * *",
+		"partner-a=0 8 * *|0 18 * * *",
+		"a=0 8 * * *|0 18 * * *;a=0 9 * * *|0 18 * * *",
+	} {
+		if _, err := ParseWindows(spec); err == nil {
+			t.Errorf("ParseWindows(%q) should fail", spec)
+		}
+	}
+}
+
+func TestWindowActiveAndNext(t *testing.T) {
+	partner := testWindows(t)[0]
+
+	tests := []struct {
+		now    string
+		next   string
+		state  string
+		active bool
+	}{
+		{now: "2026-10-19 07:59", active: false, next: "2026-10-19 08:00", state: StateUp},
+		{now: "2026-10-19 08:00", active: true, next: "2026-10-19 18:00", state: StateDown},
+		{now: "2026-10-19 17:59", active: true, next: "2026-10-19 18:00", state: StateDown},
+		{now: "2026-10-19 18:00", active: false, next: "2026-10-20 08:00", state: StateUp},
+		{now: "2026-10-17 12:00", active: false, next: "2026-10-19 08:00", state: StateUp},
+	}
+
+	for _, tt := range tests {
+		if got := partner.Active(at(tt.now)); got != tt.active {
+			t.Errorf("Active(%s) = %v, want %v", tt.now, got, tt.active)
+		}
+		next, ok := partner.Next(at(tt.now))
+		if !ok || !next.At.Equal(at(tt.next)) || next.State != tt.state {
+			t.Errorf("Next(%s) = %+v, want %s %s", tt.now, next, tt.state, tt.next)
+		}
+	}
+}
+
+func TestWindowSpanningMidnight(t *testing.T) {
+	maint := testWindows(t)[1]
+	if !maint.Active(at("2026-10-25 01:00")) {
+		t.Error("maintenance window should still be open early Sunday")
+	}
+	if maint.Active(at("2026-10-25 02:00")) {
+		t.Error("maintenance window should close at 02:00 Sunday")
+	}
+}
+
+func TestPlan(t *testing.T) {
+	now := at("2026-10-19 17:00")
+	leases := []Lease{
+		{Connection: "partner-a", Until: now.Add(2 * time.Hour)},
+		{Connection: "adhoc", Until: now.Add(30 * time.Minute)},
+		{Connection: "expired", Until: now.Add(-time.Minute)},
+	}
+
+	plan := Plan(now, testWindows(t), leases)
+	if len(plan) != 3 {
+		t.Fatalf("expected 3 connections, got %+v", plan)
+	}
+
+	adhoc, maint, partner := plan[0], plan[1], plan[2]
+	if adhoc.Connection != "adhoc" || adhoc.State != StateUp || adhoc.Reason != ReasonLease || adhoc.Scheduled {
+		t.Errorf("unexpected adhoc status %+v", adhoc)
+	}
+	if adhoc.Next == nil || !adhoc.Next.At.Equal(now.Add(30*time.Minute)) || adhoc.Next.State != StateDown {
+		t.Errorf("adhoc should go down when its lease ends, got %+v", adhoc.Next)
+	}
+
+	if maint.State != StateDown || maint.Reason != ReasonSchedule || maint.Next == nil || !maint.Next.At.Equal(at("2026-10-24 22:00")) {
+		t.Errorf("unexpected maint status %+v", maint)
+	}
+
+	if partner.State != StateUp || partner.Reason != ReasonLease || !partner.Scheduled || partner.Lease == nil {
+		t.Errorf("unexpected partner-a status %+v", partner)
+	}
+	if partner.Next == nil || !partner.Next.At.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("partner-a lease outlasts its window, got next %+v", partner.Next)
+	}
+}
+
+func TestPlanLeaseInsideWindow(t *testing.T) {
+	now := at("2026-10-19 09:00")
+	plan := Plan(now, testWindows(t), []Lease{{Connection: "partner-a", Until: now.Add(time.Hour)}})
+
+	partner := plan[1]
+	if partner.Next == nil || !partner.Next.At.Equal(at("2026-10-19 18:00")) || partner.Next.State != StateDown {
+		t.Errorf("the window keeps partner-a up after the lease, got next %+v", partner.Next)
+	}
+}
@@ -0,0 +1,86 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	StateUp   = "up"
+	StateDown = "down"
+)
+
+// Window keeps a connection up from each firing of Up until the next firing
+// of Down.
+type Window struct {
+	Up         *Cron
+	Down       *Cron
+	Connection string
+}
+
+// ParseWindows parses SWAN_SCHEDULES: entries separated by ";", each
+// "connection=<up cron>|<down cron>", for example
+// "partner-a=0 8 * * mon-fri|0 18 * * mon-fri".
+func ParseWindows(spec string) ([]Window, error) {
+	var windows []Window
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, crons, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("schedule %q: expected connection=<up cron>|<down cron>", entry)
+		}
+		upExpr, downExpr, ok := strings.Cut(crons, "|")
+		if !ok {
+			return nil, fmt.Errorf("schedule %s: expected <up cron>|<down cron>", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("schedule %s: defined more than once", name)
+		}
+		seen[name] = true
+
+		up, err := ParseCron(upExpr)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: up: %w", name, err)
+		}
+		down, err := ParseCron(downExpr)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: down: %w", name, err)
+		}
+		windows = append(windows, Window{Connection: name, Up: up, Down: down})
+	}
+	return windows, nil
+}
+
+// Active reports whether t falls inside the window, i.e. whether Up fired
+// more recently than Down. When both fire in the same minute Down wins.
+func (w *Window) Active(t time.Time) bool {
+	up, ok := w.Up.Prev(t)
+	if !ok {
+		return false
+	}
+	down, ok := w.Down.Prev(t)
+	return !ok || up.After(down)
+}
+
+// Next returns when the window next opens or closes after t.
+func (w *Window) Next(t time.Time) (Transition, bool) {
+	if w.Active(t) {
+		at, ok := w.Down.Next(t)
+		return Transition{At: at, State: StateDown}, ok
+	}
+	// An up firing in the same minute as a down firing does not open the
+	// window, so skip ahead to one that does.
+	limit := t.Add(searchLimit)
+	for at, ok := w.Up.Next(t); ok && at.Before(limit); at, ok = w.Up.Next(at) {
+		if !w.Down.Matches(at) {
+			return Transition{At: at, State: StateUp}, true
+		}
+	}
+	return Transition{}, false
+}
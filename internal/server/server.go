@@ -20,28 +20,54 @@ import (
 )
 
 type Server struct {
-	config        *config.Config
-	viciHandler   *handlers.VICIHandler
-	tsHandler     *handlers.TailscaleHandler
-	healthHandler *handlers.HealthHandler
-	broadcaster   *sse.EventBroadcaster
-	cancel        context.CancelFunc
-	mux           *http.ServeMux
-	tsnetServer   *tsnet.Server
-	tsnetListener net.Listener
+	config         *config.Config
+	viciHandler    *handlers.VICIHandler
+	tsHandler      *handlers.TailscaleHandler
+	healthHandler  *handlers.HealthHandler
+	haHandler      *handlers.HAHandler
+	schedHandler   *handlers.ScheduleHandler
+	certHandler    *handlers.CertHandler
+	pkiHandler     *handlers.PKIHandler
+	rwHandler      *handlers.RoadWarriorHandler
+	idHandler      *handlers.IdentityHandler
+	historyHandler *handlers.HistoryHandler
+	watchHandler   *handlers.ConfigWatchHandler
+	tunnelHealth   *handlers.TunnelHealthHandler
+	broadcaster    *sse.EventBroadcaster
+	cancel         context.CancelFunc
+	mux            *http.ServeMux
+	tsnetServer    *tsnet.Server
+	tsnetListener  net.Listener
 }
 
 func New(cfg *config.Config, webFS embed.FS) (*Server, error) {
-	viciHandler, err := handlers.NewVICIHandler(cfg.Swan.Connections)
+	tsHandler := handlers.NewTailscaleHandler()
+	fleetHandler := handlers.NewFleetHandler(cfg, tsHandler)
+
+	viciHandler, err := handlers.NewVICIHandler(cfg.Swan.Connections, fleetHandler.Caller)
 	if err != nil {
 		return nil, err
 	}
 
-	tsHandler := handlers.NewTailscaleHandler()
-	healthHandler := handlers.NewHealthHandler()
+	haHandler := handlers.NewHAHandler(cfg)
+	tunnelHealthHandler := handlers.NewTunnelHealthHandler(cfg)
+	healthHandler := handlers.NewHealthHandler(cfg, tsHandler, haHandler, tunnelHealthHandler)
+	metricsHandler := handlers.NewMetricsHandler(haHandler, tunnelHealthHandler)
 
 	broadcaster := sse.NewEventBroadcaster(viciHandler.Session(), tsHandler.LocalClient(), cfg.Swan.Connections)
 	sseHandler := handlers.NewSSEHandler(broadcaster)
+	diagHandler := handlers.NewDiagHandler(viciHandler.Session(), tsHandler, broadcaster)
+	supportHandler := handlers.NewSupportHandler(cfg, tsHandler)
+	scheduleHandler := handlers.NewScheduleHandler(cfg, fleetHandler.Caller)
+	certHandler := handlers.NewCertHandler(cfg, viciHandler.Session(), fleetHandler.Caller)
+	pkiHandler := handlers.NewPKIHandler(cfg, viciHandler.Session(), fleetHandler.Caller)
+	rwHandler := handlers.NewRoadWarriorHandler(cfg, viciHandler.Session(), pkiHandler, fleetHandler.Caller)
+	idHandler := handlers.NewIdentityHandler(cfg, viciHandler.Session())
+	peerHandler := handlers.NewPeerConfigHandler(cfg, viciHandler.Session())
+	historyHandler := handlers.NewHistoryHandler(cfg, viciHandler.Session(), tsHandler, fleetHandler.Caller)
+	templatesHandler := handlers.NewTemplatesHandler(cfg, viciHandler.Session(), historyHandler)
+	watchHandler := handlers.NewConfigWatchHandler(cfg, viciHandler.Session(), historyHandler)
+	dryRunHandler := handlers.NewDryRunHandler(cfg, viciHandler.Session())
 
 	mux := http.NewServeMux()
 
@@ -81,15 +107,44 @@ func New(cfg *config.Config, webFS embed.FS) (*Server, error) {
 		}
 	})
 
-	routes.RegisterRoutes(mux, viciHandler, tsHandler, healthHandler, sseHandler)
+	routes.RegisterRoutes(mux, &routes.Handlers{
+		VICI:      viciHandler,
+		Tailscale: tsHandler,
+		Health:    healthHandler,
+		SSE:       sseHandler,
+		Diag:      diagHandler,
+		Support:   supportHandler,
+		HA:        haHandler,
+		Metrics:   metricsHandler,
+		Fleet:     fleetHandler,
+		Schedule:  scheduleHandler,
+		Certs:     certHandler,
+		PKI:       pkiHandler,
+		RW:        rwHandler,
+		Identity:  idHandler,
+		Peer:      peerHandler,
+		Templates: templatesHandler,
+		History:   historyHandler,
+		Watch:     watchHandler,
+		DryRun:    dryRunHandler,
+	})
 
 	return &Server{
-		config:        cfg,
-		viciHandler:   viciHandler,
-		tsHandler:     tsHandler,
-		healthHandler: healthHandler,
-		broadcaster:   broadcaster,
-		mux:           mux,
+		config:         cfg,
+		viciHandler:    viciHandler,
+		tsHandler:      tsHandler,
+		healthHandler:  healthHandler,
+		haHandler:      haHandler,
+		schedHandler:   scheduleHandler,
+		certHandler:    certHandler,
+		pkiHandler:     pkiHandler,
+		rwHandler:      rwHandler,
+		idHandler:      idHandler,
+		historyHandler: historyHandler,
+		watchHandler:   watchHandler,
+		tunnelHealth:   tunnelHealthHandler,
+		broadcaster:    broadcaster,
+		mux:            mux,
 	}, nil
 }
 
@@ -98,6 +153,15 @@ func (s *Server) Start() error {
 	s.cancel = cancel
 
 	go s.broadcaster.Start(ctx)
+	go s.haHandler.Watch(ctx, s.broadcaster)
+	go s.schedHandler.Watch(ctx, s.broadcaster)
+	go s.tunnelHealth.Watch(ctx, s.broadcaster)
+	go s.certHandler.Watch(ctx, s.broadcaster)
+	go s.pkiHandler.Watch(ctx, s.broadcaster)
+	go s.rwHandler.Watch(ctx, s.broadcaster)
+	go s.idHandler.Watch(ctx, s.broadcaster)
+	go s.historyHandler.Watch(ctx, s.broadcaster)
+	go s.watchHandler.Watch(ctx, s.broadcaster)
 
 	addr := s.config.Address()
 	slog.Info("Starting TailSwan control server", "address", addr)
@@ -105,19 +169,76 @@ func (s *Server) Start() error {
 	slog.Info("")
 	slog.Info("API endpoints:")
 	slog.Info("  GET  /api/health                      - Health check")
+	slog.Info("  GET  /api/health/live                 - Liveness: charon and supervised processes")
+	slog.Info("  GET  /api/health/ready                - Readiness: all components, ?require_connections=a,b")
 	slog.Info("  GET  /api/events                      - Server-Sent Events stream")
+	slog.Info("  GET  /api/ha                          - High availability role")
+	slog.Info("  GET  /metrics                         - Prometheus metrics")
 	slog.Info("")
 	slog.Info("  VICI (strongSwan):")
 	slog.Info("    POST /api/vici/connections/up       - Bring connection up")
 	slog.Info("    POST /api/vici/connections/down     - Bring connection down")
 	slog.Info("    GET  /api/vici/connections/list     - List all connections")
 	slog.Info("    GET  /api/vici/sas/list             - List security associations")
+	slog.Info("    GET  /api/peer-config/{conn}        - Partner side of a connection (?format=&address=)")
+	slog.Info("    GET  /api/templates                 - Connection templates")
+	slog.Info("    POST /api/templates/{name}          - Render a template, and load it with load=true")
+	slog.Info("    GET  /api/config/history            - Configuration versions")
+	slog.Info("    GET  /api/config/history/{v}/diff   - Diff a version against the one before, or ?from=")
+	slog.Info("    POST /api/config/history/{v}/rollback - Roll back to a version")
+	slog.Info("    GET  /api/config/pending            - Change waiting to be confirmed")
+	slog.Info("    POST /api/config/confirm            - Keep the change on trial")
+	slog.Info("    POST /api/config/revert             - Revert the change on trial now")
+	slog.Info("    GET  /api/config/watch              - Last edit applied by the SWAN_WATCH watcher")
+	slog.Info("    GET  /api/config/dry-run            - What a reload would change in charon")
+	slog.Info("")
+	slog.Info("  Schedules:")
+	slog.Info("    GET  /api/schedules                 - Scheduled connections and next transitions")
+	slog.Info("    POST /api/schedules/leases          - Bring a connection up for N minutes")
+	slog.Info("    DELETE /api/schedules/leases        - Cancel a lease")
+	slog.Info("")
+	slog.Info("  Certificates:")
+	slog.Info("    GET  /api/certs                     - Certificates, CAs, CRLs and keys with expiry")
+	slog.Info("    GET  /api/certs/{kind}/{name}       - Inspect a credential")
+	slog.Info("    PUT  /api/certs/{kind}/{name}       - Store a PEM or DER file and load it into charon")
+	slog.Info("    DELETE /api/certs/{kind}/{name}     - Delete a credential and reload charon's")
+	slog.Info("")
+	slog.Info("  Built-in CA:")
+	slog.Info("    GET  /api/pki                       - CA and issued certificates")
+	slog.Info("    GET  /api/pki/ca.pem                - CA certificate")
+	slog.Info("    GET  /api/pki/crl.pem               - Current CRL")
+	slog.Info("    POST /api/pki/certs                 - Issue a server or client certificate")
+	slog.Info("    POST /api/pki/certs/{name}/revoke   - Revoke a certificate and load the CRL")
+	slog.Info("    POST /api/pki/certs/{name}/export   - Export as PKCS#12 or PEM")
+	slog.Info("")
+	slog.Info("  Road-warriors:")
+	slog.Info("    GET  /api/roadwarrior               - Users, pools and leases")
+	slog.Info("    POST /api/roadwarrior/users         - Add an EAP-MSCHAPv2 or EAP-TLS user")
+	slog.Info("    DELETE /api/roadwarrior/users/{name} - Delete a user")
+	slog.Info("    POST /api/roadwarrior/users/{name}/profile - Client profile (mobileconfig, sswan, ps1)")
+	slog.Info("    POST /api/roadwarrior/pools         - Add or replace a virtual IP pool")
+	slog.Info("    DELETE /api/roadwarrior/pools/{name} - Delete a pool")
+	slog.Info("    GET  /api/identities                - IPsec client identities and their virtual IPs")
 	slog.Info("")
 	slog.Info("  Tailscale:")
 	slog.Info("    GET  /api/tailscale/status          - Tailscale status")
 	slog.Info("    GET  /api/tailscale/peers           - List all peers")
 	slog.Info("    GET  /api/tailscale/serve           - Tailscale Serve configuration")
 	slog.Info("    GET  /api/tailscale/whois           - WhoIs lookup")
+	slog.Info("")
+	slog.Info("  Diagnostics (results stream as SSE diag-progress/diag-result):")
+	slog.Info("    POST /api/diag/ping                 - Ping a host through a CHILD_SA")
+	slog.Info("    POST /api/diag/tcp                  - TCP connect through a CHILD_SA")
+	slog.Info("    POST /api/diag/tailscale-ping       - Ping a tailnet peer")
+	slog.Info("    POST /api/diag/xfrm                 - Check a peer's traffic hits an XFRM policy")
+	slog.Info("    POST /api/diag/mtu                  - Calculate and probe CHILD_SA MTU")
+	slog.Info("    GET  /api/diag/results              - Recent diagnostic results")
+	slog.Info("")
+	slog.Info("  GET  /api/support-bundle              - Redacted troubleshooting tar.gz")
+	slog.Info("")
+	slog.Info("  Fleet:")
+	slog.Info("    GET  /api/fleet                     - Health and tunnels of all gateways")
+	slog.Info("    *    /api/fleet/proxy/{gateway}/... - Forward an /api request to a gateway")
 
 	server := &http.Server{
 		Addr:              addr,
@@ -135,6 +256,15 @@ func (s *Server) StartWithTsnet(hostname, authKey string, advertiseRoutes []stri
 	s.cancel = cancel
 
 	go s.broadcaster.Start(ctx)
+	go s.haHandler.Watch(ctx, s.broadcaster)
+	go s.schedHandler.Watch(ctx, s.broadcaster)
+	go s.tunnelHealth.Watch(ctx, s.broadcaster)
+	go s.certHandler.Watch(ctx, s.broadcaster)
+	go s.pkiHandler.Watch(ctx, s.broadcaster)
+	go s.rwHandler.Watch(ctx, s.broadcaster)
+	go s.idHandler.Watch(ctx, s.broadcaster)
+	go s.historyHandler.Watch(ctx, s.broadcaster)
+	go s.watchHandler.Watch(ctx, s.broadcaster)
 
 	s.tsnetServer = &tsnet.Server{
 		Hostname:  hostname,
@@ -179,19 +309,76 @@ func (s *Server) StartWithTsnet(hostname, authKey string, advertiseRoutes []stri
 	slog.Info("")
 	slog.Info("API endpoints:")
 	slog.Info("  GET  /api/health                      - Health check")
+	slog.Info("  GET  /api/health/live                 - Liveness: charon and supervised processes")
+	slog.Info("  GET  /api/health/ready                - Readiness: all components, ?require_connections=a,b")
 	slog.Info("  GET  /api/events                      - Server-Sent Events stream")
+	slog.Info("  GET  /api/ha                          - High availability role")
+	slog.Info("  GET  /metrics                         - Prometheus metrics")
 	slog.Info("")
 	slog.Info("  VICI (strongSwan):")
 	slog.Info("    POST /api/vici/connections/up       - Bring connection up")
 	slog.Info("    POST /api/vici/connections/down     - Bring connection down")
 	slog.Info("    GET  /api/vici/connections/list     - List all connections")
 	slog.Info("    GET  /api/vici/sas/list             - List security associations")
+	slog.Info("    GET  /api/peer-config/{conn}        - Partner side of a connection (?format=&address=)")
+	slog.Info("    GET  /api/templates                 - Connection templates")
+	slog.Info("    POST /api/templates/{name}          - Render a template, and load it with load=true")
+	slog.Info("    GET  /api/config/history            - Configuration versions")
+	slog.Info("    GET  /api/config/history/{v}/diff   - Diff a version against the one before, or ?from=")
+	slog.Info("    POST /api/config/history/{v}/rollback - Roll back to a version")
+	slog.Info("    GET  /api/config/pending            - Change waiting to be confirmed")
+	slog.Info("    POST /api/config/confirm            - Keep the change on trial")
+	slog.Info("    POST /api/config/revert             - Revert the change on trial now")
+	slog.Info("    GET  /api/config/watch              - Last edit applied by the SWAN_WATCH watcher")
+	slog.Info("    GET  /api/config/dry-run            - What a reload would change in charon")
+	slog.Info("")
+	slog.Info("  Schedules:")
+	slog.Info("    GET  /api/schedules                 - Scheduled connections and next transitions")
+	slog.Info("    POST /api/schedules/leases          - Bring a connection up for N minutes")
+	slog.Info("    DELETE /api/schedules/leases        - Cancel a lease")
+	slog.Info("")
+	slog.Info("  Certificates:")
+	slog.Info("    GET  /api/certs                     - Certificates, CAs, CRLs and keys with expiry")
+	slog.Info("    GET  /api/certs/{kind}/{name}       - Inspect a credential")
+	slog.Info("    PUT  /api/certs/{kind}/{name}       - Store a PEM or DER file and load it into charon")
+	slog.Info("    DELETE /api/certs/{kind}/{name}     - Delete a credential and reload charon's")
+	slog.Info("")
+	slog.Info("  Built-in CA:")
+	slog.Info("    GET  /api/pki                       - CA and issued certificates")
+	slog.Info("    GET  /api/pki/ca.pem                - CA certificate")
+	slog.Info("    GET  /api/pki/crl.pem               - Current CRL")
+	slog.Info("    POST /api/pki/certs                 - Issue a server or client certificate")
+	slog.Info("    POST /api/pki/certs/{name}/revoke   - Revoke a certificate and load the CRL")
+	slog.Info("    POST /api/pki/certs/{name}/export   - Export as PKCS#12 or PEM")
+	slog.Info("")
+	slog.Info("  Road-warriors:")
+	slog.Info("    GET  /api/roadwarrior               - Users, pools and leases")
+	slog.Info("    POST /api/roadwarrior/users         - Add an EAP-MSCHAPv2 or EAP-TLS user")
+	slog.Info("    DELETE /api/roadwarrior/users/{name} - Delete a user")
+	slog.Info("    POST /api/roadwarrior/users/{name}/profile - Client profile (mobileconfig, sswan, ps1)")
+	slog.Info("    POST /api/roadwarrior/pools         - Add or replace a virtual IP pool")
+	slog.Info("    DELETE /api/roadwarrior/pools/{name} - Delete a pool")
+	slog.Info("    GET  /api/identities                - IPsec client identities and their virtual IPs")
 	slog.Info("")
 	slog.Info("  Tailscale:")
 	slog.Info("    GET  /api/tailscale/status          - Tailscale status")
 	slog.Info("    GET  /api/tailscale/peers           - List all peers")
 	slog.Info("    GET  /api/tailscale/serve           - Tailscale Serve configuration")
 	slog.Info("    GET  /api/tailscale/whois           - WhoIs lookup")
+	slog.Info("")
+	slog.Info("  Diagnostics (results stream as SSE diag-progress/diag-result):")
+	slog.Info("    POST /api/diag/ping                 - Ping a host through a CHILD_SA")
+	slog.Info("    POST /api/diag/tcp                  - TCP connect through a CHILD_SA")
+	slog.Info("    POST /api/diag/tailscale-ping       - Ping a tailnet peer")
+	slog.Info("    POST /api/diag/xfrm                 - Check a peer's traffic hits an XFRM policy")
+	slog.Info("    POST /api/diag/mtu                  - Calculate and probe CHILD_SA MTU")
+	slog.Info("    GET  /api/diag/results              - Recent diagnostic results")
+	slog.Info("")
+	slog.Info("  GET  /api/support-bundle              - Redacted troubleshooting tar.gz")
+	slog.Info("")
+	slog.Info("  Fleet:")
+	slog.Info("    GET  /api/fleet                     - Health and tunnels of all gateways")
+	slog.Info("    *    /api/fleet/proxy/{gateway}/... - Forward an /api request to a gateway")
 
 	go func() {
 		slog.Info("Starting tsnet HTTPS server on :443...")
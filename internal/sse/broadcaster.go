@@ -81,6 +81,16 @@ func (eb *EventBroadcaster) UnregisterClient(ch chan models.SSEMessage) {
 	}
 }
 
+// Publish sends v as JSON to all connected clients under the given event name.
+func (eb *EventBroadcaster) Publish(event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Failed to marshal SSE event", "event", event, "error", err)
+		return
+	}
+	eb.broadcast(models.SSEMessage{Event: event, Data: data})
+}
+
 func (eb *EventBroadcaster) broadcast(msg models.SSEMessage) {
 	eb.clientsMux.RLock()
 	defer eb.clientsMux.RUnlock()
@@ -193,11 +203,7 @@ func (eb *EventBroadcaster) fetchSAs() map[string]interface{} {
 			slog.Info("Error fetching SAs", "error", err)
 			return map[string]interface{}{"success": false, "sas": []map[string]interface{}{}}
 		}
-		saMap := make(map[string]interface{})
-		for _, key := range m.Keys() {
-			saMap[key] = m.Get(key)
-		}
-		sas = append(sas, saMap)
+		sas = append(sas, viciconn.Map(m))
 	}
 
 	return map[string]interface{}{
@@ -0,0 +1,186 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/bgp"
+	"github.com/klowdo/tailswan/internal/confighistory"
+)
+
+const bgpSyncInterval = 15 * time.Second
+
+type BGPConfig struct {
+	ASN             string
+	RouterID        string
+	Neighbors       []string
+	ImportFilter    []string
+	Enabled         bool
+	AnnounceTailnet bool
+}
+
+type bgpSpeaker struct {
+	config *bgp.Config
+	client *bgp.Client
+	// states and rejected remember what was logged, so only changes are.
+	states   map[netip.Addr]string
+	rejected map[netip.Prefix]bool
+	// static are the TS_ROUTES, which stay advertised whatever BGP learns.
+	static []netip.Prefix
+}
+
+// setupBGP writes the gobgpd configuration and starts the speaker. Learned
+// prefixes are picked up by syncBGPLoop.
+func (s *Supervisor) setupBGP() error {
+	cfg, err := bgp.NewConfig(s.config.BGP.ASN, s.config.BGP.RouterID, s.config.BGP.Neighbors, s.config.BGP.ImportFilter)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Filter) == 0 {
+		slog.Warn("BGP_IMPORT_FILTER is empty, no learned prefixes will be advertised to the tailnet")
+	}
+
+	static, err := parseRoutes(s.config.TailscaleConfig.Routes)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.config.StateDir, 0o750); err != nil {
+		return err
+	}
+	path := filepath.Join(s.config.StateDir, "gobgpd.toml")
+	if err := os.WriteFile(path, []byte(cfg.Render()), 0o600); err != nil {
+		return fmt.Errorf("write gobgpd config: %w", err)
+	}
+
+	s.bgp = &bgpSpeaker{
+		config:   cfg,
+		client:   bgp.NewClient(),
+		states:   make(map[netip.Addr]string),
+		rejected: make(map[netip.Prefix]bool),
+		static:   static,
+	}
+
+	slog.Info("Starting gobgpd", "asn", cfg.ASN, "router_id", cfg.RouterID, "neighbors", len(cfg.Neighbors))
+	return s.bgpd.Start("gobgpd",
+		"-f", path,
+		"-t", "toml",
+		"--api-hosts", bgp.APIHost+":"+bgp.APIPort,
+		"--log-plain",
+	)
+}
+
+func (s *Supervisor) syncBGPLoop(ctx context.Context) {
+	ticker := time.NewTicker(bgpSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.SyncBGP(ctx); err != nil {
+				slog.Warn("BGP sync failed", "error", err)
+			}
+		}
+	}
+}
+
+// SyncBGP pushes the prefixes learned from the neighbors into the kernel
+// routing table and the Tailscale advertised routes, and optionally
+// announces the tailnet subnets back to the neighbors.
+func (s *Supervisor) SyncBGP(ctx context.Context) error {
+	if s.bgp == nil {
+		return fmt.Errorf("BGP not enabled")
+	}
+
+	s.logNeighborStates(ctx)
+
+	paths, err := s.bgp.client.RIB(ctx)
+	if err != nil {
+		return err
+	}
+
+	imported, rejected := bgp.Imported(s.bgp.config, paths)
+	for _, p := range rejected {
+		if !s.bgp.rejected[p] {
+			slog.Warn("Learned prefix rejected by BGP_IMPORT_FILTER", "prefix", p)
+			s.bgp.rejected[p] = true
+		}
+	}
+
+	if err := bgp.SyncKernelRoutes(imported); err != nil {
+		slog.Warn("Failed to sync BGP routes into the kernel", "error", err)
+	}
+
+	routes := bgp.AdvertiseRoutes(s.bgp.static, imported)
+	if !s.isLeader() {
+		routes = nil
+	}
+	changed, err := s.tsService.SetAdvertiseRoutes(ctx, routes)
+	if err != nil {
+		return err
+	}
+	if changed {
+		slog.Info("Updated advertised routes from BGP", "routes", routes, "learned", len(imported))
+		s.recordConfig(ctx, confighistory.SourceRoutes, "Advertised routes learned over BGP")
+	}
+
+	if s.config.BGP.AnnounceTailnet {
+		return s.announceTailnet(ctx, paths, imported)
+	}
+	return nil
+}
+
+func parseRoutes(routes []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(routes))
+	for _, r := range routes {
+		p, err := netip.ParsePrefix(r)
+		if err != nil {
+			return nil, fmt.Errorf("TS_ROUTES: %w", err)
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes, nil
+}
+
+func (s *Supervisor) logNeighborStates(ctx context.Context) {
+	states, err := s.bgp.client.Neighbors(ctx)
+	if err != nil {
+		slog.Warn("Failed to list BGP neighbors", "error", err)
+		return
+	}
+	for addr, state := range states {
+		if s.bgp.states[addr] != state {
+			slog.Info("BGP neighbor state changed", "neighbor", addr, "state", state)
+			s.bgp.states[addr] = state
+		}
+	}
+}
+
+func (s *Supervisor) announceTailnet(ctx context.Context, paths, imported []bgp.Path) error {
+	status, err := s.tsService.client.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("tailscale status: %w", err)
+	}
+
+	add, remove := bgp.Diff(bgp.Local(paths), bgp.TailnetSubnets(status, imported))
+	for _, p := range add {
+		if err := s.bgp.client.Announce(ctx, p); err != nil {
+			return fmt.Errorf("announce %s: %w", p, err)
+		}
+		slog.Info("Announcing tailnet subnet over BGP", "prefix", p)
+	}
+	for _, p := range remove {
+		if err := s.bgp.client.Withdraw(ctx, p); err != nil {
+			return fmt.Errorf("withdraw %s: %w", p, err)
+		}
+		slog.Info("Withdrew tailnet subnet from BGP", "prefix", p)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package supervisor
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/klowdo/tailswan/internal/confighistory"
+)
+
+// supervisorAuthor is the author of the changes TailSwan makes itself.
+var supervisorAuthor = confighistory.Author{Name: "tailswan"}
+
+// recordConfig records the swanctl configuration and the advertised routes
+// in the configuration history. With tsnet the routes belong to the
+// control server, which records its own changes.
+func (s *Supervisor) recordConfig(ctx context.Context, source, message string) {
+	if s.configs == nil || s.config.SwanConfigPath == "" {
+		return
+	}
+	var routes []string
+	if !s.config.UseTsnet {
+		if prefixes, err := s.tsService.AdvertisedRoutes(ctx); err == nil {
+			routes = confighistory.Routes(prefixes)
+		}
+	}
+	snap, err := s.configs.RecordConfig(s.config.SwanConfigPath, routes, supervisorAuthor, source, message)
+	if err != nil {
+		slog.Warn("Failed to record the configuration", "error", err)
+		return
+	}
+	if snap != nil {
+		slog.Info("Recorded configuration version", "version", snap.Version, "source", source)
+	}
+}
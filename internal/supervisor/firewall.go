@@ -0,0 +1,208 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/firewall"
+	"github.com/klowdo/tailswan/internal/identity"
+	"github.com/klowdo/tailswan/internal/mtu"
+	"github.com/klowdo/tailswan/internal/viciconn"
+	"github.com/klowdo/tailswan/internal/xfrmif"
+)
+
+const (
+	firewallReconcileInterval = 30 * time.Second
+	identityResubscribeDelay  = 5 * time.Second
+)
+
+type FirewallConfig struct {
+	Backend string
+	// Access is the RW_ACCESS policy limiting the tailnet destinations of
+	// IPsec clients by identity.
+	Access        string
+	Masquerade    bool
+	MSSClamp      bool
+	DropUnmatched bool
+}
+
+func (s *Supervisor) setupFirewall() error {
+	backend, err := firewall.NewBackend(s.config.Firewall.Backend)
+	if err != nil {
+		return err
+	}
+	s.access, err = identity.ParsePolicy(s.config.Firewall.Access)
+	if err != nil {
+		return fmt.Errorf("RW_ACCESS: %w", err)
+	}
+	s.firewall = firewall.NewManager(backend)
+
+	if err := s.ReconcileFirewall(); err != nil {
+		// charon may still be starting. reconcileFirewallLoop adds the
+		// rules for its connections once it answers.
+		slog.Warn("Cannot read charon's connections yet, installing the base firewall rules", "error", err)
+		s.reconcileMu.Lock()
+		defer s.reconcileMu.Unlock()
+		return s.firewall.Reconcile(BuildRuleSet(&s.config.Firewall, nil, nil, nil))
+	}
+	return nil
+}
+
+// ReconcileFirewall rebuilds the declared rule set from the connections
+// charon currently has loaded and the MTUs of their established CHILD_SAs,
+// and applies it. XFRM interfaces are reconciled first so route-based
+// children can be matched by interface. It is safe for concurrent use.
+func (s *Supervisor) ReconcileFirewall() error {
+	if s.firewall == nil {
+		return fmt.Errorf("firewall not initialized")
+	}
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+
+	session, err := vici.NewSession()
+	if err != nil {
+		return fmt.Errorf("connect to charon: %w", err)
+	}
+	defer session.Close() //nolint:errcheck
+
+	children, err := viciconn.Children(session)
+	if err != nil {
+		return fmt.Errorf("list connections: %w", err)
+	}
+	sas, err := viciconn.ChildSAs(session)
+	if err != nil {
+		return fmt.Errorf("list SAs: %w", err)
+	}
+
+	ifaces := s.reconcileInterfaces(children)
+	rs := BuildRuleSet(&s.config.Firewall, children, ChildMTUs(sas), ifaces)
+	if len(s.access) > 0 {
+		if err := s.identityRules(session, rs); err != nil {
+			return err
+		}
+	}
+	return s.firewall.Reconcile(rs)
+}
+
+// identityRules adds the access policy's rules for the IPsec clients that
+// currently hold virtual IPs.
+func (s *Supervisor) identityRules(session *vici.Session, rs *firewall.RuleSet) error {
+	ctx := context.Background()
+	ids, err := viciconn.IKEIdentities(ctx, session)
+	if err != nil {
+		return fmt.Errorf("list IKE_SAs: %w", err)
+	}
+	pools, err := viciconn.Pools(ctx, session)
+	if err != nil {
+		return fmt.Errorf("list pools: %w", err)
+	}
+	rs.Identities = s.access.FirewallRules(identity.Mappings(ids, time.Now()))
+	rs.VirtualIPPools = identity.PoolPrefixes(pools)
+	return nil
+}
+
+// identityLoop reconciles the firewall whenever an IKE_SA comes up or goes
+// down, so a client's rules follow its virtual IP without waiting for the
+// next pass.
+func (s *Supervisor) identityLoop(ctx context.Context) {
+	for {
+		if err := s.watchIdentities(ctx); err != nil {
+			slog.Warn("Lost ike-updown events, resubscribing", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(identityResubscribeDelay):
+		}
+	}
+}
+
+func (s *Supervisor) watchIdentities(ctx context.Context) error {
+	session, err := vici.NewSession()
+	if err != nil {
+		return fmt.Errorf("connect to charon: %w", err)
+	}
+	defer session.Close() //nolint:errcheck
+
+	return viciconn.WatchIKEUpdown(ctx, session, func(ids []viciconn.IKEIdentity, up bool) {
+		for i := range ids {
+			slog.Info("IKE_SA changed", "identity", ids[i].Identity(), "virtual_ips", ids[i].VirtualIPs, "up", up)
+		}
+		if err := s.ReconcileFirewall(); err != nil {
+			slog.Warn("Firewall reconcile failed", "error", err)
+		}
+	})
+}
+
+// reconcileFirewallLoop picks up CHILD_SAs that were established or rekeyed
+// with different proposals since the last pass, so their MSS clamp follows.
+func (s *Supervisor) reconcileFirewallLoop(ctx context.Context) {
+	ticker := time.NewTicker(firewallReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ReconcileFirewall(); err != nil {
+				slog.Warn("Firewall reconcile failed", "error", err)
+			}
+		}
+	}
+}
+
+// ChildMTUs returns the tunnel MTU of every installed CHILD_SA keyed by
+// "connection/child". When a child has several SAs the smallest MTU wins.
+func ChildMTUs(sas []viciconn.ChildSA) map[string]int {
+	mtus := make(map[string]int)
+	for i := range sas {
+		sa := &sas[i]
+		if sa.State != "INSTALLED" {
+			continue
+		}
+		result := mtu.ForChildSA(sa)
+		key := sa.IKE + "/" + sa.Name
+		if existing, ok := mtus[key]; !ok || result.TunnelMTU < existing {
+			mtus[key] = result.TunnelMTU
+		}
+	}
+	return mtus
+}
+
+// BuildRuleSet declares forwarding for every child with remote subnets.
+// ifaces maps connections to their XFRM interface; children bound to it are
+// matched by interface instead of by IPsec policy.
+func BuildRuleSet(cfg *FirewallConfig, children []viciconn.Child, mtus map[string]int, ifaces map[string]xfrmif.Interface) *firewall.RuleSet {
+	rs := &firewall.RuleSet{
+		TailscaleIface: firewall.DefaultTailscaleIface,
+		MSSClamp:       cfg.MSSClamp,
+		DropUnmatched:  cfg.DropUnmatched,
+	}
+	if cfg.Masquerade {
+		rs.MasqueradeIface = firewall.DefaultTailscaleIface
+	}
+
+	for _, child := range children {
+		remote := viciconn.Prefixes(child.RemoteTS)
+		if len(remote) == 0 {
+			continue
+		}
+		rule := firewall.ForwardRule{
+			Connection:    child.Connection,
+			Child:         child.Name,
+			RemoteSubnets: remote,
+			MTU:           mtus[child.Connection+"/"+child.Name],
+		}
+		if iface, ok := ifaces[child.Connection]; ok && child.IfIDIn == iface.IfID {
+			rule.IPsecIface = iface.Name
+		}
+		rs.Forward = append(rs.Forward, rule)
+	}
+
+	return rs
+}
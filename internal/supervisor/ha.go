@@ -0,0 +1,245 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/confighistory"
+	"github.com/klowdo/tailswan/internal/ha"
+)
+
+type HAConfig struct {
+	Mode      string
+	NodeID    string
+	Peer      string
+	LeaseFile string
+	Priority  string
+	LeaseTTL  string
+}
+
+type haMember struct {
+	elector ha.Elector
+	// lastErr remembers the last campaign error, so only changes are logged.
+	lastErr string
+	// static are the TS_ROUTES, advertised only while leading.
+	static []netip.Prefix
+	state  ha.State
+	ttl    time.Duration
+	mu     sync.Mutex
+	// resigned stops further campaigns once shutdown has handed over.
+	resigned bool
+}
+
+func (s *Supervisor) haConfigured() bool {
+	return s.config.HA.Mode != "" && s.config.HA.Mode != ha.ModeOff
+}
+
+func (s *Supervisor) haEnabled() bool {
+	return s.haConfigured() && !s.config.UseTsnet
+}
+
+// isLeader reports whether this instance should run the tunnels and
+// advertise routes. Without HA it always should.
+func (s *Supervisor) isLeader() bool {
+	return s.ha == nil || s.leader.Load()
+}
+
+// setupHA creates the elector and publishes the initial standby state.
+// Leadership is decided by haLoop.
+func (s *Supervisor) setupHA() error {
+	cfg := s.config.HA
+
+	ttl, err := time.ParseDuration(cfg.LeaseTTL)
+	if err != nil || ttl < 3*time.Second {
+		return fmt.Errorf("HA_LEASE_TTL %q must be a duration of at least 3s", cfg.LeaseTTL)
+	}
+	priority, err := strconv.Atoi(cfg.Priority)
+	if err != nil {
+		return fmt.Errorf("HA_PRIORITY %q: %w", cfg.Priority, err)
+	}
+	if cfg.NodeID == "" {
+		return fmt.Errorf("HA_NODE_ID is empty")
+	}
+	static, err := parseRoutes(s.config.TailscaleConfig.Routes)
+	if err != nil {
+		return err
+	}
+
+	var elector ha.Elector
+	switch cfg.Mode {
+	case ha.ModeLease:
+		if cfg.LeaseFile == "" {
+			return fmt.Errorf("HA_MODE=lease needs HA_LEASE_FILE")
+		}
+		elector = ha.NewLeaseElector(cfg.LeaseFile, cfg.NodeID, ttl)
+	case ha.ModeTailnet:
+		if cfg.Peer == "" {
+			return fmt.Errorf("HA_MODE=tailnet needs HA_PEER")
+		}
+		elector = ha.NewPeerElector(cfg.Peer, cfg.NodeID, priority, ttl, s.tailnetHTTPClient())
+	default:
+		return fmt.Errorf("unknown HA_MODE %q (want off, lease or tailnet)", cfg.Mode)
+	}
+
+	s.ha = &haMember{
+		elector: elector,
+		static:  static,
+		ttl:     ttl,
+		state: ha.State{
+			Mode:     cfg.Mode,
+			NodeID:   cfg.NodeID,
+			Role:     ha.RoleStandby,
+			Priority: priority,
+			Since:    time.Now().UTC(),
+		},
+	}
+	slog.Info("High availability enabled, starting as standby", "mode", cfg.Mode, "node_id", cfg.NodeID, "priority", priority)
+	return s.publishHA()
+}
+
+// tailnetHTTPClient dials through tailscaled, which also works when it runs
+// with userspace networking and the tailnet is not routable from the host.
+func (s *Supervisor) tailnetHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				host, portStr, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				port, err := strconv.ParseUint(portStr, 10, 16)
+				if err != nil {
+					return nil, err
+				}
+				return s.tsService.client.DialTCP(ctx, host, uint16(port))
+			},
+		},
+	}
+}
+
+func (s *Supervisor) haLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.ha.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		s.campaign(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Supervisor) campaign(ctx context.Context) {
+	s.ha.mu.Lock()
+	resigned := s.ha.resigned
+	s.ha.mu.Unlock()
+	if resigned {
+		return
+	}
+
+	leader, err := s.ha.elector.Campaign(ctx)
+
+	s.ha.mu.Lock()
+	errText := ""
+	if err != nil {
+		errText = err.Error()
+	}
+	if errText != s.ha.lastErr {
+		if err != nil {
+			slog.Warn("HA election failed", "error", err)
+		} else {
+			slog.Info("HA election recovered")
+		}
+		s.ha.lastErr = errText
+	}
+	s.ha.mu.Unlock()
+
+	if leader != s.leader.Load() {
+		s.transition(ctx, leader)
+	}
+	if err := s.publishHA(); err != nil {
+		slog.Warn("Failed to write HA state", "error", err)
+	}
+}
+
+// transition takes over or hands off the tunnels and subnet routes. On
+// losing leadership the routes are withdrawn first, so the tailnet moves to
+// the new leader before its tunnels are torn down here.
+func (s *Supervisor) transition(ctx context.Context, leader bool) {
+	s.leader.Store(leader)
+
+	s.ha.mu.Lock()
+	s.ha.state.Transitions++
+	s.ha.state.Since = time.Now().UTC()
+	if leader {
+		s.ha.state.Role = ha.RoleLeader
+	} else {
+		s.ha.state.Role = ha.RoleStandby
+	}
+	s.ha.mu.Unlock()
+
+	if leader {
+		slog.Info("Became HA leader, initiating connections and advertising routes")
+		s.initiateConnections()
+		s.advertiseRoutes(ctx, s.ha.static)
+		return
+	}
+
+	slog.Warn("Lost HA leadership, withdrawing routes and terminating connections")
+	s.advertiseRoutes(ctx, nil)
+	for _, conn := range s.config.SwanConnections {
+		if err := s.swanService.Terminate(conn); err != nil {
+			slog.Warn("Failed to terminate connection", "connection", conn, "error", err)
+		}
+	}
+}
+
+func (s *Supervisor) advertiseRoutes(ctx context.Context, routes []netip.Prefix) {
+	changed, err := s.tsService.SetAdvertiseRoutes(ctx, routes)
+	if err != nil {
+		slog.Warn("Failed to update advertised routes", "error", err)
+		return
+	}
+	slog.Info("Advertised routes updated", "routes", routes)
+	if changed {
+		s.recordConfig(ctx, confighistory.SourceRoutes, "Advertised routes for the HA role")
+	}
+}
+
+// resignHA hands leadership to the peer on shutdown.
+func (s *Supervisor) resignHA() {
+	if s.ha == nil {
+		return
+	}
+	s.leader.Store(false)
+	s.ha.mu.Lock()
+	s.ha.resigned = true
+	s.ha.state.Role = ha.RoleStandby
+	s.ha.mu.Unlock()
+
+	if err := s.publishHA(); err != nil {
+		slog.Warn("Failed to write HA state", "error", err)
+	}
+	if err := s.ha.elector.Resign(context.Background()); err != nil {
+		slog.Warn("Failed to resign HA leadership", "error", err)
+	}
+}
+
+func (s *Supervisor) publishHA() error {
+	s.ha.mu.Lock()
+	s.ha.state.Updated = time.Now().UTC()
+	st := s.ha.state
+	s.ha.mu.Unlock()
+
+	return ha.WriteState(s.config.StateDir, &st)
+}
@@ -0,0 +1,100 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	historyFile = "processes.json"
+	maxHistory  = 200
+)
+
+type ProcessEvent struct {
+	Time  time.Time `json:"time"`
+	Name  string    `json:"name"`
+	Event string    `json:"event"`
+	Error string    `json:"error,omitempty"`
+	PID   int       `json:"pid,omitempty"`
+}
+
+// History persists process start and exit events so other commands can
+// show how often a daemon has been restarted.
+type History struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewHistory(stateDir string) *History {
+	return &History{path: filepath.Join(stateDir, historyFile)}
+}
+
+func (h *History) Record(name, event string, pid int, err error) {
+	if h == nil {
+		return
+	}
+
+	ev := ProcessEvent{Time: time.Now().UTC(), Name: name, Event: event, PID: pid}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events, readErr := readHistoryFile(h.path)
+	if readErr != nil {
+		slog.Warn("Discarding unreadable process history", "path", h.path, "error", readErr)
+		events = nil
+	}
+	events = append(events, ev)
+	if len(events) > maxHistory {
+		events = events[len(events)-maxHistory:]
+	}
+
+	if err := writeHistoryFile(h.path, events); err != nil {
+		slog.Warn("Failed to write process history", "path", h.path, "error", err)
+	}
+}
+
+func ReadHistory(stateDir string) ([]ProcessEvent, error) {
+	return readHistoryFile(filepath.Join(stateDir, historyFile))
+}
+
+func readHistoryFile(path string) ([]ProcessEvent, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var events []ProcessEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return events, nil
+}
+
+func writeHistoryFile(path string, events []ProcessEvent) error {
+	data, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
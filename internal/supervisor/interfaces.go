@@ -0,0 +1,36 @@
+package supervisor
+
+import (
+	"log/slog"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+	"github.com/klowdo/tailswan/internal/xfrmif"
+)
+
+// reconcileInterfaces creates an XFRM interface per route-based connection
+// and routes its remote subnets over it. It returns the interfaces that are
+// in place, keyed by connection. Children whose if_id settings cannot be
+// mapped are logged once and left to charon's policies.
+func (s *Supervisor) reconcileInterfaces(children []viciconn.Child) map[string]xfrmif.Interface {
+	if s.interfaces == nil {
+		return nil
+	}
+
+	planned, problems := xfrmif.Plan(children)
+	for _, p := range problems {
+		if !s.reported[p.String()] {
+			slog.Warn("Ignoring if_id of child", "connection", p.Connection, "child", p.Child, "problem", p.Message)
+			s.reported[p.String()] = true
+		}
+	}
+
+	if err := s.interfaces.Reconcile(planned); err != nil {
+		slog.Warn("XFRM interface reconcile failed", "error", err)
+	}
+
+	ifaces := make(map[string]xfrmif.Interface, len(planned))
+	for i := range planned {
+		ifaces[planned[i].Connection] = planned[i]
+	}
+	return ifaces
+}
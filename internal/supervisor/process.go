@@ -2,6 +2,7 @@ package supervisor
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
@@ -12,25 +13,37 @@ import (
 
 type Process struct {
 	started time.Time
+	output  io.Writer
 	cmd     *exec.Cmd
+	history *History
 	name    string
 	mu      sync.Mutex
 }
 
+func NewProcess(output io.Writer, history *History) *Process {
+	return &Process{output: output, history: history}
+}
+
 func (p *Process) Start(name string, args ...string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	p.name = name
 	p.cmd = exec.Command(name, args...)
-	p.cmd.Stdout = os.Stdout
-	p.cmd.Stderr = os.Stderr
+	output := p.output
+	if output == nil {
+		output = os.Stdout
+	}
+	p.cmd.Stdout = output
+	p.cmd.Stderr = output
 
 	if err := p.cmd.Start(); err != nil {
+		p.history.Record(name, "failed", 0, err)
 		return fmt.Errorf("start %s: %w", name, err)
 	}
 
 	p.started = time.Now()
+	p.history.Record(name, "started", p.cmd.Process.Pid, nil)
 	slog.Info("Started process", "name", name, "pid", p.cmd.Process.Pid)
 	return nil
 }
@@ -39,7 +52,9 @@ func (p *Process) Wait() error {
 	if p.cmd == nil || p.cmd.Process == nil {
 		return nil
 	}
-	return p.cmd.Wait()
+	err := p.cmd.Wait()
+	p.history.Record(p.name, "exited", p.cmd.Process.Pid, err)
+	return err
 }
 
 func (p *Process) Kill() error {
@@ -0,0 +1,146 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/schedule"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const scheduleInterval = 10 * time.Second
+
+type scheduler struct {
+	leases *schedule.LeaseStore
+	// applied is the state last acted on per connection; connections that
+	// were brought down are removed.
+	applied map[string]string
+	windows []schedule.Window
+}
+
+func (s *Supervisor) setupSchedule() error {
+	windows, err := schedule.ParseWindows(s.config.SwanSchedules)
+	if err != nil {
+		return fmt.Errorf("SWAN_SCHEDULES: %w", err)
+	}
+	s.scheduler = &scheduler{
+		windows: windows,
+		leases:  schedule.NewLeaseStore(s.config.StateDir),
+		applied: make(map[string]string),
+	}
+	for _, w := range windows {
+		slog.Info("Connection schedule", "connection", w.Connection, "up", w.Up, "down", w.Down)
+	}
+	return nil
+}
+
+func (s *Supervisor) plan() []schedule.Status {
+	now := time.Now()
+	leases, err := s.scheduler.leases.Active(now)
+	if err != nil {
+		slog.Warn("Failed to read connection leases", "error", err)
+	}
+	return schedule.Plan(now, s.scheduler.windows, leases)
+}
+
+// scheduled reports whether the connection has a window or a lease, in
+// which case the scheduler starts it rather than autostart or HA takeover.
+func (s *Supervisor) scheduled(conn string) bool {
+	if s.scheduler == nil {
+		return false
+	}
+	for _, st := range s.plan() {
+		if st.Connection == conn {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Supervisor) scheduleLoop(ctx context.Context) {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		s.enforceSchedule(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// enforceSchedule initiates or terminates the connections whose desired
+// state changed since the last pass. Acting only on changes leaves a
+// connection alone when an operator brings it up or down by hand inside a
+// window; the next window edge or lease takes over again.
+func (s *Supervisor) enforceSchedule(ctx context.Context) {
+	desired := make(map[string]string)
+	for _, st := range s.plan() {
+		desired[st.Connection] = st.State
+	}
+
+	if !s.isLeader() {
+		// Only the leader runs tunnels. Losing leadership already
+		// terminated SWAN_CONNECTIONS; bring down the rest this instance
+		// started, and start from scratch on takeover.
+		desired = make(map[string]string)
+		for _, conn := range s.config.SwanConnections {
+			delete(s.scheduler.applied, conn)
+		}
+	}
+
+	// A connection that drops out of the plan had a lease that ended.
+	for conn := range s.scheduler.applied {
+		if _, ok := desired[conn]; !ok {
+			desired[conn] = schedule.StateDown
+		}
+	}
+
+	var conns []string
+	for conn, state := range desired {
+		applied, ok := s.scheduler.applied[conn]
+		if !ok {
+			applied = schedule.StateDown
+		}
+		if state != applied {
+			conns = append(conns, conn)
+		}
+	}
+	if len(conns) == 0 {
+		return
+	}
+	sort.Strings(conns)
+
+	session, err := vici.NewSession()
+	if err != nil {
+		slog.Warn("Scheduler cannot connect to charon", "error", err)
+		return
+	}
+	defer session.Close() //nolint:errcheck
+
+	for _, conn := range conns {
+		if desired[conn] == schedule.StateUp {
+			slog.Info("Schedule initiating connection", "connection", conn)
+			// Not recorded on failure, so the next pass tries again.
+			if err := viciconn.InitiateIKE(ctx, session, conn); err != nil {
+				slog.Warn("Failed to start scheduled connection", "connection", conn, "error", err)
+				continue
+			}
+			s.scheduler.applied[conn] = schedule.StateUp
+			continue
+		}
+		slog.Info("Schedule terminating connection", "connection", conn)
+		if err := viciconn.Terminate(ctx, session, conn); err != nil {
+			slog.Warn("Failed to stop scheduled connection", "connection", conn, "error", err)
+		}
+		delete(s.scheduler.applied, conn)
+	}
+}
@@ -3,37 +3,86 @@ package supervisor
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/klowdo/tailswan/internal/confighistory"
+	"github.com/klowdo/tailswan/internal/firewall"
+	"github.com/klowdo/tailswan/internal/identity"
+	"github.com/klowdo/tailswan/internal/routing"
+	"github.com/klowdo/tailswan/internal/xfrmif"
 )
 
 type Config struct {
+	LogOutput         io.Writer
 	ControlPort       string
+	StateDir          string
 	TailscaleStateDir string
 	TailscaleSocket   string
 	SwanConfigPath    string
+	SwanSchedules     string
 	SwanConnections   []string
+	Firewall          FirewallConfig
+	BGP               BGPConfig
+	HA                HAConfig
+	Watchdog          WatchdogConfig
 	TailscaleConfig   TailscaleConfig
 	UseTsnet          bool
 	SwanAutoStart     bool
+	XFRMInterfaces    bool
 }
 
 type Supervisor struct {
 	ipsec       *Process
 	tailscaled  *Process
 	server      *Process
+	bgpd        *Process
 	tsService   *TailscaleService
 	swanService *SwanService
+	firewall    *firewall.Manager
+	access      identity.Policy
+	routing     *routing.Manager
+	interfaces  *xfrmif.Manager
+	bgp         *bgpSpeaker
+	ha          *haMember
+	scheduler   *scheduler
+	watchdog    *watchdogRunner
+	configs     *confighistory.Store
+	reported    map[string]bool
 	errors      chan error
 	config      Config
+	// reconcileMu serializes firewall reconciles, which the periodic pass
+	// and ike-updown events start concurrently. It guards reported and the
+	// firewall and XFRM interface managers.
+	reconcileMu sync.Mutex
+	leader      atomic.Bool
 }
 
 func New(cfg *Config) *Supervisor {
+	var history *History
+	var configs *confighistory.Store
+	if cfg.StateDir != "" {
+		history = NewHistory(cfg.StateDir)
+		configs = confighistory.NewStore(cfg.StateDir)
+	}
+
+	var interfaces *xfrmif.Manager
+	if cfg.XFRMInterfaces {
+		interfaces = xfrmif.NewManager()
+	}
+
 	return &Supervisor{
 		config:      *cfg,
-		ipsec:       &Process{},
-		tailscaled:  &Process{},
-		server:      &Process{},
+		interfaces:  interfaces,
+		configs:     configs,
+		reported:    make(map[string]bool),
+		ipsec:       NewProcess(cfg.LogOutput, history),
+		tailscaled:  NewProcess(cfg.LogOutput, history),
+		server:      NewProcess(cfg.LogOutput, history),
+		bgpd:        NewProcess(cfg.LogOutput, history),
 		tsService:   NewTailscaleService(),
 		swanService: &SwanService{},
 		errors:      make(chan error, 1),
@@ -51,12 +100,22 @@ func (s *Supervisor) Start(ctx context.Context) error {
 		slog.Warn("swanctl load failed", "error", err)
 	}
 
-	if s.config.SwanAutoStart {
-		for _, conn := range s.config.SwanConnections {
-			if err := s.swanService.Initiate(conn); err != nil {
-				slog.Warn("Failed to start connection", "connection", conn, "error", err)
-			}
-		}
+	slog.Info("Reconciling firewall rules", "backend", s.config.Firewall.Backend)
+	if err := s.setupFirewall(); err != nil {
+		return fmt.Errorf("firewall setup: %w", err)
+	}
+
+	if err := s.setupSchedule(); err != nil {
+		return fmt.Errorf("schedule setup: %w", err)
+	}
+	if err := s.setupWatchdog(); err != nil {
+		return fmt.Errorf("watchdog setup: %w", err)
+	}
+
+	if s.haEnabled() {
+		slog.Info("High availability enabled, connections are initiated by the leader only")
+	} else if s.config.SwanAutoStart {
+		s.initiateConnections()
 	}
 
 	slog.Info("Starting control server", "port", s.config.ControlPort)
@@ -65,17 +124,35 @@ func (s *Supervisor) Start(ctx context.Context) error {
 	}
 
 	if s.config.UseTsnet {
+		if s.config.BGP.Enabled {
+			slog.Warn("BGP_ENABLED has no effect with USE_TSNET=true; BGP needs tailscaled to sync advertised routes")
+		}
+		if s.haConfigured() {
+			slog.Warn("HA_MODE has no effect with USE_TSNET=true; failover needs tailscaled to move the advertised routes")
+		}
+		if s.config.TailscaleConfig.TunMode == TunModeKernel {
+			slog.Warn("TS_TUN_MODE=kernel has no effect with USE_TSNET=true; tsnet always uses userspace networking")
+		}
 		slog.Info("Using tsnet for Tailscale integration (embedded)")
 		slog.Info("Control server will handle Tailscale connectivity via tsnet")
 	} else {
+		tun := s.tunDevice()
+		if tun != TunUserspaceNetworking {
+			slog.Info("Installing policy routing rules for kernel TUN")
+			if err := s.setupRouting(); err != nil {
+				return fmt.Errorf("policy routing setup: %w", err)
+			}
+		}
+
 		slog.Info("Starting tailscaled",
 			"state_dir", s.config.TailscaleStateDir,
-			"socket", s.config.TailscaleSocket)
+			"socket", s.config.TailscaleSocket,
+			"tun", tun)
 		if err := s.tailscaled.Start(
 			"tailscaled",
 			"--state", fmt.Sprintf("%s/tailscaled.state", s.config.TailscaleStateDir),
 			"--socket", s.config.TailscaleSocket,
-			"--tun", "userspace-networking",
+			"--tun", tun,
 		); err != nil {
 			return fmt.Errorf("tailscaled start: %w", err)
 		}
@@ -89,8 +166,14 @@ func (s *Supervisor) Start(ctx context.Context) error {
 			return fmt.Errorf("tailscaled not ready: %w", err)
 		}
 
+		upConfig := s.config.TailscaleConfig
+		if s.haEnabled() {
+			// The leader advertises the routes once elected.
+			upConfig.Routes = nil
+		}
+
 		slog.Info("Bringing up Tailscale")
-		if err := s.tsService.Up(&s.config.TailscaleConfig); err != nil {
+		if err := s.tsService.Up(&upConfig); err != nil {
 			return fmt.Errorf("tailscale up: %w", err)
 		}
 
@@ -100,11 +183,38 @@ func (s *Supervisor) Start(ctx context.Context) error {
 				return fmt.Errorf("tailscale serve: %w", err)
 			}
 		}
+
+		if s.haEnabled() {
+			if err := s.setupHA(); err != nil {
+				return fmt.Errorf("ha setup: %w", err)
+			}
+		}
+
+		if s.config.BGP.Enabled {
+			if err := s.setupBGP(); err != nil {
+				return fmt.Errorf("bgp setup: %w", err)
+			}
+		}
 	}
 
+	s.recordConfig(ctx, confighistory.SourceStartup, "Loaded at startup")
 	s.printStatus()
 
 	go s.monitor(ctx)
+	go s.reconcileFirewallLoop(ctx)
+	if len(s.access) > 0 {
+		go s.identityLoop(ctx)
+	}
+	go s.scheduleLoop(ctx)
+	if s.watchdog != nil {
+		go s.watchdogLoop(ctx)
+	}
+	if s.ha != nil {
+		go s.haLoop(ctx)
+	}
+	if s.bgp != nil {
+		go s.syncBGPLoop(ctx)
+	}
 
 	return nil
 }
@@ -112,18 +222,40 @@ func (s *Supervisor) Start(ctx context.Context) error {
 func (s *Supervisor) Stop() {
 	slog.Info("Shutting down")
 
+	s.resignHA()
+
 	if s.server != nil {
 		if err := s.server.Kill(); err != nil {
 			slog.Error("Failed to kill server", "error", err)
 		}
 	}
 
+	if s.bgp != nil {
+		if err := s.bgpd.Kill(); err != nil {
+			slog.Error("Failed to kill gobgpd", "error", err)
+		}
+	}
+
 	if s.tailscaled != nil {
 		if err := s.tailscaled.Kill(); err != nil {
 			slog.Error("Failed to kill tailscaled", "error", err)
 		}
 	}
 
+	if s.routing != nil {
+		if err := s.routing.Remove(routing.KernelRules()); err != nil {
+			slog.Error("Failed to remove policy routing rules", "error", err)
+		}
+	}
+
+	if s.interfaces != nil {
+		s.reconcileMu.Lock()
+		if err := s.interfaces.Remove(); err != nil {
+			slog.Error("Failed to remove XFRM interfaces", "error", err)
+		}
+		s.reconcileMu.Unlock()
+	}
+
 	if s.ipsec != nil {
 		if err := s.ipsec.Kill(); err != nil {
 			slog.Error("Failed to kill ipsec", "error", err)
@@ -134,12 +266,23 @@ func (s *Supervisor) Stop() {
 	slog.Info("Shutdown complete")
 }
 
+func (s *Supervisor) initiateConnections() {
+	for _, conn := range s.config.SwanConnections {
+		if s.scheduled(conn) {
+			continue
+		}
+		if err := s.swanService.Initiate(conn); err != nil {
+			slog.Warn("Failed to start connection", "connection", conn, "error", err)
+		}
+	}
+}
+
 func (s *Supervisor) Errors() <-chan error {
 	return s.errors
 }
 
 func (s *Supervisor) monitor(ctx context.Context) {
-	errChan := make(chan error, 3)
+	errChan := make(chan error, 4)
 
 	go func() {
 		err := s.ipsec.Wait()
@@ -153,6 +296,13 @@ func (s *Supervisor) monitor(ctx context.Context) {
 		}()
 	}
 
+	if s.bgp != nil {
+		go func() {
+			err := s.bgpd.Wait()
+			errChan <- fmt.Errorf("gobgpd exited: %w", err)
+		}()
+	}
+
 	go func() {
 		err := s.server.Wait()
 		errChan <- fmt.Errorf("controlserver exited: %w", err)
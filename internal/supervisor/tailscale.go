@@ -4,13 +4,17 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/netip"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"time"
 
 	"tailscale.com/client/local"
 	"tailscale.com/ipn"
+
+	"github.com/klowdo/tailswan/internal/bgp"
 )
 
 type TailscaleService struct {
@@ -28,6 +32,7 @@ type TailscaleConfig struct {
 	Socket      string
 	Hostname    string
 	AuthKey     string `json:"-"`
+	TunMode     string
 	Routes      []string
 	ExtraArgs   []string
 	SSH         bool
@@ -103,6 +108,35 @@ func (ts *TailscaleService) Up(cfg *TailscaleConfig) error {
 	return nil
 }
 
+// AdvertisedRoutes returns the subnet routes advertised to the tailnet.
+func (ts *TailscaleService) AdvertisedRoutes(ctx context.Context) ([]netip.Prefix, error) {
+	prefs, err := ts.client.GetPrefs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get prefs: %w", err)
+	}
+	return prefs.AdvertiseRoutes, nil
+}
+
+// SetAdvertiseRoutes replaces the advertised subnet routes through LocalAPI
+// and reports whether they changed.
+func (ts *TailscaleService) SetAdvertiseRoutes(ctx context.Context, routes []netip.Prefix) (bool, error) {
+	prefs, err := ts.client.GetPrefs(ctx)
+	if err != nil {
+		return false, fmt.Errorf("get prefs: %w", err)
+	}
+	if slices.Equal(bgp.Sorted(prefs.AdvertiseRoutes), bgp.Sorted(routes)) {
+		return false, nil
+	}
+
+	if _, err := ts.client.EditPrefs(ctx, &ipn.MaskedPrefs{
+		Prefs:              ipn.Prefs{AdvertiseRoutes: routes},
+		AdvertiseRoutesSet: true,
+	}); err != nil {
+		return false, fmt.Errorf("edit prefs: %w", err)
+	}
+	return true, nil
+}
+
 func (ts *TailscaleService) EnableServe(port string) error {
 	ctx := context.Background()
 
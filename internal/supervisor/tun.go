@@ -0,0 +1,43 @@
+package supervisor
+
+import (
+	"log/slog"
+
+	"github.com/klowdo/tailswan/internal/firewall"
+	"github.com/klowdo/tailswan/internal/routing"
+)
+
+const (
+	TunModeUserspace = "userspace"
+	TunModeKernel    = "kernel"
+
+	// TunUserspaceNetworking is the tailscaled --tun value for netstack mode.
+	TunUserspaceNetworking = "userspace-networking"
+)
+
+// tunDevice picks the --tun argument for tailscaled. Kernel mode falls back
+// to userspace networking when no TUN interface can be created, so the
+// gateway still comes up instead of crash-looping on a missing device.
+func (s *Supervisor) tunDevice() string {
+	switch mode := s.config.TailscaleConfig.TunMode; mode {
+	case TunModeKernel:
+	case TunModeUserspace, "":
+		return TunUserspaceNetworking
+	default:
+		slog.Warn("Unknown TS_TUN_MODE, using userspace networking", "mode", mode)
+		return TunUserspaceNetworking
+	}
+
+	if err := routing.ProbeTUN(); err != nil {
+		slog.Warn("TS_TUN_MODE=kernel but no TUN interface can be created, falling back to userspace networking",
+			"error", err,
+			"hint", "run the container with --device /dev/net/tun and --cap-add NET_ADMIN")
+		return TunUserspaceNetworking
+	}
+	return firewall.DefaultTailscaleIface
+}
+
+func (s *Supervisor) setupRouting() error {
+	s.routing = routing.NewManager()
+	return s.routing.Apply(routing.KernelRules())
+}
@@ -0,0 +1,126 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/diag"
+	"github.com/klowdo/tailswan/internal/viciconn"
+	"github.com/klowdo/tailswan/internal/watchdog"
+)
+
+type WatchdogConfig struct {
+	Probes    string
+	Interval  string
+	Timeout   string
+	Threshold string
+}
+
+type watchdogRunner struct {
+	dog      *watchdog.Watchdog
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func (s *Supervisor) setupWatchdog() error {
+	cfg := s.config.Watchdog
+	probes, err := watchdog.ParseProbes(cfg.Probes)
+	if err != nil {
+		return fmt.Errorf("WATCHDOG_PROBES: %w", err)
+	}
+	if len(probes) == 0 {
+		return nil
+	}
+
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil || interval < time.Second {
+		return fmt.Errorf("WATCHDOG_INTERVAL %q must be a duration of at least 1s", cfg.Interval)
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil || timeout <= 0 || timeout > interval {
+		return fmt.Errorf("WATCHDOG_TIMEOUT %q must be a positive duration no longer than WATCHDOG_INTERVAL", cfg.Timeout)
+	}
+	threshold, err := strconv.Atoi(cfg.Threshold)
+	if err != nil || threshold < 1 {
+		return fmt.Errorf("WATCHDOG_THRESHOLD %q must be a positive number", cfg.Threshold)
+	}
+
+	s.watchdog = &watchdogRunner{
+		dog:      watchdog.New(probes, threshold),
+		interval: interval,
+		timeout:  timeout,
+	}
+	for _, p := range probes {
+		slog.Info("Connection watchdog", "connection", p.Connection, "probe", p.Kind, "target", p.Target)
+	}
+	return s.publishWatchdog()
+}
+
+func (s *Supervisor) watchdogLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.watchdog.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.probeConnections(ctx)
+		}
+	}
+}
+
+// probeConnections probes every connection with an established CHILD_SA
+// and restarts the children whose probes failed too often in a row.
+func (s *Supervisor) probeConnections(ctx context.Context) {
+	session, err := vici.NewSession()
+	if err != nil {
+		slog.Warn("Watchdog cannot connect to charon", "error", err)
+		return
+	}
+	defer session.Close() //nolint:errcheck
+
+	sas, err := viciconn.ChildSAs(session)
+	if err != nil {
+		slog.Warn("Watchdog failed to list SAs", "error", err)
+		return
+	}
+
+	dog := s.watchdog.dog
+	for _, probe := range dog.Probes() {
+		now := time.Now().UTC()
+		sa, ok := diag.FindChildSA(sas, probe.Connection)
+		if !ok || sa.State != "INSTALLED" {
+			dog.NoSA(probe.Connection, now)
+			continue
+		}
+
+		source, _ := diag.SourceFor(sa.LocalTS)
+		latency, err := probe.Run(ctx, source, s.watchdog.timeout)
+		if err != nil {
+			slog.Warn("Tunnel probe failed", "connection", probe.Connection, "target", probe.Target, "error", err)
+		}
+		if !dog.Record(probe.Connection, latency, err, now) || !s.isLeader() {
+			continue
+		}
+
+		slog.Warn("Tunnel is not passing traffic, restarting child", "connection", probe.Connection, "child", sa.Name)
+		if err := viciconn.RestartChild(ctx, session, sa); err != nil {
+			slog.Warn("Failed to restart child", "connection", sa.IKE, "child", sa.Name, "error", err)
+		}
+		dog.Restarted(probe.Connection, time.Now().UTC())
+	}
+
+	if err := s.publishWatchdog(); err != nil {
+		slog.Warn("Failed to write watchdog state", "error", err)
+	}
+}
+
+func (s *Supervisor) publishWatchdog() error {
+	return watchdog.WriteState(s.config.StateDir, s.watchdog.dog.State(time.Now().UTC()))
+}
@@ -0,0 +1,23 @@
+package swanconf
+
+import "regexp"
+
+// secretRe matches the value of a secret or pin setting: quoted, or
+// unquoted up to the end of the line, a comment or a closing brace, since
+// an unquoted value may contain spaces.
+var secretRe = regexp.MustCompile(`(?m)\b(secret|pin)([ \t]*=[ \t]*)("(?:[^"\\\n]|\\.)*"|[^#}\n]*[^\s#}])`)
+
+// RedactSecrets replaces the values of the secret and pin settings in
+// swanctl.conf content (PSKs, EAP and XAuth passwords, private key
+// passphrases and smartcard PINs) with what replace returns for the
+// unquoted value.
+func RedactSecrets(content string, replace func(value string) string) string {
+	return secretRe.ReplaceAllStringFunc(content, func(m string) string {
+		parts := secretRe.FindStringSubmatch(m)
+		value := parts[3]
+		if len(value) >= 2 && value[0] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		return parts[1] + parts[2] + replace(value)
+	})
+}
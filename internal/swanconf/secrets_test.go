@@ -0,0 +1,32 @@
+package swanconf
+
+import "testing"
+
+func TestRedactSecrets(t *testing.T) {
+	conf := `secrets {
+    ike-a {
+        secret = correct horse battery # the PSK
+    }
+    eap-b { secret = "quoted \" value" }
+    token-c {
+        pin=1234
+    }
+    ike-d { secret = two words }
+}
+`
+	want := `secrets {
+    ike-a {
+        secret = <correct horse battery> # the PSK
+    }
+    eap-b { secret = <quoted \" value> }
+    token-c {
+        pin=<1234>
+    }
+    ike-d { secret = <two words> }
+}
+`
+	got := RedactSecrets(conf, func(v string) string { return "<" + v + ">" })
+	if got != want {
+		t.Errorf("unexpected result:\n%s\nwant:\n%s", got, want)
+	}
+}
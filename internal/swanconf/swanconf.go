@@ -0,0 +1,425 @@
+// Package swanconf reads and writes strongSwan settings files such as
+// swanctl.conf: nested sections of key = value pairs, with includes.
+package swanconf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Section is a named section holding settings and subsections in the
+// order they were first defined. As in strongSwan, a section or key
+// defined again is merged into or replaces the earlier definition.
+type Section struct {
+	Name     string
+	Settings []Setting
+	Sections []*Section
+}
+
+type Setting struct {
+	Key   string
+	Value string
+}
+
+// Get returns the value of key, or "" when it is not set.
+func (s *Section) Get(key string) string {
+	for _, kv := range s.Settings {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return ""
+}
+
+// Has reports whether key is set, possibly to "".
+func (s *Section) Has(key string) bool {
+	for _, kv := range s.Settings {
+		if kv.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Set sets key, keeping its position when it is already set.
+func (s *Section) Set(key, value string) {
+	for i := range s.Settings {
+		if s.Settings[i].Key == key {
+			s.Settings[i].Value = value
+			return
+		}
+	}
+	s.Settings = append(s.Settings, Setting{Key: key, Value: value})
+}
+
+// Section returns the subsection at path, or nil.
+func (s *Section) Section(path ...string) *Section {
+	cur := s
+	for _, name := range path {
+		var next *Section
+		for _, sub := range cur.Sections {
+			if sub.Name == name {
+				next = sub
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		cur = next
+	}
+	return cur
+}
+
+// Ensure returns the subsection name, adding it when missing.
+func (s *Section) Ensure(name string) *Section {
+	if sub := s.Section(name); sub != nil {
+		return sub
+	}
+	sub := &Section{Name: name}
+	s.Sections = append(s.Sections, sub)
+	return sub
+}
+
+// Remove deletes the subsection name and reports whether it existed.
+func (s *Section) Remove(name string) bool {
+	for i, sub := range s.Sections {
+		if sub.Name == name {
+			s.Sections = append(s.Sections[:i], s.Sections[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Clone returns a deep copy of s.
+func (s *Section) Clone() *Section {
+	c := &Section{Name: s.Name, Settings: append([]Setting(nil), s.Settings...)}
+	for _, sub := range s.Sections {
+		c.Sections = append(c.Sections, sub.Clone())
+	}
+	return c
+}
+
+func (s *Section) merge(other *Section) {
+	for _, kv := range other.Settings {
+		s.Set(kv.Key, kv.Value)
+	}
+	for _, sub := range other.Sections {
+		s.Ensure(sub.Name).merge(sub)
+	}
+}
+
+// ParseFile parses a settings file, resolving include statements relative
+// to the including file.
+func ParseFile(path string) (*Section, error) {
+	root := &Section{}
+	if err := parseFile(root, path, 0); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// Parse parses settings without includes.
+func Parse(r io.Reader) (*Section, error) {
+	root := &Section{}
+	p := &parser{r: bufio.NewReader(r), line: 1, name: "<input>"}
+	if err := p.parse(root, nil, true); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+const maxIncludeDepth = 10
+
+func parseFile(root *Section, path string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: includes nested too deep", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	p := &parser{r: bufio.NewReader(f), line: 1, name: path}
+	return p.parse(root, func(pattern string) error {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			if err := parseFile(root, m, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, true)
+}
+
+type parser struct {
+	r    *bufio.Reader
+	name string
+	line int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", p.name, p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) read() (rune, bool) {
+	c, _, err := p.r.ReadRune()
+	if err != nil {
+		return 0, false
+	}
+	if c == '\n' {
+		p.line++
+	}
+	return c, true
+}
+
+func (p *parser) unread(c rune) {
+	if p.r.UnreadRune() == nil && c == '\n' {
+		p.line--
+	}
+}
+
+// skip skips whitespace and comments and returns the next rune.
+func (p *parser) skip() (rune, bool) {
+	for {
+		c, ok := p.read()
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case c == '#':
+			p.skipLine()
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			return c, true
+		}
+	}
+}
+
+func (p *parser) skipLine() {
+	for {
+		c, ok := p.read()
+		if !ok || c == '\n' {
+			return
+		}
+	}
+}
+
+func isNameRune(c rune) bool {
+	return c > ' ' && !strings.ContainsRune("{}=#:\"", c)
+}
+
+func (p *parser) word() string {
+	var b strings.Builder
+	for {
+		c, ok := p.read()
+		if !ok {
+			break
+		}
+		if !isNameRune(c) {
+			p.unread(c)
+			break
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// parse reads settings into sec until EOF or, below the top level, a '}'.
+// include is nil when includes are not allowed.
+func (p *parser) parse(sec *Section, include func(pattern string) error, top bool) error {
+	for {
+		c, ok := p.skip()
+		if !ok {
+			if !top {
+				return p.errorf("missing '}' for section %s", sec.Name)
+			}
+			return nil
+		}
+		if c == '}' {
+			if top {
+				return p.errorf("unexpected '}'")
+			}
+			return nil
+		}
+		if !isNameRune(c) {
+			return p.errorf("unexpected %q", c)
+		}
+		p.unread(c)
+		name := p.word()
+
+		if name == "include" {
+			if next, ok := p.peekInline(); ok && next != '=' && next != '{' {
+				pattern, err := p.value()
+				if err != nil {
+					return err
+				}
+				if include == nil {
+					return p.errorf("include is not supported here")
+				}
+				if err := include(pattern); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		c, ok = p.skip()
+		switch {
+		case ok && c == '=':
+			value, err := p.value()
+			if err != nil {
+				return err
+			}
+			sec.Set(name, value)
+		case ok && c == '{':
+			if err := p.parse(sec.Ensure(name), include, false); err != nil {
+				return err
+			}
+		case ok && c == ':':
+			// name : template { ... } copies the template's settings.
+			base := p.nameAfterSpace()
+			if c, ok := p.skip(); !ok || c != '{' {
+				return p.errorf("expected '{' after %s : %s", name, base)
+			}
+			tmpl := sec.Section(base)
+			if tmpl == nil {
+				return p.errorf("unknown section %s referenced by %s", base, name)
+			}
+			sub := sec.Ensure(name)
+			sub.merge(tmpl)
+			if err := p.parse(sub, include, false); err != nil {
+				return err
+			}
+		default:
+			return p.errorf("expected '=' or '{' after %s", name)
+		}
+	}
+}
+
+func (p *parser) nameAfterSpace() string {
+	c, ok := p.skip()
+	if ok {
+		p.unread(c)
+	}
+	return p.word()
+}
+
+// peekInline returns the next non-blank rune on the current line.
+func (p *parser) peekInline() (rune, bool) {
+	for {
+		c, ok := p.read()
+		if !ok {
+			return 0, false
+		}
+		if c == ' ' || c == '\t' {
+			continue
+		}
+		p.unread(c)
+		return c, c != '\n' && c != '#'
+	}
+}
+
+// value reads a value up to the end of the line, a comment or a '}'.
+func (p *parser) value() (string, error) {
+	c, ok := p.peekInline()
+	if !ok {
+		return "", nil
+	}
+	if c == '"' {
+		p.read()
+		return p.quoted()
+	}
+	var b strings.Builder
+	for {
+		c, ok := p.read()
+		if !ok {
+			break
+		}
+		if c == '\n' || c == '#' || c == '}' {
+			p.unread(c)
+			break
+		}
+		b.WriteRune(c)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func (p *parser) quoted() (string, error) {
+	var b strings.Builder
+	for {
+		c, ok := p.read()
+		if !ok {
+			return "", p.errorf("unterminated string")
+		}
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			e, ok := p.read()
+			if !ok {
+				return "", p.errorf("unterminated string")
+			}
+			switch e {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			case 'r':
+				b.WriteRune('\r')
+			default:
+				b.WriteRune(e)
+			}
+		default:
+			b.WriteRune(c)
+		}
+	}
+}
+
+// String renders the section's contents in swanctl.conf syntax.
+func (s *Section) String() string {
+	var b strings.Builder
+	s.write(&b, 0)
+	return b.String()
+}
+
+func (s *Section) write(b *strings.Builder, depth int) {
+	indent := strings.Repeat("    ", depth)
+	for _, kv := range s.Settings {
+		if kv.Value == "" {
+			b.WriteString(indent + kv.Key + " =\n")
+			continue
+		}
+		b.WriteString(indent + kv.Key + " = " + quote(kv.Value) + "\n")
+	}
+	for i, sub := range s.Sections {
+		if i > 0 || len(s.Settings) > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + sub.Name + " {\n")
+		sub.write(b, depth+1)
+		b.WriteString(indent + "}\n")
+	}
+}
+
+func quote(v string) string {
+	if v != strings.TrimSpace(v) || strings.ContainsAny(v, "#{}\"\\\n\t\r") {
+		r := strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+		return "\"" + r.Replace(v) + "\""
+	}
+	return v
+}
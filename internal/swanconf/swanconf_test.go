@@ -0,0 +1,88 @@
+package swanconf
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseFile(t *testing.T) {
+	root, err := ParseFile(filepath.Join("testdata", "swanctl.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := root.Section("connections", "partner-a")
+	if a == nil {
+		t.Fatal("expected partner-a")
+	}
+	if got := a.Get("remote_addrs"); got != "203.0.113.10" {
+		t.Errorf("expected the comment to be stripped, got %q", got)
+	}
+	if got := a.Get("proposals"); got != "aes256-sha384-ecp384" {
+		t.Errorf("expected the include to override proposals, got %q", got)
+	}
+	if got := a.Section("local").Get("id"); got != "C=SE, O=Example, CN=gw #1" {
+		t.Errorf("unexpected quoted id %q", got)
+	}
+	net := a.Section("children", "net")
+	if net.Get("local_ts") != "10.1.0.0/24" || net.Get("esp_proposals") != "aes256gcm16-modp2048" {
+		t.Errorf("unexpected child %+v", net)
+	}
+
+	b := root.Section("connections", "partner-b")
+	if b == nil || b.Get("remote_addrs") != "198.51.100.20" || b.Get("version") != "2" || b.Section("children", "net") == nil {
+		t.Fatalf("expected partner-b to inherit partner-a, got %+v", b)
+	}
+
+	secret := root.Section("secrets", "ike-partner-a")
+	if !secret.Has("id") || secret.Get("id") != "" || secret.Get("secret") != `s3cr"et` {
+		t.Errorf("unexpected secret %+v", secret)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, bad := range []string{
+		"connections {",
+		"}",
+		"connections { a b }",
+		"a : missing { }",
+		`x = "unterminated`,
+		"include other.conf",
+	} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestString_RoundTrip(t *testing.T) {
+	root := &Section{}
+	conn := root.Ensure("connections").Ensure("partner")
+	conn.Set("remote_addrs", "203.0.113.10")
+	conn.Ensure("local").Set("id", "C=SE, CN=gw #1")
+	conn.Set("pools", "")
+
+	want := `connections {
+    partner {
+        remote_addrs = 203.0.113.10
+        pools =
+
+        local {
+            id = "C=SE, CN=gw #1"
+        }
+    }
+}
+`
+	if got := root.String(); got != want {
+		t.Errorf("unexpected rendering:\n%s", got)
+	}
+
+	parsed, err := Parse(strings.NewReader(root.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != root.String() {
+		t.Errorf("expected a round trip, got:\n%s", parsed.String())
+	}
+}
@@ -0,0 +1,380 @@
+// Package swandiff compares swanctl.conf with what charon has loaded, to
+// show what swanctl --load-all, run by tailswan reload, would change
+// before it is run.
+package swandiff
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/swanload"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+// Reasons an SA is affected.
+const (
+	ReasonChanged = "changed"
+	ReasonRemoved = "removed"
+)
+
+// Loaded is what charon has loaded, as far as VICI reports it, and its
+// SAs.
+type Loaded struct {
+	Conns       []viciconn.Conn
+	Shared      []string
+	Pools       []viciconn.Pool
+	Authorities []viciconn.Authority
+	SAs         []viciconn.ChildSA
+}
+
+// Fetch asks charon for what it has loaded with list-conns, get-shared,
+// get-pools and list-authorities, and for its SAs.
+func Fetch(ctx context.Context, session *vici.Session) (*Loaded, error) {
+	var (
+		l   Loaded
+		err error
+	)
+	if l.Conns, err = viciconn.Conns(ctx, session); err != nil {
+		return nil, fmt.Errorf("list-conns: %w", err)
+	}
+	if l.Shared, err = viciconn.SharedIDs(ctx, session); err != nil {
+		return nil, fmt.Errorf("get-shared: %w", err)
+	}
+	if l.Pools, err = viciconn.Pools(ctx, session); err != nil {
+		return nil, fmt.Errorf("get-pools: %w", err)
+	}
+	if l.Authorities, err = viciconn.Authorities(ctx, session); err != nil {
+		return nil, fmt.Errorf("list-authorities: %w", err)
+	}
+	if l.SAs, err = viciconn.ChildSAs(session); err != nil {
+		return nil, fmt.Errorf("list-sas: %w", err)
+	}
+	return &l, nil
+}
+
+// SA is a child SA of a connection a reload changes or removes.
+type SA struct {
+	IKE        string `json:"ike"`
+	Child      string `json:"child"`
+	State      string `json:"state"`
+	RemoteHost string `json:"remote_host"`
+	Reason     string `json:"reason"`
+}
+
+// Report is what a reload would change, from what charon has loaded to
+// what is on disk. Details say how each changed section differs, by kind
+// and name such as connections.partner.
+type Report struct {
+	Details     map[string][]string `json:"details,omitempty"`
+	Conns       swanload.Delta      `json:"connections"`
+	Secrets     swanload.Delta      `json:"secrets"`
+	Pools       swanload.Delta      `json:"pools"`
+	Authorities swanload.Delta      `json:"authorities"`
+	Affected    []SA                `json:"affected_sas"`
+}
+
+func (r *Report) Empty() bool {
+	return r.Conns.Empty() && r.Secrets.Empty() && r.Pools.Empty() && r.Authorities.Empty()
+}
+
+// Compare compares root, swanctl.conf with its includes, with loaded.
+// Settings charon does not report, such as proposals, timeouts, the
+// secrets themselves and the certificates of authorities, are not
+// compared.
+func Compare(root *swanconf.Section, loaded *Loaded) *Report {
+	r := &Report{Details: map[string][]string{}, Affected: []SA{}}
+	r.Conns = compare(r, "connections", sections(root, "connections"), loaded.Conns,
+		func(c viciconn.Conn) string { return c.Name }, compareConn)
+	r.Secrets = compare(r, "secrets", slices.DeleteFunc(sections(root, "secrets"), func(sec *swanconf.Section) bool {
+		return !swanload.IsShared(sec)
+	}), loaded.Shared, func(id string) string { return id }, nil)
+	r.Pools = compare(r, "pools", sections(root, "pools"), loaded.Pools,
+		func(p viciconn.Pool) string { return p.Name }, comparePool)
+	r.Authorities = compare(r, "authorities", sections(root, "authorities"), loaded.Authorities,
+		func(a viciconn.Authority) string { return a.Name }, compareAuthority)
+
+	for _, sa := range loaded.SAs {
+		reason := ""
+		switch {
+		case slices.Contains(r.Conns.Changed, sa.IKE):
+			reason = ReasonChanged
+		case slices.Contains(r.Conns.Removed, sa.IKE):
+			reason = ReasonRemoved
+		default:
+			continue
+		}
+		r.Affected = append(r.Affected, SA{IKE: sa.IKE, Child: sa.Name, State: sa.State, RemoteHost: sa.RemoteHost, Reason: reason})
+	}
+	return r
+}
+
+func sections(root *swanconf.Section, kind string) []*swanconf.Section {
+	if parent := root.Section(kind); parent != nil {
+		return slices.Clone(parent.Sections)
+	}
+	return nil
+}
+
+// compare matches the sections on disk with the loaded items by name. diff,
+// if not nil, says how a section differs from the item loaded under its
+// name.
+func compare[T any](r *Report, kind string, disk []*swanconf.Section, loaded []T, name func(T) string, diff func(*swanconf.Section, T) []string) swanload.Delta {
+	byName := map[string]T{}
+	for _, item := range loaded {
+		byName[name(item)] = item
+	}
+	var d swanload.Delta
+	for _, sec := range disk {
+		item, ok := byName[sec.Name]
+		if !ok {
+			d.Added = append(d.Added, sec.Name)
+			continue
+		}
+		delete(byName, sec.Name)
+		if diff == nil {
+			continue
+		}
+		if details := diff(sec, item); len(details) > 0 {
+			d.Changed = append(d.Changed, sec.Name)
+			r.Details[kind+"."+sec.Name] = details
+		}
+	}
+	for _, item := range loaded {
+		if _, ok := byName[name(item)]; ok {
+			d.Removed = append(d.Removed, name(item))
+		}
+	}
+	return d
+}
+
+// differ collects the settings whose loaded value differs from the one on
+// disk.
+type differ []string
+
+func (d *differ) list(key string, loaded, disk []string) {
+	loaded, disk = slices.Sorted(slices.Values(loaded)), slices.Sorted(slices.Values(disk))
+	if !slices.Equal(loaded, disk) {
+		*d = append(*d, fmt.Sprintf("%s: %s -> %s", key, join(loaded), join(disk)))
+	}
+}
+
+func (d *differ) value(key, loaded, disk string) {
+	d.list(key, []string{loaded}, []string{disk})
+}
+
+func join(values []string) string {
+	if len(values) == 0 || len(values) == 1 && values[0] == "" {
+		return "(none)"
+	}
+	return strings.Join(values, ", ")
+}
+
+func split(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func compareConn(sec *swanconf.Section, c viciconn.Conn) []string {
+	var d differ
+	d.value("version", c.Version, ikeVersion(sec.Get("version")))
+	d.list("local_addrs", orDefault(c.LocalAddrs, "%any"), orDefault(split(sec.Get("local_addrs")), "%any"))
+	d.list("remote_addrs", orDefault(c.RemoteAddrs, "%any"), orDefault(split(sec.Get("remote_addrs")), "%any"))
+	compareAuth(&d, "local", sec, c.Local)
+	compareAuth(&d, "remote", sec, c.Remote)
+
+	var disk []*swanconf.Section
+	if children := sec.Section("children"); children != nil {
+		disk = children.Sections
+	}
+	byName := map[string]viciconn.Child{}
+	for _, child := range c.Children {
+		byName[child.Name] = child
+	}
+	for _, sec := range disk {
+		child, ok := byName[sec.Name]
+		if !ok {
+			d = append(d, fmt.Sprintf("children: +%s", sec.Name))
+			continue
+		}
+		delete(byName, sec.Name)
+		mode := sec.Get("mode")
+		if mode == "" {
+			mode = "tunnel"
+		}
+		if child.Mode != "" {
+			d.value(sec.Name+".mode", child.Mode, strings.ToUpper(mode))
+		}
+		d.list(sec.Name+".local_ts", selectors(child.LocalTS), selectors(split(sec.Get("local_ts"))))
+		d.list(sec.Name+".remote_ts", selectors(child.RemoteTS), selectors(split(sec.Get("remote_ts"))))
+	}
+	for _, child := range c.Children {
+		if _, ok := byName[child.Name]; ok {
+			d = append(d, fmt.Sprintf("children: -%s", child.Name))
+		}
+	}
+	return d
+}
+
+// compareAuth compares the identities of the authentication rounds, the
+// sections of conn named local or local-..., that both set. charon adds a
+// round to a connection without any.
+func compareAuth(d *differ, prefix string, conn *swanconf.Section, loaded []viciconn.Auth) {
+	var rounds []*swanconf.Section
+	for _, sec := range conn.Sections {
+		if strings.HasPrefix(sec.Name, prefix) {
+			rounds = append(rounds, sec)
+		}
+	}
+	if n := max(len(rounds), 1); n != len(loaded) {
+		*d = append(*d, fmt.Sprintf("%s authentication rounds: %d -> %d", prefix, len(loaded), n))
+		return
+	}
+	for i, round := range rounds {
+		if id := round.Get("id"); id != "" && loaded[i].ID != "" {
+			d.value(round.Name+".id", loaded[i].ID, id)
+		}
+	}
+}
+
+func ikeVersion(v string) string {
+	switch v {
+	case "1":
+		return "IKEv1"
+	case "2":
+		return "IKEv2"
+	}
+	return "IKEv1/2"
+}
+
+func orDefault(values []string, def string) []string {
+	if len(values) == 0 {
+		return []string{def}
+	}
+	return values
+}
+
+// selectors normalizes traffic selectors the way charon reports them:
+// addresses as host prefixes, and dynamic when there are none.
+func selectors(ts []string) []string {
+	var list []string
+	for _, s := range orDefault(ts, "dynamic") {
+		addr, suffix, _ := strings.Cut(s, "[")
+		if suffix != "" {
+			suffix = "[" + suffix
+		}
+		if p, err := netip.ParsePrefix(addr); err == nil {
+			addr = p.Masked().String()
+		} else if a, err := netip.ParseAddr(addr); err == nil {
+			addr = netip.PrefixFrom(a, a.BitLen()).String()
+		}
+		list = append(list, addr+suffix)
+	}
+	return list
+}
+
+// poolLimit is the most host bits of a pool whose size charon reports
+// unchanged.
+const poolLimit = 30
+
+func comparePool(sec *swanconf.Section, p viciconn.Pool) []string {
+	base, size, ok := poolRange(sec.Get("addrs"))
+	if !ok {
+		return nil
+	}
+	var d differ
+	d.value("base", p.Base, base)
+	if size > 0 {
+		d.value("size", fmt.Sprint(p.Size), fmt.Sprint(size))
+	}
+	return d
+}
+
+// poolRange returns the base address and size charon reports for a pool
+// of addrs: a subnet, of which it does not hand out the first and last
+// address, a from-to range or a single address. size is 0 when it cannot
+// be told.
+func poolRange(addrs string) (base string, size int, ok bool) {
+	if from, to, isRange := strings.Cut(addrs, "-"); isRange {
+		a, err1 := netip.ParseAddr(strings.TrimSpace(from))
+		b, err2 := netip.ParseAddr(strings.TrimSpace(to))
+		if err1 != nil || err2 != nil {
+			return "", 0, false
+		}
+		if a.Is4() && b.Is4() {
+			a4, b4 := a.As4(), b.As4()
+			size = int(binary.BigEndian.Uint32(b4[:])) - int(binary.BigEndian.Uint32(a4[:])) + 1
+		}
+		return a.String(), size, true
+	}
+	if p, err := netip.ParsePrefix(addrs); err == nil {
+		if bits := p.Addr().BitLen() - p.Bits(); bits <= poolLimit {
+			size = 1 << bits
+			if size > 2 {
+				size -= 2
+			}
+		}
+		return p.Masked().Addr().String(), size, true
+	}
+	if a, err := netip.ParseAddr(addrs); err == nil {
+		return a.String(), 1, true
+	}
+	return "", 0, false
+}
+
+func compareAuthority(sec *swanconf.Section, a viciconn.Authority) []string {
+	var d differ
+	d.list("crl_uris", a.CRLURIs, split(sec.Get("crl_uris")))
+	d.list("ocsp_uris", a.OCSPURIs, split(sec.Get("ocsp_uris")))
+	d.value("cert_uri_base", a.CertURIBase, sec.Get("cert_uri_base"))
+	return d
+}
+
+func (r *Report) String() string {
+	if r.Empty() {
+		return "Nothing to change: the configuration matches what charon has loaded\n"
+	}
+	var b strings.Builder
+	for _, k := range []struct {
+		delta *swanload.Delta
+		kind  string
+		title string
+	}{
+		{&r.Conns, "connections", "Connections"},
+		{&r.Secrets, "secrets", "Shared secrets"},
+		{&r.Pools, "pools", "Pools"},
+		{&r.Authorities, "authorities", "Authorities"},
+	} {
+		if k.delta.Empty() {
+			fmt.Fprintf(&b, "%s: no changes\n", k.title)
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s\n", k.title, k.delta.String())
+		for _, name := range k.delta.Changed {
+			for _, detail := range r.Details[k.kind+"."+name] {
+				fmt.Fprintf(&b, "  %s: %s\n", name, detail)
+			}
+		}
+	}
+	if len(r.Affected) > 0 {
+		affected := slices.Clone(r.Affected)
+		sort.SliceStable(affected, func(i, j int) bool { return affected[i].IKE < affected[j].IKE })
+		b.WriteString("Affected SAs:\n")
+		for _, sa := range affected {
+			fmt.Fprintf(&b, "  %s/%s (%s, %s): connection %s\n", sa.IKE, sa.Child, sa.State, sa.RemoteHost, sa.Reason)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,201 @@
+package swandiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/swanload"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+const testConfig = `connections {
+	partner-a {
+		version = 2
+		remote_addrs = 198.51.100.10
+		local {
+			auth = psk
+			id = gw.example.com
+		}
+		remote {
+			auth = psk
+		}
+		children {
+			net {
+				local_ts = 10.1.0.0/16
+				remote_ts = 10.2.0.0/16, 10.3.0.1
+			}
+		}
+	}
+	partner-b {
+		remote_addrs = 198.51.100.2
+		children {
+			net {
+				local_ts = 10.1.0.0/16
+				remote_ts = 10.4.0.0/16
+			}
+		}
+	}
+	partner-c {
+		remote_addrs = 198.51.100.3
+	}
+}
+secrets {
+	ike-a {
+		secret = first
+	}
+	private-gw {
+		file = gw.pem
+	}
+}
+pools {
+	rw {
+		addrs = 10.10.0.0/24
+	}
+	contractors {
+		addrs = 10.20.0.1-10.20.0.20
+	}
+}
+authorities {
+	corp {
+		cacert = corp.pem
+		crl_uris = http://crl.example.com/corp.crl
+	}
+}
+`
+
+func testLoaded() *Loaded {
+	auth := []viciconn.Auth{{Class: "pre-shared key"}}
+	return &Loaded{
+		Conns: []viciconn.Conn{
+			{
+				Name:        "partner-a",
+				Version:     "IKEv2",
+				LocalAddrs:  []string{"%any"},
+				RemoteAddrs: []string{"198.51.100.1"},
+				Local:       []viciconn.Auth{{Class: "pre-shared key", ID: "gw.example.com"}},
+				Remote:      auth,
+				Children: []viciconn.Child{
+					{Name: "net", Mode: "TUNNEL", LocalTS: []string{"10.1.0.0/16"}, RemoteTS: []string{"10.3.0.1/32", "10.2.0.0/16"}},
+					{Name: "old", Mode: "TUNNEL", LocalTS: []string{"dynamic"}, RemoteTS: []string{"dynamic"}},
+				},
+			},
+			{
+				Name:        "partner-b",
+				Version:     "IKEv1/2",
+				RemoteAddrs: []string{"198.51.100.2"},
+				Local:       auth,
+				Remote:      auth,
+				Children:    []viciconn.Child{{Name: "net", Mode: "TUNNEL", LocalTS: []string{"10.1.0.0/16"}, RemoteTS: []string{"10.4.0.0/16"}}},
+			},
+			{Name: "partner-d", Version: "IKEv2", Local: auth, Remote: auth},
+		},
+		Shared: []string{"ike-a", "eap-alice"},
+		Pools: []viciconn.Pool{
+			{Name: "contractors", Base: "10.20.0.1", Size: 20},
+			{Name: "rw", Base: "10.10.0.0", Size: 126},
+		},
+		Authorities: []viciconn.Authority{{Name: "corp", CACert: "CN=Corp CA", CRLURIs: []string{"http://crl.example.com/corp.crl"}}},
+		SAs: []viciconn.ChildSA{
+			{IKE: "partner-a", Name: "net", State: "INSTALLED", RemoteHost: "198.51.100.1"},
+			{IKE: "partner-b", Name: "net", State: "INSTALLED", RemoteHost: "198.51.100.2"},
+			{IKE: "partner-d", Name: "net", State: "INSTALLED", RemoteHost: "198.51.100.4"},
+		},
+	}
+}
+
+func TestCompare(t *testing.T) {
+	root, err := swanconf.Parse(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Compare(root, testLoaded())
+
+	if want := (swanload.Delta{Added: []string{"partner-c"}, Changed: []string{"partner-a"}, Removed: []string{"partner-d"}}); !reflect.DeepEqual(r.Conns, want) {
+		t.Errorf("connections = %+v, want %+v", r.Conns, want)
+	}
+	if want := []string{"remote_addrs: 198.51.100.1 -> 198.51.100.10", "children: -old"}; !reflect.DeepEqual(r.Details["connections.partner-a"], want) {
+		t.Errorf("details = %q, want %q", r.Details["connections.partner-a"], want)
+	}
+	if want := (swanload.Delta{Removed: []string{"eap-alice"}}); !reflect.DeepEqual(r.Secrets, want) {
+		t.Errorf("secrets = %+v, want %+v", r.Secrets, want)
+	}
+	if want := (swanload.Delta{Changed: []string{"rw"}}); !reflect.DeepEqual(r.Pools, want) {
+		t.Errorf("pools = %+v, want %+v", r.Pools, want)
+	}
+	if !r.Authorities.Empty() {
+		t.Errorf("expected the authorities to match, got %+v", r.Authorities)
+	}
+	want := []SA{
+		{IKE: "partner-a", Child: "net", State: "INSTALLED", RemoteHost: "198.51.100.1", Reason: ReasonChanged},
+		{IKE: "partner-d", Child: "net", State: "INSTALLED", RemoteHost: "198.51.100.4", Reason: ReasonRemoved},
+	}
+	if !reflect.DeepEqual(r.Affected, want) {
+		t.Errorf("affected = %+v, want %+v", r.Affected, want)
+	}
+
+	out := r.String()
+	for _, line := range []string{
+		"Connections: +partner-c ~partner-a -partner-d\n",
+		"  partner-a: remote_addrs: 198.51.100.1 -> 198.51.100.10\n",
+		"  rw: size: 126 -> 254\n",
+		"Authorities: no changes\n",
+		"  partner-d/net (INSTALLED, 198.51.100.4): connection removed\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected %q in:\n%s", line, out)
+		}
+	}
+}
+
+func TestCompare_Unchanged(t *testing.T) {
+	root, err := swanconf.Parse(strings.NewReader(`connections {
+	partner {
+		remote_addrs = 198.51.100.1
+		children {
+			net {
+				remote_ts = 10.2.0.0/16
+			}
+		}
+	}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := []viciconn.Auth{{Class: "any"}}
+	r := Compare(root, &Loaded{Conns: []viciconn.Conn{{
+		Name:        "partner",
+		Version:     "IKEv1/2",
+		LocalAddrs:  []string{"%any"},
+		RemoteAddrs: []string{"198.51.100.1"},
+		Local:       auth,
+		Remote:      auth,
+		Children:    []viciconn.Child{{Name: "net", Mode: "TUNNEL", LocalTS: []string{"dynamic"}, RemoteTS: []string{"10.2.0.0/16"}}},
+	}}})
+	if !r.Empty() {
+		t.Errorf("expected no changes, got %+v", r)
+	}
+	if !strings.HasPrefix(r.String(), "Nothing to change") {
+		t.Errorf("String() = %q", r.String())
+	}
+}
+
+func TestPoolRange(t *testing.T) {
+	for _, tt := range []struct {
+		addrs string
+		base  string
+		size  int
+	}{
+		{"10.10.0.0/24", "10.10.0.0", 254},
+		{"10.10.0.7/30", "10.10.0.4", 2},
+		{"10.20.0.1-10.20.0.20", "10.20.0.1", 20},
+		{"10.30.0.1", "10.30.0.1", 1},
+		{"fd00::/64", "fd00::", 0},
+	} {
+		base, size, ok := poolRange(tt.addrs)
+		if !ok || base != tt.base || size != tt.size {
+			t.Errorf("poolRange(%q) = %q, %d, %v, want %q, %d", tt.addrs, base, size, ok, tt.base, tt.size)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package swanload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+// Delta lists the sections of one kind that were added, changed or removed,
+// by name.
+type Delta struct {
+	Added   []string `json:"added,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+func (d *Delta) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// Len is the number of sections that differ.
+func (d *Delta) Len() int {
+	return len(d.Added) + len(d.Changed) + len(d.Removed)
+}
+
+func (d *Delta) String() string {
+	var parts []string
+	for _, name := range d.Added {
+		parts = append(parts, "+"+name)
+	}
+	for _, name := range d.Changed {
+		parts = append(parts, "~"+name)
+	}
+	for _, name := range d.Removed {
+		parts = append(parts, "-"+name)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Changes are what differs between two versions of swanctl.conf: its
+// connections, shared secrets and pools.
+type Changes struct {
+	Conns   Delta `json:"connections"`
+	Secrets Delta `json:"secrets"`
+	Pools   Delta `json:"pools"`
+}
+
+func (c *Changes) Empty() bool {
+	return c.Conns.Empty() && c.Secrets.Empty() && c.Pools.Empty()
+}
+
+func (c *Changes) String() string {
+	var parts []string
+	for _, d := range []struct {
+		delta *Delta
+		name  string
+	}{{&c.Conns, "connections"}, {&c.Secrets, "secrets"}, {&c.Pools, "pools"}} {
+		if !d.delta.Empty() {
+			parts = append(parts, d.name+" "+d.delta.String())
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing changed"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Compare lists what changed from before to after. Secrets that are not
+// shared secrets are left out, since they are not loaded. Either may be
+// nil for an empty configuration.
+func Compare(before, after *swanconf.Section) *Changes {
+	return &Changes{
+		Conns:   compare(before, after, "connections", nil),
+		Secrets: compare(before, after, "secrets", IsShared),
+		Pools:   compare(before, after, "pools", nil),
+	}
+}
+
+// IsShared reports whether the secrets subsection sec holds a shared
+// secret, such as ike-partner, rather than a private key or token.
+func IsShared(sec *swanconf.Section) bool {
+	_, ok := sharedType(sec)
+	return ok
+}
+
+func compare(before, after *swanconf.Section, kind string, keep func(*swanconf.Section) bool) Delta {
+	old := map[string]*swanconf.Section{}
+	for _, sec := range sections(before, kind, keep) {
+		old[sec.Name] = sec
+	}
+	var d Delta
+	for _, sec := range sections(after, kind, keep) {
+		prev, ok := old[sec.Name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, sec.Name)
+		case !reflect.DeepEqual(prev, sec):
+			d.Changed = append(d.Changed, sec.Name)
+		}
+		delete(old, sec.Name)
+	}
+	for _, sec := range sections(before, kind, keep) {
+		if _, ok := old[sec.Name]; ok {
+			d.Removed = append(d.Removed, sec.Name)
+		}
+	}
+	return d
+}
+
+// sections returns the subsections of root's section kind that keep, nil
+// for all, accepts.
+func sections(root *swanconf.Section, kind string, keep func(*swanconf.Section) bool) []*swanconf.Section {
+	if root == nil {
+		return nil
+	}
+	parent := root.Section(kind)
+	if parent == nil {
+		return nil
+	}
+	var list []*swanconf.Section
+	for _, sec := range parent.Sections {
+		if keep == nil || keep(sec) {
+			list = append(list, sec)
+		}
+	}
+	return list
+}
+
+// ApplyChanges loads the sections of root that changes has as added or
+// changed and unloads the removed ones, leaving everything else in charon
+// alone. Connections replaced by load-conn keep their established SAs. A
+// section charon rejects stays as it was loaded before, and the others are
+// applied anyway; the errors are returned joined.
+func ApplyChanges(ctx context.Context, session *vici.Session, root *swanconf.Section, dir string, changes *Changes) error {
+	if err := Check(root, dir); err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range load(&changes.Secrets) {
+		s, err := ParseShared(root.Section("secrets", name))
+		if err == nil {
+			err = LoadShared(ctx, session, s)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("secrets.%s: %w", name, err))
+		}
+	}
+	for _, name := range load(&changes.Pools) {
+		if err := LoadPool(ctx, session, root.Section("pools", name)); err != nil {
+			errs = append(errs, fmt.Errorf("pools.%s: %w", name, err))
+		}
+	}
+	for _, name := range load(&changes.Conns) {
+		if err := LoadConn(ctx, session, root.Section("connections", name), dir); err != nil {
+			errs = append(errs, fmt.Errorf("connections.%s: %w", name, err))
+		}
+	}
+
+	// Unload only once what replaces them is loaded.
+	for _, name := range changes.Conns.Removed {
+		if err := UnloadConn(ctx, session, name); err != nil {
+			errs = append(errs, fmt.Errorf("connections.%s: %w", name, err))
+		}
+	}
+	for _, name := range changes.Pools.Removed {
+		if err := viciconn.UnloadPool(ctx, session, name); err != nil {
+			errs = append(errs, fmt.Errorf("pools.%s: %w", name, err))
+		}
+	}
+	for _, name := range changes.Secrets.Removed {
+		if err := viciconn.UnloadShared(ctx, session, name); err != nil {
+			errs = append(errs, fmt.Errorf("secrets.%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func load(d *Delta) []string {
+	return append(append([]string(nil), d.Added...), d.Changed...)
+}
@@ -0,0 +1,72 @@
+package swanload
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	before := parse(t, `connections {
+	a {
+		remote_addrs = 198.51.100.1
+	}
+	b {
+		remote_addrs = 198.51.100.2
+	}
+	c {
+		remote_addrs = 198.51.100.3
+	}
+}
+secrets {
+	ike-a {
+		secret = first
+	}
+	private-gw {
+		file = gw.pem
+	}
+}
+pools {
+	rw {
+		addrs = 10.10.0.0/24
+	}
+}`)
+	after := parse(t, `connections {
+	a {
+		remote_addrs = 198.51.100.1
+	}
+	b {
+		remote_addrs = 198.51.100.20
+	}
+	d {
+		remote_addrs = 198.51.100.4
+	}
+}
+secrets {
+	ike-a {
+		secret = second
+	}
+	private-gw {
+		file = other.pem
+	}
+}`)
+
+	got := Compare(before, after)
+	want := &Changes{
+		Conns:   Delta{Added: []string{"d"}, Changed: []string{"b"}, Removed: []string{"c"}},
+		Secrets: Delta{Changed: []string{"ike-a"}},
+		Pools:   Delta{Removed: []string{"rw"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Compare() = %+v, want %+v", got, want)
+	}
+	if s := got.String(); s != "connections +d ~b -c; secrets ~ike-a; pools -rw" {
+		t.Errorf("String() = %q", s)
+	}
+
+	if got := Compare(after, after); !got.Empty() {
+		t.Errorf("expected no changes, got %s", got)
+	}
+	if got := Compare(nil, after); !reflect.DeepEqual(got.Conns.Added, []string{"a", "b", "d"}) || got.Secrets.Added[0] != "ike-a" {
+		t.Errorf("expected everything to be added, got %s", got)
+	}
+}
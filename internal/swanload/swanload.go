@@ -0,0 +1,297 @@
+// Package swanload loads swanctl.conf sections into charon over VICI the
+// way swanctl --load-conns, --load-pools and --load-creds do, so single
+// connections can be loaded without reloading everything.
+package swanload
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+// listKeys are the connection settings swanctl sends as lists.
+var listKeys = map[string]bool{
+	"local_addrs":   true,
+	"remote_addrs":  true,
+	"proposals":     true,
+	"esp_proposals": true,
+	"ah_proposals":  true,
+	"local_ts":      true,
+	"remote_ts":     true,
+	"vips":          true,
+	"pools":         true,
+	"groups":        true,
+	"cert_policy":   true,
+}
+
+// fileKeys name files, relative to a directory of the swanctl directory,
+// whose contents swanctl sends instead of the names.
+var fileKeys = map[string]string{
+	"certs":   "x509",
+	"cacerts": "x509ca",
+	"pubkeys": "pubkey",
+}
+
+// sharedTypes are the secrets sections that hold shared secrets, by the
+// prefix of their name.
+var sharedTypes = map[string]string{
+	"ike":   "IKE",
+	"eap":   "EAP",
+	"xauth": "XAUTH",
+	"ntlm":  "NTLM",
+	"ppk":   "PPK",
+}
+
+// ConnMessage converts the connection conn of swanctl.conf to the message
+// load-conn expects. Certificate and public key files are read relative to
+// dir, the swanctl directory.
+func ConnMessage(conn *swanconf.Section, dir string) (*vici.Message, error) {
+	return message(conn, dir)
+}
+
+func message(sec *swanconf.Section, dir string) (*vici.Message, error) {
+	msg := vici.NewMessage()
+	for _, kv := range sec.Settings {
+		var value any = kv.Value
+		switch {
+		case fileKeys[kv.Key] != "":
+			data, err := readFiles(filepath.Join(dir, fileKeys[kv.Key]), kv.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", sec.Name, kv.Key, err)
+			}
+			value = data
+		case listKeys[kv.Key]:
+			value = splitList(kv.Value)
+		}
+		if err := msg.Set(kv.Key, value); err != nil {
+			return nil, err
+		}
+	}
+	for _, sub := range sec.Sections {
+		m, err := message(sub, dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%w", sec.Name, err)
+		}
+		if err := msg.Set(sub.Name, m); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+func readFiles(dir, names string) ([]string, error) {
+	var data []string
+	for _, name := range splitList(names) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, string(b))
+	}
+	return data, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// LoadConn loads the connection conn, replacing a loaded one of the same
+// name.
+func LoadConn(ctx context.Context, session *vici.Session, conn *swanconf.Section, dir string) error {
+	m, err := ConnMessage(conn, dir)
+	if err != nil {
+		return err
+	}
+	msg := vici.NewMessage()
+	if err := msg.Set(conn.Name, m); err != nil {
+		return err
+	}
+	_, err = session.Call(ctx, "load-conn", msg)
+	return err
+}
+
+func UnloadConn(ctx context.Context, session *vici.Session, name string) error {
+	msg := vici.NewMessage()
+	if err := msg.Set("name", name); err != nil {
+		return err
+	}
+	_, err := session.Call(ctx, "unload-conn", msg)
+	return err
+}
+
+// Shared is a shared secret of the secrets section.
+type Shared struct {
+	ID     string
+	Type   string
+	Data   string
+	Owners []string
+}
+
+// ParseShared converts a secrets subsection such as ike-partner to the
+// secret it holds. Private keys and tokens are not shared secrets and are
+// refused.
+func ParseShared(sec *swanconf.Section) (*Shared, error) {
+	typ, ok := sharedType(sec)
+	if !ok {
+		return nil, fmt.Errorf("secrets.%s: not a shared secret", sec.Name)
+	}
+	data, err := decodeSecret(sec.Get("secret"))
+	if err != nil {
+		return nil, fmt.Errorf("secrets.%s: %w", sec.Name, err)
+	}
+	s := &Shared{ID: sec.Name, Type: typ, Data: data}
+	for _, kv := range sec.Settings {
+		if kv.Key == "id" || strings.HasPrefix(kv.Key, "id-") {
+			s.Owners = append(s.Owners, kv.Value)
+		}
+	}
+	return s, nil
+}
+
+func sharedType(sec *swanconf.Section) (string, bool) {
+	prefix, _, _ := strings.Cut(sec.Name, "-")
+	typ, ok := sharedTypes[strings.TrimRight(prefix, "0123456789")]
+	return typ, ok
+}
+
+// decodeSecret decodes the 0x (hex) and 0s (base64) forms of a secret.
+func decodeSecret(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, "0x"):
+		b, err := hex.DecodeString(s[2:])
+		return string(b), err
+	case strings.HasPrefix(s, "0s"):
+		b, err := base64.StdEncoding.DecodeString(s[2:])
+		return string(b), err
+	}
+	return s, nil
+}
+
+func LoadShared(ctx context.Context, session *vici.Session, s *Shared) error {
+	return viciconn.LoadShared(ctx, session, s.ID, s.Type, s.Data, s.Owners)
+}
+
+// LoadPool loads the pool sec. Every attribute but addrs is a list.
+func LoadPool(ctx context.Context, session *vici.Session, sec *swanconf.Section) error {
+	pool := vici.NewMessage()
+	for _, kv := range sec.Settings {
+		var value any = splitList(kv.Value)
+		if kv.Key == "addrs" {
+			value = kv.Value
+		}
+		if err := pool.Set(kv.Key, value); err != nil {
+			return err
+		}
+	}
+	msg := vici.NewMessage()
+	if err := msg.Set(sec.Name, pool); err != nil {
+		return err
+	}
+	_, err := session.Call(ctx, "load-pool", msg)
+	return err
+}
+
+// Check converts everything Load would load without loading it.
+func Check(root *swanconf.Section, dir string) error {
+	var errs []error
+	if secrets := root.Section("secrets"); secrets != nil {
+		for _, sec := range secrets.Sections {
+			if _, err := ParseShared(sec); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if conns := root.Section("connections"); conns != nil {
+		for _, conn := range conns.Sections {
+			if _, err := ConnMessage(conn, dir); err != nil {
+				errs = append(errs, fmt.Errorf("connections.%w", err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Load loads the shared secrets, pools and connections of root, in that
+// order so connections find what they refer to.
+func Load(ctx context.Context, session *vici.Session, root *swanconf.Section, dir string) error {
+	if err := Check(root, dir); err != nil {
+		return err
+	}
+	if secrets := root.Section("secrets"); secrets != nil {
+		for _, sec := range secrets.Sections {
+			s, err := ParseShared(sec)
+			if err != nil {
+				return err
+			}
+			if err := LoadShared(ctx, session, s); err != nil {
+				return fmt.Errorf("secrets.%s: %w", sec.Name, err)
+			}
+		}
+	}
+	if pools := root.Section("pools"); pools != nil {
+		for _, sec := range pools.Sections {
+			if err := LoadPool(ctx, session, sec); err != nil {
+				return fmt.Errorf("pools.%s: %w", sec.Name, err)
+			}
+		}
+	}
+	if conns := root.Section("connections"); conns != nil {
+		for _, conn := range conns.Sections {
+			if err := LoadConn(ctx, session, conn, dir); err != nil {
+				return fmt.Errorf("connections.%s: %w", conn.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply makes charon's connections those of root: it loads root like Load
+// and unloads the connections root no longer has. Private keys and tokens
+// in root are left as loaded, and so are secrets and pools root no longer
+// has, since road warriors' secrets and pools are loaded at runtime.
+func Apply(ctx context.Context, session *vici.Session, root *swanconf.Section, dir string) error {
+	root = root.Clone()
+	if secrets := root.Section("secrets"); secrets != nil {
+		secrets.Sections = slices.DeleteFunc(secrets.Sections, func(sec *swanconf.Section) bool {
+			_, ok := sharedType(sec)
+			return !ok
+		})
+	}
+	if err := Load(ctx, session, root, dir); err != nil {
+		return err
+	}
+
+	loaded, err := viciconn.Conns(ctx, session)
+	if err != nil {
+		return err
+	}
+	for _, c := range loaded {
+		if root.Section("connections", c.Name) != nil {
+			continue
+		}
+		if err := UnloadConn(ctx, session, c.Name); err != nil {
+			return fmt.Errorf("connections.%s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
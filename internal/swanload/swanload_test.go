@@ -0,0 +1,132 @@
+package swanload
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/strongswan/govici/vici"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+)
+
+func parse(t *testing.T, s string) *swanconf.Section {
+	t.Helper()
+	root, err := swanconf.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestConnMessage(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "x509"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "x509", "gw.pem"), []byte("CERT"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	root := parse(t, `connections {
+	partner {
+		remote_addrs = 198.51.100.1, 198.51.100.2
+		proposals = aes256-sha256-ecp256
+		local {
+			auth = pubkey
+			certs = gw.pem
+		}
+		children {
+			partner {
+				local_ts = 10.1.0.0/16,10.2.0.0/16
+				start_action = start
+			}
+		}
+	}
+}`)
+	msg, err := ConnMessage(root.Section("connections", "partner"), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Get("remote_addrs"); !reflect.DeepEqual(got, []string{"198.51.100.1", "198.51.100.2"}) {
+		t.Errorf("remote_addrs = %#v", got)
+	}
+	if got := msg.Get("proposals"); !reflect.DeepEqual(got, []string{"aes256-sha256-ecp256"}) {
+		t.Errorf("proposals = %#v", got)
+	}
+	local, ok := msg.Get("local").(*vici.Message)
+	if !ok {
+		t.Fatalf("local = %#v", msg.Get("local"))
+	}
+	if got := local.Get("certs"); !reflect.DeepEqual(got, []string{"CERT"}) {
+		t.Errorf("certs = %#v, want the file's content", got)
+	}
+	if got := local.Get("auth"); got != "pubkey" {
+		t.Errorf("auth = %#v", got)
+	}
+	children, ok := msg.Get("children").(*vici.Message)
+	if !ok {
+		t.Fatalf("children = %#v", msg.Get("children"))
+	}
+	child, ok := children.Get("partner").(*vici.Message)
+	if !ok {
+		t.Fatalf("child = %#v", children.Get("partner"))
+	}
+	if got := child.Get("local_ts"); !reflect.DeepEqual(got, []string{"10.1.0.0/16", "10.2.0.0/16"}) {
+		t.Errorf("local_ts = %#v", got)
+	}
+	if got := child.Get("start_action"); got != "start" {
+		t.Errorf("start_action = %#v", got)
+	}
+
+	root.Section("connections", "partner", "local").Set("certs", "missing.pem")
+	if _, err := ConnMessage(root.Section("connections", "partner"), dir); err == nil || !strings.Contains(err.Error(), "partner.local.certs") {
+		t.Errorf("expected an error naming the missing certificate setting, got %v", err)
+	}
+}
+
+func TestParseShared(t *testing.T) {
+	root := parse(t, `secrets {
+	ike-partner {
+		id-local = 203.0.113.1
+		id-remote = 198.51.100.1
+		secret = 0x736563726574
+	}
+	eap1 {
+		id = alice
+		secret = 0sc2VjcmV0
+	}
+	ike {
+		secret = "plain secret"
+	}
+	rsa-gw {
+		file = gw.pem
+	}
+}`)
+	secrets := root.Section("secrets")
+	tests := []struct {
+		want *Shared
+		name string
+	}{
+		{name: "ike-partner", want: &Shared{ID: "ike-partner", Type: "IKE", Data: "secret", Owners: []string{"203.0.113.1", "198.51.100.1"}}},
+		{name: "eap1", want: &Shared{ID: "eap1", Type: "EAP", Data: "secret", Owners: []string{"alice"}}},
+		{name: "ike", want: &Shared{ID: "ike", Type: "IKE", Data: "plain secret"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseShared(secrets.Section(tt.name))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if _, err := ParseShared(secrets.Section("rsa-gw")); err == nil {
+		t.Error("expected a private key to be refused")
+	}
+	if err := Check(root, t.TempDir()); err == nil {
+		t.Error("expected Check to report the private key")
+	}
+}
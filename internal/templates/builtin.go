@@ -0,0 +1,426 @@
+package templates
+
+import (
+	"github.com/klowdo/tailswan/internal/roadwarrior"
+	"github.com/klowdo/tailswan/internal/swanconf"
+)
+
+// Proposals of the crypto parameter's choices.
+const (
+	modernIKE     = "aes256gcm16-prfsha256-ecp256"
+	modernESP     = "aes256gcm16-ecp256"
+	compatibleIKE = "aes256-sha256-modp2048"
+	compatibleESP = "aes256-sha256-modp2048"
+)
+
+// AWS site-to-site VPN: IKEv2 with DH groups 2 and 14-24, phase 1 lifetime
+// 28800s, phase 2 3600s. charon rekeys well before AWS does.
+const (
+	awsIKE = "aes256gcm16-prfsha256-modp2048,aes256-sha256-modp2048"
+	awsESP = "aes256gcm16-modp2048,aes256-sha256-modp2048"
+)
+
+// Azure VPN Gateway: the default policy only offers DH group 2 and no PFS;
+// the custom one matches an IPsec/IKE policy of DHGroup14 and PFS2048.
+// Azure's SAs live 28800s (IKE) and 27000s (IPsec).
+const (
+	azureDefaultIKE = "aes256-sha256-modp1024,aes256-sha1-modp1024"
+	azureDefaultESP = "aes256gcm16,aes256-sha256,aes256-sha1"
+	azureCustomIKE  = "aes256-sha256-modp2048"
+	azureCustomESP  = "aes256gcm16-modp2048"
+)
+
+func nameParam(def string) Param {
+	return Param{Name: "name", Label: "Connection name", Type: TypeName, Default: def, Required: true}
+}
+
+var (
+	remoteAddrsParam = Param{
+		Name: "remote_addrs", Label: "Peer address", Type: TypeHost, Required: true,
+		Help: "Public address or DNS name of the other gateway",
+	}
+	localAddrsParam = Param{
+		Name: "local_addrs", Label: "Local address", Type: TypeHost,
+		Help: "Address TailSwan uses for the tunnel; empty uses any",
+	}
+	localIDParam = Param{
+		Name: "local_id", Label: "Local identity", Type: TypeIdentity,
+		Help: "Identity TailSwan sends; empty sends its address",
+	}
+	remoteIDParam = Param{
+		Name: "remote_id", Label: "Peer identity", Type: TypeIdentity,
+		Help: "Identity the peer sends; empty expects its address",
+	}
+	localTSParam = Param{
+		Name: "local_ts", Label: "Local subnets", Type: TypeSubnets, Required: true,
+		Help: "Networks on this side the peer may reach, e.g. tailnet routes",
+	}
+	remoteTSParam = Param{
+		Name: "remote_ts", Label: "Remote subnets", Type: TypeSubnets, Required: true,
+		Help: "Networks behind the peer, advertised to the tailnet",
+	}
+	pskParam = Param{
+		Name: "psk", Label: "Pre-shared key", Type: TypeSecret, Required: true,
+		Help: "Shared with the peer's administrator out of band",
+	}
+	cryptoParam = Param{
+		Name: "crypto", Label: "Algorithms", Type: TypeChoice, Default: "modern",
+		Options: []string{"modern", "compatible"},
+		Help:    "modern: " + modernIKE + "; compatible: " + compatibleIKE + " for older devices",
+	}
+	startParam = Param{
+		Name: "start", Label: "Start", Type: TypeChoice, Default: "start",
+		Options: []string{"start", "trap", "none"},
+		Help:    "start: keep the tunnel up; trap: bring it up on traffic; none: only on demand",
+	}
+)
+
+var builtin = []*Template{
+	{
+		Name:        "psk-site-to-site",
+		Title:       "Site-to-site (pre-shared key)",
+		Description: "IKEv2 tunnel to another gateway authenticated with a pre-shared key.",
+		Params: []Param{
+			nameParam(""), remoteAddrsParam, localAddrsParam, pskParam,
+			localIDParam, remoteIDParam, localTSParam, remoteTSParam, cryptoParam, startParam,
+		},
+		render: renderPSK,
+	},
+	{
+		Name:        "cert-site-to-site",
+		Title:       "Site-to-site (certificates)",
+		Description: "IKEv2 tunnel to another gateway, both sides authenticated with X.509 certificates.",
+		Params: []Param{
+			nameParam(""), remoteAddrsParam, localAddrsParam,
+			{
+				Name: "local_cert", Label: "Local certificate", Type: TypeCert, Required: true,
+				Help: "File in the swanctl x509 directory; its private key must be installed too",
+			},
+			{
+				Name: "local_id", Label: "Local identity", Type: TypeIdentity,
+				Help: "A SAN of the local certificate; empty uses its subject",
+			},
+			{
+				Name: "remote_id", Label: "Peer identity", Type: TypeIdentity, Required: true,
+				Help: "Subject or SAN of the peer's certificate",
+			},
+			{
+				Name: "ca_cert", Label: "Peer CA", Type: TypeCert,
+				Help: "File in the swanctl x509ca directory the peer's certificate must chain to; empty trusts every CA",
+			},
+			localTSParam, remoteTSParam, cryptoParam, startParam,
+		},
+		render: renderCert,
+	},
+	{
+		Name:        "aws-vgw",
+		Title:       "AWS site-to-site VPN",
+		Description: "Both tunnels of an AWS VPN connection to a virtual private or transit gateway, with static routing.",
+		Params: []Param{
+			nameParam("aws"),
+			{
+				Name: "local_id", Label: "Customer gateway address", Type: TypeHost, Required: true,
+				Help: "Public IP of the customer gateway defined in AWS",
+			},
+			localAddrsParam,
+			{Name: "tunnel1_address", Label: "Tunnel 1 outside address", Type: TypeHost, Required: true},
+			{Name: "tunnel1_psk", Label: "Tunnel 1 pre-shared key", Type: TypeSecret, Required: true},
+			{Name: "tunnel2_address", Label: "Tunnel 2 outside address", Type: TypeHost, Required: true},
+			{Name: "tunnel2_psk", Label: "Tunnel 2 pre-shared key", Type: TypeSecret, Required: true},
+			localTSParam,
+			{
+				Name: "remote_ts", Label: "VPC CIDRs", Type: TypeSubnets, Required: true,
+				Help: "Address ranges of the VPCs behind the gateway",
+			},
+		},
+		render: renderAWS,
+	},
+	{
+		Name:        "azure-vpngw",
+		Title:       "Azure VPN Gateway",
+		Description: "Site-to-site connection to a route-based Azure virtual network gateway.",
+		Params: []Param{
+			nameParam("azure"),
+			{
+				Name: "remote_addrs", Label: "Gateway public IP", Type: TypeHost, Required: true,
+				Help: "Public IP of the Azure virtual network gateway",
+			},
+			localAddrsParam,
+			{
+				Name: "local_id", Label: "Local public IP", Type: TypeHost,
+				Help: "The local network gateway's IP address in Azure, when TailSwan is behind NAT",
+			},
+			pskParam, localTSParam,
+			{
+				Name: "remote_ts", Label: "VNet address space", Type: TypeSubnets, Required: true,
+				Help: "Address ranges of the virtual networks behind the gateway",
+			},
+			{
+				Name: "policy", Label: "IPsec/IKE policy", Type: TypeChoice, Default: "custom",
+				Options: []string{"custom", "default"},
+				Help:    "custom: a custom policy set on the Azure connection; default: Azure's default policy (DH group 2)",
+			},
+		},
+		render: renderAzure,
+	},
+	{
+		Name:        "road-warrior",
+		Title:       "Road warrior",
+		Description: "IKEv2 remote access for the users and pools managed under Road-warriors.",
+		Params: []Param{
+			nameParam("rw"),
+			{
+				Name: "server_id", Label: "Gateway identity", Type: TypeIdentity, Required: true,
+				Help: "Name clients connect to; must be a SAN of the server certificate",
+			},
+			{
+				Name: "server_cert", Label: "Server certificate", Type: TypeCert, Required: true,
+				Help: "File in the swanctl x509 directory, e.g. issued by the built-in CA with --profile server",
+			},
+			{
+				Name: "auth", Label: "Client authentication", Type: TypeChoice, Default: "eap-mschapv2",
+				Options: []string{"eap-mschapv2", "eap-tls"},
+			},
+			{Name: "pool", Label: "Address pool", Type: TypeName, Default: "rw", Required: true},
+			{
+				Name: "local_ts", Label: "Reachable subnets", Type: TypeSubnets, Default: "0.0.0.0/0", Required: true,
+				Help: "What clients reach through the tunnel; 0.0.0.0/0 sends them all their traffic",
+			},
+		},
+		render: renderRoadWarrior,
+	},
+}
+
+// section returns a section with the settings of key-value pairs kv,
+// skipping empty values.
+func section(name string, kv ...string) *swanconf.Section {
+	s := &swanconf.Section{Name: name}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] != "" {
+			s.Set(kv[i], kv[i+1])
+		}
+	}
+	return s
+}
+
+func conn(name string, settings []string, local, remote *swanconf.Section, child []string) *swanconf.Section {
+	c := section(name, settings...)
+	children := &swanconf.Section{Name: "children", Sections: []*swanconf.Section{section(name, child...)}}
+	c.Sections = append(c.Sections, local, remote, children)
+	return c
+}
+
+// pskSecret is the secret for a pre-shared key between two identities.
+// An empty identity matches any.
+func pskSecret(name, psk, localID, remoteID string) *swanconf.Section {
+	s := section("ike-"+name, "id-local", localID, "id-remote", remoteID)
+	s.Set("secret", psk)
+	return s
+}
+
+func rendered(conns, secrets []*swanconf.Section, notes ...string) *Rendered {
+	root := &swanconf.Section{}
+	root.Sections = append(root.Sections, &swanconf.Section{Name: "connections", Sections: conns})
+	if len(secrets) > 0 {
+		root.Sections = append(root.Sections, &swanconf.Section{Name: "secrets", Sections: secrets})
+	}
+	return &Rendered{Config: root, Notes: notes}
+}
+
+func crypto(v Values) (string, string) {
+	if v["crypto"] == "compatible" {
+		return compatibleIKE, compatibleESP
+	}
+	return modernIKE, modernESP
+}
+
+// dpdAction returns what to do with a CHILD_SA whose peer stopped
+// answering, in line with how it is started.
+func dpdAction(start string) string {
+	switch start {
+	case "start":
+		return "restart"
+	case "trap":
+		return "trap"
+	}
+	return "clear"
+}
+
+// addrID returns id, or addr when id is empty and addr is an IP address,
+// which is what charon sends and expects by default.
+func addrID(id, addr string) string {
+	if id == "" && isAddr(addr) {
+		return addr
+	}
+	return id
+}
+
+func exportNote(name string) string {
+	return "Once loaded, `tailswan export-peer-config " + name + "` generates the peer's side"
+}
+
+func renderPSK(v Values) *Rendered {
+	name := v["name"]
+	ike, esp := crypto(v)
+	start := v["start"]
+	if start == "none" {
+		start = ""
+	}
+	localID := addrID(v["local_id"], v["local_addrs"])
+	remoteID := addrID(v["remote_id"], v["remote_addrs"])
+	c := conn(name,
+		[]string{
+			"version", "2",
+			"local_addrs", v["local_addrs"],
+			"remote_addrs", v["remote_addrs"],
+			"proposals", ike,
+			"dpd_delay", "30s",
+		},
+		section("local", "auth", "psk", "id", localID),
+		section("remote", "auth", "psk", "id", remoteID),
+		[]string{
+			"local_ts", v["local_ts"],
+			"remote_ts", v["remote_ts"],
+			"esp_proposals", esp,
+			"start_action", start,
+			"dpd_action", dpdAction(v["start"]),
+		},
+	)
+	return rendered(
+		[]*swanconf.Section{c},
+		[]*swanconf.Section{pskSecret(name, v["psk"], localID, remoteID)},
+		exportNote(name),
+	)
+}
+
+func renderCert(v Values) *Rendered {
+	name := v["name"]
+	ike, esp := crypto(v)
+	start := v["start"]
+	if start == "none" {
+		start = ""
+	}
+	c := conn(name,
+		[]string{
+			"version", "2",
+			"local_addrs", v["local_addrs"],
+			"remote_addrs", v["remote_addrs"],
+			"proposals", ike,
+			"dpd_delay", "30s",
+		},
+		section("local", "auth", "pubkey", "certs", v["local_cert"], "id", v["local_id"]),
+		section("remote", "auth", "pubkey", "id", v["remote_id"], "cacerts", v["ca_cert"]),
+		[]string{
+			"local_ts", v["local_ts"],
+			"remote_ts", v["remote_ts"],
+			"esp_proposals", esp,
+			"start_action", start,
+			"dpd_action", dpdAction(v["start"]),
+		},
+	)
+	return rendered([]*swanconf.Section{c}, nil,
+		"The private key of "+v["local_cert"]+" must be in the swanctl private directory, e.g. issued with `tailswan pki issue <name> --profile server --install`",
+		exportNote(name),
+	)
+}
+
+func renderAWS(v Values) *Rendered {
+	name := v["name"]
+	var conns, secrets []*swanconf.Section
+	for i, tunnel := range []string{"1", "2"} {
+		tname := name + "-" + tunnel
+		addr := v["tunnel"+tunnel+"_address"]
+		start := ""
+		if i == 0 {
+			start = "start"
+		}
+		conns = append(conns, conn(tname,
+			[]string{
+				"version", "2",
+				"local_addrs", v["local_addrs"],
+				"remote_addrs", addr,
+				"proposals", awsIKE,
+				"rekey_time", "7h",
+				"dpd_delay", "10s",
+			},
+			section("local", "auth", "psk", "id", v["local_id"]),
+			section("remote", "auth", "psk", "id", addr),
+			[]string{
+				"local_ts", v["local_ts"],
+				"remote_ts", v["remote_ts"],
+				"esp_proposals", awsESP,
+				"rekey_time", "45m",
+				"life_time", "1h",
+				"start_action", start,
+				"dpd_action", "restart",
+			},
+		))
+		secrets = append(secrets, pskSecret(tname, v["tunnel"+tunnel+"_psk"], v["local_id"], addr))
+	}
+	return rendered(conns, secrets,
+		"Use static routing on the AWS VPN connection, with the local subnets as its static routes",
+		"AWS negotiates one pair of traffic selectors per tunnel: list one prefix on each side, and set the VPN connection's local IPv4 network CIDR to the local subnet and its remote IPv4 network CIDR to the VPC CIDR",
+		"Tunnel 2 is loaded but not started, since both tunnels carry the same subnets: when tunnel 1 fails, run `tailswan stop "+name+"-1` and `tailswan start "+name+"-2`",
+	)
+}
+
+func renderAzure(v Values) *Rendered {
+	name := v["name"]
+	ike, esp := azureCustomIKE, azureCustomESP
+	policyNote := "Set a custom IPsec/IKE policy on the Azure connection: IKE AES256, SHA256, DHGroup14; IPsec GCMAES256, GCMAES256, PFS2048; SA lifetime 27000 seconds"
+	if v["policy"] == "default" {
+		ike, esp = azureDefaultIKE, azureDefaultESP
+		policyNote = "Azure's default policy only offers the weak DH group 2 (modp1024); prefer policy=custom with a custom IPsec/IKE policy"
+	}
+	localID := addrID(v["local_id"], v["local_addrs"])
+	c := conn(name,
+		[]string{
+			"version", "2",
+			"local_addrs", v["local_addrs"],
+			"remote_addrs", v["remote_addrs"],
+			"proposals", ike,
+			"rekey_time", "7h",
+			"dpd_delay", "30s",
+		},
+		section("local", "auth", "psk", "id", localID),
+		section("remote", "auth", "psk", "id", addrID("", v["remote_addrs"])),
+		[]string{
+			"local_ts", v["local_ts"],
+			"remote_ts", v["remote_ts"],
+			"esp_proposals", esp,
+			"rekey_time", "7h",
+			"life_time", "7h30m",
+			"start_action", "start",
+			"dpd_action", "restart",
+		},
+	)
+	return rendered(
+		[]*swanconf.Section{c},
+		[]*swanconf.Section{pskSecret(name, v["psk"], localID, addrID("", v["remote_addrs"]))},
+		policyNote,
+		"The Azure local network gateway's address space must hold the local subnets",
+		"Azure negotiates one pair of traffic selectors; with several subnets on either side, enable UsePolicyBasedTrafficSelectors on the Azure connection, which needs the custom policy",
+	)
+}
+
+func renderRoadWarrior(v Values) *Rendered {
+	name := v["name"]
+	c := conn(name,
+		[]string{
+			"version", "2",
+			"pools", v["pool"],
+			"proposals", roadwarrior.IKEProposal,
+			"send_certreq", "no",
+		},
+		section("local", "certs", v["server_cert"], "id", v["server_id"]),
+		section("remote", "auth", v["auth"], "eap_id", "%any"),
+		[]string{
+			"local_ts", v["local_ts"],
+			"esp_proposals", roadwarrior.ESPProposal,
+		},
+	)
+	return rendered([]*swanconf.Section{c}, nil,
+		"Clients get addresses from pool "+v["pool"]+": create it with `tailswan rw pool add "+v["pool"]+" <addrs>`",
+		"Add users with `tailswan rw user add`; their profiles connect to RW_SERVER with identity RW_SERVER_ID, which should be "+v["server_id"],
+	)
+}
@@ -0,0 +1,217 @@
+// Package templates renders connections for common setups from a few
+// typed parameters, so nobody has to write swanctl.conf from scratch.
+package templates
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+)
+
+// ParamType says what a parameter holds and how it is validated.
+type ParamType string
+
+const (
+	// TypeName is a connection or pool name.
+	TypeName ParamType = "name"
+	// TypeHost is an IP address or DNS name.
+	TypeHost ParamType = "host"
+	// TypeSubnets is a comma separated list of prefixes.
+	TypeSubnets ParamType = "subnets"
+	// TypeSecret is a pre-shared key of at least minSecretLength
+	// characters.
+	TypeSecret ParamType = "secret"
+	// TypeIdentity is an IKE identity: an address, FQDN, email or DN.
+	TypeIdentity ParamType = "identity"
+	// TypeCert is a file name in the swanctl x509 or x509ca directory.
+	TypeCert ParamType = "cert"
+	// TypeChoice is one of the parameter's Options.
+	TypeChoice ParamType = "choice"
+)
+
+const minSecretLength = 16
+
+// Param is a parameter of a template.
+type Param struct {
+	Name     string    `json:"name"`
+	Label    string    `json:"label"`
+	Type     ParamType `json:"type"`
+	Default  string    `json:"default,omitempty"`
+	Help     string    `json:"help,omitempty"`
+	Options  []string  `json:"options,omitempty"`
+	Required bool      `json:"required"`
+}
+
+// Template renders a swanctl.conf snippet from parameter values.
+type Template struct {
+	render      func(v Values) *Rendered
+	Name        string  `json:"name"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Params      []Param `json:"params"`
+}
+
+// Values are parameter values by name.
+type Values map[string]string
+
+// Rendered is a rendered template: connections, and the secrets they
+// need, in swanctl.conf form, with notes for the operator. Name is the
+// value of the name parameter.
+type Rendered struct {
+	Config *swanconf.Section
+	Name   string
+	Notes  []string
+}
+
+// Connections returns the names of the rendered connections.
+func (r *Rendered) Connections() []string {
+	var names []string
+	if conns := r.Config.Section("connections"); conns != nil {
+		for _, c := range conns.Sections {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// String renders the snippet with the notes as leading comments.
+func (r *Rendered) String() string {
+	var b strings.Builder
+	for _, n := range r.Notes {
+		b.WriteString("# " + n + "\n")
+	}
+	if len(r.Notes) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString(r.Config.String())
+	return b.String()
+}
+
+// ValidationError lists the parameters with bad values and why.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, name+": "+e.Fields[name])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// All returns the built-in templates.
+func All() []*Template {
+	return builtin
+}
+
+// Lookup returns the built-in template name, or nil.
+func Lookup(name string) *Template {
+	for _, t := range builtin {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Render validates values, filling in defaults, and renders the
+// template. Values of unknown parameters are refused. Bad values are
+// reported as a *ValidationError.
+func (t *Template) Render(values Values) (*Rendered, error) {
+	v := Values{}
+	errs := map[string]string{}
+	for name := range values {
+		if !slices.ContainsFunc(t.Params, func(p Param) bool { return p.Name == name }) {
+			errs[name] = "unknown parameter"
+		}
+	}
+	for _, p := range t.Params {
+		value := strings.TrimSpace(values[p.Name])
+		if value == "" {
+			value = p.Default
+		}
+		if value == "" {
+			if p.Required {
+				errs[p.Name] = "required"
+			}
+			continue
+		}
+		if err := p.validate(value); err != nil {
+			errs[p.Name] = err.Error()
+			continue
+		}
+		v[p.Name] = value
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Fields: errs}
+	}
+	r := t.render(v)
+	r.Name = v["name"]
+	return r, nil
+}
+
+var (
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+	certPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	hostPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+)
+
+func (p *Param) validate(value string) error {
+	switch p.Type {
+	case TypeName:
+		if !namePattern.MatchString(value) {
+			return fmt.Errorf("use letters, digits, - and _")
+		}
+	case TypeHost:
+		if _, err := netip.ParseAddr(value); err != nil && !hostPattern.MatchString(value) {
+			return fmt.Errorf("%q is neither an IP address nor a DNS name", value)
+		}
+	case TypeSubnets:
+		for _, s := range strings.Split(value, ",") {
+			prefix, err := netip.ParsePrefix(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("%q is not a prefix such as 10.1.0.0/16", strings.TrimSpace(s))
+			}
+			if prefix != prefix.Masked() {
+				return fmt.Errorf("%s has host bits set, did you mean %s?", prefix, prefix.Masked())
+			}
+		}
+	case TypeSecret:
+		if len(value) < minSecretLength {
+			return fmt.Errorf("use at least %d characters", minSecretLength)
+		}
+		if strings.ContainsAny(value, "\"\\\n") {
+			return fmt.Errorf("must not contain quotes, backslashes or line breaks")
+		}
+	case TypeIdentity:
+		if strings.ContainsAny(value, "\"\n") {
+			return fmt.Errorf("must not contain quotes or line breaks")
+		}
+	case TypeCert:
+		if !certPattern.MatchString(value) {
+			return fmt.Errorf("expected a file name in the swanctl directory")
+		}
+	case TypeChoice:
+		if !slices.Contains(p.Options, value) {
+			return fmt.Errorf("expected one of %s", strings.Join(p.Options, ", "))
+		}
+	}
+	return nil
+}
+
+// isAddr reports whether s is an IP address rather than a DNS name.
+func isAddr(s string) bool {
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
@@ -0,0 +1,165 @@
+package templates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/swanconf"
+	"github.com/klowdo/tailswan/internal/swanload"
+)
+
+const psk = "correct-horse-battery-staple"
+
+var testValues = map[string]Values{
+	"psk-site-to-site": {
+		"name": "partner", "remote_addrs": "198.51.100.1", "psk": psk,
+		"local_ts": "10.1.0.0/16", "remote_ts": "10.2.0.0/16",
+	},
+	"cert-site-to-site": {
+		"name": "partner", "remote_addrs": "partner.example.com", "local_cert": "gw.pem",
+		"remote_id": "partner.example.com", "ca_cert": "partner-ca.pem",
+		"local_ts": "10.1.0.0/16", "remote_ts": "10.2.0.0/16", "start": "trap",
+	},
+	"aws-vgw": {
+		"local_id": "203.0.113.1", "tunnel1_address": "198.51.100.1", "tunnel1_psk": psk + "1",
+		"tunnel2_address": "198.51.100.2", "tunnel2_psk": psk + "2",
+		"local_ts": "10.1.0.0/16", "remote_ts": "172.31.0.0/16",
+	},
+	"azure-vpngw": {
+		"remote_addrs": "198.51.100.1", "psk": psk, "local_ts": "10.1.0.0/16", "remote_ts": "10.100.0.0/16",
+	},
+	"road-warrior": {
+		"server_id": "vpn.example.com", "server_cert": "vpn.pem",
+	},
+}
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	for sub, file := range map[string]string{"x509": "gw.pem", "x509ca": "partner-ca.pem"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, sub, file), []byte("CERT"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "x509", "vpn.pem"), []byte("CERT"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tmpl := range All() {
+		values, ok := testValues[tmpl.Name]
+		if !ok {
+			t.Errorf("no test values for template %s", tmpl.Name)
+			continue
+		}
+		r, err := tmpl.Render(values)
+		if err != nil {
+			t.Errorf("%s: %v", tmpl.Name, err)
+			continue
+		}
+		// The output must survive a round trip through the parser, notes
+		// and all, and convert to what charon loads.
+		root, err := swanconf.Parse(strings.NewReader(r.String()))
+		if err != nil {
+			t.Errorf("%s: output does not parse: %v\n%s", tmpl.Name, err, r)
+			continue
+		}
+		if !reflect.DeepEqual(root, r.Config) {
+			t.Errorf("%s: round trip changed the config:\n%s", tmpl.Name, r)
+		}
+		if err := swanload.Check(root, dir); err != nil {
+			t.Errorf("%s: %v", tmpl.Name, err)
+		}
+	}
+}
+
+func TestRender_Settings(t *testing.T) {
+	r, err := Lookup("psk-site-to-site").Render(testValues["psk-site-to-site"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := r.Config.Section("connections", "partner")
+	if got := conn.Section("remote").Get("id"); got != "198.51.100.1" {
+		t.Errorf("remote id = %q, want the peer's address", got)
+	}
+	if conn.Section("local").Has("id") {
+		t.Error("expected no local id without local_id or local_addrs")
+	}
+	child := conn.Section("children", "partner")
+	if child.Get("start_action") != "start" || child.Get("dpd_action") != "restart" {
+		t.Errorf("unexpected child %s", child)
+	}
+	if got := conn.Get("proposals"); got != modernIKE {
+		t.Errorf("proposals = %q", got)
+	}
+	secret := r.Config.Section("secrets", "ike-partner")
+	if secret.Get("secret") != psk || secret.Get("id-remote") != "198.51.100.1" || secret.Has("id-local") {
+		t.Errorf("unexpected secret %s", secret)
+	}
+
+	r, err = Lookup("aws-vgw").Render(testValues["aws-vgw"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Connections(); !reflect.DeepEqual(got, []string{"aws-1", "aws-2"}) {
+		t.Errorf("connections = %v", got)
+	}
+	if got := r.Config.Section("connections", "aws-1", "children", "aws-1").Get("start_action"); got != "start" {
+		t.Errorf("tunnel 1 start_action = %q", got)
+	}
+	if r.Config.Section("connections", "aws-2", "children", "aws-2").Has("start_action") {
+		t.Error("expected tunnel 2 not to be started")
+	}
+	if got := r.Config.Section("secrets", "ike-aws-2").Get("secret"); got != psk+"2" {
+		t.Errorf("tunnel 2 secret = %q", got)
+	}
+
+	r, err = Lookup("road-warrior").Render(testValues["road-warrior"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn = r.Config.Section("connections", "rw")
+	if conn.Get("pools") != "rw" || conn.Section("remote").Get("auth") != "eap-mschapv2" {
+		t.Errorf("unexpected defaults in %s", conn)
+	}
+	if r.Config.Section("secrets") != nil {
+		t.Error("expected no secrets for road warriors")
+	}
+}
+
+func TestRender_Validation(t *testing.T) {
+	_, err := Lookup("psk-site-to-site").Render(Values{
+		"name":         "bad name",
+		"remote_addrs": "not a host",
+		"psk":          "short",
+		"local_ts":     "10.1.0.1/16",
+		"remote_ts":    "10.2.0.0/16,nonsense",
+		"crypto":       "fast",
+		"colour":       "blue",
+	})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	for _, field := range []string{"name", "remote_addrs", "psk", "local_ts", "remote_ts", "crypto", "colour"} {
+		if verr.Fields[field] == "" {
+			t.Errorf("expected an error for %s, got %v", field, verr.Fields)
+		}
+	}
+	if !strings.Contains(verr.Fields["local_ts"], "10.1.0.0/16") {
+		t.Errorf("expected the masked prefix to be suggested, got %q", verr.Fields["local_ts"])
+	}
+
+	_, err = Lookup("aws-vgw").Render(Values{})
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	if verr.Fields["tunnel1_psk"] != "required" || verr.Fields["name"] != "" {
+		t.Errorf("expected missing required values to be reported and defaults used, got %v", verr.Fields)
+	}
+}
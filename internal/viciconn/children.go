@@ -0,0 +1,143 @@
+package viciconn
+
+import (
+	"context"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/strongswan/govici/vici"
+)
+
+type Child struct {
+	Connection string
+	Name       string
+	Mode       string
+	LocalTS    []string
+	RemoteTS   []string
+	RekeyTime  int
+	// IfIDIn and IfIDOut are the XFRM interface IDs the child's SAs and
+	// policies are bound to; zero means the child is policy based.
+	IfIDIn  uint32
+	IfIDOut uint32
+}
+
+const (
+	// IfIDUnique and IfIDUniqueDir are the values charon reports for
+	// if_id_in/if_id_out = %unique and %unique-dir.
+	IfIDUnique    uint32 = 0xffffffff
+	IfIDUniqueDir uint32 = 0xfffffffe
+)
+
+func Children(session *vici.Session) ([]Child, error) {
+	msg := vici.NewMessage()
+	var children []Child
+	for m, err := range session.CallStreaming(context.Background(), "list-conns", "list-conn", msg) {
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, parseChildren(m)...)
+	}
+	return children, nil
+}
+
+func parseChildren(m *vici.Message) []Child {
+	var children []Child
+	for _, conn := range m.Keys() {
+		connMsg, ok := m.Get(conn).(*vici.Message)
+		if !ok {
+			continue
+		}
+		childrenMsg, ok := connMsg.Get("children").(*vici.Message)
+		if !ok {
+			continue
+		}
+		for _, name := range childrenMsg.Keys() {
+			childMsg, ok := childrenMsg.Get(name).(*vici.Message)
+			if !ok {
+				continue
+			}
+			children = append(children, Child{
+				Connection: conn,
+				Name:       name,
+				Mode:       StringValue(childMsg.Get("mode")),
+				LocalTS:    ListValue(childMsg.Get("local-ts")),
+				RemoteTS:   ListValue(childMsg.Get("remote-ts")),
+				RekeyTime:  intValue(childMsg.Get("rekey_time")),
+				IfIDIn:     ifIDValue(childMsg, "in"),
+				IfIDOut:    ifIDValue(childMsg, "out"),
+			})
+		}
+	}
+	return children
+}
+
+// Prefixes parses traffic selectors into prefixes, skipping the "dynamic"
+// placeholder. A protocol/port restriction such as [tcp/443] is dropped,
+// keeping the subnet it applies to.
+func Prefixes(selectors []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(selectors))
+	for _, ts := range selectors {
+		ts, _, _ = strings.Cut(ts, "[")
+		if p, err := netip.ParsePrefix(ts); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(ts); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes
+}
+
+// ifIDValue reads an interface ID, which charon formats as hex.
+func ifIDValue(m *vici.Message, dir string) uint32 {
+	value := StringValue(m.Get("if_id_" + dir))
+	if value == "" {
+		value = StringValue(m.Get("if-id-" + dir))
+	}
+	id, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(id)
+}
+
+func StringValue(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+func ListValue(v any) []string {
+	switch val := v.(type) {
+	case []string:
+		return val
+	case string:
+		if val == "" {
+			return nil
+		}
+		return []string{val}
+	default:
+		return nil
+	}
+}
+
+// Map converts a message into plain maps, so nested sections such as an
+// IKE_SA's child-sas survive JSON encoding; *vici.Message has no exported
+// fields and would encode as {}.
+func Map(m *vici.Message) map[string]interface{} {
+	result := make(map[string]interface{}, len(m.Keys()))
+	for _, key := range m.Keys() {
+		result[key] = plainValue(m.Get(key))
+	}
+	return result
+}
+
+func plainValue(v any) any {
+	if sub, ok := v.(*vici.Message); ok {
+		return Map(sub)
+	}
+	return v
+}
@@ -0,0 +1,202 @@
+package viciconn
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+
+	"github.com/strongswan/govici/vici"
+)
+
+func buildConnMessage(t *testing.T) *vici.Message {
+	t.Helper()
+
+	child := vici.NewMessage()
+	mustSet(t, child, "mode", "TUNNEL")
+	mustSet(t, child, "local-ts", []string{"10.1.0.0/24"})
+	mustSet(t, child, "remote-ts", []string{"10.2.0.0/24", "10.3.0.0/24"})
+
+	children := vici.NewMessage()
+	mustSet(t, children, "net-net", child)
+
+	conn := vici.NewMessage()
+	mustSet(t, conn, "version", "IKEv2")
+	mustSet(t, conn, "children", children)
+
+	m := vici.NewMessage()
+	mustSet(t, m, "mysite", conn)
+	return m
+}
+
+func mustSet(t *testing.T, m *vici.Message, key string, value any) {
+	t.Helper()
+	if err := m.Set(key, value); err != nil {
+		t.Fatalf("failed to set %s: %v", key, err)
+	}
+}
+
+func TestParseChildren(t *testing.T) {
+	children := parseChildren(buildConnMessage(t))
+
+	if len(children) != 1 {
+		t.Fatalf("expected 1 child, got %d", len(children))
+	}
+
+	c := children[0]
+	if c.Connection != "mysite" {
+		t.Errorf("expected Connection %q, got %q", "mysite", c.Connection)
+	}
+	if c.Name != "net-net" {
+		t.Errorf("expected Name %q, got %q", "net-net", c.Name)
+	}
+	if c.Mode != "TUNNEL" {
+		t.Errorf("expected Mode %q, got %q", "TUNNEL", c.Mode)
+	}
+	if len(c.LocalTS) != 1 || c.LocalTS[0] != "10.1.0.0/24" {
+		t.Errorf("unexpected LocalTS %v", c.LocalTS)
+	}
+	if len(c.RemoteTS) != 2 {
+		t.Errorf("expected 2 RemoteTS, got %v", c.RemoteTS)
+	}
+	if c.IfIDIn != 0 || c.IfIDOut != 0 {
+		t.Errorf("expected policy-based child, got if_id %d/%d", c.IfIDIn, c.IfIDOut)
+	}
+}
+
+func TestParseChildrenIfID(t *testing.T) {
+	child := vici.NewMessage()
+	mustSet(t, child, "remote-ts", []string{"10.2.0.0/24"})
+	mustSet(t, child, "if_id_in", "0000002a")
+	mustSet(t, child, "if_id_out", "ffffffff")
+
+	children := vici.NewMessage()
+	mustSet(t, children, "net-net", child)
+	conn := vici.NewMessage()
+	mustSet(t, conn, "children", children)
+	m := vici.NewMessage()
+	mustSet(t, m, "mysite", conn)
+
+	c := parseChildren(m)[0]
+	if c.IfIDIn != 42 {
+		t.Errorf("expected IfIDIn 42, got %d", c.IfIDIn)
+	}
+	if c.IfIDOut != IfIDUnique {
+		t.Errorf("expected IfIDOut %%unique, got %#x", c.IfIDOut)
+	}
+}
+
+func TestParseChildrenWithoutChildren(t *testing.T) {
+	conn := vici.NewMessage()
+	mustSet(t, conn, "version", "IKEv2")
+	m := vici.NewMessage()
+	mustSet(t, m, "empty", conn)
+
+	if children := parseChildren(m); len(children) != 0 {
+		t.Errorf("expected no children, got %v", children)
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	got := Prefixes([]string{"10.2.0.1/24", "dynamic", "192.168.1.5", "fd00::/64", "10.0.0.0/8[tcp/443]", "192.168.2.1[udp]", "dynamic[tcp]"})
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.2.0.0/24"),
+		netip.MustParsePrefix("192.168.1.5/32"),
+		netip.MustParsePrefix("fd00::/64"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.2.1/32"),
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("index %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestListValue(t *testing.T) {
+	if got := ListValue([]string{"a", "b"}); len(got) != 2 {
+		t.Errorf("expected 2 values, got %v", got)
+	}
+	if got := ListValue("a"); len(got) != 1 || got[0] != "a" {
+		t.Errorf("expected [a], got %v", got)
+	}
+	if got := ListValue(""); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+	if got := ListValue(42); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+}
+
+func TestParseChildSAs(t *testing.T) {
+	child := vici.NewMessage()
+	mustSet(t, child, "name", "net-net")
+	mustSet(t, child, "state", "INSTALLED")
+	mustSet(t, child, "mode", "TUNNEL")
+	mustSet(t, child, "protocol", "ESP")
+	mustSet(t, child, "encr-alg", "AES_CBC")
+	mustSet(t, child, "encr-keysize", "256")
+	mustSet(t, child, "integ-alg", "HMAC_SHA2_256_128")
+	mustSet(t, child, "local-ts", []string{"10.1.0.0/24"})
+	mustSet(t, child, "remote-ts", []string{"10.2.0.0/24"})
+
+	childSAs := vici.NewMessage()
+	mustSet(t, childSAs, "net-net-7", child)
+
+	ike := vici.NewMessage()
+	mustSet(t, ike, "local-host", "192.0.2.1")
+	mustSet(t, ike, "remote-host", "203.0.113.10")
+	mustSet(t, ike, "nat-any", "yes")
+	mustSet(t, ike, "child-sas", childSAs)
+
+	m := vici.NewMessage()
+	mustSet(t, m, "mysite", ike)
+
+	sas := parseChildSAs(m)
+	if len(sas) != 1 {
+		t.Fatalf("expected 1 child SA, got %d", len(sas))
+	}
+
+	sa := sas[0]
+	if sa.IKE != "mysite" || sa.Name != "net-net" {
+		t.Errorf("unexpected names %q/%q", sa.IKE, sa.Name)
+	}
+	if sa.EncrAlg != "AES_CBC" || sa.EncrKeySize != 256 {
+		t.Errorf("unexpected encryption %q/%d", sa.EncrAlg, sa.EncrKeySize)
+	}
+	if sa.IntegAlg != "HMAC_SHA2_256_128" {
+		t.Errorf("unexpected integrity %q", sa.IntegAlg)
+	}
+	if sa.RemoteHost != "203.0.113.10" {
+		t.Errorf("unexpected remote host %q", sa.RemoteHost)
+	}
+	if !sa.UDPEncap {
+		t.Error("expected UDP encapsulation when NAT is detected")
+	}
+}
+
+func TestMap(t *testing.T) {
+	child := vici.NewMessage()
+	mustSet(t, child, "state", "INSTALLED")
+	childSAs := vici.NewMessage()
+	mustSet(t, childSAs, "net-net-7", child)
+
+	ike := vici.NewMessage()
+	mustSet(t, ike, "state", "ESTABLISHED")
+	mustSet(t, ike, "child-sas", childSAs)
+
+	m := vici.NewMessage()
+	mustSet(t, m, "mysite", ike)
+
+	data, err := json.Marshal(Map(m))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `{"mysite":{"child-sas":{"net-net-7":{"state":"INSTALLED"}},"state":"ESTABLISHED"}}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,96 @@
+package viciconn
+
+import (
+	"context"
+	"strings"
+
+	"github.com/strongswan/govici/vici"
+)
+
+// Conn is a connection charon has loaded, as reported by list-conns.
+// charon does not report proposals.
+type Conn struct {
+	Name        string
+	Version     string
+	LocalAddrs  []string
+	RemoteAddrs []string
+	Local       []Auth
+	Remote      []Auth
+	Children    []Child
+	RekeyTime   int
+	ReauthTime  int
+	DPDDelay    int
+}
+
+// Auth is one authentication round of a connection.
+type Auth struct {
+	// Class is "pre-shared key", "public key", "EAP", "XAuth" or "any".
+	Class   string
+	ID      string
+	EAPType string
+	Certs   []string
+	CACerts []string
+}
+
+func Conns(ctx context.Context, session *vici.Session) ([]Conn, error) {
+	var conns []Conn
+	for m, err := range session.CallStreaming(ctx, "list-conns", "list-conn", vici.NewMessage()) {
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, ParseConns(m)...)
+	}
+	return conns, nil
+}
+
+// ParseConns reads the connections of a list-conn message.
+func ParseConns(m *vici.Message) []Conn {
+	children := parseChildren(m)
+
+	var conns []Conn
+	for _, name := range m.Keys() {
+		msg, ok := m.Get(name).(*vici.Message)
+		if !ok {
+			continue
+		}
+		conn := Conn{
+			Name:        name,
+			Version:     StringValue(msg.Get("version")),
+			LocalAddrs:  ListValue(msg.Get("local_addrs")),
+			RemoteAddrs: ListValue(msg.Get("remote_addrs")),
+			RekeyTime:   intValue(msg.Get("rekey_time")),
+			ReauthTime:  intValue(msg.Get("reauth_time")),
+			DPDDelay:    intValue(msg.Get("dpd_delay")),
+		}
+		// Authentication rounds are listed as local-1, remote-1, ...
+		for _, key := range msg.Keys() {
+			section, ok := msg.Get(key).(*vici.Message)
+			if !ok {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(key, "local"):
+				conn.Local = append(conn.Local, parseAuth(section))
+			case strings.HasPrefix(key, "remote"):
+				conn.Remote = append(conn.Remote, parseAuth(section))
+			}
+		}
+		for _, child := range children {
+			if child.Connection == name {
+				conn.Children = append(conn.Children, child)
+			}
+		}
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+func parseAuth(m *vici.Message) Auth {
+	return Auth{
+		Class:   StringValue(m.Get("class")),
+		ID:      StringValue(m.Get("id")),
+		EAPType: StringValue(m.Get("eap-type")),
+		Certs:   ListValue(m.Get("certs")),
+		CACerts: ListValue(m.Get("cacerts")),
+	}
+}
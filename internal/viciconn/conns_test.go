@@ -0,0 +1,43 @@
+package viciconn
+
+import (
+	"testing"
+
+	"github.com/strongswan/govici/vici"
+)
+
+func TestParseConns(t *testing.T) {
+	m := buildConnMessage(t)
+	conn := m.Get("mysite").(*vici.Message)
+	mustSet(t, conn, "local_addrs", []string{"%any"})
+	mustSet(t, conn, "remote_addrs", []string{"203.0.113.10"})
+	mustSet(t, conn, "rekey_time", "14400")
+	mustSet(t, conn, "dpd_delay", "30")
+
+	local := vici.NewMessage()
+	mustSet(t, local, "class", "pre-shared key")
+	mustSet(t, local, "id", "gw.example.com")
+	mustSet(t, conn, "local-1", local)
+	remote := vici.NewMessage()
+	mustSet(t, remote, "class", "public key")
+	mustSet(t, remote, "cacerts", []string{"CN=Partner CA"})
+	mustSet(t, conn, "remote-1", remote)
+
+	conns := ParseConns(m)
+	if len(conns) != 1 {
+		t.Fatalf("expected 1 connection, got %+v", conns)
+	}
+	c := conns[0]
+	if c.Name != "mysite" || c.Version != "IKEv2" || c.RemoteAddrs[0] != "203.0.113.10" || c.RekeyTime != 14400 || c.DPDDelay != 30 {
+		t.Errorf("unexpected connection %+v", c)
+	}
+	if len(c.Local) != 1 || c.Local[0].Class != "pre-shared key" || c.Local[0].ID != "gw.example.com" {
+		t.Errorf("unexpected local auth %+v", c.Local)
+	}
+	if len(c.Remote) != 1 || c.Remote[0].Class != "public key" || c.Remote[0].CACerts[0] != "CN=Partner CA" {
+		t.Errorf("unexpected remote auth %+v", c.Remote)
+	}
+	if len(c.Children) != 1 || c.Children[0].Name != "net-net" || len(c.Children[0].RemoteTS) != 2 {
+		t.Errorf("unexpected children %+v", c.Children)
+	}
+}
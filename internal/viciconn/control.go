@@ -0,0 +1,109 @@
+package viciconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/strongswan/govici/vici"
+)
+
+// initiateTimeout bounds how long Initiate waits for the SA. charon keeps
+// trying in the background after the call returns.
+const initiateTimeout = 30 * time.Second
+
+// Initiate brings up the CHILD_SA named child.
+func Initiate(ctx context.Context, session *vici.Session, child string) error {
+	return initiate(ctx, session, "", child)
+}
+
+// InitiateIKE brings up the IKE_SA named ike with all the CHILD_SAs of its
+// connection.
+func InitiateIKE(ctx context.Context, session *vici.Session, ike string) error {
+	msg := vici.NewMessage()
+	if err := msg.Set("ike", ike); err != nil {
+		return err
+	}
+	var children []string
+	for m, err := range session.CallStreaming(ctx, "list-conns", "list-conn", msg) {
+		if err != nil {
+			return err
+		}
+		for _, conn := range ParseConns(m) {
+			for _, child := range conn.Children {
+				children = append(children, child.Name)
+			}
+		}
+	}
+	if len(children) == 0 {
+		return fmt.Errorf("connection %s is not loaded or has no children", ike)
+	}
+
+	var errs []error
+	for _, child := range children {
+		if err := initiate(ctx, session, ike, child); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", child, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// caller sends a command to charon; *vici.Session is one.
+type caller interface {
+	Call(ctx context.Context, cmd string, in *vici.Message) (*vici.Message, error)
+}
+
+func initiate(ctx context.Context, session caller, ike, child string) error {
+	msg := vici.NewMessage()
+	if ike != "" {
+		if err := msg.Set("ike", ike); err != nil {
+			return err
+		}
+	}
+	if err := msg.Set("child", child); err != nil {
+		return err
+	}
+	if err := msg.Set("timeout", strconv.FormatInt(initiateTimeout.Milliseconds(), 10)); err != nil {
+		return err
+	}
+	_, err := session.Call(ctx, "initiate", msg)
+	return err
+}
+
+// Terminate tears down the IKE_SA named ike together with its CHILD_SAs.
+func Terminate(ctx context.Context, session *vici.Session, ike string) error {
+	msg := vici.NewMessage()
+	if err := msg.Set("ike", ike); err != nil {
+		return err
+	}
+	_, err := session.Call(ctx, "terminate", msg)
+	return err
+}
+
+// RestartChild tears down the CHILD_SA sa, leaving its IKE_SA up, and
+// initiates the child again under the same connection. Child names are only
+// unique within a connection, so the SA is terminated by its unique id and
+// initiated with its IKE_SA's name.
+func RestartChild(ctx context.Context, session *vici.Session, sa *ChildSA) error {
+	return restartChild(ctx, session, sa)
+}
+
+func restartChild(ctx context.Context, session caller, sa *ChildSA) error {
+	if sa.UniqueID == "" {
+		return fmt.Errorf("CHILD_SA %s/%s has no unique id", sa.IKE, sa.Name)
+	}
+	var errs []error
+	msg := vici.NewMessage()
+	if err := msg.Set("child-id", sa.UniqueID); err != nil {
+		return err
+	}
+	if _, err := session.Call(ctx, "terminate", msg); err != nil {
+		errs = append(errs, fmt.Errorf("terminate: %w", err))
+	}
+	if err := initiate(ctx, session, sa.IKE, sa.Name); err != nil {
+		errs = append(errs, fmt.Errorf("initiate: %w", err))
+	}
+	return errors.Join(errs...)
+}
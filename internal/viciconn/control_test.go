@@ -0,0 +1,61 @@
+package viciconn
+
+import (
+	"context"
+	"testing"
+
+	"github.com/strongswan/govici/vici"
+)
+
+// recordingCaller records the commands sent to charon.
+type recordingCaller struct {
+	cmds []string
+	msgs []*vici.Message
+}
+
+func (c *recordingCaller) Call(_ context.Context, cmd string, in *vici.Message) (*vici.Message, error) {
+	c.cmds = append(c.cmds, cmd)
+	c.msgs = append(c.msgs, in)
+	return vici.NewMessage(), nil
+}
+
+func TestRestartChild(t *testing.T) {
+	m := vici.NewMessage()
+	for _, sa := range []struct{ ike, uniqueID string }{{"site-a", "3"}, {"site-b", "8"}} {
+		child := vici.NewMessage()
+		mustSet(t, child, "name", "net")
+		mustSet(t, child, "uniqueid", sa.uniqueID)
+		mustSet(t, child, "state", "INSTALLED")
+		childSAs := vici.NewMessage()
+		mustSet(t, childSAs, "net-"+sa.uniqueID, child)
+		ike := vici.NewMessage()
+		mustSet(t, ike, "child-sas", childSAs)
+		mustSet(t, m, sa.ike, ike)
+	}
+	sas := parseChildSAs(m)
+	if len(sas) != 2 || sas[1].IKE != "site-b" || sas[1].UniqueID != "8" {
+		t.Fatalf("unexpected child SAs %+v", sas)
+	}
+
+	c := &recordingCaller{}
+	if err := restartChild(context.Background(), c, &sas[1]); err != nil {
+		t.Fatalf("restartChild: %v", err)
+	}
+	if len(c.cmds) != 2 || c.cmds[0] != "terminate" || c.cmds[1] != "initiate" {
+		t.Fatalf("unexpected commands %v", c.cmds)
+	}
+	// Only site-b's child is terminated; site-a's child of the same name
+	// stays up.
+	terminate := c.msgs[0]
+	if StringValue(terminate.Get("child-id")) != "8" || terminate.Get("child") != nil || terminate.Get("ike") != nil {
+		t.Errorf("expected terminate by child-id 8 only, got keys %v", terminate.Keys())
+	}
+	initiate := c.msgs[1]
+	if StringValue(initiate.Get("ike")) != "site-b" || StringValue(initiate.Get("child")) != "net" {
+		t.Errorf("expected initiate of site-b's net, got ike=%v child=%v", initiate.Get("ike"), initiate.Get("child"))
+	}
+
+	if err := restartChild(context.Background(), c, &ChildSA{IKE: "site-a", Name: "net"}); err == nil {
+		t.Error("expected a CHILD_SA without a unique id to be refused")
+	}
+}
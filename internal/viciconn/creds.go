@@ -0,0 +1,91 @@
+package viciconn
+
+import (
+	"context"
+
+	"github.com/strongswan/govici/vici"
+)
+
+// LoadCert loads a certificate of certType ("X509" or "X509_CRL") into
+// charon. flag is "NONE" for an end-entity certificate and "CA" for a CA.
+func LoadCert(ctx context.Context, session *vici.Session, certType, flag string, data []byte) error {
+	msg := vici.NewMessage()
+	if err := msg.Set("type", certType); err != nil {
+		return err
+	}
+	if err := msg.Set("flag", flag); err != nil {
+		return err
+	}
+	if err := msg.Set("data", string(data)); err != nil {
+		return err
+	}
+	_, err := session.Call(ctx, "load-cert", msg)
+	return err
+}
+
+// LoadKey loads an unencrypted private key of keyType ("rsa", "ecdsa",
+// "ed25519" or "any") into charon.
+func LoadKey(ctx context.Context, session *vici.Session, keyType string, data []byte) error {
+	msg := vici.NewMessage()
+	if err := msg.Set("type", keyType); err != nil {
+		return err
+	}
+	if err := msg.Set("data", string(data)); err != nil {
+		return err
+	}
+	_, err := session.Call(ctx, "load-key", msg)
+	return err
+}
+
+// FlushCerts drops charon's volatile certificate cache, e.g. fetched CRLs,
+// of certType, or of every type when certType is empty.
+func FlushCerts(ctx context.Context, session *vici.Session, certType string) error {
+	msg := vici.NewMessage()
+	if certType != "" {
+		if err := msg.Set("type", certType); err != nil {
+			return err
+		}
+	}
+	_, err := session.Call(ctx, "flush-certs", msg)
+	return err
+}
+
+// Authority is a certification authority loaded from the authorities
+// section, as reported by list-authorities. CACert is the subject of its
+// certificate.
+type Authority struct {
+	Name        string
+	CACert      string
+	CertURIBase string
+	CRLURIs     []string
+	OCSPURIs    []string
+}
+
+func Authorities(ctx context.Context, session *vici.Session) ([]Authority, error) {
+	var authorities []Authority
+	for m, err := range session.CallStreaming(ctx, "list-authorities", "list-authority", vici.NewMessage()) {
+		if err != nil {
+			return nil, err
+		}
+		authorities = append(authorities, parseAuthorities(m)...)
+	}
+	return authorities, nil
+}
+
+func parseAuthorities(m *vici.Message) []Authority {
+	var authorities []Authority
+	for _, name := range m.Keys() {
+		msg, ok := m.Get(name).(*vici.Message)
+		if !ok {
+			continue
+		}
+		authorities = append(authorities, Authority{
+			Name:        name,
+			CACert:      StringValue(msg.Get("cacert")),
+			CertURIBase: StringValue(msg.Get("cert_uri_base")),
+			CRLURIs:     ListValue(msg.Get("crl_uris")),
+			OCSPURIs:    ListValue(msg.Get("ocsp_uris")),
+		})
+	}
+	return authorities
+}
@@ -0,0 +1,30 @@
+package viciconn
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/strongswan/govici/vici"
+)
+
+func TestParseAuthorities(t *testing.T) {
+	ca := vici.NewMessage()
+	mustSet(t, ca, "cacert", "C=CH, O=strongSwan, CN=strongSwan CA")
+	mustSet(t, ca, "crl_uris", []string{"http://crl.example.com/ca.crl"})
+	mustSet(t, ca, "ocsp_uris", []string{"http://ocsp.example.com"})
+	mustSet(t, ca, "cert_uri_base", "http://certs.example.com/")
+	m := vici.NewMessage()
+	mustSet(t, m, "strongswan", ca)
+
+	got := parseAuthorities(m)
+	want := []Authority{{
+		Name:        "strongswan",
+		CACert:      "C=CH, O=strongSwan, CN=strongSwan CA",
+		CertURIBase: "http://certs.example.com/",
+		CRLURIs:     []string{"http://crl.example.com/ca.crl"},
+		OCSPURIs:    []string{"http://ocsp.example.com"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAuthorities() = %+v, want %+v", got, want)
+	}
+}
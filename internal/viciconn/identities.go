@@ -0,0 +1,105 @@
+package viciconn
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/strongswan/govici/vici"
+)
+
+// IKEIdentity is the peer of an IKE_SA with the virtual IPs it was
+// assigned, as reported by list-sas and ike-updown events.
+type IKEIdentity struct {
+	Connection string
+	UniqueID   string
+	State      string
+	RemoteHost string
+	RemoteID   string
+	EAPID      string
+	XAuthID    string
+	VirtualIPs []string
+	// Established is how many seconds ago the IKE_SA was established.
+	Established int
+}
+
+// Identity is the identity the peer authenticated as: its EAP or XAuth
+// identity when it used one, its IKE identity otherwise.
+func (i *IKEIdentity) Identity() string {
+	switch {
+	case i.EAPID != "":
+		return i.EAPID
+	case i.XAuthID != "":
+		return i.XAuthID
+	}
+	return i.RemoteID
+}
+
+func IKEIdentities(ctx context.Context, session *vici.Session) ([]IKEIdentity, error) {
+	var ids []IKEIdentity
+	for m, err := range session.CallStreaming(ctx, "list-sas", "list-sa", vici.NewMessage()) {
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, ParseIKEIdentities(m)...)
+	}
+	return ids, nil
+}
+
+// ParseIKEIdentities reads the IKE_SAs of a list-sa or ike-updown message.
+func ParseIKEIdentities(m *vici.Message) []IKEIdentity {
+	var ids []IKEIdentity
+	for _, name := range m.Keys() {
+		sa, ok := m.Get(name).(*vici.Message)
+		if !ok {
+			continue
+		}
+		established, err := strconv.Atoi(StringValue(sa.Get("established")))
+		if err != nil {
+			established = 0
+		}
+		ids = append(ids, IKEIdentity{
+			Connection:  name,
+			UniqueID:    StringValue(sa.Get("uniqueid")),
+			State:       StringValue(sa.Get("state")),
+			RemoteHost:  StringValue(sa.Get("remote-host")),
+			RemoteID:    StringValue(sa.Get("remote-id")),
+			EAPID:       StringValue(sa.Get("remote-eap-id")),
+			XAuthID:     StringValue(sa.Get("remote-xauth-id")),
+			VirtualIPs:  ListValue(sa.Get("remote-vips")),
+			Established: established,
+		})
+	}
+	return ids
+}
+
+// IKEUp reports whether an ike-updown event announces IKE_SAs coming up
+// rather than going down.
+func IKEUp(m *vici.Message) bool {
+	return StringValue(m.Get("up")) == "yes"
+}
+
+// WatchIKEUpdown subscribes session to ike-updown events and calls fn for
+// each until ctx is done or charon closes the stream, e.g. on restart.
+func WatchIKEUpdown(ctx context.Context, session *vici.Session, fn func(ids []IKEIdentity, up bool)) error {
+	events := make(chan vici.Event, 32)
+	session.NotifyEvents(events)
+	defer session.StopEvents(events)
+
+	if err := session.Subscribe("ike-updown"); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return errors.New("event stream closed")
+			}
+			if e.Name == "ike-updown" {
+				fn(ParseIKEIdentities(e.Message), IKEUp(e.Message))
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package viciconn
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/strongswan/govici/vici"
+)
+
+func TestParseIKEIdentities(t *testing.T) {
+	alice := vici.NewMessage()
+	mustSet(t, alice, "uniqueid", "7")
+	mustSet(t, alice, "state", "ESTABLISHED")
+	mustSet(t, alice, "remote-host", "198.51.100.7")
+	mustSet(t, alice, "remote-id", "198.51.100.7")
+	mustSet(t, alice, "remote-eap-id", "alice@example.com")
+	mustSet(t, alice, "established", "42")
+	mustSet(t, alice, "remote-vips", []string{"10.10.0.1", "fd00:10::1"})
+
+	site := vici.NewMessage()
+	mustSet(t, site, "uniqueid", "3")
+	mustSet(t, site, "remote-id", "partner.example.com")
+
+	// An ike-updown event carries the IKE_SA next to the up flag.
+	m := vici.NewMessage()
+	mustSet(t, m, "up", "yes")
+	mustSet(t, m, "rw", alice)
+	mustSet(t, m, "partner", site)
+
+	if !IKEUp(m) {
+		t.Error("expected the event to announce an IKE_SA coming up")
+	}
+	ids := ParseIKEIdentities(m)
+	if len(ids) != 2 || ids[0].Connection != "rw" || ids[1].Connection != "partner" {
+		t.Fatalf("expected both IKE_SAs, got %+v", ids)
+	}
+	got := ids[0]
+	if got.Identity() != "alice@example.com" || got.RemoteID != "198.51.100.7" || got.Established != 42 {
+		t.Errorf("unexpected identity %+v", got)
+	}
+	if !slices.Equal(got.VirtualIPs, []string{"10.10.0.1", "fd00:10::1"}) {
+		t.Errorf("unexpected virtual IPs %v", got.VirtualIPs)
+	}
+	if ids[1].Identity() != "partner.example.com" || ids[1].VirtualIPs != nil {
+		t.Errorf("expected the IKE identity without virtual IPs, got %+v", ids[1])
+	}
+
+	down := vici.NewMessage()
+	mustSet(t, down, "rw", alice)
+	if IKEUp(down) {
+		t.Error("an event without up is an IKE_SA going down")
+	}
+}
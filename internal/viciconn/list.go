@@ -27,7 +27,7 @@ func Build(session *vici.Session, configured []string) []map[string]interface{}
 				details := map[string]interface{}{"loaded": true}
 				if sub, ok := m.Get(name).(*vici.Message); ok {
 					for _, k := range sub.Keys() {
-						details[k] = sub.Get(k)
+						details[k] = plainValue(sub.Get(k))
 					}
 				}
 				if _, exists := byName[name]; !exists {
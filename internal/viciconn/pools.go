@@ -0,0 +1,164 @@
+package viciconn
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/strongswan/govici/vici"
+)
+
+// Pool is a virtual IP pool with its leases, as reported by get-pools.
+type Pool struct {
+	Name    string  `json:"name"`
+	Base    string  `json:"base"`
+	Leases  []Lease `json:"leases"`
+	Size    int     `json:"size"`
+	Online  int     `json:"online"`
+	Offline int     `json:"offline"`
+}
+
+// Lease is an address of a pool assigned to an identity. Offline leases
+// are kept for the identity's next connection.
+type Lease struct {
+	Address  string `json:"address"`
+	Identity string `json:"identity"`
+	Status   string `json:"status"`
+}
+
+// LoadShared loads a shared secret of secretType ("EAP", "IKE", ...) for
+// the owner identities under id, replacing the one loaded before under it.
+func LoadShared(ctx context.Context, session *vici.Session, id, secretType, data string, owners []string) error {
+	msg := vici.NewMessage()
+	if err := msg.Set("id", id); err != nil {
+		return err
+	}
+	if err := msg.Set("type", secretType); err != nil {
+		return err
+	}
+	if err := msg.Set("data", data); err != nil {
+		return err
+	}
+	if err := msg.Set("owners", owners); err != nil {
+		return err
+	}
+	_, err := session.Call(ctx, "load-shared", msg)
+	return err
+}
+
+func UnloadShared(ctx context.Context, session *vici.Session, id string) error {
+	msg := vici.NewMessage()
+	if err := msg.Set("id", id); err != nil {
+		return err
+	}
+	_, err := session.Call(ctx, "unload-shared", msg)
+	return err
+}
+
+// SharedIDs lists the unique IDs of the loaded shared secrets. charon
+// does not return the secrets themselves.
+func SharedIDs(ctx context.Context, session *vici.Session) ([]string, error) {
+	resp, err := session.Call(ctx, "get-shared", vici.NewMessage())
+	if err != nil {
+		return nil, err
+	}
+	return ListValue(resp.Get("keys")), nil
+}
+
+// LoadPool loads a virtual IP pool of addrs, a CIDR subnet or a from-to
+// range, handing out dns to its clients.
+func LoadPool(ctx context.Context, session *vici.Session, name, addrs string, dns []string) error {
+	pool := vici.NewMessage()
+	if err := pool.Set("addrs", addrs); err != nil {
+		return err
+	}
+	if len(dns) > 0 {
+		if err := pool.Set("dns", dns); err != nil {
+			return err
+		}
+	}
+	msg := vici.NewMessage()
+	if err := msg.Set(name, pool); err != nil {
+		return err
+	}
+	_, err := session.Call(ctx, "load-pool", msg)
+	return err
+}
+
+// UnloadPool unloads a pool. charon refuses while it has online leases.
+func UnloadPool(ctx context.Context, session *vici.Session, name string) error {
+	msg := vici.NewMessage()
+	if err := msg.Set("name", name); err != nil {
+		return err
+	}
+	_, err := session.Call(ctx, "unload-pool", msg)
+	return err
+}
+
+// Pools lists the loaded pools with their leases.
+func Pools(ctx context.Context, session *vici.Session) ([]Pool, error) {
+	msg := vici.NewMessage()
+	if err := msg.Set("leases", "yes"); err != nil {
+		return nil, err
+	}
+	resp, err := session.Call(ctx, "get-pools", msg)
+	if err != nil {
+		return nil, err
+	}
+	return parsePools(resp), nil
+}
+
+func parsePools(m *vici.Message) []Pool {
+	pools := []Pool{}
+	for _, name := range m.Keys() {
+		p, ok := m.Get(name).(*vici.Message)
+		if !ok {
+			continue
+		}
+		pool := Pool{
+			Name:    name,
+			Base:    StringValue(p.Get("base")),
+			Size:    intValue(p.Get("size")),
+			Online:  intValue(p.Get("online")),
+			Offline: intValue(p.Get("offline")),
+			Leases:  []Lease{},
+		}
+		if leases, ok := p.Get("leases").(*vici.Message); ok {
+			for _, key := range leases.Keys() {
+				l, ok := leases.Get(key).(*vici.Message)
+				if !ok {
+					continue
+				}
+				pool.Leases = append(pool.Leases, Lease{
+					Address:  StringValue(l.Get("address")),
+					Identity: StringValue(l.Get("identity")),
+					Status:   StringValue(l.Get("status")),
+				})
+			}
+		}
+		pools = append(pools, pool)
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+	return pools
+}
+
+func intValue(v any) int {
+	n, err := strconv.Atoi(StringValue(v))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Started returns when charon started, from the stats command. It changes
+// when charon restarts and forgets what was loaded over VICI.
+func Started(ctx context.Context, session *vici.Session) (string, error) {
+	resp, err := session.Call(ctx, "stats", vici.NewMessage())
+	if err != nil {
+		return "", err
+	}
+	if uptime, ok := resp.Get("uptime").(*vici.Message); ok {
+		return StringValue(uptime.Get("since")), nil
+	}
+	return "", nil
+}
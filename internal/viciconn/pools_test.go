@@ -0,0 +1,46 @@
+package viciconn
+
+import (
+	"testing"
+
+	"github.com/strongswan/govici/vici"
+)
+
+func TestParsePools(t *testing.T) {
+	lease := vici.NewMessage()
+	mustSet(t, lease, "address", "10.10.0.1")
+	mustSet(t, lease, "identity", "alice")
+	mustSet(t, lease, "status", "online")
+	leases := vici.NewMessage()
+	mustSet(t, leases, "0", lease)
+
+	rw := vici.NewMessage()
+	mustSet(t, rw, "base", "10.10.0.0")
+	mustSet(t, rw, "size", "254")
+	mustSet(t, rw, "online", "1")
+	mustSet(t, rw, "offline", "0")
+	mustSet(t, rw, "leases", leases)
+
+	empty := vici.NewMessage()
+	mustSet(t, empty, "base", "10.20.0.0")
+	mustSet(t, empty, "size", "14")
+
+	m := vici.NewMessage()
+	mustSet(t, m, "rw", rw)
+	mustSet(t, m, "contractors", empty)
+
+	pools := parsePools(m)
+	if len(pools) != 2 || pools[0].Name != "contractors" || pools[1].Name != "rw" {
+		t.Fatalf("expected pools sorted by name, got %+v", pools)
+	}
+	if len(pools[0].Leases) != 0 || pools[0].Size != 14 {
+		t.Errorf("unexpected empty pool %+v", pools[0])
+	}
+	got := pools[1]
+	if got.Base != "10.10.0.0" || got.Size != 254 || got.Online != 1 {
+		t.Errorf("unexpected pool %+v", got)
+	}
+	if len(got.Leases) != 1 || got.Leases[0] != (Lease{Address: "10.10.0.1", Identity: "alice", Status: "online"}) {
+		t.Errorf("unexpected leases %+v", got.Leases)
+	}
+}
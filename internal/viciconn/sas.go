@@ -0,0 +1,86 @@
+package viciconn
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/strongswan/govici/vici"
+)
+
+type ChildSA struct {
+	IKE  string
+	Name string
+	// UniqueID tells apart CHILD_SAs of the same name, which children of
+	// different connections may have.
+	UniqueID    string
+	State       string
+	Mode        string
+	Protocol    string
+	EncrAlg     string
+	IntegAlg    string
+	LocalHost   string
+	RemoteHost  string
+	LocalTS     []string
+	RemoteTS    []string
+	EncrKeySize int
+	UDPEncap    bool
+}
+
+func ChildSAs(session *vici.Session) ([]ChildSA, error) {
+	msg := vici.NewMessage()
+	var sas []ChildSA
+	for m, err := range session.CallStreaming(context.Background(), "list-sas", "list-sa", msg) {
+		if err != nil {
+			return nil, err
+		}
+		sas = append(sas, parseChildSAs(m)...)
+	}
+	return sas, nil
+}
+
+func parseChildSAs(m *vici.Message) []ChildSA {
+	var sas []ChildSA
+	for _, ike := range m.Keys() {
+		ikeMsg, ok := m.Get(ike).(*vici.Message)
+		if !ok {
+			continue
+		}
+		childSAs, ok := ikeMsg.Get("child-sas").(*vici.Message)
+		if !ok {
+			continue
+		}
+		natAny := StringValue(ikeMsg.Get("nat-any")) == "yes"
+
+		for _, key := range childSAs.Keys() {
+			child, ok := childSAs.Get(key).(*vici.Message)
+			if !ok {
+				continue
+			}
+			name := StringValue(child.Get("name"))
+			if name == "" {
+				name = key
+			}
+			keySize, err := strconv.Atoi(StringValue(child.Get("encr-keysize")))
+			if err != nil {
+				keySize = 0
+			}
+			sas = append(sas, ChildSA{
+				IKE:         ike,
+				Name:        name,
+				UniqueID:    StringValue(child.Get("uniqueid")),
+				State:       StringValue(child.Get("state")),
+				Mode:        StringValue(child.Get("mode")),
+				Protocol:    StringValue(child.Get("protocol")),
+				EncrAlg:     StringValue(child.Get("encr-alg")),
+				IntegAlg:    StringValue(child.Get("integ-alg")),
+				LocalHost:   StringValue(ikeMsg.Get("local-host")),
+				RemoteHost:  StringValue(ikeMsg.Get("remote-host")),
+				LocalTS:     ListValue(child.Get("local-ts")),
+				RemoteTS:    ListValue(child.Get("remote-ts")),
+				EncrKeySize: keySize,
+				UDPEncap:    natAny || StringValue(child.Get("encap")) == "yes",
+			})
+		}
+	}
+	return sas
+}
@@ -0,0 +1,146 @@
+// Package watchdog probes targets behind the tunnels and decides when a
+// CHILD_SA that is established but no longer passes traffic needs to be
+// restarted.
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/diag"
+)
+
+const (
+	KindICMP = "icmp"
+	KindTCP  = "tcp"
+	KindHTTP = "http"
+)
+
+// Probe checks that a target behind a connection's CHILD_SA is reachable.
+type Probe struct {
+	Connection string
+	Kind       string
+	// Target is an address for icmp, address:port for tcp and a URL for
+	// http.
+	Target string
+	// addr is the parsed target address for icmp and tcp.
+	addr netip.AddrPort
+}
+
+// ParseProbes parses WATCHDOG_PROBES: entries separated by ";", each
+// "connection=icmp:<addr>", "connection=tcp:<addr>:<port>" or
+// "connection=<http or https URL>".
+func ParseProbes(spec string) ([]Probe, error) {
+	var probes []Probe
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, target, ok := strings.Cut(entry, "=")
+		name, target = strings.TrimSpace(name), strings.TrimSpace(target)
+		if !ok || name == "" || target == "" {
+			return nil, fmt.Errorf("probe %q: expected connection=<target>", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("probe %s: defined more than once", name)
+		}
+		seen[name] = true
+
+		p, err := parseProbe(name, target)
+		if err != nil {
+			return nil, fmt.Errorf("probe %s: %w", name, err)
+		}
+		probes = append(probes, p)
+	}
+	return probes, nil
+}
+
+func parseProbe(name, target string) (Probe, error) {
+	p := Probe{Connection: name}
+	switch {
+	case strings.HasPrefix(target, "icmp:"):
+		addr, err := netip.ParseAddr(strings.TrimPrefix(target, "icmp:"))
+		if err != nil {
+			return p, fmt.Errorf("invalid icmp target: %w", err)
+		}
+		p.Kind, p.Target, p.addr = KindICMP, addr.String(), netip.AddrPortFrom(addr, 0)
+	case strings.HasPrefix(target, "tcp:"):
+		addr, err := netip.ParseAddrPort(strings.TrimPrefix(target, "tcp:"))
+		if err != nil {
+			return p, fmt.Errorf("invalid tcp target, expected tcp:<addr>:<port>: %w", err)
+		}
+		p.Kind, p.Target, p.addr = KindTCP, addr.String(), addr
+	case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+		u, err := url.Parse(target)
+		if err != nil || u.Host == "" {
+			return p, fmt.Errorf("invalid http target %q", target)
+		}
+		p.Kind, p.Target = KindHTTP, u.String()
+	default:
+		return p, fmt.Errorf("unknown target %q, expected icmp:, tcp: or an http(s) URL", target)
+	}
+	return p, nil
+}
+
+// Run probes the target once from source, the local address that makes the
+// probe match the child's policy, and returns the round trip time.
+func (p *Probe) Run(ctx context.Context, source netip.Addr, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	switch p.Kind {
+	case KindICMP:
+		if _, err := diag.Ping(ctx, p.addr.Addr(), source, 1, nil); err != nil {
+			return 0, err
+		}
+	case KindTCP:
+		if _, err := diag.TCPConnect(ctx, p.addr, source, timeout); err != nil {
+			return 0, err
+		}
+	case KindHTTP:
+		if err := p.get(ctx, source); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("unknown probe kind %q", p.Kind)
+	}
+	return time.Since(start), nil
+}
+
+// get succeeds on any response below 400.
+func (p *Probe) get(ctx context.Context, source netip.Addr) error {
+	dialer := &net.Dialer{}
+	if source.IsValid() {
+		dialer.LocalAddr = &net.TCPAddr{IP: source.AsSlice()}
+	}
+	client := &http.Client{
+		Transport: &http.Transport{DialContext: dialer.DialContext, DisableKeepAlives: true},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Target, http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("GET %s: %s", p.Target, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package watchdog
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestParseProbes(t *testing.T) {
+	probes, err := ParseProbes("partner-a=icmp:10.2.0.10; partner-b = tcp:10.3.0.5:443 ;partner-c=https://10.4.0.1/health")
+	if err != nil {
+		t.Fatalf("ParseProbes() error = %v", err)
+	}
+
+	want := []Probe{
+		{Connection: "partner-a", Kind: KindICMP, Target: "10.2.0.10"},
+		{Connection: "partner-b", Kind: KindTCP, Target: "10.3.0.5:443"},
+		{Connection: "partner-c", Kind: KindHTTP, Target: "https://10.4.0.1/health"},
+	}
+	if len(probes) != len(want) {
+		t.Fatalf("ParseProbes() = %+v, want %+v", probes, want)
+	}
+	for i := range want {
+		got := probes[i]
+		if got.Connection != want[i].Connection || got.Kind != want[i].Kind || got.Target != want[i].Target {
+			t.Errorf("probe %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestParseProbesErrors(t *testing.T) {
+	for _, spec := range []string{
+		"partner-a",
+		"partner-a=",
+		"partner-a=icmp:gateway",
+		"partner-a=tcp:10.2.0.10",
+		"partner-a=udp:10.2.0.10:53",
+		"partner-a=http://",
+		"partner-a=icmp:10.2.0.10;partner-a=icmp:10.2.0.11",
+	} {
+		if _, err := ParseProbes(spec); err == nil {
+			t.Errorf("ParseProbes(%q) should fail", spec)
+		}
+	}
+}
+
+func TestProbeRunTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close() //nolint:errcheck
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if err := conn.Close(); err != nil {
+				return
+			}
+		}
+	}()
+
+	probes, err := ParseProbes("net-net=tcp:" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("ParseProbes() error = %v", err)
+	}
+	source := netip.MustParseAddr("127.0.0.1")
+	if _, err := probes[0].Run(context.Background(), source, time.Second); err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+
+	addr := ln.Addr().String()
+	if err := ln.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	probes, err = ParseProbes("net-net=tcp:" + addr)
+	if err != nil {
+		t.Fatalf("ParseProbes() error = %v", err)
+	}
+	if _, err := probes[0].Run(context.Background(), source, time.Second); err == nil {
+		t.Error("Run() against a closed port should fail")
+	}
+}
+
+func TestProbeRunHTTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "redirect", status: http.StatusFound},
+		{name: "server error", status: http.StatusServiceUnavailable, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			probes, err := ParseProbes("net-net=" + srv.URL + "/health")
+			if err != nil {
+				t.Fatalf("ParseProbes() error = %v", err)
+			}
+			_, err = probes[0].Run(context.Background(), netip.Addr{}, time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
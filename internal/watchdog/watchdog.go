@@ -0,0 +1,147 @@
+package watchdog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klowdo/tailswan/internal/statefile"
+)
+
+const (
+	StatusUnknown = "unknown"
+	StatusHealthy = "healthy"
+	StatusFailing = "failing"
+	// StatusNoSA means the connection has no CHILD_SA to probe through.
+	// Bringing it up is left to autostart, schedules or the operator.
+	StatusNoSA = "no_sa"
+
+	stateFile = "watchdog.json"
+)
+
+// Health is the probe state of one connection, separate from the state of
+// its SAs.
+type Health struct {
+	LastCheck   time.Time `json:"last_check"`
+	LastSuccess time.Time `json:"last_success"`
+	LastRestart time.Time `json:"last_restart"`
+	Connection  string    `json:"connection"`
+	Kind        string    `json:"kind"`
+	Target      string    `json:"target"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	LatencyMs   float64   `json:"latency_ms"`
+	// Failures counts the probes that failed in a row since the last
+	// success or restart.
+	Failures int `json:"failures"`
+	Restarts int `json:"restarts"`
+}
+
+func (h *Health) Healthy() bool {
+	return h.Status == StatusHealthy
+}
+
+// State is what the supervisor publishes for the control server.
+type State struct {
+	Updated time.Time `json:"updated"`
+	Tunnels []Health  `json:"tunnels"`
+}
+
+// Watchdog tracks probe results and decides when to restart a child.
+type Watchdog struct {
+	health    map[string]*Health
+	probes    []Probe
+	threshold int
+}
+
+// New restarts a child after threshold probes failed in a row.
+func New(probes []Probe, threshold int) *Watchdog {
+	w := &Watchdog{probes: probes, threshold: threshold, health: make(map[string]*Health)}
+	for _, p := range probes {
+		w.health[p.Connection] = &Health{Connection: p.Connection, Kind: p.Kind, Target: p.Target, Status: StatusUnknown}
+	}
+	return w
+}
+
+func (w *Watchdog) Probes() []Probe {
+	return w.probes
+}
+
+// NoSA records that the connection has no CHILD_SA. The failure count is
+// reset, so a connection that comes back is given the full threshold.
+func (w *Watchdog) NoSA(conn string, now time.Time) {
+	h := w.health[conn]
+	h.LastCheck = now
+	h.Status = StatusNoSA
+	h.Error = ""
+	h.Failures = 0
+}
+
+// Record stores a probe result and reports whether the child should be
+// restarted.
+func (w *Watchdog) Record(conn string, latency time.Duration, err error, now time.Time) bool {
+	h := w.health[conn]
+	h.LastCheck = now
+	if err == nil {
+		h.Status = StatusHealthy
+		h.Error = ""
+		h.LastSuccess = now
+		h.LatencyMs = float64(latency.Microseconds()) / 1000
+		h.Failures = 0
+		return false
+	}
+
+	h.Status = StatusFailing
+	h.Error = err.Error()
+	h.Failures++
+	return h.Failures >= w.threshold
+}
+
+// Restarted records that the child was restarted.
+func (w *Watchdog) Restarted(conn string, now time.Time) {
+	h := w.health[conn]
+	h.Restarts++
+	h.LastRestart = now
+	h.Failures = 0
+}
+
+// State returns the health of every probed connection in configuration
+// order.
+func (w *Watchdog) State(now time.Time) *State {
+	st := &State{Updated: now, Tunnels: make([]Health, 0, len(w.probes))}
+	for _, p := range w.probes {
+		st.Tunnels = append(st.Tunnels, *w.health[p.Connection])
+	}
+	return st
+}
+
+func StatePath(stateDir string) string {
+	return filepath.Join(stateDir, stateFile)
+}
+
+func WriteState(stateDir string, st *State) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return statefile.WriteAtomic(StatePath(stateDir), data)
+}
+
+// ReadState returns nil without an error when the watchdog is not running.
+func ReadState(stateDir string) (*State, error) {
+	data, err := os.ReadFile(StatePath(stateDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", StatePath(stateDir), err)
+	}
+	return &st, nil
+}
@@ -0,0 +1,100 @@
+package watchdog
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestWatchdog(t *testing.T) *Watchdog {
+	t.Helper()
+	probes, err := ParseProbes("partner-a=icmp:10.2.0.10;partner-b=tcp:10.3.0.5:443")
+	if err != nil {
+		t.Fatalf("ParseProbes() error = %v", err)
+	}
+	return New(probes, 3)
+}
+
+func TestWatchdogRestartsAfterThreshold(t *testing.T) {
+	w := newTestWatchdog(t)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	dead := errors.New("no replies from 10.2.0.10")
+
+	if w.Record("partner-a", 12*time.Millisecond, nil, now) {
+		t.Fatal("a successful probe should not restart")
+	}
+	for i := 1; i < 3; i++ {
+		if w.Record("partner-a", 0, dead, now) {
+			t.Fatalf("failure %d is below the threshold", i)
+		}
+	}
+	if !w.Record("partner-a", 0, dead, now) {
+		t.Fatal("the third failure in a row should restart")
+	}
+	w.Restarted("partner-a", now)
+
+	h := w.State(now).Tunnels[0]
+	if h.Status != StatusFailing || h.Failures != 0 || h.Restarts != 1 || h.Error == "" {
+		t.Errorf("unexpected health after restart %+v", h)
+	}
+	if h.LatencyMs != 12 || !h.LastSuccess.Equal(now) {
+		t.Errorf("the last success should be kept, got %+v", h)
+	}
+
+	if w.Record("partner-a", 0, dead, now) {
+		t.Error("failures count again from zero after a restart")
+	}
+}
+
+func TestWatchdogSuccessResetsFailures(t *testing.T) {
+	w := newTestWatchdog(t)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	dead := errors.New("connect refused")
+
+	w.Record("partner-b", 0, dead, now)
+	w.Record("partner-b", 0, dead, now)
+	w.Record("partner-b", 5*time.Millisecond, nil, now)
+	if w.Record("partner-b", 0, dead, now) {
+		t.Error("a success in between should reset the count")
+	}
+}
+
+func TestWatchdogNoSA(t *testing.T) {
+	w := newTestWatchdog(t)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	w.Record("partner-a", 0, errors.New("timeout"), now)
+	w.NoSA("partner-a", now)
+
+	st := w.State(now)
+	if st.Tunnels[0].Status != StatusNoSA || st.Tunnels[0].Failures != 0 {
+		t.Errorf("unexpected health %+v", st.Tunnels[0])
+	}
+	if st.Tunnels[1].Status != StatusUnknown || st.Tunnels[1].Connection != "partner-b" {
+		t.Errorf("unprobed connection should be unknown, got %+v", st.Tunnels[1])
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	st, err := ReadState(dir)
+	if err != nil || st != nil {
+		t.Fatalf("ReadState() = %v, %v; want nil, nil", st, err)
+	}
+
+	w := newTestWatchdog(t)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	w.Record("partner-a", 7*time.Millisecond, nil, now)
+	if err := WriteState(dir, w.State(now)); err != nil {
+		t.Fatalf("WriteState() error = %v", err)
+	}
+
+	st, err = ReadState(dir)
+	if err != nil {
+		t.Fatalf("ReadState() error = %v", err)
+	}
+	if len(st.Tunnels) != 2 || !st.Tunnels[0].Healthy() || st.Tunnels[0].LatencyMs != 7 {
+		t.Errorf("unexpected state %+v", st)
+	}
+}
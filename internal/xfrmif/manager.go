@@ -0,0 +1,186 @@
+package xfrmif
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sync"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Manager creates the planned XFRM interfaces, keeps their routes in the
+// main table in sync and deletes interfaces it created once their connection
+// is gone. Interfaces it did not create are never removed.
+type Manager struct {
+	nl netlinker
+	// managed holds the interfaces the manager created.
+	managed map[string]bool
+	mu      sync.Mutex
+}
+
+// netlinker is the part of the netlink API the manager uses; a
+// *netlink.Handle, and a fake in tests.
+type netlinker interface {
+	LinkByName(name string) (netlink.Link, error)
+	LinkAdd(link netlink.Link) error
+	LinkDel(link netlink.Link) error
+	LinkSetUp(link netlink.Link) error
+	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+	RouteReplace(route *netlink.Route) error
+	RouteDel(route *netlink.Route) error
+}
+
+func NewManager() *Manager {
+	return &Manager{nl: &netlink.Handle{}, managed: make(map[string]bool)}
+}
+
+func (m *Manager) Reconcile(ifaces []Interface) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	desired := make(map[string]bool, len(ifaces))
+	for i := range ifaces {
+		desired[ifaces[i].Name] = true
+		if err := m.ensure(&ifaces[i]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ifaces[i].Name, err))
+		}
+	}
+
+	for name := range m.managed {
+		if desired[name] {
+			continue
+		}
+		if err := m.deleteLink(name); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		slog.Info("Removed XFRM interface", "interface", name)
+		delete(m.managed, name)
+	}
+	return errors.Join(errs...)
+}
+
+// Remove deletes every interface the manager created.
+func (m *Manager) Remove() error {
+	return m.Reconcile(nil)
+}
+
+// ensure creates the interface unless it exists, and syncs its routes. An
+// existing interface is used as it is, but not taken over: it was created
+// by someone else and is left in place when it is no longer planned.
+func (m *Manager) ensure(iface *Interface) error {
+	link, err := m.nl.LinkByName(iface.Name)
+	var notFound netlink.LinkNotFoundError
+	switch {
+	case errors.As(err, &notFound):
+		link = &netlink.Xfrmi{LinkAttrs: netlink.LinkAttrs{Name: iface.Name}, Ifid: iface.IfID}
+		if err := m.nl.LinkAdd(link); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+		m.managed[iface.Name] = true
+		if link, err = m.nl.LinkByName(iface.Name); err != nil {
+			return err
+		}
+		slog.Info("Created XFRM interface", "interface", iface.Name, "if_id", iface.IfID, "connection", iface.Connection)
+	case err != nil:
+		return err
+	}
+
+	xfrmi, ok := link.(*netlink.Xfrmi)
+	if !ok {
+		return fmt.Errorf("exists as %s, not an XFRM interface", link.Type())
+	}
+	if xfrmi.Ifid != iface.IfID {
+		return fmt.Errorf("exists with if_id %d, expected %d", xfrmi.Ifid, iface.IfID)
+	}
+
+	if link.Attrs().Flags&net.FlagUp == 0 {
+		if err := m.nl.LinkSetUp(link); err != nil {
+			return fmt.Errorf("set up: %w", err)
+		}
+	}
+	return m.syncRoutes(link, iface.Routes)
+}
+
+func (m *Manager) syncRoutes(link netlink.Link, routes []netip.Prefix) error {
+	existing, err := m.nl.RouteList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("list routes: %w", err)
+	}
+
+	var current []netip.Prefix
+	for i := range existing {
+		r := &existing[i]
+		if r.Protocol != unix.RTPROT_STATIC || r.Dst == nil {
+			continue
+		}
+		if p, ok := prefixFromIPNet(r.Dst); ok {
+			current = append(current, p)
+		}
+	}
+
+	var errs []error
+	for _, p := range routes {
+		route := &netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Dst:       ipNet(p),
+			Scope:     netlink.SCOPE_LINK,
+			Protocol:  unix.RTPROT_STATIC,
+		}
+		if err := m.nl.RouteReplace(route); err != nil {
+			errs = append(errs, fmt.Errorf("route %s: %w", p, err))
+		}
+	}
+	for _, p := range staleRoutes(current, routes) {
+		route := &netlink.Route{LinkIndex: link.Attrs().Index, Dst: ipNet(p), Protocol: unix.RTPROT_STATIC}
+		if err := m.nl.RouteDel(route); err != nil {
+			errs = append(errs, fmt.Errorf("delete route %s: %w", p, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) deleteLink(name string) error {
+	link, err := m.nl.LinkByName(name)
+	var notFound netlink.LinkNotFoundError
+	if errors.As(err, &notFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return m.nl.LinkDel(link)
+}
+
+// staleRoutes returns the routes in current that are no longer wanted.
+func staleRoutes(current, wanted []netip.Prefix) []netip.Prefix {
+	keep := make(map[netip.Prefix]bool, len(wanted))
+	for _, p := range wanted {
+		keep[p] = true
+	}
+	var stale []netip.Prefix
+	for _, p := range current {
+		if !keep[p] {
+			stale = append(stale, p)
+		}
+	}
+	return stale
+}
+
+func ipNet(p netip.Prefix) *net.IPNet {
+	return &net.IPNet{IP: p.Addr().AsSlice(), Mask: net.CIDRMask(p.Bits(), p.Addr().BitLen())}
+}
+
+func prefixFromIPNet(n *net.IPNet) (netip.Prefix, bool) {
+	addr, ok := netip.AddrFromSlice(n.IP)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	ones, _ := n.Mask.Size()
+	return netip.PrefixFrom(addr.Unmap(), ones).Masked(), true
+}
@@ -0,0 +1,107 @@
+package xfrmif
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// fakeNetlink keeps links and their routes in memory.
+type fakeNetlink struct {
+	links   map[string]*netlink.Xfrmi
+	routes  map[int][]netlink.Route
+	deleted []string
+}
+
+func newFakeNetlink() *fakeNetlink {
+	return &fakeNetlink{links: map[string]*netlink.Xfrmi{}, routes: map[int][]netlink.Route{}}
+}
+
+func (f *fakeNetlink) add(name string, ifID uint32) {
+	f.links[name] = &netlink.Xfrmi{
+		LinkAttrs: netlink.LinkAttrs{Name: name, Index: len(f.links) + 1, Flags: net.FlagUp},
+		Ifid:      ifID,
+	}
+}
+
+func (f *fakeNetlink) LinkByName(name string) (netlink.Link, error) {
+	link, ok := f.links[name]
+	if !ok {
+		return nil, netlink.LinkNotFoundError{}
+	}
+	return link, nil
+}
+
+func (f *fakeNetlink) LinkAdd(link netlink.Link) error {
+	xfrmi, ok := link.(*netlink.Xfrmi)
+	if !ok {
+		return unix.EINVAL
+	}
+	f.add(xfrmi.Name, xfrmi.Ifid)
+	return nil
+}
+
+func (f *fakeNetlink) LinkDel(link netlink.Link) error {
+	delete(f.links, link.Attrs().Name)
+	f.deleted = append(f.deleted, link.Attrs().Name)
+	return nil
+}
+
+func (f *fakeNetlink) LinkSetUp(netlink.Link) error {
+	return nil
+}
+
+func (f *fakeNetlink) RouteList(link netlink.Link, _ int) ([]netlink.Route, error) {
+	return f.routes[link.Attrs().Index], nil
+}
+
+func (f *fakeNetlink) RouteReplace(route *netlink.Route) error {
+	f.routes[route.LinkIndex] = append(f.routes[route.LinkIndex], *route)
+	return nil
+}
+
+func (f *fakeNetlink) RouteDel(route *netlink.Route) error {
+	f.routes[route.LinkIndex] = slices.DeleteFunc(f.routes[route.LinkIndex], func(r netlink.Route) bool {
+		return r.Dst.String() == route.Dst.String()
+	})
+	return nil
+}
+
+func TestManager_KeepsPreexistingInterfaces(t *testing.T) {
+	nl := newFakeNetlink()
+	nl.add("xfrm-site-a", 1)
+	m := &Manager{nl: nl, managed: map[string]bool{}}
+
+	err := m.Reconcile([]Interface{
+		{Name: "xfrm-site-a", Connection: "site-a", IfID: 1, Routes: []netip.Prefix{netip.MustParsePrefix("10.2.0.0/24")}},
+		{Name: "xfrm-site-b", Connection: "site-b", IfID: 2},
+	})
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if nl.links["xfrm-site-b"] == nil {
+		t.Fatal("expected xfrm-site-b to be created")
+	}
+	if routes := nl.routes[nl.links["xfrm-site-a"].Index]; len(routes) != 1 || routes[0].Dst.String() != "10.2.0.0/24" {
+		t.Errorf("expected the routes of the existing interface synced, got %v", routes)
+	}
+
+	// Neither a config change nor Stop deletes the interface TailSwan
+	// found in place.
+	if err := m.Reconcile([]Interface{{Name: "xfrm-site-b", Connection: "site-b", IfID: 2}}); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if err := m.Remove(); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if !slices.Equal(nl.deleted, []string{"xfrm-site-b"}) {
+		t.Errorf("expected only the created interface deleted, got %v", nl.deleted)
+	}
+	if nl.links["xfrm-site-a"] == nil {
+		t.Error("expected the existing interface kept")
+	}
+}
@@ -0,0 +1,115 @@
+package xfrmif
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+// Interface is the XFRM interface for one route-based connection and the
+// remote subnets routed over it.
+type Interface struct {
+	Name       string
+	Connection string
+	Routes     []netip.Prefix
+	IfID       uint32
+}
+
+// Problem is a child whose if_id settings cannot be mapped onto a single
+// per-connection interface. Its traffic is left to charon's policies.
+type Problem struct {
+	Connection string
+	Child      string
+	Message    string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s/%s: %s", p.Connection, p.Child, p.Message)
+}
+
+func Name(ifID uint32) string {
+	return fmt.Sprintf("xfrm%d", ifID)
+}
+
+// Plan derives one interface per connection from the loaded children.
+// Children without an if_id stay policy based; a connection only gets an
+// interface when all of its route-based children agree on one fixed ID
+// that no other connection uses.
+func Plan(children []viciconn.Child) ([]Interface, []Problem) {
+	var problems []Problem
+	byConn := make(map[string]*Interface)
+	owner := make(map[uint32]string)
+	var order []string
+
+	for i := range children {
+		child := &children[i]
+		if child.IfIDIn == 0 && child.IfIDOut == 0 {
+			continue
+		}
+
+		problem := func(format string, args ...any) {
+			problems = append(problems, Problem{
+				Connection: child.Connection,
+				Child:      child.Name,
+				Message:    fmt.Sprintf(format, args...),
+			})
+		}
+
+		switch {
+		case child.IfIDIn != child.IfIDOut:
+			problem("if_id_in (%d) and if_id_out (%d) differ; set both to the same value", child.IfIDIn, child.IfIDOut)
+			continue
+		case child.IfIDIn == viciconn.IfIDUnique || child.IfIDIn == viciconn.IfIDUniqueDir:
+			problem("%%unique interface IDs change with every SA; set a fixed if_id_in/if_id_out")
+			continue
+		}
+
+		id := child.IfIDIn
+		iface, ok := byConn[child.Connection]
+		if ok && iface.IfID != id {
+			problem("if_id %d differs from if_id %d used by other children of the connection", id, iface.IfID)
+			continue
+		}
+		if other, taken := owner[id]; taken && other != child.Connection {
+			problem("if_id %d is already used by connection %s", id, other)
+			continue
+		}
+
+		if !ok {
+			iface = &Interface{Name: Name(id), Connection: child.Connection, IfID: id}
+			byConn[child.Connection] = iface
+			owner[id] = child.Connection
+			order = append(order, child.Connection)
+		}
+		for _, remote := range viciconn.Prefixes(child.RemoteTS) {
+			if remote.Bits() == 0 {
+				problem("remote_ts %s would replace the default route and is not routed over %s", remote, iface.Name)
+				continue
+			}
+			iface.Routes = append(iface.Routes, remote)
+		}
+	}
+
+	ifaces := make([]Interface, 0, len(order))
+	for _, conn := range order {
+		iface := byConn[conn]
+		iface.Routes = dedupe(iface.Routes)
+		ifaces = append(ifaces, *iface)
+	}
+	return ifaces, problems
+}
+
+func dedupe(prefixes []netip.Prefix) []netip.Prefix {
+	seen := make(map[netip.Prefix]bool, len(prefixes))
+	result := make([]netip.Prefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].String() < result[j].String() })
+	return result
+}
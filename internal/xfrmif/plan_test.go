@@ -0,0 +1,100 @@
+package xfrmif
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/klowdo/tailswan/internal/viciconn"
+)
+
+func TestPlan(t *testing.T) {
+	children := []viciconn.Child{
+		{Connection: "site-a", Name: "lan", RemoteTS: []string{"10.2.0.0/24"}, IfIDIn: 1, IfIDOut: 1},
+		{Connection: "site-a", Name: "dmz", RemoteTS: []string{"10.3.0.0/24", "10.2.0.0/24"}, IfIDIn: 1, IfIDOut: 1},
+		{Connection: "site-b", Name: "lan", RemoteTS: []string{"10.4.0.0/24"}, IfIDIn: 2, IfIDOut: 2},
+		{Connection: "legacy", Name: "lan", RemoteTS: []string{"10.5.0.0/24"}},
+	}
+
+	ifaces, problems := Plan(children)
+	if len(problems) != 0 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+	if len(ifaces) != 2 {
+		t.Fatalf("expected 2 interfaces, got %+v", ifaces)
+	}
+
+	a := ifaces[0]
+	if a.Name != "xfrm1" || a.Connection != "site-a" || a.IfID != 1 {
+		t.Errorf("unexpected interface %+v", a)
+	}
+	want := []netip.Prefix{netip.MustParsePrefix("10.2.0.0/24"), netip.MustParsePrefix("10.3.0.0/24")}
+	if len(a.Routes) != len(want) || a.Routes[0] != want[0] || a.Routes[1] != want[1] {
+		t.Errorf("expected routes %v, got %v", want, a.Routes)
+	}
+	if ifaces[1].Name != "xfrm2" {
+		t.Errorf("expected xfrm2 for site-b, got %s", ifaces[1].Name)
+	}
+}
+
+func TestPlanProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     string
+		children []viciconn.Child
+	}{
+		{
+			name:     "asymmetric if_id",
+			children: []viciconn.Child{{Connection: "a", Name: "c", IfIDIn: 1, IfIDOut: 2}},
+			want:     "differ",
+		},
+		{
+			name:     "unique if_id",
+			children: []viciconn.Child{{Connection: "a", Name: "c", IfIDIn: viciconn.IfIDUnique, IfIDOut: viciconn.IfIDUnique}},
+			want:     "%unique",
+		},
+		{
+			name: "children of one connection disagree",
+			children: []viciconn.Child{
+				{Connection: "a", Name: "c1", IfIDIn: 1, IfIDOut: 1},
+				{Connection: "a", Name: "c2", IfIDIn: 2, IfIDOut: 2},
+			},
+			want: "other children",
+		},
+		{
+			name: "if_id shared between connections",
+			children: []viciconn.Child{
+				{Connection: "a", Name: "c", IfIDIn: 1, IfIDOut: 1},
+				{Connection: "b", Name: "c", IfIDIn: 1, IfIDOut: 1},
+			},
+			want: "already used by connection a",
+		},
+		{
+			name:     "default route",
+			children: []viciconn.Child{{Connection: "a", Name: "c", RemoteTS: []string{"0.0.0.0/0"}, IfIDIn: 1, IfIDOut: 1}},
+			want:     "default route",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, problems := Plan(tt.children)
+			if len(problems) != 1 {
+				t.Fatalf("expected 1 problem, got %v", problems)
+			}
+			if !strings.Contains(problems[0].Message, tt.want) {
+				t.Errorf("expected problem mentioning %q, got %q", tt.want, problems[0].Message)
+			}
+		})
+	}
+}
+
+func TestStaleRoutes(t *testing.T) {
+	current := []netip.Prefix{netip.MustParsePrefix("10.2.0.0/24"), netip.MustParsePrefix("10.9.0.0/24")}
+	wanted := []netip.Prefix{netip.MustParsePrefix("10.2.0.0/24"), netip.MustParsePrefix("10.3.0.0/24")}
+
+	stale := staleRoutes(current, wanted)
+	if len(stale) != 1 || stale[0] != netip.MustParsePrefix("10.9.0.0/24") {
+		t.Errorf("expected [10.9.0.0/24], got %v", stale)
+	}
+}